@@ -2,6 +2,7 @@ package lxd
 
 import (
 	"bytes"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
@@ -19,7 +20,9 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gorilla/websocket"
 
@@ -27,6 +30,7 @@ import (
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/schema"
 	"github.com/lxc/lxd/shared/simplestreams"
 	"github.com/lxc/lxd/shared/version"
 )
@@ -53,7 +57,7 @@ var (
 	// LXD passes an error string along which is more informative than
 	// whatever static error message we would put here.
 	LXDErrors = map[int]error{
-		http.StatusNotFound: fmt.Errorf("not found"),
+		http.StatusNotFound: &api.ResponseError{Code: http.StatusNotFound, Name: api.ErrorNameNotFound, Message: "not found"},
 	}
 )
 
@@ -94,7 +98,7 @@ func HoistResponse(r *http.Response, rtype api.ResponseType) (*api.Response, err
 		// Try and use a known error if we have one for this code.
 		err, ok := LXDErrors[resp.Code]
 		if !ok {
-			return nil, fmt.Errorf(resp.Error)
+			return nil, resp.AsError()
 		}
 		return nil, err
 	}
@@ -106,6 +110,10 @@ func HoistResponse(r *http.Response, rtype api.ResponseType) (*api.Response, err
 	return resp, nil
 }
 
+// How long the image index of a simplestreams remote is used without
+// checking for a newer one.
+const simplestreamsCacheTTL = time.Hour
+
 // NewClient returns a new LXD client.
 func NewClient(config *Config, remote string) (*Client, error) {
 	if remote == "" {
@@ -116,6 +124,13 @@ func NewClient(config *Config, remote string) (*Client, error) {
 	if !ok {
 		return nil, fmt.Errorf("unknown remote name: %q", remote)
 	}
+	if config.Timeout > 0 {
+		r.ConnectTimeout = config.Timeout
+	}
+	if config.Project != "" {
+		r.Project = config.Project
+	}
+
 	info := ConnectInfo{
 		Name:         remote,
 		RemoteConfig: r,
@@ -126,7 +141,7 @@ func NewClient(config *Config, remote string) (*Client, error) {
 		if info.RemoteConfig.Addr == "unix://" {
 			info.RemoteConfig.Addr = fmt.Sprintf("unix:%s", shared.VarPath("unix.socket"))
 		}
-	} else {
+	} else if !strings.HasPrefix(r.Addr, "ssh:") {
 		// Read the client certificate (if it exists)
 		clientCertPath := path.Join(config.ConfigDir, "client.crt")
 		if shared.PathExists(clientCertPath) {
@@ -139,16 +154,13 @@ func NewClient(config *Config, remote string) (*Client, error) {
 		}
 
 		// Read the client key (if it exists)
-		clientKeyPath := path.Join(config.ConfigDir, "client.key")
-		if shared.PathExists(clientKeyPath) {
-			keyBytes, err := ioutil.ReadFile(clientKeyPath)
-			if err != nil {
-				return nil, err
-			}
-
-			info.ClientPEMKey = string(keyBytes)
+		key, err := config.ClientKey()
+		if err != nil {
+			return nil, err
 		}
 
+		info.ClientPEMKey = key
+
 		// Read the client key (if it exists)
 		clientCaPath := path.Join(config.ConfigDir, "client.ca")
 		if shared.PathExists(clientCaPath) {
@@ -177,6 +189,11 @@ func NewClient(config *Config, remote string) (*Client, error) {
 	}
 	c.Config = *config
 
+	// Keep the image index of simplestreams remotes around for offline use
+	if c.simplestreams != nil && config.ConfigDir != "" {
+		c.simplestreams.SetCache(filepath.Join(config.ConfigDir, "cache", remote), simplestreamsCacheTTL)
+	}
+
 	return c, nil
 }
 
@@ -239,27 +256,99 @@ func connectViaUnix(c *Client, remote *RemoteConfig) error {
 	return nil
 }
 
+// remoteTransport applies the per-remote connect timeout and TLS
+// verification policy to a transport.
+func remoteTransport(remote *RemoteConfig, tlsconfig *tls.Config) (*http.Transport, error) {
+	switch remote.Verify {
+	case "", "default":
+	case "none":
+		tlsconfig.InsecureSkipVerify = true
+	default:
+		return nil, fmt.Errorf("Invalid TLS verification policy for %s: %s", remote.Addr, remote.Verify)
+	}
+
+	if remote.ConnectTimeout < 0 || remote.ReadTimeout < 0 {
+		return nil, fmt.Errorf("Invalid timeout for %s", remote.Addr)
+	}
+
+	dial := shared.RFC3493Dialer
+	handshakeTimeout := 10 * time.Second
+	if remote.ConnectTimeout > 0 {
+		timeout := time.Duration(remote.ConnectTimeout) * time.Second
+		dial = func(network, address string) (net.Conn, error) {
+			conn, err := shared.RFC3493DialerTimeout(timeout)(network, address)
+			if err != nil {
+				return nil, fmt.Errorf("%s (connect timeout of %s)", err, timeout)
+			}
+
+			return conn, nil
+		}
+		handshakeTimeout = timeout
+	}
+
+	return &http.Transport{
+		TLSClientConfig:     tlsconfig,
+		Dial:                dial,
+		Proxy:               shared.ProxyFromEnvironment,
+		DisableKeepAlives:   true,
+		TLSHandshakeTimeout: handshakeTimeout,
+	}, nil
+}
+
+// remoteReadTimeout applies the per-remote read timeout to a transport,
+// operation waits (long-polls) being left without one.
+func remoteReadTimeout(remote *RemoteConfig, tr *http.Transport) http.RoundTripper {
+	if remote.ReadTimeout == 0 {
+		return tr
+	}
+
+	return &readTimeoutTransport{
+		transport: &http.Transport{
+			TLSClientConfig:       tr.TLSClientConfig,
+			Dial:                  tr.Dial,
+			Proxy:                 tr.Proxy,
+			DisableKeepAlives:     tr.DisableKeepAlives,
+			TLSHandshakeTimeout:   tr.TLSHandshakeTimeout,
+			ResponseHeaderTimeout: time.Duration(remote.ReadTimeout) * time.Second,
+		},
+		wait: tr,
+	}
+}
+
+// readTimeoutTransport sends the operation waits through a transport without
+// a read timeout
+type readTimeoutTransport struct {
+	transport http.RoundTripper
+	wait      http.RoundTripper
+}
+
+func (t *readTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/wait") {
+		return t.wait.RoundTrip(req)
+	}
+
+	return t.transport.RoundTrip(req)
+}
+
 func connectViaHttp(c *Client, remote *RemoteConfig, clientCert, clientKey, clientCA, serverCert string) error {
 	tlsconfig, err := shared.GetTLSConfigMem(clientCert, clientKey, clientCA, serverCert)
 	if err != nil {
 		return err
 	}
 
-	tr := &http.Transport{
-		TLSClientConfig:   tlsconfig,
-		Dial:              shared.RFC3493Dialer,
-		Proxy:             shared.ProxyFromEnvironment,
-		DisableKeepAlives: true,
+	tr, err := remoteTransport(remote, tlsconfig)
+	if err != nil {
+		return err
 	}
 
-	c.websocketDialer.NetDial = shared.RFC3493Dialer
+	c.websocketDialer.NetDial = tr.Dial
 	c.websocketDialer.TLSClientConfig = tlsconfig
 
 	justAddr := strings.TrimPrefix(remote.Addr, "https://")
 	c.BaseURL = "https://" + justAddr
 	c.BaseWSURL = "wss://" + justAddr
 	c.Transport = "https"
-	c.Http.Transport = tr
+	c.Http.Transport = remoteReadTimeout(remote, tr)
 	c.Remote = remote
 	c.Certificate = serverCert
 	// We don't actually need to connect yet, defer that until someone
@@ -291,6 +380,8 @@ func NewClientFromInfo(info ConnectInfo) (*Client, error) {
 	var err error
 	if strings.HasPrefix(info.RemoteConfig.Addr, "unix:") {
 		err = connectViaUnix(c, &info.RemoteConfig)
+	} else if strings.HasPrefix(info.RemoteConfig.Addr, "ssh:") {
+		err = connectViaSSH(c, &info.RemoteConfig)
 	} else {
 		err = connectViaHttp(c, &info.RemoteConfig, info.ClientPEMCert, info.ClientPEMKey, info.ClientPEMCa, info.ServerPEMCert)
 	}
@@ -298,19 +389,21 @@ func NewClientFromInfo(info ConnectInfo) (*Client, error) {
 		return nil, err
 	}
 
+	if info.RemoteConfig.Project != "" && info.RemoteConfig.Project != "default" && info.RemoteConfig.Protocol != "simplestreams" {
+		c.Http.Transport = &projectTransport{project: info.RemoteConfig.Project, transport: c.Http.Transport}
+	}
+
 	if info.RemoteConfig.Protocol == "simplestreams" {
 		tlsconfig, err := shared.GetTLSConfig("", "", "", nil)
 		if err != nil {
 			return nil, err
 		}
 
-		tr := &http.Transport{
-			TLSClientConfig:   tlsconfig,
-			Dial:              shared.RFC3493Dialer,
-			Proxy:             shared.ProxyFromEnvironment,
-			DisableKeepAlives: true,
+		tr, err := remoteTransport(&info.RemoteConfig, tlsconfig)
+		if err != nil {
+			return nil, err
 		}
-		c.Http.Transport = tr
+		c.Http.Transport = remoteReadTimeout(&info.RemoteConfig, tr)
 
 		ss := simplestreams.NewClient(c.Remote.Addr, c.Http, version.UserAgent)
 		c.simplestreams = ss
@@ -319,10 +412,31 @@ func NewClientFromInfo(info ConnectInfo) (*Client, error) {
 	return c, nil
 }
 
+// projectTransport makes all the requests it handles target a project
+type projectTransport struct {
+	project   string
+	transport http.RoundTripper
+}
+
+func (t *projectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("project") == "" {
+		// RoundTrip must not modify the request, so work on a copy
+		newReq := *req
+		newURL := *req.URL
+		values := newURL.Query()
+		values.Set("project", t.project)
+		newURL.RawQuery = values.Encode()
+		newReq.URL = &newURL
+		req = &newReq
+	}
+
+	return t.transport.RoundTrip(req)
+}
+
 func (c *Client) Addresses() ([]string, error) {
 	addresses := make([]string, 0)
 
-	if c.Transport == "unix" {
+	if c.Transport == "unix" || c.Transport == "ssh" {
 		serverStatus, err := c.ServerStatus()
 		if err != nil {
 			return nil, err
@@ -445,6 +559,39 @@ func (c *Client) Websocket(operation string, secret string) (*websocket.Conn, er
 	return WebsocketDial(c.websocketDialer, url)
 }
 
+// websockets connects to several websockets of an operation at once,
+// rather than paying for a connection setup after the other.
+func (c *Client) websockets(operation string, secrets []string) ([]*websocket.Conn, error) {
+	conns := make([]*websocket.Conn, len(secrets))
+	errs := make([]error, len(secrets))
+
+	wg := sync.WaitGroup{}
+	for i, secret := range secrets {
+		wg.Add(1)
+		go func(i int, secret string) {
+			defer wg.Done()
+			conns[i], errs[i] = c.Websocket(operation, secret)
+		}(i, secret)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		for _, conn := range conns {
+			if conn != nil {
+				conn.Close()
+			}
+		}
+
+		return nil, err
+	}
+
+	return conns, nil
+}
+
 func (c *Client) url(elem ...string) string {
 	// Normalize the URL
 	path := strings.Join(elem, "/")
@@ -586,6 +733,27 @@ func (c *Client) ListContainers() ([]api.Container, error) {
 	return result, nil
 }
 
+// ListContainersFull returns the containers along with their state and
+// snapshots in a single request
+func (c *Client) ListContainersFull() ([]api.ContainerFull, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	resp, err := c.get("containers?recursion=2")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []api.ContainerFull
+
+	if err := resp.MetadataAsStruct(&result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
 func (c *Client) CopyImage(image string, dest *Client, copy_aliases bool, aliases []string, public bool, autoUpdate bool, progressHandler func(progress string)) error {
 	source := shared.Jmap{
 		"type":        "image",
@@ -814,6 +982,26 @@ func (c *Client) ExportImage(image string, target string) (string, error) {
 }
 
 func (c *Client) PostImageURL(imageFile string, properties []string, public bool, aliases []string, progressHandler func(progress string)) (string, error) {
+	source := shared.Jmap{
+		"type": "url",
+		"mode": "pull",
+		"url":  imageFile}
+
+	return c.postImageSource(source, properties, public, aliases, progressHandler)
+}
+
+// PostImageOCI has the server convert an OCI image from a registry (e.g.
+// docker://alpine:latest) into a LXD image.
+func (c *Client) PostImageOCI(reference string, properties []string, public bool, aliases []string) (string, error) {
+	source := shared.Jmap{
+		"type": "oci",
+		"mode": "pull",
+		"url":  reference}
+
+	return c.postImageSource(source, properties, public, aliases, nil)
+}
+
+func (c *Client) postImageSource(source shared.Jmap, properties []string, public bool, aliases []string, progressHandler func(progress string)) (string, error) {
 	if c.Remote.Public {
 		return "", fmt.Errorf("This function isn't supported by public remotes.")
 	}
@@ -828,40 +1016,26 @@ func (c *Client) PostImageURL(imageFile string, properties []string, public bool
 		imgProperties[fields[0]] = fields[1]
 	}
 
-	source := shared.Jmap{
-		"type": "url",
-		"mode": "pull",
-		"url":  imageFile}
 	body := shared.Jmap{"public": public, "properties": imgProperties, "source": source}
 
 	operation := ""
-	handler := func(msg interface{}) {
-		if msg == nil {
-			return
-		}
-
-		event := msg.(map[string]interface{})
-		if event["type"].(string) != "operation" {
+	handler := func(event api.Event) {
+		if event.Type != "operation" {
 			return
 		}
 
-		if event["metadata"] == nil {
-			return
-		}
-
-		md := event["metadata"].(map[string]interface{})
-		if !strings.HasSuffix(operation, md["id"].(string)) {
+		md, err := event.ToOperation()
+		if err != nil {
 			return
 		}
 
-		if md["metadata"] == nil {
+		if !strings.HasSuffix(operation, md.ID) {
 			return
 		}
 
-		opMd := md["metadata"].(map[string]interface{})
-		_, ok := opMd["download_progress"]
+		progress, ok := md.Metadata["download_progress"].(string)
 		if ok {
-			progressHandler(opMd["download_progress"].(string))
+			progressHandler(progress)
 		}
 	}
 
@@ -1100,6 +1274,27 @@ func (c *Client) PutImageInfo(name string, p api.ImagePut) error {
 	return err
 }
 
+// ImageIndexCachedSince returns the date of the cached image index used by
+// the last calls because the remote couldn't be reached, the zero time if the
+// index is up to date.
+func (c *Client) ImageIndexCachedSince() time.Time {
+	if c.simplestreams == nil {
+		return time.Time{}
+	}
+
+	return c.simplestreams.CachedSince()
+}
+
+// RefreshImageIndex fetches the image index of a simplestreams remote again,
+// ignoring the cached copy.
+func (c *Client) RefreshImageIndex() error {
+	if c.simplestreams == nil {
+		return fmt.Errorf("This function is only supported by simplestreams remotes.")
+	}
+
+	return c.simplestreams.Refresh()
+}
+
 func (c *Client) ListImages() ([]api.Image, error) {
 	if c.Remote.Protocol == "simplestreams" && c.simplestreams != nil {
 		return c.simplestreams.ListImages()
@@ -1239,6 +1434,70 @@ func (c *Client) CertificateAdd(cert *x509.Certificate, name string) error {
 	return err
 }
 
+func (c *Client) CertificateTokenCreate() (*api.CertificateToken, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	resp, err := c.post("certificates/tokens", nil, api.SyncResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	token := api.CertificateToken{}
+	if err := resp.MetadataAsStruct(&token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (c *Client) CertificateInfo(fingerprint string) (*api.Certificate, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	resp, err := c.get(fmt.Sprintf("certificates/%s", fingerprint))
+	if err != nil {
+		return nil, err
+	}
+
+	cert := api.Certificate{}
+	if err := resp.MetadataAsStruct(&cert); err != nil {
+		return nil, err
+	}
+
+	return &cert, nil
+}
+
+// GetSchema returns the JSON schema the server publishes for the named object
+func (c *Client) GetSchema(name string) (schema.Schema, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	resp, err := c.get(fmt.Sprintf("schemas/%s", name))
+	if err != nil {
+		return nil, err
+	}
+
+	s := schema.Schema{}
+	if err := resp.MetadataAsStruct(&s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (c *Client) CertificateUpdate(fingerprint string, cert api.CertificatePut) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	_, err := c.put(fmt.Sprintf("certificates/%s", fingerprint), cert, api.SyncResponse)
+	return err
+}
+
 func (c *Client) CertificateRemove(fingerprint string) error {
 	if c.Remote.Public {
 		return fmt.Errorf("This function isn't supported by public remotes.")
@@ -1451,14 +1710,29 @@ func (c *Client) LocalCopy(source string, name string, config map[string]string,
 	return c.post("containers", body, api.AsyncResponse)
 }
 
-func (c *Client) Monitor(types []string, handler func(interface{}), done chan bool) error {
+func (c *Client) Monitor(types []string, handler func(api.Event), done chan bool) error {
+	return c.MonitorSince(types, "", handler, done)
+}
+
+// MonitorSince behaves like Monitor but first replays the past events the
+// server still has since the given RFC3339 timestamp or relative duration.
+func (c *Client) MonitorSince(types []string, since string, handler func(api.Event), done chan bool) error {
 	if c.Remote.Public {
 		return fmt.Errorf("This function isn't supported by public remotes.")
 	}
 
-	url := c.BaseWSURL + path.Join("/", "1.0", "events")
+	query := url.Values{}
 	if len(types) != 0 {
-		url += "?type=" + strings.Join(types, ",")
+		query.Set("type", strings.Join(types, ","))
+	}
+
+	if since != "" {
+		query.Set("since", since)
+	}
+
+	url := c.BaseWSURL + path.Join("/", "1.0", "events")
+	if len(query) != 0 {
+		url += "?" + query.Encode()
 	}
 
 	conn, err := WebsocketDial(c.websocketDialer, url)
@@ -1487,14 +1761,14 @@ func (c *Client) Monitor(types []string, handler func(interface{}), done chan bo
 		case <-done:
 			return nil
 		case data := <-readCh:
-			message := make(map[string]interface{})
+			event := api.Event{}
 
-			err = json.Unmarshal(data, &message)
+			err = json.Unmarshal(data, &event)
 			if err != nil {
 				return err
 			}
 
-			handler(message)
+			handler(event)
 		case err := <-errCh:
 			return err
 		}
@@ -1510,6 +1784,20 @@ func (c *Client) Exec(name string, cmd []string, env map[string]string,
 	stdin io.ReadCloser, stdout io.WriteCloser,
 	stderr io.WriteCloser, controlHandler func(*Client, *websocket.Conn),
 	width int, height int) (int, error) {
+	return c.ExecWithMode(name, cmd, env, stdin, stdout, stderr, controlHandler != nil, controlHandler, width, height)
+}
+
+// ExecWithMode behaves like Exec but lets the session be interactive or not
+// independently of the controlHandler. Interactive sessions get a pty,
+// non-interactive ones return when all of the command output is sent to the
+// output buffers. In both cases, a controlHandler may be passed to talk over
+// the control socket and handle things like signals and (for interactive
+// sessions) SIGWINCH.
+func (c *Client) ExecWithMode(name string, cmd []string, env map[string]string,
+	stdin io.ReadCloser, stdout io.WriteCloser,
+	stderr io.WriteCloser, interactive bool,
+	controlHandler func(*Client, *websocket.Conn),
+	width int, height int) (int, error) {
 
 	if c.Remote.Public {
 		return -1, fmt.Errorf("This function isn't supported by public remotes.")
@@ -1518,7 +1806,7 @@ func (c *Client) Exec(name string, cmd []string, env map[string]string,
 	body := shared.Jmap{
 		"command":            cmd,
 		"wait-for-websocket": true,
-		"interactive":        controlHandler != nil,
+		"interactive":        interactive,
 		"environment":        env,
 	}
 
@@ -1544,47 +1832,46 @@ func (c *Client) Exec(name string, cmd []string, env map[string]string,
 		return -1, err
 	}
 
-	if controlHandler != nil {
-		var control *websocket.Conn
-		if wsControl, ok := fds["control"]; ok {
-			control, err = c.Websocket(resp.Operation, wsControl.(string))
-			if err != nil {
-				return -1, err
-			}
-			defer control.Close()
+	// Connect all the websockets at once, the command only starts once
+	// they're all connected.
+	secrets := []string{fds["0"].(string)}
+	if !interactive {
+		secrets = append(secrets, fds["1"].(string), fds["2"].(string))
+	}
 
-			go controlHandler(c, control)
-		}
+	wsControl, hasControl := fds["control"]
+	if controlHandler != nil && hasControl {
+		secrets = append(secrets, wsControl.(string))
+	}
 
-		conn, err := c.Websocket(resp.Operation, fds["0"].(string))
-		if err != nil {
-			return -1, err
-		}
+	conns, err := c.websockets(resp.Operation, secrets)
+	if err != nil {
+		return -1, err
+	}
+
+	if controlHandler != nil && hasControl {
+		control := conns[len(conns)-1]
+		defer control.Close()
+
+		go controlHandler(c, control)
+	}
+
+	if interactive {
+		conn := conns[0]
 
 		shared.WebsocketSendStream(conn, stdin, -1)
 		<-shared.WebsocketRecvStream(stdout, conn)
 		conn.Close()
 
 	} else {
-		conns := make([]*websocket.Conn, 3)
 		dones := make([]chan bool, 3)
 
-		conns[0], err = c.Websocket(resp.Operation, fds[strconv.Itoa(0)].(string))
-		if err != nil {
-			return -1, err
-		}
 		defer conns[0].Close()
-
 		dones[0] = shared.WebsocketSendStream(conns[0], stdin, -1)
 
 		outputs := []io.WriteCloser{stdout, stderr}
 		for i := 1; i < 3; i++ {
-			conns[i], err = c.Websocket(resp.Operation, fds[strconv.Itoa(i)].(string))
-			if err != nil {
-				return -1, err
-			}
 			defer conns[i].Close()
-
 			dones[i] = shared.WebsocketRecvStream(outputs[i-1], conns[i])
 		}
 
@@ -1646,6 +1933,36 @@ func (c *Client) Action(name string, action shared.ContainerAction, timeout int,
 	return c.put(fmt.Sprintf("containers/%s/state", name), body, api.AsyncResponse)
 }
 
+// BulkAction applies the same action to several containers at once,
+// returning the operation of each of them
+func (c *Client) BulkAction(names []string, action shared.ContainerAction, timeout int, force bool, stateful bool) (map[string]api.Operation, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	body := api.ContainersStatePut{
+		Containers: names,
+		State: api.ContainerStatePut{
+			Action:   string(action),
+			Timeout:  timeout,
+			Force:    force,
+			Stateful: stateful,
+		},
+	}
+
+	resp, err := c.put("containers", body, api.SyncResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := map[string]api.Operation{}
+	if err := resp.MetadataAsStruct(&ops); err != nil {
+		return nil, err
+	}
+
+	return ops, nil
+}
+
 func (c *Client) Delete(name string) (*api.Response, error) {
 	if c.Remote.Public {
 		return nil, fmt.Errorf("This function isn't supported by public remotes.")
@@ -1686,6 +2003,26 @@ func (c *Client) ServerStatus() (*api.Server, error) {
 	return &ss, nil
 }
 
+// ServerDebug returns the daemon's self-diagnostics (goroutines, operations,
+// database and storage health).
+func (c *Client) ServerDebug() (map[string]interface{}, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	resp, err := c.baseGet(c.url("internal", "debug"))
+	if err != nil {
+		return nil, err
+	}
+
+	info := map[string]interface{}{}
+	if err := resp.MetadataAsStruct(&info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
 func (c *Client) ContainerInfo(name string) (*api.Container, error) {
 	if c.Remote.Public {
 		return nil, fmt.Errorf("This function isn't supported by public remotes.")
@@ -1724,58 +2061,245 @@ func (c *Client) ContainerState(name string) (*api.ContainerState, error) {
 	return &ct, nil
 }
 
-func (c *Client) GetLog(container string, log string) (io.Reader, error) {
+func (c *Client) ContainerUsageHistory(name string) ([]api.ContainerUsageSample, error) {
 	if c.Remote.Public {
 		return nil, fmt.Errorf("This function isn't supported by public remotes.")
 	}
 
-	uri := c.url(version.APIVersion, "containers", container, "logs", log)
-	resp, err := c.getRaw(uri)
+	samples := []api.ContainerUsageSample{}
+
+	resp, err := c.get(fmt.Sprintf("containers/%s/usage", name))
 	if err != nil {
 		return nil, err
 	}
 
-	return resp.Body, nil
+	if err := resp.MetadataAsStruct(&samples); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
 }
 
-func (c *Client) ProfileConfig(name string) (*api.Profile, error) {
+func (c *Client) ContainerExport(name string, target io.Writer, optimized bool) error {
 	if c.Remote.Public {
-		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+		return fmt.Errorf("This function isn't supported by public remotes.")
 	}
 
-	ct := api.Profile{}
-
-	resp, err := c.get(fmt.Sprintf("profiles/%s", name))
-	if err != nil {
-		return nil, err
+	uri := c.url(version.APIVersion, "containers", name, "export")
+	if optimized {
+		uri += "?optimized=1"
 	}
-
-	if err := resp.MetadataAsStruct(&ct); err != nil {
-		return nil, err
+	raw, err := c.getRaw(uri)
+	if err != nil {
+		return err
 	}
+	defer raw.Body.Close()
 
-	return &ct, nil
+	_, err = io.Copy(target, raw.Body)
+	return err
 }
 
-func (c *Client) PushFile(container string, p string, gid int, uid int, mode string, buf io.ReadSeeker) error {
+// ContainerExportDisk writes a bootable disk image of the container, in the
+// given format (raw or qcow2), to the target.
+func (c *Client) ContainerExportDisk(name string, format string, target io.Writer) error {
 	if c.Remote.Public {
 		return fmt.Errorf("This function isn't supported by public remotes.")
 	}
 
-	query := url.Values{"path": []string{p}}
-	uri := c.url(version.APIVersion, "containers", container, "files") + "?" + query.Encode()
-
-	req, err := http.NewRequest("POST", uri, buf)
+	body := api.ContainerExportPost{Format: format}
+	resp, err := c.post(fmt.Sprintf("containers/%s/export", name), body, api.AsyncResponse)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("User-Agent", version.UserAgent)
-	req.Header.Set("X-LXD-type", "file")
 
-	if mode != "" {
-		req.Header.Set("X-LXD-mode", mode)
+	op, err := c.WaitForSuccessOp(resp.Operation)
+	if err != nil {
+		return err
 	}
-	if uid != -1 {
+
+	raw, err := c.getRaw(c.url(version.APIVersion, "containers", name, "export", op.ID))
+	if err != nil {
+		return err
+	}
+	defer raw.Body.Close()
+
+	_, err = io.Copy(target, raw.Body)
+	return err
+}
+
+// ContainerResetIdentity gives a stopped container new MAC addresses and
+// cleans its DHCP client state, along with its machine-id if requested.
+func (c *Client) ContainerResetIdentity(name string, machineID bool) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	body := api.ContainerResetIdentityPost{MachineID: machineID}
+
+	_, err := c.post(fmt.Sprintf("containers/%s/reset-identity", name), body, api.SyncResponse)
+	return err
+}
+
+func (c *Client) ContainerImport(source io.Reader, name string, pool string) (*api.Response, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	uri := c.url(version.APIVersion, "containers")
+	req, err := http.NewRequest("POST", uri, source)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", version.UserAgent)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if name != "" {
+		req.Header.Set("X-LXD-name", name)
+	}
+
+	if pool != "" {
+		req.Header.Set("X-LXD-pool", pool)
+	}
+
+	raw, err := c.Http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return HoistResponse(raw, api.AsyncResponse)
+}
+
+func (c *Client) ListContainerBackups(name string) ([]api.ContainerBackup, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	resp, err := c.get(fmt.Sprintf("containers/%s/backups?recursion=1", name))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []api.ContainerBackup
+
+	if err := resp.MetadataAsStruct(&result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *Client) ContainerBackupCreate(name string, backupName string, optimized bool) (*api.Response, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	body := api.ContainerBackupsPost{Name: backupName, OptimizedStorage: optimized}
+	return c.post(fmt.Sprintf("containers/%s/backups", name), body, api.AsyncResponse)
+}
+
+func (c *Client) ContainerBackupDelete(name string, backupName string) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	_, err := c.delete(fmt.Sprintf("containers/%s/backups/%s", name, backupName), nil, api.SyncResponse)
+	return err
+}
+
+func (c *Client) ContainerBackupExport(name string, backupName string, target io.Writer) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	raw, err := c.getRaw(c.url(version.APIVersion, "containers", name, "backups", backupName, "export"))
+	if err != nil {
+		return err
+	}
+	defer raw.Body.Close()
+
+	_, err = io.Copy(target, raw.Body)
+	return err
+}
+
+func (c *Client) GetLog(container string, log string) (io.Reader, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	uri := c.url(version.APIVersion, "containers", container, "logs", log)
+	resp, err := c.getRaw(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+func (c *Client) GetConsoleLog(container string) (io.Reader, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	uri := c.url(version.APIVersion, "containers", container, "console")
+	resp, err := c.getRaw(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+func (c *Client) DeleteConsoleLog(container string) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	_, err := c.delete(fmt.Sprintf("containers/%s/console", container), nil, api.SyncResponse)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Client) ProfileConfig(name string) (*api.Profile, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	ct := api.Profile{}
+
+	resp, err := c.get(fmt.Sprintf("profiles/%s", name))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := resp.MetadataAsStruct(&ct); err != nil {
+		return nil, err
+	}
+
+	return &ct, nil
+}
+
+func (c *Client) PushFile(container string, p string, gid int, uid int, mode string, buf io.ReadSeeker) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	query := url.Values{"path": []string{p}}
+	uri := c.url(version.APIVersion, "containers", container, "files") + "?" + query.Encode()
+
+	req, err := http.NewRequest("POST", uri, buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", version.UserAgent)
+	req.Header.Set("X-LXD-type", "file")
+
+	if mode != "" {
+		req.Header.Set("X-LXD-mode", mode)
+	}
+	if uid != -1 {
 		req.Header.Set("X-LXD-uid", strconv.FormatUint(uint64(uid), 10))
 	}
 	if gid != -1 {
@@ -1912,6 +2436,10 @@ func (c *Client) PullFile(container string, p string) (int64, int64, int, string
 	}
 
 	uri := c.url(version.APIVersion, "containers", container, "files")
+	if shared.IsSnapshot(container) {
+		fields := strings.SplitN(container, shared.SnapshotDelimiter, 2)
+		uri = c.url(version.APIVersion, "containers", fields[0], "snapshots", fields[1], "files")
+	}
 	query := url.Values{"path": []string{p}}
 
 	r, err := c.getRaw(uri + "?" + query.Encode())
@@ -1974,7 +2502,7 @@ func (c *Client) RecursivePullFile(container string, p string, targetDir string)
 			return err
 		}
 
-		_, err = io.Copy(f, buf)
+		_, err = shared.CopyFileData(f, buf)
 		if err != nil {
 			return err
 		}
@@ -2000,6 +2528,13 @@ func (c *Client) DeleteFile(container string, p string) error {
 }
 
 func (c *Client) GetMigrationSourceWS(container string, stateful bool, containerOnly bool) (*api.Response, error) {
+	return c.GetMigrationSourceWSWithBwlimit(container, stateful, containerOnly, "")
+}
+
+// GetMigrationSourceWSWithBwlimit behaves like GetMigrationSourceWS but also
+// caps the bandwidth rsync uses on the source side, empty meaning the
+// server's default.
+func (c *Client) GetMigrationSourceWSWithBwlimit(container string, stateful bool, containerOnly bool, bwlimit string) (*api.Response, error) {
 	if c.Remote.Public {
 		return nil, fmt.Errorf("This function isn't supported by public remotes.")
 	}
@@ -2007,6 +2542,10 @@ func (c *Client) GetMigrationSourceWS(container string, stateful bool, container
 	body := shared.Jmap{
 		"migration": true,
 		"live":      stateful}
+
+	if bwlimit != "" {
+		body["rsync"] = api.MigrationRsyncOptions{Bwlimit: bwlimit}
+	}
 	url := fmt.Sprintf("containers/%s", container)
 	if shared.IsSnapshot(container) {
 		pieces := strings.SplitN(container, shared.SnapshotDelimiter, 2)
@@ -2285,11 +2824,20 @@ func (c *Client) RestoreSnapshot(container string, snapshotName string, stateful
 }
 
 func (c *Client) Snapshot(container string, snapshotName string, stateful bool) (*api.Response, error) {
+	return c.SnapshotWithDescription(container, snapshotName, stateful, "")
+}
+
+// SnapshotWithDescription behaves like Snapshot but also sets the description
+// of the new snapshot.
+func (c *Client) SnapshotWithDescription(container string, snapshotName string, stateful bool, description string) (*api.Response, error) {
 	if c.Remote.Public {
 		return nil, fmt.Errorf("This function isn't supported by public remotes.")
 	}
 
 	body := shared.Jmap{"name": snapshotName, "stateful": stateful}
+	if description != "" {
+		body["description"] = description
+	}
 	return c.post(fmt.Sprintf("containers/%s/snapshots", container), body, api.AsyncResponse)
 }
 
@@ -2852,6 +3400,235 @@ func (c *Client) ListNetworks() ([]api.Network, error) {
 	return networks, nil
 }
 
+func (c *Client) NetworkForwardCreate(network string, forward api.NetworkForwardsPost) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	_, err := c.post(fmt.Sprintf("networks/%s/forwards", network), forward, api.SyncResponse)
+	return err
+}
+
+func (c *Client) NetworkForwardGet(network string, address string) (api.NetworkForward, error) {
+	if c.Remote.Public {
+		return api.NetworkForward{}, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	resp, err := c.get(fmt.Sprintf("networks/%s/forwards/%s", network, address))
+	if err != nil {
+		return api.NetworkForward{}, err
+	}
+
+	forward := api.NetworkForward{}
+	if err := resp.MetadataAsStruct(&forward); err != nil {
+		return api.NetworkForward{}, err
+	}
+
+	return forward, nil
+}
+
+func (c *Client) NetworkForwardPut(network string, address string, forward api.NetworkForwardPut) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	_, err := c.put(fmt.Sprintf("networks/%s/forwards/%s", network, address), forward, api.SyncResponse)
+	return err
+}
+
+func (c *Client) NetworkForwardDelete(network string, address string) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	_, err := c.delete(fmt.Sprintf("networks/%s/forwards/%s", network, address), nil, api.SyncResponse)
+	return err
+}
+
+func (c *Client) ListNetworkForwards(network string) ([]api.NetworkForward, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	resp, err := c.get(fmt.Sprintf("networks/%s/forwards?recursion=1", network))
+	if err != nil {
+		return nil, err
+	}
+
+	forwards := []api.NetworkForward{}
+	if err := resp.MetadataAsStruct(&forwards); err != nil {
+		return nil, err
+	}
+
+	return forwards, nil
+}
+
+func (c *Client) NetworkZoneCreate(zone api.NetworkZonesPost) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	_, err := c.post("network-zones", zone, api.SyncResponse)
+	return err
+}
+
+func (c *Client) NetworkZoneGet(name string) (api.NetworkZone, error) {
+	if c.Remote.Public {
+		return api.NetworkZone{}, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	resp, err := c.get(fmt.Sprintf("network-zones/%s", name))
+	if err != nil {
+		return api.NetworkZone{}, err
+	}
+
+	zone := api.NetworkZone{}
+	if err := resp.MetadataAsStruct(&zone); err != nil {
+		return api.NetworkZone{}, err
+	}
+
+	return zone, nil
+}
+
+func (c *Client) NetworkZonePut(name string, zone api.NetworkZonePut) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	_, err := c.put(fmt.Sprintf("network-zones/%s", name), zone, api.SyncResponse)
+	return err
+}
+
+func (c *Client) NetworkZoneDelete(name string) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	_, err := c.delete(fmt.Sprintf("network-zones/%s", name), nil, api.SyncResponse)
+	return err
+}
+
+func (c *Client) ListNetworkZones() ([]api.NetworkZone, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	resp, err := c.get("network-zones?recursion=1")
+	if err != nil {
+		return nil, err
+	}
+
+	zones := []api.NetworkZone{}
+	if err := resp.MetadataAsStruct(&zones); err != nil {
+		return nil, err
+	}
+
+	return zones, nil
+}
+
+// Project functions
+func (c *Client) ProjectCreate(name string, config map[string]string) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	body := shared.Jmap{"name": name, "config": config}
+
+	_, err := c.post("projects", body, api.SyncResponse)
+	return err
+}
+
+func (c *Client) ProjectGet(name string) (api.Project, error) {
+	if c.Remote.Public {
+		return api.Project{}, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	resp, err := c.get(fmt.Sprintf("projects/%s", name))
+	if err != nil {
+		return api.Project{}, err
+	}
+
+	project := api.Project{}
+	if err := resp.MetadataAsStruct(&project); err != nil {
+		return api.Project{}, err
+	}
+
+	return project, nil
+}
+
+func (c *Client) ProjectPut(name string, project api.ProjectPut) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	_, err := c.put(fmt.Sprintf("projects/%s", name), project, api.SyncResponse)
+	return err
+}
+
+func (c *Client) ProjectDelete(name string) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	_, err := c.delete(fmt.Sprintf("projects/%s", name), nil, api.SyncResponse)
+	return err
+}
+
+func (c *Client) ListProjects() ([]api.Project, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	resp, err := c.get("projects?recursion=1")
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []api.Project{}
+	if err := resp.MetadataAsStruct(&projects); err != nil {
+		return nil, err
+	}
+
+	return projects, nil
+}
+
+// Warning functions
+func (c *Client) ListWarnings() ([]api.Warning, error) {
+	if c.Remote.Public {
+		return nil, fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	resp, err := c.get("warnings?recursion=1")
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := []api.Warning{}
+	if err := resp.MetadataAsStruct(&warnings); err != nil {
+		return nil, err
+	}
+
+	return warnings, nil
+}
+
+func (c *Client) WarningPut(id int64, warning api.WarningPut) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	_, err := c.put(fmt.Sprintf("warnings/%d", id), warning, api.SyncResponse)
+	return err
+}
+
+func (c *Client) WarningDelete(id int64) error {
+	if c.Remote.Public {
+		return fmt.Errorf("This function isn't supported by public remotes.")
+	}
+
+	_, err := c.delete(fmt.Sprintf("warnings/%d", id), nil, api.SyncResponse)
+	return err
+}
+
 // Storage functions
 func (c *Client) ListStoragePools() ([]api.StoragePool, error) {
 	if c.Remote.Public {
@@ -2998,33 +3775,23 @@ func (c *Client) StoragePoolVolumeTypeDelete(pool string, volume string, volumeT
 
 // Helper to set up a progess handler for download operations
 func wireDownloadProgressHandler(c *Client, progressHandler func(progress string), operation *string) {
-	handler := func(msg interface{}) {
-		if msg == nil {
-			return
-		}
-
-		event := msg.(map[string]interface{})
-		if event["type"].(string) != "operation" {
+	handler := func(event api.Event) {
+		if event.Type != "operation" {
 			return
 		}
 
-		if event["metadata"] == nil {
-			return
-		}
-
-		md := event["metadata"].(map[string]interface{})
-		if !strings.HasSuffix(*operation, md["id"].(string)) {
+		md, err := event.ToOperation()
+		if err != nil {
 			return
 		}
 
-		if md["metadata"] == nil {
+		if !strings.HasSuffix(*operation, md.ID) {
 			return
 		}
 
-		opMd := md["metadata"].(map[string]interface{})
-		_, ok := opMd["download_progress"]
+		progress, ok := md.Metadata["download_progress"].(string)
 		if ok {
-			progressHandler(opMd["download_progress"].(string))
+			progressHandler(progress)
 		}
 	}
 
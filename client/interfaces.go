@@ -54,6 +54,8 @@ type ContainerServer interface {
 	// Container functions
 	GetContainerNames() (names []string, err error)
 	GetContainers() (containers []api.Container, err error)
+	GetContainersWithFilter(filters []string) (containers []api.Container, err error)
+	GetContainersFull() (containers []api.ContainerFull, err error)
 	GetContainer(name string) (container *api.Container, ETag string, err error)
 	CreateContainer(container api.ContainersPost) (op *Operation, err error)
 	CreateContainerFromImage(source ImageServer, image api.Image, imgcontainer api.ContainersPost) (op *RemoteOperation, err error)
@@ -79,12 +81,16 @@ type ContainerServer interface {
 	DeleteContainerSnapshot(containerName string, name string) (op *Operation, err error)
 
 	GetContainerState(name string) (state *api.ContainerState, ETag string, err error)
+	UpdateContainersState(names []string, state api.ContainerStatePut) (ops map[string]*Operation, err error)
 	UpdateContainerState(name string, state api.ContainerStatePut, ETag string) (op *Operation, err error)
 
 	GetContainerLogfiles(name string) (logfiles []string, err error)
 	GetContainerLogfile(name string, filename string) (content io.ReadCloser, err error)
 	DeleteContainerLogfile(name string, filename string) (err error)
 
+	GetContainerConsoleLog(name string) (content io.ReadCloser, err error)
+	DeleteContainerConsoleLog(name string) (err error)
+
 	// Event handling functions
 	GetEvents() (listener *EventListener, err error)
 
@@ -236,12 +242,18 @@ type ContainerCopyArgs struct {
 
 	// If set, only the container will copied, its snapshots won't
 	ContainerOnly bool
+
+	// Tunables for the rsync transfers of a migration (ignored for local copies)
+	Rsync *api.MigrationRsyncOptions
 }
 
 // The ContainerSnapshotCopyArgs struct is used to pass additional options during container copy
 type ContainerSnapshotCopyArgs struct {
 	// If set, the container will be renamed on copy
 	Name string
+
+	// Tunables for the rsync transfers of a migration (ignored for local copies)
+	Rsync *api.MigrationRsyncOptions
 }
 
 // The ContainerExecArgs struct is used to pass additional options during container exec
@@ -149,7 +149,7 @@ func (r *ProtocolLXD) rawQuery(method string, url string, data interface{}, ETag
 
 	// Handle errors
 	if response.Type == api.ErrorResponse {
-		return nil, "", fmt.Errorf(response.Error)
+		return nil, "", response.AsError()
 	}
 
 	return &response, etag, nil
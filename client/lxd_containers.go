@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/gorilla/websocket"
@@ -48,6 +49,47 @@ func (r *ProtocolLXD) GetContainers() ([]api.Container, error) {
 	return containers, nil
 }
 
+// GetContainersWithFilter returns a list of containers whose config matches
+// all of the provided "key=value" filters
+func (r *ProtocolLXD) GetContainersWithFilter(filters []string) ([]api.Container, error) {
+	if !r.HasExtension("container_filter_config") {
+		return nil, fmt.Errorf("The server is missing the required \"container_filter_config\" API extension")
+	}
+
+	containers := []api.Container{}
+
+	v := url.Values{}
+	v.Set("recursion", "1")
+	for _, filter := range filters {
+		v.Add("filter", filter)
+	}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers?%s", v.Encode()), nil, "", &containers)
+	if err != nil {
+		return nil, err
+	}
+
+	return containers, nil
+}
+
+// GetContainersFull returns a list of containers including their state and snapshots
+func (r *ProtocolLXD) GetContainersFull() ([]api.ContainerFull, error) {
+	if !r.HasExtension("container_full") {
+		return nil, fmt.Errorf("The server is missing the required \"container_full\" API extension")
+	}
+
+	containers := []api.ContainerFull{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/containers?recursion=2", nil, "", &containers)
+	if err != nil {
+		return nil, err
+	}
+
+	return containers, nil
+}
+
 // GetContainer returns the container entry for the provided name
 func (r *ProtocolLXD) GetContainer(name string) (*api.Container, string, error) {
 	container := api.Container{}
@@ -260,6 +302,14 @@ func (r *ProtocolLXD) CopyContainer(source ContainerServer, container api.Contai
 		ContainerOnly: req.Source.ContainerOnly,
 	}
 
+	if args != nil && args.Rsync != nil {
+		if !source.HasExtension("migration_rsync_options") {
+			return nil, fmt.Errorf("The source server is missing the required \"migration_rsync_options\" API extension")
+		}
+
+		sourceReq.Rsync = args.Rsync
+	}
+
 	op, err := source.MigrateContainer(container.Name, sourceReq)
 	if err != nil {
 		return nil, err
@@ -367,24 +417,38 @@ func (r *ProtocolLXD) ExecContainer(containerName string, exec api.ContainerExec
 			}
 		}
 
-		// Call the control handler with a connection to the control socket
+		// Connect all the needed websockets at once
+		secrets := map[string]string{}
 		if args.Control != nil && fds["control"] != "" {
-			conn, err := r.GetOperationWebsocket(op.ID, fds["control"])
-			if err != nil {
-				return nil, err
+			secrets["control"] = fds["control"]
+		}
+
+		if exec.Interactive {
+			if args.Stdin != nil && args.Stdout != nil {
+				secrets["0"] = fds["0"]
+			}
+		} else {
+			for _, fd := range []string{"0", "1", "2"} {
+				if fds[fd] != "" {
+					secrets[fd] = fds[fd]
+				}
 			}
+		}
 
-			go args.Control(conn)
+		wsConns, err := r.getOperationWebsockets(op.ID, secrets)
+		if err != nil {
+			return nil, err
+		}
+
+		// Call the control handler with a connection to the control socket
+		if wsConns["control"] != nil {
+			go args.Control(wsConns["control"])
 		}
 
 		if exec.Interactive {
 			// Handle interactive sections
 			if args.Stdin != nil && args.Stdout != nil {
-				// Connect to the websocket
-				conn, err := r.GetOperationWebsocket(op.ID, fds["0"])
-				if err != nil {
-					return nil, err
-				}
+				conn := wsConns["0"]
 
 				// And attach stdin and stdout to it
 				go func() {
@@ -399,34 +463,19 @@ func (r *ProtocolLXD) ExecContainer(containerName string, exec api.ContainerExec
 			conns := []*websocket.Conn{}
 
 			// Handle stdin
-			if fds["0"] != "" {
-				conn, err := r.GetOperationWebsocket(op.ID, fds["0"])
-				if err != nil {
-					return nil, err
-				}
-
+			if conn := wsConns["0"]; conn != nil {
 				conns = append(conns, conn)
 				dones = append(dones, shared.WebsocketSendStream(conn, args.Stdin, -1))
 			}
 
 			// Handle stdout
-			if fds["1"] != "" {
-				conn, err := r.GetOperationWebsocket(op.ID, fds["1"])
-				if err != nil {
-					return nil, err
-				}
-
+			if conn := wsConns["1"]; conn != nil {
 				conns = append(conns, conn)
 				dones = append(dones, shared.WebsocketRecvStream(args.Stdout, conn))
 			}
 
 			// Handle stderr
-			if fds["2"] != "" {
-				conn, err := r.GetOperationWebsocket(op.ID, fds["2"])
-				if err != nil {
-					return nil, err
-				}
-
+			if conn := wsConns["2"]; conn != nil {
 				conns = append(conns, conn)
 				dones = append(dones, shared.WebsocketRecvStream(args.Stderr, conn))
 			}
@@ -700,6 +749,14 @@ func (r *ProtocolLXD) CopyContainerSnapshot(source ContainerServer, snapshot api
 		Migration: true,
 	}
 
+	if args != nil && args.Rsync != nil {
+		if !source.HasExtension("migration_rsync_options") {
+			return nil, fmt.Errorf("The source server is missing the required \"migration_rsync_options\" API extension")
+		}
+
+		sourceReq.Rsync = args.Rsync
+	}
+
 	op, err := source.MigrateContainerSnapshot(cName, sName, sourceReq)
 	if err != nil {
 		return nil, err
@@ -787,6 +844,38 @@ func (r *ProtocolLXD) UpdateContainerState(name string, state api.ContainerState
 	return op, nil
 }
 
+// UpdateContainersState applies the same state change to several containers,
+// returning the operation of each of them
+func (r *ProtocolLXD) UpdateContainersState(names []string, state api.ContainerStatePut) (map[string]*Operation, error) {
+	if !r.HasExtension("container_bulk_state") {
+		return nil, fmt.Errorf("The server is missing the required \"container_bulk_state\" API extension")
+	}
+
+	req := api.ContainersStatePut{
+		Containers: names,
+		State:      state,
+	}
+
+	// Send the request
+	respOperations := map[string]api.Operation{}
+	_, err := r.queryStruct("PUT", "/containers", req, "", &respOperations)
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup the Operation wrappers
+	ops := map[string]*Operation{}
+	for name, respOperation := range respOperations {
+		ops[name] = &Operation{
+			Operation: respOperation,
+			r:         r,
+			chActive:  make(chan bool),
+		}
+	}
+
+	return ops, nil
+}
+
 // GetContainerLogfiles returns a list of logfiles for the container
 func (r *ProtocolLXD) GetContainerLogfiles(name string) ([]string, error) {
 	urls := []string{}
@@ -847,3 +936,52 @@ func (r *ProtocolLXD) DeleteContainerLogfile(name string, filename string) error
 
 	return nil
 }
+
+// GetContainerConsoleLog returns the content of the container's console log
+//
+// Note that it's the caller's responsibility to close the returned ReadCloser
+func (r *ProtocolLXD) GetContainerConsoleLog(name string) (io.ReadCloser, error) {
+	if !r.HasExtension("console_log") {
+		return nil, fmt.Errorf("The server is missing the required \"console_log\" API extension")
+	}
+
+	// Prepare the HTTP request
+	url := fmt.Sprintf("%s/1.0/containers/%s/console", r.httpHost, name)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the user agent
+	if r.httpUserAgent != "" {
+		req.Header.Set("User-Agent", r.httpUserAgent)
+	}
+
+	// Send the request
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the return value for a cleaner error
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to fetch %s: %s", url, resp.Status)
+	}
+
+	return resp.Body, err
+}
+
+// DeleteContainerConsoleLog clears the container's console log
+func (r *ProtocolLXD) DeleteContainerConsoleLog(name string) error {
+	if !r.HasExtension("console_log") {
+		return fmt.Errorf("The server is missing the required \"console_log\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/containers/%s/console", name), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
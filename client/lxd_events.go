@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 )
 
 // Event handling functions
@@ -71,18 +72,17 @@ func (r *ProtocolLXD) GetEvents() (*EventListener, error) {
 			}
 
 			// Attempt to unpack the message
-			message := make(map[string]interface{})
-			err = json.Unmarshal(data, &message)
+			event := api.Event{}
+			err = json.Unmarshal(data, &event)
 			if err != nil {
 				continue
 			}
 
 			// Extract the message type
-			_, ok := message["type"]
-			if !ok {
+			if event.Type == "" {
 				continue
 			}
-			messageType := message["type"].(string)
+			messageType := event.Type
 
 			// Send the message to all handlers
 			r.eventListenersLock.Lock()
@@ -93,7 +93,7 @@ func (r *ProtocolLXD) GetEvents() (*EventListener, error) {
 						continue
 					}
 
-					go target.function(message)
+					go target.function(event)
 				}
 				listener.targetsLock.Unlock()
 			}
@@ -2,6 +2,7 @@ package lxd
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/gorilla/websocket"
 
@@ -31,6 +32,47 @@ func (r *ProtocolLXD) GetOperationWebsocket(uuid string, secret string) (*websoc
 	return r.websocket(path)
 }
 
+// getOperationWebsockets connects to several websockets of an operation at
+// once, returning them under the same keys as their secrets.
+func (r *ProtocolLXD) getOperationWebsockets(uuid string, secrets map[string]string) (map[string]*websocket.Conn, error) {
+	conns := map[string]*websocket.Conn{}
+	var firstErr error
+
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for key, secret := range secrets {
+		wg.Add(1)
+		go func(key string, secret string) {
+			defer wg.Done()
+
+			conn, err := r.GetOperationWebsocket(uuid, secret)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+
+			conns[key] = conn
+		}(key, secret)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		for _, conn := range conns {
+			conn.Close()
+		}
+
+		return nil, firstErr
+	}
+
+	return conns, nil
+}
+
 // DeleteOperation deletes (cancels) a running operation
 func (r *ProtocolLXD) DeleteOperation(uuid string) error {
 	// Send the request
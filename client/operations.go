@@ -1,7 +1,6 @@
 package lxd
 
 import (
-	"encoding/json"
 	"fmt"
 	"sync"
 
@@ -38,8 +37,8 @@ func (op *Operation) AddHandler(function func(api.Operation)) (*EventTarget, err
 	}
 
 	// Wrap the function to filter unwanted messages
-	wrapped := func(data interface{}) {
-		newOp := op.extractOperation(data)
+	wrapped := func(event api.Event) {
+		newOp := op.extractOperation(event)
 		if newOp == nil {
 			return
 		}
@@ -138,11 +137,11 @@ func (op *Operation) setupListener() error {
 
 	// Setup the handler
 	chReady := make(chan bool)
-	_, err = listener.AddHandler([]string{"operation"}, func(data interface{}) {
+	_, err = listener.AddHandler([]string{"operation"}, func(event api.Event) {
 		<-chReady
 
 		// Get an operation struct out of this data
-		newOp := op.extractOperation(data)
+		newOp := op.extractOperation(event)
 		if newOp == nil {
 			return
 		}
@@ -198,21 +197,9 @@ func (op *Operation) setupListener() error {
 	return nil
 }
 
-func (op *Operation) extractOperation(data interface{}) *api.Operation {
-	// Extract the metadata
-	meta, ok := data.(map[string]interface{})["metadata"]
-	if !ok {
-		return nil
-	}
-
-	// And attempt to decode it as JSON operation data
-	encoded, err := json.Marshal(meta)
-	if err != nil {
-		return nil
-	}
-
-	newOp := api.Operation{}
-	err = json.Unmarshal(encoded, &newOp)
+func (op *Operation) extractOperation(event api.Event) *api.Operation {
+	// Decode the metadata as operation data
+	newOp, err := event.ToOperation()
 	if err != nil {
 		return nil
 	}
@@ -222,7 +209,7 @@ func (op *Operation) extractOperation(data interface{}) *api.Operation {
 		return nil
 	}
 
-	return &newOp
+	return newOp
 }
 
 // The RemoteOperation type represents an ongoing LXD operation between two servers
@@ -250,7 +237,7 @@ func (op *RemoteOperation) AddHandler(function func(api.Operation)) (*EventTarge
 	} else {
 		// Generate a mock EventTarget
 		target = &EventTarget{
-			function: func(interface{}) { function(api.Operation{}) },
+			function: func(api.Event) { function(api.Operation{}) },
 			types:    []string{"operation"},
 		}
 	}
@@ -0,0 +1,161 @@
+package lxd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// The default location of the LXD unix socket on the remote host
+const sshDefaultSocket = "/var/lib/lxd/unix.socket"
+
+// sshConn is a connection to the unix socket of a remote LXD, relayed by
+// "lxd netcat" running over ssh.
+type sshConn struct {
+	host   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+type sshAddr string
+
+func (a sshAddr) Network() string {
+	return "ssh"
+}
+
+func (a sshAddr) String() string {
+	return string(a)
+}
+
+func (c *sshConn) Read(b []byte) (int, error) {
+	return c.stdout.Read(b)
+}
+
+func (c *sshConn) Write(b []byte) (int, error) {
+	return c.stdin.Write(b)
+}
+
+func (c *sshConn) Close() error {
+	c.stdin.Close()
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+
+	return nil
+}
+
+func (c *sshConn) LocalAddr() net.Addr {
+	return sshAddr("localhost")
+}
+
+func (c *sshConn) RemoteAddr() net.Addr {
+	return sshAddr(c.host)
+}
+
+// Deadlines aren't supported on the ssh pipes
+func (c *sshConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *sshConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *sshConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// sshDial starts ssh to the host of an ssh://[user@]host[:port][/socket]
+// remote address, relaying its standard input and output to the socket.
+func sshDial(addr string, timeout int) (net.Conn, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("Invalid ssh remote address: %s", addr)
+	}
+
+	socket := u.Path
+	if socket == "" || socket == "/" {
+		socket = sshDefaultSocket
+	}
+
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		host = u.Host
+		port = ""
+	}
+
+	// Would be taken as options by ssh
+	if strings.HasPrefix(host, "-") || (u.User != nil && strings.HasPrefix(u.User.Username(), "-")) {
+		return nil, fmt.Errorf("Invalid ssh remote address: %s", addr)
+	}
+
+	if timeout <= 0 {
+		timeout = 10
+	}
+
+	args := []string{"-T", "-o", fmt.Sprintf("ConnectTimeout=%d", timeout)}
+	if port != "" {
+		args = append(args, "-p", port)
+	}
+
+	target := host
+	if u.User != nil {
+		target = fmt.Sprintf("%s@%s", u.User.Username(), host)
+	}
+	args = append(args, "--", target, "lxd", "netcat", socket, "ssh")
+
+	cmd := exec.Command("ssh", args...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to run ssh: %s", err)
+	}
+
+	return &sshConn{host: u.Host, cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+func connectViaSSH(c *Client, remote *RemoteConfig) error {
+	c.BaseURL = "http://unix.socket"
+	c.BaseWSURL = "ws://unix.socket"
+	c.Transport = "ssh"
+
+	dial := func(network, addr string) (net.Conn, error) {
+		// As with unix sockets, the arguments are derived from BaseURL
+		return sshDial(remote.Addr, remote.ConnectTimeout)
+	}
+
+	c.Http.Transport = remoteReadTimeout(remote, &http.Transport{
+		Dial:              dial,
+		DisableKeepAlives: true,
+	})
+	c.websocketDialer.NetDial = dial
+	c.Remote = remote
+
+	st, err := c.ServerStatus()
+	if err != nil {
+		return err
+	}
+	c.Certificate = st.Environment.Certificate
+	return nil
+}
@@ -9,8 +9,6 @@ import (
 	"strings"
 
 	"gopkg.in/yaml.v2"
-
-	"github.com/lxc/lxd/shared"
 )
 
 // Config holds settings to be used by a client or daemon.
@@ -29,6 +27,17 @@ type Config struct {
 	// Command line aliases for `lxc`
 	Aliases map[string]string `yaml:"aliases"`
 
+	// Timeout, in seconds, overrides the connect timeout (and so TLS
+	// handshake) of all remotes when set (see RemoteConfig).
+	Timeout int `yaml:"-"`
+
+	// Project overrides the project used on all remotes when set.
+	Project string `yaml:"-"`
+
+	// KeyStorage is where the client key is kept, either "file" (the
+	// default, client.key in ConfigDir) or "keyring" for the OS keyring.
+	KeyStorage string `yaml:"key_storage,omitempty"`
+
 	// This is the path to the config directory, so the client can find
 	// previously stored server certs, give good error messages, and save
 	// new server certs, etc.
@@ -44,6 +53,20 @@ type RemoteConfig struct {
 	Public   bool   `yaml:"public"`
 	Protocol string `yaml:"protocol,omitempty"`
 	Static   bool   `yaml:"-"`
+
+	// ConnectTimeout and ReadTimeout are in seconds, zero meaning the
+	// default (10s to connect, no limit on waiting for a response).
+	// Operation waits aren't subject to ReadTimeout.
+	ConnectTimeout int `yaml:"connect_timeout,omitempty"`
+	ReadTimeout    int `yaml:"read_timeout,omitempty"`
+
+	// Verify is the TLS verification policy, either "default" (the
+	// stored server certificate or the system CAs) or "none".
+	Verify string `yaml:"verify,omitempty"`
+
+	// Project is the project used on the remote, empty meaning the
+	// default project.
+	Project string `yaml:"project,omitempty"`
 }
 
 var LocalRemote = RemoteConfig{
@@ -108,6 +131,8 @@ func LoadConfig(path string) (*Config, error) {
 	c.ConfigDir = filepath.Dir(path)
 
 	for k, v := range StaticRemotes {
+		// The project is the only setting kept for static remotes
+		v.Project = c.Remotes[k].Project
 		c.Remotes[k] = v
 	}
 
@@ -124,7 +149,14 @@ func LoadConfig(path string) (*Config, error) {
 
 // SaveConfig writes the provided configuration to the config file.
 func SaveConfig(c *Config, fname string) error {
-	for k := range StaticRemotes {
+	for k, v := range StaticRemotes {
+		remote, ok := c.Remotes[k]
+		if ok && remote.Project != "" {
+			v.Project = remote.Project
+			c.Remotes[k] = v
+			continue
+		}
+
 		delete(c.Remotes, k)
 	}
 
@@ -141,13 +173,23 @@ func SaveConfig(c *Config, fname string) error {
 	defer os.Remove(fname + ".new")
 
 	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("cannot marshal configuration: %v", err)
+	}
+
 	_, err = f.Write(data)
 	if err != nil {
 		return fmt.Errorf("cannot write configuration: %v", err)
 	}
 
+	// Make sure the new file is complete before it replaces the old one
+	err = f.Sync()
+	if err != nil {
+		return fmt.Errorf("cannot write configuration: %v", err)
+	}
+
 	f.Close()
-	err = shared.FileMove(fname+".new", fname)
+	err = os.Rename(fname+".new", fname)
 	if err != nil {
 		return fmt.Errorf("cannot rename temporary config file: %v", err)
 	}
@@ -0,0 +1,144 @@
+package lxd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// The OS keyring is accessed through the secret service (secret-tool) on
+// Linux and the keychain (security) on macOS.
+const keyringService = "lxd"
+
+func keyringGet(account string) (string, error) {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", account)
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+	default:
+		return "", fmt.Errorf("The OS keyring isn't supported on %s", runtime.GOOS)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("Failed to read %s from the OS keyring: %s", account, strings.TrimSpace(stderr.String()))
+	}
+
+	// The keychain adds a trailing newline
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func keyringSet(account string, value string) error {
+	var cmd *exec.Cmd
+
+	// The secret is passed on stdin so it never shows up in the process list
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label", fmt.Sprintf("LXD %s", account), "service", keyringService, "account", account)
+		cmd.Stdin = strings.NewReader(value)
+	case "darwin":
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a \"%s\" -X %s\n", keyringService, account, hex.EncodeToString([]byte(value))))
+	default:
+		return fmt.Errorf("The OS keyring isn't supported on %s", runtime.GOOS)
+	}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to store %s in the OS keyring: %s", account, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// UseKeyring returns whether the client key is kept in the OS keyring
+func (c *Config) UseKeyring() bool {
+	return c.KeyStorage == "keyring"
+}
+
+func (c *Config) keyringAccount() string {
+	return c.ConfigPath("client.key")
+}
+
+// HasClientKey returns whether a client key is available
+func (c *Config) HasClientKey() bool {
+	if shared.PathExists(c.ConfigPath("client.key")) {
+		return true
+	}
+
+	if c.UseKeyring() {
+		_, err := keyringGet(c.keyringAccount())
+		return err == nil
+	}
+
+	return false
+}
+
+// ClientKey returns the PEM encoded client key, an empty string if there's
+// none. A key file left over from before the keyring was enabled is moved
+// into the keyring.
+func (c *Config) ClientKey() (string, error) {
+	keyPath := c.ConfigPath("client.key")
+
+	if shared.PathExists(keyPath) {
+		content, err := ioutil.ReadFile(keyPath)
+		if err != nil {
+			return "", err
+		}
+
+		if c.UseKeyring() {
+			err = c.SaveClientKey(content)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		return string(content), nil
+	}
+
+	// No key is expected without a certificate
+	if !c.UseKeyring() || !shared.PathExists(c.ConfigPath("client.crt")) {
+		return "", nil
+	}
+
+	key, err := keyringGet(c.keyringAccount())
+	if err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// SaveClientKey stores the PEM encoded client key according to the
+// configured key storage.
+func (c *Config) SaveClientKey(key []byte) error {
+	keyPath := c.ConfigPath("client.key")
+
+	if !c.UseKeyring() {
+		return ioutil.WriteFile(keyPath, key, 0600)
+	}
+
+	err := keyringSet(c.keyringAccount(), string(key))
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(keyPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -3,13 +3,18 @@ package main
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
 
 	"github.com/lxc/lxd"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/gnuflag"
 	"github.com/lxc/lxd/shared/i18n"
+	"github.com/lxc/lxd/shared/version"
 )
 
 type actionCmd struct {
@@ -22,6 +27,9 @@ type actionCmd struct {
 	force       bool
 	stateful    bool
 	stateless   bool
+	all         bool
+	parallel    int
+	allTimeout  int
 }
 
 func (c *actionCmd) showByDefault() bool {
@@ -36,8 +44,15 @@ func (c *actionCmd) usage() string {
 
 	return fmt.Sprintf(i18n.G(
 		`Usage: lxc %s [<remote>:]<container> [[<remote>:]<container>...]
+       lxc %s --all [<remote>:] [--parallel=N] [--all-timeout=SECONDS]
+
+%s%s
 
-%s%s`), c.name, c.description, extra)
+With --all, the action is run against every container of the remote which
+is in a suitable state, with at most N of them processed at once. Without
+--parallel, servers supporting it are sent a single request for all of them.
+Containers not done by the end of --all-timeout are reported as failed.
+A summary of the results is printed at the end.`), c.name, c.name, c.description, extra)
 }
 
 func (c *actionCmd) flags() {
@@ -48,13 +63,17 @@ func (c *actionCmd) flags() {
 	}
 	gnuflag.BoolVar(&c.stateful, "stateful", false, i18n.G("Store the container state (only for stop)"))
 	gnuflag.BoolVar(&c.stateless, "stateless", false, i18n.G("Ignore the container state (only for start)"))
+	gnuflag.BoolVar(&c.all, "all", false, i18n.G("Run against all containers"))
+	gnuflag.IntVar(&c.parallel, "parallel", 0, i18n.G("Number of containers to process at once (0 for no limit)"))
+	gnuflag.IntVar(&c.allTimeout, "all-timeout", 0, i18n.G("Time to wait for all the containers (0 for no limit)"))
 }
 
 func (c *actionCmd) doAction(config *lxd.Config, nameArg string) error {
 	state := false
+	action := c.action
 
 	// Only store state if asked to
-	if c.action == "stop" && c.stateful {
+	if action == "stop" && c.stateful {
 		state = true
 	}
 
@@ -68,7 +87,7 @@ func (c *actionCmd) doAction(config *lxd.Config, nameArg string) error {
 		return fmt.Errorf(i18n.G("Must supply container name for: ")+"\"%s\"", nameArg)
 	}
 
-	if c.action == shared.Start {
+	if action == shared.Start {
 		current, err := d.ContainerInfo(name)
 		if err != nil {
 			return err
@@ -76,16 +95,16 @@ func (c *actionCmd) doAction(config *lxd.Config, nameArg string) error {
 
 		// "start" for a frozen container means "unfreeze"
 		if current.StatusCode == api.Frozen {
-			c.action = shared.Unfreeze
+			action = shared.Unfreeze
 		}
 
 		// Always restore state (if present) unless asked not to
-		if c.action == shared.Start && current.Stateful && !c.stateless {
+		if action == shared.Start && current.Stateful && !c.stateless {
 			state = true
 		}
 	}
 
-	resp, err := d.Action(name, c.action, c.timeout, c.force, state)
+	resp, err := d.Action(name, action, c.timeout, c.force, state)
 	if err != nil {
 		return err
 	}
@@ -101,13 +120,142 @@ func (c *actionCmd) doAction(config *lxd.Config, nameArg string) error {
 	return nil
 }
 
+// allContainers returns the containers of a remote the action applies to.
+func (c *actionCmd) allContainers(config *lxd.Config, args []string) (*lxd.Client, string, []api.Container, error) {
+	if len(args) > 1 {
+		return nil, "", nil, errArgs
+	}
+
+	remote := config.DefaultRemote
+	if len(args) == 1 {
+		remote = config.ParseRemote(args[0])
+	}
+
+	d, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	containers, err := d.ListContainers()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	cts := []api.Container{}
+	for _, ct := range containers {
+		switch c.action {
+		case shared.Start:
+			if ct.StatusCode == api.Running {
+				continue
+			}
+		case shared.Stop:
+			if ct.StatusCode == api.Stopped {
+				continue
+			}
+		case shared.Freeze, shared.Restart:
+			if ct.StatusCode != api.Running {
+				continue
+			}
+		}
+
+		cts = append(cts, ct)
+	}
+
+	return d, remote, cts, nil
+}
+
+// bulkAction runs the action against all the containers using a single
+// request per kind of state change, then waits for the resulting operations.
+func (c *actionCmd) bulkAction(d *lxd.Client, remote string, cts []api.Container, timeout time.Duration) []batchResult {
+	type change struct {
+		action   shared.ContainerAction
+		stateful bool
+	}
+
+	// Group the containers needing the same state change
+	changes := map[change][]string{}
+	for _, ct := range cts {
+		key := change{c.action, c.action == shared.Stop && c.stateful}
+		if c.action == shared.Start {
+			if ct.StatusCode == api.Frozen {
+				key.action = shared.Unfreeze
+			} else if ct.Stateful && !c.stateless {
+				key.stateful = true
+			}
+		}
+
+		changes[key] = append(changes[key], ct.Name)
+	}
+
+	results := []batchResult{}
+	waits := map[string]string{}
+	for key, names := range changes {
+		ops, err := d.BulkAction(names, key.action, c.timeout, c.force, key.stateful)
+		if err != nil {
+			for _, name := range names {
+				results = append(results, batchResult{err, fmt.Sprintf("%s:%s", remote, name)})
+			}
+			continue
+		}
+
+		for name, op := range ops {
+			waits[fmt.Sprintf("%s:%s", remote, name)] = fmt.Sprintf("/%s/operations/%s", version.APIVersion, op.ID)
+		}
+	}
+
+	names := []string{}
+	for name := range waits {
+		names = append(names, name)
+	}
+
+	return append(results, runBatch(names, 0, timeout, func(name string) error {
+		err := d.WaitForSuccess(waits[name])
+		if err != nil {
+			return fmt.Errorf("%s\n"+i18n.G("Try `lxc info --show-log %s` for more info"), err, name)
+		}
+
+		return nil
+	})...)
+}
+
 func (c *actionCmd) run(config *lxd.Config, args []string) error {
+	timeout := time.Duration(c.allTimeout) * time.Second
+
+	if c.all {
+		d, remote, cts, err := c.allContainers(config, args)
+		if err != nil {
+			return err
+		}
+
+		if len(cts) == 0 {
+			return nil
+		}
+
+		// Let the server handle all the containers at once when possible
+		if c.parallel <= 0 {
+			status, err := d.ServerStatus()
+			if err == nil && shared.StringInSlice("container_bulk_state", status.APIExtensions) {
+				return c.showSummary(c.bulkAction(d, remote, cts, timeout))
+			}
+		}
+
+		args = []string{}
+		for _, ct := range cts {
+			args = append(args, fmt.Sprintf("%s:%s", remote, ct.Name))
+		}
+	}
+
 	if len(args) == 0 {
 		return errArgs
 	}
 
 	// Run the action for every listed container
-	results := runBatch(args, func(name string) error { return c.doAction(config, name) })
+	results := runBatch(args, c.parallel, timeout, func(name string) error { return c.doAction(config, name) })
+
+	// Show a summary table when processing all containers
+	if c.all {
+		return c.showSummary(results)
+	}
 
 	// Single container is easy
 	if len(results) == 1 {
@@ -136,3 +284,38 @@ func (c *actionCmd) run(config *lxd.Config, args []string) error {
 
 	return nil
 }
+
+func (c *actionCmd) showSummary(results []batchResult) error {
+	sort.Sort(byBatchName(results))
+
+	failed := 0
+	data := [][]string{}
+	for _, result := range results {
+		status := i18n.G("OK")
+		msg := ""
+		if result.err != nil {
+			failed++
+			status = i18n.G("FAILED")
+			msg = strings.Split(result.err.Error(), "\n")[0]
+		}
+
+		data = append(data, []string{result.name, status, msg})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowLine(true)
+	table.SetHeader([]string{
+		i18n.G("NAME"),
+		i18n.G("RESULT"),
+		i18n.G("ERROR")})
+	table.AppendBulk(data)
+	table.Render()
+
+	if failed > 0 {
+		return fmt.Errorf(i18n.G("%d of %d containers failed to %s"), failed, len(results), c.name)
+	}
+
+	return nil
+}
@@ -0,0 +1,648 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/gnuflag"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+// applyEnvironmentKey records which environment created a network, profile
+// or container, only those are considered for pruning.
+const applyEnvironmentKey = "user.environment"
+
+type applyNetwork struct {
+	Description string            `yaml:"description"`
+	Config      map[string]string `yaml:"config"`
+}
+
+type applyProfile struct {
+	Description string                       `yaml:"description"`
+	Config      map[string]string            `yaml:"config"`
+	Devices     map[string]map[string]string `yaml:"devices"`
+}
+
+type applyContainer struct {
+	Image     string                       `yaml:"image"`
+	Profiles  []string                     `yaml:"profiles"`
+	Config    map[string]string            `yaml:"config"`
+	Devices   map[string]map[string]string `yaml:"devices"`
+	Ephemeral bool                         `yaml:"ephemeral"`
+	State     string                       `yaml:"state"`
+	Depends   []string                     `yaml:"depends"`
+}
+
+// applyEnvironment is the content of an environment file.
+type applyEnvironment struct {
+	Name       string                    `yaml:"name"`
+	Networks   map[string]applyNetwork   `yaml:"networks"`
+	Profiles   map[string]applyProfile   `yaml:"profiles"`
+	Containers map[string]applyContainer `yaml:"containers"`
+}
+
+type applyCmd struct {
+	prune bool
+}
+
+func (c *applyCmd) showByDefault() bool {
+	return false
+}
+
+func (c *applyCmd) usage() string {
+	return i18n.G(
+		`Usage: lxc apply <file> [<remote>:] [--prune]
+
+Create or update the networks, profiles and containers described in a YAML file.
+
+Missing objects are created and existing ones are updated to match the file,
+so applying the same file again is a no-op. Objects created from the file are
+tagged with the environment name (user.environment), those no longer in the
+file are deleted when --prune is passed.
+
+The image of a container is only used when creating it. Containers are
+created and started after the ones they depend on. Their state is either
+"running" (default) or "stopped".
+
+*File format*
+name: web
+networks:
+  webbr0:
+    config:
+      ipv4.address: 10.0.3.1/24
+profiles:
+  web:
+    devices:
+      eth0:
+        type: nic
+        nictype: bridged
+        parent: webbr0
+containers:
+  db:
+    image: ubuntu:16.04
+    profiles: [default, web]
+  frontend:
+    image: ubuntu:16.04
+    profiles: [default, web]
+    config:
+      limits.cpu: "2"
+    depends: [db]
+
+If name is missing, the file name without extension is used.`)
+}
+
+func (c *applyCmd) flags() {
+	gnuflag.BoolVar(&c.prune, "prune", false, i18n.G("Delete the objects of the environment that aren't in the file anymore"))
+}
+
+// parseApplyEnvironment parses and validates an environment file.
+func parseApplyEnvironment(content []byte, defaultName string) (*applyEnvironment, error) {
+	env := applyEnvironment{}
+	err := yaml.Unmarshal(content, &env)
+	if err != nil {
+		return nil, err
+	}
+
+	if env.Name == "" {
+		env.Name = defaultName
+	}
+
+	if env.Name == "" {
+		return nil, fmt.Errorf(i18n.G("Missing environment name"))
+	}
+
+	for name, ct := range env.Containers {
+		if ct.Image == "" {
+			return nil, fmt.Errorf(i18n.G("Missing image for container %s"), name)
+		}
+
+		if !shared.StringInSlice(ct.State, []string{"", "running", "stopped"}) {
+			return nil, fmt.Errorf(i18n.G("Invalid state for container %s: %s"), name, ct.State)
+		}
+
+		for _, dep := range ct.Depends {
+			_, ok := env.Containers[dep]
+			if !ok || dep == name {
+				return nil, fmt.Errorf(i18n.G("Invalid dependency for container %s: %s"), name, dep)
+			}
+		}
+	}
+
+	return &env, nil
+}
+
+// containerOrder returns the containers sorted so that each one comes after
+// the ones it depends on.
+func (env *applyEnvironment) containerOrder() ([]string, error) {
+	names := []string{}
+	for name := range env.Containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	order := []string{}
+	done := map[string]bool{}
+	visiting := map[string]bool{}
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		if done[name] {
+			return nil
+		}
+
+		if visiting[name] {
+			return fmt.Errorf(i18n.G("Dependency loop involving container %s"), name)
+		}
+		visiting[name] = true
+
+		deps := append([]string{}, env.Containers[name].Depends...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			err := visit(dep)
+			if err != nil {
+				return err
+			}
+		}
+
+		done[name] = true
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		err := visit(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// applyConfig returns the configuration to set on an object, keeping the
+// environment tag of an existing object or adding it to a new one.
+func (env *applyEnvironment) applyConfig(config map[string]string, current map[string]string, exists bool) (map[string]string, error) {
+	result := map[string]string{}
+	for k, v := range config {
+		result[k] = v
+	}
+
+	owner, ok := current[applyEnvironmentKey]
+	if ok && owner != env.Name {
+		return nil, fmt.Errorf(i18n.G("Owned by environment %s"), owner)
+	}
+
+	if ok || !exists {
+		result[applyEnvironmentKey] = env.Name
+	}
+
+	return result, nil
+}
+
+func (c *applyCmd) run(config *lxd.Config, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errArgs
+	}
+
+	content, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	defaultName := strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+	env, err := parseApplyEnvironment(content, defaultName)
+	if err != nil {
+		return err
+	}
+
+	order, err := env.containerOrder()
+	if err != nil {
+		return err
+	}
+
+	remote := config.DefaultRemote
+	if len(args) == 2 {
+		remote, _ = config.ParseRemoteAndContainer(args[1])
+	}
+
+	d, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	// Networks first as profiles and containers may use them
+	changes, err := c.applyNetworks(d, env)
+	if err != nil {
+		return err
+	}
+
+	count, err := c.applyProfiles(d, env)
+	if err != nil {
+		return err
+	}
+	changes += count
+
+	count, err = c.applyContainers(config, d, env, order)
+	if err != nil {
+		return err
+	}
+	changes += count
+
+	count, err = c.pruneEnvironment(d, env)
+	if err != nil {
+		return err
+	}
+	changes += count
+
+	if changes == 0 {
+		fmt.Printf(i18n.G("Environment %s is up to date")+"\n", env.Name)
+	}
+
+	return nil
+}
+
+// applyNetworkAuto checks whether a desired network value of "auto" is
+// already satisfied by the concrete value LXD picked, so that the network
+// doesn't get renumbered on each run.
+func applyNetworkAuto(key string, value string, current string) bool {
+	if value != "auto" || current == "" || current == "none" {
+		return false
+	}
+
+	return shared.StringInSlice(key, []string{"ipv4.address", "ipv6.address", "fan.underlay_subnet"})
+}
+
+func (c *applyCmd) applyNetworks(d *lxd.Client, env *applyEnvironment) (int, error) {
+	networks, err := d.ListNetworks()
+	if err != nil {
+		return 0, err
+	}
+
+	current := map[string]api.Network{}
+	for _, network := range networks {
+		current[network.Name] = network
+	}
+
+	changes := 0
+	for _, name := range applySortedKeys(env.Networks) {
+		network := env.Networks[name]
+		existing, exists := current[name]
+
+		if exists && !existing.Managed {
+			return changes, fmt.Errorf(i18n.G("Network %s exists but isn't managed by LXD"), name)
+		}
+
+		// Only the keys from the file are managed, as LXD fills in
+		// the addresses and such of new networks.
+		config, err := env.applyConfig(network.Config, existing.Config, exists)
+		if err != nil {
+			return changes, fmt.Errorf(i18n.G("Can't apply network %s: %s"), name, err)
+		}
+
+		if !exists {
+			fmt.Printf(i18n.G("Creating network %s")+"\n", name)
+			err = d.NetworkCreate(name, config)
+			if err != nil {
+				return changes, err
+			}
+			changes++
+
+			existing, err = d.NetworkGet(name)
+			if err != nil {
+				return changes, err
+			}
+
+			if network.Description == existing.Description {
+				continue
+			}
+		}
+
+		put := existing.Writable()
+		if put.Config == nil {
+			put.Config = map[string]string{}
+		}
+
+		changed := put.Description != network.Description
+		for k, v := range config {
+			if put.Config[k] != v && !applyNetworkAuto(k, v, put.Config[k]) {
+				put.Config[k] = v
+				changed = true
+			}
+		}
+
+		if !changed {
+			continue
+		}
+
+		if exists {
+			fmt.Printf(i18n.G("Updating network %s")+"\n", name)
+			changes++
+		}
+
+		put.Description = network.Description
+		err = d.NetworkPut(name, put)
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	return changes, nil
+}
+
+func (c *applyCmd) applyProfiles(d *lxd.Client, env *applyEnvironment) (int, error) {
+	profiles, err := d.ListProfiles()
+	if err != nil {
+		return 0, err
+	}
+
+	current := map[string]api.Profile{}
+	for _, profile := range profiles {
+		current[profile.Name] = profile
+	}
+
+	changes := 0
+	for _, name := range applySortedKeys(env.Profiles) {
+		profile := env.Profiles[name]
+		existing, exists := current[name]
+
+		config, err := env.applyConfig(profile.Config, existing.Config, exists)
+		if err != nil {
+			return changes, fmt.Errorf(i18n.G("Can't apply profile %s: %s"), name, err)
+		}
+
+		put := api.ProfilePut{
+			Config:      config,
+			Description: profile.Description,
+			Devices:     applyDevices(profile.Devices),
+		}
+
+		if exists {
+			if put.Description == existing.Description && applyConfigEqual(put.Config, existing.Config) && applyDevicesEqual(put.Devices, existing.Devices) {
+				continue
+			}
+
+			fmt.Printf(i18n.G("Updating profile %s")+"\n", name)
+		} else {
+			fmt.Printf(i18n.G("Creating profile %s")+"\n", name)
+			err = d.ProfileCreate(name)
+			if err != nil {
+				return changes, err
+			}
+		}
+		changes++
+
+		err = d.PutProfile(name, put)
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	return changes, nil
+}
+
+func (c *applyCmd) applyContainers(config *lxd.Config, d *lxd.Client, env *applyEnvironment, order []string) (int, error) {
+	containers, err := d.ListContainers()
+	if err != nil {
+		return 0, err
+	}
+
+	current := map[string]api.Container{}
+	for _, ct := range containers {
+		current[ct.Name] = ct
+	}
+
+	changes := 0
+	for _, name := range order {
+		ct := env.Containers[name]
+		existing, exists := current[name]
+
+		ctConfig, err := env.applyConfig(ct.Config, existing.Config, exists)
+		if err != nil {
+			return changes, fmt.Errorf(i18n.G("Can't apply container %s: %s"), name, err)
+		}
+
+		profiles := ct.Profiles
+		if profiles == nil {
+			profiles = []string{"default"}
+		}
+
+		if !exists {
+			fmt.Printf(i18n.G("Creating container %s")+"\n", name)
+
+			iremote, image := config.ParseRemoteAndContainer(ct.Image)
+			if !strings.Contains(ct.Image, ":") {
+				iremote = d.Name
+			}
+
+			resp, err := d.Init(name, iremote, image, &profiles, ctConfig, applyDevices(ct.Devices), ct.Ephemeral)
+			if err != nil {
+				return changes, err
+			}
+
+			err = d.WaitForSuccess(resp.Operation)
+			if err != nil {
+				return changes, err
+			}
+			changes++
+		} else {
+			// Keep the keys LXD manages itself
+			for k, v := range existing.Config {
+				if strings.HasPrefix(k, "volatile.") || strings.HasPrefix(k, "image.") {
+					ctConfig[k] = v
+				}
+			}
+
+			put := existing.Writable()
+			put.Config = ctConfig
+			put.Devices = applyDevices(ct.Devices)
+			put.Profiles = profiles
+			put.Ephemeral = ct.Ephemeral
+
+			if !applyConfigEqual(put.Config, existing.Config) || !applyDevicesEqual(put.Devices, existing.Devices) || !reflect.DeepEqual(put.Profiles, existing.Profiles) || put.Ephemeral != existing.Ephemeral {
+				fmt.Printf(i18n.G("Updating container %s")+"\n", name)
+				err = d.UpdateContainerConfig(name, put)
+				if err != nil {
+					return changes, err
+				}
+				changes++
+			}
+		}
+
+		state, err := d.ContainerState(name)
+		if err != nil {
+			return changes, err
+		}
+
+		var resp *api.Response
+		if ct.State == "stopped" && state.StatusCode == api.Running {
+			fmt.Printf(i18n.G("Stopping container %s")+"\n", name)
+			resp, err = d.Action(name, shared.Stop, -1, false, false)
+		} else if ct.State != "stopped" && state.StatusCode != api.Running {
+			fmt.Printf(i18n.G("Starting container %s")+"\n", name)
+			resp, err = d.Action(name, shared.Start, -1, false, false)
+		} else {
+			continue
+		}
+		if err != nil {
+			return changes, err
+		}
+		changes++
+
+		err = d.WaitForSuccess(resp.Operation)
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	return changes, nil
+}
+
+// pruneEnvironment deletes (or with --prune unset, lists) the objects tagged
+// with the environment name which aren't in the file anymore.
+func (c *applyCmd) pruneEnvironment(d *lxd.Client, env *applyEnvironment) (int, error) {
+	changes := 0
+	prune := func(kind string, name string, del func() error) error {
+		if !c.prune {
+			fmt.Fprintf(os.Stderr, i18n.G("The %s %s isn't in the file anymore, use --prune to delete it")+"\n", kind, name)
+			return nil
+		}
+
+		fmt.Printf(i18n.G("Deleting %s %s")+"\n", kind, name)
+		changes++
+		return del()
+	}
+
+	containers, err := d.ListContainers()
+	if err != nil {
+		return changes, err
+	}
+
+	for _, ct := range containers {
+		_, ok := env.Containers[ct.Name]
+		if ok || ct.Config[applyEnvironmentKey] != env.Name {
+			continue
+		}
+
+		name := ct.Name
+		err := prune(i18n.G("container"), name, func() error {
+			if ct.StatusCode == api.Running {
+				resp, err := d.Action(name, shared.Stop, -1, true, false)
+				if err != nil {
+					return err
+				}
+
+				err = d.WaitForSuccess(resp.Operation)
+				if err != nil {
+					return err
+				}
+			}
+
+			resp, err := d.Delete(name)
+			if err != nil {
+				return err
+			}
+
+			return d.WaitForSuccess(resp.Operation)
+		})
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	profiles, err := d.ListProfiles()
+	if err != nil {
+		return changes, err
+	}
+
+	for _, profile := range profiles {
+		_, ok := env.Profiles[profile.Name]
+		if ok || profile.Config[applyEnvironmentKey] != env.Name {
+			continue
+		}
+
+		name := profile.Name
+		err := prune(i18n.G("profile"), name, func() error { return d.ProfileDelete(name) })
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	networks, err := d.ListNetworks()
+	if err != nil {
+		return changes, err
+	}
+
+	for _, network := range networks {
+		_, ok := env.Networks[network.Name]
+		if ok || network.Config[applyEnvironmentKey] != env.Name {
+			continue
+		}
+
+		name := network.Name
+		err := prune(i18n.G("network"), name, func() error { return d.NetworkDelete(name) })
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	return changes, nil
+}
+
+func applySortedKeys(m interface{}) []string {
+	keys := []string{}
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func applyDevices(devices map[string]map[string]string) map[string]map[string]string {
+	if devices == nil {
+		return map[string]map[string]string{}
+	}
+
+	return devices
+}
+
+// applyConfigEqual compares two configurations, a nil one being empty.
+func applyConfigEqual(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		other, ok := b[k]
+		if !ok || other != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+func applyDevicesEqual(a map[string]map[string]string, b map[string]map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		other, ok := b[k]
+		if !ok || !applyConfigEqual(v, other) {
+			return false
+		}
+	}
+
+	return true
+}
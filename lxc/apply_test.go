@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type applyTestSuite struct {
+	suite.Suite
+}
+
+func TestApplyTestSuite(t *testing.T) {
+	suite.Run(t, new(applyTestSuite))
+}
+
+// The file name is used when the environment has no name.
+func (s *applyTestSuite) Test_parseApplyEnvironment_default_name() {
+	env, err := parseApplyEnvironment([]byte("containers:\n  c1:\n    image: ubuntu\n"), "myenv")
+	s.Nil(err)
+	s.Equal("myenv", env.Name)
+
+	env, err = parseApplyEnvironment([]byte("name: other\n"), "myenv")
+	s.Nil(err)
+	s.Equal("other", env.Name)
+}
+
+// Containers need an image, a valid state and existing dependencies.
+func (s *applyTestSuite) Test_parseApplyEnvironment_invalid() {
+	for _, content := range []string{
+		"containers:\n  c1:\n    profiles: [default]\n",
+		"containers:\n  c1:\n    image: ubuntu\n    state: frozen\n",
+		"containers:\n  c1:\n    image: ubuntu\n    depends: [c2]\n",
+		"containers:\n  c1:\n    image: ubuntu\n    depends: [c1]\n",
+	} {
+		_, err := parseApplyEnvironment([]byte(content), "myenv")
+		s.NotNil(err, content)
+	}
+}
+
+// Containers come after their dependencies, in name order otherwise.
+func (s *applyTestSuite) Test_containerOrder() {
+	env := applyEnvironment{Containers: map[string]applyContainer{
+		"web":   {Depends: []string{"db", "cache"}},
+		"db":    {},
+		"cache": {Depends: []string{"db"}},
+		"admin": {},
+	}}
+
+	order, err := env.containerOrder()
+	s.Nil(err)
+	s.Equal([]string{"admin", "db", "cache", "web"}, order)
+}
+
+// Dependency loops are refused.
+func (s *applyTestSuite) Test_containerOrder_loop() {
+	env := applyEnvironment{Containers: map[string]applyContainer{
+		"c1": {Depends: []string{"c2"}},
+		"c2": {Depends: []string{"c1"}},
+	}}
+
+	_, err := env.containerOrder()
+	s.NotNil(err)
+}
+
+// Objects of another environment are refused, existing ones not created from
+// a file aren't tagged.
+func (s *applyTestSuite) Test_applyConfig() {
+	env := applyEnvironment{Name: "myenv"}
+
+	config, err := env.applyConfig(map[string]string{"a": "b"}, nil, false)
+	s.Nil(err)
+	s.Equal(map[string]string{"a": "b", applyEnvironmentKey: "myenv"}, config)
+
+	config, err = env.applyConfig(map[string]string{"a": "b"}, map[string]string{}, true)
+	s.Nil(err)
+	s.Equal(map[string]string{"a": "b"}, config)
+
+	_, err = env.applyConfig(nil, map[string]string{applyEnvironmentKey: "other"}, true)
+	s.NotNil(err)
+}
+
+// Desired "auto" addresses are satisfied by the ones LXD picked.
+func (s *applyTestSuite) Test_applyNetworkAuto() {
+	s.True(applyNetworkAuto("ipv4.address", "auto", "10.0.3.1/24"))
+	s.True(applyNetworkAuto("ipv6.address", "auto", "fd42::1/64"))
+	s.True(applyNetworkAuto("fan.underlay_subnet", "auto", "192.0.2.0/24"))
+	s.False(applyNetworkAuto("ipv4.address", "auto", "none"))
+	s.False(applyNetworkAuto("ipv4.address", "auto", ""))
+	s.False(applyNetworkAuto("ipv4.address", "10.0.4.1/24", "10.0.3.1/24"))
+	s.False(applyNetworkAuto("ipv4.nat", "auto", "true"))
+}
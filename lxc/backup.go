@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/lxc/lxd"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/gnuflag"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+type backupCmd struct {
+	optimized      bool
+	encrypt        bool
+	passphraseFile string
+}
+
+func (c *backupCmd) showByDefault() bool {
+	return true
+}
+
+func (c *backupCmd) usage() string {
+	return i18n.G(
+		`Usage: lxc backup <subcommand> [options]
+
+Manage the backups stored by the LXD daemon.
+
+Backups are also created automatically according to the container's
+backups.schedule key, with backups.retention limiting how many are kept.
+
+lxc backup list [<remote>:]<container>
+    List the stored backups of a container.
+
+lxc backup create [<remote>:]<container> [<backup>] [--optimized-storage]
+    Create a new backup, named after the current time if no name is given.
+
+lxc backup delete [<remote>:]<container> <backup>
+    Delete a stored backup.
+
+lxc backup export [<remote>:]<container> <backup> [target] [--encrypt [--passphrase-file=FILE]]
+    Download a stored backup, by default to <backup>.tar.gz or to standard
+    output with "-", optionally encrypting it with a passphrase (see "lxc export").
+
+Stored backups are encrypted by the server itself when the
+backups.encryption_passphrase server key is set.`)
+}
+
+func (c *backupCmd) flags() {
+	gnuflag.BoolVar(&c.optimized, "optimized-storage", false, i18n.G("Use the storage backend's native format for the backup"))
+	gnuflag.BoolVar(&c.encrypt, "encrypt", false, i18n.G("Encrypt the backup with a passphrase"))
+	gnuflag.StringVar(&c.passphraseFile, "passphrase-file", "", i18n.G("File containing the backup passphrase"))
+}
+
+func (c *backupCmd) run(config *lxd.Config, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+
+	remote, name := config.ParseRemoteAndContainer(args[1])
+	client, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 2 {
+			return errArgs
+		}
+
+		return c.doBackupList(client, name)
+	case "create":
+		if len(args) > 3 {
+			return errArgs
+		}
+
+		backupName := ""
+		if len(args) == 3 {
+			backupName = args[2]
+		}
+
+		resp, err := client.ContainerBackupCreate(name, backupName, c.optimized)
+		if err != nil {
+			return err
+		}
+
+		return client.WaitForSuccess(resp.Operation)
+	case "delete":
+		if len(args) != 3 {
+			return errArgs
+		}
+
+		return client.ContainerBackupDelete(name, args[2])
+	case "export":
+		if len(args) < 3 || len(args) > 4 {
+			return errArgs
+		}
+
+		target := fmt.Sprintf("%s.tar.gz", args[2])
+		if len(args) == 4 {
+			target = args[3]
+		}
+
+		passphrase := ""
+		if c.encrypt {
+			passphrase, err = backupPassphrase(c.passphraseFile, true)
+			if err != nil {
+				return err
+			}
+		}
+
+		download := func(w io.Writer) error {
+			return client.ContainerBackupExport(name, args[2], w)
+		}
+
+		if target == "-" {
+			return backupDownload(os.Stdout, passphrase, download)
+		}
+
+		f, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		err = backupDownload(f, passphrase, download)
+		if err != nil {
+			os.Remove(target)
+			return err
+		}
+
+		return nil
+	default:
+		return errArgs
+	}
+}
+
+func (c *backupCmd) doBackupList(client *lxd.Client, name string) error {
+	backups, err := client.ListContainerBackups(name)
+	if err != nil {
+		return err
+	}
+
+	const layout = "2006/01/02 15:04 UTC"
+
+	data := [][]string{}
+	for _, backup := range backups {
+		data = append(data, []string{
+			backup.Name,
+			backup.CreationDate.UTC().Format(layout),
+			shared.GetByteSizeString(backup.Size, 2)})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowLine(true)
+	table.SetHeader([]string{
+		i18n.G("NAME"),
+		i18n.G("CREATED AT"),
+		i18n.G("SIZE")})
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
@@ -9,6 +12,7 @@ import (
 	"sort"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"gopkg.in/yaml.v2"
@@ -18,11 +22,13 @@ import (
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/gnuflag"
 	"github.com/lxc/lxd/shared/i18n"
+	"github.com/lxc/lxd/shared/schema"
 	"github.com/lxc/lxd/shared/termios"
 )
 
 type configCmd struct {
-	expanded bool
+	expanded  bool
+	machineID bool
 }
 
 func (c *configCmd) showByDefault() bool {
@@ -31,6 +37,7 @@ func (c *configCmd) showByDefault() bool {
 
 func (c *configCmd) flags() {
 	gnuflag.BoolVar(&c.expanded, "expanded", false, i18n.G("Show the expanded configuration"))
+	gnuflag.BoolVar(&c.machineID, "machine-id", false, i18n.G("Also reset the container's machine-id"))
 }
 
 func (c *configCmd) configEditHelp() string {
@@ -40,6 +47,7 @@ func (c *configCmd) configEditHelp() string {
 ###
 ### A sample configuration looks like:
 ### name: container1
+### description: My test container
 ### profiles:
 ### - default
 ### config:
@@ -54,6 +62,16 @@ func (c *configCmd) configEditHelp() string {
 ### Note that the name is shown but cannot be changed`)
 }
 
+func (c *configCmd) trustEditHelp() string {
+	return i18n.G(
+		`### This is a yaml representation of a trusted certificate.
+### Any line starting with a '# will be ignored.
+###
+### A sample configuration looks like:
+### name: laptop
+### type: client`)
+}
+
 func (c *configCmd) usage() string {
 	return i18n.G(
 		`Usage: lxc config <subcommand> [options]
@@ -77,11 +95,23 @@ lxc config show [<remote>:][container] [--expanded]
 lxc config edit [<remote>:][container]
     Edit configuration, either by launching external editor or reading STDIN.
 
+lxc config reset-identity [<remote>:]<container> [--machine-id]
+    Give a stopped container new MAC addresses and clean its DHCP client
+    state, for containers copied from one another. With --machine-id, its
+    /etc/machine-id is emptied so a new one is generated on boot.
+
 *Device management*
 
 lxc config device add [<remote>:]<container> <device> <type> [key=value...]
     Add a device to a container.
 
+lxc config device add [<remote>:]<container> - < devices.yaml
+    Add the devices described in YAML on stdin (a map of device names to
+    their properties, including "type") to a container.
+
+lxc config device edit [<remote>:]<container> <device>
+    Edit a device's properties, either by launching external editor or reading STDIN.
+
 lxc config device get [<remote>:]<container> <device> <key>
     Get a device property.
 
@@ -111,6 +141,16 @@ lxc config trust add [<remote>:] <certfile.crt>
 lxc config trust remove [<remote>:] [hostname|fingerprint]
     Remove the cert from trusted hosts.
 
+lxc config trust token [<remote>:]
+    Generate a one-time join token for "lxc remote add <remote> <token>".
+
+lxc config trust show [<remote>:]<fingerprint>
+    Show the details of a trusted cert (subject, key, validity, SANs).
+
+lxc config trust edit [<remote>:]<fingerprint>
+    Edit the name and type of a trusted cert, either by launching an
+    external editor or reading STDIN.
+
 *Examples*
 
 cat config.yaml | lxc config edit <container>
@@ -122,6 +162,9 @@ lxc config device add [<remote>:]container1 <device-name> disk source=/share/c1
 lxc config set [<remote>:]<container> limits.cpu 2
     Will set a CPU limit of "2" for the container.
 
+lxc config set [<remote>:]<container> description "Web frontend"
+    Will set the container's description.
+
 lxc config set core.https_address [::]:8443
     Will have LXD listen on IPv4 and IPv6 port 8443.
 
@@ -152,6 +195,23 @@ func (c *configCmd) doSet(config *lxd.Config, args []string, unset bool) error {
 		value = string(buf[:])
 	}
 
+	// The description is a container property rather than a config key
+	if key == "description" {
+		st, err := d.ContainerInfo(container)
+		if err != nil {
+			return err
+		}
+
+		if unset && st.Description == "" {
+			return fmt.Errorf(i18n.G("Can't unset key '%s', it's not currently set."), key)
+		}
+
+		put := st.Writable()
+		put.Description = value
+
+		return d.UpdateContainerConfig(container, put)
+	}
+
 	if unset {
 		st, err := d.ContainerInfo(container)
 		if err != nil {
@@ -338,6 +398,33 @@ func (c *configCmd) run(config *lxd.Config, args []string) error {
 
 			name, _ := shared.SplitExt(fname)
 			return d.CertificateAdd(cert, name)
+		case "token":
+			if len(args) > 3 {
+				return errArgs
+			}
+
+			remote := config.DefaultRemote
+			if len(args) == 3 {
+				remote = config.ParseRemote(args[2])
+			}
+
+			d, err := lxd.NewClient(config, remote)
+			if err != nil {
+				return err
+			}
+
+			token, err := d.CertificateTokenCreate()
+			if err != nil {
+				return err
+			}
+
+			value, err := encodeJoinToken(token)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(value)
+			return nil
 		case "remove":
 			var remote string
 			if len(args) < 3 {
@@ -354,6 +441,30 @@ func (c *configCmd) run(config *lxd.Config, args []string) error {
 			}
 
 			return d.CertificateRemove(args[len(args)-1])
+		case "show":
+			if len(args) != 3 {
+				return errArgs
+			}
+
+			remote, fingerprint := config.ParseRemoteAndContainer(args[2])
+			d, err := lxd.NewClient(config, remote)
+			if err != nil {
+				return err
+			}
+
+			return c.doTrustShow(d, fingerprint)
+		case "edit":
+			if len(args) != 3 {
+				return errArgs
+			}
+
+			remote, fingerprint := config.ParseRemoteAndContainer(args[2])
+			d, err := lxd.NewClient(config, remote)
+			if err != nil {
+				return err
+			}
+
+			return c.doTrustEdit(d, fingerprint)
 		default:
 			return errArgs
 		}
@@ -451,7 +562,12 @@ func (c *configCmd) run(config *lxd.Config, args []string) error {
 			if err != nil {
 				return err
 			}
-			fmt.Println(resp.Config[key])
+
+			if key == "description" {
+				fmt.Println(resp.Description)
+			} else {
+				fmt.Println(resp.Config[key])
+			}
 		} else {
 			resp, err := d.ServerStatus()
 			if err != nil {
@@ -491,10 +607,19 @@ func (c *configCmd) run(config *lxd.Config, args []string) error {
 			return c.deviceUnset(config, "container", args)
 		case "show":
 			return c.deviceShow(config, "container", args)
+		case "edit":
+			return c.deviceEdit(config, "container", args)
 		default:
 			return errArgs
 		}
 
+	case "reset-identity":
+		if len(args) != 2 {
+			return errArgs
+		}
+
+		return c.doResetIdentity(config, args[1])
+
 	case "edit":
 		if len(args) < 1 {
 			return errArgs
@@ -524,6 +649,29 @@ func (c *configCmd) run(config *lxd.Config, args []string) error {
 	return errArgs
 }
 
+func (c *configCmd) doResetIdentity(config *lxd.Config, name string) error {
+	remote, container := config.ParseRemoteAndContainer(name)
+	if container == "" {
+		return errArgs
+	}
+
+	d, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	serverStatus, err := d.ServerStatus()
+	if err != nil {
+		return err
+	}
+
+	if !shared.StringInSlice("container_reset_identity", serverStatus.APIExtensions) {
+		return fmt.Errorf(i18n.G("The server doesn't support resetting the identity of containers"))
+	}
+
+	return d.ContainerResetIdentity(container, c.machineID)
+}
+
 func (c *configCmd) doContainerConfigEdit(client *lxd.Client, cont string) error {
 	// If stdin isn't a terminal, read text from it
 	if !termios.IsTerminal(int(syscall.Stdin)) {
@@ -532,6 +680,16 @@ func (c *configCmd) doContainerConfigEdit(client *lxd.Client, cont string) error
 			return err
 		}
 
+		s, err := getSchema(client, "container")
+		if err != nil {
+			return err
+		}
+
+		err = schema.ValidateYAML(s, contents)
+		if err != nil {
+			return err
+		}
+
 		newdata := api.ContainerPut{}
 		err = yaml.Unmarshal(contents, &newdata)
 		if err != nil {
@@ -558,10 +716,19 @@ func (c *configCmd) doContainerConfigEdit(client *lxd.Client, cont string) error
 		return err
 	}
 
+	s, err := getSchema(client, "container")
+	if err != nil {
+		return err
+	}
+
 	for {
-		// Parse the text received from the editor
+		// Validate and parse the text received from the editor
 		newdata := api.ContainerPut{}
-		err = yaml.Unmarshal(content, &newdata)
+		err = schema.ValidateYAML(s, content)
+		if err == nil {
+			err = yaml.Unmarshal(content, &newdata)
+		}
+
 		if err == nil {
 			err = client.UpdateContainerConfig(cont, newdata)
 		}
@@ -587,6 +754,21 @@ func (c *configCmd) doContainerConfigEdit(client *lxd.Client, cont string) error
 	return nil
 }
 
+// getSchema fetches the server's schema for the named object, or nil when
+// the server is too old to publish one
+func getSchema(client *lxd.Client, name string) (schema.Schema, error) {
+	s, err := client.GetSchema(name)
+	if err != nil {
+		if api.IsErrorName(err, api.ErrorNameNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return s, nil
+}
+
 func (c *configCmd) doDaemonConfigEdit(client *lxd.Client) error {
 	// If stdin isn't a terminal, read text from it
 	if !termios.IsTerminal(int(syscall.Stdin)) {
@@ -595,6 +777,16 @@ func (c *configCmd) doDaemonConfigEdit(client *lxd.Client) error {
 			return err
 		}
 
+		s, err := getSchema(client, "server")
+		if err != nil {
+			return err
+		}
+
+		err = schema.ValidateYAML(s, contents)
+		if err != nil {
+			return err
+		}
+
 		newdata := api.ServerPut{}
 		err = yaml.Unmarshal(contents, &newdata)
 		if err != nil {
@@ -623,10 +815,19 @@ func (c *configCmd) doDaemonConfigEdit(client *lxd.Client) error {
 		return err
 	}
 
+	s, err := getSchema(client, "server")
+	if err != nil {
+		return err
+	}
+
 	for {
-		// Parse the text received from the editor
+		// Validate and parse the text received from the editor
 		newdata := api.ServerPut{}
-		err = yaml.Unmarshal(content, &newdata)
+		err = schema.ValidateYAML(s, content)
+		if err == nil {
+			err = yaml.Unmarshal(content, &newdata)
+		}
+
 		if err == nil {
 			_, err = client.UpdateServerConfig(newdata)
 		}
@@ -653,6 +854,10 @@ func (c *configCmd) doDaemonConfigEdit(client *lxd.Client) error {
 }
 
 func (c *configCmd) deviceAdd(config *lxd.Config, which string, args []string) error {
+	if len(args) == 4 && args[3] == "-" {
+		return c.deviceAddYAML(config, which, args)
+	}
+
 	if len(args) < 5 {
 		return errArgs
 	}
@@ -690,6 +895,265 @@ func (c *configCmd) deviceAdd(config *lxd.Config, which string, args []string) e
 	return err
 }
 
+func (c *configCmd) deviceEditHelp() string {
+	return i18n.G(
+		`### This is a yaml representation of the device.
+### Any line starting with a '# will be ignored.
+###
+### A device is a set of properties, one of them being its type.
+###
+### An example would look like:
+### type: disk
+### source: /share/c1
+### path: /opt`)
+}
+
+// deviceSchema describes a device, a set of string properties
+var deviceSchema = schema.Schema{
+	"type":                 "object",
+	"additionalProperties": schema.Schema{"type": "string"},
+}
+
+// parseDevicesYAML validates and parses a map of devices, errors pointing at
+// the offending YAML path
+func parseDevicesYAML(content []byte) (map[string]map[string]string, error) {
+	s := schema.Schema{"type": "object", "additionalProperties": deviceSchema}
+	err := schema.ValidateYAML(s, content)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := map[string]map[string]string{}
+	err = yaml.Unmarshal(content, &devices)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if devices[name] == nil || devices[name]["type"] == "" {
+			return nil, fmt.Errorf(i18n.G("Missing field \"type\" at %s"), name)
+		}
+	}
+
+	return devices, nil
+}
+
+// parseDeviceYAML validates and parses the properties of a single device
+func parseDeviceYAML(content []byte) (map[string]string, error) {
+	err := schema.ValidateYAML(deviceSchema, content)
+	if err != nil {
+		return nil, err
+	}
+
+	device := map[string]string{}
+	err = yaml.Unmarshal(content, &device)
+	if err != nil {
+		return nil, err
+	}
+
+	if device["type"] == "" {
+		return nil, fmt.Errorf(i18n.G("Missing field \"type\" at top level"))
+	}
+
+	return device, nil
+}
+
+// deviceUpdate applies a change to the local devices of a container or profile
+func (c *configCmd) deviceUpdate(client *lxd.Client, which string, name string, update func(devices map[string]map[string]string) error) error {
+	if which == "profile" {
+		st, err := client.ProfileConfig(name)
+		if err != nil {
+			return err
+		}
+
+		if st.Devices == nil {
+			st.Devices = map[string]map[string]string{}
+		}
+
+		err = update(st.Devices)
+		if err != nil {
+			return err
+		}
+
+		return client.PutProfile(name, st.Writable())
+	}
+
+	st, err := client.ContainerInfo(name)
+	if err != nil {
+		return err
+	}
+
+	if st.Devices == nil {
+		st.Devices = map[string]map[string]string{}
+	}
+
+	err = update(st.Devices)
+	if err != nil {
+		return err
+	}
+
+	return client.UpdateContainerConfig(name, st.Writable())
+}
+
+func (c *configCmd) deviceAddYAML(config *lxd.Config, which string, args []string) error {
+	remote, name := config.ParseRemoteAndContainer(args[2])
+
+	client, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	contents, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	newDevices, err := parseDevicesYAML(contents)
+	if err != nil {
+		return err
+	}
+
+	if len(newDevices) == 0 {
+		return fmt.Errorf(i18n.G("No device found in the input"))
+	}
+
+	names := []string{}
+	for devname := range newDevices {
+		names = append(names, devname)
+	}
+	sort.Strings(names)
+
+	err = c.deviceUpdate(client, which, name, func(devices map[string]map[string]string) error {
+		for _, devname := range names {
+			_, ok := devices[devname]
+			if ok {
+				return fmt.Errorf(i18n.G("The device already exists: %s"), devname)
+			}
+
+			devices[devname] = newDevices[devname]
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, devname := range names {
+		fmt.Printf(i18n.G("Device %s added to %s")+"\n", devname, name)
+	}
+
+	return nil
+}
+
+func (c *configCmd) deviceEdit(config *lxd.Config, which string, args []string) error {
+	if len(args) < 4 {
+		return errArgs
+	}
+
+	remote, name := config.ParseRemoteAndContainer(args[2])
+
+	client, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	devname := args[3]
+	replace := func(device map[string]string) error {
+		return c.deviceUpdate(client, which, name, func(devices map[string]map[string]string) error {
+			_, ok := devices[devname]
+			if !ok {
+				return fmt.Errorf(i18n.G("The device doesn't exist"))
+			}
+
+			devices[devname] = device
+			return nil
+		})
+	}
+
+	// If stdin isn't a terminal, read text from it
+	if !termios.IsTerminal(int(syscall.Stdin)) {
+		contents, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		device, err := parseDeviceYAML(contents)
+		if err != nil {
+			return err
+		}
+
+		return replace(device)
+	}
+
+	// Extract the current value
+	var devices map[string]map[string]string
+	if which == "profile" {
+		st, err := client.ProfileConfig(name)
+		if err != nil {
+			return err
+		}
+
+		devices = st.Devices
+	} else {
+		st, err := client.ContainerInfo(name)
+		if err != nil {
+			return err
+		}
+
+		devices = st.Devices
+	}
+
+	device, ok := devices[devname]
+	if !ok {
+		return fmt.Errorf(i18n.G("The device doesn't exist"))
+	}
+
+	data, err := yaml.Marshal(&device)
+	if err != nil {
+		return err
+	}
+
+	// Spawn the editor
+	content, err := shared.TextEditor("", []byte(c.deviceEditHelp()+"\n\n"+string(data)))
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Validate and parse the text received from the editor
+		device, err := parseDeviceYAML(content)
+		if err == nil {
+			err = replace(device)
+		}
+
+		// Respawn the editor
+		if err != nil {
+			fmt.Fprintf(os.Stderr, i18n.G("Config parsing error: %s")+"\n", err)
+			fmt.Println(i18n.G("Press enter to open the editor again"))
+
+			_, err := os.Stdin.Read(make([]byte, 1))
+			if err != nil {
+				return err
+			}
+
+			content, err = shared.TextEditor("", content)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		break
+	}
+	return nil
+}
+
 func (c *configCmd) deviceGet(config *lxd.Config, which string, args []string) error {
 	if len(args) < 5 {
 		return errArgs
@@ -939,3 +1403,141 @@ func (c *configCmd) deviceShow(config *lxd.Config, which string, args []string)
 
 	return nil
 }
+
+func (c *configCmd) doTrustShow(client *lxd.Client, fingerprint string) error {
+	if fingerprint == "" {
+		return fmt.Errorf(i18n.G("No fingerprint specified."))
+	}
+
+	entry, err := client.CertificateInfo(fingerprint)
+	if err != nil {
+		return err
+	}
+
+	certBlock, _ := pem.Decode([]byte(entry.Certificate))
+	if certBlock == nil {
+		return fmt.Errorf(i18n.G("Invalid certificate"))
+	}
+
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return err
+	}
+
+	keyType := i18n.G("unknown")
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		keyType = fmt.Sprintf("RSA %d bits", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		keyType = fmt.Sprintf("ECDSA %s", key.Curve.Params().Name)
+	}
+
+	const layout = "Jan 2, 2006 at 3:04pm (MST)"
+	fmt.Printf(i18n.G("Fingerprint: %s")+"\n", entry.Fingerprint)
+	fmt.Printf(i18n.G("Name: %s")+"\n", entry.Name)
+	fmt.Printf(i18n.G("Type: %s")+"\n", entry.Type)
+	fmt.Printf(i18n.G("Subject: %s")+"\n", c.certName(cert.Subject))
+	fmt.Printf(i18n.G("Issuer: %s")+"\n", c.certName(cert.Issuer))
+	fmt.Printf(i18n.G("Serial: %s")+"\n", cert.SerialNumber.String())
+	fmt.Printf(i18n.G("Key: %s")+"\n", keyType)
+	fmt.Printf(i18n.G("Valid from: %s")+"\n", cert.NotBefore.Format(layout))
+	if time.Now().After(cert.NotAfter) {
+		fmt.Printf(i18n.G("Valid until: %s (expired)")+"\n", cert.NotAfter.Format(layout))
+	} else {
+		fmt.Printf(i18n.G("Valid until: %s")+"\n", cert.NotAfter.Format(layout))
+	}
+
+	if len(cert.DNSNames) > 0 || len(cert.IPAddresses) > 0 {
+		fmt.Println(i18n.G("Subject alternative names:"))
+		for _, name := range cert.DNSNames {
+			fmt.Printf("  DNS: %s\n", name)
+		}
+
+		for _, ip := range cert.IPAddresses {
+			fmt.Printf("  IP: %s\n", ip.String())
+		}
+	}
+
+	return nil
+}
+
+func (c *configCmd) certName(name pkix.Name) string {
+	fields := []string{}
+	if name.CommonName != "" {
+		fields = append(fields, fmt.Sprintf("CN=%s", name.CommonName))
+	}
+
+	for _, org := range name.Organization {
+		fields = append(fields, fmt.Sprintf("O=%s", org))
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+func (c *configCmd) doTrustEdit(client *lxd.Client, fingerprint string) error {
+	if fingerprint == "" {
+		return fmt.Errorf(i18n.G("No fingerprint specified."))
+	}
+
+	// If stdin isn't a terminal, read text from it
+	if !termios.IsTerminal(int(syscall.Stdin)) {
+		contents, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		newdata := api.CertificatePut{}
+		err = yaml.Unmarshal(contents, &newdata)
+		if err != nil {
+			return err
+		}
+
+		return client.CertificateUpdate(fingerprint, newdata)
+	}
+
+	// Extract the current value
+	cert, err := client.CertificateInfo(fingerprint)
+	if err != nil {
+		return err
+	}
+
+	brief := cert.Writable()
+	data, err := yaml.Marshal(&brief)
+	if err != nil {
+		return err
+	}
+
+	// Spawn the editor
+	content, err := shared.TextEditor("", []byte(c.trustEditHelp()+"\n\n"+string(data)))
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Parse the text received from the editor
+		newdata := api.CertificatePut{}
+		err = yaml.Unmarshal(content, &newdata)
+		if err == nil {
+			err = client.CertificateUpdate(cert.Fingerprint, newdata)
+		}
+
+		// Respawn the editor
+		if err != nil {
+			fmt.Fprintf(os.Stderr, i18n.G("Config parsing error: %s")+"\n", err)
+			fmt.Println(i18n.G("Press enter to open the editor again"))
+
+			_, err := os.Stdin.Read(make([]byte, 1))
+			if err != nil {
+				return err
+			}
+
+			content, err = shared.TextEditor("", content)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		break
+	}
+	return nil
+}
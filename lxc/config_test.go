@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type configTestSuite struct {
+	suite.Suite
+}
+
+func TestConfigTestSuite(t *testing.T) {
+	suite.Run(t, new(configTestSuite))
+}
+
+// Several devices can be described at once.
+func (s *configTestSuite) Test_parseDevicesYAML() {
+	devices, err := parseDevicesYAML([]byte(`
+eth1:
+  type: nic
+  nictype: bridged
+  parent: lxdbr0
+  mtu: 1400
+data:
+  type: disk
+  source: /srv/data
+  path: /data
+  readonly: true
+`))
+	s.Nil(err)
+	s.Equal(map[string]map[string]string{
+		"eth1": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0", "mtu": "1400"},
+		"data": {"type": "disk", "source": "/srv/data", "path": "/data", "readonly": "true"},
+	}, devices)
+}
+
+// Errors point at the offending YAML path.
+func (s *configTestSuite) Test_parseDevicesYAML_paths() {
+	_, err := parseDevicesYAML([]byte("eth1:\n  type: nic\n  parent:\n    name: lxdbr0\n"))
+	s.EqualError(err, "Expected a string at eth1.parent")
+
+	_, err = parseDevicesYAML([]byte("eth1: nic\n"))
+	s.EqualError(err, "Expected an object at eth1")
+
+	_, err = parseDevicesYAML([]byte("eth1:\n  nictype: bridged\n"))
+	s.EqualError(err, "Missing field \"type\" at eth1")
+}
+
+// A single device is a flat set of properties.
+func (s *configTestSuite) Test_parseDeviceYAML() {
+	device, err := parseDeviceYAML([]byte("type: disk\npath: /data\nsource: /srv/data\n"))
+	s.Nil(err)
+	s.Equal(map[string]string{"type": "disk", "path": "/data", "source": "/srv/data"}, device)
+
+	_, err = parseDeviceYAML([]byte("type: disk\nsource: [a, b]\n"))
+	s.EqualError(err, "Expected a string at source")
+
+	_, err = parseDeviceYAML([]byte("path: /data\n"))
+	s.EqualError(err, "Missing field \"type\" at top level")
+}
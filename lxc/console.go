@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lxc/lxd"
+	"github.com/lxc/lxd/shared/gnuflag"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+type consoleCmd struct {
+	showLog  bool
+	clearLog bool
+}
+
+func (c *consoleCmd) showByDefault() bool {
+	return true
+}
+
+func (c *consoleCmd) usage() string {
+	return i18n.G(
+		`Usage: lxc console [<remote>:]<container> --show-log [--clear]
+
+Interact with the container's console.
+
+lxc console [<remote>:]<container> --show-log
+    Show the container's console log, including output from before
+    the last container restart.
+
+lxc console [<remote>:]<container> --show-log --clear
+    Clear the container's console log.`)
+}
+
+func (c *consoleCmd) flags() {
+	gnuflag.BoolVar(&c.showLog, "show-log", false, i18n.G("Retrieve the container's console log"))
+	gnuflag.BoolVar(&c.clearLog, "clear", false, i18n.G("Clear the container's console log"))
+}
+
+func (c *consoleCmd) run(config *lxd.Config, args []string) error {
+	if len(args) != 1 {
+		return errArgs
+	}
+
+	if !c.showLog {
+		return fmt.Errorf(i18n.G("Attaching to the console isn't supported, use --show-log to retrieve the console log"))
+	}
+
+	remote, name := config.ParseRemoteAndContainer(args[0])
+	d, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	if c.clearLog {
+		return d.DeleteConsoleLog(name)
+	}
+
+	log, err := d.GetConsoleLog(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(os.Stdout, log)
+	return err
+}
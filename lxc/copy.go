@@ -16,6 +16,7 @@ type copyCmd struct {
 	confArgs      configList
 	ephem         bool
 	containerOnly bool
+	bwlimit       string
 }
 
 func (c *copyCmd) showByDefault() bool {
@@ -24,7 +25,7 @@ func (c *copyCmd) showByDefault() bool {
 
 func (c *copyCmd) usage() string {
 	return i18n.G(
-		`Usage: lxc copy [<remote>:]<source>[/<snapshot>] [[<remote>:]<destination>] [--ephemeral|e] [--profile|-p <profile>...] [--config|-c <key=value>...] [--container-only]
+		`Usage: lxc copy [<remote>:]<source>[/<snapshot>] [[<remote>:]<destination>] [--ephemeral|e] [--profile|-p <profile>...] [--config|-c <key=value>...] [--container-only] [--bwlimit <limit>]
 
 Copy containers within or in between LXD instances.`)
 }
@@ -37,6 +38,7 @@ func (c *copyCmd) flags() {
 	gnuflag.BoolVar(&c.ephem, "ephemeral", false, i18n.G("Ephemeral container"))
 	gnuflag.BoolVar(&c.ephem, "e", false, i18n.G("Ephemeral container"))
 	gnuflag.BoolVar(&c.containerOnly, "container-only", false, i18n.G("Copy the container without its snapshots"))
+	gnuflag.StringVar(&c.bwlimit, "bwlimit", "", i18n.G("Bandwidth limit for the rsync transfers between servers (e.g. 10m)"))
 }
 
 func (c *copyCmd) copyContainer(config *lxd.Config, sourceResource string, destResource string, keepVolatile bool, ephemeral int, stateful bool, containerOnly bool) error {
@@ -180,7 +182,18 @@ func (c *copyCmd) copyContainer(config *lxd.Config, sourceResource string, destR
 		}
 	}
 
-	sourceWSResponse, err := source.GetMigrationSourceWS(sourceName, stateful, containerOnly)
+	if c.bwlimit != "" {
+		serverStatus, err := source.ServerStatus()
+		if err != nil {
+			return err
+		}
+
+		if !shared.StringInSlice("migration_rsync_options", serverStatus.APIExtensions) {
+			return fmt.Errorf(i18n.G("The source server doesn't support bandwidth limits"))
+		}
+	}
+
+	sourceWSResponse, err := source.GetMigrationSourceWSWithBwlimit(sourceName, stateful, containerOnly, c.bwlimit)
 	if err != nil {
 		return err
 	}
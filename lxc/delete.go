@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/lxc/lxd"
@@ -24,9 +25,16 @@ func (c *deleteCmd) showByDefault() bool {
 
 func (c *deleteCmd) usage() string {
 	return i18n.G(
-		`Usage: lxc delete [<remote>:]<container>[/<snapshot>] [[<remote>:]<container>[/<snapshot>]...]
+		`Usage: lxc delete [<remote>:]<container>[/<snapshot>] [[<remote>:]<container>[/<snapshot>]...] [--force|-f] [--interactive|-i]
 
-Delete containers and snapshots.`)
+Delete containers and snapshots.
+
+Names may contain shell wildcards ("*", "?", "[...]"), in which case they
+match containers or, when including a "/", snapshots (e.g. "web*" or "c1/*").
+
+With --interactive, the objects to delete are listed and each of them needs
+to be confirmed. Snapshots of a container which was deleted before them are
+skipped.`)
 }
 
 func (c *deleteCmd) flags() {
@@ -36,16 +44,13 @@ func (c *deleteCmd) flags() {
 	gnuflag.BoolVar(&c.interactive, "interactive", false, i18n.G("Require user confirmation"))
 }
 
-func (c *deleteCmd) promptDelete(name string) error {
+func (c *deleteCmd) promptDelete(name string) bool {
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Printf(i18n.G("Remove %s (yes/no): "), name)
 	input, _ := reader.ReadString('\n')
 	input = strings.TrimSuffix(input, "\n")
-	if !shared.StringInSlice(strings.ToLower(input), []string{i18n.G("yes")}) {
-		return fmt.Errorf(i18n.G("User aborted delete operation."))
-	}
 
-	return nil
+	return shared.StringInSlice(strings.ToLower(input), []string{i18n.G("yes")})
 }
 
 func (c *deleteCmd) doDelete(d *lxd.Client, name string) error {
@@ -57,11 +62,69 @@ func (c *deleteCmd) doDelete(d *lxd.Client, name string) error {
 	return d.WaitForSuccess(resp.Operation)
 }
 
+// expandName returns the containers or snapshots matching a name which may
+// contain wildcards.
+func (c *deleteCmd) expandName(d *lxd.Client, name string) ([]string, error) {
+	if !strings.ContainsAny(name, "*?[") {
+		return []string{name}, nil
+	}
+
+	names := []string{}
+	if shared.IsSnapshot(name) {
+		fields := strings.SplitN(name, shared.SnapshotDelimiter, 2)
+		snapshots, err := d.ListSnapshots(fields[0])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, snapshot := range snapshots {
+			match, err := path.Match(name, snapshot.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			if match {
+				names = append(names, snapshot.Name)
+			}
+		}
+	} else {
+		containers, err := d.ListContainers()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, container := range containers {
+			match, err := path.Match(name, container.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			if match {
+				names = append(names, container.Name)
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf(i18n.G("No container or snapshot matches %s"), name)
+	}
+
+	return names, nil
+}
+
 func (c *deleteCmd) run(config *lxd.Config, args []string) error {
 	if len(args) == 0 {
 		return errArgs
 	}
 
+	type target struct {
+		client *lxd.Client
+		remote string
+		name   string
+	}
+
+	// Resolve all the names first, so the whole list can be confirmed
+	targets := []target{}
 	for _, nameArg := range args {
 		remote, name := config.ParseRemoteAndContainer(nameArg)
 
@@ -70,15 +133,47 @@ func (c *deleteCmd) run(config *lxd.Config, args []string) error {
 			return err
 		}
 
-		if c.interactive {
-			err := c.promptDelete(name)
-			if err != nil {
-				return err
+		names, err := c.expandName(d, name)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			targets = append(targets, target{client: d, remote: remote, name: name})
+		}
+	}
+
+	if c.interactive {
+		fmt.Println(i18n.G("The following will be deleted:"))
+		for _, t := range targets {
+			fmt.Printf("  %s:%s\n", t.remote, t.name)
+		}
+	}
+
+	// Containers deleted so far, which took care of their snapshots
+	deleted := map[string]bool{}
+	for _, t := range targets {
+		d := t.client
+		name := t.name
+
+		if shared.IsSnapshot(name) {
+			fields := strings.SplitN(name, shared.SnapshotDelimiter, 2)
+			if deleted[t.remote+":"+fields[0]] {
+				continue
 			}
 		}
 
+		if c.interactive && !c.promptDelete(name) {
+			continue
+		}
+
 		if shared.IsSnapshot(name) {
-			return c.doDelete(d, name)
+			err := c.doDelete(d, name)
+			if err != nil {
+				return err
+			}
+
+			continue
 		}
 
 		ct, err := d.ContainerInfo(name)
@@ -105,14 +200,19 @@ func (c *deleteCmd) run(config *lxd.Config, args []string) error {
 				return fmt.Errorf(i18n.G("Stopping container failed!"))
 			}
 
+			// Ephemeral containers are gone once stopped
 			if ct.Ephemeral == true {
-				return nil
+				deleted[t.remote+":"+name] = true
+				continue
 			}
 		}
 
 		if err := c.doDelete(d, name); err != nil {
 			return err
 		}
+
+		deleted[t.remote+":"+name] = true
 	}
+
 	return nil
 }
@@ -42,6 +42,9 @@ type execCmd struct {
 	forceInteractive    bool
 	forceNonInteractive bool
 	disableStdin        bool
+	noSignalForwarding  bool
+
+	interactive bool
 }
 
 func (c *execCmd) showByDefault() bool {
@@ -50,11 +53,13 @@ func (c *execCmd) showByDefault() bool {
 
 func (c *execCmd) usage() string {
 	return i18n.G(
-		`Usage: lxc exec [<remote>:]<container> [-t] [-T] [-n] [--mode=auto|interactive|non-interactive] [--env KEY=VALUE...] [--] <command line>
+		`Usage: lxc exec [<remote>:]<container> [-t] [-T] [-n] [--mode=auto|interactive|non-interactive] [--no-signal-forwarding] [--env KEY=VALUE...] [--] <command line>
 
 Execute commands in containers.
 
-Mode defaults to non-interactive, interactive mode is selected if both stdin AND stdout are terminals (stderr is ignored).`)
+Mode defaults to non-interactive, interactive mode is selected if both stdin AND stdout are terminals (stderr is ignored).
+
+Signals received by lxc (e.g. SIGINT, SIGTERM or SIGHUP) are forwarded to the command unless --no-signal-forwarding is passed.`)
 }
 
 func (c *execCmd) flags() {
@@ -63,6 +68,7 @@ func (c *execCmd) flags() {
 	gnuflag.BoolVar(&c.forceInteractive, "t", false, i18n.G("Force pseudo-terminal allocation"))
 	gnuflag.BoolVar(&c.forceNonInteractive, "T", false, i18n.G("Disable pseudo-terminal allocation"))
 	gnuflag.BoolVar(&c.disableStdin, "n", false, i18n.G("Disable stdin (reads from /dev/null)"))
+	gnuflag.BoolVar(&c.noSignalForwarding, "no-signal-forwarding", false, i18n.G("Don't forward signals to the command"))
 }
 
 func (c *execCmd) sendTermSize(control *websocket.Conn) error {
@@ -135,10 +141,9 @@ func (c *execCmd) run(config *lxd.Config, args []string) error {
 		return err
 	}
 
-	/* FIXME: Default values for HOME and USER are now handled by LXD.
-	   This code should be removed after most users upgraded.
-	*/
-	env := map[string]string{"HOME": "/root", "USER": "root"}
+	// HOME and USER are left to LXD, so the container's environment.* keys
+	// can override its defaults
+	env := map[string]string{}
 	if myTerm, ok := c.getTERM(); ok {
 		env["TERM"] = myTerm
 	}
@@ -174,8 +179,11 @@ func (c *execCmd) run(config *lxd.Config, args []string) error {
 		defer termios.Restore(cfd, oldttystate)
 	}
 
+	c.interactive = interactive
+
+	// Without a pty nor signals to forward, there's nothing to control
 	handler := c.controlSocketHandler
-	if !interactive {
+	if !interactive && c.noSignalForwarding {
 		handler = nil
 	}
 
@@ -194,7 +202,7 @@ func (c *execCmd) run(config *lxd.Config, args []string) error {
 	}
 
 	stdout := c.getStdout()
-	ret, err := d.Exec(name, args[1:], env, stdin, stdout, os.Stderr, handler, width, height)
+	ret, err := d.ExecWithMode(name, args[1:], env, stdin, stdout, os.Stderr, interactive, handler, width, height)
 	if err != nil {
 		return err
 	}
@@ -23,121 +23,70 @@ func (c *execCmd) getTERM() (string, bool) {
 }
 
 func (c *execCmd) controlSocketHandler(d *lxd.Client, control *websocket.Conn) {
+	signals := []os.Signal{}
+	if c.interactive {
+		signals = append(signals, syscall.SIGWINCH)
+	}
+
+	if !c.noSignalForwarding {
+		signals = append(signals,
+			syscall.SIGTERM,
+			syscall.SIGHUP,
+			syscall.SIGINT,
+			syscall.SIGQUIT,
+			syscall.SIGABRT,
+			syscall.SIGTSTP,
+			syscall.SIGTTIN,
+			syscall.SIGTTOU,
+			syscall.SIGUSR1,
+			syscall.SIGUSR2,
+			syscall.SIGSEGV,
+			syscall.SIGCONT)
+	}
+
 	ch := make(chan os.Signal, 10)
-	signal.Notify(ch,
-		syscall.SIGWINCH,
-		syscall.SIGTERM,
-		syscall.SIGHUP,
-		syscall.SIGINT,
-		syscall.SIGQUIT,
-		syscall.SIGABRT,
-		syscall.SIGTSTP,
-		syscall.SIGTTIN,
-		syscall.SIGTTOU,
-		syscall.SIGUSR1,
-		syscall.SIGUSR2,
-		syscall.SIGSEGV,
-		syscall.SIGCONT)
+	signal.Notify(ch, signals...)
+	defer signal.Stop(ch)
 
 	closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
 	defer control.WriteMessage(websocket.CloseMessage, closeMsg)
 
+	// The terminal may have been resized since the session was requested
+	if c.interactive {
+		err := c.sendTermSize(control)
+		if err != nil {
+			logger.Debugf("error setting term size %s", err)
+			return
+		}
+	}
+
 	for {
 		sig := <-ch
-		switch sig {
-		case syscall.SIGWINCH:
+		if sig == syscall.SIGWINCH {
 			logger.Debugf("Received '%s signal', updating window geometry.", sig)
 			err := c.sendTermSize(control)
 			if err != nil {
 				logger.Debugf("error setting term size %s", err)
 				return
 			}
-		case syscall.SIGTERM:
-			logger.Debugf("Received '%s signal', forwarding to executing program.", sig)
-			err := c.forwardSignal(control, syscall.SIGTERM)
-			if err != nil {
-				logger.Debugf("Failed to forward signal '%s'.", syscall.SIGTERM)
-				return
-			}
-		case syscall.SIGHUP:
-			logger.Debugf("Received '%s signal', forwarding to executing program.", sig)
-			err := c.forwardSignal(control, syscall.SIGHUP)
-			if err != nil {
-				logger.Debugf("Failed to forward signal '%s'.", syscall.SIGHUP)
-				return
-			}
-		case syscall.SIGINT:
-			logger.Debugf("Received '%s signal', forwarding to executing program.", sig)
-			err := c.forwardSignal(control, syscall.SIGINT)
-			if err != nil {
-				logger.Debugf("Failed to forward signal '%s'.", syscall.SIGINT)
-				return
-			}
-		case syscall.SIGQUIT:
-			logger.Debugf("Received '%s signal', forwarding to executing program.", sig)
-			err := c.forwardSignal(control, syscall.SIGQUIT)
-			if err != nil {
-				logger.Debugf("Failed to forward signal '%s'.", syscall.SIGQUIT)
-				return
-			}
-		case syscall.SIGABRT:
-			logger.Debugf("Received '%s signal', forwarding to executing program.", sig)
-			err := c.forwardSignal(control, syscall.SIGABRT)
-			if err != nil {
-				logger.Debugf("Failed to forward signal '%s'.", syscall.SIGABRT)
-				return
-			}
-		case syscall.SIGTSTP:
-			logger.Debugf("Received '%s signal', forwarding to executing program.", sig)
-			err := c.forwardSignal(control, syscall.SIGTSTP)
-			if err != nil {
-				logger.Debugf("Failed to forward signal '%s'.", syscall.SIGTSTP)
-				return
-			}
-		case syscall.SIGTTIN:
-			logger.Debugf("Received '%s signal', forwarding to executing program.", sig)
-			err := c.forwardSignal(control, syscall.SIGTTIN)
-			if err != nil {
-				logger.Debugf("Failed to forward signal '%s'.", syscall.SIGTTIN)
-				return
-			}
-		case syscall.SIGTTOU:
-			logger.Debugf("Received '%s signal', forwarding to executing program.", sig)
-			err := c.forwardSignal(control, syscall.SIGTTOU)
-			if err != nil {
-				logger.Debugf("Failed to forward signal '%s'.", syscall.SIGTTOU)
-				return
-			}
-		case syscall.SIGUSR1:
-			logger.Debugf("Received '%s signal', forwarding to executing program.", sig)
-			err := c.forwardSignal(control, syscall.SIGUSR1)
-			if err != nil {
-				logger.Debugf("Failed to forward signal '%s'.", syscall.SIGUSR1)
-				return
-			}
-		case syscall.SIGUSR2:
-			logger.Debugf("Received '%s signal', forwarding to executing program.", sig)
-			err := c.forwardSignal(control, syscall.SIGUSR2)
-			if err != nil {
-				logger.Debugf("Failed to forward signal '%s'.", syscall.SIGUSR2)
-				return
-			}
-		case syscall.SIGSEGV:
-			logger.Debugf("Received '%s signal', forwarding to executing program.", sig)
-			err := c.forwardSignal(control, syscall.SIGSEGV)
-			if err != nil {
-				logger.Debugf("Failed to forward signal '%s'.", syscall.SIGSEGV)
-				return
-			}
-		case syscall.SIGCONT:
-			logger.Debugf("Received '%s signal', forwarding to executing program.", sig)
-			err := c.forwardSignal(control, syscall.SIGCONT)
+
+			continue
+		}
+
+		logger.Debugf("Received '%s signal', forwarding to executing program.", sig)
+		err := c.forwardSignal(control, sig.(syscall.Signal))
+		if err != nil {
+			logger.Debugf("Failed to forward signal '%s'.", sig)
+			return
+		}
+
+		// Resuming after a suspend, the window may have changed meanwhile
+		if sig == syscall.SIGCONT && c.interactive {
+			err := c.sendTermSize(control)
 			if err != nil {
-				logger.Debugf("Failed to forward signal '%s'.", syscall.SIGCONT)
+				logger.Debugf("error setting term size %s", err)
 				return
 			}
-		default:
-			break
 		}
 	}
 }
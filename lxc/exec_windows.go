@@ -36,6 +36,10 @@ func (c *execCmd) controlSocketHandler(d *lxd.Client, control *websocket.Conn) {
 	// TODO: figure out what the equivalent of signal.SIGWINCH is on
 	// windows and use that; for now if you resize your terminal it just
 	// won't work quite correctly.
+	if !c.interactive {
+		return
+	}
+
 	err := c.sendTermSize(control)
 	if err != nil {
 		logger.Debugf("error setting term size %s", err)
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lxc/lxd"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/gnuflag"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+type exportCmd struct {
+	format         string
+	optimized      bool
+	encrypt        bool
+	passphraseFile string
+}
+
+func (c *exportCmd) showByDefault() bool {
+	return true
+}
+
+func (c *exportCmd) usage() string {
+	return i18n.G(
+		`Usage: lxc export [<remote>:]<container> [target] [--optimized-storage] [--encrypt [--passphrase-file=FILE]] [--format=raw|qcow2]
+
+Export a container, including its configuration and snapshots, as a backup tarball.
+
+If no target is given, the tarball is written to <container>.tar.gz in the current directory.
+A target of "-" writes it to standard output.
+
+With --optimized-storage, the tarball holds the storage backend's native
+send streams (zfs or btrfs). It's faster to create and restore but can only
+be imported on a storage pool using the same backend.
+
+With --encrypt, the tarball is encrypted (AES-256-GCM) with a passphrase read
+from --passphrase-file, the LXD_BACKUP_PASSPHRASE environment variable or
+prompted for.
+
+With --format=raw or --format=qcow2, the stopped container is instead exported
+as a bootable disk image (<container>.img or <container>.qcow2 by default),
+booting the newest kernel installed in its /boot.`)
+}
+
+func (c *exportCmd) flags() {
+	gnuflag.StringVar(&c.format, "format", "", i18n.G("Export as a disk image (raw or qcow2) rather than a backup tarball"))
+	gnuflag.BoolVar(&c.optimized, "optimized-storage", false, i18n.G("Use the storage backend's native format for the backup"))
+	gnuflag.BoolVar(&c.encrypt, "encrypt", false, i18n.G("Encrypt the backup with a passphrase"))
+	gnuflag.StringVar(&c.passphraseFile, "passphrase-file", "", i18n.G("File containing the backup passphrase"))
+}
+
+func (c *exportCmd) run(config *lxd.Config, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errArgs
+	}
+
+	remote, name := config.ParseRemoteAndContainer(args[0])
+	d, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	if c.format != "" {
+		return c.runDisk(d, name, args)
+	}
+
+	target := fmt.Sprintf("%s.tar.gz", name)
+	if len(args) == 2 {
+		target = args[1]
+	}
+
+	passphrase := ""
+	if c.encrypt {
+		passphrase, err = backupPassphrase(c.passphraseFile, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	if target == "-" {
+		return backupDownload(os.Stdout, passphrase, func(w io.Writer) error {
+			return d.ContainerExport(name, w, c.optimized)
+		})
+	}
+
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = backupDownload(f, passphrase, func(w io.Writer) error {
+		return d.ContainerExport(name, w, c.optimized)
+	})
+	if err != nil {
+		os.Remove(target)
+		return err
+	}
+
+	return nil
+}
+
+func (c *exportCmd) runDisk(d *lxd.Client, name string, args []string) error {
+	extensions := map[string]string{"raw": "img", "qcow2": "qcow2"}
+	extension, ok := extensions[c.format]
+	if !ok {
+		return fmt.Errorf(i18n.G("Invalid disk image format: %s"), c.format)
+	}
+
+	if c.optimized || c.encrypt {
+		return fmt.Errorf(i18n.G("--optimized-storage and --encrypt can't be used with --format"))
+	}
+
+	serverStatus, err := d.ServerStatus()
+	if err != nil {
+		return err
+	}
+
+	if !shared.StringInSlice("container_export_disk", serverStatus.APIExtensions) {
+		return fmt.Errorf(i18n.G("The server doesn't support exporting disk images"))
+	}
+
+	target := fmt.Sprintf("%s.%s", name, extension)
+	if len(args) == 2 {
+		target = args[1]
+	}
+
+	if target == "-" {
+		return d.ContainerExportDisk(name, c.format, os.Stdout)
+	}
+
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = d.ContainerExportDisk(name, c.format, f)
+	if err != nil {
+		os.Remove(target)
+		return err
+	}
+
+	return nil
+}
@@ -2,9 +2,10 @@ package main
 
 import (
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path"
 	"strconv"
 	"strings"
@@ -26,6 +27,8 @@ type fileCmd struct {
 	recursive bool
 
 	mkdirs bool
+
+	readOnly bool
 }
 
 func (c *fileCmd) showByDefault() bool {
@@ -38,8 +41,8 @@ func (c *fileCmd) usage() string {
 
 Manage files in containers.
 
-lxc file pull [-r|--recursive] [<remote>:]<container>/<path> [[<remote>:]<container>/<path>...] <target path>
-    Pull files from containers.
+lxc file pull [-r|--recursive] [<remote>:]<container>[/<snapshot>]/<path> [[<remote>:]<container>[/<snapshot>]/<path>...] <target path>
+    Pull files from containers or their snapshots.
 
 lxc file push [-r|--recursive] [-p|--create-dirs] [--uid=UID] [--gid=GID] [--mode=MODE] <source path> [<source path>...] [<remote>:]<container>/<path>
     Push files into containers.
@@ -50,12 +53,22 @@ lxc file delete [<remote>:]<container>/<path> [[<remote>:]<container>/<path>...]
 lxc file edit [<remote>:]<container>/<path>
     Edit files in containers using the default text editor.
 
+lxc file mount [--read-only] [<remote>:]<container>/<path> <target path>
+    Mount a directory of the container on the local machine (requires sshfs
+    locally and sftp-server in the container), until interrupted.
+
 *Examples*
 lxc file push /etc/hosts foo/etc/hosts
    To push /etc/hosts into the container "foo".
 
 lxc file pull foo/etc/hosts .
-   To pull /etc/hosts from the container and write it to the current directory.`)
+   To pull /etc/hosts from the container and write it to the current directory.
+
+lxc file pull foo/snap0/etc/hosts .
+   To pull /etc/hosts from the "snap0" snapshot of the container "foo".
+
+lxc file mount foo/srv/app ~/app
+   To work on the container's /srv/app from ~/app with local tools.`)
 }
 
 func (c *fileCmd) flags() {
@@ -66,6 +79,7 @@ func (c *fileCmd) flags() {
 	gnuflag.BoolVar(&c.recursive, "r", false, i18n.G("Recursively push or pull files"))
 	gnuflag.BoolVar(&c.mkdirs, "create-dirs", false, i18n.G("Create any directories necessary"))
 	gnuflag.BoolVar(&c.mkdirs, "p", false, i18n.G("Create any directories necessary"))
+	gnuflag.BoolVar(&c.readOnly, "read-only", false, i18n.G("Mount the directory read-only"))
 }
 
 func (c *fileCmd) push(config *lxd.Config, send_file_perms bool, args []string) error {
@@ -253,6 +267,24 @@ func (c *fileCmd) push(config *lxd.Config, send_file_perms bool, args []string)
 	return nil
 }
 
+// snapshotSource turns <snapshot>/<path> into the snapshot's name and the path
+// within it when the first component of the path is one of the container's
+// snapshots, a snapshot taking precedence over a directory of the same name.
+func (c *fileCmd) snapshotSource(d *lxd.Client, container string, p string) (string, string) {
+	fields := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2)
+	if len(fields) != 2 || fields[0] == "" {
+		return container, p
+	}
+
+	snapName := container + shared.SnapshotDelimiter + fields[0]
+	_, err := d.SnapshotInfo(snapName)
+	if err != nil {
+		return container, p
+	}
+
+	return snapName, fields[1]
+}
+
 func (c *fileCmd) pull(config *lxd.Config, args []string) error {
 	if len(args) < 2 {
 		return errArgs
@@ -297,6 +329,8 @@ func (c *fileCmd) pull(config *lxd.Config, args []string) error {
 			return err
 		}
 
+		container, pathSpec[1] = c.snapshotSource(d, container, pathSpec[1])
+
 		if c.recursive {
 			if err := d.RecursivePullFile(container, pathSpec[1], target); err != nil {
 				return err
@@ -337,7 +371,7 @@ func (c *fileCmd) pull(config *lxd.Config, args []string) error {
 			}
 		}
 
-		_, err = io.Copy(f, buf)
+		_, err = shared.CopyFileData(f, buf)
 		if err != nil {
 			return err
 		}
@@ -412,6 +446,99 @@ func (c *fileCmd) edit(config *lxd.Config, args []string) error {
 	return nil
 }
 
+// sftpServerScript runs the first sftp-server found in the container with
+// the given arguments, its protocol going over stdin and stdout.
+const sftpServerScript = `for server in /usr/lib/openssh/sftp-server /usr/libexec/openssh/sftp-server /usr/lib/ssh/sftp-server /usr/libexec/sftp-server; do
+    [ -x "${server}" ] && exec "${server}" "$@"
+done
+echo "No sftp-server found in the container" >&2
+exit 1`
+
+func (c *fileCmd) mount(config *lxd.Config, args []string) error {
+	if len(args) != 2 {
+		return errArgs
+	}
+
+	pathSpec := strings.SplitN(args[0], "/", 2)
+	if len(pathSpec) != 2 {
+		return fmt.Errorf(i18n.G("Invalid path %s"), args[0])
+	}
+
+	target := args[1]
+	if !shared.IsDir(target) {
+		return fmt.Errorf(i18n.G("Target path %s isn't a directory"), target)
+	}
+
+	_, err := exec.LookPath("sshfs")
+	if err != nil {
+		return fmt.Errorf(i18n.G("sshfs is required to mount container paths"))
+	}
+
+	remote, container := config.ParseRemoteAndContainer(pathSpec[0])
+	d, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	// Have sshfs talk SFTP over its stdin and stdout, which get connected
+	// to an sftp-server run in the container through exec
+	sshfsArgs := []string{"-f", "-o", "slave", fmt.Sprintf("%s:/%s", container, pathSpec[1]), target}
+	serverArgs := []string{"sh", "-c", sftpServerScript, "sftp-server"}
+	if c.readOnly {
+		sshfsArgs = append([]string{"-o", "ro"}, sshfsArgs...)
+		serverArgs = append(serverArgs, "-R")
+	}
+
+	sshfs := exec.Command("sshfs", sshfsArgs...)
+	sshfs.Stderr = os.Stderr
+
+	toServer, err := sshfs.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	fromServer, err := sshfs.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	err = sshfs.Start()
+	if err != nil {
+		return err
+	}
+
+	// Unmounting makes sshfs exit, which in turn stops the server
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		_, err := shared.RunCommand("fusermount", "-u", target)
+		if err != nil {
+			sshfs.Process.Kill()
+		}
+	}()
+
+	fmt.Printf(i18n.G("Mounted %s on %s, press Ctrl+C to unmount")+"\n", args[0], target)
+
+	ret, err := d.Exec(container, serverArgs, nil, toServer, fromServer, os.Stderr, nil, 0, 0)
+	if err != nil {
+		sshfs.Process.Kill()
+		sshfs.Wait()
+		return err
+	}
+
+	err = sshfs.Wait()
+	if err != nil {
+		return err
+	}
+
+	if ret != 0 {
+		return fmt.Errorf(i18n.G("sftp-server exited with status %d"), ret)
+	}
+
+	return nil
+}
+
 func (c *fileCmd) run(config *lxd.Config, args []string) error {
 	if len(args) < 1 {
 		return errUsage
@@ -426,6 +553,8 @@ func (c *fileCmd) run(config *lxd.Config, args []string) error {
 		return c.delete(config, args[1:])
 	case "edit":
 		return c.edit(config, args[1:])
+	case "mount":
+		return c.mount(config, args[1:])
 	default:
 		return errArgs
 	}
@@ -89,6 +89,10 @@ hash or alias name (if one is set).
 lxc image import <tarball> [<rootfs tarball>|<URL>] [<remote>:] [--public] [--created-at=ISO-8601] [--expires-at=ISO-8601] [--fingerprint=FINGERPRINT] [--alias=ALIAS...] [prop=value]
     Import an image tarball (or tarballs) into the LXD image store.
 
+    A "docker save" tarball or a docker://<image> reference to an image in a
+    registry gets converted by the server into an image running the
+    application as the container's init.
+
 lxc image copy [<remote>:]<image> <remote>: [--alias=ALIAS...] [--copy-aliases] [--public] [--auto-update]
     Copy an image from one LXD daemon to another over the network.
 
@@ -99,7 +103,9 @@ lxc image delete [<remote>:]<image> [[<remote>:]<image>...]
     Delete one or more images from the LXD image store.
 
 lxc image refresh [<remote>:]<image> [[<remote>:]<image>...]
-    Refresh one or more images from its parent remote.
+    Refresh one or more images from its parent remote now, rather than
+    waiting for the next automatic update.
+    When given an alias, the fingerprint of the newly fetched image is shown.
 
 lxc image export [<remote>:]<image> [target]
     Export an image from the LXD image store into a distributable tarball.
@@ -113,6 +119,11 @@ lxc image export [<remote>:]<image> [target]
     the appropriate extension will be appended to the provided file name
     based on the algorithm used to compress the image.
 
+lxc image refresh-index [<remote>:]
+    Fetch the image index of a simplestreams remote again, rather than using
+    the copy cached for up to an hour. Listing the images of a remote which
+    can't be reached falls back to the cached index, whatever its age.
+
 lxc image info [<remote>:]<image>
     Print everything LXD knows about a given image.
 
@@ -145,6 +156,13 @@ lxc image list [<remote>:] [filter] [--format csv|json|table|yaml] [-c <columns>
 
         u - Upload date
 
+        P - Newline-separated list of all image properties
+
+    A single image property can be shown in its own column using
+    "property.<key>[:<name>]", e.g. -c "l,property.os,property.release:REL".
+
+    Filters apply to all output formats, including json and yaml.
+
 lxc image show [<remote>:]<image>
     Yaml output of the user modifiable properties of an image.
 
@@ -218,6 +236,15 @@ func (c *imageCmd) uploadDateColumnData(image api.Image) string {
 	return image.UploadedAt.UTC().Format("Jan 2, 2006 at 3:04pm (MST)")
 }
 
+func (c *imageCmd) propertiesColumnData(image api.Image) string {
+	properties := []string{}
+	for k, v := range image.Properties {
+		properties = append(properties, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(properties)
+	return strings.Join(properties, "\n")
+}
+
 func (c *imageCmd) parseColumns() ([]imageColumn, error) {
 	columnsShorthandMap := map[rune]imageColumn{
 		'l': {i18n.G("ALIAS"), c.aliasColumnData},
@@ -228,6 +255,7 @@ func (c *imageCmd) parseColumns() ([]imageColumn, error) {
 		'a': {i18n.G("ARCH"), c.architectureColumnData},
 		's': {i18n.G("SIZE"), c.sizeColumnData},
 		'u': {i18n.G("UPLOAD DATE"), c.uploadDateColumnData},
+		'P': {i18n.G("PROPERTIES"), c.propertiesColumnData},
 	}
 
 	columnList := strings.Split(c.columnsRaw, ",")
@@ -238,6 +266,29 @@ func (c *imageCmd) parseColumns() ([]imageColumn, error) {
 			return nil, fmt.Errorf("Empty column entry (redundant, leading or trailing command) in '%s'", c.columnsRaw)
 		}
 
+		// Single properties are selected with "property.<key>[:<name>]"
+		if strings.HasPrefix(columnEntry, "property.") {
+			cc := strings.SplitN(strings.TrimPrefix(columnEntry, "property."), ":", 2)
+			if cc[0] == "" {
+				return nil, fmt.Errorf("Missing property name in '%s'", columnEntry)
+			}
+
+			key := cc[0]
+			name := strings.ToUpper(key)
+			if len(cc) > 1 {
+				if cc[1] == "" {
+					return nil, fmt.Errorf("Invalid name in '%s'", columnEntry)
+				}
+
+				name = cc[1]
+			}
+
+			columns = append(columns, imageColumn{name, func(image api.Image) string {
+				return image.Properties[key]
+			}})
+			continue
+		}
+
 		for _, columnRune := range columnEntry {
 			if column, ok := columnsShorthandMap[columnRune]; ok {
 				columns = append(columns, column)
@@ -417,7 +468,13 @@ func (c *imageCmd) run(config *lxd.Config, args []string) error {
 			}
 
 			if refreshed {
-				progress.Done(i18n.G("Image refreshed successfully!"))
+				// Aliases follow the refreshed image
+				newImage := c.dereferenceAlias(d, inName)
+				if newImage != image {
+					progress.Done(fmt.Sprintf(i18n.G("Image refreshed successfully! New fingerprint: %s"), newImage))
+				} else {
+					progress.Done(i18n.G("Image refreshed successfully!"))
+				}
 			} else {
 				progress.Done(i18n.G("Image already up to date."))
 			}
@@ -547,6 +604,11 @@ func (c *imageCmd) run(config *lxd.Config, args []string) error {
 			}
 		} else if strings.HasPrefix(imageFile, "http://") {
 			return fmt.Errorf(i18n.G("Only https:// is supported for remote image import."))
+		} else if strings.HasPrefix(imageFile, "docker://") {
+			fingerprint, err = d.PostImageOCI(imageFile, properties, c.publicImage, c.addAliases)
+			if err == nil {
+				fmt.Printf(i18n.G("Image imported with fingerprint: %s")+"\n", fingerprint)
+			}
 		} else {
 			progress := ProgressRenderer{Format: i18n.G("Transferring image: %s")}
 			handler := func(percent int64, speed int64) {
@@ -601,6 +663,11 @@ func (c *imageCmd) run(config *lxd.Config, args []string) error {
 			return err
 		}
 
+		cachedSince := d.ImageIndexCachedSince()
+		if !cachedSince.IsZero() {
+			fmt.Fprintf(os.Stderr, i18n.G("Warning: %s couldn't be reached, showing the image list cached at %s")+"\n", remote, cachedSince.UTC().Format("2006/01/02 15:04 UTC"))
+		}
+
 		for _, image := range allImages {
 			if !c.imageShouldShow(filters, &image) {
 				continue
@@ -611,6 +678,23 @@ func (c *imageCmd) run(config *lxd.Config, args []string) error {
 
 		return c.showImages(images, filters, columns)
 
+	case "refresh-index":
+		if len(args) > 2 {
+			return errArgs
+		}
+
+		remote, _ = config.ParseRemoteAndContainer("")
+		if len(args) == 2 {
+			remote = config.ParseRemote(args[1])
+		}
+
+		d, err := lxd.NewClient(config, remote)
+		if err != nil {
+			return err
+		}
+
+		return d.RefreshImageIndex()
+
 	case "edit":
 		if len(args) < 2 {
 			return errArgs
@@ -769,20 +853,14 @@ func (c *imageCmd) showImages(images []api.Image, filters []string, columns []im
 		table.AppendBulk(tableData())
 		table.Render()
 	case listFormatJSON:
-		data := make([]*api.Image, len(images))
-		for i := range images {
-			data[i] = &images[i]
-		}
+		data := c.filterImages(images, filters)
 		enc := json.NewEncoder(os.Stdout)
 		err := enc.Encode(data)
 		if err != nil {
 			return err
 		}
 	case listFormatYAML:
-		data := make([]*api.Image, len(images))
-		for i := range images {
-			data[i] = &images[i]
-		}
+		data := c.filterImages(images, filters)
 
 		out, err := yaml.Marshal(data)
 		if err != nil {
@@ -796,6 +874,19 @@ func (c *imageCmd) showImages(images []api.Image, filters []string, columns []im
 	return nil
 }
 
+func (c *imageCmd) filterImages(images []api.Image, filters []string) []*api.Image {
+	data := []*api.Image{}
+	for i := range images {
+		if !c.imageShouldShow(filters, &images[i]) {
+			continue
+		}
+
+		data = append(data, &images[i])
+	}
+
+	return data
+}
+
 func (c *imageCmd) showAliases(aliases []api.ImageAliasesEntry, filters []string) error {
 	data := [][]string{}
 	for _, alias := range aliases {
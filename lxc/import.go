@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lxc/lxd"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/gnuflag"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+type importCmd struct {
+	name           string
+	storagePool    string
+	passphraseFile string
+	verifyOnly     bool
+}
+
+func (c *importCmd) showByDefault() bool {
+	return true
+}
+
+func (c *importCmd) usage() string {
+	return i18n.G(
+		`Usage: lxc import [<remote>:] <backup file> [--name=NAME] [--storage|-s <pool>] [--passphrase-file=FILE] [--verify-only]
+
+Import a container backup tarball created by "lxc export", "-" reads it from
+standard input.
+
+With --verify-only, the tarball is checked locally against the checksums of
+its manifest and nothing gets imported.
+
+The container keeps its original name unless --name is passed and is created
+on the storage pool it was backed up from unless --storage is passed.
+
+Encrypted backups are decrypted locally using the passphrase read from
+--passphrase-file, the LXD_BACKUP_PASSPHRASE environment variable or
+prompted for.`)
+}
+
+func (c *importCmd) flags() {
+	gnuflag.StringVar(&c.name, "name", "", i18n.G("Name of the imported container"))
+	gnuflag.StringVar(&c.storagePool, "storage", "", i18n.G("Storage pool name"))
+	gnuflag.StringVar(&c.storagePool, "s", "", i18n.G("Storage pool name"))
+	gnuflag.StringVar(&c.passphraseFile, "passphrase-file", "", i18n.G("File containing the backup passphrase"))
+	gnuflag.BoolVar(&c.verifyOnly, "verify-only", false, i18n.G("Only check the backup's integrity"))
+}
+
+func (c *importCmd) run(config *lxd.Config, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errArgs
+	}
+
+	remote := config.DefaultRemote
+	file := args[0]
+	if len(args) == 2 {
+		remote, _ = config.ParseRemoteAndContainer(args[0])
+		file = args[1]
+	}
+
+	var err error
+	f := os.Stdin
+	if file != "-" {
+		f, err = os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+	}
+
+	reader := bufio.NewReader(f)
+	var source io.Reader = reader
+
+	header, _ := reader.Peek(len(shared.EncryptedStreamMagic))
+	if shared.IsEncryptedStream(header) {
+		passphrase, err := backupPassphrase(c.passphraseFile, false)
+		if err != nil {
+			return err
+		}
+
+		source, err = shared.NewDecryptReader(source, passphrase)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.verifyOnly {
+		name, count, err := shared.VerifyBackupTarball(source, true)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf(i18n.G("Backup of %s verified (%d files)")+"\n", name, count)
+		return nil
+	}
+
+	d, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.ContainerImport(source, c.name, c.storagePool)
+	if err != nil {
+		return err
+	}
+
+	return d.WaitForSuccess(resp.Operation)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 
@@ -30,8 +31,10 @@ Show container or server information.
 lxc info [<remote>:]<container> [--show-log]
     For container information.
 
-lxc info [<remote>:]
-    For LXD server information.`)
+lxc info [<remote>:] [--debug]
+    For LXD server information (version, API extensions, certificate
+    fingerprint, storage drivers and host features), including
+    self-diagnostics with --debug.`)
 }
 
 func (c *infoCmd) flags() {
@@ -72,6 +75,24 @@ func (c *infoCmd) remoteInfo(d *lxd.Client) error {
 
 	fmt.Printf("%s", data)
 
+	// The global --debug flag also requests the daemon's self-diagnostics
+	debugFlag := gnuflag.Lookup("debug")
+	if debugFlag == nil || debugFlag.Value.String() != "true" {
+		return nil
+	}
+
+	debugInfo, err := d.ServerDebug()
+	if err != nil {
+		return err
+	}
+
+	data, err = yaml.Marshal(map[string]interface{}{"debug": debugInfo})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", data)
+
 	return nil
 }
 
@@ -92,10 +113,16 @@ func (c *infoCmd) containerInfo(d *lxd.Client, name string, showLog bool) error
 	if d.Remote != nil && d.Remote.Addr != "" {
 		fmt.Printf(i18n.G("Remote: %s")+"\n", d.Remote.Addr)
 	}
+	if ct.Description != "" {
+		fmt.Printf(i18n.G("Description: %s")+"\n", ct.Description)
+	}
 	fmt.Printf(i18n.G("Architecture: %s")+"\n", ct.Architecture)
 	if shared.TimeIsSet(ct.CreatedAt) {
 		fmt.Printf(i18n.G("Created: %s")+"\n", ct.CreatedAt.UTC().Format(layout))
 	}
+	if shared.TimeIsSet(ct.LastUsedAt) {
+		fmt.Printf(i18n.G("Last Used: %s")+"\n", ct.LastUsedAt.UTC().Format(layout))
+	}
 
 	fmt.Printf(i18n.G("Status: %s")+"\n", ct.Status)
 	if ct.Ephemeral {
@@ -104,6 +131,9 @@ func (c *infoCmd) containerInfo(d *lxd.Client, name string, showLog bool) error
 		fmt.Printf(i18n.G("Type: persistent") + "\n")
 	}
 	fmt.Printf(i18n.G("Profiles: %s")+"\n", strings.Join(ct.Profiles, ", "))
+	if ct.Config["volatile.restart_required"] != "" {
+		fmt.Printf(i18n.G("Pending restart: %s")+"\n", strings.Replace(ct.Config["volatile.restart_required"], ",", ", ", -1))
+	}
 	if cs.Pid != 0 {
 		fmt.Printf(i18n.G("Pid: %d")+"\n", cs.Pid)
 
@@ -131,6 +161,16 @@ func (c *infoCmd) containerInfo(d *lxd.Client, name string, showLog bool) error
 		// Processes
 		fmt.Printf("  "+i18n.G("Processes: %d")+"\n", cs.Processes)
 
+		// cloud-init
+		if cs.CloudInit != "" {
+			fmt.Printf("  "+i18n.G("Cloud-init: %s")+"\n", cs.CloudInit)
+		}
+
+		// Health probe
+		if cs.Health != "" {
+			fmt.Printf("  "+i18n.G("Health: %s")+"\n", cs.Health)
+		}
+
 		// Disk usage
 		diskInfo := ""
 		if cs.Disk != nil {
@@ -152,6 +192,10 @@ func (c *infoCmd) containerInfo(d *lxd.Client, name string, showLog bool) error
 			cpuInfo += fmt.Sprintf("    %s: %v\n", i18n.G("CPU usage (in seconds)"), cs.CPU.Usage/1000000000)
 		}
 
+		if cs.CPU.Usage != 0 && cs.CPU.UsagePercent >= 0 {
+			cpuInfo += fmt.Sprintf("    %s: %.1f%%\n", i18n.G("CPU usage (percentage)"), cs.CPU.UsagePercent)
+		}
+
 		if cpuInfo != "" {
 			fmt.Println(fmt.Sprintf("  %s", i18n.G("CPU usage:")))
 			fmt.Printf(cpuInfo)
@@ -175,6 +219,10 @@ func (c *infoCmd) containerInfo(d *lxd.Client, name string, showLog bool) error
 			memoryInfo += fmt.Sprintf("    %s: %s\n", i18n.G("Swap (peak)"), shared.GetByteSizeString(cs.Memory.SwapUsagePeak, 2))
 		}
 
+		if cs.Memory.OOMKills != 0 {
+			memoryInfo += fmt.Sprintf("    %s: %d\n", i18n.G("OOM kills"), cs.Memory.OOMKills)
+		}
+
 		if memoryInfo != "" {
 			fmt.Println(fmt.Sprintf("  %s", i18n.G("Memory usage:")))
 			fmt.Printf(memoryInfo)
@@ -189,6 +237,15 @@ func (c *infoCmd) containerInfo(d *lxd.Client, name string, showLog bool) error
 				networkInfo += fmt.Sprintf("      %s: %s\n", i18n.G("Bytes sent"), shared.GetByteSizeString(net.Counters.BytesSent, 2))
 				networkInfo += fmt.Sprintf("      %s: %d\n", i18n.G("Packets received"), net.Counters.PacketsReceived)
 				networkInfo += fmt.Sprintf("      %s: %d\n", i18n.G("Packets sent"), net.Counters.PacketsSent)
+				if net.Counters.ErrorsReceived != 0 || net.Counters.ErrorsSent != 0 {
+					networkInfo += fmt.Sprintf("      %s: %d\n", i18n.G("Errors received"), net.Counters.ErrorsReceived)
+					networkInfo += fmt.Sprintf("      %s: %d\n", i18n.G("Errors sent"), net.Counters.ErrorsSent)
+				}
+
+				if net.Counters.PacketsDroppedInbound != 0 || net.Counters.PacketsDroppedOutbound != 0 {
+					networkInfo += fmt.Sprintf("      %s: %d\n", i18n.G("Packets dropped (inbound)"), net.Counters.PacketsDroppedInbound)
+					networkInfo += fmt.Sprintf("      %s: %d\n", i18n.G("Packets dropped (outbound)"), net.Counters.PacketsDroppedOutbound)
+				}
 			}
 		}
 
@@ -196,6 +253,33 @@ func (c *infoCmd) containerInfo(d *lxd.Client, name string, showLog bool) error
 			fmt.Println(fmt.Sprintf("  %s", i18n.G("Network usage:")))
 			fmt.Printf(networkInfo)
 		}
+
+		// Usage trend, only available when the server samples usage
+		samples, err := d.ContainerUsageHistory(name)
+		if err == nil && len(samples) > 1 {
+			first := samples[0]
+			last := samples[len(samples)-1]
+			elapsed := last.Timestamp.Sub(first.Timestamp)
+
+			memMin := first.Memory
+			memMax := first.Memory
+			for _, sample := range samples {
+				if sample.Memory < memMin {
+					memMin = sample.Memory
+				}
+
+				if sample.Memory > memMax {
+					memMax = sample.Memory
+				}
+			}
+
+			fmt.Println(fmt.Sprintf("  %s", fmt.Sprintf(i18n.G("Usage trend (last %s):"), elapsed-elapsed%time.Second)))
+			if elapsed > 0 {
+				fmt.Printf("    %s: %.2f\n", i18n.G("CPU (average cores)"), float64(last.CPU-first.CPU)/float64(elapsed.Nanoseconds()))
+			}
+			fmt.Printf("    %s: %s - %s\n", i18n.G("Memory (min - max)"), shared.GetByteSizeString(memMin, 2), shared.GetByteSizeString(memMax, 2))
+			fmt.Printf("    %s: %s -> %s\n", i18n.G("Disk"), shared.GetByteSizeString(first.Disk, 2), shared.GetByteSizeString(last.Disk, 2))
+		}
 	}
 
 	// List snapshots
@@ -222,6 +306,14 @@ func (c *infoCmd) containerInfo(d *lxd.Client, name string, showLog bool) error
 		} else {
 			fmt.Printf(" (" + i18n.G("stateless") + ")")
 		}
+
+		if snap.Size >= 0 {
+			fmt.Printf(" ("+i18n.G("size: %s")+")", shared.GetByteSizeString(snap.Size, 2))
+		}
+
+		if snap.Description != "" {
+			fmt.Printf(" - %s", snap.Description)
+		}
 		fmt.Printf("\n")
 
 		first_snapshot = false
@@ -251,37 +251,27 @@ func (c *initCmd) run(config *lxd.Config, args []string) error {
 
 func (c *initCmd) initProgressTracker(d *lxd.Client, progress *ProgressRenderer, operation string) {
 	progress.Format = i18n.G("Retrieving image: %s")
-	handler := func(msg interface{}) {
-		if msg == nil {
+	handler := func(event api.Event) {
+		if event.Type != "operation" {
 			return
 		}
 
-		event := msg.(map[string]interface{})
-		if event["type"].(string) != "operation" {
-			return
-		}
-
-		if event["metadata"] == nil {
-			return
-		}
-
-		md := event["metadata"].(map[string]interface{})
-		if !strings.HasSuffix(operation, md["id"].(string)) {
+		md, err := event.ToOperation()
+		if err != nil {
 			return
 		}
 
-		if md["metadata"] == nil {
+		if !strings.HasSuffix(operation, md.ID) {
 			return
 		}
 
-		if api.StatusCode(md["status_code"].(float64)).IsFinal() {
+		if md.StatusCode.IsFinal() {
 			return
 		}
 
-		opMd := md["metadata"].(map[string]interface{})
-		_, ok := opMd["download_progress"]
+		downloadProgress, ok := md.Metadata["download_progress"].(string)
 		if ok {
-			progress.Update(opMd["download_progress"].(string))
+			progress.Update(downloadProgress)
 		}
 	}
 	go d.Monitor([]string{"operation"}, handler, nil)
@@ -42,7 +42,7 @@ func (c *listCmd) showByDefault() bool {
 
 func (c *listCmd) usage() string {
 	return i18n.G(
-		`Usage: lxc list [<remote>:] [filters] [--format csv|json|table|yaml] [-c <columns>] [--fast]
+		`Usage: lxc list [<remote>:] [filters] [--format csv|json|table|yaml|ansible-inventory] [-c <columns>] [--fast]
 
 List the existing containers.
 
@@ -65,6 +65,15 @@ A key/value pair referring to a configuration item. For those, the namespace can
 
 A regular expression matching a configuration item or its value. (e.g. volatile.eth0.hwaddr=00:16:3e:.*).
 
+When several filters are given, only containers matching all of them are listed,
+e.g. "lxc list web user.owner=alice" lists the web containers owned by alice.
+
+*Ansible inventory*
+The ansible-inventory format outputs the containers as an Ansible dynamic
+inventory, using the lxd connection plugin. Containers are grouped by
+profile (profile_<name>), state (status_<state>) and user.* key
+(user_<key>_<value>).
+
 *Columns*
 The -c option takes a comma separated list of arguments that control
 which container attributes to output when displaying in table or csv
@@ -89,6 +98,8 @@ Pre-defined column shorthand chars:
 
 	d - Description
 
+	h - Health, as reported by the container's health probe
+
 	l - Last used date
 
 	n - Name
@@ -103,6 +114,8 @@ Pre-defined column shorthand chars:
 
 	t - Type (persistent or ephemeral)
 
+	u - CPU usage (percentage of one CPU)
+
 Custom columns are defined with "key[:name][:maxWidth]":
 
 	KEY: The (extended) config key to display
@@ -127,7 +140,7 @@ lxc list -c ns,user.comment:comment
 func (c *listCmd) flags() {
 	gnuflag.StringVar(&c.columnsRaw, "c", "ns46tS", i18n.G("Columns"))
 	gnuflag.StringVar(&c.columnsRaw, "columns", "ns46tS", i18n.G("Columns"))
-	gnuflag.StringVar(&c.format, "format", "table", i18n.G("Format (csv|json|table|yaml)"))
+	gnuflag.StringVar(&c.format, "format", "table", i18n.G("Format (csv|json|table|yaml|ansible-inventory)"))
 	gnuflag.BoolVar(&c.fast, "fast", false, i18n.G("Fast mode (same as --columns=nsacPt)"))
 }
 
@@ -188,7 +201,7 @@ func (c *listCmd) shouldShow(filters []string, state *api.Container) bool {
 			}
 
 			if state.ExpandedConfig[key] == value {
-				return true
+				continue
 			}
 
 			if !found {
@@ -202,7 +215,7 @@ func (c *listCmd) shouldShow(filters []string, state *api.Container) bool {
 
 			r, err := regexp.Compile(regexpValue)
 			if err == nil && r.MatchString(state.Name) == true {
-				return true
+				continue
 			}
 
 			if !strings.HasPrefix(state.Name, filter) {
@@ -214,7 +227,7 @@ func (c *listCmd) shouldShow(filters []string, state *api.Container) bool {
 	return true
 }
 
-func (c *listCmd) listContainers(d *lxd.Client, cinfos []api.Container, filters []string, columns []column) error {
+func (c *listCmd) listContainers(d *lxd.Client, cinfos []api.Container, cStates map[string]*api.ContainerState, cSnapshots map[string][]api.ContainerSnapshot, filters []string, columns []column) error {
 	headers := []string{}
 	for _, column := range columns {
 		headers = append(headers, column.Name)
@@ -225,12 +238,10 @@ func (c *listCmd) listContainers(d *lxd.Client, cinfos []api.Container, filters
 		threads = len(cinfos)
 	}
 
-	cStates := map[string]*api.ContainerState{}
 	cStatesLock := sync.Mutex{}
 	cStatesQueue := make(chan string, threads)
 	cStatesWg := sync.WaitGroup{}
 
-	cSnapshots := map[string][]api.ContainerSnapshot{}
 	cSnapshotsLock := sync.Mutex{}
 	cSnapshotsQueue := make(chan string, threads)
 	cSnapshotsWg := sync.WaitGroup{}
@@ -386,6 +397,12 @@ func (c *listCmd) listContainers(d *lxd.Client, cinfos []api.Container, filters
 			return err
 		}
 		fmt.Printf("%s", out)
+	case listFormatAnsible:
+		out, err := json.MarshalIndent(listAnsibleInventory(d.Name, cinfos), "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", out)
 	default:
 		return fmt.Errorf("invalid format %q", c.format)
 	}
@@ -400,6 +417,74 @@ type listContainerItem struct {
 	Snapshots []api.ContainerSnapshot `json:"snapshots" yaml:"snapshots"`
 }
 
+var ansibleGroupRegexp = regexp.MustCompile("[^A-Za-z0-9_]")
+
+// ansibleGroupName turns the parts of a group name into a valid Ansible
+// group name.
+func ansibleGroupName(parts ...string) string {
+	return ansibleGroupRegexp.ReplaceAllString(strings.Join(parts, "_"), "_")
+}
+
+// listAnsibleInventory builds an Ansible dynamic inventory of the containers.
+func listAnsibleInventory(remote string, cinfos []api.Container) map[string]interface{} {
+	hosts := []string{}
+	hostvars := map[string]interface{}{}
+	groups := map[string][]string{}
+
+	addToGroup := func(group string, name string) {
+		if !shared.StringInSlice(name, groups[group]) {
+			groups[group] = append(groups[group], name)
+		}
+	}
+
+	for _, cinfo := range cinfos {
+		hosts = append(hosts, cinfo.Name)
+		hostvars[cinfo.Name] = map[string]interface{}{
+			"ansible_connection": "lxd",
+			"ansible_lxd_remote": remote,
+			"lxd_architecture":   cinfo.Architecture,
+			"lxd_ephemeral":      cinfo.Ephemeral,
+			"lxd_profiles":       cinfo.Profiles,
+			"lxd_status":         cinfo.Status,
+		}
+
+		for _, profile := range cinfo.Profiles {
+			addToGroup(ansibleGroupName("profile", profile), cinfo.Name)
+		}
+
+		addToGroup(ansibleGroupName("status", strings.ToLower(cinfo.Status)), cinfo.Name)
+
+		for k, v := range cinfo.ExpandedConfig {
+			// Skip multi-line values such as cloud-init data
+			if !strings.HasPrefix(k, "user.") || strings.Contains(v, "\n") {
+				continue
+			}
+
+			addToGroup(ansibleGroupName(k, v), cinfo.Name)
+		}
+	}
+
+	sort.Strings(hosts)
+	inventory := map[string]interface{}{
+		"_meta": map[string]interface{}{"hostvars": hostvars},
+	}
+
+	children := []string{}
+	for group, members := range groups {
+		sort.Strings(members)
+		inventory[group] = map[string]interface{}{"hosts": members}
+		children = append(children, group)
+	}
+	sort.Strings(children)
+
+	inventory["all"] = map[string]interface{}{
+		"hosts":    hosts,
+		"children": children,
+	}
+
+	return inventory
+}
+
 func (c *listCmd) run(config *lxd.Config, args []string) error {
 	var remote string
 	name := ""
@@ -427,12 +512,40 @@ func (c *listCmd) run(config *lxd.Config, args []string) error {
 		return err
 	}
 
-	var cts []api.Container
-	ctslist, err := d.ListContainers()
+	columns, err := c.parseColumns()
 	if err != nil {
 		return err
 	}
 
+	// The inventory only uses the container configuration
+	if c.format == listFormatAnsible {
+		columns = []column{}
+	}
+
+	// States and snapshots already known, the rest is fetched per container
+	cStates := map[string]*api.ContainerState{}
+	cSnapshots := map[string][]api.ContainerSnapshot{}
+
+	var ctslist []api.Container
+	if c.needsFull(d, columns) {
+		full, err := d.ListContainersFull()
+		if err != nil {
+			return err
+		}
+
+		for _, cinfo := range full {
+			ctslist = append(ctslist, cinfo.Container)
+			cStates[cinfo.Name] = cinfo.State
+			cSnapshots[cinfo.Name] = cinfo.Snapshots
+		}
+	} else {
+		ctslist, err = d.ListContainers()
+		if err != nil {
+			return err
+		}
+	}
+
+	var cts []api.Container
 	for _, cinfo := range ctslist {
 		if !c.shouldShow(filters, &cinfo) {
 			continue
@@ -441,12 +554,30 @@ func (c *listCmd) run(config *lxd.Config, args []string) error {
 		cts = append(cts, cinfo)
 	}
 
-	columns, err := c.parseColumns()
+	return c.listContainers(d, cts, cStates, cSnapshots, filters, columns)
+}
+
+// needsFull returns whether the state or snapshots of the containers are
+// needed and can be retrieved along with the listing itself
+func (c *listCmd) needsFull(d *lxd.Client, columns []column) bool {
+	needed := false
+	for _, column := range columns {
+		if column.NeedsState || column.NeedsSnapshots {
+			needed = true
+			break
+		}
+	}
+
+	if !needed {
+		return false
+	}
+
+	status, err := d.ServerStatus()
 	if err != nil {
-		return err
+		return false
 	}
 
-	return c.listContainers(d, cts, filters, columns)
+	return shared.StringInSlice("container_full", status.APIExtensions)
 }
 
 func (c *listCmd) parseColumns() ([]column, error) {
@@ -456,6 +587,7 @@ func (c *listCmd) parseColumns() ([]column, error) {
 		'a': {i18n.G("ARCHITECTURE"), c.ArchitectureColumnData, false, false},
 		'c': {i18n.G("CREATED AT"), c.CreatedColumnData, false, false},
 		'd': {i18n.G("DESCRIPTION"), c.descriptionColumnData, false, false},
+		'h': {i18n.G("HEALTH"), c.healthColumnData, true, false},
 		'l': {i18n.G("LAST USED AT"), c.LastUsedColumnData, false, false},
 		'n': {i18n.G("NAME"), c.nameColumnData, false, false},
 		'p': {i18n.G("PID"), c.PIDColumnData, true, false},
@@ -463,6 +595,7 @@ func (c *listCmd) parseColumns() ([]column, error) {
 		'S': {i18n.G("SNAPSHOTS"), c.numberSnapshotsColumnData, false, true},
 		's': {i18n.G("STATE"), c.statusColumnData, false, false},
 		't': {i18n.G("TYPE"), c.typeColumnData, false, false},
+		'u': {i18n.G("CPU USAGE"), c.cpuUsageColumnData, true, false},
 		'b': {i18n.G("STORAGE POOL"), c.StoragePoolColumnData, false, false},
 	}
 
@@ -620,6 +753,22 @@ func (c *listCmd) numberSnapshotsColumnData(cInfo api.Container, cState *api.Con
 	return ""
 }
 
+func (c *listCmd) cpuUsageColumnData(cInfo api.Container, cState *api.ContainerState, cSnaps []api.ContainerSnapshot) string {
+	if cInfo.IsActive() && cState != nil && cState.CPU.UsagePercent >= 0 {
+		return fmt.Sprintf("%.1f%%", cState.CPU.UsagePercent)
+	}
+
+	return ""
+}
+
+func (c *listCmd) healthColumnData(cInfo api.Container, cState *api.ContainerState, cSnaps []api.ContainerSnapshot) string {
+	if cInfo.IsActive() && cState != nil {
+		return cState.Health
+	}
+
+	return ""
+}
+
 func (c *listCmd) PIDColumnData(cInfo api.Container, cState *api.ContainerState, cSnaps []api.ContainerSnapshot) string {
 	if cInfo.IsActive() && cState != nil {
 		return fmt.Sprintf("%d", cState.Pid)
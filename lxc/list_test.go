@@ -204,3 +204,58 @@ func TestInvalidColumns(t *testing.T) {
 	run("base_image")
 	run("volatile.image")
 }
+
+func TestListAnsibleInventory(t *testing.T) {
+	cinfos := []api.Container{
+		{
+			ContainerPut:   api.ContainerPut{Profiles: []string{"default", "web-tier"}},
+			Name:           "web1",
+			Status:         "Running",
+			ExpandedConfig: map[string]string{"user.role": "web", "user.user-data": "#cloud-config\npackages: []", "limits.cpu": "2"},
+		},
+		{
+			ContainerPut:   api.ContainerPut{Profiles: []string{"default"}},
+			Name:           "db1",
+			Status:         "Stopped",
+			ExpandedConfig: map[string]string{"user.role": "db"},
+		},
+	}
+
+	inventory := listAnsibleInventory("local", cinfos)
+
+	expected := map[string][]string{
+		"profile_default":  {"db1", "web1"},
+		"profile_web_tier": {"web1"},
+		"status_running":   {"web1"},
+		"status_stopped":   {"db1"},
+		"user_role_web":    {"web1"},
+		"user_role_db":     {"db1"},
+	}
+
+	for group, hosts := range expected {
+		entry, ok := inventory[group].(map[string]interface{})
+		if !ok {
+			t.Fatalf("missing group %s", group)
+		}
+
+		if strings.Join(entry["hosts"].([]string), ",") != strings.Join(hosts, ",") {
+			t.Errorf("wrong hosts for group %s: %v", group, entry["hosts"])
+		}
+	}
+
+	// all, _meta and the groups above
+	if len(inventory) != len(expected)+2 {
+		t.Errorf("unexpected groups: %v", inventory)
+	}
+
+	all := inventory["all"].(map[string]interface{})
+	if strings.Join(all["hosts"].([]string), ",") != "db1,web1" {
+		t.Errorf("wrong hosts for all: %v", all["hosts"])
+	}
+
+	hostvars := inventory["_meta"].(map[string]interface{})["hostvars"].(map[string]interface{})
+	web1 := hostvars["web1"].(map[string]interface{})
+	if web1["ansible_connection"] != "lxd" || web1["ansible_lxd_remote"] != "local" {
+		t.Errorf("wrong hostvars for web1: %v", web1)
+	}
+}
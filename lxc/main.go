@@ -10,6 +10,7 @@ import (
 
 	"github.com/lxc/lxd"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/gnuflag"
 	"github.com/lxc/lxd/shared/i18n"
 	"github.com/lxc/lxd/shared/logger"
@@ -35,6 +36,20 @@ func main() {
 			msg = i18n.G("Permission denied, are you in the lxd group?")
 		}
 
+		// Translate the generic server errors
+		if respErr, ok := err.(*api.ResponseError); ok {
+			switch respErr.Name {
+			case api.ErrorNameNotFound:
+				msg = fmt.Sprintf(i18n.G("error: %v"), i18n.G("not found"))
+			case api.ErrorNameForbidden:
+				msg = fmt.Sprintf(i18n.G("error: %v"), i18n.G("not authorized"))
+			case api.ErrorNameAlreadyExists:
+				msg = fmt.Sprintf(i18n.G("error: %v"), i18n.G("already exists"))
+			case api.ErrorNameNotImplemented:
+				msg = fmt.Sprintf(i18n.G("error: %v"), i18n.G("not implemented"))
+			}
+		}
+
 		fmt.Fprintln(os.Stderr, fmt.Sprintf("%s", msg))
 		os.Exit(1)
 	}
@@ -45,6 +60,8 @@ func run() error {
 	debug := gnuflag.Bool("debug", false, i18n.G("Enable debug mode"))
 	forceLocal := gnuflag.Bool("force-local", false, i18n.G("Force using the local unix socket"))
 	noAlias := gnuflag.Bool("no-alias", false, i18n.G("Ignore aliases when determining what command to run"))
+	timeout := gnuflag.Int("connect-timeout", 0, i18n.G("Connection timeout in seconds, overriding those of the remotes"))
+	project := gnuflag.String("project", "", i18n.G("Project to use, overriding those of the remotes"))
 
 	configDir := "$HOME/.config/lxc"
 	if os.Getenv("LXD_CONF") != "" {
@@ -124,6 +141,12 @@ func run() error {
 		return err
 	}
 
+	if *timeout < 0 {
+		return fmt.Errorf(i18n.G("Invalid timeout: %d"), *timeout)
+	}
+	config.Timeout = *timeout
+	config.Project = *project
+
 	// If the user is running a command that may attempt to connect to the local daemon
 	// and this is the first time the client has been run by the user, then check to see
 	// if LXD has been properly configured.  Don't display the message if the var path
@@ -181,14 +204,19 @@ type command interface {
 }
 
 var commands = map[string]command{
+	"apply":   &applyCmd{},
+	"backup":  &backupCmd{},
 	"config":  &configCmd{},
+	"console": &consoleCmd{},
 	"copy":    &copyCmd{},
 	"delete":  &deleteCmd{},
 	"exec":    &execCmd{},
+	"export":  &exportCmd{},
 	"file":    &fileCmd{},
 	"finger":  &fingerCmd{},
 	"help":    &helpCmd{},
 	"image":   &imageCmd{},
+	"import":  &importCmd{},
 	"info":    &infoCmd{},
 	"init":    &initCmd{},
 	"launch":  &launchCmd{},
@@ -203,6 +231,7 @@ var commands = map[string]command{
 		name:        "pause",
 	},
 	"profile": &profileCmd{},
+	"project": &projectCmd{},
 	"publish": &publishCmd{},
 	"remote":  &remoteCmd{},
 	"restart": &actionCmd{
@@ -214,6 +243,7 @@ var commands = map[string]command{
 		timeout:     -1,
 	},
 	"restore":  &restoreCmd{},
+	"shell":    &shellCmd{},
 	"snapshot": &snapshotCmd{},
 	"start": &actionCmd{
 		action:      shared.Start,
@@ -231,13 +261,12 @@ var commands = map[string]command{
 	},
 	"storage": &storageCmd{},
 	"version": &versionCmd{},
+	"warning": &warningCmd{},
 }
 
 // defaultAliases contains LXC's built-in command line aliases.  The built-in
 // aliases are checked only if no user-defined alias was found.
 var defaultAliases = map[string]string{
-	"shell": "exec @ARGS@ -- login -f root",
-
 	"cp":     "copy",
 	"ls":     "list",
 	"mv":     "move",
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
 	"gopkg.in/yaml.v2"
 
 	"github.com/lxc/lxd"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/gnuflag"
 	"github.com/lxc/lxd/shared/i18n"
+	"github.com/lxc/lxd/shared/termios"
 )
 
 type typeList []string
@@ -31,6 +38,9 @@ func (f *typeList) Set(value string) error {
 
 type monitorCmd struct {
 	typeArgs typeList
+	format   string
+	pretty   bool
+	since    string
 }
 
 func (c *monitorCmd) showByDefault() bool {
@@ -39,7 +49,7 @@ func (c *monitorCmd) showByDefault() bool {
 
 func (c *monitorCmd) usage() string {
 	return i18n.G(
-		`Usage: lxc monitor [<remote>:] [--type=TYPE...]
+		`Usage: lxc monitor [<remote>:] [--type=TYPE...] [--since=DURATION] [--format json|yaml] [--pretty]
 
 Monitor a local or remote LXD server.
 
@@ -47,13 +57,29 @@ By default the monitor will listen to all message types.
 
 Message types to listen for can be specified with --type.
 
+Past events still known to the server can be replayed with --since,
+either as a duration (e.g. 10m) or as an RFC3339 timestamp.
+
+Events are printed as YAML documents by default, --format=json prints
+one JSON object per line and --pretty prints one human friendly line
+per event.
+
 *Examples*
 lxc monitor --type=logging
-    Only show log message.`)
+    Only show log message.
+
+lxc monitor --pretty --type=logging
+    Show log messages as colorized lines.
+
+lxc monitor --since=10m
+    Show the events of the last 10 minutes, then keep listening.`)
 }
 
 func (c *monitorCmd) flags() {
 	gnuflag.Var(&c.typeArgs, "type", i18n.G("Event type to listen for"))
+	gnuflag.StringVar(&c.format, "format", "yaml", i18n.G("Format (json|yaml)"))
+	gnuflag.BoolVar(&c.pretty, "pretty", false, i18n.G("Pretty rendering"))
+	gnuflag.StringVar(&c.since, "since", "", i18n.G("Replay past events since a duration or timestamp"))
 }
 
 func (c *monitorCmd) run(config *lxd.Config, args []string) error {
@@ -69,12 +95,48 @@ func (c *monitorCmd) run(config *lxd.Config, args []string) error {
 		remote, _ = config.ParseRemoteAndContainer(args[0])
 	}
 
+	if !c.pretty && c.format != listFormatJSON && c.format != listFormatYAML {
+		return fmt.Errorf(i18n.G("Invalid format: %s"), c.format)
+	}
+
 	d, err := lxd.NewClient(config, remote)
 	if err != nil {
 		return err
 	}
 
-	handler := func(message interface{}) {
+	color := termios.IsTerminal(int(syscall.Stdout))
+
+	handler := func(event api.Event) {
+		if c.pretty {
+			fmt.Println(monitorRenderPretty(event, color))
+			return
+		}
+
+		if c.format == listFormatJSON {
+			render, err := json.Marshal(&event)
+			if err != nil {
+				fmt.Printf("error: %s\n", err)
+				return
+			}
+
+			fmt.Printf("%s\n", render)
+			return
+		}
+
+		// Decode the metadata so it's rendered as YAML too
+		var metadata interface{}
+		err := json.Unmarshal(event.Metadata, &metadata)
+		if err != nil {
+			fmt.Printf("error: %s\n", err)
+			return
+		}
+
+		message := map[string]interface{}{
+			"type":      event.Type,
+			"timestamp": event.Timestamp.Format(time.RFC3339Nano),
+			"metadata":  metadata,
+		}
+
 		render, err := yaml.Marshal(&message)
 		if err != nil {
 			fmt.Printf("error: %s\n", err)
@@ -84,5 +146,77 @@ func (c *monitorCmd) run(config *lxd.Config, args []string) error {
 		fmt.Printf("%s\n\n", render)
 	}
 
-	return d.Monitor(c.typeArgs, handler, nil)
+	return d.MonitorSince(c.typeArgs, c.since, handler, nil)
+}
+
+// monitorRenderPretty renders an event as a single human friendly line,
+// optionally using ANSI colors based on the log level or operation status.
+func monitorRenderPretty(event api.Event, color bool) string {
+	timestamp := event.Timestamp.Local().Format("2006-01-02 15:04:05")
+
+	colorCode := 0
+	label := strings.ToUpper(event.Type)
+	var text string
+
+	switch event.Type {
+	case "logging":
+		logging, err := event.ToLogging()
+		if err != nil {
+			break
+		}
+
+		label = strings.ToUpper(logging.Level)
+
+		switch logging.Level {
+		case "crit":
+			colorCode = 35
+		case "eror", "error":
+			colorCode = 31
+		case "warn":
+			colorCode = 33
+		case "info":
+			colorCode = 32
+		case "dbug", "debug":
+			colorCode = 36
+		}
+
+		text = logging.Message
+
+		keys := []string{}
+		for k := range logging.Context {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			text += fmt.Sprintf(" %s=%s", k, logging.Context[k])
+		}
+	case "operation":
+		op, err := event.ToOperation()
+		if err != nil {
+			break
+		}
+
+		switch op.Status {
+		case "Failure":
+			colorCode = 31
+		case "Success":
+			colorCode = 32
+		default:
+			colorCode = 34
+		}
+
+		text = fmt.Sprintf("%s %s (%s)", op.ID, op.Class, op.Status)
+		if op.Err != "" {
+			text += fmt.Sprintf(": %s", op.Err)
+		}
+	default:
+		text = string(event.Metadata)
+	}
+
+	if color && colorCode != 0 {
+		label = fmt.Sprintf("\x1b[%dm%s\x1b[0m", colorCode, label)
+	}
+
+	return fmt.Sprintf("%s %s %s", timestamp, label, text)
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestMonitorRenderPretty(t *testing.T) {
+	event := api.Event{
+		Type:      "logging",
+		Timestamp: time.Date(2017, 6, 1, 10, 30, 0, 0, time.Local),
+		Metadata: json.RawMessage(`{
+			"level": "info",
+			"message": "Starting container",
+			"context": {"name": "c1", "action": "start"}
+		}`),
+	}
+
+	out := monitorRenderPretty(event, false)
+	expected := "2017-06-01 10:30:00 INFO Starting container action=start name=c1"
+	if out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+
+	out = monitorRenderPretty(event, true)
+	expected = "2017-06-01 10:30:00 \x1b[32mINFO\x1b[0m Starting container action=start name=c1"
+	if out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+}
+
+func TestMonitorRenderPrettyOperation(t *testing.T) {
+	event := api.Event{
+		Type:      "operation",
+		Timestamp: time.Date(2017, 6, 1, 10, 30, 0, 0, time.Local),
+		Metadata: json.RawMessage(`{
+			"id": "1234",
+			"class": "task",
+			"status": "Failure",
+			"err": "boom"
+		}`),
+	}
+
+	out := monitorRenderPretty(event, false)
+	expected := "2017-06-01 10:30:00 OPERATION 1234 task (Failure): boom"
+	if out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+}
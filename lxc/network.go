@@ -45,6 +45,45 @@ func (c *networkCmd) networkEditHelp() string {
 ### Note that only the configuration can be changed.`)
 }
 
+func (c *networkCmd) networkForwardEditHelp() string {
+	return i18n.G(
+		`### This is a yaml representation of the network forward.
+### Any line starting with a '# will be ignored.
+###
+### A network forward consists of a set of configuration items and ports.
+###
+### An example would look like:
+### listen_address: 192.0.2.10
+### config:
+###   target_address: 10.62.42.20
+### description: Web server
+### ports:
+### - description: HTTP and HTTPS
+###   protocol: tcp
+###   listen_port: 80,443
+###   target_address: 10.62.42.10
+###   target_port: ""
+###
+### Note that the listen address can't be changed.`)
+}
+
+func (c *networkCmd) networkZoneEditHelp() string {
+	return i18n.G(
+		`### This is a yaml representation of the network zone.
+### Any line starting with a '# will be ignored.
+###
+### A network zone consists of a set of configuration items.
+###
+### An example would look like:
+### name: lxd.example.net
+### config:
+###   dns.nameservers: ns1.example.net
+###   peers.ns1.address: 192.0.2.53
+### description: Containers of the lab
+###
+### Note that the name can't be changed.`)
+}
+
 func (c *networkCmd) usage() string {
 	return i18n.G(
 		`Usage: lxc network <subcommand> [options]
@@ -87,9 +126,67 @@ lxc network detach [<remote>:]<network> <container> [device name]
 lxc network detach-profile [<remote>:]<network> <container> [device name]
     Remove a network interface connecting the network to a specified profile.
 
+lxc network forward list [<remote>:]<network>
+    List the forwards of a network.
+
+lxc network forward show [<remote>:]<network> <listen address>
+    Show details of a network forward.
+
+lxc network forward create [<remote>:]<network> <listen address> [key=value...]
+    Create a network forward.
+
+lxc network forward set [<remote>:]<network> <listen address> <key> <value>
+    Set network forward configuration.
+
+lxc network forward unset [<remote>:]<network> <listen address> <key>
+    Unset network forward configuration.
+
+lxc network forward edit [<remote>:]<network> <listen address>
+    Edit a network forward, either by launching external editor or reading STDIN.
+
+lxc network forward delete [<remote>:]<network> <listen address>
+    Delete a network forward.
+
+lxc network forward port add [<remote>:]<network> <listen address> <protocol> <listen ports> <target address> [<target port>]
+    Forward ports (comma separated, ranges allowed) to a target address.
+
+lxc network forward port remove [<remote>:]<network> <listen address> <protocol> <listen ports>
+    Stop forwarding ports.
+
+lxc network zone list [<remote>:]
+    List the network zones.
+
+lxc network zone show [<remote>:]<zone>
+    Show details of a network zone.
+
+lxc network zone create [<remote>:]<zone> [key=value...]
+    Create a network zone.
+
+lxc network zone get [<remote>:]<zone> <key>
+    Get network zone configuration.
+
+lxc network zone set [<remote>:]<zone> <key> <value>
+    Set network zone configuration.
+
+lxc network zone unset [<remote>:]<zone> <key>
+    Unset network zone configuration.
+
+lxc network zone edit [<remote>:]<zone>
+    Edit a network zone, either by launching external editor or reading STDIN.
+
+lxc network zone delete [<remote>:]<zone>
+    Delete a network zone.
+
 *Examples*
 cat network.yaml | lxc network edit <network>
-    Update a network using the content of network.yaml`)
+    Update a network using the content of network.yaml
+
+lxc network forward port add lxdbr0 192.0.2.10 tcp 80,443 10.62.42.10
+    Forward HTTP and HTTPS traffic reaching 192.0.2.10 to 10.62.42.10
+
+lxc network zone create lxd.example.net peers.ns1.address=192.0.2.53
+lxc network set lxdbr0 dns.zone.forward lxd.example.net
+    Publish the containers of lxdbr0 as <container>.lxd.example.net`)
 }
 
 func (c *networkCmd) flags() {}
@@ -103,6 +200,14 @@ func (c *networkCmd) run(config *lxd.Config, args []string) error {
 		return c.doNetworkList(config, args)
 	}
 
+	if args[0] == "forward" {
+		return c.doNetworkForward(config, args[1:])
+	}
+
+	if args[0] == "zone" {
+		return c.doNetworkZone(config, args[1:])
+	}
+
 	if len(args) < 2 {
 		return errArgs
 	}
@@ -524,3 +629,533 @@ func (c *networkCmd) doNetworkShow(client *lxd.Client, name string) error {
 
 	return nil
 }
+
+func (c *networkCmd) doNetworkForward(config *lxd.Config, args []string) error {
+	if len(args) < 2 {
+		return errArgs
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+	if subcommand == "port" {
+		if len(args) < 2 {
+			return errArgs
+		}
+
+		subcommand = "port-" + args[0]
+		args = args[1:]
+	}
+
+	remote, network := config.ParseRemoteAndContainer(args[0])
+	client, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	if subcommand == "list" {
+		return c.doNetworkForwardList(client, network)
+	}
+
+	if len(args) < 2 {
+		return errArgs
+	}
+
+	address := args[1]
+	switch subcommand {
+	case "create":
+		return c.doNetworkForwardCreate(client, network, address, args[2:])
+	case "delete":
+		return c.doNetworkForwardDelete(client, network, address)
+	case "edit":
+		return c.doNetworkForwardEdit(client, network, address)
+	case "set":
+		return c.doNetworkForwardSet(client, network, address, args[2:])
+	case "unset":
+		if len(args) != 3 {
+			return errArgs
+		}
+		return c.doNetworkForwardSet(client, network, address, args[2:])
+	case "show":
+		return c.doNetworkForwardShow(client, network, address)
+	case "port-add":
+		return c.doNetworkForwardPortAdd(client, network, address, args[2:])
+	case "port-remove":
+		return c.doNetworkForwardPortRemove(client, network, address, args[2:])
+	default:
+		return errArgs
+	}
+}
+
+func (c *networkCmd) doNetworkForwardList(client *lxd.Client, network string) error {
+	forwards, err := client.ListNetworkForwards(network)
+	if err != nil {
+		return err
+	}
+
+	data := [][]string{}
+	for _, forward := range forwards {
+		strPorts := fmt.Sprintf("%d", len(forward.Ports))
+		data = append(data, []string{forward.ListenAddress, forward.Description, forward.Config["target_address"], strPorts})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowLine(true)
+	table.SetHeader([]string{
+		i18n.G("LISTEN ADDRESS"),
+		i18n.G("DESCRIPTION"),
+		i18n.G("DEFAULT TARGET ADDRESS"),
+		i18n.G("PORTS")})
+	sort.Sort(byName(data))
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
+
+func (c *networkCmd) doNetworkForwardCreate(client *lxd.Client, network string, address string, args []string) error {
+	forward := api.NetworkForwardsPost{
+		ListenAddress: address,
+	}
+	forward.Config = map[string]string{}
+	forward.Ports = []api.NetworkForwardPort{}
+
+	for _, entry := range args {
+		if !strings.Contains(entry, "=") {
+			return fmt.Errorf(i18n.G("Bad key=value pair: %s"), entry)
+		}
+
+		fields := strings.SplitN(entry, "=", 2)
+		forward.Config[fields[0]] = fields[1]
+	}
+
+	err := client.NetworkForwardCreate(network, forward)
+	if err == nil {
+		fmt.Printf(i18n.G("Network forward %s created")+"\n", address)
+	}
+
+	return err
+}
+
+func (c *networkCmd) doNetworkForwardDelete(client *lxd.Client, network string, address string) error {
+	err := client.NetworkForwardDelete(network, address)
+	if err == nil {
+		fmt.Printf(i18n.G("Network forward %s deleted")+"\n", address)
+	}
+
+	return err
+}
+
+func (c *networkCmd) doNetworkForwardEdit(client *lxd.Client, network string, address string) error {
+	// If stdin isn't a terminal, read text from it
+	if !termios.IsTerminal(int(syscall.Stdin)) {
+		contents, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		newdata := api.NetworkForwardPut{}
+		err = yaml.Unmarshal(contents, &newdata)
+		if err != nil {
+			return err
+		}
+		return client.NetworkForwardPut(network, address, newdata)
+	}
+
+	// Extract the current value
+	forward, err := client.NetworkForwardGet(network, address)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&forward)
+	if err != nil {
+		return err
+	}
+
+	// Spawn the editor
+	content, err := shared.TextEditor("", []byte(c.networkForwardEditHelp()+"\n\n"+string(data)))
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Parse the text received from the editor
+		newdata := api.NetworkForwardPut{}
+		err = yaml.Unmarshal(content, &newdata)
+		if err == nil {
+			err = client.NetworkForwardPut(network, address, newdata)
+		}
+
+		// Respawn the editor
+		if err != nil {
+			fmt.Fprintf(os.Stderr, i18n.G("Config parsing error: %s")+"\n", err)
+			fmt.Println(i18n.G("Press enter to open the editor again"))
+
+			_, err := os.Stdin.Read(make([]byte, 1))
+			if err != nil {
+				return err
+			}
+
+			content, err = shared.TextEditor("", content)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		break
+	}
+	return nil
+}
+
+func (c *networkCmd) doNetworkForwardSet(client *lxd.Client, network string, address string, args []string) error {
+	// we shifted @args so so it should read "<key> [<value>]"
+	if len(args) < 1 {
+		return errArgs
+	}
+
+	forward, err := client.NetworkForwardGet(network, address)
+	if err != nil {
+		return err
+	}
+
+	key := args[0]
+	var value string
+	if len(args) < 2 {
+		value = ""
+	} else {
+		value = args[1]
+	}
+
+	if forward.Config == nil {
+		forward.Config = map[string]string{}
+	}
+
+	if value == "" {
+		delete(forward.Config, key)
+	} else {
+		forward.Config[key] = value
+	}
+
+	return client.NetworkForwardPut(network, address, forward.Writable())
+}
+
+func (c *networkCmd) doNetworkForwardShow(client *lxd.Client, network string, address string) error {
+	forward, err := client.NetworkForwardGet(network, address)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&forward)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", data)
+
+	return nil
+}
+
+func (c *networkCmd) doNetworkForwardPortAdd(client *lxd.Client, network string, address string, args []string) error {
+	// we shifted @args so so it should read "<protocol> <listen ports> <target address> [<target port>]"
+	if len(args) < 3 || len(args) > 4 {
+		return errArgs
+	}
+
+	forward, err := client.NetworkForwardGet(network, address)
+	if err != nil {
+		return err
+	}
+
+	port := api.NetworkForwardPort{
+		Protocol:      args[0],
+		ListenPort:    args[1],
+		TargetAddress: args[2],
+	}
+
+	if len(args) == 4 {
+		port.TargetPort = args[3]
+	}
+
+	forward.Ports = append(forward.Ports, port)
+
+	return client.NetworkForwardPut(network, address, forward.Writable())
+}
+
+func (c *networkCmd) doNetworkForwardPortRemove(client *lxd.Client, network string, address string, args []string) error {
+	// we shifted @args so so it should read "<protocol> <listen ports>"
+	if len(args) != 2 {
+		return errArgs
+	}
+
+	forward, err := client.NetworkForwardGet(network, address)
+	if err != nil {
+		return err
+	}
+
+	ports := []api.NetworkForwardPort{}
+	for _, port := range forward.Ports {
+		if port.Protocol == args[0] && port.ListenPort == args[1] {
+			continue
+		}
+
+		ports = append(ports, port)
+	}
+
+	if len(ports) == len(forward.Ports) {
+		return fmt.Errorf(i18n.G("No %s forward for port %s"), args[0], args[1])
+	}
+
+	forward.Ports = ports
+
+	return client.NetworkForwardPut(network, address, forward.Writable())
+}
+
+func (c *networkCmd) doNetworkZone(config *lxd.Config, args []string) error {
+	if len(args) < 1 {
+		return errArgs
+	}
+
+	if args[0] == "list" {
+		return c.doNetworkZoneList(config, args)
+	}
+
+	if len(args) < 2 {
+		return errArgs
+	}
+
+	remote, zone := config.ParseRemoteAndContainer(args[1])
+	client, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "create":
+		return c.doNetworkZoneCreate(client, zone, args[2:])
+	case "delete":
+		return c.doNetworkZoneDelete(client, zone)
+	case "edit":
+		return c.doNetworkZoneEdit(client, zone)
+	case "get":
+		return c.doNetworkZoneGet(client, zone, args[2:])
+	case "set":
+		return c.doNetworkZoneSet(client, zone, args[2:])
+	case "unset":
+		if len(args) != 3 {
+			return errArgs
+		}
+		return c.doNetworkZoneSet(client, zone, args[2:])
+	case "show":
+		return c.doNetworkZoneShow(client, zone)
+	default:
+		return errArgs
+	}
+}
+
+func (c *networkCmd) doNetworkZoneList(config *lxd.Config, args []string) error {
+	var remote string
+	if len(args) > 1 {
+		var name string
+		remote, name = config.ParseRemoteAndContainer(args[1])
+		if name != "" {
+			return fmt.Errorf(i18n.G("Cannot provide container name to list"))
+		}
+	} else {
+		remote = config.DefaultRemote
+	}
+
+	client, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	zones, err := client.ListNetworkZones()
+	if err != nil {
+		return err
+	}
+
+	data := [][]string{}
+	for _, zone := range zones {
+		strUsedBy := fmt.Sprintf("%d", len(zone.UsedBy))
+		data = append(data, []string{zone.Name, zone.Description, strUsedBy})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowLine(true)
+	table.SetHeader([]string{
+		i18n.G("NAME"),
+		i18n.G("DESCRIPTION"),
+		i18n.G("USED BY")})
+	sort.Sort(byName(data))
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
+
+func (c *networkCmd) doNetworkZoneCreate(client *lxd.Client, name string, args []string) error {
+	zone := api.NetworkZonesPost{
+		Name: name,
+	}
+	zone.Config = map[string]string{}
+
+	for _, entry := range args {
+		if !strings.Contains(entry, "=") {
+			return fmt.Errorf(i18n.G("Bad key=value pair: %s"), entry)
+		}
+
+		fields := strings.SplitN(entry, "=", 2)
+		zone.Config[fields[0]] = fields[1]
+	}
+
+	err := client.NetworkZoneCreate(zone)
+	if err == nil {
+		fmt.Printf(i18n.G("Network zone %s created")+"\n", name)
+	}
+
+	return err
+}
+
+func (c *networkCmd) doNetworkZoneDelete(client *lxd.Client, name string) error {
+	err := client.NetworkZoneDelete(name)
+	if err == nil {
+		fmt.Printf(i18n.G("Network zone %s deleted")+"\n", name)
+	}
+
+	return err
+}
+
+func (c *networkCmd) doNetworkZoneEdit(client *lxd.Client, name string) error {
+	// If stdin isn't a terminal, read text from it
+	if !termios.IsTerminal(int(syscall.Stdin)) {
+		contents, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		newdata := api.NetworkZonePut{}
+		err = yaml.Unmarshal(contents, &newdata)
+		if err != nil {
+			return err
+		}
+		return client.NetworkZonePut(name, newdata)
+	}
+
+	// Extract the current value
+	zone, err := client.NetworkZoneGet(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&zone)
+	if err != nil {
+		return err
+	}
+
+	// Spawn the editor
+	content, err := shared.TextEditor("", []byte(c.networkZoneEditHelp()+"\n\n"+string(data)))
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Parse the text received from the editor
+		newdata := api.NetworkZonePut{}
+		err = yaml.Unmarshal(content, &newdata)
+		if err == nil {
+			err = client.NetworkZonePut(name, newdata)
+		}
+
+		// Respawn the editor
+		if err != nil {
+			fmt.Fprintf(os.Stderr, i18n.G("Config parsing error: %s")+"\n", err)
+			fmt.Println(i18n.G("Press enter to open the editor again"))
+
+			_, err := os.Stdin.Read(make([]byte, 1))
+			if err != nil {
+				return err
+			}
+
+			content, err = shared.TextEditor("", content)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		break
+	}
+	return nil
+}
+
+func (c *networkCmd) doNetworkZoneGet(client *lxd.Client, name string, args []string) error {
+	// we shifted @args so so it should read "<key>"
+	if len(args) != 1 {
+		return errArgs
+	}
+
+	zone, err := client.NetworkZoneGet(name)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range zone.Config {
+		if k == args[0] {
+			fmt.Printf("%s\n", v)
+		}
+	}
+	return nil
+}
+
+func (c *networkCmd) doNetworkZoneSet(client *lxd.Client, name string, args []string) error {
+	// we shifted @args so so it should read "<key> [<value>]"
+	if len(args) < 1 {
+		return errArgs
+	}
+
+	zone, err := client.NetworkZoneGet(name)
+	if err != nil {
+		return err
+	}
+
+	key := args[0]
+	var value string
+	if len(args) < 2 {
+		value = ""
+	} else {
+		value = args[1]
+	}
+
+	if zone.Config == nil {
+		zone.Config = map[string]string{}
+	}
+
+	if value == "" {
+		delete(zone.Config, key)
+	} else {
+		zone.Config[key] = value
+	}
+
+	return client.NetworkZonePut(name, zone.Writable())
+}
+
+func (c *networkCmd) doNetworkZoneShow(client *lxd.Client, name string) error {
+	zone, err := client.NetworkZoneGet(name)
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(zone.UsedBy)
+
+	data, err := yaml.Marshal(&zone)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", data)
+
+	return nil
+}
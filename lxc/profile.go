@@ -14,6 +14,7 @@ import (
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/i18n"
+	"github.com/lxc/lxd/shared/schema"
 	"github.com/lxc/lxd/shared/termios"
 )
 
@@ -111,6 +112,13 @@ lxc profile device unset [<remote>:]<profile> <name> <key>
 lxc profile device add [<remote>:]<profile> <device> <type> [key=value...]
     Add a profile device, such as a disk or a nic, to the containers using the specified profile.
 
+lxc profile device add [<remote>:]<profile> - < devices.yaml
+    Add the devices described in YAML on stdin (a map of device names to
+    their properties, including "type") to the profile.
+
+lxc profile device edit [<remote>:]<profile> <device>
+    Edit a device's properties, either by launching external editor or reading STDIN.
+
 *Examples*
 cat profile.yaml | lxc profile edit <profile>
     Update a profile using the content of profile.yaml
@@ -211,6 +219,17 @@ func (c *profileCmd) doProfileCreate(client *lxd.Client, p string) error {
 	return err
 }
 
+// getProfileSchema returns the profile schema, extended with the read-only
+// fields found in "lxc profile show" so that its output can be fed back in
+func getProfileSchema(client *lxd.Client) (schema.Schema, error) {
+	s, err := getSchema(client, "profile")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Allow("name", "used_by"), nil
+}
+
 func (c *profileCmd) doProfileEdit(client *lxd.Client, p string) error {
 	// If stdin isn't a terminal, read text from it
 	if !termios.IsTerminal(int(syscall.Stdin)) {
@@ -219,6 +238,16 @@ func (c *profileCmd) doProfileEdit(client *lxd.Client, p string) error {
 			return err
 		}
 
+		s, err := getProfileSchema(client)
+		if err != nil {
+			return err
+		}
+
+		err = schema.ValidateYAML(s, contents)
+		if err != nil {
+			return err
+		}
+
 		newdata := api.ProfilePut{}
 		err = yaml.Unmarshal(contents, &newdata)
 		if err != nil {
@@ -244,10 +273,19 @@ func (c *profileCmd) doProfileEdit(client *lxd.Client, p string) error {
 		return err
 	}
 
+	s, err := getProfileSchema(client)
+	if err != nil {
+		return err
+	}
+
 	for {
-		// Parse the text received from the editor
+		// Validate and parse the text received from the editor
 		newdata := api.ProfilePut{}
-		err = yaml.Unmarshal(content, &newdata)
+		err = schema.ValidateYAML(s, content)
+		if err == nil {
+			err = yaml.Unmarshal(content, &newdata)
+		}
+
 		if err == nil {
 			err = client.PutProfile(p, newdata)
 		}
@@ -405,6 +443,8 @@ func (c *profileCmd) doProfileDevice(config *lxd.Config, args []string) error {
 		return cfg.deviceSet(config, "profile", args)
 	case "unset":
 		return cfg.deviceUnset(config, "profile", args)
+	case "edit":
+		return cfg.deviceEdit(config, "profile", args)
 	default:
 		return errArgs
 	}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd"
+	"github.com/lxc/lxd/shared/i18n"
+	"github.com/lxc/lxd/shared/termios"
+)
+
+type projectCmd struct {
+}
+
+func (c *projectCmd) showByDefault() bool {
+	return true
+}
+
+func (c *projectCmd) usage() string {
+	return i18n.G(
+		`Usage: lxc project <subcommand> [options]
+
+Manage projects, each holding its own set of containers and profiles.
+
+lxc project list [<remote>:]
+    List available projects, the one currently in use being marked.
+
+lxc project show [<remote>:]<project>
+    Show details of a project.
+
+lxc project create [<remote>:]<project> [key=value...]
+    Create a project.
+
+lxc project get [<remote>:]<project> <key>
+    Get project configuration.
+
+lxc project set [<remote>:]<project> <key> <value>
+    Set project configuration.
+
+lxc project unset [<remote>:]<project> <key>
+    Unset project configuration.
+
+lxc project delete [<remote>:]<project>
+    Delete an empty project.
+
+lxc project switch [<remote>:]<project>
+    Use the project for all further commands against the remote.
+
+The project to use can also be set for a single command with --project.`)
+}
+
+func (c *projectCmd) flags() {}
+
+func (c *projectCmd) run(config *lxd.Config, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	if args[0] == "list" {
+		return c.doProjectList(config, args)
+	}
+
+	if len(args) < 2 {
+		return errArgs
+	}
+
+	remote, project := config.ParseRemoteAndContainer(args[1])
+	if project == "" {
+		return errArgs
+	}
+
+	if args[0] == "switch" {
+		return c.doProjectSwitch(config, remote, project)
+	}
+
+	client, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "create":
+		return c.doProjectCreate(client, project, args[2:])
+	case "delete":
+		return c.doProjectDelete(client, project)
+	case "get":
+		return c.doProjectGet(client, project, args[2:])
+	case "set":
+		return c.doProjectSet(client, project, args[2:])
+	case "unset":
+		if len(args) != 3 {
+			return errArgs
+		}
+
+		return c.doProjectSet(client, project, args[2:])
+	case "show":
+		return c.doProjectShow(client, project)
+	default:
+		return errArgs
+	}
+}
+
+func (c *projectCmd) doProjectCreate(client *lxd.Client, name string, args []string) error {
+	config := map[string]string{}
+
+	for i := 0; i < len(args); i++ {
+		entry := strings.SplitN(args[i], "=", 2)
+		if len(entry) < 2 {
+			return errArgs
+		}
+
+		config[entry[0]] = entry[1]
+	}
+
+	err := client.ProjectCreate(name, config)
+	if err == nil {
+		fmt.Printf(i18n.G("Project %s created")+"\n", name)
+	}
+
+	return err
+}
+
+func (c *projectCmd) doProjectDelete(client *lxd.Client, name string) error {
+	err := client.ProjectDelete(name)
+	if err == nil {
+		fmt.Printf(i18n.G("Project %s deleted")+"\n", name)
+	}
+
+	return err
+}
+
+func (c *projectCmd) doProjectGet(client *lxd.Client, name string, args []string) error {
+	if len(args) != 1 {
+		return errArgs
+	}
+
+	project, err := client.ProjectGet(name)
+	if err != nil {
+		return err
+	}
+
+	value, ok := project.Config[args[0]]
+	if ok {
+		fmt.Printf("%s\n", value)
+	}
+
+	return nil
+}
+
+func (c *projectCmd) doProjectList(config *lxd.Config, args []string) error {
+	var remote string
+	if len(args) > 1 {
+		var name string
+		remote, name = config.ParseRemoteAndContainer(args[1])
+		if name != "" {
+			return fmt.Errorf(i18n.G("Cannot provide container name to list"))
+		}
+	} else {
+		remote = config.DefaultRemote
+	}
+
+	client, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	projects, err := client.ListProjects()
+	if err != nil {
+		return err
+	}
+
+	current := client.Remote.Project
+	if current == "" {
+		current = "default"
+	}
+
+	data := [][]string{}
+	for _, project := range projects {
+		name := project.Name
+		if name == current {
+			name = fmt.Sprintf("%s (%s)", name, i18n.G("current"))
+		}
+
+		strUsedBy := fmt.Sprintf("%d", len(project.UsedBy))
+		data = append(data, []string{name, project.Description, strUsedBy})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowLine(true)
+	table.SetHeader([]string{
+		i18n.G("NAME"),
+		i18n.G("DESCRIPTION"),
+		i18n.G("USED BY")})
+	sort.Sort(byName(data))
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
+
+func (c *projectCmd) doProjectSet(client *lxd.Client, name string, args []string) error {
+	// we shifted @args so so it should read "<key> [<value>]"
+	if len(args) < 1 {
+		return errArgs
+	}
+
+	project, err := client.ProjectGet(name)
+	if err != nil {
+		return err
+	}
+
+	key := args[0]
+	var value string
+	if len(args) < 2 {
+		value = ""
+	} else {
+		value = args[1]
+	}
+
+	if !termios.IsTerminal(int(syscall.Stdin)) && value == "-" {
+		buf, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf(i18n.G("Can't read from stdin: %s"), err)
+		}
+		value = string(buf[:])
+	}
+
+	if project.Config == nil {
+		project.Config = map[string]string{}
+	}
+	project.Config[key] = value
+
+	return client.ProjectPut(name, project.Writable())
+}
+
+func (c *projectCmd) doProjectShow(client *lxd.Client, name string) error {
+	project, err := client.ProjectGet(name)
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(project.UsedBy)
+
+	data, err := yaml.Marshal(&project)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", data)
+
+	return nil
+}
+
+func (c *projectCmd) doProjectSwitch(config *lxd.Config, remote string, name string) error {
+	rc, ok := config.Remotes[remote]
+	if !ok {
+		return fmt.Errorf(i18n.G("remote %s doesn't exist"), remote)
+	}
+
+	if rc.Public {
+		return fmt.Errorf(i18n.G("Public remotes don't have projects"))
+	}
+
+	// Make sure the project exists before switching to it, without
+	// going through the current one as it may be gone already
+	config.Project = name
+	client, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.ProjectGet(name)
+	if err != nil {
+		return err
+	}
+
+	if name == "default" {
+		name = ""
+	}
+
+	rc.Project = name
+	config.Remotes[remote] = rc
+
+	return lxd.SaveConfig(config, configPath)
+}
@@ -1,26 +1,34 @@
 package main
 
 import (
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/csv"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 
 	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/yaml.v2"
 
 	"github.com/lxc/lxd"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/gnuflag"
 	"github.com/lxc/lxd/shared/i18n"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/simplestreams"
+	"github.com/lxc/lxd/shared/version"
 )
 
 type remoteCmd struct {
@@ -28,6 +36,16 @@ type remoteCmd struct {
 	password   string
 	public     bool
 	protocol   string
+	format     string
+}
+
+type remoteListEntry struct {
+	Name     string `json:"name" yaml:"name"`
+	Addr     string `json:"addr" yaml:"addr"`
+	Protocol string `json:"protocol" yaml:"protocol"`
+	Public   bool   `json:"public" yaml:"public"`
+	Static   bool   `json:"static" yaml:"static"`
+	Default  bool   `json:"default" yaml:"default"`
 }
 
 func (c *remoteCmd) showByDefault() bool {
@@ -43,10 +61,20 @@ Manage the list of remote LXD servers.
 lxc remote add [<remote>] <IP|FQDN|URL> [--accept-certificate] [--password=PASSWORD] [--public] [--protocol=PROTOCOL]
     Add the remote <remote> at <url>.
 
+lxc remote add <remote> <token>
+    Add the remote <remote> using a join token from "lxc config trust token".
+
+lxc remote add <remote> ssh://[user@]host[:port][/path/to/unix.socket]
+    Add the remote <remote>, reached through ssh using the LXD socket of host.
+
+    The protocol ("lxd" or "simplestreams") is detected when an https URL
+    is given without --protocol. Remotes added with --public are only used
+    as image servers and don't need a client certificate or password.
+
 lxc remote remove <remote>
     Remove the remote <remote>.
 
-lxc remote list
+lxc remote list [--format csv|json|table|yaml]
     List all remotes.
 
 lxc remote rename <old name> <new name>
@@ -59,7 +87,16 @@ lxc remote set-default <remote>
     Set the default remote.
 
 lxc remote get-default
-    Print the default remote.`)
+    Print the default remote.
+
+lxc remote check <remote>
+    Check the connection to <remote>, from TCP reachability to authentication.
+
+Each remote in config.yml can also set "connect_timeout" and "read_timeout"
+(in seconds) and "verify" ("default" or "none" to skip TLS verification).
+
+Setting "key_storage: keyring" in config.yml keeps the client key in the OS
+keyring (secret service or macOS keychain) rather than in client.key.`)
 }
 
 func (c *remoteCmd) flags() {
@@ -67,6 +104,7 @@ func (c *remoteCmd) flags() {
 	gnuflag.StringVar(&c.password, "password", "", i18n.G("Remote admin password"))
 	gnuflag.StringVar(&c.protocol, "protocol", "", i18n.G("Server protocol (lxd or simplestreams)"))
 	gnuflag.BoolVar(&c.public, "public", false, i18n.G("Public image server"))
+	gnuflag.StringVar(&c.format, "format", "table", i18n.G("Format (csv|json|table|yaml)"))
 }
 
 func (c *remoteCmd) generateClientCertificate(config *lxd.Config) error {
@@ -76,6 +114,32 @@ func (c *remoteCmd) generateClientCertificate(config *lxd.Config) error {
 	// testing scenarios where only the default repositories are used.
 	certf := config.ConfigPath("client.crt")
 	keyf := config.ConfigPath("client.key")
+
+	if config.UseKeyring() {
+		if shared.PathExists(certf) && config.HasClientKey() {
+			return nil
+		}
+
+		fmt.Fprintf(os.Stderr, i18n.G("Generating a client certificate. This may take a minute...")+"\n")
+
+		err := os.MkdirAll(config.ConfigDir, 0750)
+		if err != nil {
+			return err
+		}
+
+		certBytes, keyBytes, err := shared.GenerateMemCert(true)
+		if err != nil {
+			return err
+		}
+
+		err = config.SaveClientKey(keyBytes)
+		if err != nil {
+			return err
+		}
+
+		return ioutil.WriteFile(certf, certBytes, 0644)
+	}
+
 	if !shared.PathExists(certf) || !shared.PathExists(keyf) {
 		fmt.Fprintf(os.Stderr, i18n.G("Generating a client certificate. This may take a minute...")+"\n")
 
@@ -84,7 +148,7 @@ func (c *remoteCmd) generateClientCertificate(config *lxd.Config) error {
 	return nil
 }
 
-func (c *remoteCmd) getRemoteCertificate(address string) (*x509.Certificate, error) {
+func (c *remoteCmd) permissiveClient() (*http.Client, error) {
 	// Setup a permissive TLS config
 	tlsConfig, err := shared.GetTLSConfig("", "", "", nil)
 	if err != nil {
@@ -98,8 +162,16 @@ func (c *remoteCmd) getRemoteCertificate(address string) (*x509.Certificate, err
 		Proxy:           shared.ProxyFromEnvironment,
 	}
 
+	return &http.Client{Transport: tr}, nil
+}
+
+func (c *remoteCmd) getRemoteCertificate(address string) (*x509.Certificate, error) {
+	client, err := c.permissiveClient()
+	if err != nil {
+		return nil, err
+	}
+
 	// Connect
-	client := &http.Client{Transport: tr}
 	resp, err := client.Get(address)
 	if err != nil {
 		return nil, err
@@ -113,30 +185,74 @@ func (c *remoteCmd) getRemoteCertificate(address string) (*x509.Certificate, err
 	return resp.TLS.PeerCertificates[0], nil
 }
 
-func (c *remoteCmd) addServer(config *lxd.Config, server string, addr string, acceptCert bool, password string, public bool, protocol string) error {
-	var rScheme string
-	var rHost string
-	var rPort string
+// remoteProtocols maps the accepted --protocol values to the stored protocol
+var remoteProtocols = map[string]string{
+	"lxd":           "lxd",
+	"simplestreams": "simplestreams",
+	"simplestream":  "simplestreams",
+}
 
-	// Setup the remotes list
-	if config.Remotes == nil {
-		config.Remotes = make(map[string]lxd.RemoteConfig)
+func (c *remoteCmd) parseProtocol(protocol string) (string, error) {
+	if protocol == "" {
+		return "", nil
 	}
 
-	/* Complex remote URL parsing */
-	remoteURL, err := url.Parse(addr)
+	value, ok := remoteProtocols[strings.ToLower(protocol)]
+	if !ok {
+		return "", fmt.Errorf(i18n.G("Invalid protocol: %s"), protocol)
+	}
+
+	return value, nil
+}
+
+// detectProtocol probes an https URL for the LXD API, then for a
+// simplestreams index. The certificate isn't checked at this point, this is
+// done later on for LXD remotes.
+func (c *remoteCmd) detectProtocol(address string) (string, error) {
+	client, err := c.permissiveClient()
 	if err != nil {
-		remoteURL = &url.URL{Host: addr}
+		return "", err
 	}
 
-	// Fast track simplestreams
-	if protocol == "simplestreams" {
-		if remoteURL.Scheme != "https" {
-			return fmt.Errorf(i18n.G("Only https URLs are supported for simplestreams"))
-		}
+	address = strings.TrimSuffix(address, "/")
 
-		config.Remotes[server] = lxd.RemoteConfig{Addr: addr, Public: true, Protocol: protocol}
-		return nil
+	resp, err := client.Get(address + "/1.0")
+	if err != nil {
+		return "", err
+	}
+
+	response := api.Response{}
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	resp.Body.Close()
+	if err == nil && response.Type == api.SyncResponse {
+		return "lxd", nil
+	}
+
+	resp, err = client.Get(address + "/streams/v1/index.json")
+	if err != nil {
+		return "", err
+	}
+
+	index := simplestreams.SimpleStreamsIndex{}
+	err = json.NewDecoder(resp.Body).Decode(&index)
+	resp.Body.Close()
+	if err == nil && resp.StatusCode == http.StatusOK && index.Format == "index:1.0" {
+		return "simplestreams", nil
+	}
+
+	return "", fmt.Errorf(i18n.G("%s isn't a LXD or simplestreams server, use --protocol to override"), address)
+}
+
+// parseAddress turns the address of a LXD remote, be it an IP, a FQDN, a
+// unix socket path or a full URL, into its scheme and canonical URL.
+func (c *remoteCmd) parseAddress(addr string) (string, string, error) {
+	var rScheme string
+	var rHost string
+	var rPort string
+
+	remoteURL, err := url.Parse(addr)
+	if err != nil {
+		remoteURL = &url.URL{Host: addr}
 	}
 
 	// Fix broken URL parser
@@ -147,7 +263,7 @@ func (c *remoteCmd) addServer(config *lxd.Config, server string, addr string, ac
 
 	if remoteURL.Scheme != "" {
 		if remoteURL.Scheme != "unix" && remoteURL.Scheme != "https" {
-			return fmt.Errorf(i18n.G("Invalid URL scheme \"%s\" in \"%s\""), remoteURL.Scheme, addr)
+			return "", "", fmt.Errorf(i18n.G("Invalid URL scheme \"%s\" in \"%s\""), remoteURL.Scheme, addr)
 		}
 
 		rScheme = remoteURL.Scheme
@@ -185,9 +301,58 @@ func (c *remoteCmd) addServer(config *lxd.Config, server string, addr string, ac
 	}
 
 	if rPort != "" {
-		addr = rScheme + "://" + rHost + ":" + rPort
-	} else {
-		addr = rScheme + "://" + rHost
+		return rScheme, rScheme + "://" + rHost + ":" + rPort, nil
+	}
+
+	return rScheme, rScheme + "://" + rHost, nil
+}
+
+func (c *remoteCmd) addServer(config *lxd.Config, server string, addr string, acceptCert bool, password string, public bool, protocol string, fingerprint string) error {
+	// Setup the remotes list
+	if config.Remotes == nil {
+		config.Remotes = make(map[string]lxd.RemoteConfig)
+	}
+
+	/* Complex remote URL parsing */
+	remoteURL, err := url.Parse(addr)
+	if err != nil {
+		remoteURL = &url.URL{Host: addr}
+	}
+
+	protocol, err = c.parseProtocol(protocol)
+	if err != nil {
+		return err
+	}
+
+	// Only full URLs can point to a simplestreams server
+	if protocol == "" && remoteURL.Scheme == "https" && remoteURL.Host != "" {
+		protocol, err = c.detectProtocol(addr)
+		if err != nil {
+			return err
+		}
+	}
+
+	// SSH remotes relay to the unix socket of the server, with ssh doing
+	// the authentication
+	if remoteURL.Scheme == "ssh" {
+		config.Remotes[server] = lxd.RemoteConfig{Addr: addr}
+		_, err := lxd.NewClient(config, server)
+		return err
+	}
+
+	// Fast track simplestreams
+	if protocol == "simplestreams" {
+		if remoteURL.Scheme != "https" {
+			return fmt.Errorf(i18n.G("Only https URLs are supported for simplestreams"))
+		}
+
+		config.Remotes[server] = lxd.RemoteConfig{Addr: addr, Public: true, Protocol: protocol}
+		return nil
+	}
+
+	rScheme, addr, err := c.parseAddress(addr)
+	if err != nil {
+		return err
 	}
 
 	// Finally, actually add the remote, almost...  If the remote is a private
@@ -226,7 +391,11 @@ func (c *remoteCmd) addServer(config *lxd.Config, server string, addr string, ac
 	}
 
 	if certificate != nil {
-		if !acceptCert {
+		if fingerprint != "" {
+			if shared.CertFingerprint(certificate) != fingerprint {
+				return fmt.Errorf(i18n.G("The server certificate doesn't match the one in the join token"))
+			}
+		} else if !acceptCert {
 			digest := shared.CertFingerprint(certificate)
 
 			fmt.Printf(i18n.G("Certificate fingerprint: %s")+"\n", digest)
@@ -264,7 +433,7 @@ func (c *remoteCmd) addServer(config *lxd.Config, server string, addr string, ac
 	}
 
 	if d.IsPublic() || public {
-		config.Remotes[server] = lxd.RemoteConfig{Addr: addr, Public: true}
+		config.Remotes[server] = lxd.RemoteConfig{Addr: addr, Public: true, Protocol: protocol}
 
 		if _, err := d.GetServerConfig(); err != nil {
 			return err
@@ -306,6 +475,29 @@ func (c *remoteCmd) addServer(config *lxd.Config, server string, addr string, ac
 	return nil
 }
 
+// addServerToken adds a remote from a join token, trying each of the
+// server's addresses in turn.
+func (c *remoteCmd) addServerToken(config *lxd.Config, server string, token *api.CertificateToken) error {
+	var err error
+
+	for _, addr := range token.Addresses {
+		err = c.addServer(config, server, addr, false, token.Secret, false, "lxd", token.Fingerprint)
+		if err == nil {
+			return nil
+		}
+
+		logger.Debugf("Failed to add remote using %s: %s", addr, err)
+		delete(config.Remotes, server)
+		c.removeCertificate(config, server)
+	}
+
+	if err == nil {
+		return fmt.Errorf(i18n.G("The join token doesn't contain any address"))
+	}
+
+	return err
+}
+
 func (c *remoteCmd) removeCertificate(config *lxd.Config, remote string) {
 	certf := config.ServerCertPath(remote)
 	logger.Debugf("Trying to remove %s", certf)
@@ -313,6 +505,227 @@ func (c *remoteCmd) removeCertificate(config *lxd.Config, remote string) {
 	os.Remove(certf)
 }
 
+func (c *remoteCmd) doRemoteList(config *lxd.Config) error {
+	names := []string{}
+	for name := range config.Remotes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := []remoteListEntry{}
+	for _, name := range names {
+		rc := config.Remotes[name]
+		if rc.Protocol == "" {
+			rc.Protocol = "lxd"
+		}
+
+		entries = append(entries, remoteListEntry{
+			Name:     name,
+			Addr:     rc.Addr,
+			Protocol: rc.Protocol,
+			Public:   rc.Public,
+			Static:   rc.Static,
+			Default:  name == config.DefaultRemote,
+		})
+	}
+
+	tableData := func() [][]string {
+		yesNo := func(value bool) string {
+			if value {
+				return i18n.G("YES")
+			}
+
+			return i18n.G("NO")
+		}
+
+		data := [][]string{}
+		for _, entry := range entries {
+			strName := entry.Name
+			if entry.Default {
+				strName = fmt.Sprintf("%s (%s)", entry.Name, i18n.G("default"))
+			}
+
+			data = append(data, []string{strName, entry.Addr, entry.Protocol, yesNo(entry.Public), yesNo(entry.Static)})
+		}
+
+		return data
+	}
+
+	switch c.format {
+	case listFormatCSV:
+		w := csv.NewWriter(os.Stdout)
+		w.WriteAll(tableData())
+		if err := w.Error(); err != nil {
+			return err
+		}
+	case listFormatTable:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetAutoWrapText(false)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetRowLine(true)
+		table.SetHeader([]string{
+			i18n.G("NAME"),
+			i18n.G("URL"),
+			i18n.G("PROTOCOL"),
+			i18n.G("PUBLIC"),
+			i18n.G("STATIC")})
+		table.AppendBulk(tableData())
+		table.Render()
+	case listFormatJSON:
+		enc := json.NewEncoder(os.Stdout)
+		err := enc.Encode(entries)
+		if err != nil {
+			return err
+		}
+	case listFormatYAML:
+		out, err := yaml.Marshal(entries)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s", out)
+	default:
+		return fmt.Errorf("invalid format %q", c.format)
+	}
+
+	return nil
+}
+
+func (c *remoteCmd) doRemoteCheck(config *lxd.Config, name string) error {
+	rc, ok := config.Remotes[name]
+	if !ok {
+		return fmt.Errorf(i18n.G("remote %s doesn't exist"), name)
+	}
+
+	failed := func(step string, err error, diagnosis string) error {
+		fmt.Printf("%s: %s\n", step, err)
+		fmt.Printf(i18n.G("Diagnosis: %s")+"\n", diagnosis)
+		return fmt.Errorf(i18n.G("Remote %s failed the %s check"), name, strings.ToLower(step))
+	}
+
+	if strings.HasPrefix(rc.Addr, "unix:") || strings.HasPrefix(rc.Addr, "ssh:") {
+		d, err := lxd.NewClient(config, name)
+		if err != nil {
+			return failed(i18n.G("Connection"), err, i18n.G("The LXD daemon isn't running or you don't have access to its socket (are you in the lxd group?)"))
+		}
+
+		fmt.Printf("%s: %s\n", i18n.G("Connection"), i18n.G("ok"))
+		return c.checkAPI(d, failed)
+	}
+
+	remoteURL, err := url.Parse(rc.Addr)
+	if err != nil || remoteURL.Host == "" {
+		return failed(i18n.G("Address"), fmt.Errorf(i18n.G("Invalid URL %s"), rc.Addr), i18n.G("Fix the remote's URL with \"lxc remote set-url\""))
+	}
+
+	host := remoteURL.Host
+	hostname, _, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		host = net.JoinHostPort(hostname, "443")
+	}
+
+	// TCP reachability
+	conn, err := shared.RFC3493DialerTimeout(5*time.Second)("tcp", host)
+	if err != nil {
+		return failed(i18n.G("TCP connection"), err, i18n.G("The server is down, the address or port is wrong, or a firewall is in the way (is core.https_address set on the server?)"))
+	}
+	fmt.Printf("%s: %s\n", i18n.G("TCP connection"), i18n.G("ok"))
+
+	// TLS handshake, the certificate is checked separately
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+	tlsConn.SetDeadline(time.Now().Add(10 * time.Second))
+	err = tlsConn.Handshake()
+	if err != nil {
+		conn.Close()
+		return failed(i18n.G("TLS handshake"), err, i18n.G("Something other than LXD is listening on that port, or a proxy is intercepting the connection"))
+	}
+
+	certs := tlsConn.ConnectionState().PeerCertificates
+	tlsConn.Close()
+	if len(certs) == 0 {
+		return failed(i18n.G("TLS handshake"), fmt.Errorf(i18n.G("Unable to read remote TLS certificate")), i18n.G("The server didn't present a certificate"))
+	}
+	fmt.Printf("%s: %s\n", i18n.G("TLS handshake"), i18n.G("ok"))
+
+	// Certificate validity
+	cert := certs[0]
+	const layout = "2006/01/02 15:04 UTC"
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		err := fmt.Errorf(i18n.G("valid from %s to %s"), cert.NotBefore.UTC().Format(layout), cert.NotAfter.UTC().Format(layout))
+		return failed(i18n.G("Certificate validity"), err, i18n.G("The server certificate expired (or the clock of one of the machines is wrong) and needs to be regenerated"))
+	}
+	fmt.Printf("%s: %s\n", i18n.G("Certificate validity"), fmt.Sprintf(i18n.G("ok (until %s)"), cert.NotAfter.UTC().Format(layout)))
+
+	// Certificate trust
+	certPath := config.ServerCertPath(name)
+	if shared.PathExists(certPath) {
+		stored, err := shared.ReadCert(certPath)
+		if err != nil {
+			return failed(i18n.G("Certificate trust"), err, fmt.Sprintf(i18n.G("Remove and re-add the remote to replace %s"), certPath))
+		}
+
+		if !stored.Equal(cert) {
+			err := fmt.Errorf(i18n.G("fingerprint %s doesn't match the stored %s"), shared.CertFingerprint(cert), shared.CertFingerprint(stored))
+			return failed(i18n.G("Certificate trust"), err, i18n.G("The server certificate changed, if that's expected remove and re-add the remote"))
+		}
+
+		fmt.Printf("%s: %s\n", i18n.G("Certificate trust"), i18n.G("ok (matches the stored certificate)"))
+	} else if rc.Verify == "none" {
+		fmt.Printf("%s: %s\n", i18n.G("Certificate trust"), i18n.G("not verified"))
+	} else {
+		intermediates := x509.NewCertPool()
+		for _, extra := range certs[1:] {
+			intermediates.AddCert(extra)
+		}
+
+		_, err := cert.Verify(x509.VerifyOptions{DNSName: hostname, Intermediates: intermediates})
+		if err != nil {
+			return failed(i18n.G("Certificate trust"), err, i18n.G("The certificate isn't signed by a known CA and none was stored for this remote, re-add it to accept the certificate"))
+		}
+
+		fmt.Printf("%s: %s\n", i18n.G("Certificate trust"), i18n.G("ok (signed by a known CA)"))
+	}
+
+	d, err := lxd.NewClient(config, name)
+	if err != nil {
+		return failed(i18n.G("Connection"), err, i18n.G("The client configuration or certificate couldn't be loaded"))
+	}
+
+	if rc.Protocol == "simplestreams" {
+		_, err := d.ListImages()
+		if err != nil {
+			return failed(i18n.G("Image index"), err, i18n.G("The URL doesn't point to a simplestreams image server"))
+		}
+
+		fmt.Printf("%s: %s\n", i18n.G("Image index"), i18n.G("ok"))
+		return nil
+	}
+
+	return c.checkAPI(d, failed)
+}
+
+func (c *remoteCmd) checkAPI(d *lxd.Client, failed func(string, error, string) error) error {
+	status, err := d.ServerStatus()
+	if err != nil {
+		return failed(i18n.G("API"), err, i18n.G("The server doesn't speak the LXD API, check the URL and protocol of the remote"))
+	}
+
+	if status.APIVersion != version.APIVersion {
+		err := fmt.Errorf(i18n.G("server has API %s, client has %s"), status.APIVersion, version.APIVersion)
+		return failed(i18n.G("API version"), err, i18n.G("Use a client matching the server's API version"))
+	}
+	fmt.Printf("%s: %s\n", i18n.G("API version"), status.APIVersion)
+
+	if status.Auth != "trusted" && !d.Remote.Public && !status.Public {
+		err := fmt.Errorf("%s", status.Auth)
+		return failed(i18n.G("Authentication"), err, i18n.G("The server doesn't trust this client, re-add the remote with the server's trust password"))
+	}
+	fmt.Printf("%s: %s\n", i18n.G("Authentication"), status.Auth)
+
+	return nil
+}
+
 func (c *remoteCmd) run(config *lxd.Config, args []string) error {
 	if len(args) < 1 {
 		return errUsage
@@ -334,7 +747,21 @@ func (c *remoteCmd) run(config *lxd.Config, args []string) error {
 			return fmt.Errorf(i18n.G("remote %s exists as <%s>"), remote, rc.Addr)
 		}
 
-		err := c.addServer(config, remote, fqdn, c.acceptCert, c.password, c.public, c.protocol)
+		token, err := decodeJoinToken(fqdn)
+		if err == nil {
+			if len(args) < 3 {
+				return fmt.Errorf(i18n.G("A remote name is required when adding a remote with a join token"))
+			}
+
+			err = c.addServerToken(config, remote, token)
+			if err != nil {
+				return err
+			}
+
+			break
+		}
+
+		err = c.addServer(config, remote, fqdn, c.acceptCert, c.password, c.public, c.protocol, "")
 		if err != nil {
 			delete(config.Remotes, remote)
 			c.removeCertificate(config, remote)
@@ -364,44 +791,11 @@ func (c *remoteCmd) run(config *lxd.Config, args []string) error {
 		c.removeCertificate(config, args[1])
 
 	case "list":
-		data := [][]string{}
-		for name, rc := range config.Remotes {
-			strPublic := i18n.G("NO")
-			if rc.Public {
-				strPublic = i18n.G("YES")
-			}
-
-			strStatic := i18n.G("NO")
-			if rc.Static {
-				strStatic = i18n.G("YES")
-			}
-
-			if rc.Protocol == "" {
-				rc.Protocol = "lxd"
-			}
-
-			strName := name
-			if name == config.DefaultRemote {
-				strName = fmt.Sprintf("%s (%s)", name, i18n.G("default"))
-			}
-			data = append(data, []string{strName, rc.Addr, rc.Protocol, strPublic, strStatic})
+		if len(args) != 1 {
+			return errArgs
 		}
 
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetAutoWrapText(false)
-		table.SetAlignment(tablewriter.ALIGN_LEFT)
-		table.SetRowLine(true)
-		table.SetHeader([]string{
-			i18n.G("NAME"),
-			i18n.G("URL"),
-			i18n.G("PROTOCOL"),
-			i18n.G("PUBLIC"),
-			i18n.G("STATIC")})
-		sort.Sort(byName(data))
-		table.AppendBulk(data)
-		table.Render()
-
-		return nil
+		return c.doRemoteList(config)
 
 	case "rename":
 		if len(args) != 3 {
@@ -422,8 +816,8 @@ func (c *remoteCmd) run(config *lxd.Config, args []string) error {
 		}
 
 		// Rename the certificate file
-		oldPath := filepath.Join(config.ConfigPath("servercerts"), fmt.Sprintf("%s.crt", args[1]))
-		newPath := filepath.Join(config.ConfigPath("servercerts"), fmt.Sprintf("%s.crt", args[2]))
+		oldPath := config.ServerCertPath(args[1])
+		newPath := config.ServerCertPath(args[2])
 		if shared.PathExists(oldPath) {
 			err := os.Rename(oldPath, newPath)
 			if err != nil {
@@ -438,6 +832,14 @@ func (c *remoteCmd) run(config *lxd.Config, args []string) error {
 			config.DefaultRemote = args[2]
 		}
 
+		// Don't leave the certificate behind a remote which wasn't renamed
+		err := lxd.SaveConfig(config, configPath)
+		if err != nil && shared.PathExists(newPath) {
+			os.Rename(newPath, oldPath)
+		}
+
+		return err
+
 	case "set-url":
 		if len(args) != 3 {
 			return errArgs
@@ -452,7 +854,26 @@ func (c *remoteCmd) run(config *lxd.Config, args []string) error {
 			return fmt.Errorf(i18n.G("remote %s is static and cannot be modified"), args[1])
 		}
 
-		config.Remotes[args[1]] = lxd.RemoteConfig{Addr: args[2]}
+		addr := args[2]
+		if rc.Protocol != "simplestreams" {
+			_, parsed, err := c.parseAddress(addr)
+			if err != nil {
+				return err
+			}
+
+			addr = parsed
+		}
+
+		// The stored certificate and other properties are kept
+		rc.Addr = addr
+		config.Remotes[args[1]] = rc
+
+	case "check":
+		if len(args) != 2 {
+			return errArgs
+		}
+
+		return c.doRemoteCheck(config, args[1])
 
 	case "set-default":
 		if len(args) != 2 {
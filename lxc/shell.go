@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/lxc/lxd"
+	"github.com/lxc/lxd/shared/gnuflag"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+// shellScript looks up root's shell in the container's /etc/passwd and runs
+// it as a login shell, falling back to /bin/sh.
+const shellScript = `login_shell=/bin/sh
+while IFS=: read -r user _ _ _ _ _ shell; do
+    if [ "${user}" = "root" ]; then
+        [ -x "${shell}" ] && login_shell="${shell}"
+        break
+    fi
+done < /etc/passwd
+cd "${HOME}" 2>/dev/null
+exec "${login_shell}" -l`
+
+type shellCmd struct {
+	exec execCmd
+}
+
+func (c *shellCmd) showByDefault() bool {
+	return true
+}
+
+func (c *shellCmd) usage() string {
+	return i18n.G(
+		`Usage: lxc shell [<remote>:]<container> [--env KEY=VALUE...]
+
+Open a login shell as root in a container.
+
+The shell is the one configured for root in the container's /etc/passwd.`)
+}
+
+func (c *shellCmd) flags() {
+	gnuflag.Var(&c.exec.envArgs, "env", i18n.G("Environment variable to set (e.g. HOME=/home/foo)"))
+}
+
+func (c *shellCmd) run(config *lxd.Config, args []string) error {
+	if len(args) != 1 {
+		return errArgs
+	}
+
+	c.exec.modeFlag = "auto"
+
+	return c.exec.run(config, []string{args[0], "sh", "-c", shellScript})
+}
@@ -10,7 +10,8 @@ import (
 )
 
 type snapshotCmd struct {
-	stateful bool
+	stateful    bool
+	description string
 }
 
 func (c *snapshotCmd) showByDefault() bool {
@@ -19,20 +20,28 @@ func (c *snapshotCmd) showByDefault() bool {
 
 func (c *snapshotCmd) usage() string {
 	return i18n.G(
-		`Usage: lxc snapshot [<remote>:]<container> <snapshot name> [--stateful]
+		`Usage: lxc snapshot [<remote>:]<container> [<snapshot name>] [--stateful] [--description <text>]
 
 Create container snapshots.
 
 When --stateful is used, LXD attempts to checkpoint the container's
 running state, including process memory state, TCP connections, ...
 
+When no name is given, one is generated from the container's
+"snapshots.pattern" key ("snap%d" by default), where "%d" is replaced by the
+next free number and "%t" by the current date and time.
+
 *Examples*
 lxc snapshot u1 snap0
-    Create a snapshot of "u1" called "snap0".`)
+    Create a snapshot of "u1" called "snap0".
+
+lxc snapshot u1 --stateful --description "Before upgrade"
+    Create a stateful snapshot of "u1" with a generated name.`)
 }
 
 func (c *snapshotCmd) flags() {
 	gnuflag.BoolVar(&c.stateful, "stateful", false, i18n.G("Whether or not to snapshot the container's running state"))
+	gnuflag.StringVar(&c.description, "description", "", i18n.G("Description of the snapshot"))
 }
 
 func (c *snapshotCmd) run(config *lxd.Config, args []string) error {
@@ -58,7 +67,7 @@ func (c *snapshotCmd) run(config *lxd.Config, args []string) error {
 		return fmt.Errorf(i18n.G("'/' not allowed in snapshot name"))
 	}
 
-	resp, err := d.Snapshot(name, snapname, c.stateful)
+	resp, err := d.SnapshotWithDescription(name, snapname, c.stateful, c.description)
 	if err != nil {
 		return err
 	}
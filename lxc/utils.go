@@ -1,10 +1,19 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/i18n"
 )
@@ -15,6 +24,8 @@ const (
 	listFormatJSON  = "json"
 	listFormatTable = "table"
 	listFormatYAML  = "yaml"
+
+	listFormatAnsible = "ansible-inventory"
 )
 
 // Progress tracking
@@ -159,18 +170,69 @@ type batchResult struct {
 	name string
 }
 
-func runBatch(names []string, action func(name string) error) []batchResult {
+type byBatchName []batchResult
+
+func (a byBatchName) Len() int {
+	return len(a)
+}
+
+func (a byBatchName) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+func (a byBatchName) Less(i, j int) bool {
+	return a[i].name < a[j].name
+}
+
+// runBatch runs action against all names, with at most parallel of them
+// running at once (no limit if 0). Names which haven't completed once timeout
+// expires (no timeout if 0) are reported as failed.
+func runBatch(names []string, parallel int, timeout time.Duration, action func(name string) error) []batchResult {
 	chResult := make(chan batchResult, len(names))
 
+	chNames := make(chan string, len(names))
 	for _, name := range names {
-		go func(name string) {
-			chResult <- batchResult{action(name), name}
-		}(name)
+		chNames <- name
+	}
+	close(chNames)
+
+	if parallel <= 0 || parallel > len(names) {
+		parallel = len(names)
+	}
+
+	for i := 0; i < parallel; i++ {
+		go func() {
+			for name := range chNames {
+				chResult <- batchResult{action(name), name}
+			}
+		}()
+	}
+
+	var chTimeout <-chan time.Time
+	if timeout > 0 {
+		chTimeout = time.After(timeout)
 	}
 
 	results := []batchResult{}
+	done := map[string]bool{}
 	for range names {
-		results = append(results, <-chResult)
+		select {
+		case result := <-chResult:
+			results = append(results, result)
+			done[result.name] = true
+		case <-chTimeout:
+			// Don't start anything new
+			for range chNames {
+			}
+
+			for _, name := range names {
+				if !done[name] {
+					results = append(results, batchResult{fmt.Errorf(i18n.G("Timed out")), name})
+				}
+			}
+
+			return results
+		}
 	}
 
 	return results
@@ -194,3 +256,105 @@ func summaryLine(usage string) string {
 
 	return i18n.G("Missing summary.")
 }
+
+// Backup encryption
+
+// backupPassphrase returns the passphrase used to encrypt or decrypt a backup,
+// read from the given file, the LXD_BACKUP_PASSPHRASE environment variable or
+// prompted for (twice if confirm is set).
+func backupPassphrase(file string, confirm bool) (string, error) {
+	if file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+
+	passphrase := os.Getenv("LXD_BACKUP_PASSPHRASE")
+	if passphrase != "" {
+		return passphrase, nil
+	}
+
+	// Backups may be streamed through stdin or stdout, so prompt on stderr
+	if !terminal.IsTerminal(0) {
+		return "", fmt.Errorf(i18n.G("A passphrase must be provided through --passphrase-file or LXD_BACKUP_PASSPHRASE"))
+	}
+
+	fmt.Fprintf(os.Stderr, i18n.G("Backup passphrase: "))
+	pwd, err := terminal.ReadPassword(0)
+	fmt.Fprintln(os.Stderr, "")
+	if err != nil {
+		return "", err
+	}
+
+	if confirm {
+		fmt.Fprintf(os.Stderr, i18n.G("Confirm backup passphrase: "))
+		again, err := terminal.ReadPassword(0)
+		fmt.Fprintln(os.Stderr, "")
+		if err != nil {
+			return "", err
+		}
+
+		if string(pwd) != string(again) {
+			return "", fmt.Errorf(i18n.G("Passphrases don't match"))
+		}
+	}
+
+	if len(pwd) == 0 {
+		return "", fmt.Errorf(i18n.G("An empty passphrase isn't allowed"))
+	}
+
+	return string(pwd), nil
+}
+
+// backupDownload runs download against target, encrypting its output with the
+// passphrase if one is given.
+func backupDownload(target io.Writer, passphrase string, download func(w io.Writer) error) error {
+	if passphrase == "" {
+		return download(target)
+	}
+
+	w, err := shared.NewEncryptWriter(target, passphrase)
+	if err != nil {
+		return err
+	}
+
+	err = download(w)
+	if err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// encodeJoinToken turns a certificate token into the single string given to
+// "lxc remote add".
+func encodeJoinToken(token *api.CertificateToken) (string, error) {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func decodeJoinToken(value string) (*api.CertificateToken, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	token := api.CertificateToken{}
+	err = json.Unmarshal(data, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.Secret == "" || token.Fingerprint == "" {
+		return nil, fmt.Errorf(i18n.G("Invalid join token"))
+	}
+
+	return &token, nil
+}
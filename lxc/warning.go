@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/lxc/lxd"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/gnuflag"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+type warningCmd struct {
+	all bool
+}
+
+func (c *warningCmd) showByDefault() bool {
+	return true
+}
+
+func (c *warningCmd) usage() string {
+	return i18n.G(
+		`Usage: lxc warning <subcommand> [options]
+
+Manage the warnings recorded by the LXD daemon.
+
+lxc warning list [<remote>:] [--all]
+    List the warnings, including acknowledged ones with --all.
+
+lxc warning ack [<remote>:]<id>
+    Acknowledge a warning, hiding it from the default list.
+
+lxc warning delete [<remote>:]<id>
+    Delete a warning. It will be recorded again if the problem is still detected.`)
+}
+
+func (c *warningCmd) flags() {
+	gnuflag.BoolVar(&c.all, "all", false, i18n.G("Show acknowledged warnings too"))
+}
+
+func (c *warningCmd) run(config *lxd.Config, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	if args[0] == "list" {
+		return c.doWarningList(config, args)
+	}
+
+	if len(args) != 2 {
+		return errArgs
+	}
+
+	remote, name := config.ParseRemoteAndContainer(args[1])
+	id, err := strconv.ParseInt(name, 10, 64)
+	if err != nil {
+		return fmt.Errorf(i18n.G("Invalid warning ID: %s"), name)
+	}
+
+	client, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "ack":
+		return client.WarningPut(id, api.WarningPut{Status: "acknowledged"})
+	case "delete":
+		return client.WarningDelete(id)
+	default:
+		return errArgs
+	}
+}
+
+func (c *warningCmd) doWarningList(config *lxd.Config, args []string) error {
+	var remote string
+	if len(args) > 1 {
+		var name string
+		remote, name = config.ParseRemoteAndContainer(args[1])
+		if name != "" {
+			return fmt.Errorf(i18n.G("Cannot provide container name to list"))
+		}
+	} else {
+		remote = config.DefaultRemote
+	}
+
+	client, err := lxd.NewClient(config, remote)
+	if err != nil {
+		return err
+	}
+
+	warnings, err := client.ListWarnings()
+	if err != nil {
+		return err
+	}
+
+	const layout = "2006/01/02 15:04 UTC"
+
+	data := [][]string{}
+	for _, warning := range warnings {
+		if warning.Status == "acknowledged" && !c.all {
+			continue
+		}
+
+		data = append(data, []string{
+			fmt.Sprintf("%d", warning.ID),
+			warning.Type,
+			warning.Entity,
+			warning.Message,
+			warning.Status,
+			fmt.Sprintf("%d", warning.Count),
+			warning.LastSeenAt.UTC().Format(layout)})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowLine(true)
+	table.SetHeader([]string{
+		i18n.G("ID"),
+		i18n.G("TYPE"),
+		i18n.G("ENTITY"),
+		i18n.G("MESSAGE"),
+		i18n.G("STATUS"),
+		i18n.G("COUNT"),
+		i18n.G("LAST SEEN")})
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
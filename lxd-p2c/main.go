@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/gnuflag"
+	"github.com/lxc/lxd/shared/osarch"
+)
+
+var argProfiles = gnuflag.String("profiles", "default", "")
+var argStorage = gnuflag.String("storage", "", "")
+var argNoProfiles = gnuflag.Bool("no-profiles", false, "")
+var argHelp = gnuflag.Bool("help", false, "")
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	gnuflag.Usage = func() {
+		fmt.Printf("Usage: lxd-p2c <target URL> <container name> <filesystem root> [<filesystem mounts>...] [options]\n")
+		fmt.Printf("\nTransfer a physical or virtual machine into a LXD container.\n")
+		fmt.Printf("\nThe filesystem root and any additional mounts are copied as-is, other\n")
+		fmt.Printf("filesystems such as /proc, /sys or /dev aren't transferred.\n")
+
+		fmt.Printf("\nOptions:\n")
+		fmt.Printf("    --profiles=default\n")
+		fmt.Printf("        Comma separated list of profiles to apply to the new container\n")
+		fmt.Printf("    --no-profiles\n")
+		fmt.Printf("        Create the container with no profiles applied\n")
+		fmt.Printf("    --storage=POOL\n")
+		fmt.Printf("        Storage pool to use for the container\n")
+		fmt.Printf("    --help\n")
+		fmt.Printf("        Print this help message\n")
+	}
+
+	// The rsync transfer re-executes us as a netcat relay
+	if len(os.Args) > 1 && os.Args[1] == "netcat" {
+		return cmdNetcat(os.Args[1:])
+	}
+
+	gnuflag.Parse(true)
+	if *argHelp {
+		gnuflag.Usage()
+		return nil
+	}
+
+	args := gnuflag.Args()
+	if len(args) < 3 {
+		gnuflag.Usage()
+		os.Exit(1)
+	}
+
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("This tool must be run as root")
+	}
+
+	target := args[0]
+	name := args[1]
+	rootfs := args[2]
+	mounts := args[3:]
+
+	if !shared.IsDir(rootfs) {
+		return fmt.Errorf("Filesystem root '%s' isn't a directory", rootfs)
+	}
+
+	for _, mount := range mounts {
+		if !shared.IsDir(mount) {
+			return fmt.Errorf("Filesystem mount '%s' isn't a directory", mount)
+		}
+	}
+
+	// Connect to the target
+	c, err := connectTarget(target)
+	if err != nil {
+		return err
+	}
+
+	// Assemble the filesystem to transfer
+	path, cleanup, err := setupSource(rootfs, mounts)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Prepare the container creation request
+	arch, err := osarch.ArchitectureGetLocal()
+	if err != nil {
+		return err
+	}
+
+	req := api.ContainersPost{
+		Name: name,
+		Source: api.ContainerSource{
+			Type: "migration",
+			Mode: "push",
+		},
+	}
+	req.Architecture = arch
+	req.Config = map[string]string{}
+	req.Devices = map[string]map[string]string{}
+
+	req.Profiles = []string{}
+	if !*argNoProfiles {
+		for _, profile := range strings.Split(*argProfiles, ",") {
+			profile = strings.TrimSpace(profile)
+			if profile != "" {
+				req.Profiles = append(req.Profiles, profile)
+			}
+		}
+	}
+
+	if *argStorage != "" {
+		req.Devices["root"] = map[string]string{
+			"type": "disk",
+			"path": "/",
+			"pool": *argStorage,
+		}
+	}
+
+	op, err := c.CreateContainer(req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Transferring %s to %s\n", rootfs, name)
+	err = transferRootfs(op, path)
+	if err != nil {
+		return err
+	}
+
+	err = op.Wait()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Container %s successfully created\n", name)
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/websocket"
+	"github.com/pborman/uuid"
+
+	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/migration"
+	"github.com/lxc/lxd/shared"
+)
+
+// transferRootfs acts as the source of a push migration, sending the
+// content of path over the operation's websockets.
+func transferRootfs(op *lxd.Operation, path string) error {
+	secrets := map[string]string{}
+	for key, value := range op.Metadata {
+		secret, ok := value.(string)
+		if ok {
+			secrets[key] = secret
+		}
+	}
+
+	if secrets["control"] == "" || secrets["fs"] == "" {
+		return fmt.Errorf("The target didn't provide the migration websockets")
+	}
+
+	wsControl, err := op.GetWebsocket(secrets["control"])
+	if err != nil {
+		return err
+	}
+	defer wsControl.Close()
+
+	wsFs, err := op.GetWebsocket(secrets["fs"])
+	if err != nil {
+		return err
+	}
+	defer wsFs.Close()
+
+	// Send our header, we only ever offer a plain rsync transfer
+	fsType := migration.MigrationFSType_RSYNC
+	header := migration.MigrationHeader{
+		Fs: &fsType,
+	}
+
+	err = protoSend(wsControl, &header)
+	if err != nil {
+		protoSendError(wsControl, err)
+		return err
+	}
+
+	err = protoRecv(wsControl, &header)
+	if err != nil {
+		protoSendError(wsControl, err)
+		return err
+	}
+
+	if header.GetFs() != migration.MigrationFSType_RSYNC {
+		err := fmt.Errorf("Unsupported filesystem transfer type: %s", header.GetFs())
+		protoSendError(wsControl, err)
+		return err
+	}
+
+	err = rsyncSend(wsFs, shared.AddSlash(path))
+	if err != nil {
+		protoSendError(wsControl, err)
+		return fmt.Errorf("Failed to transfer the filesystem: %v", err)
+	}
+
+	// The target tells us whether it could use what we sent
+	msg := migration.MigrationControl{}
+	err = protoRecv(wsControl, &msg)
+	if err != nil {
+		return err
+	}
+
+	if !msg.GetSuccess() {
+		return fmt.Errorf("%s", msg.GetMessage())
+	}
+
+	return nil
+}
+
+func protoSend(ws *websocket.Conn, msg proto.Message) error {
+	w, err := ws.NextWriter(websocket.BinaryMessage)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return shared.WriteAll(w, data)
+}
+
+func protoRecv(ws *websocket.Conn, msg proto.Message) error {
+	mt, r, err := ws.NextReader()
+	if err != nil {
+		return err
+	}
+
+	if mt != websocket.BinaryMessage {
+		return fmt.Errorf("Only binary messages allowed")
+	}
+
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(buf, msg)
+}
+
+func protoSendError(ws *websocket.Conn, err error) {
+	msg := migration.MigrationControl{
+		Success: proto.Bool(false),
+		Message: proto.String(err.Error()),
+	}
+	protoSend(ws, &msg)
+}
+
+// rsyncSend runs rsync against the target's receiving half, relaying it
+// over the websocket through a netcat instance of ourselves. The arguments
+// must match the ones of the rsync server started by LXD.
+func rsyncSend(conn *websocket.Conn, path string) error {
+	execPath, err := os.Readlink("/proc/self/exe")
+	if err != nil {
+		return err
+	}
+
+	auds := fmt.Sprintf("@lxd-p2c/%s", uuid.NewRandom().String())
+	if len(auds) > shared.ABSTRACT_UNIX_SOCK_LEN-1 {
+		auds = auds[:shared.ABSTRACT_UNIX_SOCK_LEN-1]
+	}
+
+	l, err := net.Listen("unix", auds)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	rsyncCmd := fmt.Sprintf("sh -c \"%s netcat %s\"", execPath, auds)
+	cmd := exec.Command("rsync",
+		"-arvP",
+		"--devices",
+		"--numeric-ids",
+		"--partial",
+		"--sparse",
+		path,
+		"localhost:/tmp/foo",
+		"-e",
+		rsyncCmd)
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	dataSocket, err := l.Accept()
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	defer dataSocket.Close()
+
+	readDone, writeDone := shared.WebsocketMirror(conn, dataSocket, dataSocket, nil, nil)
+
+	output, err := ioutil.ReadAll(stderr)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	err = cmd.Wait()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, string(output))
+	}
+
+	<-readDone
+	<-writeDone
+
+	return nil
+}
+
+// cmdNetcat relays stdin/stdout to the unix socket given as its first
+// argument. Any further arguments are the ones rsync adds for the remote
+// shell and are ignored.
+func cmdNetcat(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("Bad arguments %q", args)
+	}
+
+	conn, err := net.Dial("unix", args[1])
+	if err != nil {
+		return err
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	go func() {
+		io.Copy(os.Stdout, conn)
+		conn.Close()
+		wg.Done()
+	}()
+
+	go func() {
+		io.Copy(conn, os.Stdin)
+	}()
+
+	wg.Wait()
+
+	return nil
+}
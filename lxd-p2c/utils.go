@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// connectTarget connects to the target LXD, checking its certificate with
+// the user and adding a freshly generated client certificate to its trust
+// store if needed.
+func connectTarget(url string) (lxd.ContainerServer, error) {
+	if !strings.HasPrefix(url, "https://") {
+		url = fmt.Sprintf("https://%s", url)
+	}
+
+	clientCrt, clientKey, err := shared.GenerateMemCert(true)
+	if err != nil {
+		return nil, err
+	}
+
+	args := lxd.ConnectionArgs{
+		TLSClientCert: string(clientCrt),
+		TLSClientKey:  string(clientKey),
+		UserAgent:     "lxd-p2c",
+	}
+
+	// Attempt to connect using the system root CA
+	c, err := lxd.ConnectLXD(url, &args)
+	if err != nil {
+		// Failed to connect using the system CA, so retrieve the remote certificate
+		certificate, err := getRemoteCertificate(url)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Printf("Certificate fingerprint: %s\n", shared.CertFingerprint(certificate))
+		fmt.Printf("ok (y/n)? ")
+		line, err := shared.ReadStdin()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) < 1 || line[0] != 'y' && line[0] != 'Y' {
+			return nil, fmt.Errorf("Server certificate NACKed by user")
+		}
+
+		args.TLSServerCert = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}))
+		c, err = lxd.ConnectLXD(url, &args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	srv, _, err := c.GetServer()
+	if err != nil {
+		return nil, err
+	}
+
+	if srv.Auth == "trusted" {
+		return c, nil
+	}
+
+	fmt.Printf("Admin password for %s: ", url)
+	pwd, err := terminal.ReadPassword(0)
+	if err != nil {
+		/* We got an error, maybe this isn't a terminal, let's try to
+		 * read it as a file */
+		pwd, err = shared.ReadStdin()
+		if err != nil {
+			return nil, err
+		}
+	}
+	fmt.Println("")
+
+	certBlock, _ := pem.Decode(clientCrt)
+	if certBlock == nil {
+		return nil, fmt.Errorf("Invalid client certificate")
+	}
+
+	req := api.CertificatesPost{
+		Certificate: base64.StdEncoding.EncodeToString(certBlock.Bytes),
+		Password:    string(pwd),
+	}
+	req.Name = "lxd-p2c"
+	req.Type = "client"
+
+	err = c.CreateCertificate(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reconnect so the server information reflects our new trust status
+	return lxd.ConnectLXD(url, &args)
+}
+
+func getRemoteCertificate(url string) (*x509.Certificate, error) {
+	client := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("Unable to read remote TLS certificate")
+	}
+
+	return resp.TLS.PeerCertificates[0], nil
+}
+
+// setupSource bind-mounts the filesystem root and the additional mounts
+// under a temporary directory. Bind mounts aren't recursive so anything
+// else mounted on the source system (/proc, /sys, /dev, ...) is left out.
+func setupSource(rootfs string, mounts []string) (string, func(), error) {
+	path, err := ioutil.TempDir("", "lxd-p2c_")
+	if err != nil {
+		return "", nil, err
+	}
+
+	mounted := []string{}
+	cleanup := func() {
+		for i := len(mounted) - 1; i >= 0; i-- {
+			syscall.Unmount(mounted[i], syscall.MNT_DETACH)
+		}
+		os.Remove(path)
+	}
+
+	err = syscall.Mount(rootfs, path, "none", syscall.MS_BIND, "")
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("Failed to bind-mount %s: %v", rootfs, err)
+	}
+	mounted = append(mounted, path)
+
+	for _, mount := range mounts {
+		target := filepath.Join(path, mount)
+		if !shared.IsDir(target) {
+			cleanup()
+			return "", nil, fmt.Errorf("Mount point %s doesn't exist in %s", mount, rootfs)
+		}
+
+		err = syscall.Mount(mount, target, "none", syscall.MS_BIND, "")
+		if err != nil {
+			cleanup()
+			return "", nil, fmt.Errorf("Failed to bind-mount %s: %v", mount, err)
+		}
+		mounted = append(mounted, target)
+	}
+
+	return path, cleanup, nil
+}
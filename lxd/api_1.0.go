@@ -23,8 +23,17 @@ var api10 = []Command{
 	containerFileCmd,
 	containerLogsCmd,
 	containerLogCmd,
+	containerConsoleCmd,
+	containerUsageCmd,
+	containerResetIdentityCmd,
+	containerExportCmd,
+	containerExportDiskCmd,
+	containerBackupsCmd,
+	containerBackupCmd,
+	containerBackupExportCmd,
 	containerSnapshotsCmd,
 	containerSnapshotCmd,
+	containerSnapshotFileCmd,
 	containerExecCmd,
 	aliasCmd,
 	aliasesCmd,
@@ -40,16 +49,27 @@ var api10 = []Command{
 	operationWebsocket,
 	networksCmd,
 	networkCmd,
+	networkForwardsCmd,
+	networkForwardCmd,
+	networkZonesCmd,
+	networkZoneCmd,
 	api10Cmd,
 	certificatesCmd,
+	certificateTokensCmd,
 	certificateFingerprintCmd,
 	profilesCmd,
 	profileCmd,
+	projectsCmd,
+	projectCmd,
+	schemasCmd,
+	schemaCmd,
 	storagePoolsCmd,
 	storagePoolCmd,
 	storagePoolVolumesCmd,
 	storagePoolVolumesTypeCmd,
 	storagePoolVolumeTypeCmd,
+	warningsCmd,
+	warningCmd,
 }
 
 func api10Get(d *Daemon, r *http.Request) Response {
@@ -84,6 +104,36 @@ func api10Get(d *Daemon, r *http.Request) Response {
 			"container_exec_recording",
 			"certificate_update",
 			"container_exec_signal_handling",
+			"server_environment_features",
+			"container_snapshot_description",
+			"container_state_counters",
+			"error_names",
+			"container_filter_config",
+			"api_schemas",
+			"container_full",
+			"container_bulk_state",
+			"conditional_get",
+			"snapshot_size",
+			"collection_pagination",
+			"compression_zstd",
+			"migration_rsync_options",
+			"autostart_concurrency",
+			"snapshot_list_without_size",
+			"image_oci_import",
+			"container_cloud_init",
+			"webhooks",
+			"remote_syslog",
+			"container_export_disk",
+			"network_forwards",
+			"container_reset_identity",
+			"images_cache_limits",
+			"container_stateful_shutdown",
+			"network_zones",
+			"server_certificate_acme",
+			"container_boot_dependencies",
+			"container_health_probes",
+			"container_autorestart",
+			"container_oom_policy",
 			"gpu_devices",
 			"container_image_properties",
 			"migration_progress",
@@ -109,6 +159,33 @@ func api10Get(d *Daemon, r *http.Request) Response {
 			"entity_description",
 			"image_force_refresh",
 			"storage_lvm_lv_resizing",
+			"daemon_logging",
+			"console_log",
+			"events_history",
+			"container_usage_history",
+			"warnings",
+			"container_backup",
+			"container_backup_optimized",
+			"container_backup_schedule",
+			"container_backup_s3",
+			"container_backup_encryption",
+			"container_backup_manifest",
+			"snapshot_files",
+			"container_limits_cpu_nodes",
+			"container_hugepages",
+			"container_restart_required",
+			"limits_overcommit",
+			"container_kernel_limits",
+			"container_cpu_percentage",
+			"certificate_token",
+			"projects",
+			"projects_limits",
+			"container_nesting_setup",
+			"container_shiftfs",
+			"container_freeze_schedule",
+			"container_shutdown_ordering",
+			"network_dns_host_resolver",
+			"container_exec_signal_handling",
 		},
 		APIStatus:  "stable",
 		APIVersion: version.APIVersion,
@@ -200,10 +277,17 @@ func api10Get(d *Daemon, r *http.Request) Response {
 
 	drivers := readStoragePoolDriversCache()
 	for _, driver := range drivers {
-		// Initialize a core storage interface for the given driver.
-		sCore, err := storageCoreInit(driver)
-		if err != nil {
-			continue
+		// Drivers are probed at startup, unless their tools got
+		// installed since.
+		sVersion, ok := storageDriversAvailable[driver]
+		if !ok {
+			// Initialize a core storage interface for the given driver.
+			sCore, err := storageCoreInit(driver)
+			if err != nil {
+				continue
+			}
+
+			sVersion = sCore.GetStorageTypeVersion()
 		}
 
 		if env.Storage != "" {
@@ -213,7 +297,6 @@ func api10Get(d *Daemon, r *http.Request) Response {
 		}
 
 		// Get the version of the storage drivers in use.
-		sVersion := sCore.GetStorageTypeVersion()
 		if env.StorageVersion != "" {
 			env.StorageVersion = env.StorageVersion + " | " + sVersion
 		} else {
@@ -221,6 +304,24 @@ func api10Get(d *Daemon, r *http.Request) Response {
 		}
 	}
 
+	// Storage drivers whose tools are available, whether in use or not
+	env.StorageAvailable = []string{}
+	for _, driver := range supportedStoragePoolDrivers {
+		_, ok := storageDriversAvailable[driver]
+		if ok {
+			env.StorageAvailable = append(env.StorageAvailable, driver)
+		}
+	}
+
+	env.KernelFeatures = map[string]string{
+		"apparmor":          fmt.Sprintf("%v", aaAvailable),
+		"cgroup_unified":    fmt.Sprintf("%v", cgUnified),
+		"criu":              fmt.Sprintf("%v", criuAvailable),
+		"running_in_userns": fmt.Sprintf("%v", runningInUserns),
+		"shiftfs":           fmt.Sprintf("%v", shiftfsAvailable),
+		"userns":            fmt.Sprintf("%v", shared.PathExists("/proc/self/ns/user")),
+	}
+
 	fullSrv := api.Server{ServerUntrusted: srv}
 	fullSrv.Environment = env
 	fullSrv.Config = daemonConfigRender()
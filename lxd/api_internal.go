@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -27,6 +28,8 @@ var apiInternal = []Command{
 	internalContainerOnStartCmd,
 	internalContainerOnStopCmd,
 	internalContainersCmd,
+	internalRecoverCmd,
+	internalDebugCmd,
 }
 
 func internalReady(d *Daemon, r *http.Request) Response {
@@ -138,6 +141,12 @@ func internalImport(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("The name of the container is required."))
 	}
 
+	return internalImportContainer(d, req.Name, req.Force)
+}
+
+// internalImportContainer re-creates the database entries of a container
+// found on disk from its backup.yaml file.
+func internalImportContainer(d *Daemon, name string, force bool) Response {
 	storagePoolsPath := shared.VarPath("storage-pools")
 	storagePoolsDir, err := os.Open(storagePoolsPath)
 	if err != nil {
@@ -156,7 +165,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 	containerMntPoints := []string{}
 	containerPoolName := ""
 	for _, poolName := range storagePoolNames {
-		containerMntPoint := getContainerMountPoint(poolName, req.Name)
+		containerMntPoint := getContainerMountPoint(poolName, name)
 		if shared.PathExists(containerMntPoint) {
 			containerMntPoints = append(containerMntPoints, containerMntPoint)
 			containerPoolName = poolName
@@ -165,9 +174,9 @@ func internalImport(d *Daemon, r *http.Request) Response {
 
 	// Sanity checks.
 	if len(containerMntPoints) > 1 {
-		return BadRequest(fmt.Errorf("The container \"%s\" seems to exist on another storage pool.", req.Name))
+		return BadRequest(fmt.Errorf("The container \"%s\" seems to exist on another storage pool.", name))
 	} else if len(containerMntPoints) != 1 {
-		return BadRequest(fmt.Errorf("The container \"%s\" does not seem to exist on any storage pool.", req.Name))
+		return BadRequest(fmt.Errorf("The container \"%s\" does not seem to exist on any storage pool.", name))
 	}
 
 	// User needs to make sure that we can access the directory where
@@ -183,7 +192,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 	}
 
 	// Read in the backup.yaml file.
-	backup, err := slurpBackupFile(shared.VarPath("containers", req.Name, "backup.yaml"))
+	backup, err := slurpBackupFile(filepath.Join(containerMntPoint, "backup.yaml"))
 	if err != nil {
 		return SmartError(err)
 	}
@@ -224,33 +233,33 @@ func internalImport(d *Daemon, r *http.Request) Response {
 	}
 
 	// Check if a storage volume entry for the container already exists.
-	_, volume, ctVolErr := dbStoragePoolVolumeGetType(d.db, req.Name, storagePoolVolumeTypeContainer, poolID)
+	_, volume, ctVolErr := dbStoragePoolVolumeGetType(d.db, name, storagePoolVolumeTypeContainer, poolID)
 	if ctVolErr != nil {
 		if ctVolErr != NoSuchObjectError {
 			return SmartError(ctVolErr)
 		}
 	}
 	// If a storage volume entry exists only proceed if force was specified.
-	if ctVolErr == nil && !req.Force {
-		return BadRequest(fmt.Errorf("Storage volume for container \"%s\" already exists in the database. Set \"force\" to overwrite.", req.Name))
+	if ctVolErr == nil && !force {
+		return BadRequest(fmt.Errorf("Storage volume for container \"%s\" already exists in the database. Set \"force\" to overwrite.", name))
 	}
 
 	// Check if an entry for the container already exists in the db.
-	_, containerErr := dbContainerId(d.db, req.Name)
+	_, containerErr := dbContainerId(d.db, name)
 	if containerErr != nil {
 		if containerErr != sql.ErrNoRows {
 			return SmartError(containerErr)
 		}
 	}
 	// If a db entry exists only proceed if force was specified.
-	if containerErr == nil && !req.Force {
-		return BadRequest(fmt.Errorf("Entry for container \"%s\" already exists in the database. Set \"force\" to overwrite.", req.Name))
+	if containerErr == nil && !force {
+		return BadRequest(fmt.Errorf("Entry for container \"%s\" already exists in the database. Set \"force\" to overwrite.", name))
 	}
 
 	// Detect discrepancy between snapshots recorded in "backup.yaml" and
 	// those actually existing on disk.
 	snapshotNames := []string{}
-	snapshotsPath := getSnapshotMountPoint(containerPoolName, req.Name)
+	snapshotsPath := getSnapshotMountPoint(containerPoolName, name)
 	snapshotsDir, err := os.Open(snapshotsPath)
 	if err != nil {
 		if !os.IsNotExist(err) {
@@ -268,7 +277,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 
 	onDiskSnapshots := map[string]*api.ContainerSnapshot{}
 	for _, snapName := range snapshotNames {
-		fullSnapName := fmt.Sprintf("%s/%s", req.Name, snapName)
+		fullSnapName := fmt.Sprintf("%s/%s", name, snapName)
 		snapshotMntPoint := getSnapshotMountPoint(containerPoolName, fullSnapName)
 		if shared.PathExists(snapshotMntPoint) {
 			onDiskSnapshots[fullSnapName] = nil
@@ -281,7 +290,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 		// Kick out any snapshots that do not exist on-disk anymore.
 		_, ok := onDiskSnapshots[snap.Name]
 		if !ok {
-			logger.Warnf("The snapshot \"%s\" for container \"%s\" does not exist on disk anymore. Skipping...", snap.Name, req.Name)
+			logger.Warnf("The snapshot \"%s\" for container \"%s\" does not exist on disk anymore. Skipping...", snap.Name, name)
 			continue
 		}
 
@@ -296,7 +305,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 		}
 
 		// If a db entry exists only proceed if force was specified.
-		if snapErr == nil && !req.Force {
+		if snapErr == nil && !force {
 			return BadRequest(fmt.Errorf("Entry for snapshot \"%s\" already exists in the database. Set \"force\" to overwrite.", snap.Name))
 		}
 
@@ -309,7 +318,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 		}
 
 		// If a storage volume entry exists only proceed if force was specified.
-		if snapVolErr == nil && !req.Force {
+		if snapVolErr == nil && !force {
 			return BadRequest(fmt.Errorf("Storage volume for snapshot \"%s\" already exists in the database. Set \"force\" to overwrite.", snap.Name))
 		}
 	}
@@ -320,7 +329,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 
 	if ctVolErr == nil {
 		if volume.Name != backup.Volume.Name {
-			return BadRequest(fmt.Errorf("The name \"%s\" of the storage volume is not identical to the container's name \"%s\".", volume.Name, req.Name))
+			return BadRequest(fmt.Errorf("The name \"%s\" of the storage volume is not identical to the container's name \"%s\".", volume.Name, name))
 		}
 
 		if volume.Type != backup.Volume.Type {
@@ -329,7 +338,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 
 		// Remove the storage volume db entry for the container since
 		// force was specified.
-		err := dbStoragePoolVolumeDelete(d.db, req.Name, storagePoolVolumeTypeContainer, poolID)
+		err := dbStoragePoolVolumeDelete(d.db, name, storagePoolVolumeTypeContainer, poolID)
 		if err != nil {
 			return SmartError(err)
 		}
@@ -338,7 +347,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 	if containerErr == nil {
 		// Remove the storage volume db entry for the container since
 		// force was specified.
-		err := dbContainerRemove(d.db, req.Name)
+		err := dbContainerRemove(d.db, name)
 		if err != nil {
 			return SmartError(err)
 		}
@@ -372,6 +381,8 @@ func internalImport(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	containerLifecycleEvent("container-created", backup.Container.Name)
+
 	for snapName, snap := range onDiskSnapshots {
 		// Check if an entry for the snapshot already exists in the db.
 		_, snapErr := dbContainerId(d.db, snapName)
@@ -382,7 +393,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 		}
 
 		// If a db entry exists only proceed if force was specified.
-		if snapErr == nil && !req.Force {
+		if snapErr == nil && !force {
 			return BadRequest(fmt.Errorf("Entry for snapshot \"%s\" already exists in the database. Set \"force\" to overwrite.", snapName))
 		}
 
@@ -395,7 +406,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 		}
 
 		// If a storage volume entry exists only proceed if force was specified.
-		if csVolErr == nil && !req.Force {
+		if csVolErr == nil && !force {
 			return BadRequest(fmt.Errorf("Storage volume for snapshot \"%s\" already exists in the database. Set \"force\" to overwrite.", snapName))
 		}
 
@@ -417,7 +428,7 @@ func internalImport(d *Daemon, r *http.Request) Response {
 		// "backup.yaml" file. Recreate it by copying the parent
 		// container's settings.
 		if snap == nil {
-			logger.Warnf("The snapshot \"%s\" for the container \"%s\" exists on disk but not in the backup file. Restoring with parent container's settings.", snapName, req.Name)
+			logger.Warnf("The snapshot \"%s\" for the container \"%s\" exists on disk but not in the backup file. Restoring with parent container's settings.", snapName, name)
 			snap = &api.ContainerSnapshot{}
 			snap.Config = backup.Container.Config
 			snap.CreationDate = backup.Container.CreatedAt
@@ -463,3 +474,127 @@ func internalImport(d *Daemon, r *http.Request) Response {
 }
 
 var internalContainersCmd = Command{name: "containers", post: internalImport}
+
+type internalRecoverContainer struct {
+	Name   string `json:"name" yaml:"name"`
+	Pool   string `json:"pool" yaml:"pool"`
+	Status string `json:"status" yaml:"status"`
+	Error  string `json:"error" yaml:"error"`
+}
+
+type internalRecoverRequest struct {
+	Force bool `json:"force" yaml:"force"`
+}
+
+// internalRecoverScan looks through the storage pools for containers which
+// have a volume on disk but no database entry.
+func internalRecoverScan(d *Daemon) ([]internalRecoverContainer, error) {
+	result := []internalRecoverContainer{}
+
+	pools, err := ioutil.ReadDir(shared.VarPath("storage-pools"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+
+		return nil, err
+	}
+
+	for _, pool := range pools {
+		if !pool.IsDir() {
+			continue
+		}
+
+		ents, err := ioutil.ReadDir(shared.VarPath("storage-pools", pool.Name(), "containers"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		for _, ent := range ents {
+			if !ent.IsDir() {
+				continue
+			}
+
+			_, err := dbContainerId(d.db, ent.Name())
+			if err == nil {
+				continue
+			} else if err != sql.ErrNoRows {
+				return nil, err
+			}
+
+			status := "recoverable"
+			mntPoint := getContainerMountPoint(pool.Name(), ent.Name())
+			isEmpty, err := shared.PathIsEmpty(mntPoint)
+			if err != nil {
+				return nil, err
+			}
+
+			if isEmpty {
+				status = "unmounted"
+			} else if !shared.PathExists(filepath.Join(mntPoint, "backup.yaml")) {
+				status = "missing_backup_file"
+			}
+
+			result = append(result, internalRecoverContainer{Name: ent.Name(), Pool: pool.Name(), Status: status})
+		}
+	}
+
+	return result, nil
+}
+
+func internalRecoverGet(d *Daemon, r *http.Request) Response {
+	result, err := internalRecoverScan(d)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, result)
+}
+
+func internalRecoverPost(d *Daemon, r *http.Request) Response {
+	req := internalRecoverRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	result, err := internalRecoverScan(d)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for i, ct := range result {
+		if ct.Status != "recoverable" {
+			continue
+		}
+
+		// The container symlink may have been lost along with the database
+		ctPath := shared.VarPath("containers", ct.Name)
+		if !shared.PathExists(ctPath) {
+			err := os.Symlink(getContainerMountPoint(ct.Pool, ct.Name), ctPath)
+			if err != nil {
+				result[i].Status = "failed"
+				result[i].Error = err.Error()
+				continue
+			}
+		}
+
+		resp := internalImportContainer(d, ct.Name, req.Force)
+		if resp != EmptySyncResponse {
+			result[i].Status = "failed"
+			result[i].Error = resp.String()
+			continue
+		}
+
+		logger.Info("Recovered container", log.Ctx{"container": ct.Name, "pool": ct.Pool})
+		result[i].Status = "recovered"
+	}
+
+	return SyncResponse(true, result)
+}
+
+var internalRecoverCmd = Command{name: "recover", get: internalRecoverGet, post: internalRecoverPost}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+/* Backups are uploaded to S3 compatible object storage using multipart
+ * uploads, so only a single part is ever held in memory and no local copy of
+ * the tarball is needed. Requests are signed with AWS signature version 4
+ * and use path-style URLs (<endpoint>/<bucket>/<key>) which are supported by
+ * most S3 implementations.
+ */
+
+// Size of the parts of a multipart upload, S3 requires at least 5MiB
+const backupS3PartSize = 16 * 1024 * 1024
+
+type backupTargetS3 struct {
+	client    *http.Client
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+}
+
+func backupTargetS3Load(d *Daemon) (backupTarget, error) {
+	endpoint := daemonConfig["backups.s3.endpoint"].Get()
+	bucket := daemonConfig["backups.s3.bucket"].Get()
+	if endpoint == "" || bucket == "" {
+		return nil, fmt.Errorf("The s3 backup target requires backups.s3.endpoint and backups.s3.bucket")
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := d.httpClient("")
+	if err != nil {
+		return nil, err
+	}
+
+	return &backupTargetS3{
+		client:    client,
+		endpoint:  u,
+		bucket:    bucket,
+		region:    daemonConfig["backups.s3.region"].Get(),
+		accessKey: daemonConfig["backups.s3.access_key"].Get(),
+		secretKey: daemonConfig["backups.s3.secret_key"].Get(),
+	}, nil
+}
+
+func daemonConfigValidateS3Endpoint(d *Daemon, key string, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("Invalid S3 endpoint: %s", value)
+	}
+
+	return nil
+}
+
+// s3Escape encodes a string as required for S3 signatures (RFC 3986)
+func s3Escape(value string, encodeSlash bool) string {
+	var buf bytes.Buffer
+	for _, c := range []byte(value) {
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~", c) >= 0 || c == '/' && !encodeSlash {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+
+	return buf.String()
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func s3ResponseError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	s3err := s3Error{}
+	err := xml.Unmarshal(body, &s3err)
+	if err != nil || s3err.Code == "" {
+		return fmt.Errorf("S3 request failed: %s", resp.Status)
+	}
+
+	return fmt.Errorf("S3 request failed: %s (%s)", s3err.Message, s3err.Code)
+}
+
+// do sends a signed request for the given object key (or the bucket itself
+// if key is empty) and fails on any non-2xx response.
+func (t *backupTargetS3) do(method string, key string, query url.Values, body []byte) (*http.Response, error) {
+	path := fmt.Sprintf("%s/%s", strings.TrimSuffix(t.endpoint.EscapedPath(), "/"), s3Escape(t.bucket, true))
+	if key != "" {
+		path = fmt.Sprintf("%s/%s", path, s3Escape(key, false))
+	}
+
+	keys := []string{}
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	params := []string{}
+	for _, k := range keys {
+		params = append(params, fmt.Sprintf("%s=%s", s3Escape(k, true), s3Escape(query.Get(k), true)))
+	}
+	rawQuery := strings.Join(params, "&")
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("%s://%s%s", t.endpoint.Scheme, t.endpoint.Host, path), reader)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = rawQuery
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", now.Format("20060102"), t.region)
+
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", hex.EncodeToString(payloadHash[:]))
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		rawQuery,
+		fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", t.endpoint.Host, hex.EncodeToString(payloadHash[:]), amzDate),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	signingKey := s3HMAC([]byte("AWS4"+t.secretKey), now.Format("20060102"))
+	signingKey = s3HMAC(signingKey, t.region)
+	signingKey = s3HMAC(signingKey, "s3")
+	signingKey = s3HMAC(signingKey, "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		t.accessKey, scope, signedHeaders, hex.EncodeToString(s3HMAC(signingKey, stringToSign))))
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, s3ResponseError(resp)
+	}
+
+	return resp, nil
+}
+
+func (t *backupTargetS3) key(container string, name string) string {
+	return fmt.Sprintf("%s/%s.tar.gz", container, name)
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (t *backupTargetS3) List(container string) ([]api.ContainerBackup, error) {
+	backups := []api.ContainerBackup{}
+	prefix := fmt.Sprintf("%s/", container)
+
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", prefix)
+
+	for {
+		resp, err := t.do("GET", "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		result := s3ListResult{}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range result.Contents {
+			name := strings.TrimPrefix(obj.Key, prefix)
+			if strings.Contains(name, "/") || !strings.HasSuffix(name, ".tar.gz") {
+				continue
+			}
+
+			backups = append(backups, api.ContainerBackup{
+				Name:         strings.TrimSuffix(name, ".tar.gz"),
+				CreationDate: obj.LastModified,
+				Size:         obj.Size,
+			})
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+
+	return backups, nil
+}
+
+type s3CompletePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteUpload struct {
+	XMLName xml.Name         `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletePart `xml:"Part"`
+}
+
+func (t *backupTargetS3) Store(container string, name string, write func(w io.Writer) error) error {
+	key := t.key(container, name)
+
+	resp, err := t.do("POST", key, url.Values{"uploads": []string{""}}, nil)
+	if err != nil {
+		return err
+	}
+
+	initiate := struct {
+		UploadID string `xml:"UploadId"`
+	}{}
+	err = xml.NewDecoder(resp.Body).Decode(&initiate)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	abort := func(err error) error {
+		resp, abortErr := t.do("DELETE", key, url.Values{"uploadId": []string{initiate.UploadID}}, nil)
+		if abortErr == nil {
+			resp.Body.Close()
+		}
+
+		return err
+	}
+
+	// Produce the backup in the background and upload it part by part
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(write(writer))
+	}()
+
+	complete := s3CompleteUpload{}
+	buf := make([]byte, backupS3PartSize)
+	for {
+		n, err := io.ReadFull(reader, buf)
+		if err == io.EOF && len(complete.Parts) > 0 {
+			break
+		} else if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			reader.CloseWithError(err)
+			return abort(err)
+		}
+
+		partNumber := len(complete.Parts) + 1
+		query := url.Values{}
+		query.Set("partNumber", strconv.Itoa(partNumber))
+		query.Set("uploadId", initiate.UploadID)
+
+		resp, err := t.do("PUT", key, query, buf[:n])
+		if err != nil {
+			reader.CloseWithError(err)
+			return abort(err)
+		}
+		resp.Body.Close()
+
+		complete.Parts = append(complete.Parts, s3CompletePart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+
+		if n < len(buf) {
+			break
+		}
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return abort(err)
+	}
+
+	resp, err = t.do("POST", key, url.Values{"uploadId": []string{initiate.UploadID}}, body)
+	if err != nil {
+		return abort(err)
+	}
+	defer resp.Body.Close()
+
+	// Completion failures may be reported with a 200 status
+	s3err := s3Error{}
+	content, _ := ioutil.ReadAll(resp.Body)
+	if xml.Unmarshal(content, &s3err) == nil && s3err.Code != "" {
+		return abort(fmt.Errorf("S3 request failed: %s (%s)", s3err.Message, s3err.Code))
+	}
+
+	return nil
+}
+
+func (t *backupTargetS3) Open(container string, name string) (io.ReadCloser, error) {
+	resp, err := t.do("GET", t.key(container, name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+func (t *backupTargetS3) Delete(container string, name string) error {
+	resp, err := t.do("DELETE", t.key(container, name), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
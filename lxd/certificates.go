@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -94,9 +96,19 @@ func certificatesPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
-	// Access check
+	// Access check, a join token being an alternative to the trust
+	// password. It's only consumed once the certificate got saved, the
+	// lock being held until then.
+	useToken := false
 	if !d.isTrustedClient(r) && d.PasswordCheck(req.Password) != nil {
-		return Forbidden
+		certificateTokensLock.Lock()
+		defer certificateTokensLock.Unlock()
+
+		if !certificateTokenValid(req.Password) {
+			return Forbidden
+		}
+
+		useToken = true
 	}
 
 	if req.Type != "client" {
@@ -145,6 +157,10 @@ func certificatesPost(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	if useToken {
+		delete(certificateTokens, req.Password)
+	}
+
 	d.clientCerts = append(d.clientCerts, *cert)
 
 	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/certificates/%s", version.APIVersion, fingerprint))
@@ -152,6 +168,69 @@ func certificatesPost(d *Daemon, r *http.Request) Response {
 
 var certificatesCmd = Command{name: "certificates", untrustedPost: true, get: certificatesGet, post: certificatesPost}
 
+// Join tokens only live in memory, they're lost when the daemon restarts
+const certificateTokenExpiry = 24 * time.Hour
+
+var certificateTokensLock sync.Mutex
+var certificateTokens = map[string]time.Time{}
+
+// certificateTokenValid returns whether a join token exists and hasn't
+// expired, certificateTokensLock being held
+func certificateTokenValid(secret string) bool {
+	if secret == "" {
+		return false
+	}
+
+	expiry, ok := certificateTokens[secret]
+	if !ok {
+		return false
+	}
+
+	return time.Now().Before(expiry)
+}
+
+func certificateTokensPost(d *Daemon, r *http.Request) Response {
+	addresses, err := d.ListenAddresses()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	if len(addresses) == 0 || len(d.tlsConfig.Certificates) == 0 {
+		return BadRequest(fmt.Errorf("The server isn't available over the network, set core.https_address first"))
+	}
+
+	cert, err := x509.ParseCertificate(d.tlsConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		return InternalError(err)
+	}
+
+	secret, err := shared.RandomCryptoString()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	token := api.CertificateToken{
+		Addresses:   addresses,
+		Fingerprint: shared.CertFingerprint(cert),
+		Secret:      secret,
+		ExpiresAt:   time.Now().Add(certificateTokenExpiry).UTC(),
+	}
+
+	certificateTokensLock.Lock()
+	now := time.Now()
+	for entry, expiry := range certificateTokens {
+		if now.After(expiry) {
+			delete(certificateTokens, entry)
+		}
+	}
+	certificateTokens[secret] = token.ExpiresAt
+	certificateTokensLock.Unlock()
+
+	return SyncResponse(true, &token)
+}
+
+var certificateTokensCmd = Command{name: "certificates/tokens", post: certificateTokensPost}
+
 func certificateFingerprintGet(d *Daemon, r *http.Request) Response {
 	fingerprint := mux.Vars(r)["fingerprint"]
 
@@ -2,12 +2,191 @@ package main
 
 import (
 	"bufio"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+
+	"github.com/lxc/lxd/shared"
 )
 
+// cGroupIsUnified returns whether the host only has the unified (v2) CGroup hierarchy
+func cGroupIsUnified() bool {
+	return shared.PathExists("/sys/fs/cgroup/cgroup.controllers") && !shared.PathExists("/sys/fs/cgroup/memory/")
+}
+
+// cGroupHasController returns whether the given (v1 named) controller is available
+func cGroupHasController(controller string) bool {
+	if !cgUnified {
+		return shared.PathExists(path.Join("/sys/fs/cgroup", controller))
+	}
+
+	content, err := ioutil.ReadFile("/sys/fs/cgroup/cgroup.controllers")
+	if err != nil {
+		return false
+	}
+
+	controllers := strings.Fields(string(content))
+
+	switch controller {
+	case "blkio":
+		return shared.StringInSlice("io", controllers)
+	case "cpuacct":
+		return shared.StringInSlice("cpu", controllers)
+	case "devices":
+		// Device access is controlled through eBPF programs
+		return true
+	case "net_prio":
+		return false
+	}
+
+	return shared.StringInSlice(controller, controllers)
+}
+
+// cGroupHasSwapAccounting returns whether swap usage can be limited
+func cGroupHasSwapAccounting() bool {
+	if !cgUnified {
+		return shared.PathExists("/sys/fs/cgroup/memory/memory.memsw.limit_in_bytes")
+	}
+
+	// The root CGroup doesn't have the swap files, look at its children
+	matches, _ := filepath.Glob("/sys/fs/cgroup/*/memory.swap.max")
+	return len(matches) > 0
+}
+
+// cGroupUnifiedItem translates a CGroup v1 key and value into their unified
+// hierarchy equivalent. An empty key is returned for settings which don't
+// have one.
+func cGroupUnifiedItem(key string, value string) (string, string) {
+	unlimited := func(value string) string {
+		if value == "-1" {
+			return "max"
+		}
+
+		return value
+	}
+
+	switch key {
+	case "memory.limit_in_bytes":
+		return "memory.max", unlimited(value)
+	case "memory.soft_limit_in_bytes":
+		// Memory below memory.low is only reclaimed under global pressure
+		if value == "-1" {
+			return "memory.low", "0"
+		}
+
+		return "memory.low", value
+	case "memory.memsw.limit_in_bytes":
+		// memory.swap.max only limits swap, see cGroupUnifiedSwapMax
+		return "", ""
+	case "memory.usage_in_bytes":
+		return "memory.current", value
+	case "memory.max_usage_in_bytes":
+		return "memory.peak", value
+	case "memory.memsw.usage_in_bytes":
+		return "memory.swap.current", value
+	case "memory.memsw.max_usage_in_bytes":
+		return "memory.swap.peak", value
+	case "memory.swappiness":
+		return "", ""
+	case "memory.oom_control":
+		// Both have an oom_kill counter
+		return "memory.events", value
+	case "cpu.shares":
+		shares, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "cpu.weight", value
+		}
+
+		// Scaled so that the default 1024 shares map to the default weight of 100
+		weight := shares * 100 / 1024
+		if weight < 1 {
+			weight = 1
+		} else if weight > 10000 {
+			weight = 10000
+		}
+
+		return "cpu.weight", fmt.Sprintf("%d", weight)
+	case "cpu.cfs_quota_us", "cpu.cfs_period_us":
+		// Both are combined into cpu.max, see cGroupCPUMax
+		return "", ""
+	case "cpuset.effective_cpus":
+		return "cpuset.cpus.effective", value
+	case "cpuset.effective_mems":
+		return "cpuset.mems.effective", value
+	case "blkio.weight":
+		// blkio.weight defaults to 500 (10-1000), io.weight to 100 (1-10000)
+		weight, err := strconv.Atoi(value)
+		if err != nil {
+			return "io.weight", value
+		}
+
+		if weight < 5 {
+			weight = 5
+		}
+
+		return "io.weight", fmt.Sprintf("%d", weight/5)
+	case "blkio.throttle.read_bps_device", "blkio.throttle.read_iops_device", "blkio.throttle.write_bps_device", "blkio.throttle.write_iops_device":
+		fields := strings.Fields(value)
+		if len(fields) != 2 {
+			return "io.max", value
+		}
+
+		limit := fields[1]
+		if limit == "0" {
+			limit = "max"
+		}
+
+		names := map[string]string{
+			"blkio.throttle.read_bps_device":   "rbps",
+			"blkio.throttle.read_iops_device":  "riops",
+			"blkio.throttle.write_bps_device":  "wbps",
+			"blkio.throttle.write_iops_device": "wiops",
+		}
+
+		return "io.max", fmt.Sprintf("%s %s=%s", fields[0], names[key], limit)
+	case "net_prio.ifpriomap":
+		return "", ""
+	}
+
+	if strings.HasPrefix(key, "hugetlb.") && strings.HasSuffix(key, ".limit_in_bytes") {
+		return strings.TrimSuffix(key, ".limit_in_bytes") + ".max", unlimited(value)
+	}
+
+	return key, value
+}
+
+// cGroupCPUMax returns the unified hierarchy cpu.max value for a CFS quota and period
+func cGroupCPUMax(quota string, period string) string {
+	if quota == "-1" {
+		quota = "max"
+	}
+
+	return fmt.Sprintf("%s %s", quota, period)
+}
+
+// cGroupUnifiedSwapMax returns the unified hierarchy memory.swap.max value
+// for a memory limit and a v1 memory+swap limit (-1 for none), no swap being
+// allowed when it's disabled.
+func cGroupUnifiedSwapMax(memory int64, memsw int64, swap bool) string {
+	if !swap {
+		return "0"
+	}
+
+	if memory < 0 || memsw < 0 {
+		return "max"
+	}
+
+	if memsw < memory {
+		return "0"
+	}
+
+	return fmt.Sprintf("%d", memsw-memory)
+}
+
 func getInitCgroupPath(controller string) string {
 	f, err := os.Open("/proc/1/cgroup")
 	if err != nil {
@@ -19,16 +198,21 @@ func getInitCgroupPath(controller string) string {
 	for scan.Scan() {
 		line := scan.Text()
 
-		fields := strings.Split(line, ":")
+		fields := strings.SplitN(line, ":", 3)
 		if len(fields) != 3 {
 			return "/"
 		}
 
-		if fields[2] != controller {
+		// The unified hierarchy has an empty controller list
+		if cgUnified {
+			if fields[1] != "" {
+				continue
+			}
+		} else if !shared.StringInSlice(controller, strings.Split(fields[1], ",")) {
 			continue
 		}
 
-		initPath := string(fields[3])
+		initPath := string(fields[2])
 
 		// ignore trailing /init.scope if it is there
 		dir, file := path.Split(initPath)
@@ -42,9 +226,25 @@ func getInitCgroupPath(controller string) string {
 	return "/"
 }
 
-func cGroupGet(controller, cgroup, file string) (string, error) {
+func cGroupPath(controller, cgroup, file string) (string, error) {
 	initPath := getInitCgroupPath(controller)
-	path := path.Join("/sys/fs/cgroup", controller, initPath, cgroup, file)
+	if !cgUnified {
+		return path.Join("/sys/fs/cgroup", controller, initPath, cgroup, file), nil
+	}
+
+	file, _ = cGroupUnifiedItem(file, "")
+	if file == "" {
+		return "", fmt.Errorf("The CGroup key isn't available with the unified hierarchy")
+	}
+
+	return path.Join("/sys/fs/cgroup", initPath, cgroup, file), nil
+}
+
+func cGroupGet(controller, cgroup, file string) (string, error) {
+	path, err := cGroupPath(controller, cgroup, file)
+	if err != nil {
+		return "", err
+	}
 
 	contents, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -54,8 +254,10 @@ func cGroupGet(controller, cgroup, file string) (string, error) {
 }
 
 func cGroupSet(controller, cgroup, file string, value string) error {
-	initPath := getInitCgroupPath(controller)
-	path := path.Join("/sys/fs/cgroup", controller, initPath, cgroup, file)
+	path, err := cGroupPath(controller, cgroup, file)
+	if err != nil {
+		return err
+	}
 
 	return ioutil.WriteFile(path, []byte(value), 0755)
 }
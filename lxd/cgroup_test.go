@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCGroupUnifiedItem(t *testing.T) {
+	tests := []struct {
+		key      string
+		value    string
+		newKey   string
+		newValue string
+	}{
+		{"memory.limit_in_bytes", "-1", "memory.max", "max"},
+		{"memory.limit_in_bytes", "1073741824", "memory.max", "1073741824"},
+		{"memory.soft_limit_in_bytes", "-1", "memory.low", "0"},
+		{"memory.memsw.limit_in_bytes", "1073741824", "", ""},
+		{"memory.swappiness", "0", "", ""},
+		{"cpu.shares", "1024", "cpu.weight", "100"},
+		{"cpu.shares", "2", "cpu.weight", "1"},
+		{"cpu.shares", "262144", "cpu.weight", "10000"},
+		{"blkio.weight", "500", "io.weight", "100"},
+		{"blkio.throttle.read_bps_device", "8:0 0", "io.max", "8:0 rbps=max"},
+		{"hugetlb.2MB.limit_in_bytes", "-1", "hugetlb.2MB.max", "max"},
+	}
+
+	for _, test := range tests {
+		key, value := cGroupUnifiedItem(test.key, test.value)
+		if key != test.newKey || value != test.newValue {
+			t.Errorf("Expected %s=%q for %s=%q, got %s=%q", test.newKey, test.newValue, test.key, test.value, key, value)
+		}
+	}
+}
+
+func TestCGroupUnifiedSwapMax(t *testing.T) {
+	tests := []struct {
+		memory int64
+		memsw  int64
+		swap   bool
+		result string
+	}{
+		// limits.memory.swap=false
+		{1073741824, 1073741824, false, "0"},
+		{-1, -1, false, "0"},
+
+		// Swap allowed on top of the memory limit
+		{1073741824, 1610612736, true, "536870912"},
+		{1073741824, 1073741824, true, "0"},
+		{1073741824, -1, true, "max"},
+		{-1, -1, true, "max"},
+	}
+
+	for _, test := range tests {
+		result := cGroupUnifiedSwapMax(test.memory, test.memsw, test.swap)
+		if result != test.result {
+			t.Errorf("Expected %q for memory=%d memsw=%d swap=%v, got %q", test.result, test.memory, test.memsw, test.swap, result)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Where the NoCloud seed files are mounted in containers with cloud-init.seed
+const cloudInitSeedPath = "var/lib/cloud/seed/nocloud-net"
+
+// The files cloud-init writes while running and once done
+const (
+	cloudInitStatusFile = "/run/cloud-init/status.json"
+	cloudInitResultFile = "/run/cloud-init/result.json"
+)
+
+// cloudInitMaxFileSize is how much of the status and result files is read,
+// larger ones being reported as malformed
+const cloudInitMaxFileSize = 64 * 1024
+
+// Final cloud-init status of the running containers, by init PID as it only
+// changes on the next boot
+type cloudInitCachedStatus struct {
+	pid    int
+	status string
+}
+
+var cloudInitStatusLock sync.Mutex
+var cloudInitStatus = map[string]cloudInitCachedStatus{}
+
+// cloudInitMetaData returns the NoCloud meta-data of a container, as also
+// served by /dev/lxd.
+func cloudInitMetaData(c container) string {
+	_, hostname := projectSplitName(c.Name())
+	value := c.ExpandedConfig()["user.meta-data"]
+
+	return fmt.Sprintf("#cloud-config\ninstance-id: %s\nlocal-hostname: %s\n%s", c.Name(), hostname, value)
+}
+
+// cloudInitUsed returns whether the container is configured for cloud-init,
+// in which case its status is reported in the container state.
+func cloudInitUsed(config map[string]string) bool {
+	if shared.IsTrue(config["cloud-init.seed"]) {
+		return true
+	}
+
+	for _, key := range []string{"user.user-data", "user.vendor-data", "user.network-config"} {
+		if config[key] != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cloudInitWriteSeed writes the NoCloud seed files of a container to path.
+func cloudInitWriteSeed(c container, path string) error {
+	config := c.ExpandedConfig()
+
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		return err
+	}
+
+	files := map[string]string{
+		"meta-data":   cloudInitMetaData(c),
+		"user-data":   "#cloud-config\n{}",
+		"vendor-data": "#cloud-config\n{}",
+	}
+
+	if config["user.user-data"] != "" {
+		files["user-data"] = config["user.user-data"]
+	}
+
+	if config["user.vendor-data"] != "" {
+		files["vendor-data"] = config["user.vendor-data"]
+	}
+
+	// Without network-config, cloud-init keeps the image's default
+	if config["user.network-config"] != "" {
+		files["network-config"] = config["user.network-config"]
+	} else {
+		os.Remove(filepath.Join(path, "network-config"))
+	}
+
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(path, name), []byte(content), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cloudInitParseStatus returns the cloud-init status ("running", "done" or
+// "error") from the content of its status and result files, nil ones being
+// missing. An empty string means cloud-init hasn't started (or isn't there).
+func cloudInitParseStatus(status []byte, result []byte) string {
+	if result != nil {
+		content := struct {
+			V1 struct {
+				Errors []interface{} `json:"errors"`
+			} `json:"v1"`
+		}{}
+
+		err := json.Unmarshal(result, &content)
+		if err != nil || len(content.V1.Errors) > 0 {
+			return "error"
+		}
+
+		return "done"
+	}
+
+	if status != nil {
+		return "running"
+	}
+
+	return ""
+}
+
+// cloudInitReadFile reads up to cloudInitMaxFileSize bytes from path, which
+// must be a regular file. Symlinks aren't followed and FIFOs aren't waited on
+// as path is controlled by the container.
+func cloudInitReadFile(path string) ([]byte, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s isn't a regular file", path)
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(f, cloudInitMaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) > cloudInitMaxFileSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", path, cloudInitMaxFileSize)
+	}
+
+	return content, nil
+}
+
+// cloudInitCachedState returns the final cloud-init status of a container if
+// it was already seen during the boot of init PID pid.
+func cloudInitCachedState(name string, pid int) (string, bool) {
+	cloudInitStatusLock.Lock()
+	defer cloudInitStatusLock.Unlock()
+
+	cached, ok := cloudInitStatus[name]
+	if !ok || cached.pid != pid {
+		return "", false
+	}
+
+	return cached.status, true
+}
+
+// cloudInitCacheState records the cloud-init status of a container, only
+// "done" and "error" being final for the current boot.
+func cloudInitCacheState(name string, pid int, status string) {
+	if status != "done" && status != "error" {
+		return
+	}
+
+	cloudInitStatusLock.Lock()
+	cloudInitStatus[name] = cloudInitCachedStatus{pid: pid, status: status}
+	cloudInitStatusLock.Unlock()
+}
+
+func cloudInitForget(name string) {
+	cloudInitStatusLock.Lock()
+	delete(cloudInitStatus, name)
+	cloudInitStatusLock.Unlock()
+}
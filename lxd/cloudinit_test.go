@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCloudInitParseStatus(t *testing.T) {
+	tests := []struct {
+		status   []byte
+		result   []byte
+		expected string
+	}{
+		{nil, nil, ""},
+		{[]byte(`{"v1": {"stage": "init"}}`), nil, "running"},
+		{[]byte(`{"v1": {}}`), []byte(`{"v1": {"datasource": "DataSourceNoCloud", "errors": []}}`), "done"},
+		{nil, []byte(`{"v1": {"errors": ["('init', RuntimeError())"]}}`), "error"},
+		{nil, []byte(`garbage`), "error"},
+	}
+
+	for _, test := range tests {
+		status := cloudInitParseStatus(test.status, test.result)
+		if status != test.expected {
+			t.Errorf("Expected %q for %q/%q, got %q", test.expected, test.status, test.result, status)
+		}
+	}
+}
+
+func TestCloudInitUsed(t *testing.T) {
+	if cloudInitUsed(map[string]string{"cloud-init.seed": "false", "user.foo": "bar"}) {
+		t.Error("cloud-init reported as used without any of its keys")
+	}
+
+	if !cloudInitUsed(map[string]string{"cloud-init.seed": "true"}) {
+		t.Error("cloud-init not reported as used with cloud-init.seed")
+	}
+
+	if !cloudInitUsed(map[string]string{"user.network-config": "version: 1"}) {
+		t.Error("cloud-init not reported as used with user.network-config")
+	}
+}
+
+func TestCloudInitCachedState(t *testing.T) {
+	defer cloudInitForget("c1")
+
+	cloudInitCacheState("c1", 100, "running")
+	_, ok := cloudInitCachedState("c1", 100)
+	if ok {
+		t.Error("Non-final status cached")
+	}
+
+	cloudInitCacheState("c1", 100, "done")
+	status, ok := cloudInitCachedState("c1", 100)
+	if !ok || status != "done" {
+		t.Errorf("Expected the cached \"done\" status, got %q", status)
+	}
+
+	_, ok = cloudInitCachedState("c1", 200)
+	if ok {
+		t.Error("Status cached for a previous boot returned")
+	}
+}
+
+func TestCloudInitReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd_cloudinit_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "status.json"), []byte(`{"v1": {}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Symlink("/dev/zero", filepath.Join(dir, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = syscall.Mkfifo(filepath.Join(dir, "fifo"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := cloudInitReadFile(filepath.Join(dir, "status.json"))
+	if err != nil || string(content) != `{"v1": {}}` {
+		t.Errorf("Unexpected content %q (%v)", content, err)
+	}
+
+	for _, name := range []string{"link", "fifo"} {
+		_, err = cloudInitReadFile(filepath.Join(dir, name))
+		if err == nil {
+			t.Errorf("%s was read", name)
+		}
+	}
+
+	_, err = cloudInitReadFile(filepath.Join(dir, "missing"))
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected a missing file, got %v", err)
+	}
+}
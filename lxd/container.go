@@ -6,6 +6,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,7 +16,10 @@ import (
 	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/osarch"
+
+	log "gopkg.in/inconshreveable/log15.v2"
 )
 
 // Helper functions
@@ -38,6 +43,8 @@ func containerPath(name string, isSnapshot bool) string {
 	return shared.VarPath("containers", name)
 }
 
+// containerValidName checks a container name as given by users, which
+// can't have a project prefix.
 func containerValidName(name string) error {
 	if strings.Contains(name, shared.SnapshotDelimiter) {
 		return fmt.Errorf(
@@ -52,6 +59,13 @@ func containerValidName(name string) error {
 	return nil
 }
 
+// containerValidInternalName checks the name a container is stored as,
+// <project>_<name> for the containers in a project.
+func containerValidInternalName(name string) error {
+	_, name = projectSplitName(name)
+	return containerValidName(name)
+}
+
 func containerValidConfigKey(d *Daemon, key string, value string) error {
 	f, err := shared.ConfigKeyChecker(key)
 	if err != nil {
@@ -234,6 +248,37 @@ func containerValidConfig(d *Daemon, config map[string]string, profile bool, exp
 		return fmt.Errorf("security.syscalls.whitelist is mutually exclusive with security.syscalls.blacklist*")
 	}
 
+	if shared.IsTrue(config["security.nesting"]) {
+		// Nested containers need their own range within the container's map
+		if shared.IsTrue(config["security.idmap.isolated"]) && !shared.IsTrue(config["security.privileged"]) {
+			size := config["security.idmap.size"]
+			if size != "" && size != "auto" {
+				value, err := strconv.ParseInt(size, 10, 64)
+				if err == nil && value < idmapNestingSize {
+					return fmt.Errorf("security.nesting requires security.idmap.size to be at least %d", idmapNestingSize)
+				}
+			}
+		}
+
+		// The mounts set up for nesting mustn't be overridden
+		for _, line := range strings.Split(config["raw.lxc"], "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "lxc.mount.auto") {
+				return fmt.Errorf("raw.lxc can't set lxc.mount.auto when security.nesting is enabled")
+			}
+		}
+	}
+
+	switch config["health.probe"] {
+	case "exec":
+		if config["health.probe.command"] == "" {
+			return fmt.Errorf("The exec health probe requires health.probe.command")
+		}
+	case "tcp", "http":
+		if config["health.probe.port"] == "" {
+			return fmt.Errorf("The %s health probe requires health.probe.port", config["health.probe"])
+		}
+	}
+
 	if expanded && (config["security.privileged"] == "" || !shared.IsTrue(config["security.privileged"])) && d.IdmapSet == nil {
 		return fmt.Errorf("LXD doesn't have a uid/gid allocation. In this mode, only privileged containers are supported.")
 	}
@@ -241,6 +286,137 @@ func containerValidConfig(d *Daemon, config map[string]string, profile bool, exp
 	return nil
 }
 
+// containerLimitsUsage returns the number of CPUs and the amount of memory
+// the given expanded config limits a container to, -1 meaning unlimited.
+func containerLimitsUsage(config map[string]string) (int64, int64, error) {
+	cpus := int64(-1)
+	if config["limits.cpu"] != "" {
+		count, err := strconv.ParseInt(config["limits.cpu"], 10, 64)
+		if err == nil {
+			cpus = count
+		} else {
+			ids, err := shared.ParseCpuset(config["limits.cpu"])
+			if err != nil {
+				return -1, -1, err
+			}
+
+			cpus = int64(len(ids))
+		}
+	}
+
+	memory := int64(-1)
+	if config["limits.memory"] != "" {
+		var err error
+		memory, err = deviceParseMemoryLimit(config["limits.memory"])
+		if err != nil {
+			return -1, -1, err
+		}
+	}
+
+	return cpus, memory, nil
+}
+
+// containerValidLimits checks the CPU and memory limits of a container
+// against the host's resources, according to the limits.*_overcommit server
+// keys. Containers without limits aren't accounted for.
+func containerValidLimits(d *Daemon, name string, config map[string]string) error {
+	cpuFactor := daemonConfig["limits.cpu_overcommit"].Get()
+	memoryFactor := daemonConfig["limits.memory_overcommit"].Get()
+	if cpuFactor == "" && memoryFactor == "" {
+		return nil
+	}
+
+	cpus, memory, err := containerLimitsUsage(config)
+	if err != nil {
+		return err
+	}
+
+	if cpus == -1 && memory == -1 {
+		return nil
+	}
+
+	// Add up the limits of all the other containers
+	containers, err := dbContainersList(d.db, cTypeRegular)
+	if err != nil {
+		return err
+	}
+
+	totalCpus := cpus
+	totalMemory := memory
+	for _, ctName := range containers {
+		if ctName == name {
+			continue
+		}
+
+		c, err := containerLoadByName(d, ctName)
+		if err != nil {
+			return err
+		}
+
+		ctCpus, ctMemory, err := containerLimitsUsage(c.ExpandedConfig())
+		if err != nil {
+			continue
+		}
+
+		if ctCpus > 0 {
+			totalCpus += ctCpus
+		}
+
+		if ctMemory > 0 {
+			totalMemory += ctMemory
+		}
+	}
+
+	overcommitted := []string{}
+
+	if cpuFactor != "" && cpus != -1 {
+		factor, err := strconv.ParseFloat(cpuFactor, 64)
+		if err != nil {
+			return err
+		}
+
+		hostCpus := int64(runtime.NumCPU())
+		if cpus > hostCpus {
+			return fmt.Errorf("The host only has %d CPUs", hostCpus)
+		}
+
+		if float64(totalCpus) > float64(hostCpus)*factor {
+			overcommitted = append(overcommitted, fmt.Sprintf("%d CPUs allocated out of %d (overcommit factor %s)", totalCpus, hostCpus, cpuFactor))
+		}
+	}
+
+	if memoryFactor != "" && memory != -1 {
+		factor, err := strconv.ParseFloat(memoryFactor, 64)
+		if err != nil {
+			return err
+		}
+
+		hostMemory, err := deviceTotalMemory()
+		if err != nil {
+			return err
+		}
+
+		if memory > hostMemory {
+			return fmt.Errorf("The host only has %s of memory", shared.GetByteSizeString(hostMemory, 2))
+		}
+
+		if float64(totalMemory) > float64(hostMemory)*factor {
+			overcommitted = append(overcommitted, fmt.Sprintf("%s of memory allocated out of %s (overcommit factor %s)", shared.GetByteSizeString(totalMemory, 2), shared.GetByteSizeString(hostMemory, 2), memoryFactor))
+		}
+	}
+
+	if len(overcommitted) == 0 {
+		return nil
+	}
+
+	if daemonConfig["limits.overcommit_action"].Get() == "warn" {
+		logger.Warn("Host resources are overcommitted", log.Ctx{"container": name, "usage": strings.Join(overcommitted, ", ")})
+		return nil
+	}
+
+	return fmt.Errorf("Host resources would be overcommitted: %s", strings.Join(overcommitted, ", "))
+}
+
 func isRootDiskDevice(device types.Device) bool {
 	if device["type"] == "disk" && device["path"] == "/" && device["source"] == "" {
 		return true
@@ -290,17 +466,17 @@ func containerValidDevices(d *Daemon, devices types.Devices, profile bool, expan
 
 		for k := range m {
 			if !containerValidDeviceConfigKey(m["type"], k) {
-				return fmt.Errorf("Invalid device configuration key for %s: %s", m["type"], k)
+				return fmt.Errorf("Invalid device configuration key for %s device '%s': %s", m["type"], name, k)
 			}
 		}
 
 		if m["type"] == "nic" {
 			if m["nictype"] == "" {
-				return fmt.Errorf("Missing nic type")
+				return fmt.Errorf("Missing nic type for device '%s'", name)
 			}
 
 			if !shared.StringInSlice(m["nictype"], []string{"bridged", "physical", "p2p", "macvlan"}) {
-				return fmt.Errorf("Bad nic type: %s", m["nictype"])
+				return fmt.Errorf("Bad nic type for device '%s': %s", name, m["nictype"])
 			}
 
 			if shared.StringInSlice(m["nictype"], []string{"bridged", "physical", "macvlan"}) && m["parent"] == "" {
@@ -467,6 +643,8 @@ type container interface {
 	// Status
 	Render() (interface{}, interface{}, error)
 	RenderState() (*api.ContainerState, error)
+	NetworkState() map[string]api.ContainerStateNetwork
+	SnapshotUsage() (int64, error)
 	IsPrivileged() bool
 	IsRunning() bool
 	IsFrozen() bool
@@ -536,6 +714,8 @@ func containerCreateAsEmpty(d *Daemon, args containerArgs) (container, error) {
 		return nil, err
 	}
 
+	containerLifecycleEvent("container-created", c.Name())
+
 	return c, nil
 }
 
@@ -595,6 +775,8 @@ func containerCreateFromImage(d *Daemon, args containerArgs, hash string) (conta
 		return nil, err
 	}
 
+	containerLifecycleEvent("container-created", c.Name())
+
 	return c, nil
 }
 
@@ -660,6 +842,8 @@ func containerCreateAsCopy(d *Daemon, args containerArgs, sourceContainer contai
 		}
 	}
 
+	containerLifecycleEvent("container-created", ct.Name())
+
 	return ct, nil
 }
 
@@ -756,7 +940,7 @@ func containerCreateInternal(d *Daemon, args containerArgs) (container, error) {
 
 	// Validate container name
 	if args.Ctype == cTypeRegular {
-		err := containerValidName(args.Name)
+		err := containerValidInternalName(args.Name)
 		if err != nil {
 			return nil, err
 		}
@@ -828,6 +1012,10 @@ func containerCreateInternal(d *Daemon, args containerArgs) (container, error) {
 		return nil, err
 	}
 
+	if args.Ctype == cTypeRegular {
+		networkZoneInvalidate()
+	}
+
 	return c, nil
 }
 
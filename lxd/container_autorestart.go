@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "gopkg.in/inconshreveable/log15.v2"
+
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// containerAutorestartReset is how long a restarted container must stay up
+// for its next stop to be backed off from scratch again.
+const containerAutorestartReset = 10 * time.Minute
+
+type containerAutorestartState struct {
+	restarts    int
+	lastRestart time.Time
+	timer       *time.Timer
+}
+
+// Restarts of the containers which stopped without LXD being asked to
+var containerAutorestartLock sync.Mutex
+var containerAutorestarts = map[string]*containerAutorestartState{}
+
+// containerAutorestartConfigInt returns an integer boot.autorestart key, or
+// its default.
+func containerAutorestartConfigInt(config map[string]string, key string, defaultValue int) int {
+	value, err := strconv.Atoi(config[key])
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+
+	return value
+}
+
+// containerAutorestartDelay returns how long to wait before the given
+// consecutive restart, doubling from boot.autorestart.delay up to
+// boot.autorestart.max_delay.
+func containerAutorestartDelay(config map[string]string, restarts int) time.Duration {
+	delay := time.Duration(containerAutorestartConfigInt(config, "boot.autorestart.delay", 5)) * time.Second
+	maxDelay := time.Duration(containerAutorestartConfigInt(config, "boot.autorestart.max_delay", 300)) * time.Second
+
+	for i := 0; i < restarts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return delay
+}
+
+// containerAutorestartFailed returns whether the container's init process
+// exited on an error or got killed, as logged by liblxc before running the
+// post-stop hook. A clean exit or a halt (init killed by SIGINT following a
+// poweroff) isn't a failure.
+func containerAutorestartFailed(log []byte) bool {
+	for _, line := range strings.Split(string(log), "\n") {
+		if strings.Contains(line, " ended on error (") {
+			return true
+		}
+
+		idx := strings.Index(line, " ended on signal (")
+		if idx < 0 {
+			continue
+		}
+
+		signal := strings.SplitN(line[idx+len(" ended on signal ("):], ")", 2)[0]
+		if signal != fmt.Sprintf("%d", syscall.SIGINT) && signal != fmt.Sprintf("%d", syscall.SIGHUP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containerAutorestartSchedule restarts a container which stopped without
+// LXD being asked to, as per its boot.autorestart policy. With on-failure,
+// clean exits are left alone and it gives up after boot.autorestart.retries
+// consecutive restarts.
+func containerAutorestartSchedule(d *Daemon, name string, config map[string]string, failed bool) {
+	policy := config["boot.autorestart"]
+	if policy == "" {
+		return
+	}
+
+	containerAutorestartLock.Lock()
+	defer containerAutorestartLock.Unlock()
+
+	if policy == "on-failure" && !failed {
+		state, ok := containerAutorestarts[name]
+		if ok && state.timer != nil {
+			state.timer.Stop()
+		}
+
+		delete(containerAutorestarts, name)
+		return
+	}
+
+	state, ok := containerAutorestarts[name]
+	if !ok || (state.timer == nil && time.Since(state.lastRestart) > containerAutorestartReset) {
+		state = &containerAutorestartState{}
+		containerAutorestarts[name] = state
+	}
+
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+
+	retries := containerAutorestartConfigInt(config, "boot.autorestart.retries", 3)
+	if policy == "on-failure" && state.restarts >= retries {
+		logger.Warn("Container keeps stopping, no longer restarting it", log.Ctx{"container": name, "restarts": state.restarts})
+		delete(containerAutorestarts, name)
+		return
+	}
+
+	delay := containerAutorestartDelay(config, state.restarts)
+	state.restarts++
+
+	logger.Info("Restarting container", log.Ctx{"container": name, "delay": delay, "restarts": state.restarts})
+
+	state.timer = time.AfterFunc(delay, func() {
+		containerAutorestartLock.Lock()
+		current := containerAutorestarts[name] == state
+		if current {
+			state.timer = nil
+			state.lastRestart = time.Now()
+		}
+		containerAutorestartLock.Unlock()
+
+		// The restart got cancelled meanwhile
+		if !current {
+			return
+		}
+
+		c, err := containerLoadByName(d, name)
+		if err != nil || c.IsRunning() || c.ExpandedConfig()["boot.autorestart"] == "" {
+			return
+		}
+
+		err = c.Start(false)
+		if err != nil {
+			logger.Error("Failed to restart container", log.Ctx{"container": name, "err": err})
+			containerAutorestartSchedule(d, name, c.ExpandedConfig(), true)
+			return
+		}
+
+		containerLifecycleEvent("container-restarted", name)
+	})
+}
+
+// containerAutorestartCancel is called when a container gets stopped or
+// deleted through LXD, dropping any pending restart.
+func containerAutorestartCancel(name string) {
+	containerAutorestartLock.Lock()
+	defer containerAutorestartLock.Unlock()
+
+	state, ok := containerAutorestarts[name]
+	if !ok {
+		return
+	}
+
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+
+	delete(containerAutorestarts, name)
+}
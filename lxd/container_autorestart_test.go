@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestContainerAutorestartDelay(t *testing.T) {
+	config := map[string]string{"boot.autorestart.delay": "5", "boot.autorestart.max_delay": "60"}
+
+	expected := []time.Duration{5, 10, 20, 40, 60, 60}
+	for restarts, delay := range expected {
+		result := containerAutorestartDelay(config, restarts)
+		if result != delay*time.Second {
+			t.Errorf("Expected %v before restart %d, got %v", delay*time.Second, restarts+1, result)
+		}
+	}
+
+	// Defaults
+	result := containerAutorestartDelay(map[string]string{}, 10)
+	if result != 300*time.Second {
+		t.Errorf("Expected the default maximum delay, got %v", result)
+	}
+}
+
+func TestContainerAutorestartFailed(t *testing.T) {
+	tests := []struct {
+		log    string
+		failed bool
+	}{
+		// Clean exit
+		{"", false},
+		{"lxc 20180101 INFO     start - start.c:lxc_fini:871 - Closing fd", false},
+
+		// Poweroff from inside the container
+		{"lxc 20180101 INFO     error - error.c:lxc_error_set_and_log:55 - Child <1234> ended on signal (2)", false},
+
+		// Crashes and kills
+		{"lxc 20180101 INFO     error - error.c:lxc_error_set_and_log:49 - Child <1234> ended on error (1)", true},
+		{"lxc 20180101 INFO     error - error.c:lxc_error_set_and_log:55 - Child <1234> ended on signal (9)", true},
+		{"lxc 20180101 INFO     error - error.c:lxc_error_set_and_log:55 - Child <1234> ended on signal (11)\nlxc 20180101 INFO     start - start.c:lxc_fini:871 - Closing fd", true},
+	}
+
+	for _, test := range tests {
+		result := containerAutorestartFailed([]byte(test.log))
+		if result != test.failed {
+			t.Errorf("Expected %v for %q, got %v", test.failed, test.log, result)
+		}
+	}
+}
@@ -0,0 +1,925 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/osarch"
+	"github.com/lxc/lxd/shared/version"
+
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+/* Container backups are gzip (or zstd, see backups.compression_algorithm)
+ * compressed tarballs with the following layout:
+ *
+ *   backup/index.yaml              Container, snapshots and storage information
+ *   backup/snapshots/<name>/       Content of each snapshot, oldest first
+ *   backup/container/              Content of the container itself
+ *   backup/manifest.yaml           SHA-256 checksum of every regular file above
+ *
+ * Optimized backups instead contain the storage backend's send streams as
+ * backup/snapshots/<name>.bin and backup/container.bin and can only be
+ * restored on a pool using the same driver.
+ *
+ * The file ownership is stored as found on disk, the idmap recorded in the
+ * container configuration is used to remap it when the container starts.
+ */
+
+// backupTarWriter records the checksum of every regular file written to the
+// tarball so they can be stored in the manifest.
+type backupTarWriter struct {
+	*tar.Writer
+
+	manifest shared.BackupManifest
+	current  string
+	hash     hash.Hash
+}
+
+func newBackupTarWriter(w io.Writer) *backupTarWriter {
+	return &backupTarWriter{
+		Writer:   tar.NewWriter(w),
+		manifest: shared.BackupManifest{Files: map[string]string{}},
+	}
+}
+
+func (tw *backupTarWriter) finishEntry() {
+	if tw.hash != nil {
+		tw.manifest.Files[tw.current] = hex.EncodeToString(tw.hash.Sum(nil))
+		tw.hash = nil
+	}
+}
+
+func (tw *backupTarWriter) WriteHeader(hdr *tar.Header) error {
+	tw.finishEntry()
+
+	if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA {
+		tw.current = hdr.Name
+		tw.hash = sha256.New()
+	}
+
+	return tw.Writer.WriteHeader(hdr)
+}
+
+func (tw *backupTarWriter) Write(b []byte) (int, error) {
+	n, err := tw.Writer.Write(b)
+	if tw.hash != nil {
+		tw.hash.Write(b[:n])
+	}
+
+	return n, err
+}
+
+// Close appends the manifest and closes the tarball.
+func (tw *backupTarWriter) Close() error {
+	tw.finishEntry()
+
+	data, err := yaml.Marshal(&tw.manifest)
+	if err != nil {
+		return err
+	}
+
+	err = tw.Writer.WriteHeader(&tar.Header{
+		Name:    shared.BackupManifestPath,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Writer.Write(data)
+	if err != nil {
+		return err
+	}
+
+	return tw.Writer.Close()
+}
+
+// backupTarStoreFile adds a single file to the backup tarball under the given name.
+func backupTarStoreFile(linkmap map[uint64]string, tw *backupTarWriter, name string, path string, fi os.FileInfo) error {
+	var err error
+	var major, minor, nlink int
+	var ino uint64
+
+	// Sockets can't be stored and are meaningless once restored
+	if fi.Mode()&os.ModeSocket == os.ModeSocket {
+		return nil
+	}
+
+	link := ""
+	if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+		link, err = os.Readlink(path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve symlink: %s", err)
+		}
+	}
+
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return fmt.Errorf("failed to create tar info header: %s", err)
+	}
+
+	hdr.Name = name
+	if fi.IsDir() || fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+		hdr.Size = 0
+	} else {
+		hdr.Size = fi.Size()
+	}
+
+	hdr.Uid, hdr.Gid, major, minor, ino, nlink, err = shared.GetFileStat(path)
+	if err != nil {
+		return fmt.Errorf("failed to get file stat: %s", err)
+	}
+
+	if major != -1 {
+		hdr.Devmajor = int64(major)
+		hdr.Devminor = int64(minor)
+	}
+
+	// If it's a hardlink we've already seen use the old name
+	if fi.Mode().IsRegular() && nlink > 1 {
+		if firstpath, found := linkmap[ino]; found {
+			hdr.Typeflag = tar.TypeLink
+			hdr.Linkname = firstpath
+			hdr.Size = 0
+		} else {
+			linkmap[ino] = hdr.Name
+		}
+	}
+
+	// Handle xattrs (for real files only)
+	if link == "" {
+		hdr.Xattrs, err = shared.GetAllXattr(path)
+		if err != nil {
+			return fmt.Errorf("failed to read xattr: %s", err)
+		}
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write tar header: %s", err)
+	}
+
+	if hdr.Typeflag == tar.TypeReg {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open the file: %s", err)
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(tw, f); err != nil {
+			return fmt.Errorf("failed to copy file content: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// backupTarStoreContainer adds the whole content of the container (or
+// snapshot) directory to the backup tarball under the given prefix.
+func backupTarStoreContainer(tw *backupTarWriter, c container, prefix string) error {
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	// The container path is usually a symlink into the storage pool
+	cDir, err := filepath.EvalSymlinks(c.Path())
+	if err != nil {
+		return err
+	}
+
+	linkmap := map[uint64]string{}
+
+	return filepath.Walk(cDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := prefix
+		if path != cDir {
+			name = filepath.Join(prefix, path[len(cDir)+1:])
+		}
+
+		return backupTarStoreFile(linkmap, tw, name, path, fi)
+	})
+}
+
+// backupTarStoreStreams adds the storage backend's send streams for the
+// snapshots and the container to the backup tarball.
+func backupTarStoreStreams(tw *backupTarWriter, c container, snapshots []container) error {
+	tmpDir, err := ioutil.TempDir(shared.VarPath("backups"), "lxd_backup_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	err = c.Storage().ContainerBackupDump(c, snapshots, tmpDir)
+	if err != nil {
+		return err
+	}
+
+	linkmap := map[uint64]string{}
+	return filepath.Walk(tmpDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == tmpDir {
+			return nil
+		}
+
+		return backupTarStoreFile(linkmap, tw, filepath.Join("backup", path[len(tmpDir)+1:]), path, fi)
+	})
+}
+
+// containerBackupWrite writes a full backup of the container to the writer.
+// Optimized backups embed the native send streams of the storage backend
+// rather than the container's files.
+func containerBackupWrite(c container, w io.Writer, optimized bool) error {
+	ci, _, err := c.Render()
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := c.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	index := backupFile{Container: ci.(*api.Container), OptimizedStorage: optimized}
+	for _, snap := range snapshots {
+		si, _, err := snap.Render()
+		if err != nil {
+			return err
+		}
+
+		index.Snapshots = append(index.Snapshots, si.(*api.ContainerSnapshot))
+	}
+
+	poolName, err := c.StoragePool()
+	if err != nil {
+		return err
+	}
+
+	_, index.Pool, err = dbStoragePoolGet(c.Daemon().db, poolName)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&index)
+	if err != nil {
+		return err
+	}
+
+	gw, err := backupCompressWriter(w, daemonConfig["backups.compression_algorithm"].Get())
+	if err != nil {
+		return err
+	}
+	defer gw.Close()
+
+	tw := newBackupTarWriter(gw)
+
+	hdr := &tar.Header{
+		Name:    shared.BackupIndexPath,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: ci.(*api.Container).CreatedAt,
+	}
+
+	err = tw.WriteHeader(hdr)
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(data)
+	if err != nil {
+		return err
+	}
+
+	if optimized {
+		err = backupTarStoreStreams(tw, c, snapshots)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, snap := range snapshots {
+			_, snapName, _ := containerGetParentAndSnapshotName(snap.Name())
+			err = backupTarStoreContainer(tw, snap, filepath.Join("backup", "snapshots", snapName))
+			if err != nil {
+				return err
+			}
+		}
+
+		err = backupTarStoreContainer(tw, c, filepath.Join("backup", "container"))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+// cmdWriter feeds the standard input of an external (de)compressor which
+// writes to the underlying writer. Closing it waits for the command to exit.
+type cmdWriter struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	closed bool
+}
+
+func (w *cmdWriter) Write(p []byte) (int, error) {
+	return w.stdin.Write(p)
+}
+
+func (w *cmdWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	err := w.stdin.Close()
+	waitErr := w.cmd.Wait()
+	if err != nil {
+		return err
+	}
+
+	return waitErr
+}
+
+// backupCompressWriter returns a writer compressing the backup tarball with
+// the given algorithm.
+func backupCompressWriter(w io.Writer, algorithm string) (io.WriteCloser, error) {
+	if algorithm != "zstd" {
+		return gzip.NewWriter(w), nil
+	}
+
+	cmd := exec.Command("zstd", "-c", "-q", "-T0")
+	cmd.Stdout = w
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cmdWriter{cmd: cmd, stdin: stdin}, nil
+}
+
+// backupExtension returns the file extension matching the configured
+// backup compression.
+func backupExtension() string {
+	if daemonConfig["backups.compression_algorithm"].Get() == "zstd" {
+		return ".tar.zst"
+	}
+
+	return ".tar.gz"
+}
+
+// backupTarExtractArgs returns the tar arguments extracting the given backup
+// tarball, whichever compression it uses.
+func backupTarExtractArgs(path string) ([]string, error) {
+	extractArgs, extension, err := detectCompression(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if extension != ".tar.gz" && extension != ".tar.zst" {
+		return nil, fmt.Errorf("Invalid backup tarball: unsupported compression")
+	}
+
+	return append(tarDecompressArgs(extractArgs, extension), path), nil
+}
+
+func containerExportGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	c, err := containerLoadByName(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	format := r.FormValue("format")
+	if format != "" && format != "tarball" {
+		return BadRequest(fmt.Errorf("Disk images are built by POSTing to the export endpoint"))
+	}
+
+	f, err := ioutil.TempFile(shared.VarPath("backups"), "lxd_backup_")
+	if err != nil {
+		return InternalError(err)
+	}
+	defer f.Close()
+
+	err = containerBackupWrite(c, f, shared.IsTrue(r.FormValue("optimized")))
+	if err != nil {
+		os.Remove(f.Name())
+		return SmartError(err)
+	}
+
+	ent := fileResponseEntry{
+		path:     f.Name(),
+		filename: c.Name() + backupExtension(),
+	}
+
+	return FileResponse(r, []fileResponseEntry{ent}, nil, true)
+}
+
+// containerExportPost builds a disk image of the container in the
+// background, to be downloaded from the "url" of the operation's metadata.
+func containerExportPost(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	c, err := containerLoadByName(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	req := api.ContainerExportPost{}
+	err = shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	extension, ok := containerDiskImageFormats[req.Format]
+	if !ok {
+		return BadRequest(fmt.Errorf("Invalid export format: %s", req.Format))
+	}
+
+	if c.IsRunning() {
+		return BadRequest(fmt.Errorf("The container must be stopped to be exported as a disk image"))
+	}
+
+	containerDiskImagesPrune()
+
+	run := func(op *operation) error {
+		err := os.MkdirAll(containerDiskImagesPath(name), 0700)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(containerDiskImagesPath(name), op.id+extension)
+		err = containerDiskImageWrite(c, path, req.Format)
+		if err != nil {
+			os.Remove(path)
+			return err
+		}
+
+		return op.UpdateMetadata(map[string]interface{}{
+			"url": fmt.Sprintf("/%s/containers/%s/export/%s", version.APIVersion, name, op.id),
+		})
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(operationClassTask, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+var containerExportCmd = Command{name: "containers/{name}/export", get: containerExportGet, post: containerExportPost}
+
+// containerExportDiskGet downloads a disk image built by an export
+// operation, which is removed once served.
+func containerExportDiskGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	id := mux.Vars(r)["id"]
+
+	_, err := containerLoadByName(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if id == "" || strings.Contains(id, "/") || strings.HasPrefix(id, ".") {
+		return BadRequest(fmt.Errorf("Invalid disk image: %s", id))
+	}
+
+	for _, extension := range containerDiskImageFormats {
+		path := filepath.Join(containerDiskImagesPath(name), id+extension)
+		if !shared.PathExists(path) {
+			continue
+		}
+
+		ent := fileResponseEntry{
+			path:     path,
+			filename: name + extension,
+		}
+
+		return FileResponse(r, []fileResponseEntry{ent}, nil, true)
+	}
+
+	return NotFound
+}
+
+var containerExportDiskCmd = Command{name: "containers/{name}/export/{id}", get: containerExportDiskGet}
+
+// backupReadIndex looks for the index at the beginning of a backup tarball.
+func backupReadIndex(path string) (*backupFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, len(shared.EncryptedStreamMagic))
+	f.ReadAt(header, 0)
+	if shared.IsEncryptedStream(header) {
+		return nil, fmt.Errorf("The backup is encrypted and must be decrypted before being imported")
+	}
+
+	gr, err := shared.NewBackupDecompressReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid backup tarball: %s", err)
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid backup tarball: %s", err)
+	}
+
+	if hdr.Name != shared.BackupIndexPath {
+		return nil, fmt.Errorf("Invalid backup tarball: missing %s", shared.BackupIndexPath)
+	}
+
+	data, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return nil, err
+	}
+
+	index := backupFile{}
+	err = yaml.Unmarshal(data, &index)
+	if err != nil {
+		return nil, err
+	}
+
+	if index.Container == nil {
+		return nil, fmt.Errorf("Invalid backup tarball: missing container information")
+	}
+
+	return &index, nil
+}
+
+// backupUnpack replaces the content of the container directory with the
+// given directory from the backup tarball.
+func backupUnpack(c container, path string, member string) error {
+	entries, err := ioutil.ReadDir(c.Path())
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		err := os.RemoveAll(filepath.Join(c.Path(), entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	args, err := backupTarExtractArgs(path)
+	if err != nil {
+		return err
+	}
+
+	args = append(args, "-C", c.Path(), "--numeric-owner", "--xattrs", "--xattrs-include=*")
+	if runningInUserns {
+		args = append(args, "--wildcards", fmt.Sprintf("--exclude=%s/rootfs/dev/*", member))
+	}
+	args = append(args, fmt.Sprintf("--strip-components=%d", len(strings.Split(member, "/"))), member)
+
+	output, err := shared.RunCommand("tar", args...)
+	if err != nil {
+		return fmt.Errorf("Unpack failed, %s. %s", err, output)
+	}
+
+	return nil
+}
+
+func containerBackupRestore(d *Daemon, path string, name string, pool string) error {
+	index, err := backupReadIndex(path)
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		name = index.Container.Name
+	}
+
+	architecture, err := osarch.ArchitectureId(index.Container.Architecture)
+	if err != nil {
+		return err
+	}
+
+	// A renamed container may live next to the original one, so treat it
+	// like a copy and don't carry over its MAC addresses and such.
+	renamed := name != index.Container.Name
+
+	config := map[string]string{}
+	for k, v := range index.Container.Config {
+		// Those get regenerated for the new container
+		if k == "volatile.idmap.next" || k == "volatile.idmap.base" {
+			continue
+		}
+
+		if renamed && strings.HasPrefix(k, "volatile.") && !shared.StringInSlice(k, []string{"volatile.base_image", "volatile.last_state.idmap"}) {
+			continue
+		}
+
+		config[k] = v
+	}
+
+	profiles, err := dbProfiles(d.db)
+	if err != nil {
+		return err
+	}
+
+	for _, profile := range index.Container.Profiles {
+		if !shared.StringInSlice(profile, profiles) {
+			return fmt.Errorf("The profile \"%s\" doesn't exist on this host", profile)
+		}
+	}
+
+	args := containerArgs{
+		Architecture: architecture,
+		Config:       config,
+		Ctype:        cTypeRegular,
+		Description:  index.Container.Description,
+		Devices:      index.Container.Devices,
+		Ephemeral:    index.Container.Ephemeral,
+		Name:         name,
+		Profiles:     index.Container.Profiles,
+	}
+
+	// Figure out the storage pool to restore into
+	rootDevName, rootDev, _ := containerGetRootDiskDevice(args.Devices)
+	if pool != "" {
+		_, err := dbStoragePoolGetID(d.db, pool)
+		if err != nil {
+			return err
+		}
+
+		if rootDevName == "" {
+			if args.Devices == nil {
+				args.Devices = types.Devices{}
+			}
+
+			rootDevName = "root"
+			rootDev = types.Device{"type": "disk", "path": "/"}
+			args.Devices[rootDevName] = rootDev
+		}
+
+		rootDev["pool"] = pool
+	} else if rootDevName != "" && rootDev["pool"] != "" {
+		_, err := dbStoragePoolGetID(d.db, rootDev["pool"])
+		if err == NoSuchObjectError {
+			return fmt.Errorf("The storage pool \"%s\" doesn't exist on this host, a target pool must be specified", rootDev["pool"])
+		} else if err != nil {
+			return err
+		}
+	}
+
+	c, err := containerCreateAsEmpty(d, args)
+	if err != nil {
+		return err
+	}
+
+	success := false
+	defer func() {
+		if !success {
+			c.Delete()
+		}
+	}()
+
+	if index.OptimizedStorage {
+		err = backupRestoreOptimized(d, c, index, path)
+	} else {
+		err = backupRestoreGeneric(d, c, index, path)
+	}
+	if err != nil {
+		return err
+	}
+
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	err = writeBackupFile(c)
+	if err != nil {
+		return err
+	}
+
+	success = true
+	return nil
+}
+
+// backupSnapshotArgs returns the arguments needed to re-create a snapshot of
+// the restored container, on the same pool as the container.
+func backupSnapshotArgs(c container, snap *api.ContainerSnapshot) (containerArgs, error) {
+	_, snapName, _ := containerGetParentAndSnapshotName(snap.Name)
+
+	architecture, err := osarch.ArchitectureId(snap.Architecture)
+	if err != nil {
+		return containerArgs{}, err
+	}
+
+	poolName, err := c.StoragePool()
+	if err != nil {
+		return containerArgs{}, err
+	}
+
+	devices := snap.Devices
+	rootDevName, _, _ := containerGetRootDiskDevice(devices)
+	if rootDevName != "" {
+		devices[rootDevName]["pool"] = poolName
+	}
+
+	return containerArgs{
+		Architecture: architecture,
+		Config:       snap.Config,
+		Ctype:        cTypeSnapshot,
+		Devices:      devices,
+		Ephemeral:    snap.Ephemeral,
+		Name:         fmt.Sprintf("%s%s%s", c.Name(), shared.SnapshotDelimiter, snapName),
+		Profiles:     snap.Profiles,
+	}, nil
+}
+
+// backupRestoreGeneric restores the snapshots and the container by unpacking
+// them one at a time into the container and letting the storage backend
+// snapshot it.
+func backupRestoreGeneric(d *Daemon, c container, index *backupFile, path string) error {
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	for _, snap := range index.Snapshots {
+		logger.Debug("Restoring snapshot from backup", log.Ctx{"container": c.Name(), "snapshot": snap.Name})
+
+		_, snapName, _ := containerGetParentAndSnapshotName(snap.Name)
+		err = backupUnpack(c, path, fmt.Sprintf("backup/snapshots/%s", snapName))
+		if err != nil {
+			return err
+		}
+
+		snapArgs, err := backupSnapshotArgs(c, snap)
+		if err != nil {
+			return err
+		}
+
+		_, err = containerCreateAsSnapshot(d, snapArgs, c)
+		if err != nil {
+			return err
+		}
+	}
+
+	return backupUnpack(c, path, "backup/container")
+}
+
+// backupRestoreOptimized restores the snapshots and the container from the
+// native send streams of the storage backend.
+func backupRestoreOptimized(d *Daemon, c container, index *backupFile, path string) error {
+	if index.Pool == nil {
+		return fmt.Errorf("Invalid backup tarball: missing storage pool information")
+	}
+
+	if index.Pool.Driver != c.Storage().GetStorageTypeName() {
+		return fmt.Errorf("This backup can only be restored on a storage pool using the \"%s\" driver", index.Pool.Driver)
+	}
+
+	tmpDir, err := ioutil.TempDir(shared.VarPath("backups"), "lxd_backup_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	args, err := backupTarExtractArgs(path)
+	if err != nil {
+		return err
+	}
+
+	args = append(args, "-C", tmpDir, "--strip-components=1", "backup/container.bin", "backup/snapshots")
+	output, err := shared.RunCommand("tar", args...)
+	if err != nil {
+		return fmt.Errorf("Unpack failed, %s. %s", err, output)
+	}
+
+	snapshots := []container{}
+	for _, snap := range index.Snapshots {
+		snapArgs, err := backupSnapshotArgs(c, snap)
+		if err != nil {
+			return err
+		}
+
+		sc, err := containerCreateEmptySnapshot(d, snapArgs)
+		if err != nil {
+			return err
+		}
+
+		snapshots = append(snapshots, sc)
+	}
+
+	return c.Storage().ContainerBackupLoad(c, snapshots, tmpDir)
+}
+
+func createFromBackup(d *Daemon, data io.Reader, name string, pool string) Response {
+	// Store the backup to disk before processing it
+	f, err := ioutil.TempFile(shared.VarPath("backups"), "lxd_backup_")
+	if err != nil {
+		return InternalError(err)
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, data)
+	if err != nil {
+		os.Remove(f.Name())
+		return InternalError(err)
+	}
+
+	index, err := backupReadIndex(f.Name())
+	if err != nil {
+		os.Remove(f.Name())
+		return BadRequest(err)
+	}
+
+	if name == "" {
+		name = index.Container.Name
+	}
+
+	err = containerValidName(name)
+	if err != nil {
+		os.Remove(f.Name())
+		return BadRequest(err)
+	}
+
+	run := func(op *operation) error {
+		defer os.Remove(f.Name())
+
+		// Check the content against the manifest before restoring anything
+		backup, err := os.Open(f.Name())
+		if err != nil {
+			return err
+		}
+
+		_, _, err = shared.VerifyBackupTarball(backup, false)
+		backup.Close()
+		if err != nil {
+			return err
+		}
+
+		return containerBackupRestore(d, f.Name(), name, pool)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(operationClassTask, resources, nil, run, nil, nil)
+	if err != nil {
+		os.Remove(f.Name())
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "gopkg.in/inconshreveable/log15.v2"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+)
+
+/* Stored backups live wherever backups.target points to, a local directory
+ * (${LXD_DIR}/backups/containers if unset), a custom storage volume or an S3
+ * bucket, as <container>/<backup>.tar.gz. Removing a container keeps its
+ * backups around.
+ */
+
+// Serializes backup creation and pruning between the API and the scheduler
+var containerBackupsLock sync.Mutex
+
+// Number of backups kept when backups.retention isn't set
+const containerBackupsDefaultRetention = 7
+
+func daemonConfigValidateBackupsTarget(d *Daemon, key string, value string) error {
+	if value == "" || value == "s3" {
+		return nil
+	}
+
+	if filepath.IsAbs(value) {
+		if !shared.IsDir(value) {
+			return fmt.Errorf("Backup target %s isn't a directory", value)
+		}
+
+		return nil
+	}
+
+	fields := strings.SplitN(value, "/", 2)
+	if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+		return fmt.Errorf("Backup target must be an absolute path, <pool>/<volume> or s3")
+	}
+
+	poolID, err := dbStoragePoolGetID(d.db, fields[0])
+	if err != nil {
+		return fmt.Errorf("Storage pool %s not found", fields[0])
+	}
+
+	_, err = dbStoragePoolVolumeGetTypeID(d.db, fields[1], storagePoolVolumeTypeCustom, poolID)
+	if err != nil {
+		return fmt.Errorf("Storage volume %s not found in pool %s", fields[1], fields[0])
+	}
+
+	return nil
+}
+
+// backupTarget is where stored container backups are kept.
+type backupTarget interface {
+	// List returns the backups of a container, in no particular order
+	List(container string) ([]api.ContainerBackup, error)
+
+	// Store saves a new backup whose content is produced by write
+	Store(container string, name string, write func(w io.Writer) error) error
+
+	Open(container string, name string) (io.ReadCloser, error)
+	Delete(container string, name string) error
+}
+
+func backupTargetLoad(d *Daemon) (backupTarget, error) {
+	target := daemonConfig["backups.target"].Get()
+	if target == "" {
+		return &backupTargetDir{path: shared.VarPath("backups", "containers")}, nil
+	}
+
+	if target == "s3" {
+		return backupTargetS3Load(d)
+	}
+
+	if filepath.IsAbs(target) {
+		return &backupTargetDir{path: target}, nil
+	}
+
+	fields := strings.SplitN(target, "/", 2)
+	s, err := storagePoolVolumeInit(d, fields[0], fields[1], storagePoolVolumeTypeCustom)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.StoragePoolVolumeMount()
+	if err != nil {
+		return nil, err
+	}
+
+	return &backupTargetDir{path: getStoragePoolVolumeMountPoint(fields[0], fields[1])}, nil
+}
+
+// backupTargetDir stores backups as <path>/<container>/<backup>.tar.gz
+type backupTargetDir struct {
+	path string
+}
+
+func (t *backupTargetDir) List(container string) ([]api.ContainerBackup, error) {
+	backups := []api.ContainerBackup{}
+
+	ents, err := ioutil.ReadDir(filepath.Join(t.path, container))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return backups, nil
+		}
+
+		return nil, err
+	}
+
+	for _, ent := range ents {
+		if ent.IsDir() || strings.HasPrefix(ent.Name(), ".") || !strings.HasSuffix(ent.Name(), ".tar.gz") {
+			continue
+		}
+
+		backups = append(backups, api.ContainerBackup{
+			Name:         strings.TrimSuffix(ent.Name(), ".tar.gz"),
+			CreationDate: ent.ModTime(),
+			Size:         ent.Size(),
+		})
+	}
+
+	return backups, nil
+}
+
+func (t *backupTargetDir) Store(container string, name string, write func(w io.Writer) error) error {
+	dir := filepath.Join(t.path, container)
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	// Write to a hidden file so partial backups never get listed
+	tmpPath := filepath.Join(dir, fmt.Sprintf(".%s.tar.gz.partial", name))
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = write(f)
+	f.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	err = os.Rename(tmpPath, filepath.Join(dir, fmt.Sprintf("%s.tar.gz", name)))
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+func (t *backupTargetDir) Open(container string, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(t.path, container, fmt.Sprintf("%s.tar.gz", name)))
+}
+
+func (t *backupTargetDir) Delete(container string, name string) error {
+	return os.Remove(filepath.Join(t.path, container, fmt.Sprintf("%s.tar.gz", name)))
+}
+
+type containerBackupsByDate []api.ContainerBackup
+
+func (a containerBackupsByDate) Len() int {
+	return len(a)
+}
+
+func (a containerBackupsByDate) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+func (a containerBackupsByDate) Less(i, j int) bool {
+	return a[i].CreationDate.Before(a[j].CreationDate)
+}
+
+// containerBackupsList returns the stored backups of a container, oldest first.
+func containerBackupsList(target backupTarget, name string) ([]api.ContainerBackup, error) {
+	backups, err := target.List(name)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(containerBackupsByDate(backups))
+
+	return backups, nil
+}
+
+// containerBackupGetInfo returns the given stored backup or a NoSuchObjectError.
+func containerBackupGetInfo(target backupTarget, name string, backupName string) (*api.ContainerBackup, error) {
+	backups, err := target.List(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, backup := range backups {
+		if backup.Name == backupName {
+			return &backup, nil
+		}
+	}
+
+	return nil, NoSuchObjectError
+}
+
+func containerBackupValidName(backupName string) error {
+	if backupName == "" || strings.Contains(backupName, "/") || strings.HasPrefix(backupName, ".") {
+		return fmt.Errorf("Invalid backup name: %s", backupName)
+	}
+
+	return nil
+}
+
+// containerBackupCreate stores a new backup of the container and prunes the
+// oldest ones according to backups.retention.
+func containerBackupCreate(d *Daemon, c container, backupName string, optimized bool) error {
+	containerBackupsLock.Lock()
+	defer containerBackupsLock.Unlock()
+
+	if backupName == "" {
+		backupName = fmt.Sprintf("backup-%s", time.Now().UTC().Format("20060102-150405"))
+	}
+
+	err := containerBackupValidName(backupName)
+	if err != nil {
+		return err
+	}
+
+	target, err := backupTargetLoad(d)
+	if err != nil {
+		return err
+	}
+
+	_, err = containerBackupGetInfo(target, c.Name(), backupName)
+	if err == nil {
+		return fmt.Errorf("Backup %s already exists", backupName)
+	} else if err != NoSuchObjectError {
+		return err
+	}
+
+	passphrase := daemonConfig["backups.encryption_passphrase"].Get()
+	err = target.Store(c.Name(), backupName, func(w io.Writer) error {
+		if passphrase == "" {
+			return containerBackupWrite(c, w, optimized)
+		}
+
+		ew, err := shared.NewEncryptWriter(w, passphrase)
+		if err != nil {
+			return err
+		}
+
+		err = containerBackupWrite(c, ew, optimized)
+		if err != nil {
+			return err
+		}
+
+		return ew.Close()
+	})
+	if err != nil {
+		return err
+	}
+
+	return containerBackupsPrune(target, c)
+}
+
+func containerBackupsPrune(target backupTarget, c container) error {
+	retention := containerBackupsDefaultRetention
+	value := c.ExpandedConfig()["backups.retention"]
+	if value != "" {
+		retention, _ = strconv.Atoi(value)
+	}
+
+	// A retention of 0 keeps everything
+	if retention <= 0 {
+		return nil
+	}
+
+	backups, err := containerBackupsList(target, c.Name())
+	if err != nil {
+		return err
+	}
+
+	for len(backups) > retention {
+		err := target.Delete(c.Name(), backups[0].Name)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Pruned container backup", log.Ctx{"container": c.Name(), "backup": backups[0].Name})
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// containersBackupSchedule backs up every container whose backups.schedule
+// interval has elapsed since its most recent backup.
+func containersBackupSchedule(d *Daemon) {
+	names, err := dbContainersList(d.db, cTypeRegular)
+	if err != nil {
+		logger.Error("Failed to list containers for scheduled backups", log.Ctx{"err": err})
+		return
+	}
+
+	var target backupTarget
+	for _, name := range names {
+		c, err := containerLoadByName(d, name)
+		if err != nil {
+			continue
+		}
+
+		interval, err := shared.BackupScheduleInterval(c.ExpandedConfig()["backups.schedule"])
+		if err != nil || interval == 0 {
+			continue
+		}
+
+		if target == nil {
+			target, err = backupTargetLoad(d)
+			if err != nil {
+				logger.Error("Failed to load the backup target", log.Ctx{"err": err})
+				return
+			}
+		}
+
+		backups, err := containerBackupsList(target, name)
+		if err != nil {
+			logger.Error("Failed to list container backups", log.Ctx{"container": name, "err": err})
+			continue
+		}
+
+		if len(backups) > 0 && time.Since(backups[len(backups)-1].CreationDate) < interval {
+			continue
+		}
+
+		logger.Info("Running scheduled container backup", log.Ctx{"container": name})
+		err = containerBackupCreate(d, c, "", shared.IsTrue(c.ExpandedConfig()["backups.optimized_storage"]))
+		if err != nil {
+			logger.Error("Failed scheduled container backup", log.Ctx{"container": name, "err": err})
+		}
+	}
+}
+
+func containerBackupsGet(d *Daemon, r *http.Request) Response {
+	recursion, err := strconv.Atoi(r.FormValue("recursion"))
+	if err != nil {
+		recursion = 0
+	}
+
+	name := mux.Vars(r)["name"]
+
+	target, err := backupTargetLoad(d)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	backups, err := containerBackupsList(target, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if recursion == 0 {
+		urls := []string{}
+		for _, backup := range backups {
+			urls = append(urls, fmt.Sprintf("/%s/containers/%s/backups/%s", version.APIVersion, name, backup.Name))
+		}
+
+		return SyncResponse(true, urls)
+	}
+
+	return SyncResponse(true, backups)
+}
+
+func containerBackupsPost(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	c, err := containerLoadByName(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	req := api.ContainerBackupsPost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Name != "" {
+		err = containerBackupValidName(req.Name)
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
+	run := func(op *operation) error {
+		return containerBackupCreate(d, c, req.Name, req.OptimizedStorage)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(operationClassTask, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+var containerBackupsCmd = Command{name: "containers/{name}/backups", get: containerBackupsGet, post: containerBackupsPost}
+
+func containerBackupGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	backupName := mux.Vars(r)["backupName"]
+
+	target, err := backupTargetLoad(d)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	backup, err := containerBackupGetInfo(target, name, backupName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, backup)
+}
+
+func containerBackupDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	backupName := mux.Vars(r)["backupName"]
+
+	containerBackupsLock.Lock()
+	defer containerBackupsLock.Unlock()
+
+	target, err := backupTargetLoad(d)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	_, err = containerBackupGetInfo(target, name, backupName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = target.Delete(name, backupName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+var containerBackupCmd = Command{name: "containers/{name}/backups/{backupName}", get: containerBackupGet, delete: containerBackupDelete}
+
+func containerBackupExportGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	backupName := mux.Vars(r)["backupName"]
+
+	target, err := backupTargetLoad(d)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	backup, err := containerBackupGetInfo(target, name, backupName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	reader, err := target.Open(name, backupName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return StreamResponse(reader, fmt.Sprintf("%s.tar.gz", backupName), backup.Size)
+}
+
+var containerBackupExportCmd = Command{name: "containers/{name}/backups/{backupName}/export", get: containerBackupExportGet}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "gopkg.in/inconshreveable/log15.v2"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/logging"
+)
+
+const consoleForwardInterval = 2 * time.Second
+
+// Maximum amount of console output forwarded per container and interval,
+// the rest is sent on the next rounds.
+const consoleForwardChunk = 64 * 1024
+
+// State of the forwarding of console logs to core.syslog_address: the offset
+// up to which each running container's log was sent and the current writer.
+// The writer sends from its own goroutine, the lock is never held while
+// connecting.
+var consoleForwardLock sync.Mutex
+var consoleForwardOffsets = map[string]int64{}
+var consoleForwardWriter logging.TargetWriter
+var consoleForwardAddress string
+
+func consoleLogFilePath(name string) string {
+	return shared.LogPath(name, "console.log")
+}
+
+// consoleLogMaxSize returns the size past which the console log of a
+// container gets rotated, 0 meaning never.
+func consoleLogMaxSize(config map[string]string) (int64, error) {
+	value := config["console.log_size"]
+	if value == "" {
+		value = "1MB"
+	}
+
+	return shared.ParseByteSizeString(value)
+}
+
+// consoleLogRotate moves the console log out of the way once it grew past
+// maxSize, keeping a single previous generation around.
+func consoleLogRotate(path string, maxSize int64) error {
+	if maxSize <= 0 {
+		return nil
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if fi.Size() < maxSize {
+		return nil
+	}
+
+	return os.Rename(path, path+".1")
+}
+
+func containerConsoleLogGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	c, err := containerLoadByName(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	path := consoleLogFilePath(c.Name())
+	if !shared.PathExists(path) {
+		// Nothing got logged yet
+		ent := fileResponseEntry{
+			buffer:   []byte{},
+			filename: filepath.Base(path),
+		}
+
+		return FileResponse(r, []fileResponseEntry{ent}, nil, false)
+	}
+
+	ent := fileResponseEntry{
+		path:     path,
+		filename: filepath.Base(path),
+	}
+
+	return FileResponse(r, []fileResponseEntry{ent}, nil, false)
+}
+
+func containerConsoleLogDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	c, err := containerLoadByName(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	path := consoleLogFilePath(c.Name())
+
+	// Drop the previous generation
+	err = os.Remove(path + ".1")
+	if err != nil && !os.IsNotExist(err) {
+		return InternalError(err)
+	}
+
+	// Truncate rather than remove as liblxc may still be writing to it
+	err = os.Truncate(path, 0)
+	if err != nil && !os.IsNotExist(err) {
+		return InternalError(fmt.Errorf("Failed to clear the console log: %s", err))
+	}
+
+	return EmptySyncResponse
+}
+
+var containerConsoleCmd = Command{
+	name:   "containers/{name}/console",
+	get:    containerConsoleLogGet,
+	delete: containerConsoleLogDelete,
+}
+
+// consoleForwardReset is called when a container starts, after its console
+// log was rotated, so that its whole output gets forwarded.
+func consoleForwardReset(name string) {
+	var offset int64
+	fi, err := os.Stat(consoleLogFilePath(name))
+	if err == nil {
+		offset = fi.Size()
+	}
+
+	consoleForwardLock.Lock()
+	consoleForwardOffsets[name] = offset
+	consoleForwardLock.Unlock()
+}
+
+// consoleForwardRead returns the complete lines of the file past offset (up
+// to consoleForwardChunk) and the offset following them.
+func consoleForwardRead(path string, offset int64) ([]string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, offset, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, offset, err
+	}
+
+	// The log got rotated
+	if fi.Size() < offset {
+		offset = 0
+	}
+
+	buf := make([]byte, consoleForwardChunk)
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, offset, err
+	}
+	buf = buf[:n]
+
+	// Keep partial lines for later, unless they fill the whole chunk
+	end := bytes.LastIndexByte(buf, '\n') + 1
+	if end == 0 && n == consoleForwardChunk {
+		end = n
+	}
+
+	lines := []string{}
+	for _, line := range strings.Split(string(buf[:end]), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, offset + int64(end), nil
+}
+
+// containersConsoleForward sends the new console output of the running
+// containers to core.syslog_address when core.log_console is set.
+func containersConsoleForward(d *Daemon) {
+	address := daemonConfig["core.syslog_address"].Get()
+	enabled := daemonConfig["core.log_console"].GetBool() && address != ""
+
+	consoleForwardLock.Lock()
+	if consoleForwardWriter != nil && (!enabled || consoleForwardAddress != address) {
+		consoleForwardWriter.Close()
+		consoleForwardWriter = nil
+	}
+
+	if !enabled {
+		// Only new output gets sent once enabled again
+		consoleForwardOffsets = map[string]int64{}
+	}
+
+	writer := consoleForwardWriter
+	consoleForwardLock.Unlock()
+
+	if !enabled {
+		return
+	}
+
+	if writer == nil {
+		var err error
+		writer, err = logging.NewTargetWriter(address, "lxd-console")
+		if err != nil {
+			logger.Debug("Failed to connect to the syslog address for console forwarding", log.Ctx{"address": address, "err": err})
+			return
+		}
+
+		consoleForwardLock.Lock()
+		consoleForwardWriter = writer
+		consoleForwardAddress = address
+		consoleForwardLock.Unlock()
+	}
+
+	names, err := dbContainersList(d.db, cTypeRegular)
+	if err != nil {
+		logger.Error("Failed to list containers for console forwarding", log.Ctx{"err": err})
+		return
+	}
+
+	running := map[string]bool{}
+	for _, name := range names {
+		c, err := containerLoadByName(d, name)
+		if err == nil && c.IsRunning() {
+			running[name] = true
+		}
+	}
+
+	messages := []string{}
+
+	consoleForwardLock.Lock()
+	for _, name := range names {
+		if !running[name] {
+			delete(consoleForwardOffsets, name)
+			continue
+		}
+
+		path := consoleLogFilePath(name)
+		offset, ok := consoleForwardOffsets[name]
+		if !ok {
+			// Containers already running when forwarding got enabled
+			// only get their new output sent.
+			fi, err := os.Stat(path)
+			if err == nil {
+				consoleForwardOffsets[name] = fi.Size()
+			}
+
+			continue
+		}
+
+		lines, offset, err := consoleForwardRead(path, offset)
+		if err != nil {
+			continue
+		}
+		consoleForwardOffsets[name] = offset
+
+		for _, line := range lines {
+			messages = append(messages, fmt.Sprintf("%s: %s", name, line))
+		}
+	}
+	consoleForwardLock.Unlock()
+
+	for _, msg := range messages {
+		err := writer.Info(msg)
+		if err != nil {
+			logger.Debug("Failed to forward console output", log.Ctx{"err": err})
+			break
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestConsoleForwardRead(t *testing.T) {
+	f, err := ioutil.TempFile("", "lxd_console_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString("first line\r\nsecond line\npartial")
+	f.Close()
+
+	// Partial lines are kept for later
+	lines, offset, err := consoleForwardRead(f.Name(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(lines, []string{"first line", "second line"}) || offset != 24 {
+		t.Fatalf("Unexpected lines %v up to %d", lines, offset)
+	}
+
+	lines, offset, err = consoleForwardRead(f.Name(), offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lines) != 0 || offset != 24 {
+		t.Fatalf("Unexpected lines %v up to %d", lines, offset)
+	}
+
+	// The log got rotated and is shorter than the offset
+	err = ioutil.WriteFile(f.Name(), []byte("boot\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines, offset, err = consoleForwardRead(f.Name(), 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(lines, []string{"boot"}) || offset != 5 {
+		t.Fatalf("Unexpected lines %v up to %d", lines, offset)
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lxc/lxd/shared"
+)
+
+/* Disk image exports turn a container into something a VM or a cloud can
+ * boot: a DOS partitioned disk holding a single ext4 partition (labelled
+ * "rootfs") with the container's files, unshifted, and grub installed on
+ * the disk's MBR.
+ *
+ * The container must ship its own kernel in /boot, the "export.kernel_cmdline"
+ * configuration key then sets the extra kernel arguments.
+ */
+
+// containerDiskImagesPath returns where the disk images of a container built
+// by export operations are kept until they get downloaded.
+func containerDiskImagesPath(name string) string {
+	return shared.VarPath("backups", "disks", name)
+}
+
+// containerDiskImagesMaxAge is how long a disk image which never got
+// downloaded is kept.
+const containerDiskImagesMaxAge = 24 * time.Hour
+
+// containerDiskImageFormats are the valid values of the export "format"
+// argument producing a disk image, with their file extension.
+var containerDiskImageFormats = map[string]string{
+	"raw":   ".img",
+	"qcow2": ".qcow2",
+}
+
+// containerDiskImagesPrune removes the disk images nobody downloaded.
+func containerDiskImagesPrune() {
+	paths, err := filepath.Glob(filepath.Join(shared.VarPath("backups", "disks"), "*", "*"))
+	if err != nil {
+		return
+	}
+
+	for _, path := range paths {
+		fi, err := os.Lstat(path)
+		if err == nil && time.Since(fi.ModTime()) > containerDiskImagesMaxAge {
+			os.Remove(path)
+		}
+	}
+}
+
+// containerDiskImageVersionLess compares kernel versions, numeric parts
+// being compared as numbers so that 4.4.0-101 comes after 4.4.0-21.
+func containerDiskImageVersionLess(a string, b string) bool {
+	split := func(version string) []string {
+		parts := []string{}
+		for len(version) > 0 {
+			digit := version[0] >= '0' && version[0] <= '9'
+
+			i := 1
+			for i < len(version) && (version[i] >= '0' && version[i] <= '9') == digit {
+				i++
+			}
+
+			parts = append(parts, version[:i])
+			version = version[i:]
+		}
+
+		return parts
+	}
+
+	aParts := split(a)
+	bParts := split(b)
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		if aParts[i] == bParts[i] {
+			continue
+		}
+
+		aInt, aErr := strconv.ParseUint(aParts[i], 10, 64)
+		bInt, bErr := strconv.ParseUint(bParts[i], 10, 64)
+		if aErr == nil && bErr == nil && aInt != bInt {
+			return aInt < bInt
+		}
+
+		return aParts[i] < bParts[i]
+	}
+
+	return len(aParts) < len(bParts)
+}
+
+type containerDiskImageKernels []string
+
+func (s containerDiskImageKernels) Len() int {
+	return len(s)
+}
+
+func (s containerDiskImageKernels) Less(i, j int) bool {
+	return containerDiskImageVersionLess(s[i], s[j])
+}
+
+func (s containerDiskImageKernels) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// containerDiskImageRegular returns whether the path, relative to root, is a
+// regular file reached without following any symlink.
+func containerDiskImageRegular(root string, path string) bool {
+	err := containerDiskImageDir(root, filepath.Dir(path))
+	if err != nil {
+		return false
+	}
+
+	fi, err := os.Lstat(filepath.Join(root, path))
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// containerDiskImageDir checks that the path, relative to root, is a
+// directory reached without following any symlink, the container's files
+// being able to point anywhere on the host.
+func containerDiskImageDir(root string, path string) error {
+	current := root
+	for _, name := range strings.Split(strings.Trim(filepath.Clean("/"+path), "/"), "/") {
+		if name == "" {
+			continue
+		}
+
+		current = filepath.Join(current, name)
+		fi, err := os.Lstat(current)
+		if err != nil {
+			return err
+		}
+
+		if !fi.IsDir() {
+			return fmt.Errorf("/%s isn't a directory", path)
+		}
+	}
+
+	return nil
+}
+
+// containerDiskImageWriteFile writes a file, relative to root, replacing
+// whatever was there without following symlinks.
+func containerDiskImageWriteFile(root string, path string, content []byte) error {
+	err := containerDiskImageDir(root, filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	fullPath := filepath.Join(root, path)
+	fi, err := os.Lstat(fullPath)
+	if err == nil && !fi.Mode().IsRegular() {
+		err = os.Remove(fullPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(content)
+	return err
+}
+
+// containerDiskImageKernel returns the newest kernel and matching initrd (if
+// any) found in the container's /boot, relative to its root. Only regular
+// files are considered.
+func containerDiskImageKernel(rootfs string) (string, string, error) {
+	err := containerDiskImageDir(rootfs, "/boot")
+	if err != nil {
+		return "", "", fmt.Errorf("No kernel found in /boot, one must be installed in the container first")
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(rootfs, "boot"))
+	if err != nil {
+		return "", "", err
+	}
+
+	kernels := []string{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "vmlinuz-") && entry.Mode().IsRegular() {
+			kernels = append(kernels, strings.TrimPrefix(entry.Name(), "vmlinuz-"))
+		}
+	}
+
+	if len(kernels) == 0 {
+		return "", "", fmt.Errorf("No kernel found in /boot, one must be installed in the container first")
+	}
+
+	sort.Sort(containerDiskImageKernels(kernels))
+	version := kernels[len(kernels)-1]
+
+	initrd := ""
+	for _, name := range []string{"initrd.img-" + version, "initramfs-" + version + ".img"} {
+		if containerDiskImageRegular(rootfs, filepath.Join("/boot", name)) {
+			initrd = filepath.Join("/boot", name)
+			break
+		}
+	}
+
+	return filepath.Join("/boot", "vmlinuz-"+version), initrd, nil
+}
+
+// containerDiskImageSize returns the size of the disk needed to hold the
+// given root filesystem, leaving some room to grow.
+func containerDiskImageSize(rootfs string) (int64, error) {
+	output, err := shared.RunCommand("du", "-sxb", rootfs)
+	if err != nil {
+		return -1, err
+	}
+
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return -1, fmt.Errorf("Unable to parse the disk usage of %s", rootfs)
+	}
+
+	usage, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return -1, err
+	}
+
+	// 20% for filesystem overhead and growth, plus 512MB and the
+	// partition table, rounded up to the MB.
+	size := usage + usage/5 + 513*1024*1024
+	return (size/(1024*1024) + 1) * 1024 * 1024, nil
+}
+
+// containerDiskImageWrite writes a bootable disk image of the stopped
+// container to the given path, in raw or qcow2 format.
+func containerDiskImageWrite(c container, path string, format string) error {
+	_, ok := containerDiskImageFormats[format]
+	if !ok {
+		return fmt.Errorf("Invalid disk image format: %s", format)
+	}
+
+	if c.IsRunning() {
+		return fmt.Errorf("The container must be stopped to be exported as a disk image")
+	}
+
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	rootfs := c.RootfsPath()
+	kernel, initrd, err := containerDiskImageKernel(rootfs)
+	if err != nil {
+		return err
+	}
+
+	size, err := containerDiskImageSize(rootfs)
+	if err != nil {
+		return err
+	}
+
+	// qcow2 images are converted from a raw one
+	rawPath := path
+	if format != "raw" {
+		rawPath = path + ".raw"
+		defer os.Remove(rawPath)
+	}
+
+	f, err := os.Create(rawPath)
+	if err != nil {
+		return err
+	}
+
+	err = f.Truncate(size)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	// A single bootable partition spanning the disk
+	cmd := exec.Command("sfdisk", "--quiet", rawPath)
+	cmd.Stdin = strings.NewReader("label: dos\nstart=2048, type=83, bootable\n")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to partition the disk image: %s", strings.TrimSpace(string(output)))
+	}
+
+	output, err = exec.Command("losetup", "--show", "-f", "-P", rawPath).Output()
+	if err != nil {
+		return fmt.Errorf("Failed to setup a loop device for the disk image: %s", err)
+	}
+
+	loopDev := strings.TrimSpace(string(output))
+	defer shared.RunCommand("losetup", "-d", loopDev)
+
+	partDev := loopDev + "p1"
+	_, err = shared.TryRunCommand("mkfs.ext4", "-q", "-L", "rootfs", partDev)
+	if err != nil {
+		return err
+	}
+
+	mntPath, err := ioutil.TempDir(shared.VarPath("backups"), "lxd_disk_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(mntPath)
+
+	err = tryMount(partDev, mntPath, "ext4", 0, "")
+	if err != nil {
+		return err
+	}
+	mounted := true
+	defer func() {
+		if mounted {
+			tryUnmount(mntPath, syscall.MNT_DETACH)
+		}
+	}()
+
+	output, err = exec.Command("rsync", "-aHAX", "--sparse", "--devices", "--numeric-ids", shared.AddSlash(rootfs), mntPath).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to copy the container's files: %s", strings.TrimSpace(string(output)))
+	}
+
+	// Files of unprivileged containers are stored shifted
+	idmapset, err := c.LastIdmapSet()
+	if err != nil {
+		return err
+	}
+
+	if idmapset != nil {
+		err = idmapset.UnshiftRootfs(mntPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The copy must still be booting the same files, with nothing in the
+	// way pointing outside of the image
+	for _, path := range []string{kernel, initrd} {
+		if path != "" && !containerDiskImageRegular(mntPath, path) {
+			return fmt.Errorf("%s isn't a regular file", path)
+		}
+	}
+
+	err = containerDiskImageWriteFile(mntPath, "/etc/fstab", []byte("LABEL=rootfs / ext4 defaults 0 1\n"))
+	if err != nil {
+		return err
+	}
+
+	// grub gets a fresh directory of its own
+	err = os.RemoveAll(filepath.Join(mntPath, "boot", "grub"))
+	if err != nil {
+		return err
+	}
+
+	err = os.Mkdir(filepath.Join(mntPath, "boot", "grub"), 0755)
+	if err != nil {
+		return err
+	}
+
+	_, err = shared.RunCommand("grub-install", "--target=i386-pc", fmt.Sprintf("--boot-directory=%s", filepath.Join(mntPath, "boot")), loopDev)
+	if err != nil {
+		return err
+	}
+
+	cmdline := strings.TrimSpace(fmt.Sprintf("root=LABEL=rootfs ro console=tty0 console=ttyS0 %s", c.ExpandedConfig()["export.kernel_cmdline"]))
+	grubCfg := fmt.Sprintf(`set timeout=1
+menuentry "%s" {
+	linux %s %s
+`, c.Name(), kernel, cmdline)
+	if initrd != "" {
+		grubCfg += fmt.Sprintf("\tinitrd %s\n", initrd)
+	}
+	grubCfg += "}\n"
+
+	err = containerDiskImageWriteFile(mntPath, "/boot/grub/grub.cfg", []byte(grubCfg))
+	if err != nil {
+		return err
+	}
+
+	err = tryUnmount(mntPath, 0)
+	if err != nil {
+		return err
+	}
+	mounted = false
+
+	if format != "raw" {
+		_, err = shared.RunCommand("qemu-img", "convert", "-f", "raw", "-O", format, rawPath, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestContainerDiskImageKernel(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "lxd_test_disk_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootfs)
+
+	_, _, err = containerDiskImageKernel(rootfs)
+	if err == nil {
+		t.Fatal("No error without a kernel")
+	}
+
+	err = os.MkdirAll(filepath.Join(rootfs, "boot"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"vmlinuz-4.4.0-21-generic", "vmlinuz-4.4.0-101-generic", "vmlinuz-4.4.0-92-generic", "initrd.img-4.4.0-101-generic"} {
+		err = ioutil.WriteFile(filepath.Join(rootfs, "boot", name), []byte{}, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kernel, initrd, err := containerDiskImageKernel(rootfs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if kernel != "/boot/vmlinuz-4.4.0-101-generic" {
+		t.Errorf("Wrong kernel: %s", kernel)
+	}
+
+	if initrd != "/boot/initrd.img-4.4.0-101-generic" {
+		t.Errorf("Wrong initrd: %s", initrd)
+	}
+
+	// Symlinks are never followed, they could point anywhere on the host
+	err = os.Symlink("/etc/passwd", filepath.Join(rootfs, "boot", "vmlinuz-5.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kernel, _, err = containerDiskImageKernel(rootfs)
+	if err != nil || kernel != "/boot/vmlinuz-4.4.0-101-generic" {
+		t.Errorf("Symlinked kernel wasn't skipped: %s (%v)", kernel, err)
+	}
+
+	err = os.Rename(filepath.Join(rootfs, "boot"), filepath.Join(rootfs, "realboot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Symlink("realboot", filepath.Join(rootfs, "boot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = containerDiskImageKernel(rootfs)
+	if err == nil {
+		t.Errorf("Symlinked /boot was followed")
+	}
+}
+
+func TestContainerDiskImageVersionLess(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		less bool
+	}{
+		{"4.4.0-21-generic", "4.4.0-101-generic", true},
+		{"4.4.0-101-generic", "4.4.0-21-generic", false},
+		{"4.9.0", "4.15.0", true},
+		{"4.15.0", "4.9.0", false},
+		{"4.15.0", "4.15.0-1", true},
+		{"4.15.0-20-generic", "4.15.0-20-lowlatency", true},
+	}
+
+	for _, test := range tests {
+		less := containerDiskImageVersionLess(test.a, test.b)
+		if less != test.less {
+			t.Errorf("Expected %v comparing %s with %s, got %v", test.less, test.a, test.b, less)
+		}
+	}
+}
+
+func TestContainerDiskImageWriteFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "lxd_test_disk_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	target := filepath.Join(root, "target")
+	err = ioutil.WriteFile(target, []byte("host"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.MkdirAll(filepath.Join(root, "mnt", "etc"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Symlink(target, filepath.Join(root, "mnt", "etc", "fstab"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = containerDiskImageWriteFile(filepath.Join(root, "mnt"), "/etc/fstab", []byte("image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(target)
+	if err != nil || string(content) != "host" {
+		t.Errorf("The symlink target got overwritten: %q", content)
+	}
+
+	// Symlinked directories are refused
+	err = os.Symlink(root, filepath.Join(root, "mnt", "boot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = containerDiskImageWriteFile(filepath.Join(root, "mnt"), "/boot/target", []byte("image"))
+	if err == nil {
+		t.Errorf("Wrote through a symlinked directory")
+	}
+}
@@ -138,90 +138,100 @@ func (s *execWs) Do(op *operation) error {
 	attachedChildIsDead := make(chan bool, 1)
 	var wgEOF sync.WaitGroup
 
-	if s.interactive {
-		wgEOF.Add(1)
-		go func() {
-			attachedChildPid := <-attachedChildIsBorn
-			select {
-			case <-s.controlConnected:
-				break
+	// The control socket is used for signals in both modes, window sizes
+	// only making sense with a pty
+	go func() {
+		var attachedChildPid int
+		select {
+		case attachedChildPid = <-attachedChildIsBorn:
+			break
+
+		case <-controlExit:
+			return
+		}
 
-			case <-controlExit:
-				return
+		select {
+		case <-s.controlConnected:
+			break
+
+		case <-controlExit:
+			return
+		}
+
+		for {
+			s.connsLock.Lock()
+			conn := s.conns[-1]
+			s.connsLock.Unlock()
+
+			mt, r, err := conn.NextReader()
+			if mt == websocket.CloseMessage {
+				break
 			}
 
-			for {
-				s.connsLock.Lock()
-				conn := s.conns[-1]
-				s.connsLock.Unlock()
+			if err != nil {
+				logger.Debugf("Got error getting next reader %s", err)
+				er, ok := err.(*websocket.CloseError)
+				if !ok {
+					break
+				}
 
-				mt, r, err := conn.NextReader()
-				if mt == websocket.CloseMessage {
+				if er.Code != websocket.CloseAbnormalClosure {
 					break
 				}
 
+				// If an abnormal closure occurred, kill the attached process.
+				err := syscall.Kill(attachedChildPid, syscall.SIGKILL)
 				if err != nil {
-					logger.Debugf("Got error getting next reader %s", err)
-					er, ok := err.(*websocket.CloseError)
-					if !ok {
-						break
-					}
-
-					if er.Code != websocket.CloseAbnormalClosure {
-						break
-					}
-
-					// If an abnormal closure occurred, kill the attached process.
-					err := syscall.Kill(attachedChildPid, syscall.SIGKILL)
-					if err != nil {
-						logger.Debugf("Failed to send SIGKILL to pid %d.", attachedChildPid)
-					} else {
-						logger.Debugf("Sent SIGKILL to pid %d.", attachedChildPid)
-					}
-					return
+					logger.Debugf("Failed to send SIGKILL to pid %d.", attachedChildPid)
+				} else {
+					logger.Debugf("Sent SIGKILL to pid %d.", attachedChildPid)
 				}
+				return
+			}
 
-				buf, err := ioutil.ReadAll(r)
+			buf, err := ioutil.ReadAll(r)
+			if err != nil {
+				logger.Debugf("Failed to read message %s", err)
+				break
+			}
+
+			command := api.ContainerExecControl{}
+
+			if err := json.Unmarshal(buf, &command); err != nil {
+				logger.Debugf("Failed to unmarshal control socket command: %s", err)
+				continue
+			}
+
+			if command.Command == "window-resize" && s.interactive {
+				winchWidth, err := strconv.Atoi(command.Args["width"])
 				if err != nil {
-					logger.Debugf("Failed to read message %s", err)
-					break
+					logger.Debugf("Unable to extract window width: %s", err)
+					continue
 				}
 
-				command := api.ContainerExecControl{}
-
-				if err := json.Unmarshal(buf, &command); err != nil {
-					logger.Debugf("Failed to unmarshal control socket command: %s", err)
+				winchHeight, err := strconv.Atoi(command.Args["height"])
+				if err != nil {
+					logger.Debugf("Unable to extract window height: %s", err)
 					continue
 				}
 
-				if command.Command == "window-resize" {
-					winchWidth, err := strconv.Atoi(command.Args["width"])
-					if err != nil {
-						logger.Debugf("Unable to extract window width: %s", err)
-						continue
-					}
-
-					winchHeight, err := strconv.Atoi(command.Args["height"])
-					if err != nil {
-						logger.Debugf("Unable to extract window height: %s", err)
-						continue
-					}
-
-					err = shared.SetSize(int(ptys[0].Fd()), winchWidth, winchHeight)
-					if err != nil {
-						logger.Debugf("Failed to set window size to: %dx%d", winchWidth, winchHeight)
-						continue
-					}
-				} else if command.Command == "signal" {
-					if err := syscall.Kill(attachedChildPid, syscall.Signal(command.Signal)); err != nil {
-						logger.Debugf("Failed forwarding signal '%s' to PID %d.", command.Signal, attachedChildPid)
-						continue
-					}
-					logger.Debugf("Forwarded signal '%d' to PID %d.", command.Signal, attachedChildPid)
+				err = shared.SetSize(int(ptys[0].Fd()), winchWidth, winchHeight)
+				if err != nil {
+					logger.Debugf("Failed to set window size to: %dx%d", winchWidth, winchHeight)
+					continue
 				}
+			} else if command.Command == "signal" {
+				if err := syscall.Kill(attachedChildPid, syscall.Signal(command.Signal)); err != nil {
+					logger.Debugf("Failed forwarding signal '%s' to PID %d.", command.Signal, attachedChildPid)
+					continue
+				}
+				logger.Debugf("Forwarded signal '%d' to PID %d.", command.Signal, attachedChildPid)
 			}
-		}()
+		}
+	}()
 
+	if s.interactive {
+		wgEOF.Add(1)
 		go func() {
 			s.connsLock.Lock()
 			conn := s.conns[0]
@@ -271,14 +281,13 @@ func (s *execWs) Do(op *operation) error {
 		conn := s.conns[-1]
 		s.connsLock.Unlock()
 
-		if conn == nil {
-			if s.interactive {
-				controlExit <- true
-			}
-		} else {
+		if conn != nil {
 			conn.Close()
 		}
 
+		// Also releases the control goroutine when the command never started
+		close(controlExit)
+
 		attachedChildIsDead <- true
 
 		wgEOF.Wait()
@@ -298,12 +307,10 @@ func (s *execWs) Do(op *operation) error {
 
 	cmd, _, attachedPid, err := s.container.Exec(s.command, s.env, stdin, stdout, stderr, false)
 	if err != nil {
-		return err
+		return finisher(-1, err)
 	}
 
-	if s.interactive {
-		attachedChildIsBorn <- attachedPid
-	}
+	attachedChildIsBorn <- attachedPid
 
 	err = cmd.Wait()
 	if err == nil {
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -37,6 +36,25 @@ func containerFileHandler(d *Daemon, r *http.Request) Response {
 	}
 }
 
+// snapshotFileHandler serves files out of a snapshot, whose storage gets
+// mounted for the duration of the request.
+func snapshotFileHandler(d *Daemon, r *http.Request) Response {
+	containerName := mux.Vars(r)["name"]
+	snapshotName := mux.Vars(r)["snapshotName"]
+
+	sc, err := containerLoadByName(d, containerName+shared.SnapshotDelimiter+snapshotName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	path := r.FormValue("path")
+	if path == "" {
+		return BadRequest(fmt.Errorf("missing path argument"))
+	}
+
+	return containerFileGet(sc, path, r)
+}
+
 func containerFileGet(c container, path string, r *http.Request) Response {
 	/*
 	 * Copy out of the ns to a temporary file, and then use that to serve
@@ -101,7 +119,7 @@ func containerFilePut(c container, path string, r *http.Request) Response {
 			os.Remove(temp.Name())
 		}()
 
-		_, err = io.Copy(temp, r.Body)
+		_, err = shared.CopyFileData(temp, r.Body)
 		if err != nil {
 			return InternalError(err)
 		}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/shared/api"
 )
 
 func containerGet(d *Daemon, r *http.Request) Response {
@@ -18,5 +20,10 @@ func containerGet(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	ct, ok := state.(*api.Container)
+	if ok {
+		projectStripContainer(d, projectParam(r), ct)
+	}
+
 	return SyncResponseETag(true, state, etag)
 }
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	log "gopkg.in/inconshreveable/log15.v2"
+
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// containerHealthCheckInterval is how often the probed containers are looked
+// at for probes which are due, each container having its own
+// health.interval.
+const containerHealthCheckInterval = 5 * time.Second
+
+// Health of the running containers with a health probe
+const (
+	containerHealthStarting  = "starting"
+	containerHealthHealthy   = "healthy"
+	containerHealthUnhealthy = "unhealthy"
+)
+
+type containerHealthState struct {
+	status    string
+	failures  int
+	interval  time.Duration
+	lastProbe time.Time
+	probing   bool
+}
+
+// Health of the running containers with a health probe, the only ones being
+// looked at
+var containerHealthLock sync.Mutex
+var containerHealth = map[string]*containerHealthState{}
+
+// containerHealthReset is called when a container starts or its probe
+// changes, probes having to pass again before it's reported healthy.
+func containerHealthReset(c container) {
+	config := c.ExpandedConfig()
+	if config["health.probe"] == "" {
+		containerHealthForget(c.Name())
+		return
+	}
+
+	interval := time.Duration(containerHealthConfigInt(config, "health.interval", 30)) * time.Second
+
+	containerHealthLock.Lock()
+	containerHealth[c.Name()] = &containerHealthState{status: containerHealthStarting, interval: interval}
+	containerHealthLock.Unlock()
+}
+
+func containerHealthForget(name string) {
+	containerHealthLock.Lock()
+	delete(containerHealth, name)
+	containerHealthLock.Unlock()
+}
+
+// containerHealthStatus returns the health of a running container, empty if
+// it has no probe or none ran yet.
+func containerHealthStatus(c container) string {
+	if c.ExpandedConfig()["health.probe"] == "" {
+		return ""
+	}
+
+	containerHealthLock.Lock()
+	defer containerHealthLock.Unlock()
+
+	state, ok := containerHealth[c.Name()]
+	if !ok {
+		return ""
+	}
+
+	return state.status
+}
+
+// containerHealthWait waits for a container with a health probe to be
+// reported healthy, for its dependents to be started.
+func containerHealthWait(c container) error {
+	if c.ExpandedConfig()["health.probe"] == "" {
+		return nil
+	}
+
+	for {
+		switch containerHealthStatus(c) {
+		case containerHealthHealthy:
+			return nil
+		case containerHealthUnhealthy:
+			return fmt.Errorf("Container is unhealthy")
+		}
+
+		if !c.IsRunning() {
+			return fmt.Errorf("Container isn't running")
+		}
+
+		time.Sleep(time.Second)
+	}
+}
+
+// containerHealthConfigInt returns an integer health key, or its default.
+func containerHealthConfigInt(config map[string]string, key string, defaultValue int) int {
+	value, err := strconv.Atoi(config[key])
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+
+	return value
+}
+
+// containerHealthAddress returns the address the TCP and HTTP probes connect
+// to, the first global address of the container on the probed port.
+func containerHealthAddress(c container, port string) (string, error) {
+	networks := c.NetworkState()
+
+	for _, family := range []string{"inet", "inet6"} {
+		for name, network := range networks {
+			if name == "lo" {
+				continue
+			}
+
+			for _, address := range network.Addresses {
+				if address.Family == family && address.Scope == "global" {
+					return net.JoinHostPort(address.Address, port), nil
+				}
+			}
+		}
+	}
+
+	return "", fmt.Errorf("Container has no address to probe")
+}
+
+// containerHealthProbe runs the health probe of a container once.
+func containerHealthProbe(c container) error {
+	config := c.ExpandedConfig()
+	timeout := time.Duration(containerHealthConfigInt(config, "health.timeout", 5)) * time.Second
+
+	switch config["health.probe"] {
+	case "exec":
+		devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		defer devNull.Close()
+
+		cmd, _, attachedPid, err := c.Exec([]string{"/bin/sh", "-c", config["health.probe.command"]}, nil, devNull, devNull, devNull, false)
+		if err != nil {
+			return err
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- cmd.Wait()
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				return fmt.Errorf("Probe command failed: %v", err)
+			}
+
+			return nil
+		case <-time.After(timeout):
+			syscall.Kill(attachedPid, syscall.SIGKILL)
+			return fmt.Errorf("Probe command timed out")
+		}
+
+	case "tcp":
+		address, err := containerHealthAddress(c, config["health.probe.port"])
+		if err != nil {
+			return err
+		}
+
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+
+		return nil
+
+	case "http":
+		address, err := containerHealthAddress(c, config["health.probe.port"])
+		if err != nil {
+			return err
+		}
+
+		path := config["health.probe.path"]
+		if path == "" {
+			path = "/"
+		}
+
+		client := &http.Client{Timeout: timeout}
+		resp, err := client.Get(fmt.Sprintf("http://%s%s", address, path))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("Probe got status %d", resp.StatusCode)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("Unknown health probe: %s", config["health.probe"])
+}
+
+// containerHealthUpdate records the result of a probe and returns the new
+// status if it changed. A container only becomes unhealthy after
+// health.retries consecutive failures.
+func containerHealthUpdate(state *containerHealthState, err error, retries int) string {
+	status := state.status
+	if err == nil {
+		state.failures = 0
+		status = containerHealthHealthy
+	} else {
+		state.failures++
+		if state.failures >= retries {
+			status = containerHealthUnhealthy
+		}
+	}
+
+	if status == state.status {
+		return ""
+	}
+
+	state.status = status
+	return status
+}
+
+// containersHealthInit starts probing the containers which were already
+// running when LXD started.
+func containersHealthInit(d *Daemon) {
+	names, err := dbContainersList(d.db, cTypeRegular)
+	if err != nil {
+		logger.Error("Failed to list containers for health probes", log.Ctx{"err": err})
+		return
+	}
+
+	for _, name := range names {
+		c, err := containerLoadByName(d, name)
+		if err != nil || !c.IsRunning() {
+			continue
+		}
+
+		containerHealthReset(c)
+	}
+}
+
+// containersHealthCheck runs the health probes which are due, sending a
+// container-healthy or container-unhealthy lifecycle event when a container's
+// health changes.
+func containersHealthCheck(d *Daemon) {
+	due := map[string]*containerHealthState{}
+
+	containerHealthLock.Lock()
+	for name, state := range containerHealth {
+		if state.probing || time.Since(state.lastProbe) < state.interval {
+			continue
+		}
+
+		state.probing = true
+		due[name] = state
+	}
+	containerHealthLock.Unlock()
+
+	for name, state := range due {
+		go func(name string, state *containerHealthState) {
+			c, err := containerLoadByName(d, name)
+			if err != nil || !c.IsRunning() || c.ExpandedConfig()["health.probe"] == "" {
+				containerHealthLock.Lock()
+				if containerHealth[name] == state {
+					delete(containerHealth, name)
+				}
+				containerHealthLock.Unlock()
+				return
+			}
+
+			err = containerHealthProbe(c)
+			config := c.ExpandedConfig()
+			retries := containerHealthConfigInt(config, "health.retries", 3)
+
+			containerHealthLock.Lock()
+			state.probing = false
+			state.lastProbe = time.Now()
+			state.interval = time.Duration(containerHealthConfigInt(config, "health.interval", 30)) * time.Second
+			status := containerHealthUpdate(state, err, retries)
+			current := containerHealth[name] == state
+			containerHealthLock.Unlock()
+
+			// The container got restarted or stopped meanwhile
+			if !current || status == "" {
+				return
+			}
+
+			if status == containerHealthUnhealthy {
+				logger.Warn("Container is unhealthy", log.Ctx{"container": name, "err": err})
+			} else {
+				logger.Info("Container is healthy", log.Ctx{"container": name})
+			}
+
+			containerLifecycleEvent(fmt.Sprintf("container-%s", status), name)
+		}(name, state)
+	}
+}
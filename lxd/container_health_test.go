@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestContainerHealthUpdate(t *testing.T) {
+	state := &containerHealthState{status: containerHealthStarting}
+	failure := fmt.Errorf("connection refused")
+
+	steps := []struct {
+		err    error
+		status string
+		change string
+	}{
+		// Failures while starting up are retried
+		{failure, containerHealthStarting, ""},
+		{nil, containerHealthHealthy, containerHealthHealthy},
+		{nil, containerHealthHealthy, ""},
+		{failure, containerHealthHealthy, ""},
+		{failure, containerHealthHealthy, ""},
+		{failure, containerHealthUnhealthy, containerHealthUnhealthy},
+		{failure, containerHealthUnhealthy, ""},
+		{nil, containerHealthHealthy, containerHealthHealthy},
+	}
+
+	for i, step := range steps {
+		change := containerHealthUpdate(state, step.err, 3)
+		if change != step.change || state.status != step.status {
+			t.Errorf("Step %d: expected %q (change %q), got %q (change %q)", i, step.status, step.change, state.status, change)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+/* Containers copied before copies got fresh volatile keys share their MAC
+ * addresses with their source, and so their DHCP leases and DUIDs. Resetting
+ * the identity of such a container gives it new MAC addresses, drops the
+ * leases of the old ones and removes the DHCP client state found in its
+ * rootfs, optionally along with its machine-id.
+ */
+
+// containerIdentityLeases are the DHCP client lease files (holding the
+// client's DUID for DHCPv6) removed from the container's rootfs.
+var containerIdentityLeases = []string{
+	"/var/lib/dhcp/dhclient*.leases",
+	"/var/lib/dhcp/dhclient*.lease",
+	"/var/lib/dhclient/*.leases",
+	"/var/lib/dhclient/*.lease",
+	"/var/lib/NetworkManager/*.lease",
+	"/var/lib/dhcpcd/*.lease",
+	"/var/lib/dhcpcd/*.lease6",
+	"/var/lib/dhcpcd/duid",
+	"/etc/dhcpcd.duid",
+}
+
+// containerIdentityPath returns the path of a file of the container's rootfs
+// on the host, refusing any path whose parent resolves outside the rootfs.
+func containerIdentityPath(rootfs string, path string) (string, error) {
+	fullpath := filepath.Join(rootfs, path)
+
+	parent, err := filepath.EvalSymlinks(filepath.Dir(fullpath))
+	if err != nil {
+		return "", err
+	}
+
+	if parent != rootfs && !strings.HasPrefix(parent, shared.AddSlash(rootfs)) {
+		return "", fmt.Errorf("%s points outside of the container", filepath.Dir(path))
+	}
+
+	return filepath.Join(parent, filepath.Base(fullpath)), nil
+}
+
+// containerIdentityClean removes the DHCP client state from the rootfs and
+// empties its machine-id if requested, systemd generating a new one on boot.
+func containerIdentityClean(rootfs string, machineID bool) error {
+	rootfs, err := filepath.EvalSymlinks(rootfs)
+	if err != nil {
+		return err
+	}
+
+	for _, pattern := range containerIdentityLeases {
+		matches, err := filepath.Glob(filepath.Join(rootfs, pattern))
+		if err != nil {
+			return err
+		}
+
+		for _, match := range matches {
+			path, err := containerIdentityPath(rootfs, strings.TrimPrefix(match, rootfs))
+			if err != nil {
+				continue
+			}
+
+			err = os.Remove(path)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	if !machineID {
+		return nil
+	}
+
+	// Truncating keeps the file's ownership, which matters for
+	// unprivileged containers.
+	path, err := containerIdentityPath(rootfs, "/etc/machine-id")
+	if err == nil {
+		fi, err := os.Lstat(path)
+		if err == nil && fi.Mode().IsRegular() {
+			err = os.Truncate(path, 0)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// The D-Bus copy is regenerated from the above
+	path, err = containerIdentityPath(rootfs, "/var/lib/dbus/machine-id")
+	if err == nil {
+		fi, err := os.Lstat(path)
+		if err == nil && fi.Mode().IsRegular() {
+			err = os.Remove(path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// containerResetIdentity gives a stopped container new MAC addresses and
+// cleans its DHCP client state and, optionally, its machine-id.
+func containerResetIdentity(c container, machineID bool) error {
+	if c.IsRunning() {
+		return fmt.Errorf("The container must be stopped to reset its identity")
+	}
+
+	config := map[string]string{}
+	for k, v := range c.LocalConfig() {
+		config[k] = v
+	}
+
+	for name, m := range c.ExpandedDevices() {
+		key := fmt.Sprintf("volatile.%s.hwaddr", name)
+		if m["type"] != "nic" || config[key] == "" {
+			continue
+		}
+
+		// Forget the lease of the old address
+		if m["nictype"] == "bridged" {
+			networkClearLease(c.Daemon(), m["parent"], config[key])
+		}
+
+		hwaddr, err := deviceNextInterfaceHWAddr()
+		if err != nil {
+			return err
+		}
+
+		config[key] = hwaddr
+	}
+
+	args := containerArgs{
+		Architecture: c.Architecture(),
+		Config:       config,
+		Description:  c.Description(),
+		Devices:      c.LocalDevices(),
+		Ephemeral:    c.IsEphemeral(),
+		Profiles:     c.Profiles(),
+	}
+
+	err := c.Update(args, false)
+	if err != nil {
+		return err
+	}
+
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	return containerIdentityClean(c.RootfsPath(), machineID)
+}
+
+func containerResetIdentityPost(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	c, err := containerLoadByName(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	req := api.ContainerResetIdentityPost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if c.IsRunning() {
+		return BadRequest(fmt.Errorf("The container must be stopped to reset its identity"))
+	}
+
+	err = containerResetIdentity(c, req.MachineID)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+var containerResetIdentityCmd = Command{name: "containers/{name}/reset-identity", post: containerResetIdentityPost}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lxc/lxd/shared"
+)
+
+func TestContainerIdentityClean(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "lxd_identity_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootfs)
+
+	outside, err := ioutil.TempDir("", "lxd_identity_outside_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+
+	files := map[string]string{
+		"etc/machine-id":                     "0123456789abcdef0123456789abcdef\n",
+		"var/lib/dbus/machine-id":            "0123456789abcdef0123456789abcdef\n",
+		"var/lib/dhcp/dhclient.eth0.leases":  "lease {}\n",
+		"var/lib/dhcp/dhclient6.eth0.leases": "default-duid \"\\000\\001\";\n",
+		"var/lib/dhcp/keep-me":               "\n",
+	}
+
+	for name, content := range files {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(rootfs, name)), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(filepath.Join(rootfs, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A lease directory pointing out of the container must be left alone
+	err = ioutil.WriteFile(filepath.Join(outside, "dhclient.leases"), []byte("\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Symlink(outside, filepath.Join(rootfs, "var", "lib", "dhclient"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = containerIdentityClean(rootfs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"var/lib/dhcp/dhclient.eth0.leases", "var/lib/dhcp/dhclient6.eth0.leases"} {
+		if shared.PathExists(filepath.Join(rootfs, name)) {
+			t.Errorf("%s wasn't removed", name)
+		}
+	}
+
+	if !shared.PathExists(filepath.Join(rootfs, "var/lib/dhcp/keep-me")) {
+		t.Errorf("Unrelated file was removed")
+	}
+
+	if !shared.PathExists(filepath.Join(outside, "dhclient.leases")) {
+		t.Errorf("File outside of the container was removed")
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(rootfs, "etc/machine-id"))
+	if err != nil || len(content) == 0 {
+		t.Errorf("machine-id was reset without being asked to")
+	}
+
+	err = containerIdentityClean(rootfs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err = ioutil.ReadFile(filepath.Join(rootfs, "etc/machine-id"))
+	if err != nil || len(content) != 0 {
+		t.Errorf("machine-id wasn't emptied")
+	}
+
+	if shared.PathExists(filepath.Join(rootfs, "var/lib/dbus/machine-id")) {
+		t.Errorf("D-Bus machine-id wasn't removed")
+	}
+}
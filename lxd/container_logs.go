@@ -23,7 +23,7 @@ func containerLogsGet(d *Daemon, r *http.Request) Response {
 	 */
 	name := mux.Vars(r)["name"]
 
-	if err := containerValidName(name); err != nil {
+	if err := containerValidInternalName(name); err != nil {
 		return BadRequest(err)
 	}
 
@@ -56,6 +56,8 @@ func validLogFileName(fname string) bool {
 	 */
 	return fname == "lxc.log" ||
 		fname == "lxc.conf" ||
+		fname == "console.log" ||
+		fname == "console.log.1" ||
 		strings.HasPrefix(fname, "migration_") ||
 		strings.HasPrefix(fname, "snapshot_") ||
 		strings.HasPrefix(fname, "exec_")
@@ -65,7 +67,7 @@ func containerLogGet(d *Daemon, r *http.Request) Response {
 	name := mux.Vars(r)["name"]
 	file := mux.Vars(r)["file"]
 
-	if err := containerValidName(name); err != nil {
+	if err := containerValidInternalName(name); err != nil {
 		return BadRequest(err)
 	}
 
@@ -85,7 +87,7 @@ func containerLogDelete(d *Daemon, r *http.Request) Response {
 	name := mux.Vars(r)["name"]
 	file := mux.Vars(r)["file"]
 
-	if err := containerValidName(name); err != nil {
+	if err := containerValidInternalName(name); err != nil {
 		return BadRequest(err)
 	}
 
@@ -105,6 +105,17 @@ func lxcSetConfigItem(c *lxc.Container, key string, value string) error {
 		return fmt.Errorf("Uninitialized go-lxc struct")
 	}
 
+	// Translate CGroup v1 keys on hosts using the unified hierarchy
+	if cgUnified && strings.HasPrefix(key, "lxc.cgroup.") {
+		cgKey, cgValue := cGroupUnifiedItem(strings.TrimPrefix(key, "lxc.cgroup."), value)
+		if cgKey == "" {
+			return nil
+		}
+
+		key = fmt.Sprintf("lxc.cgroup2.%s", cgKey)
+		value = cgValue
+	}
+
 	err := c.SetConfigItem(key, value)
 	if err != nil {
 		return fmt.Errorf("Failed to set LXC config: %s=%s", key, value)
@@ -113,6 +124,25 @@ func lxcSetConfigItem(c *lxc.Container, key string, value string) error {
 	return nil
 }
 
+// containerConfigNeedsRestart returns whether a change to the given key can
+// only be applied by restarting the container.
+func containerConfigNeedsRestart(key string, oldConfig map[string]string) bool {
+	if shared.StringInSlice(key, []string{"console.log", "console.log_size", "raw.idmap", "raw.lxc", "raw.seccomp", "security.idmap.isolated", "security.idmap.size", "security.privileged"}) {
+		return true
+	}
+
+	if strings.HasPrefix(key, "security.syscalls.") || strings.HasPrefix(key, "limits.kernel.") {
+		return true
+	}
+
+	// /dev/hugepages is only set up when the container starts
+	if strings.HasPrefix(key, "limits.hugepages.") {
+		return oldConfig["limits.hugepages.2MB"] == "" && oldConfig["limits.hugepages.1GB"] == ""
+	}
+
+	return false
+}
+
 func lxcValidConfig(rawLxc string) error {
 	for _, line := range strings.Split(rawLxc, "\n") {
 		// Ignore empty lines
@@ -220,6 +250,22 @@ func containerLXCCreate(d *Daemon, args containerArgs) (container, error) {
 		return nil, err
 	}
 
+	if !c.IsSnapshot() {
+		err = containerValidLimits(d, c.name, c.expandedConfig)
+		if err != nil {
+			c.Delete()
+			logger.Error("Failed creating container", ctxMap)
+			return nil, err
+		}
+
+		err = projectValidLimits(d, c.name, c.expandedConfig, c.expandedDevices)
+		if err != nil {
+			c.Delete()
+			logger.Error("Failed creating container", ctxMap)
+			return nil, err
+		}
+	}
+
 	err = containerValidDevices(d, c.expandedDevices, false, true)
 	if err != nil {
 		c.Delete()
@@ -279,7 +325,7 @@ func containerLXCCreate(d *Daemon, args containerArgs) (container, error) {
 			d,
 			args.Name,
 			c.expandedConfig["security.idmap.isolated"],
-			c.expandedConfig["security.idmap.size"],
+			idmapConfigSize(c.expandedConfig),
 			c.expandedConfig["raw.idmap"],
 		)
 
@@ -320,9 +366,14 @@ func containerLXCCreate(d *Daemon, args containerArgs) (container, error) {
 	// Invalid idmap cache
 	c.idmapset = nil
 
-	// Set last_state to the map we have on disk
+	// Set last_state to the map we have on disk, shiftfs leaving it unshifted
 	if c.localConfig["volatile.last_state.idmap"] == "" {
-		err = c.ConfigKeySet("volatile.last_state.idmap", jsonIdmap)
+		diskJsonIdmap := jsonIdmap
+		if shiftfsAvailable {
+			diskJsonIdmap = "[]"
+		}
+
+		err = c.ConfigKeySet("volatile.last_state.idmap", diskJsonIdmap)
 		if err != nil {
 			c.Delete()
 			logger.Error("Failed creating container", ctxMap)
@@ -482,6 +533,20 @@ func idmapSize(daemon *Daemon, isolatedStr string, size string) (int64, error) {
 	return idMapSize, nil
 }
 
+// idmapNestingSize is the default idmap size of isolated nesting containers,
+// leaving room for a full map for the containers nested inside them.
+const idmapNestingSize = 131072
+
+// idmapConfigSize returns the idmap size requested by an expanded config
+func idmapConfigSize(config map[string]string) string {
+	size := config["security.idmap.size"]
+	if (size == "" || size == "auto") && shared.IsTrue(config["security.nesting"]) && shared.IsTrue(config["security.idmap.isolated"]) {
+		return fmt.Sprintf("%d", idmapNestingSize)
+	}
+
+	return size
+}
+
 var idmapLock sync.Mutex
 
 func parseRawIdmap(value string) ([]shared.IdmapEntry, error) {
@@ -634,7 +699,7 @@ func findIdmap(daemon *Daemon, cName string, isolatedStr string, configSize stri
 			}
 		}
 
-		cSize, err := idmapSize(daemon, container.ExpandedConfig()["security.idmap.isolated"], container.ExpandedConfig()["security.idmap.size"])
+		cSize, err := idmapSize(daemon, container.ExpandedConfig()["security.idmap.isolated"], idmapConfigSize(container.ExpandedConfig()))
 		if err != nil {
 			return nil, 0, err
 		}
@@ -746,6 +811,10 @@ func (c *containerLXC) initLXC() error {
 
 	if !shared.PathExists("/proc/self/ns/cgroup") {
 		mounts = append(mounts, "cgroup:mixed")
+	} else if c.IsNesting() && lxc.VersionAtLeast(2, 1, 0) {
+		// Give nested container managers a writable view of their own
+		// CGroup namespace rather than relying on the init system
+		mounts = append(mounts, "cgroup:rw:force")
 	}
 
 	err = lxcSetConfigItem(cc, "lxc.mount.auto", strings.Join(mounts, " "))
@@ -782,6 +851,18 @@ func (c *containerLXC) initLXC() error {
 		bindMounts = append(bindMounts, "/dev/mqueue")
 	}
 
+	// Only expose hugepages to containers allowed to allocate some
+	if c.expandedConfig["limits.hugepages.2MB"] != "" || c.expandedConfig["limits.hugepages.1GB"] != "" {
+		if c.IsPrivileged() && !runningInUserns {
+			err = lxcSetConfigItem(cc, "lxc.mount.entry", "hugetlbfs dev/hugepages hugetlbfs rw,relatime,create=dir,optional")
+			if err != nil {
+				return err
+			}
+		} else {
+			bindMounts = append(bindMounts, "/dev/hugepages")
+		}
+	}
+
 	for _, mnt := range bindMounts {
 		if !shared.PathExists(mnt) {
 			continue
@@ -871,7 +952,8 @@ func (c *containerLXC) initLXC() error {
 	logLevel := "warn"
 	if debug {
 		logLevel = "trace"
-	} else if verbose {
+	} else if verbose || c.expandedConfig["boot.autorestart"] == "on-failure" {
+		// The exit status of init is only logged at the info level
 		logLevel = "info"
 	}
 
@@ -880,6 +962,32 @@ func (c *containerLXC) initLXC() error {
 		return err
 	}
 
+	// Setup the console log
+	if c.expandedConfig["console.log"] == "" || shared.IsTrue(c.expandedConfig["console.log"]) {
+		err = lxcSetConfigItem(cc, "lxc.console.logfile", consoleLogFilePath(c.name))
+		if err != nil {
+			return err
+		}
+
+		// Older liblxc only get the log rotated on start
+		maxSize, err := consoleLogMaxSize(c.expandedConfig)
+		if err != nil {
+			return err
+		}
+
+		if maxSize > 0 && lxc.VersionAtLeast(3, 0, 0) {
+			err = lxcSetConfigItem(cc, "lxc.console.size", fmt.Sprintf("%d", maxSize))
+			if err != nil {
+				return err
+			}
+
+			err = lxcSetConfigItem(cc, "lxc.console.rotate", "1")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// Setup architecture
 	personality, err := osarch.ArchitecturePersonality(c.architecture)
 	if err != nil {
@@ -912,7 +1020,8 @@ func (c *containerLXC) initLXC() error {
 	}
 
 	// Setup the hostname
-	err = lxcSetConfigItem(cc, "lxc.utsname", c.Name())
+	_, hostname := projectSplitName(c.Name())
+	err = lxcSetConfigItem(cc, "lxc.utsname", hostname)
 	if err != nil {
 		return err
 	}
@@ -923,6 +1032,14 @@ func (c *containerLXC) initLXC() error {
 		return err
 	}
 
+	// Setup the cloud-init seed, written on start
+	if shared.IsTrue(c.expandedConfig["cloud-init.seed"]) {
+		err = lxcSetConfigItem(cc, "lxc.mount.entry", fmt.Sprintf("%s %s none bind,ro,create=dir 0 0", filepath.Join(c.DevicesPath(), "cloud-init"), cloudInitSeedPath))
+		if err != nil {
+			return err
+		}
+	}
+
 	// Setup AppArmor
 	if aaAvailable {
 		if aaConfined || !aaAdmin {
@@ -969,6 +1086,37 @@ func (c *containerLXC) initLXC() error {
 		return err
 	}
 
+	// Mount the rootfs through shiftfs rather than shifting it on disk
+	if shiftfsAvailable && idmapset != nil {
+		rootfs := strconv.Quote(c.RootfsPath())
+
+		mountPath, err := exec.LookPath("mount")
+		if err != nil {
+			return err
+		}
+
+		umountPath, err := exec.LookPath("umount")
+		if err != nil {
+			return err
+		}
+
+		err = lxcSetConfigItem(cc, "lxc.hook.pre-start", fmt.Sprintf("%s -t shiftfs -o mark %s %s", mountPath, rootfs, rootfs))
+		if err != nil {
+			return err
+		}
+
+		// A failure here leaves the mark behind, see shiftfsUnmark
+		err = lxcSetConfigItem(cc, "lxc.hook.pre-mount", fmt.Sprintf("%s -t shiftfs %s %s", mountPath, rootfs, rootfs))
+		if err != nil {
+			return err
+		}
+
+		err = lxcSetConfigItem(cc, "lxc.hook.start-host", fmt.Sprintf("%s -l %s", umountPath, rootfs))
+		if err != nil {
+			return err
+		}
+	}
+
 	if idmapset != nil {
 		lines := idmapset.ToLxcString()
 		for _, line := range lines {
@@ -979,6 +1127,48 @@ func (c *containerLXC) initLXC() error {
 		}
 	}
 
+	// Containers from converted OCI images run their command as init, with
+	// its environment (which environment.* keys can still override)
+	if c.expandedConfig["image.oci.cmd"] != "" {
+		initCmdKey := "lxc.init_cmd"
+		if lxc.VersionAtLeast(2, 1, 0) {
+			initCmdKey = "lxc.init.cmd"
+		}
+
+		args, err := ociSplitCommand(c.expandedConfig["image.oci.cmd"])
+		if err != nil {
+			return err
+		}
+
+		initCmd, err := ociLXCCommand(args)
+		if err != nil {
+			return err
+		}
+
+		err = lxcSetConfigItem(cc, initCmdKey, initCmd)
+		if err != nil {
+			return err
+		}
+
+		if c.expandedConfig["image.oci.cwd"] != "" && lxc.VersionAtLeast(2, 1, 0) {
+			err = lxcSetConfigItem(cc, "lxc.init.cwd", c.expandedConfig["image.oci.cwd"])
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, env := range strings.Split(c.expandedConfig["image.oci.env"], "\n") {
+			if env == "" || c.expandedConfig["environment."+strings.SplitN(env, "=", 2)[0]] != "" {
+				continue
+			}
+
+			err = lxcSetConfigItem(cc, "lxc.environment", env)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// Setup environment
 	for k, v := range c.expandedConfig {
 		if strings.HasPrefix(k, "environment.") {
@@ -998,24 +1188,9 @@ func (c *containerLXC) initLXC() error {
 
 		// Configure the memory limits
 		if memory != "" {
-			var valueInt int64
-			if strings.HasSuffix(memory, "%") {
-				percent, err := strconv.ParseInt(strings.TrimSuffix(memory, "%"), 10, 64)
-				if err != nil {
-					return err
-				}
-
-				memoryTotal, err := deviceTotalMemory()
-				if err != nil {
-					return err
-				}
-
-				valueInt = int64((memoryTotal / 100) * percent)
-			} else {
-				valueInt, err = shared.ParseByteSizeString(memory)
-				if err != nil {
-					return err
-				}
+			valueInt, err := deviceParseMemoryLimit(memory)
+			if err != nil {
+				return err
 			}
 
 			if memoryEnforce == "soft" {
@@ -1047,19 +1222,33 @@ func (c *containerLXC) initLXC() error {
 			}
 		}
 
-		// Configure the swappiness
-		if memorySwap != "" && !shared.IsTrue(memorySwap) {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.memory.swappiness", "0")
-			if err != nil {
-				return err
+		// The unified hierarchy has no swappiness, swap being limited instead
+		if cgUnified && cgSwapAccounting {
+			swap := memorySwap == "" || shared.IsTrue(memorySwap)
+
+			memoryLimit := int64(-1)
+			if memory != "" && memoryEnforce != "soft" {
+				memoryLimit, err = deviceParseMemoryLimit(memory)
+				if err != nil {
+					return err
+				}
 			}
-		} else if memorySwapPriority != "" {
-			priority, err := strconv.Atoi(memorySwapPriority)
+
+			// As with CGroup v1, memory and swap are limited together
+			err = lxcSetConfigItem(cc, "lxc.cgroup2.memory.swap.max", cGroupUnifiedSwapMax(memoryLimit, memoryLimit, swap))
 			if err != nil {
 				return err
 			}
+		}
 
-			err = lxcSetConfigItem(cc, "lxc.cgroup.memory.swappiness", fmt.Sprintf("%d", 60-10+priority))
+		// Configure the swappiness
+		swappiness, err := deviceMemorySwappiness(memorySwap, memorySwapPriority)
+		if err != nil {
+			return err
+		}
+
+		if swappiness != "" {
+			err = lxcSetConfigItem(cc, "lxc.cgroup.memory.swappiness", swappiness)
 			if err != nil {
 				return err
 			}
@@ -1083,37 +1272,49 @@ func (c *containerLXC) initLXC() error {
 			}
 		}
 
-		if cpuCfsPeriod != "-1" {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.cfs_period_us", cpuCfsPeriod)
-			if err != nil {
-				return err
+		if cgUnified {
+			if cpuCfsQuota != "-1" {
+				err = lxcSetConfigItem(cc, "lxc.cgroup2.cpu.max", cGroupCPUMax(cpuCfsQuota, cpuCfsPeriod))
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			if cpuCfsPeriod != "-1" {
+				err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.cfs_period_us", cpuCfsPeriod)
+				if err != nil {
+					return err
+				}
 			}
-		}
 
-		if cpuCfsQuota != "-1" {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.cfs_quota_us", cpuCfsQuota)
-			if err != nil {
-				return err
+			if cpuCfsQuota != "-1" {
+				err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.cfs_quota_us", cpuCfsQuota)
+				if err != nil {
+					return err
+				}
 			}
 		}
 	}
 
+	// NUMA node pinning, the CPUs themselves are assigned by the scheduler
+	cpuNodes := c.expandedConfig["limits.cpu.nodes"]
+	if cpuNodes != "" && cgCpusetController {
+		err = lxcSetConfigItem(cc, "lxc.cgroup.cpuset.mems", cpuNodes)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Disk limits
 	if cgBlkioController {
 		diskPriority := c.expandedConfig["limits.disk.priority"]
 		if diskPriority != "" {
-			priorityInt, err := strconv.Atoi(diskPriority)
+			priority, err := deviceParseDiskPriority(diskPriority)
 			if err != nil {
 				return err
 			}
 
-			// Minimum valid value is 10
-			priority := priorityInt * 100
-			if priority == 0 {
-				priority = 10
-			}
-
-			err = lxcSetConfigItem(cc, "lxc.cgroup.blkio.weight", fmt.Sprintf("%d", priority))
+			err = lxcSetConfigItem(cc, "lxc.cgroup.blkio.weight", priority)
 			if err != nil {
 				return err
 			}
@@ -1170,6 +1371,43 @@ func (c *containerLXC) initLXC() error {
 		}
 	}
 
+	// Hugepages
+	if cgHugetlbController {
+		for _, pageSize := range []string{"2MB", "1GB"} {
+			value := c.expandedConfig[fmt.Sprintf("limits.hugepages.%s", pageSize)]
+			if value == "" {
+				continue
+			}
+
+			limit, err := shared.ParseByteSizeString(value)
+			if err != nil {
+				return err
+			}
+
+			err = lxcSetConfigItem(cc, fmt.Sprintf("lxc.cgroup.hugetlb.%s.limit_in_bytes", pageSize), fmt.Sprintf("%d", limit))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Kernel resource limits of the container's init
+	for _, resource := range shared.KernelLimits {
+		value := c.expandedConfig[fmt.Sprintf("limits.kernel.%s", resource)]
+		if value == "" {
+			continue
+		}
+
+		if !lxc.VersionAtLeast(2, 1, 0) {
+			return fmt.Errorf("limits.kernel.%s requires LXC 2.1 or higher", resource)
+		}
+
+		err = lxcSetConfigItem(cc, fmt.Sprintf("lxc.prlimit.%s", resource), value)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Processes
 	if cgPidsController {
 		processes := c.expandedConfig["limits.processes"]
@@ -1423,7 +1661,7 @@ func (c *containerLXC) expandConfig() error {
 
 	// Apply all the profiles
 	for _, name := range c.profiles {
-		profileConfig, err := dbProfileConfig(c.daemon.db, name)
+		profileConfig, _, err := profileCacheGet(c.daemon.db, name)
 		if err != nil {
 			return err
 		}
@@ -1447,7 +1685,7 @@ func (c *containerLXC) expandDevices() error {
 
 	// Apply all the profiles
 	for _, p := range c.profiles {
-		profileDevices, err := dbDevices(c.daemon.db, p, true)
+		_, profileDevices, err := profileCacheGet(c.daemon.db, p)
 		if err != nil {
 			return err
 		}
@@ -1543,6 +1781,23 @@ func (c *containerLXC) startCommon() (string, error) {
 		}
 	}
 
+	// Rotate the console log
+	maxSize, err := consoleLogMaxSize(c.expandedConfig)
+	if err != nil {
+		return "", err
+	}
+
+	err = consoleLogRotate(consoleLogFilePath(c.name), maxSize)
+	if err != nil {
+		logger.Warn("Failed to rotate the console log", log.Ctx{"container": c.name, "err": err})
+	}
+	consoleForwardReset(c.name)
+
+	// Without the pids CGroup the process limit can't be enforced
+	if c.expandedConfig["limits.processes"] != "" && !cgPidsController {
+		logger.Warn("Ignoring limits.processes as the pids CGroup controller is missing", log.Ctx{"container": c.name})
+	}
+
 	// Load any required kernel modules
 	kernelModules := c.expandedConfig["linux.kernel_modules"]
 	if kernelModules != "" {
@@ -1583,20 +1838,36 @@ func (c *containerLXC) startCommon() (string, error) {
 		delete(c.expandedConfig, "volatile.apply_quota")
 	}
 
+	// All the pending configuration changes are applied by this start
+	if c.localConfig["volatile.restart_required"] != "" {
+		err := dbContainerConfigRemove(c.daemon.db, c.id, "volatile.restart_required")
+		if err != nil {
+			return "", err
+		}
+
+		delete(c.localConfig, "volatile.restart_required")
+		delete(c.expandedConfig, "volatile.restart_required")
+	}
+
 	/* Deal with idmap changes */
 	idmap, err := c.IdmapSet()
 	if err != nil {
 		return "", err
 	}
 
+	diskIdmap, err := containerDiskIdmap(c)
+	if err != nil {
+		return "", err
+	}
+
 	lastIdmap, err := c.LastIdmapSet()
 	if err != nil {
 		return "", err
 	}
 
 	var jsonIdmap string
-	if idmap != nil {
-		idmapBytes, err := json.Marshal(idmap.Idmap)
+	if diskIdmap != nil {
+		idmapBytes, err := json.Marshal(diskIdmap.Idmap)
 		if err != nil {
 			return "", err
 		}
@@ -1605,15 +1876,19 @@ func (c *containerLXC) startCommon() (string, error) {
 		jsonIdmap = "[]"
 	}
 
-	if !reflect.DeepEqual(idmap, lastIdmap) {
-		logger.Debugf("Container idmap changed, remapping")
-
+	// With shiftfs, the container directory still follows the current map
+	remap := !reflect.DeepEqual(diskIdmap, lastIdmap)
+	if remap || (shiftfsAvailable && idmap != nil) {
 		ourStart, err = c.StorageStart()
 		if err != nil {
 			return "", err
 		}
 
-		if lastIdmap != nil {
+		if remap {
+			logger.Debugf("Container idmap changed, remapping")
+		}
+
+		if remap && lastIdmap != nil {
 			err = lastIdmap.UnshiftRootfs(c.RootfsPath())
 			if err != nil {
 				if ourStart {
@@ -1623,8 +1898,8 @@ func (c *containerLXC) startCommon() (string, error) {
 			}
 		}
 
-		if idmap != nil {
-			err = idmap.ShiftRootfs(c.RootfsPath())
+		if remap && diskIdmap != nil {
+			err = diskIdmap.ShiftRootfs(c.RootfsPath())
 			if err != nil {
 				if ourStart {
 					c.StorageStop()
@@ -1850,6 +2125,13 @@ func (c *containerLXC) startCommon() (string, error) {
 		return "", err
 	}
 
+	if shared.IsTrue(c.expandedConfig["cloud-init.seed"]) {
+		err = cloudInitWriteSeed(c, filepath.Join(c.DevicesPath(), "cloud-init"))
+		if err != nil {
+			return "", fmt.Errorf("Failed to write the cloud-init seed: %s", err)
+		}
+	}
+
 	// Rotate the log file
 	logfile := c.LogFilePath()
 	if shared.PathExists(logfile) {
@@ -1954,6 +2236,10 @@ func (c *containerLXC) Start(stateful bool) error {
 		}
 
 		logger.Info("Started container", ctxMap)
+		containerOOMReset(c.name)
+		containerHealthReset(c)
+		networkZoneInvalidate()
+		containerLifecycleEvent("container-started", c.name)
 
 		return err
 	} else if c.stateful {
@@ -2015,11 +2301,23 @@ func (c *containerLXC) Start(stateful bool) error {
 
 		logger.Error("Failed starting container", ctxMap)
 
+		// Don't leave the shiftfs mark behind if the start failed past it
+		if shiftfsAvailable {
+			err := shiftfsUnmark(c.RootfsPath())
+			if err != nil {
+				logger.Error("Failed to remove the shiftfs mark", log.Ctx{"container": c.Name(), "err": err})
+			}
+		}
+
 		// Return the actual error
 		return err
 	}
 
 	logger.Info("Started container", ctxMap)
+	containerOOMReset(c.name)
+	containerHealthReset(c)
+	networkZoneInvalidate()
+	containerLifecycleEvent("container-started", c.name)
 
 	return nil
 }
@@ -2123,6 +2421,9 @@ func (c *containerLXC) OnStart() error {
 func (c *containerLXC) Stop(stateful bool) error {
 	var ctxMap log.Ctx
 
+	// Don't restart containers stopped on purpose
+	containerAutorestartCancel(c.name)
+
 	// Check that we're not already stopped
 	if !c.IsRunning() {
 		return fmt.Errorf("The container is already stopped")
@@ -2217,6 +2518,9 @@ func (c *containerLXC) Stop(stateful bool) error {
 func (c *containerLXC) Shutdown(timeout time.Duration) error {
 	var ctxMap log.Ctx
 
+	// Don't restart containers stopped on purpose
+	containerAutorestartCancel(c.name)
+
 	// Check that we're not already stopped
 	if !c.IsRunning() {
 		return fmt.Errorf("The container is already stopped")
@@ -2352,6 +2656,24 @@ func (c *containerLXC) OnStop(target string) error {
 			logger.Error("Failed to set container state", log.Ctx{"container": c.Name(), "err": err})
 		}
 
+		containerOOMForget(c.name)
+		containerHealthForget(c.name)
+		cloudInitForget(c.name)
+		containerLifecycleEvent("container-stopped", c.name)
+
+		// Restart containers which stopped on their own
+		if op == nil && !c.ephemeral {
+			failed := true
+			if c.expandedConfig["boot.autorestart"] == "on-failure" {
+				content, err := ioutil.ReadFile(c.LogFilePath())
+				if err == nil {
+					failed = containerAutorestartFailed(content)
+				}
+			}
+
+			containerAutorestartSchedule(c.daemon, c.name, c.expandedConfig, failed)
+		}
+
 		// Destroy ephemeral containers
 		if c.ephemeral {
 			err = c.Delete()
@@ -2484,6 +2806,8 @@ func (c *containerLXC) Render() (interface{}, interface{}, error) {
 			Name:            c.name,
 			Profiles:        c.profiles,
 			Stateful:        c.stateful,
+			Description:     c.description,
+			Size:            -1,
 		}, etag, nil
 	} else {
 		// FIXME: Render shouldn't directly access the go-lxc struct
@@ -2515,6 +2839,21 @@ func (c *containerLXC) Render() (interface{}, interface{}, error) {
 	}
 }
 
+// SnapshotUsage returns the disk space used by a snapshot as reported by its
+// storage backend.
+func (c *containerLXC) SnapshotUsage() (int64, error) {
+	if !c.IsSnapshot() {
+		return -1, fmt.Errorf("Container %s isn't a snapshot", c.name)
+	}
+
+	err := c.initStorage()
+	if err != nil {
+		return -1, err
+	}
+
+	return c.storage.ContainerSnapshotGetUsage(c)
+}
+
 func (c *containerLXC) RenderState() (*api.ContainerState, error) {
 	// Load the go-lxc struct
 	err := c.initLXC()
@@ -2540,11 +2879,51 @@ func (c *containerLXC) RenderState() (*api.ContainerState, error) {
 		status.Network = c.networkState()
 		status.Pid = int64(pid)
 		status.Processes = c.processesState()
+
+		if cloudInitUsed(c.expandedConfig) {
+			status.CloudInit = c.cloudInitState()
+		}
+
+		status.Health = containerHealthStatus(c)
 	}
 
 	return &status, nil
 }
 
+// cloudInitState returns the status of cloud-init in the running container,
+// its files no longer being read once it finished for the current boot.
+func (c *containerLXC) cloudInitState() string {
+	pid := c.InitPID()
+
+	status, ok := cloudInitCachedState(c.name, pid)
+	if ok {
+		return status
+	}
+
+	readFile := func(path string) []byte {
+		content, err := cloudInitReadFile(fmt.Sprintf("/proc/%d/root%s", pid, path))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			// Present but unreadable, reported as malformed
+			return []byte{}
+		}
+
+		return content
+	}
+
+	result := readFile(cloudInitResultFile)
+	if result != nil {
+		status = cloudInitParseStatus(nil, result)
+		cloudInitCacheState(c.name, pid, status)
+		return status
+	}
+
+	return cloudInitParseStatus(readFile(cloudInitStatusFile), nil)
+}
+
 func (c *containerLXC) Snapshots() ([]container, error) {
 	// Get all the snapshots
 	snaps, err := dbContainerGetSnapshots(c.daemon.db, c.name)
@@ -2787,6 +3166,15 @@ func (c *containerLXC) Delete() error {
 
 	logger.Info("Deleted container", ctxMap)
 
+	if !c.IsSnapshot() {
+		containerOOMForget(c.name)
+		containerHealthForget(c.name)
+		cloudInitForget(c.name)
+		containerAutorestartCancel(c.name)
+		networkZoneInvalidate()
+		containerLifecycleEvent("container-deleted", c.name)
+	}
+
 	return nil
 }
 
@@ -2807,7 +3195,7 @@ func (c *containerLXC) Rename(newName string) error {
 	}
 
 	// Sanity checks
-	if !c.IsSnapshot() && !shared.ValidHostname(newName) {
+	if !c.IsSnapshot() && containerValidInternalName(newName) != nil {
 		return fmt.Errorf("Invalid container name")
 	}
 
@@ -2895,6 +3283,8 @@ func (c *containerLXC) Rename(newName string) error {
 	// Invalidate the go-lxc cache
 	c.c = nil
 
+	networkZoneInvalidate()
+
 	logger.Info("Renamed container", ctxMap)
 
 	return nil
@@ -2912,6 +3302,15 @@ func (c *containerLXC) CGroupGet(key string) (string, error) {
 		return "", fmt.Errorf("Can't get cgroups on a stopped container")
 	}
 
+	if cgUnified {
+		cgKey, _ := cGroupUnifiedItem(key, "")
+		if cgKey == "" {
+			return "", fmt.Errorf("The CGroup key %s isn't available with the unified hierarchy", key)
+		}
+
+		key = cgKey
+	}
+
 	value := c.c.CgroupItem(key)
 	return strings.Join(value, "\n"), nil
 }
@@ -2928,6 +3327,13 @@ func (c *containerLXC) CGroupSet(key string, value string) error {
 		return fmt.Errorf("Can't set cgroups on a stopped container")
 	}
 
+	if cgUnified {
+		key, value = cGroupUnifiedItem(key, value)
+		if key == "" {
+			return nil
+		}
+	}
+
 	err = c.c.SetCgroupItem(key, value)
 	if err != nil {
 		return fmt.Errorf("Failed to set cgroup %s=\"%s\": %s", key, value, err)
@@ -2955,6 +3361,9 @@ type backupFile struct {
 	Snapshots []*api.ContainerSnapshot `yaml:"snapshots"`
 	Pool      *api.StoragePool         `yaml:"pool"`
 	Volume    *api.StorageVolume       `yaml:"volume"`
+
+	// Only set in backup tarballs
+	OptimizedStorage bool `yaml:"optimized_storage,omitempty"`
 }
 
 func writeBackupFile(c container) error {
@@ -3224,12 +3633,26 @@ func (c *containerLXC) Update(args containerArgs, userRequested bool) error {
 		return err
 	}
 
+	if shared.StringInSlice("limits.cpu", changedConfig) || shared.StringInSlice("limits.memory", changedConfig) {
+		err = containerValidLimits(c.daemon, c.name, c.expandedConfig)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Do some validation of the devices diff
 	err = containerValidDevices(c.daemon, c.expandedDevices, false, true)
 	if err != nil {
 		return err
 	}
 
+	if !c.IsSnapshot() {
+		err = projectValidLimits(c.daemon, c.name, c.expandedConfig, c.expandedDevices)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Run through initLXC to catch anything we missed
 	c.c = nil
 	err = c.initLXC()
@@ -3251,7 +3674,7 @@ func (c *containerLXC) Update(args containerArgs, userRequested bool) error {
 		}
 	}
 
-	if shared.StringInSlice("security.idmap.isolated", changedConfig) || shared.StringInSlice("security.idmap.size", changedConfig) || shared.StringInSlice("raw.idmap", changedConfig) || shared.StringInSlice("security.privileged", changedConfig) {
+	if shared.StringInSlice("security.idmap.isolated", changedConfig) || shared.StringInSlice("security.idmap.size", changedConfig) || shared.StringInSlice("raw.idmap", changedConfig) || shared.StringInSlice("security.privileged", changedConfig) || shared.StringInSlice("security.nesting", changedConfig) {
 		var idmap *shared.IdmapSet
 		base := int64(0)
 		if !c.IsPrivileged() {
@@ -3260,7 +3683,7 @@ func (c *containerLXC) Update(args containerArgs, userRequested bool) error {
 				c.daemon,
 				c.Name(),
 				c.expandedConfig["security.idmap.isolated"],
-				c.expandedConfig["security.idmap.size"],
+				idmapConfigSize(c.expandedConfig),
 				c.expandedConfig["raw.idmap"],
 			)
 			if err != nil {
@@ -3391,22 +3814,12 @@ func (c *containerLXC) Update(args containerArgs, userRequested bool) error {
 					continue
 				}
 
-				priorityInt := 5
-				diskPriority := c.expandedConfig["limits.disk.priority"]
-				if diskPriority != "" {
-					priorityInt, err = strconv.Atoi(diskPriority)
-					if err != nil {
-						return err
-					}
-				}
-
-				// Minimum valid value is 10
-				priority := priorityInt * 100
-				if priority == 0 {
-					priority = 10
+				priority, err := deviceParseDiskPriority(c.expandedConfig["limits.disk.priority"])
+				if err != nil {
+					return err
 				}
 
-				err = c.CGroupSet("blkio.weight", fmt.Sprintf("%d", priority))
+				err = c.CGroupSet("blkio.weight", priority)
 				if err != nil {
 					return err
 				}
@@ -3424,20 +3837,8 @@ func (c *containerLXC) Update(args containerArgs, userRequested bool) error {
 				// Parse memory
 				if memory == "" {
 					memory = "-1"
-				} else if strings.HasSuffix(memory, "%") {
-					percent, err := strconv.ParseInt(strings.TrimSuffix(memory, "%"), 10, 64)
-					if err != nil {
-						return err
-					}
-
-					memoryTotal, err := deviceTotalMemory()
-					if err != nil {
-						return err
-					}
-
-					memory = fmt.Sprintf("%d", int64((memoryTotal/100)*percent))
 				} else {
-					valueInt, err := shared.ParseByteSizeString(memory)
+					valueInt, err := deviceParseMemoryLimit(memory)
 					if err != nil {
 						return err
 					}
@@ -3541,28 +3942,47 @@ func (c *containerLXC) Update(args containerArgs, userRequested bool) error {
 					}
 				}
 
-				// Configure the swappiness
-				if key == "limits.memory.swap" || key == "limits.memory.swap.priority" {
-					memorySwap := c.expandedConfig["limits.memory.swap"]
-					memorySwapPriority := c.expandedConfig["limits.memory.swap.priority"]
-					if memorySwap != "" && !shared.IsTrue(memorySwap) {
-						err = c.CGroupSet("memory.swappiness", "0")
+				// The unified hierarchy has no swappiness, swap being limited instead
+				if cgUnified && cgSwapAccounting {
+					swap := memorySwap == "" || shared.IsTrue(memorySwap)
+
+					memoryLimit := int64(-1)
+					if memoryEnforce != "soft" {
+						memoryLimit, err = strconv.ParseInt(memory, 10, 64)
 						if err != nil {
+							revertMemory()
 							return err
 						}
-					} else {
-						priority := 0
-						if memorySwapPriority != "" {
-							priority, err = strconv.Atoi(memorySwapPriority)
-							if err != nil {
-								return err
-							}
-						}
+					}
+
+					// As with CGroup v1, memory and swap are limited together
+					err = c.CGroupSet("memory.swap.max", cGroupUnifiedSwapMax(memoryLimit, memoryLimit, swap))
+					if err != nil {
+						revertMemory()
+						return err
+					}
+				}
+
+				// Configure the swappiness
+				if key == "limits.memory.swap" || key == "limits.memory.swap.priority" {
+					swappiness, err := deviceMemorySwappiness(c.expandedConfig["limits.memory.swap"], c.expandedConfig["limits.memory.swap.priority"])
+					if err != nil {
+						return err
+					}
 
-						err = c.CGroupSet("memory.swappiness", fmt.Sprintf("%d", 60-10+priority))
+					// Go back to the host's value when both keys are unset
+					if swappiness == "" {
+						content, err := ioutil.ReadFile("/proc/sys/vm/swappiness")
 						if err != nil {
 							return err
 						}
+
+						swappiness = strings.TrimSpace(string(content))
+					}
+
+					err = c.CGroupSet("memory.swappiness", swappiness)
+					if err != nil {
+						return err
 					}
 				}
 			} else if key == "limits.network.priority" {
@@ -3573,6 +3993,28 @@ func (c *containerLXC) Update(args containerArgs, userRequested bool) error {
 			} else if key == "limits.cpu" {
 				// Trigger a scheduler re-run
 				deviceTaskSchedulerTrigger("container", c.name, "changed")
+			} else if key == "limits.cpu.nodes" {
+				// Skip if no cpuset CGroup
+				if !cgCpusetController {
+					continue
+				}
+
+				// Reset to all the host's memory nodes when unset
+				mems := c.expandedConfig["limits.cpu.nodes"]
+				if mems == "" {
+					mems, err = cGroupGet("cpuset", "/", "cpuset.effective_mems")
+					if err != nil {
+						return err
+					}
+				}
+
+				err = c.CGroupSet("cpuset.mems", mems)
+				if err != nil {
+					return err
+				}
+
+				// Re-pin the CPUs to the new nodes
+				deviceTaskSchedulerTrigger("container", c.name, "changed")
 			} else if key == "limits.cpu.priority" || key == "limits.cpu.allowance" {
 				// Skip if no cpu CGroup
 				if !cgCpuController {
@@ -3590,12 +4032,38 @@ func (c *containerLXC) Update(args containerArgs, userRequested bool) error {
 					return err
 				}
 
-				err = c.CGroupSet("cpu.cfs_period_us", cpuCfsPeriod)
-				if err != nil {
-					return err
+				if cgUnified {
+					err = c.CGroupSet("cpu.max", cGroupCPUMax(cpuCfsQuota, cpuCfsPeriod))
+					if err != nil {
+						return err
+					}
+				} else {
+					err = c.CGroupSet("cpu.cfs_period_us", cpuCfsPeriod)
+					if err != nil {
+						return err
+					}
+
+					err = c.CGroupSet("cpu.cfs_quota_us", cpuCfsQuota)
+					if err != nil {
+						return err
+					}
+				}
+			} else if strings.HasPrefix(key, "limits.hugepages.") {
+				if !cgHugetlbController {
+					continue
+				}
+
+				limit := "-1"
+				if value != "" {
+					valueInt, err := shared.ParseByteSizeString(value)
+					if err != nil {
+						return err
+					}
+					limit = fmt.Sprintf("%d", valueInt)
 				}
 
-				err = c.CGroupSet("cpu.cfs_quota_us", cpuCfsQuota)
+				pageSize := strings.TrimPrefix(key, "limits.hugepages.")
+				err = c.CGroupSet(fmt.Sprintf("hugetlb.%s.limit_in_bytes", pageSize), limit)
 				if err != nil {
 					return err
 				}
@@ -3854,6 +4322,27 @@ func (c *containerLXC) Update(args containerArgs, userRequested bool) error {
 		}
 	}
 
+	// Record the changes which will only be applied on next start
+	if isRunning {
+		pending := []string{}
+		if c.localConfig["volatile.restart_required"] != "" {
+			pending = strings.Split(c.localConfig["volatile.restart_required"], ",")
+		}
+
+		for _, key := range changedConfig {
+			if containerConfigNeedsRestart(key, oldExpandedConfig) && !shared.StringInSlice(key, pending) {
+				pending = append(pending, key)
+			}
+		}
+
+		if len(pending) > 0 {
+			sort.Strings(pending)
+			c.localConfig["volatile.restart_required"] = strings.Join(pending, ",")
+			c.expandedConfig["volatile.restart_required"] = c.localConfig["volatile.restart_required"]
+			logger.Info("Some configuration changes will only apply after a restart", log.Ctx{"container": c.name, "keys": pending})
+		}
+	}
+
 	// Cleanup any leftover volatile entries
 	netNames := []string{}
 	for _, k := range c.expandedDevices.DeviceNames() {
@@ -3957,6 +4446,18 @@ func (c *containerLXC) Update(args containerArgs, userRequested bool) error {
 		networkUpdateStatic(c.daemon, "")
 	}
 
+	// Start or stop probing the container
+	if isRunning {
+		for _, key := range changedConfig {
+			if strings.HasPrefix(key, "health.") {
+				containerHealthReset(c)
+				break
+			}
+		}
+	}
+
+	networkZoneInvalidate()
+
 	// Success, update the closure to mark that the changes should be kept.
 	undoChanges = false
 
@@ -4443,12 +4944,12 @@ func (c *containerLXC) templateApplyNow(trigger string) error {
 			gid := int64(0)
 
 			// Get the right uid and gid for the container
-			if !c.IsPrivileged() {
-				idmapset, err := c.IdmapSet()
-				if err != nil {
-					return err
-				}
+			idmapset, err := containerDiskIdmap(c)
+			if err != nil {
+				return err
+			}
 
+			if idmapset != nil {
 				uid, gid = idmapset.ShiftIntoNs(0, 0)
 			}
 
@@ -4907,21 +5408,43 @@ func (c *containerLXC) Exec(command []string, env map[string]string, stdin *os.F
 	return nil, 0, attachedPid, nil
 }
 
-func (c *containerLXC) cpuState() api.ContainerStateCPU {
-	cpu := api.ContainerStateCPU{}
+func (c *containerLXC) cpuUsage() int64 {
+	// The unified hierarchy reports microseconds in cpu.stat
+	if cgUnified {
+		value, _ := c.CGroupGet("cpu.stat")
+		for _, line := range strings.Split(value, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "usage_usec" {
+				usage, err := strconv.ParseInt(fields[1], 10, 64)
+				if err == nil {
+					return usage * 1000
+				}
+			}
+		}
 
-	if !cgCpuacctController {
-		return cpu
+		return -1
 	}
 
-	// CPU usage in seconds
 	value, err := c.CGroupGet("cpuacct.usage")
 	valueInt, err := strconv.ParseInt(value, 10, 64)
 	if err != nil {
-		valueInt = -1
+		return -1
+	}
+
+	return valueInt
+}
+
+func (c *containerLXC) cpuState() api.ContainerStateCPU {
+	cpu := api.ContainerStateCPU{}
+
+	if !cgCpuacctController {
+		return cpu
 	}
 
-	cpu.Usage = valueInt
+	// CPU usage in nanoseconds, the utilization being sampled in the
+	// background
+	cpu.Usage = c.cpuUsage()
+	cpu.UsagePercent = containerCPUPercent(c)
 
 	return cpu
 }
@@ -4941,7 +5464,21 @@ func (c *containerLXC) diskState() map[string]api.ContainerStateDisk {
 			continue
 		}
 
+		// Other disks report the usage of the filesystem mounted at
+		// their path inside the container
 		if d["path"] != "/" {
+			pid := c.InitPID()
+			if pid < 1 {
+				continue
+			}
+
+			fs := syscall.Statfs_t{}
+			err := syscall.Statfs(fmt.Sprintf("/proc/%d/root/%s", pid, strings.TrimPrefix(d["path"], "/")), &fs)
+			if err != nil {
+				continue
+			}
+
+			disk[name] = api.ContainerStateDisk{Usage: int64(fs.Blocks-fs.Bfree) * int64(fs.Bsize)}
 			continue
 		}
 
@@ -4988,7 +5525,12 @@ func (c *containerLXC) memoryState() api.ContainerStateMemory {
 			valueInt = -1
 		}
 
-		memory.SwapUsage = valueInt - memory.Usage
+		// The unified hierarchy accounts swap separately
+		if cgUnified {
+			memory.SwapUsage = valueInt
+		} else {
+			memory.SwapUsage = valueInt - memory.Usage
+		}
 
 		// Swap peak in bytes
 		value, err = c.CGroupGet("memory.memsw.max_usage_in_bytes")
@@ -4997,12 +5539,27 @@ func (c *containerLXC) memoryState() api.ContainerStateMemory {
 			valueInt = -1
 		}
 
-		memory.SwapUsagePeak = valueInt - memory.UsagePeak
+		if cgUnified {
+			memory.SwapUsagePeak = valueInt
+		} else {
+			memory.SwapUsagePeak = valueInt - memory.UsagePeak
+		}
+	}
+
+	// Processes killed by the OOM killer since the container started
+	value, err = c.CGroupGet("memory.oom_control")
+	if err == nil {
+		memory.OOMKills, _ = containerOOMKillCount(value)
 	}
 
 	return memory
 }
 
+// NetworkState returns the network interfaces of the running container.
+func (c *containerLXC) NetworkState() map[string]api.ContainerStateNetwork {
+	return c.networkState()
+}
+
 func (c *containerLXC) networkState() map[string]api.ContainerStateNetwork {
 	result := map[string]api.ContainerStateNetwork{}
 
@@ -5112,14 +5669,16 @@ func (c *containerLXC) tarStoreFile(linkmap map[uint64]string, offset int, tw *t
 
 	// Unshift the id under /rootfs/ for unpriv containers
 	if !c.IsPrivileged() && strings.HasPrefix(hdr.Name, "/rootfs") {
-		idmapset, err := c.IdmapSet()
+		idmapset, err := containerDiskIdmap(c)
 		if err != nil {
 			return err
 		}
 
-		huid, hgid := idmapset.ShiftFromNs(int64(hdr.Uid), int64(hdr.Gid))
-		hdr.Uid = int(huid)
-		hdr.Gid = int(hgid)
+		if idmapset != nil {
+			huid, hgid := idmapset.ShiftFromNs(int64(hdr.Uid), int64(hdr.Gid))
+			hdr.Uid = int(huid)
+			hdr.Gid = int(hgid)
+		}
 		if hdr.Uid == -1 || hdr.Gid == -1 {
 			return nil
 		}
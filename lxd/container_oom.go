@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "gopkg.in/inconshreveable/log15.v2"
+
+	"github.com/lxc/lxd/shared/logger"
+)
+
+const containerOOMCheckInterval = 15 * time.Second
+
+// Number of OOM kills last seen in the memory cgroup of running containers
+var containerOOMLock sync.Mutex
+var containerOOMKills = map[string]int64{}
+
+// containerOOMReset is called when a container starts with a new cgroup.
+func containerOOMReset(name string) {
+	containerOOMLock.Lock()
+	containerOOMKills[name] = 0
+	containerOOMLock.Unlock()
+}
+
+func containerOOMForget(name string) {
+	containerOOMLock.Lock()
+	delete(containerOOMKills, name)
+	containerOOMLock.Unlock()
+}
+
+// containerOOMKillCount extracts the oom_kill counter from the content of
+// memory.oom_control (or memory.events with the unified hierarchy).
+func containerOOMKillCount(value string) (int64, bool) {
+	for _, line := range strings.Split(value, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "oom_kill" {
+			continue
+		}
+
+		count, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		return count, true
+	}
+
+	// Kernels older than 4.13 don't count the kills
+	return 0, false
+}
+
+// containersOOMCheck sends a container-oom lifecycle event for the running
+// containers which had processes killed by the OOM killer since last time.
+func containersOOMCheck(d *Daemon) {
+	if !cgMemoryController {
+		return
+	}
+
+	names, err := dbContainersList(d.db, cTypeRegular)
+	if err != nil {
+		logger.Error("Failed to list containers for OOM detection", log.Ctx{"err": err})
+		return
+	}
+
+	for _, name := range names {
+		c, err := containerLoadByName(d, name)
+		if err != nil || !c.IsRunning() {
+			continue
+		}
+
+		value, err := c.CGroupGet("memory.oom_control")
+		if err != nil {
+			continue
+		}
+
+		kills, ok := containerOOMKillCount(value)
+		if !ok {
+			continue
+		}
+
+		// Containers already running when LXD started only get a
+		// baseline on their first check.
+		containerOOMLock.Lock()
+		previous, known := containerOOMKills[name]
+		containerOOMKills[name] = kills
+		containerOOMLock.Unlock()
+
+		if known && kills > previous {
+			logger.Warn("Container processes killed by the OOM killer", log.Ctx{"container": name, "kills": kills - previous})
+			containerLifecycleEvent("container-oom", name)
+			containerOOMPolicy(c, kills)
+		}
+	}
+}
+
+// containerOOMAction returns what oom.policy calls for ("freeze", "restart"
+// or nothing) once a container's processes got killed kills times since it
+// started, at least oom.threshold kills being needed.
+func containerOOMAction(config map[string]string, kills int64, frozen bool) string {
+	policy := config["oom.policy"]
+	if policy == "" {
+		return ""
+	}
+
+	threshold, err := strconv.ParseInt(config["oom.threshold"], 10, 64)
+	if err != nil || threshold <= 0 {
+		threshold = 3
+	}
+
+	if kills < threshold {
+		return ""
+	}
+
+	if policy == "freeze" && frozen {
+		return ""
+	}
+
+	return policy
+}
+
+// containerOOMPolicy applies oom.policy to a container whose processes got
+// killed by the OOM killer.
+func containerOOMPolicy(c container, kills int64) {
+	action := containerOOMAction(c.ExpandedConfig(), kills, c.IsFrozen())
+
+	switch action {
+	case "freeze":
+		logger.Warn("Freezing container after repeated OOM kills", log.Ctx{"container": c.Name(), "kills": kills})
+		err := c.Freeze()
+		if err != nil {
+			logger.Error("Failed to freeze container", log.Ctx{"container": c.Name(), "err": err})
+			return
+		}
+
+		containerLifecycleEvent("container-frozen", c.Name())
+	case "restart":
+		// Restarting starts over with a new cgroup and so a new count
+		logger.Warn("Restarting container after repeated OOM kills", log.Ctx{"container": c.Name(), "kills": kills})
+		go func() {
+			err := c.Stop(false)
+			if err != nil {
+				logger.Error("Failed to stop container", log.Ctx{"container": c.Name(), "err": err})
+				return
+			}
+
+			err = c.Start(false)
+			if err != nil {
+				logger.Error("Failed to restart container", log.Ctx{"container": c.Name(), "err": err})
+				return
+			}
+
+			containerLifecycleEvent("container-restarted", c.Name())
+		}()
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestContainerOOMKillCount(t *testing.T) {
+	tests := []struct {
+		value    string
+		count    int64
+		expected bool
+	}{
+		// memory.oom_control
+		{"oom_kill_disable 0\nunder_oom 0\noom_kill 4\n", 4, true},
+		{"oom_kill_disable 0\nunder_oom 0\n", 0, false},
+		// memory.events
+		{"low 0\nhigh 0\nmax 12\noom 3\noom_kill 2\n", 2, true},
+		{"low 0\nhigh 0\nmax 12\noom 3\noom_kill 2\noom_group_kill 0\n", 2, true},
+		{"oom_kill nope\n", 0, false},
+		{"", 0, false},
+	}
+
+	for _, test := range tests {
+		count, ok := containerOOMKillCount(test.value)
+		if ok != test.expected || count != test.count {
+			t.Errorf("Expected %d (%v) for %q, got %d (%v)", test.count, test.expected, test.value, count, ok)
+		}
+	}
+}
+
+func TestContainerOOMAction(t *testing.T) {
+	tests := []struct {
+		config   map[string]string
+		kills    int64
+		frozen   bool
+		expected string
+	}{
+		{map[string]string{}, 10, false, ""},
+		{map[string]string{"oom.policy": "freeze"}, 2, false, ""},
+		{map[string]string{"oom.policy": "freeze"}, 3, false, "freeze"},
+		{map[string]string{"oom.policy": "freeze"}, 3, true, ""},
+		{map[string]string{"oom.policy": "restart", "oom.threshold": "1"}, 1, false, "restart"},
+		{map[string]string{"oom.policy": "restart", "oom.threshold": "5"}, 4, false, ""},
+		{map[string]string{"oom.policy": "restart", "oom.threshold": "0"}, 3, true, "restart"},
+	}
+
+	for i, test := range tests {
+		action := containerOOMAction(test.config, test.kills, test.frozen)
+		if action != test.expected {
+			t.Errorf("Test %d: expected %q, got %q", i, test.expected, action)
+		}
+	}
+}
@@ -72,6 +72,11 @@ func containerPatch(d *Daemon, r *http.Request) Response {
 	// Check if profiles was passed
 	if req.Profiles == nil {
 		req.Profiles = c.Profiles()
+	} else {
+		profileProject := projectProfiles(d, projectParam(r))
+		for i, profile := range req.Profiles {
+			req.Profiles[i] = projectPrefix(profileProject, profile)
+		}
 	}
 
 	// Check if config was passed
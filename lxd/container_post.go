@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 
@@ -47,7 +48,7 @@ func containerPost(d *Daemon, r *http.Request) Response {
 	}
 
 	if req.Migration {
-		ws, err := NewMigrationSource(c, stateful, req.ContainerOnly)
+		ws, err := NewMigrationSource(c, stateful, req.ContainerOnly, req.Rsync)
 		if err != nil {
 			return InternalError(err)
 		}
@@ -63,6 +64,12 @@ func containerPost(d *Daemon, r *http.Request) Response {
 		return OperationResponse(op)
 	}
 
+	// Renames stay within the container's project
+	if !shared.ValidHostname(req.Name) {
+		return BadRequest(fmt.Errorf("Container name isn't a valid hostname"))
+	}
+	req.Name = projectPrefix(projectParam(r), req.Name)
+
 	// Check that the name isn't already in use
 	id, _ := dbContainerId(d.db, req.Name)
 	if id > 0 {
@@ -45,6 +45,11 @@ func containerPut(d *Daemon, r *http.Request) Response {
 		architecture = 0
 	}
 
+	profileProject := projectProfiles(d, projectParam(r))
+	for i, profile := range configRaw.Profiles {
+		configRaw.Profiles[i] = projectPrefix(profileProject, profile)
+	}
+
 	var do = func(*operation) error { return nil }
 
 	if configRaw.Restore == "" {
@@ -3,8 +3,11 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -20,37 +23,58 @@ func containerSnapshotsGet(d *Daemon, r *http.Request) Response {
 		recursion = 0
 	}
 
+	project := projectParam(r)
 	cname := mux.Vars(r)["name"]
 	c, err := containerLoadByName(d, cname)
 	if err != nil {
 		return SmartError(err)
 	}
 
+	// The names are all there is to return without recursion, so don't
+	// load the snapshots for them.
+	if recursion == 0 {
+		names, err := dbContainerGetSnapshots(d.db, c.Name())
+		if err != nil {
+			return SmartError(err)
+		}
+
+		resultString := []string{}
+		for _, name := range names {
+			_, snapName, _ := containerGetParentAndSnapshotName(name)
+			url := fmt.Sprintf("/%s/containers/%s/snapshots/%s", version.APIVersion, projectStrip(project, cname), snapName)
+			resultString = append(resultString, url)
+		}
+
+		return SyncResponse(true, resultString)
+	}
+
 	snaps, err := c.Snapshots()
 	if err != nil {
 		return SmartError(err)
 	}
 
-	resultString := []string{}
-	resultMap := []*api.ContainerSnapshot{}
+	withSize := true
+	if r.FormValue("size") != "" {
+		withSize = shared.IsTrue(r.FormValue("size"))
+	}
 
+	resultMap := []*api.ContainerSnapshot{}
 	for _, snap := range snaps {
-		_, snapName, _ := containerGetParentAndSnapshotName(snap.Name())
-		if recursion == 0 {
-			url := fmt.Sprintf("/%s/containers/%s/snapshots/%s", version.APIVersion, cname, snapName)
-			resultString = append(resultString, url)
-		} else {
-			render, _, err := snap.Render()
-			if err != nil {
-				continue
-			}
+		render, _, err := snap.Render()
+		if err != nil {
+			continue
+		}
 
-			resultMap = append(resultMap, render.(*api.ContainerSnapshot))
+		snapshot := render.(*api.ContainerSnapshot)
+		snapshot.Name = projectStrip(project, snapshot.Name)
+
+		// Asking the storage backend can be slow with many snapshots,
+		// clients not needing the sizes can skip it.
+		if withSize {
+			snapshot.Size = snapshotSize(snap)
 		}
-	}
 
-	if recursion == 0 {
-		return SyncResponse(true, resultString)
+		resultMap = append(resultMap, snapshot)
 	}
 
 	return SyncResponse(true, resultMap)
@@ -60,8 +84,8 @@ func containerSnapshotsGet(d *Daemon, r *http.Request) Response {
  * Note, the code below doesn't deal with snapshots of snapshots.
  * To do that, we'll need to weed out based on # slashes in names
  */
-func nextSnapshot(d *Daemon, name string) int {
-	base := name + shared.SnapshotDelimiter + "snap"
+func nextSnapshot(d *Daemon, name string, prefix string) int {
+	base := name + shared.SnapshotDelimiter + prefix
 	length := len(base)
 	q := fmt.Sprintf("SELECT name FROM containers WHERE type=? AND SUBSTR(name,1,?)=?")
 	var numstr string
@@ -92,6 +116,31 @@ func nextSnapshot(d *Daemon, name string) int {
 	return max
 }
 
+// snapshotNameFromPattern generates the name of a new snapshot of a container
+// from its "snapshots.pattern" key. "%t" is replaced by the current time and
+// "%d" by the next free number for the resulting prefix.
+func snapshotNameFromPattern(d *Daemon, name string, pattern string) string {
+	if pattern == "" {
+		pattern = "snap%d"
+	}
+
+	pattern = strings.Replace(pattern, "%t", time.Now().UTC().Format("20060102-1504"), -1)
+
+	// Add a counter if the name is already taken
+	if !strings.Contains(pattern, "%d") {
+		_, err := dbContainerId(d.db, name+shared.SnapshotDelimiter+pattern)
+		if err != nil {
+			return pattern
+		}
+
+		pattern = pattern + "-%d"
+	}
+
+	fields := strings.SplitN(pattern, "%d", 2)
+	i := nextSnapshot(d, name, fields[0])
+	return fmt.Sprintf("%s%d%s", fields[0], i, fields[1])
+}
+
 func containerSnapshotsPost(d *Daemon, r *http.Request) Response {
 	name := mux.Vars(r)["name"]
 
@@ -121,8 +170,7 @@ func containerSnapshotsPost(d *Daemon, r *http.Request) Response {
 
 	if req.Name == "" {
 		// come up with a name
-		i := nextSnapshot(d, name)
-		req.Name = fmt.Sprintf("snap%d", i)
+		req.Name = snapshotNameFromPattern(d, name, c.ExpandedConfig()["snapshots.pattern"])
 	}
 
 	fullName := name +
@@ -140,6 +188,7 @@ func containerSnapshotsPost(d *Daemon, r *http.Request) Response {
 			Architecture: c.Architecture(),
 			Devices:      c.LocalDevices(),
 			Stateful:     req.Stateful,
+			Description:  req.Description,
 		}
 
 		_, err := containerCreateAsSnapshot(d, args, c)
@@ -192,18 +241,43 @@ func snapshotGet(sc container, name string) Response {
 		return SmartError(err)
 	}
 
-	return SyncResponse(true, render.(*api.ContainerSnapshot))
+	snapshot := render.(*api.ContainerSnapshot)
+	snapshot.Size = snapshotSize(sc)
+
+	return SyncResponse(true, snapshot)
+}
+
+// snapshotSize returns the disk usage of a snapshot or -1 if the storage
+// backend can't tell.
+func snapshotSize(sc container) int64 {
+	size, err := sc.SnapshotUsage()
+	if err != nil {
+		return -1
+	}
+
+	return size
 }
 
 func snapshotPost(d *Daemon, r *http.Request, sc container, containerName string) Response {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return InternalError(err)
+	}
+
 	raw := shared.Jmap{}
-	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+	if err := json.Unmarshal(body, &raw); err != nil {
 		return BadRequest(err)
 	}
 
 	migration, err := raw.GetBool("migration")
 	if err == nil && migration {
-		ws, err := NewMigrationSource(sc, false, true)
+		req := api.ContainerSnapshotPost{}
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			return BadRequest(err)
+		}
+
+		ws, err := NewMigrationSource(sc, false, true, req.Rsync)
 		if err != nil {
 			return SmartError(err)
 		}
@@ -7,9 +7,12 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	log "gopkg.in/inconshreveable/log15.v2"
 
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
 )
 
 func containerState(d *Daemon, r *http.Request) Response {
@@ -48,6 +51,26 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	do, err := containerStateDo(c, raw)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(operationClassTask, resources, nil, do, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+// containerStateDo returns the operation function applying the requested
+// state change to the container.
+func containerStateDo(c container, raw api.ContainerStatePut) (func(*operation) error, error) {
+	var err error
 	var do func(*operation) error
 	switch shared.ContainerAction(raw.Action) {
 	case shared.Start:
@@ -152,16 +175,142 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 			return c.Unfreeze()
 		}
 	default:
-		return BadRequest(fmt.Errorf("unknown action %s", raw.Action))
+		return nil, fmt.Errorf("unknown action %s", raw.Action)
 	}
 
-	resources := map[string][]string{}
-	resources["containers"] = []string{name}
+	return do, nil
+}
 
-	op, err := operationCreate(operationClassTask, resources, nil, do, nil, nil)
+// containersStatePut applies the same state change to a list of containers,
+// returning the operation of each of them.
+func containersStatePut(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+
+	req := api.ContainersStatePut{}
+
+	// Same default as for a single container
+	req.State.Timeout = -1
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	if len(req.Containers) == 0 {
+		return BadRequest(fmt.Errorf("No containers specified"))
+	}
+
+	// Don't mess with containers while in setup mode
+	<-d.readyChan
+
+	// Validate everything before starting any operation
+	dos := map[string]func(*operation) error{}
+	for _, name := range req.Containers {
+		c, err := containerLoadByName(d, projectPrefix(project, name))
+		if err != nil {
+			return SmartError(err)
+		}
+
+		dos[name], err = containerStateDo(c, req.State)
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
+	ops := map[string]*api.Operation{}
+	for _, name := range req.Containers {
+		resources := map[string][]string{}
+		resources["containers"] = []string{projectPrefix(project, name)}
+
+		op, err := operationCreate(operationClassTask, resources, nil, dos[name], nil, nil)
+		if err != nil {
+			return InternalError(err)
+		}
+
+		_, err = op.Run()
+		if err != nil {
+			return InternalError(err)
+		}
+
+		_, ops[name], err = op.Render()
+		if err != nil {
+			return InternalError(err)
+		}
+	}
+
+	return SyncResponse(true, ops)
+}
+
+// containersFreezeSchedule freezes the running containers entering one of
+// their freeze.schedule windows and thaws those it froze once it's over.
+func containersFreezeSchedule(d *Daemon) {
+	names, err := dbContainersList(d.db, cTypeRegular)
 	if err != nil {
-		return InternalError(err)
+		logger.Error("Failed to list containers for scheduled freezing", log.Ctx{"err": err})
+		return
 	}
 
-	return OperationResponse(op)
+	now := time.Now()
+	for _, name := range names {
+		c, err := containerLoadByName(d, name)
+		if err != nil {
+			continue
+		}
+
+		windows, err := shared.FreezeScheduleParse(c.ExpandedConfig()["freeze.schedule"])
+		if err != nil {
+			continue
+		}
+
+		active := false
+		for _, window := range windows {
+			if window.Active(now) {
+				active = true
+				break
+			}
+		}
+
+		// Containers thawed by hand during a window are left alone until
+		// the next one, the key only being cleared once it's over
+		scheduled := shared.IsTrue(c.LocalConfig()["volatile.freeze.scheduled"])
+		if active && !scheduled && c.IsRunning() && !c.IsFrozen() {
+			err = c.Freeze()
+			if err != nil {
+				logger.Error("Failed scheduled container freeze", log.Ctx{"container": name, "err": err})
+				continue
+			}
+
+			err = c.ConfigKeySet("volatile.freeze.scheduled", "true")
+			if err != nil {
+				logger.Error("Failed to record scheduled container freeze", log.Ctx{"container": name, "err": err})
+			}
+
+			containerLifecycleEvent("container-frozen", name)
+		} else if !active && scheduled {
+			if c.IsFrozen() {
+				err = c.Unfreeze()
+				if err != nil {
+					logger.Error("Failed scheduled container unfreeze", log.Ctx{"container": name, "err": err})
+					continue
+				}
+
+				containerLifecycleEvent("container-thawed", name)
+			}
+
+			err = c.ConfigKeySet("volatile.freeze.scheduled", "")
+			if err != nil {
+				logger.Error("Failed to clear scheduled container freeze", log.Ctx{"container": name, "err": err})
+			}
+		}
+	}
+}
+
+// containerLifecycleEvent notifies listeners of an action the daemon took
+// on a container by itself.
+func containerLifecycleEvent(action string, name string) {
+	project, name := projectSplitName(name)
+
+	eventSend("lifecycle", api.EventLifecycle{
+		Action:  action,
+		Source:  fmt.Sprintf("/%s/containers/%s", version.APIVersion, name),
+		Project: project})
 }
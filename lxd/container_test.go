@@ -390,6 +390,200 @@ func (suite *containerTestSuite) TestContainer_findIdmap_maxed() {
 	}
 }
 
+func (suite *containerTestSuite) TestContainer_ProfileChangeFlushesCache() {
+	_, err := dbProfileCreate(suite.d.db, "cached", "", map[string]string{"user.foo": "before"}, types.Devices{})
+	suite.Req.Nil(err)
+	defer dbProfileDelete(suite.d.db, "cached")
+
+	args := containerArgs{
+		Ctype:    cTypeRegular,
+		Profiles: []string{"default", "cached"},
+		Name:     "testFoo",
+	}
+
+	c, err := containerCreateInternal(suite.d, args)
+	suite.Req.Nil(err)
+	defer c.Delete()
+
+	suite.Equal("before", c.ExpandedConfig()["user.foo"])
+
+	id, _, err := dbProfileGet(suite.d.db, "cached")
+	suite.Req.Nil(err)
+
+	tx, err := dbBegin(suite.d.db)
+	suite.Req.Nil(err)
+	suite.Req.Nil(dbProfileConfigClear(tx, id))
+	suite.Req.Nil(dbProfileConfigAdd(tx, id, map[string]string{"user.foo": "after"}))
+	suite.Req.Nil(txCommit(tx))
+
+	// Without a flush, the cached profile is still used
+	c, err = containerLoadByName(suite.d, "testFoo")
+	suite.Req.Nil(err)
+	suite.Equal("before", c.ExpandedConfig()["user.foo"])
+
+	profileCacheFlush()
+	c, err = containerLoadByName(suite.d, "testFoo")
+	suite.Req.Nil(err)
+	suite.Equal("after", c.ExpandedConfig()["user.foo"])
+}
+
+func (suite *containerTestSuite) TestContainer_AutostartDependencies() {
+	containers := []container{}
+	for name, config := range map[string]map[string]string{
+		"db":  {"boot.autostart.priority": "1", "boot.depends_on": "web"},
+		"app": {"boot.autostart.priority": "5", "boot.depends_on": "db, cache"},
+		"web": {"boot.autostart.priority": "10", "boot.depends_on": "app"},
+	} {
+		c, err := containerCreateInternal(suite.d, containerArgs{
+			Ctype:  cTypeRegular,
+			Config: config,
+			Name:   name,
+		})
+		suite.Req.Nil(err)
+		defer c.Delete()
+
+		containers = append(containers, c)
+	}
+
+	dependencies, priorities := containersAutostartDependencies(containers)
+
+	// One of the edges of the cycle gets ignored
+	edges := len(dependencies["db"]) + len(dependencies["app"]) + len(dependencies["web"])
+	suite.Equal(3, edges)
+
+	// Containers start no earlier than what they depend on
+	for name, names := range dependencies {
+		for _, dependency := range names {
+			if dependency == "cache" {
+				continue
+			}
+
+			suite.True(priorities[name] <= priorities[dependency], "%s starts before its dependency %s", name, dependency)
+		}
+	}
+}
+
 func TestContainerTestSuite(t *testing.T) {
 	suite.Run(t, new(containerTestSuite))
 }
+
+// benchmarkContainers sets up a test daemon with the given number of
+// containers sharing two profiles, each with the given number of snapshots.
+// The returned function tears it down.
+func benchmarkContainers(b *testing.B, count int, snapshots int) (*containerTestSuite, func()) {
+	s := &containerTestSuite{}
+	s.SetupSuite()
+	s.SetupTest()
+	teardown := func() {
+		s.TearDownTest()
+		s.TearDownSuite()
+	}
+
+	_, err := dbProfileCreate(s.d.db, "extra", "", map[string]string{"user.foo": "bar"}, types.Devices{})
+	if err != nil {
+		teardown()
+		b.Fatal(err)
+	}
+
+	for i := 0; i < count; i++ {
+		args := containerArgs{
+			Ctype:    cTypeRegular,
+			Profiles: []string{"default", "extra"},
+			Name:     fmt.Sprintf("c%d", i),
+		}
+
+		_, err := containerCreateInternal(s.d, args)
+		if err != nil {
+			teardown()
+			b.Fatal(err)
+		}
+
+		for j := 0; j < snapshots; j++ {
+			args.Ctype = cTypeSnapshot
+			args.Name = fmt.Sprintf("c%d%ssnap%d", i, shared.SnapshotDelimiter, j)
+
+			_, err := containerCreateInternal(s.d, args)
+			if err != nil {
+				teardown()
+				b.Fatal(err)
+			}
+		}
+	}
+
+	return s, teardown
+}
+
+// BenchmarkContainersGetRecursion0 measures GET /1.0/containers with a few
+// hundred containers.
+func BenchmarkContainersGetRecursion0(b *testing.B) {
+	s, teardown := benchmarkContainers(b, 300, 0)
+	defer teardown()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := doContainersGet(s.d, projectDefault, 0, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkContainersGetRecursion1 measures GET /1.0/containers?recursion=1
+// with a few hundred containers sharing two profiles.
+func BenchmarkContainersGetRecursion1(b *testing.B) {
+	s, teardown := benchmarkContainers(b, 300, 0)
+	defer teardown()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := doContainersGet(s.d, projectDefault, 1, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkContainerGet measures loading and rendering a single container,
+// as done by GET /1.0/containers/<name>, when it has many snapshots.
+func BenchmarkContainerGet(b *testing.B) {
+	s, teardown := benchmarkContainers(b, 1, 200)
+	defer teardown()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := doContainerGet(s.d, "c0")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func (suite *containerTestSuite) TestContainer_ValidName() {
+	suite.Req.Nil(containerValidName("c1"))
+	suite.Req.NotNil(containerValidName("proj_c1"))
+	suite.Req.NotNil(containerValidName("c1/snap0"))
+
+	// Stored names of the containers in a project
+	suite.Req.Nil(containerValidInternalName("proj_c1"))
+	suite.Req.NotNil(containerValidInternalName("proj_c_1"))
+}
+
+// BenchmarkContainerSnapshots measures loading all the snapshots of a
+// container, as done when listing them with recursion.
+func BenchmarkContainerSnapshots(b *testing.B) {
+	s, teardown := benchmarkContainers(b, 1, 200)
+	defer teardown()
+
+	c, err := containerLoadByName(s.d, "c0")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := c.Snapshots()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "gopkg.in/inconshreveable/log15.v2"
+
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Number of usage samples kept for each container
+const containerUsageHistorySize = 60
+
+var containerUsageLock sync.Mutex
+var containerUsageHistory = map[string][]api.ContainerUsageSample{}
+
+// CPU utilization is sampled in the background for the containers whose
+// state was asked for recently, the state reporting the last computed value.
+const containerCPUSampleInterval = 2 * time.Second
+const containerCPUSampleMaxAge = time.Minute
+
+type containerCPUSample struct {
+	container *containerLXC
+	lastQuery time.Time
+	timestamp time.Time
+	usage     int64
+	percent   float64
+}
+
+var containerCPULock sync.Mutex
+var containerCPUSamples = map[string]*containerCPUSample{}
+
+// containerCPUPercent returns the last CPU utilization computed for a
+// container, -1 until two samples were taken.
+func containerCPUPercent(c *containerLXC) float64 {
+	containerCPULock.Lock()
+	defer containerCPULock.Unlock()
+
+	sample, ok := containerCPUSamples[c.name]
+	if !ok {
+		sample = &containerCPUSample{usage: -1, percent: -1}
+		containerCPUSamples[c.name] = sample
+	}
+
+	sample.container = c
+	sample.lastQuery = time.Now()
+
+	return sample.percent
+}
+
+// containersCPUSample reads the CPU usage of the containers whose state was
+// asked for in the last containerCPUSampleMaxAge.
+func containersCPUSample() {
+	samples := map[string]*containerCPUSample{}
+
+	containerCPULock.Lock()
+	for name, sample := range containerCPUSamples {
+		if time.Since(sample.lastQuery) > containerCPUSampleMaxAge {
+			delete(containerCPUSamples, name)
+			continue
+		}
+
+		samples[name] = sample
+	}
+	containerCPULock.Unlock()
+
+	for _, sample := range samples {
+		containerCPULock.Lock()
+		c := sample.container
+		containerCPULock.Unlock()
+
+		usage := int64(-1)
+		if c.IsRunning() {
+			usage = c.cpuUsage()
+		}
+		now := time.Now()
+
+		containerCPULock.Lock()
+		percent := float64(-1)
+		if usage != -1 && sample.usage != -1 {
+			percent = float64(usage-sample.usage) * 100 / float64(now.Sub(sample.timestamp).Nanoseconds())
+			if percent < 0 {
+				percent = 0
+			}
+		}
+
+		sample.timestamp = now
+		sample.usage = usage
+		sample.percent = percent
+		containerCPULock.Unlock()
+	}
+}
+
+func containersUsageSample(d *Daemon) {
+	names, err := dbContainersList(d.db, cTypeRegular)
+	if err != nil {
+		logger.Error("Failed to list containers for usage sampling", log.Ctx{"err": err})
+		return
+	}
+
+	samples := map[string]api.ContainerUsageSample{}
+	for _, name := range names {
+		c, err := containerLoadByName(d, name)
+		if err != nil {
+			continue
+		}
+
+		if !c.IsRunning() {
+			continue
+		}
+
+		state, err := c.RenderState()
+		if err != nil {
+			logger.Debug("Failed to sample container usage", log.Ctx{"container": name, "err": err})
+			continue
+		}
+
+		sample := api.ContainerUsageSample{
+			Timestamp: time.Now().UTC(),
+			CPU:       state.CPU.Usage,
+			Memory:    state.Memory.Usage,
+		}
+
+		for _, disk := range state.Disk {
+			sample.Disk += disk.Usage
+		}
+
+		samples[name] = sample
+	}
+
+	containerUsageLock.Lock()
+	defer containerUsageLock.Unlock()
+
+	// Forget about containers which went away
+	for name := range containerUsageHistory {
+		found := false
+		for _, entry := range names {
+			if entry == name {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			delete(containerUsageHistory, name)
+		}
+	}
+
+	for name, sample := range samples {
+		history := append(containerUsageHistory[name], sample)
+		if len(history) > containerUsageHistorySize {
+			history = history[len(history)-containerUsageHistorySize:]
+		}
+
+		containerUsageHistory[name] = history
+	}
+}
+
+func containerUsageGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	c, err := containerLoadByName(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	containerUsageLock.Lock()
+	history := make([]api.ContainerUsageSample, len(containerUsageHistory[c.Name()]))
+	copy(history, containerUsageHistory[c.Name()])
+	containerUsageLock.Unlock()
+
+	return SyncResponse(true, history)
+}
+
+var containerUsageCmd = Command{name: "containers/{name}/usage", get: containerUsageGet}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"runtime"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,6 +19,7 @@ var containersCmd = Command{
 	name: "containers",
 	get:  containersGet,
 	post: containersPost,
+	put:  containersStatePut,
 }
 
 var containerCmd = Command{
@@ -53,32 +57,122 @@ var containerSnapshotCmd = Command{
 	delete: snapshotHandler,
 }
 
+var containerSnapshotFileCmd = Command{
+	name: "containers/{name}/snapshots/{snapshotName}/files",
+	get:  snapshotFileHandler,
+}
+
 var containerExecCmd = Command{
 	name: "containers/{name}/exec",
 	post: containerExecPost,
 }
 
-type containerAutostartList []container
+// containerAutostartList orders containers by decreasing priority, as
+// returned by containersAutostartDependencies, then by name.
+type containerAutostartList struct {
+	containers []container
+	priorities map[string]int
+}
 
-func (slice containerAutostartList) Len() int {
-	return len(slice)
+func (list containerAutostartList) Len() int {
+	return len(list.containers)
 }
 
-func (slice containerAutostartList) Less(i, j int) bool {
-	iOrder := slice[i].ExpandedConfig()["boot.autostart.priority"]
-	jOrder := slice[j].ExpandedConfig()["boot.autostart.priority"]
+func (list containerAutostartList) Less(i, j int) bool {
+	iOrder := list.priorities[list.containers[i].Name()]
+	jOrder := list.priorities[list.containers[j].Name()]
 
 	if iOrder != jOrder {
-		iOrderInt, _ := strconv.Atoi(iOrder)
-		jOrderInt, _ := strconv.Atoi(jOrder)
-		return iOrderInt > jOrderInt
+		return iOrder > jOrder
 	}
 
-	return slice[i].Name() < slice[j].Name()
+	return list.containers[i].Name() < list.containers[j].Name()
 }
 
-func (slice containerAutostartList) Swap(i, j int) {
-	slice[i], slice[j] = slice[j], slice[i]
+func (list containerAutostartList) Swap(i, j int) {
+	list.containers[i], list.containers[j] = list.containers[j], list.containers[i]
+}
+
+// containerDependencies returns the internal names of the containers listed
+// in boot.depends_on, which belong to the container's project.
+func containerDependencies(c container) []string {
+	project, _ := projectSplitName(c.Name())
+
+	dependencies := []string{}
+	for _, name := range strings.Split(c.ExpandedConfig()["boot.depends_on"], ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		dependencies = append(dependencies, projectPrefix(project, name))
+	}
+
+	return dependencies
+}
+
+// containersAutostartDependencies returns the dependencies of the containers,
+// along with their boot.autostart.priority lowered to that of their
+// dependencies so those always come first. Circular dependencies are ignored.
+func containersAutostartDependencies(containers []container) (map[string][]string, map[string]int) {
+	byName := map[string]container{}
+	for _, c := range containers {
+		byName[c.Name()] = c
+	}
+
+	dependencies := map[string][]string{}
+	priorities := map[string]int{}
+	visiting := map[string]bool{}
+
+	var visit func(c container) int
+	visit = func(c container) int {
+		name := c.Name()
+
+		priority, ok := priorities[name]
+		if ok {
+			return priority
+		}
+
+		priority, _ = strconv.Atoi(c.ExpandedConfig()["boot.autostart.priority"])
+
+		visiting[name] = true
+		dependencies[name] = []string{}
+		for _, dependency := range containerDependencies(c) {
+			if visiting[dependency] {
+				logger.Warn("Ignoring circular container dependency", log.Ctx{"container": name, "dependency": dependency})
+				continue
+			}
+
+			dependencies[name] = append(dependencies[name], dependency)
+
+			dc, ok := byName[dependency]
+			if !ok {
+				continue
+			}
+
+			dependencyPriority := visit(dc)
+			if dependencyPriority < priority {
+				priority = dependencyPriority
+			}
+		}
+		visiting[name] = false
+
+		priorities[name] = priority
+		return priority
+	}
+
+	for _, c := range containers {
+		visit(c)
+	}
+
+	return dependencies, priorities
+}
+
+// containerAutostartStatus tracks a container started at boot, done being
+// closed once it's up or failed to start.
+type containerAutostartStatus struct {
+	done chan bool
+	err  error
 }
 
 func containersRestart(d *Daemon) error {
@@ -99,33 +193,166 @@ func containersRestart(d *Daemon) error {
 		containers = append(containers, c)
 	}
 
-	sort.Sort(containerAutostartList(containers))
+	maxConcurrent := int(daemonConfig["core.autostart_concurrency"].GetInt64())
+	if maxConcurrent <= 0 {
+		maxConcurrent = runtime.NumCPU()
+	}
 
-	// Restart the containers
+	toStart := []container{}
 	for _, c := range containers {
 		config := c.ExpandedConfig()
 		lastState := config["volatile.last_state.power"]
 
 		autoStart := config["boot.autostart"]
-		autoStartDelay := config["boot.autostart.delay"]
+		if !shared.IsTrue(autoStart) && (autoStart != "" || lastState != "RUNNING") {
+			continue
+		}
 
-		if shared.IsTrue(autoStart) || (autoStart == "" && lastState == "RUNNING") {
-			if c.IsRunning() {
-				continue
+		if c.IsRunning() {
+			continue
+		}
+
+		toStart = append(toStart, c)
+	}
+
+	dependencies, priorities := containersAutostartDependencies(toStart)
+	sort.Sort(containerAutostartList{containers: toStart, priorities: priorities})
+
+	statuses := map[string]*containerAutostartStatus{}
+	for _, c := range toStart {
+		statuses[c.Name()] = &containerAutostartStatus{done: make(chan bool)}
+	}
+
+	// Dependencies which aren't started at boot must already be running
+	byName := map[string]container{}
+	for _, c := range containers {
+		byName[c.Name()] = c
+	}
+
+	wait := func(c container) error {
+		for _, dependency := range dependencies[c.Name()] {
+			_, name := projectSplitName(dependency)
+
+			dc, ok := byName[dependency]
+			if !ok {
+				return fmt.Errorf("Dependency '%s' doesn't exist", name)
 			}
 
-			c.Start(false)
+			status, ok := statuses[dependency]
+			if ok {
+				<-status.done
+				if status.err != nil {
+					return fmt.Errorf("Dependency '%s' failed to start", name)
+				}
+			} else if !dc.IsRunning() {
+				return fmt.Errorf("Dependency '%s' isn't running", name)
+			}
 
-			autoStartDelayInt, err := strconv.Atoi(autoStartDelay)
-			if err == nil {
-				time.Sleep(time.Duration(autoStartDelayInt) * time.Second)
+			// Dependencies with a health probe must also pass it
+			err := containerHealthWait(dc)
+			if err != nil {
+				return fmt.Errorf("Dependency '%s' isn't healthy: %v", name, err)
 			}
 		}
+
+		return nil
+	}
+
+	// Restart the containers, one priority at a time
+	group := []container{}
+	var lastPriority int
+	for _, c := range toStart {
+		priority := priorities[c.Name()]
+		if len(group) > 0 && priority != lastPriority {
+			containersAutostartGroup(group, maxConcurrent, wait, statuses)
+			group = []container{}
+		}
+		lastPriority = priority
+
+		group = append(group, c)
 	}
+	containersAutostartGroup(group, maxConcurrent, wait, statuses)
 
 	return nil
 }
 
+// containersAutostartGroup starts containers sharing the same priority, at
+// most maxConcurrent at a time, and returns once all of them are done. A
+// container keeps its slot for its boot.autostart.delay after starting, so a
+// limit of 1 starts them one after the other like it used to. Containers
+// only take a slot once wait, which blocks on their dependencies, returned.
+func containersAutostartGroup(containers []container, maxConcurrent int, wait func(c container) error, statuses map[string]*containerAutostartStatus) {
+	var wg sync.WaitGroup
+
+	slots := make(chan bool, maxConcurrent)
+	for _, c := range containers {
+		wg.Add(1)
+
+		go func(c container) {
+			defer wg.Done()
+
+			status := statuses[c.Name()]
+
+			status.err = wait(c)
+			if status.err != nil {
+				close(status.done)
+				logger.Error("Failed to start container", log.Ctx{"container": c.Name(), "err": status.err})
+				return
+			}
+
+			slots <- true
+			defer func() {
+				<-slots
+			}()
+
+			status.err = containerAutostart(c)
+			close(status.done)
+			if status.err != nil {
+				logger.Error("Failed to start container", log.Ctx{"container": c.Name(), "err": status.err})
+				return
+			}
+
+			autoStartDelayInt, err := strconv.Atoi(c.ExpandedConfig()["boot.autostart.delay"])
+			if err == nil {
+				time.Sleep(time.Duration(autoStartDelayInt) * time.Second)
+			}
+		}(c)
+	}
+	wg.Wait()
+}
+
+// containerAutostart starts a container, restoring the state it was stopped
+// with if any. A state which can't be restored anymore, say after a kernel
+// or CRIU update, is dropped in favor of a normal boot.
+func containerAutostart(c container) error {
+	if !c.IsStateful() {
+		return c.Start(false)
+	}
+
+	err := c.Start(true)
+	if err == nil || c.IsRunning() {
+		return err
+	}
+
+	logger.Warn("Failed to restore the container state, booting it instead", log.Ctx{"container": c.Name(), "err": err})
+	return c.Start(false)
+}
+
+// containerShutdownStateful checkpoints a container being stopped with the
+// host, falling back to a clean shutdown if that fails.
+func containerShutdownStateful(c container, timeout time.Duration) {
+	err := c.Stop(true)
+	if err == nil {
+		return
+	}
+
+	logger.Warn("Failed to save the container state, shutting it down instead", log.Ctx{"container": c.Name(), "err": err})
+	if c.IsRunning() {
+		c.Shutdown(timeout)
+		c.Stop(false)
+	}
+}
+
 func containersShutdown(d *Daemon) error {
 	var wg sync.WaitGroup
 
@@ -135,18 +362,35 @@ func containersShutdown(d *Daemon) error {
 		return err
 	}
 
+	containers := []container{}
+	for _, r := range results {
+		// Load the container
+		c, err := containerLoadByName(d, r)
+		if err != nil {
+			return err
+		}
+
+		containers = append(containers, c)
+	}
+
+	// Stop the containers in the reverse of their startup order
+	_, priorities := containersAutostartDependencies(containers)
+	sort.Sort(sort.Reverse(containerAutostartList{containers: containers, priorities: priorities}))
+
 	// Reset all container states
 	_, err = dbExec(d.db, "DELETE FROM containers_config WHERE key='volatile.last_state.power'")
 	if err != nil {
 		return err
 	}
 
-	for _, r := range results {
-		// Load the container
-		c, err := containerLoadByName(d, r)
-		if err != nil {
-			return err
+	var lastPriority int
+	for i, c := range containers {
+		// Wait for the previous priority to be down before moving on
+		priority := priorities[c.Name()]
+		if i > 0 && priority != lastPriority {
+			wg.Wait()
 		}
+		lastPriority = priority
 
 		// Record the current state
 		lastState := c.State()
@@ -164,13 +408,18 @@ func containersShutdown(d *Daemon) error {
 
 			// Stop the container
 			wg.Add(1)
-			go func() {
-				c.Shutdown(time.Second * time.Duration(timeoutSeconds))
-				c.Stop(false)
+			go func(c container, lastState string) {
+				timeout := time.Second * time.Duration(timeoutSeconds)
+				if shared.IsTrue(c.ExpandedConfig()["boot.host_shutdown_stateful"]) {
+					containerShutdownStateful(c, timeout)
+				} else {
+					c.Shutdown(timeout)
+					c.Stop(false)
+				}
 				c.ConfigKeySet("volatile.last_state.power", lastState)
 
 				wg.Done()
-			}()
+			}(c, lastState)
 		} else {
 			c.ConfigKeySet("volatile.last_state.power", lastState)
 		}
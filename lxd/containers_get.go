@@ -3,6 +3,9 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/lxc/lxd/shared/api"
@@ -10,10 +13,34 @@ import (
 	"github.com/lxc/lxd/shared/version"
 )
 
+// containersGetWorkers is how many containers get their state rendered at
+// once when listing them with recursion.
+const containersGetWorkers = 10
+
 func containersGet(d *Daemon, r *http.Request) Response {
+	filters, err := containersParseFilters(r.URL.Query()["filter"])
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	recursion, err := strconv.Atoi(r.FormValue("recursion"))
+	if err != nil {
+		recursion = 0
+	}
+
+	params, err := listParamsGet(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	for i := 0; i < 100; i++ {
-		result, err := doContainersGet(d, d.isRecursionRequest(r))
+		result, err := doContainersGet(d, projectParam(r), recursion, filters)
 		if err == nil {
+			result, err = params.apply(result, "name")
+			if err != nil {
+				return BadRequest(err)
+			}
+
 			return SyncResponse(true, result)
 		}
 		if !isDbLockedError(err) {
@@ -30,22 +57,74 @@ func containersGet(d *Daemon, r *http.Request) Response {
 	return InternalError(fmt.Errorf("DB is locked"))
 }
 
-func doContainersGet(d *Daemon, recursion bool) (interface{}, error) {
+// containersParseFilters parses the "key=value" config filters of a
+// container listing.
+func containersParseFilters(values []string) (map[string]string, error) {
+	filters := map[string]string{}
+	for _, value := range values {
+		fields := strings.SplitN(value, "=", 2)
+		if len(fields) != 2 || fields[0] == "" {
+			return nil, fmt.Errorf("Invalid filter, expected key=value: %s", value)
+		}
+
+		filters[fields[0]] = fields[1]
+	}
+
+	return filters, nil
+}
+
+// containerMatchesFilters returns whether all the filtered config keys of a
+// container have the requested values, unset keys matching empty values.
+func containerMatchesFilters(d *Daemon, name string, filters map[string]string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+
+	c, err := containerLoadByName(d, name)
+	if err != nil {
+		return false
+	}
+
+	config := c.ExpandedConfig()
+	for key, value := range filters {
+		if config[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func doContainersGet(d *Daemon, project string, recursion int, filters map[string]string) (interface{}, error) {
 	result, err := dbContainersList(d.db, cTypeRegular)
 	if err != nil {
 		return nil, err
 	}
 
-	resultString := []string{}
-	resultList := []*api.Container{}
+	projects, err := dbProjects(d.db)
 	if err != nil {
-		return []string{}, err
+		return nil, err
 	}
 
+	resultString := []string{}
+	resultList := []*api.Container{}
+	resultFullList := []*api.ContainerFull{}
+	fullNames := []string{}
+
 	for _, container := range result {
-		if !recursion {
-			url := fmt.Sprintf("/%s/containers/%s", version.APIVersion, container)
+		if !projectOwns(projects, project, container) {
+			continue
+		}
+
+		if !containerMatchesFilters(d, container, filters) {
+			continue
+		}
+
+		if recursion == 0 {
+			url := fmt.Sprintf("/%s/containers/%s", version.APIVersion, projectStrip(project, container))
 			resultString = append(resultString, url)
+		} else if recursion > 1 {
+			fullNames = append(fullNames, container)
 		} else {
 			c, err := doContainerGet(d, container)
 			if err != nil {
@@ -54,17 +133,67 @@ func doContainersGet(d *Daemon, recursion bool) (interface{}, error) {
 					Status:     api.Error.String(),
 					StatusCode: api.Error}
 			}
+
+			projectStripContainer(d, project, c)
 			resultList = append(resultList, c)
 		}
 	}
 
-	if !recursion {
+	if recursion == 0 {
 		return resultString, nil
 	}
 
+	if recursion > 1 {
+		resultFullList = doContainersFullGet(d, fullNames)
+		for _, c := range resultFullList {
+			projectStripContainer(d, project, &c.Container)
+			for i := range c.Snapshots {
+				c.Snapshots[i].Name = projectStrip(project, c.Snapshots[i].Name)
+			}
+		}
+
+		return resultFullList, nil
+	}
+
 	return resultList, nil
 }
 
+// doContainersFullGet renders containers along with their state and
+// snapshots, in the given order, containersGetWorkers at a time.
+func doContainersFullGet(d *Daemon, names []string) []*api.ContainerFull {
+	results := make([]*api.ContainerFull, len(names))
+
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < containersGetWorkers && i < len(names); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range indexes {
+				c, err := doContainerFullGet(d, names[index])
+				if err != nil {
+					c = &api.ContainerFull{Container: api.Container{
+						Name:       names[index],
+						Status:     api.Error.String(),
+						StatusCode: api.Error}}
+				}
+
+				results[index] = c
+			}
+		}()
+	}
+
+	for i := range names {
+		indexes <- i
+	}
+	close(indexes)
+
+	wg.Wait()
+
+	return results
+}
+
 func doContainerGet(d *Daemon, cname string) (*api.Container, error) {
 	c, err := containerLoadByName(d, cname)
 	if err != nil {
@@ -78,3 +207,42 @@ func doContainerGet(d *Daemon, cname string) (*api.Container, error) {
 
 	return cts.(*api.Container), nil
 }
+
+// doContainerFullGet renders a container along with its state and snapshots.
+func doContainerFullGet(d *Daemon, cname string) (*api.ContainerFull, error) {
+	c, err := containerLoadByName(d, cname)
+	if err != nil {
+		return nil, err
+	}
+
+	cts, _, err := c.Render()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := c.RenderState()
+	if err != nil {
+		return nil, err
+	}
+
+	snaps, err := c.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []api.ContainerSnapshot{}
+	for _, snap := range snaps {
+		render, _, err := snap.Render()
+		if err != nil {
+			continue
+		}
+
+		snapshots = append(snapshots, *render.(*api.ContainerSnapshot))
+	}
+
+	return &api.ContainerFull{
+		Container: *cts.(*api.Container),
+		State:     state,
+		Snapshots: snapshots,
+	}, nil
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/dustinkirkland/golang-petname"
 	"github.com/gorilla/websocket"
 
+	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -95,12 +96,24 @@ func createFromImage(d *Daemon, req *api.ContainersPost) Response {
 
 		var info *api.Image
 		if req.Source.Server != "" {
+			// Keep the image cached until the container is created,
+			// then prune the cache if it grew too large.
+			imagesCacheHold(hash)
+			defer imagesCacheCheckSize(d)
+			defer imagesCacheRelease(hash)
+
 			info, err = d.ImageDownload(
 				op, req.Source.Server, req.Source.Protocol, req.Source.Certificate, req.Source.Secret,
 				hash, true, daemonConfig["images.auto_update_cached"].GetBool(), "", true)
 			if err != nil {
 				return err
 			}
+
+			// The alias may have resolved to another fingerprint
+			if info.Fingerprint != hash {
+				imagesCacheHold(info.Fingerprint)
+				defer imagesCacheRelease(info.Fingerprint)
+			}
 		} else {
 			_, info, err = dbImageGet(d.db, hash, false, false)
 			if err != nil {
@@ -314,7 +327,7 @@ func createFromMigration(d *Daemon, req *api.ContainersPost) Response {
 			return InternalError(err)
 		}
 
-		if ps.MigrationType() == MigrationFSType_RSYNC {
+		if ps.MigrationType() == migration.MigrationFSType_RSYNC {
 			c, err = containerCreateFromImage(d, args, req.Source.BaseImage)
 			if err != nil {
 				return InternalError(err)
@@ -494,6 +507,27 @@ func createFromCopy(d *Daemon, req *api.ContainersPost) Response {
 func containersPost(d *Daemon, r *http.Request) Response {
 	logger.Debugf("Responding to container create")
 
+	project := projectParam(r)
+
+	// Restoring a container from a backup tarball
+	if r.Header.Get("Content-Type") == "application/octet-stream" {
+		name := r.Header.Get("X-LXD-name")
+		if project != projectDefault {
+			if name == "" {
+				return BadRequest(fmt.Errorf("A container name must be provided when restoring into a project"))
+			}
+
+			err := projectCheckContainerLimit(d, project)
+			if err != nil {
+				return BadRequest(err)
+			}
+
+			name = projectPrefix(project, name)
+		}
+
+		return createFromBackup(d, r.Body, name, r.Header.Get("X-LXD-pool"))
+	}
+
 	req := api.ContainersPost{}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return BadRequest(err)
@@ -515,7 +549,7 @@ func containersPost(d *Daemon, r *http.Request) Response {
 		for {
 			i++
 			req.Name = strings.ToLower(petname.Generate(2, "-"))
-			if !shared.StringInSlice(req.Name, cs) {
+			if !shared.StringInSlice(projectPrefix(project, req.Name), cs) {
 				break
 			}
 
@@ -538,6 +572,33 @@ func containersPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("Invalid container name: '%s' is reserved for snapshots", shared.SnapshotDelimiter))
 	}
 
+	// Underscores are only valid in the internal names
+	if !shared.ValidHostname(req.Name) {
+		return BadRequest(fmt.Errorf("Container name isn't a valid hostname"))
+	}
+
+	// Map the names to those used internally for the project
+	if project != projectDefault {
+		err = projectCheckContainerLimit(d, project)
+		if err != nil {
+			return BadRequest(err)
+		}
+
+		if req.Profiles == nil {
+			req.Profiles = []string{"default"}
+		}
+
+		profileProject := projectProfiles(d, project)
+		for i, profile := range req.Profiles {
+			req.Profiles[i] = projectPrefix(profileProject, profile)
+		}
+
+		req.Name = projectPrefix(project, req.Name)
+		if req.Source.Type == "copy" {
+			req.Source.Source = projectPrefix(project, req.Source.Source)
+		}
+	}
+
 	switch req.Source.Type {
 	case "image":
 		return createFromImage(d, &req)
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStatefulContainer records the power actions taken on it, failing the
+// stateful ones when asked to.
+type fakeStatefulContainer struct {
+	container
+
+	stateful     bool
+	running      bool
+	failStateful bool
+	actions      []string
+}
+
+func (c *fakeStatefulContainer) Name() string       { return "c1" }
+func (c *fakeStatefulContainer) IsStateful() bool   { return c.stateful }
+func (c *fakeStatefulContainer) IsRunning() bool    { return c.running }
+func (c *fakeStatefulContainer) action(name string) { c.actions = append(c.actions, name) }
+
+func (c *fakeStatefulContainer) Start(stateful bool) error {
+	if stateful {
+		c.action("restore")
+		if c.failStateful {
+			return fmt.Errorf("restore failed")
+		}
+	} else {
+		c.action("start")
+	}
+
+	c.running = true
+	return nil
+}
+
+func (c *fakeStatefulContainer) Stop(stateful bool) error {
+	if stateful {
+		c.action("checkpoint")
+		if c.failStateful {
+			return fmt.Errorf("checkpoint failed")
+		}
+	} else {
+		c.action("stop")
+	}
+
+	c.running = false
+	return nil
+}
+
+func (c *fakeStatefulContainer) Shutdown(timeout time.Duration) error {
+	c.action("shutdown")
+	c.running = false
+	return nil
+}
+
+func TestContainerAutostart(t *testing.T) {
+	tests := []struct {
+		stateful     bool
+		failStateful bool
+		expected     string
+	}{
+		{false, false, "start"},
+		{true, false, "restore"},
+		{true, true, "restore start"},
+	}
+
+	for i, test := range tests {
+		c := &fakeStatefulContainer{stateful: test.stateful, failStateful: test.failStateful}
+
+		err := containerAutostart(c)
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %v", i, err)
+		}
+
+		actions := strings.Join(c.actions, " ")
+		if actions != test.expected || !c.running {
+			t.Errorf("Test %d: expected %q, got %q (running: %v)", i, test.expected, actions, c.running)
+		}
+	}
+}
+
+func TestContainerShutdownStateful(t *testing.T) {
+	tests := []struct {
+		failStateful bool
+		expected     string
+	}{
+		{false, "checkpoint"},
+		{true, "checkpoint shutdown stop"},
+	}
+
+	for i, test := range tests {
+		c := &fakeStatefulContainer{running: true, failStateful: test.failStateful}
+
+		containerShutdownStateful(c, time.Second)
+
+		actions := strings.Join(c.actions, " ")
+		if actions != test.expected || c.running {
+			t.Errorf("Test %d: expected %q, got %q (running: %v)", i, test.expected, actions, c.running)
+		}
+	}
+}
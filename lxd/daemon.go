@@ -22,6 +22,7 @@ import (
 	"syscall"
 	"time"
 
+	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/scrypt"
 
 	"github.com/gorilla/mux"
@@ -52,10 +53,21 @@ var cgCpuController = false
 var cgCpuacctController = false
 var cgCpusetController = false
 var cgDevicesController = false
+var cgHugetlbController = false
 var cgMemoryController = false
 var cgNetPrioController = false
 var cgPidsController = false
 var cgSwapAccounting = false
+var cgUnified = false
+
+// Shiftfs
+var shiftfsAvailable = false
+
+// CRIU
+var criuAvailable = false
+
+// Storage drivers whose tools are available, with their version
+var storageDriversAvailable = map[string]string{}
 
 // UserNS
 var runningInUserns = false
@@ -80,6 +92,7 @@ type Daemon struct {
 	pruneChan           chan bool
 	shutdownChan        chan bool
 	resetAutoUpdateChan chan bool
+	resetUsageChan      chan bool
 
 	TCPSocket  *Socket
 	UnixSocket *Socket
@@ -249,7 +262,7 @@ func (d *Daemon) createCmd(version string, c Command) {
 		switch r.Method {
 		case "GET":
 			if c.get != nil {
-				resp = c.get(d, r)
+				resp = conditionalResponse(r, c.get(d, r))
 			}
 		case "PUT":
 			if c.put != nil {
@@ -500,6 +513,25 @@ func (d *Daemon) UpdateHTTPsPort(newAddress string) error {
 	return nil
 }
 
+// UpdateLogging re-applies the logging configuration, overriding the log level
+// and adding a log target and remote syslog on top of what was requested on
+// the command line.
+func (d *Daemon) UpdateLogging(level string, target string, syslogAddress string) error {
+	syslog := ""
+	if *argSyslog {
+		syslog = "lxd"
+	}
+
+	targets := []string{}
+	for _, value := range []string{target, syslogAddress} {
+		if value != "" {
+			targets = append(targets, value)
+		}
+	}
+
+	return logging.Reconfigure(logger.Log, syslog, *argLogfile, verbose, debug, level, targets, eventsHandler{})
+}
+
 func haveMacAdmin() bool {
 	c, err := capability.NewPid(0)
 	if err != nil {
@@ -634,51 +666,77 @@ func (d *Daemon) Init() error {
 	}
 
 	/* Detect CGroup support */
-	cgBlkioController = shared.PathExists("/sys/fs/cgroup/blkio/")
+	cgUnified = cGroupIsUnified()
+	if cgUnified {
+		logger.Infof("Using the unified CGroup hierarchy")
+	}
+
+	cgBlkioController = cGroupHasController("blkio")
 	if !cgBlkioController {
 		logger.Warnf("Couldn't find the CGroup blkio controller, I/O limits will be ignored.")
 	}
 
-	cgCpuController = shared.PathExists("/sys/fs/cgroup/cpu/")
+	cgCpuController = cGroupHasController("cpu")
 	if !cgCpuController {
 		logger.Warnf("Couldn't find the CGroup CPU controller, CPU time limits will be ignored.")
 	}
 
-	cgCpuacctController = shared.PathExists("/sys/fs/cgroup/cpuacct/")
+	cgCpuacctController = cGroupHasController("cpuacct")
 	if !cgCpuacctController {
 		logger.Warnf("Couldn't find the CGroup CPUacct controller, CPU accounting will not be available.")
 	}
 
-	cgCpusetController = shared.PathExists("/sys/fs/cgroup/cpuset/")
+	cgCpusetController = cGroupHasController("cpuset")
 	if !cgCpusetController {
 		logger.Warnf("Couldn't find the CGroup CPUset controller, CPU pinning will be ignored.")
 	}
 
-	cgDevicesController = shared.PathExists("/sys/fs/cgroup/devices/")
+	cgDevicesController = cGroupHasController("devices")
 	if !cgDevicesController {
 		logger.Warnf("Couldn't find the CGroup devices controller, device access control won't work.")
 	}
 
-	cgMemoryController = shared.PathExists("/sys/fs/cgroup/memory/")
+	cgHugetlbController = cGroupHasController("hugetlb")
+	if !cgHugetlbController {
+		logger.Warnf("Couldn't find the CGroup hugetlb controller, hugepage limits will be ignored.")
+	}
+
+	cgMemoryController = cGroupHasController("memory")
 	if !cgMemoryController {
 		logger.Warnf("Couldn't find the CGroup memory controller, memory limits will be ignored.")
 	}
 
-	cgNetPrioController = shared.PathExists("/sys/fs/cgroup/net_prio/")
+	cgNetPrioController = cGroupHasController("net_prio")
 	if !cgNetPrioController {
 		logger.Warnf("Couldn't find the CGroup network class controller, network limits will be ignored.")
 	}
 
-	cgPidsController = shared.PathExists("/sys/fs/cgroup/pids/")
+	cgPidsController = cGroupHasController("pids")
 	if !cgPidsController {
 		logger.Warnf("Couldn't find the CGroup pids controller, process limits will be ignored.")
 	}
 
-	cgSwapAccounting = shared.PathExists("/sys/fs/cgroup/memory/memory.memsw.limit_in_bytes")
+	cgSwapAccounting = cGroupHasSwapAccounting()
 	if !cgSwapAccounting {
 		logger.Warnf("CGroup memory swap accounting is disabled, swap limits will be ignored.")
 	}
 
+	/* Detect shiftfs support */
+	if shared.IsTrue(os.Getenv("LXD_SHIFTFS_DISABLE")) {
+		logger.Infof("Shiftfs support has been disabled, container filesystems will be shifted on disk")
+	} else {
+		shiftfsAvailable = shiftfsCanUse()
+		if shiftfsAvailable {
+			logger.Infof("Using shiftfs, container filesystems won't be shifted on disk")
+		}
+	}
+
+	/* Detect CRIU and the available storage drivers */
+	_, err = exec.LookPath("criu")
+	criuAvailable = err == nil
+
+	storageDriversAvailable = storageDriversProbe()
+
 	/* Get the list of supported architectures */
 	var architectures = []int{}
 
@@ -709,6 +767,10 @@ func (d *Daemon) Init() error {
 	if err := os.MkdirAll(shared.VarPath(), 0711); err != nil {
 		return err
 	}
+	if err := os.MkdirAll(shared.VarPath("backups"), 0700); err != nil {
+		return err
+	}
+
 	if err := os.MkdirAll(shared.CachePath(), 0700); err != nil {
 		return err
 	}
@@ -807,6 +869,17 @@ func (d *Daemon) Init() error {
 	}
 
 	if !d.MockMode {
+		/* Apply the logging configuration */
+		logLevel := daemonConfig["core.log_level"].Get()
+		logTarget := daemonConfig["core.log_target"].Get()
+		syslogAddress := daemonConfig["core.syslog_address"].Get()
+		if logLevel != "" || logTarget != "" || syslogAddress != "" {
+			err = d.UpdateLogging(logLevel, logTarget, syslogAddress)
+			if err != nil {
+				logger.Error("Failed to apply the logging configuration", log.Ctx{"err": err})
+			}
+		}
+
 		/* Read the storage pools */
 		err = d.SetupStorageDriver(false)
 		if err != nil {
@@ -893,6 +966,12 @@ func (d *Daemon) Init() error {
 			return err
 		}
 
+		// Its names are checked on each handshake
+		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return err
+		}
+
 		tlsConfig := &tls.Config{
 			ClientAuth:   tls.RequestClientCert,
 			Certificates: []tls.Certificate{cert},
@@ -902,6 +981,7 @@ func (d *Daemon) Init() error {
 				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
 				tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA},
 			PreferServerCipherSuites: true,
+			NextProtos:               []string{"http/1.1", acme.ALPNProto},
 		}
 
 		if shared.PathExists(shared.VarPath("server.ca")) {
@@ -920,6 +1000,19 @@ func (d *Daemon) Init() error {
 
 		tlsConfig.BuildNameToCertificate()
 
+		// Trusted certificate presented to the clients asking for its names
+		tlsConfig.GetCertificate = d.getCertificate
+
+		err = serverCertificateLoad(daemonConfig["core.https_certificate"].Get(), daemonConfig["core.https_certificate_key"].Get())
+		if err != nil {
+			logger.Error("Failed to load the HTTPS certificate", log.Ctx{"err": err})
+		}
+
+		err = serverACMESetup(serverACMEConfig())
+		if err != nil {
+			logger.Error("Failed to set up ACME", log.Ctx{"err": err})
+		}
+
 		d.tlsConfig = tlsConfig
 
 		readSavedClientCAList(d)
@@ -1045,6 +1138,16 @@ func (d *Daemon) Init() error {
 		d.tomb.Go(func() error { return http.Serve(d.TCPSocket.Socket, &lxdHttpServer{d.mux, d}) })
 	}
 
+	// Serve the network zones
+	dnsAddress := daemonConfig["core.dns_address"].Get()
+	if dnsAddress != "" && !d.MockMode {
+		logger.Info(" - binding DNS socket", log.Ctx{"address": dnsAddress})
+		err := dnsServerStart(d, dnsAddress)
+		if err != nil {
+			logger.Error("cannot listen on DNS socket, skipping...", log.Ctx{"err": err})
+		}
+	}
+
 	// Run the post initialization actions
 	if !d.MockMode && !d.SetupMode {
 		err := d.Ready()
@@ -1053,6 +1156,34 @@ func (d *Daemon) Init() error {
 		}
 	}
 
+	// In setup mode, we're ready as soon as the API is available
+	if d.SetupMode {
+		err := sdNotify("READY=1")
+		if err != nil {
+			logger.Warn("Failed to notify systemd", log.Ctx{"err": err})
+		}
+	}
+
+	// Ping the systemd watchdog as long as the database is responsive
+	interval := sdWatchdogInterval()
+	if !d.MockMode && interval > 0 {
+		logger.Info("Enabling the systemd watchdog", log.Ctx{"interval": interval})
+
+		go func() {
+			for {
+				time.Sleep(interval)
+
+				err := d.db.Ping()
+				if err != nil {
+					logger.Error("Skipping systemd watchdog ping", log.Ctx{"err": err})
+					continue
+				}
+
+				sdNotify("WATCHDOG=1")
+			}
+		}()
+	}
+
 	return nil
 }
 
@@ -1107,6 +1238,96 @@ func (d *Daemon) Ready() error {
 		}
 	}()
 
+	/* Sample container resource usage */
+	d.resetUsageChan = make(chan bool)
+	go func() {
+		for {
+			interval := daemonConfig["core.usage_history_interval"].GetInt64()
+			if interval > 0 {
+				timer := time.NewTimer(time.Duration(interval) * time.Second)
+				timeChan := timer.C
+
+				select {
+				case <-timeChan:
+					containersUsageSample(d)
+				case <-d.resetUsageChan:
+					timer.Stop()
+				}
+			} else {
+				select {
+				case <-d.resetUsageChan:
+					continue
+				}
+			}
+		}
+	}()
+
+	/* Sample the CPU utilization of containers */
+	go func() {
+		for {
+			containersCPUSample()
+			time.Sleep(containerCPUSampleInterval)
+		}
+	}()
+
+	/* Scheduled container backups */
+	go func() {
+		for {
+			containersBackupSchedule(d)
+			time.Sleep(time.Minute)
+		}
+	}()
+
+	/* Scheduled container freezing */
+	go func() {
+		for {
+			containersFreezeSchedule(d)
+			time.Sleep(time.Minute)
+		}
+	}()
+
+	/* Forward console logs to core.syslog_address */
+	go func() {
+		for {
+			containersConsoleForward(d)
+			time.Sleep(consoleForwardInterval)
+		}
+	}()
+
+	/* Detect OOM kills in containers */
+	go func() {
+		for {
+			containersOOMCheck(d)
+			time.Sleep(containerOOMCheckInterval)
+		}
+	}()
+
+	/* Run the container health probes */
+	go func() {
+		containersHealthInit(d)
+
+		for {
+			containersHealthCheck(d)
+			time.Sleep(containerHealthCheckInterval)
+		}
+	}()
+
+	/* Reload the HTTPS certificate when renewed */
+	go func() {
+		for {
+			time.Sleep(serverCertificateCheckInterval)
+			serverCertificateCheck()
+		}
+	}()
+
+	/* Detect host misconfigurations */
+	go func() {
+		for {
+			warningsCheck(d)
+			time.Sleep(time.Hour)
+		}
+	}()
+
 	/* Restore containers */
 	containersRestart(d)
 
@@ -1115,6 +1336,12 @@ func (d *Daemon) Ready() error {
 
 	close(d.readyChan)
 
+	/* Let systemd know we're done starting up */
+	err := sdNotify("READY=1")
+	if err != nil {
+		logger.Warn("Failed to notify systemd", log.Ctx{"err": err})
+	}
+
 	return nil
 }
 
@@ -1162,6 +1389,8 @@ var errStop = fmt.Errorf("requested stop")
 func (d *Daemon) Stop() error {
 	forceStop := false
 
+	sdNotify("STOPPING=1")
+
 	d.tomb.Kill(errStop)
 	logger.Infof("Stopping REST API handler:")
 	for _, socket := range []*Socket{d.TCPSocket, d.UnixSocket} {
@@ -1178,6 +1407,8 @@ func (d *Daemon) Stop() error {
 		}
 	}
 
+	dnsServerStop()
+
 	logger.Infof("Stopping /dev/lxd handler")
 	d.devlxd.Close()
 	logger.Infof("Stopped /dev/lxd handler")
@@ -1194,7 +1425,7 @@ func (d *Daemon) Stop() error {
 	}
 
 	logger.Infof("Closing the database")
-	d.db.Close()
+	dbClose(d.db)
 
 	logger.Infof("Saving simplestreams cache")
 	imageSaveStreamCache()
@@ -1390,6 +1621,13 @@ func (s *lxdHttpServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Requests made against a project use internal object names
+	resp := projectRewriteRequest(s.d, req)
+	if resp != nil && s.d.isTrustedClient(req) {
+		resp.Render(rw)
+		return
+	}
+
 	// Call the original server
 	s.r.ServeHTTP(rw, req)
 }
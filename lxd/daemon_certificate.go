@@ -0,0 +1,325 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	log "gopkg.in/inconshreveable/log15.v2"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+/* The HTTPS endpoint presents the server certificate (server.crt), which
+ * clients pin when adding the server as a remote. A certificate trusted by
+ * browsers and the system CAs can be set up on top of it, either loaded from
+ * core.https_certificate (reloaded whenever the file changes) or obtained and
+ * renewed through ACME for acme.domain.
+ *
+ * That certificate is presented to the clients asking for one of its names
+ * (SNI), the others, such as remotes added by address, still getting the
+ * server certificate. As the LXD clients ask for the first name of the
+ * certificate they pinned, the names of the server certificate (the
+ * hostname) always get it.
+ */
+
+// serverCertificateCheckInterval is how often the certificate files are
+// checked for changes
+const serverCertificateCheckInterval = time.Minute
+
+type serverCertificateFile struct {
+	certPath string
+	keyPath  string
+	modTime  time.Time
+
+	cert  *tls.Certificate
+	names []string
+}
+
+var serverCertificate *serverCertificateFile
+var serverCertificateLock sync.Mutex
+
+var serverACMEManager *autocert.Manager
+var serverACMEDomain string
+var serverACMEListener net.Listener
+var serverACMELock sync.Mutex
+
+// serverCertificateModTime returns the last modification time of the
+// certificate and key files.
+func serverCertificateModTime(certPath string, keyPath string) time.Time {
+	modTime := time.Time{}
+	for _, path := range []string{certPath, keyPath} {
+		fi, err := os.Stat(path)
+		if err == nil && fi.ModTime().After(modTime) {
+			modTime = fi.ModTime()
+		}
+	}
+
+	return modTime
+}
+
+// serverCertificateLoad loads the certificate presented for its names, an
+// empty key path meaning the key is in the certificate file.
+func serverCertificateLoad(certPath string, keyPath string) error {
+	if certPath == "" {
+		serverCertificateLock.Lock()
+		serverCertificate = nil
+		serverCertificateLock.Unlock()
+
+		return nil
+	}
+
+	if keyPath == "" {
+		keyPath = certPath
+	}
+
+	modTime := serverCertificateModTime(certPath, keyPath)
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return fmt.Errorf("Failed to load the HTTPS certificate: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("Failed to parse the HTTPS certificate: %v", err)
+	}
+
+	names := leaf.DNSNames
+	if len(names) == 0 && leaf.Subject.CommonName != "" {
+		names = []string{leaf.Subject.CommonName}
+	}
+
+	if len(names) == 0 {
+		return fmt.Errorf("The HTTPS certificate has no DNS name")
+	}
+
+	serverCertificateLock.Lock()
+	serverCertificate = &serverCertificateFile{
+		certPath: certPath,
+		keyPath:  keyPath,
+		modTime:  modTime,
+		cert:     &cert,
+		names:    names,
+	}
+	serverCertificateLock.Unlock()
+
+	return nil
+}
+
+// serverCertificateCheck reloads the certificate when its files changed.
+func serverCertificateCheck() {
+	serverCertificateLock.Lock()
+	current := serverCertificate
+	serverCertificateLock.Unlock()
+
+	if current == nil {
+		return
+	}
+
+	modTime := serverCertificateModTime(current.certPath, current.keyPath)
+	if modTime.Equal(current.modTime) {
+		return
+	}
+
+	err := serverCertificateLoad(current.certPath, current.keyPath)
+	if err != nil {
+		// Likely caught halfway through a renewal, retry on the next change
+		logger.Error("Failed to reload the HTTPS certificate", log.Ctx{"cert": current.certPath, "err": err})
+
+		serverCertificateLock.Lock()
+		current.modTime = modTime
+		serverCertificateLock.Unlock()
+
+		return
+	}
+
+	logger.Info("Reloaded the HTTPS certificate", log.Ctx{"cert": current.certPath})
+}
+
+// serverCertificateMatch returns whether a certificate name, possibly a
+// wildcard, covers the name a client asked for.
+func serverCertificateMatch(name string, serverName string) bool {
+	name = strings.ToLower(name)
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+
+	if name == serverName {
+		return true
+	}
+
+	if strings.HasPrefix(name, "*.") {
+		i := strings.Index(serverName, ".")
+		return i > 0 && serverName[i:] == name[1:]
+	}
+
+	return false
+}
+
+// serverACMESetup (re)configures ACME from the acme.* keys.
+func serverACMESetup(config map[string]string) error {
+	serverACMELock.Lock()
+	defer serverACMELock.Unlock()
+
+	if serverACMEListener != nil {
+		serverACMEListener.Close()
+		serverACMEListener = nil
+	}
+
+	serverACMEManager = nil
+	serverACMEDomain = ""
+
+	domain := config["acme.domain"]
+	if domain == "" {
+		return nil
+	}
+
+	if !shared.IsTrue(config["acme.agree_tos"]) {
+		return fmt.Errorf("The terms of service of the ACME CA must be agreed to with acme.agree_tos first")
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domain),
+		Cache:      autocert.DirCache(shared.VarPath("acme")),
+		Email:      config["acme.email"],
+		Client:     &acme.Client{DirectoryURL: config["acme.ca_url"]},
+	}
+
+	// HTTP-01 challenges, TLS-ALPN-01 ones being answered on core.https_address
+	address := config["acme.http_address"]
+	if address != "" {
+		_, _, err := net.SplitHostPort(address)
+		if err != nil {
+			address = net.JoinHostPort(address, "80")
+		}
+
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return fmt.Errorf("cannot listen on ACME challenge socket: %v", err)
+		}
+
+		go http.Serve(listener, manager.HTTPHandler(nil))
+		serverACMEListener = listener
+	}
+
+	serverACMEManager = manager
+	serverACMEDomain = domain
+
+	return nil
+}
+
+func serverACMEConfig() map[string]string {
+	config := map[string]string{}
+	for _, key := range []string{"acme.agree_tos", "acme.ca_url", "acme.domain", "acme.email", "acme.http_address"} {
+		config[key] = daemonConfig[key].Get()
+	}
+
+	return config
+}
+
+// serverCertificateReserved checks whether a name is one of the server
+// certificate's, which existing remotes ask for.
+func (d *Daemon) serverCertificateReserved(serverName string) bool {
+	if d.tlsConfig == nil || len(d.tlsConfig.Certificates) == 0 {
+		return false
+	}
+
+	leaf := d.tlsConfig.Certificates[0].Leaf
+	if leaf == nil {
+		var err error
+		leaf, err = x509.ParseCertificate(d.tlsConfig.Certificates[0].Certificate[0])
+		if err != nil {
+			return false
+		}
+	}
+
+	for _, name := range leaf.DNSNames {
+		if serverCertificateMatch(name, serverName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getCertificate picks the certificate to present to a client asking for a
+// given name, nil falling back to the server certificate.
+func (d *Daemon) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if d.serverCertificateReserved(hello.ServerName) {
+		return nil, nil
+	}
+
+	serverACMELock.Lock()
+	manager := serverACMEManager
+	domain := serverACMEDomain
+	serverACMELock.Unlock()
+
+	if manager != nil && serverCertificateMatch(domain, hello.ServerName) {
+		cert, err := manager.GetCertificate(hello)
+		if err == nil {
+			return cert, nil
+		}
+
+		logger.Warn("Failed to get the ACME certificate", log.Ctx{"domain": domain, "err": err})
+	}
+
+	serverCertificateLock.Lock()
+	current := serverCertificate
+	serverCertificateLock.Unlock()
+
+	if current != nil {
+		for _, name := range current.names {
+			if serverCertificateMatch(name, hello.ServerName) {
+				return current.cert, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func daemonConfigSetCertificate(d *Daemon, key string, value string) (string, error) {
+	// Get the current config
+	config := map[string]string{}
+	config["core.https_certificate"] = daemonConfig["core.https_certificate"].Get()
+	config["core.https_certificate_key"] = daemonConfig["core.https_certificate_key"].Get()
+
+	// Apply the change
+	config[key] = value
+
+	err := serverCertificateLoad(config["core.https_certificate"], config["core.https_certificate_key"])
+	if err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+func daemonConfigSetACME(d *Daemon, key string, value string) (string, error) {
+	// Get the current config
+	config := serverACMEConfig()
+
+	// Apply the change
+	config[key] = value
+	if value == "" {
+		config[key] = daemonConfig[key].defaultValue
+	}
+
+	err := serverACMESetup(config)
+	if err != nil {
+		// Restore the previous setup
+		serverACMESetup(serverACMEConfig())
+		return "", err
+	}
+
+	return value, nil
+}
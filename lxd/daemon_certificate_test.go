@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServerCertificateMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverName string
+		match      bool
+	}{
+		{"lxd.example.net", "lxd.example.net", true},
+		{"lxd.example.net", "LXD.example.net.", true},
+		{"lxd.example.net", "example.net", false},
+		{"lxd.example.net", "", false},
+		{"*.example.net", "lxd.example.net", true},
+		{"*.example.net", "example.net", false},
+		{"*.example.net", "a.lxd.example.net", false},
+	}
+
+	for _, test := range tests {
+		match := serverCertificateMatch(test.name, test.serverName)
+		if match != test.match {
+			t.Errorf("Expected %v for %q against %q, got %v", test.match, test.name, test.serverName, match)
+		}
+	}
+}
+
+// serverCertificateTestPEM generates a certificate and key for the given
+// names.
+func serverCertificateTestPEM(t *testing.T, names ...string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: names[0]},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     names,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestServerCertificateLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-cert-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer serverCertificateLoad("", "")
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server certificate, whose first name existing remotes ask for
+	serverCert, serverKey := serverCertificateTestPEM(t, hostname)
+	server, err := tls.X509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Daemon{tlsConfig: &tls.Config{Certificates: []tls.Certificate{server}}}
+
+	// Certificate and key in a single file
+	path := filepath.Join(dir, "server.pem")
+	write := func() {
+		cert, key := serverCertificateTestPEM(t, "lxd.example.net", hostname)
+
+		err = ioutil.WriteFile(path, append(cert, key...), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write()
+	err = serverCertificateLoad(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := d.getCertificate(&tls.ClientHelloInfo{ServerName: "lxd.example.net"})
+	if err != nil || first == nil {
+		t.Fatalf("Expected the certificate for lxd.example.net, got %v (%v)", first, err)
+	}
+
+	cert, err := d.getCertificate(&tls.ClientHelloInfo{ServerName: hostname})
+	if err != nil || cert != nil {
+		t.Errorf("Expected the server certificate for %q, got %v (%v)", hostname, cert, err)
+	}
+
+	cert, err = d.getCertificate(&tls.ClientHelloInfo{ServerName: "other.example.net"})
+	if err != nil || cert != nil {
+		t.Errorf("Expected no certificate for another name, got %v (%v)", cert, err)
+	}
+
+	// Renewed certificates get picked up
+	write()
+	later := time.Now().Add(time.Minute)
+	err = os.Chtimes(path, later, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverCertificateCheck()
+
+	cert, err = d.getCertificate(&tls.ClientHelloInfo{ServerName: "lxd.example.net"})
+	if err != nil || cert == nil || cert == first {
+		t.Errorf("Expected the renewed certificate, got %v (%v)", cert, err)
+	}
+}
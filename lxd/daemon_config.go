@@ -16,6 +16,7 @@ import (
 
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/logging"
 )
 
 var daemonConfigLock sync.Mutex
@@ -174,20 +175,52 @@ func (k *daemonConfigKey) GetInt64() int64 {
 func daemonConfigInit(db *sql.DB) error {
 	// Set all the keys
 	daemonConfig = map[string]*daemonConfigKey{
+		"acme.agree_tos":    {valueType: "bool", setter: daemonConfigSetACME},
+		"acme.ca_url":       {valueType: "string", defaultValue: "https://acme-v02.api.letsencrypt.org/directory", setter: daemonConfigSetACME},
+		"acme.domain":       {valueType: "string", setter: daemonConfigSetACME},
+		"acme.email":        {valueType: "string", setter: daemonConfigSetACME},
+		"acme.http_address": {valueType: "string", setter: daemonConfigSetACME},
+
+		"backups.compression_algorithm": {valueType: "string", validValues: []string{"gzip", "zstd"}, validator: daemonConfigValidateCompression, defaultValue: "gzip"},
+		"backups.encryption_passphrase": {valueType: "string", hiddenValue: true},
+		"backups.s3.access_key":         {valueType: "string"},
+		"backups.s3.bucket":             {valueType: "string"},
+		"backups.s3.endpoint":           {valueType: "string", validator: daemonConfigValidateS3Endpoint},
+		"backups.s3.region":             {valueType: "string", defaultValue: "us-east-1"},
+		"backups.s3.secret_key":         {valueType: "string", hiddenValue: true},
+		"backups.target":                {valueType: "string", validator: daemonConfigValidateBackupsTarget},
+
+		"core.autostart_concurrency":     {valueType: "int", defaultValue: "0"},
+		"core.dns_address":               {valueType: "string", setter: daemonConfigSetDNSAddress},
 		"core.https_address":             {valueType: "string", setter: daemonConfigSetAddress},
+		"core.https_certificate":         {valueType: "string", setter: daemonConfigSetCertificate},
+		"core.https_certificate_key":     {valueType: "string", setter: daemonConfigSetCertificate},
 		"core.https_allowed_headers":     {valueType: "string"},
 		"core.https_allowed_methods":     {valueType: "string"},
 		"core.https_allowed_origin":      {valueType: "string"},
 		"core.https_allowed_credentials": {valueType: "bool"},
+		"core.log_console":               {valueType: "bool", defaultValue: "false"},
+		"core.log_level":                 {valueType: "string", validValues: []string{"debug", "info", "warn", "error", "crit"}, setter: daemonConfigSetLogging},
+		"core.log_target":                {valueType: "string", validator: daemonConfigValidateLogTarget, setter: daemonConfigSetLogging},
 		"core.proxy_http":                {valueType: "string", setter: daemonConfigSetProxy},
 		"core.proxy_https":               {valueType: "string", setter: daemonConfigSetProxy},
 		"core.proxy_ignore_hosts":        {valueType: "string", setter: daemonConfigSetProxy},
+		"core.syslog_address":            {valueType: "string", validator: daemonConfigValidateSyslogAddress, setter: daemonConfigSetLogging},
 		"core.trust_password":            {valueType: "string", hiddenValue: true, setter: daemonConfigSetPassword},
+		"core.usage_history_interval":    {valueType: "int", defaultValue: "0", trigger: daemonConfigTriggerUsageHistory},
 
-		"images.auto_update_cached":    {valueType: "bool", defaultValue: "true"},
-		"images.auto_update_interval":  {valueType: "int", defaultValue: "6"},
-		"images.compression_algorithm": {valueType: "string", validator: daemonConfigValidateCompression, defaultValue: "gzip"},
-		"images.remote_cache_expiry":   {valueType: "int", defaultValue: "10", trigger: daemonConfigTriggerExpiry},
+		"images.auto_update_cached":          {valueType: "bool", defaultValue: "true"},
+		"images.auto_update_interval":        {valueType: "int", defaultValue: "6"},
+		"images.compression_algorithm":       {valueType: "string", validator: daemonConfigValidateCompression, defaultValue: "gzip"},
+		"images.remote_cache_expiry":         {valueType: "int", defaultValue: "10", trigger: daemonConfigTriggerExpiry},
+		"images.remote_cache_expiry_origins": {valueType: "string", validator: daemonConfigValidateCacheOrigins, trigger: daemonConfigTriggerExpiry},
+		"images.remote_cache_max_size":       {valueType: "string", validator: daemonConfigValidateCacheMaxSize, trigger: daemonConfigTriggerExpiry},
+
+		"limits.cpu_overcommit":    {valueType: "string", validator: daemonConfigValidateOvercommit},
+		"limits.memory_overcommit": {valueType: "string", validator: daemonConfigValidateOvercommit},
+		"limits.overcommit_action": {valueType: "string", validValues: []string{"refuse", "warn"}, defaultValue: "refuse"},
+
+		"migration.compression_algorithm": {valueType: "string", validValues: []string{"bzip2", "gzip", "lzma", "none", "xz", "zstd"}, validator: daemonConfigValidateCompression, defaultValue: "none"},
 
 		// Keys deprecated since the implementation of the storage api.
 		"storage.lvm_fstype":           {valueType: "string", defaultValue: "ext4", validValues: []string{"ext4", "xfs"}, validator: storageDeprecatedKeys},
@@ -198,6 +231,10 @@ func daemonConfigInit(db *sql.DB) error {
 		"storage.zfs_pool_name":        {valueType: "string", validator: storageDeprecatedKeys},
 		"storage.zfs_remove_snapshots": {valueType: "bool", validator: storageDeprecatedKeys},
 		"storage.zfs_use_refquota":     {valueType: "bool", validator: storageDeprecatedKeys},
+
+		"webhooks.events": {valueType: "string", validator: daemonConfigValidateWebhookEvents},
+		"webhooks.secret": {valueType: "string", hiddenValue: true},
+		"webhooks.urls":   {valueType: "string", validator: daemonConfigValidateWebhookURLs},
 	}
 
 	// Load the values from the DB
@@ -299,11 +336,40 @@ func daemonConfigSetProxy(d *Daemon, key string, value string) (string, error) {
 	return value, nil
 }
 
+func daemonConfigSetLogging(d *Daemon, key string, value string) (string, error) {
+	// Get the current config
+	config := map[string]string{}
+	config["core.log_level"] = daemonConfig["core.log_level"].Get()
+	config["core.log_target"] = daemonConfig["core.log_target"].Get()
+	config["core.syslog_address"] = daemonConfig["core.syslog_address"].Get()
+
+	// Apply the change
+	config[key] = value
+
+	// Swap the log handlers
+	err := d.UpdateLogging(config["core.log_level"], config["core.log_target"], config["core.syslog_address"])
+	if err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
 func daemonConfigTriggerExpiry(d *Daemon, key string, value string) {
 	// Trigger an image pruning run
 	d.pruneChan <- true
 }
 
+func daemonConfigTriggerUsageHistory(d *Daemon, key string, value string) {
+	// Nothing to reset until the daemon is ready
+	if d.resetUsageChan == nil {
+		return
+	}
+
+	// Reset the usage sampling timer
+	d.resetUsageChan <- true
+}
+
 func daemonConfigValidateCompression(d *Daemon, key string, value string) error {
 	if value == "none" {
 		return nil
@@ -313,6 +379,31 @@ func daemonConfigValidateCompression(d *Daemon, key string, value string) error
 	return err
 }
 
+func daemonConfigValidateOvercommit(d *Daemon, key string, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	factor, err := strconv.ParseFloat(value, 64)
+	if err != nil || factor < 1 {
+		return fmt.Errorf("Invalid overcommit factor, must be a number greater or equal to 1: %s", value)
+	}
+
+	return nil
+}
+
+func daemonConfigValidateLogTarget(d *Daemon, key string, value string) error {
+	return logging.ValidateTarget(value)
+}
+
+func daemonConfigValidateSyslogAddress(d *Daemon, key string, value string) error {
+	if value == "syslog" {
+		return fmt.Errorf("Invalid syslog address: %s", value)
+	}
+
+	return logging.ValidateTarget(value)
+}
+
 func storageDeprecatedKeys(d *Daemon, key string, value string) error {
 	if value == "" || daemonConfig[key].defaultValue == value {
 		return nil
@@ -3,6 +3,7 @@ package main
 import (
 	"database/sql"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/mattn/go-sqlite3"
@@ -140,6 +141,46 @@ CREATE TABLE IF NOT EXISTS networks_config (
     UNIQUE (network_id, key),
     FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE
 );
+CREATE TABLE IF NOT EXISTS networks_forwards (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_id INTEGER NOT NULL,
+    listen_address VARCHAR(255) NOT NULL,
+    description TEXT,
+    UNIQUE (network_id, listen_address),
+    FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS networks_forwards_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_forward_id INTEGER NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    value TEXT,
+    UNIQUE (network_forward_id, key),
+    FOREIGN KEY (network_forward_id) REFERENCES networks_forwards (id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS networks_forwards_ports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_forward_id INTEGER NOT NULL,
+    protocol VARCHAR(255) NOT NULL,
+    listen_port VARCHAR(255) NOT NULL,
+    target_port VARCHAR(255) NOT NULL DEFAULT "",
+    target_address VARCHAR(255) NOT NULL,
+    description TEXT,
+    FOREIGN KEY (network_forward_id) REFERENCES networks_forwards (id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS networks_zones (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    UNIQUE (name)
+);
+CREATE TABLE IF NOT EXISTS networks_zones_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_zone_id INTEGER NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    value TEXT,
+    UNIQUE (network_zone_id, key),
+    FOREIGN KEY (network_zone_id) REFERENCES networks_zones (id) ON DELETE CASCADE
+);
 CREATE TABLE IF NOT EXISTS patches (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     name VARCHAR(255) NOT NULL,
@@ -176,6 +217,20 @@ CREATE TABLE IF NOT EXISTS profiles_devices_config (
     UNIQUE (profile_device_id, key),
     FOREIGN KEY (profile_device_id) REFERENCES profiles_devices (id) ON DELETE CASCADE
 );
+CREATE TABLE IF NOT EXISTS projects (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    UNIQUE (name)
+);
+CREATE TABLE IF NOT EXISTS projects_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    project_id INTEGER NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    value TEXT,
+    UNIQUE (project_id, key),
+    FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
+);
 CREATE TABLE IF NOT EXISTS schema (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     version INTEGER NOT NULL,
@@ -213,15 +268,36 @@ CREATE TABLE IF NOT EXISTS storage_volumes_config (
     value TEXT,
     UNIQUE (storage_volume_id, key),
     FOREIGN KEY (storage_volume_id) REFERENCES storage_volumes (id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS warnings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    type VARCHAR(255) NOT NULL,
+    entity VARCHAR(255) NOT NULL DEFAULT "",
+    message TEXT NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    count INTEGER NOT NULL DEFAULT 1,
+    first_seen_date DATETIME NOT NULL,
+    last_seen_date DATETIME NOT NULL,
+    UNIQUE (type, entity)
 );`
 
-func enableForeignKeys(conn *sqlite3.SQLiteConn) error {
+// dbConnectHook sets up every new database connection. Write-ahead logging
+// lets readers proceed while a transaction is writing, instead of failing
+// with "database is locked" once the busy timeout expires.
+func dbConnectHook(conn *sqlite3.SQLiteConn) error {
 	_, err := conn.Exec("PRAGMA foreign_keys=ON;", nil)
+	if err != nil {
+		return err
+	}
+
+	// WAL is safe with synchronous=NORMAL, only the latest commits may be
+	// lost on power failure.
+	_, err = conn.Exec("PRAGMA journal_mode=WAL; PRAGMA synchronous=NORMAL;", nil)
 	return err
 }
 
 func init() {
-	sql.Register("sqlite3_with_fk", &sqlite3.SQLiteDriver{ConnectHook: enableForeignKeys})
+	sql.Register("sqlite3_with_fk", &sqlite3.SQLiteDriver{ConnectHook: dbConnectHook})
 }
 
 // Create the initial (current) schema for a given SQLite DB connection.
@@ -299,6 +375,67 @@ func isNoMatchError(err error) bool {
 	return false
 }
 
+// Prepared statements are cached per database and query, up to
+// dbStatementsMax queries per database so that queries embedding values
+// don't grow the cache forever.
+const dbStatementsMax = 512
+
+var dbStatements = struct {
+	sync.Mutex
+	stmts map[*sql.DB]map[string]*sql.Stmt
+}{stmts: map[*sql.DB]map[string]*sql.Stmt{}}
+
+// dbPrepare returns a prepared statement for the query, preparing it only
+// the first time it's run against this database.
+func dbPrepare(db *sql.DB, q string) (*sql.Stmt, error) {
+	dbStatements.Lock()
+	defer dbStatements.Unlock()
+
+	stmts, ok := dbStatements.stmts[db]
+	if !ok {
+		stmts = map[string]*sql.Stmt{}
+		dbStatements.stmts[db] = stmts
+	}
+
+	stmt, ok := stmts[q]
+	if ok {
+		return stmt, nil
+	}
+
+	stmt, err := db.Prepare(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stmts) < dbStatementsMax {
+		stmts[q] = stmt
+	}
+
+	return stmt, nil
+}
+
+// dbStatementRelease closes the statement unless it's held by the cache.
+func dbStatementRelease(db *sql.DB, q string, stmt *sql.Stmt) {
+	dbStatements.Lock()
+	defer dbStatements.Unlock()
+
+	if dbStatements.stmts[db][q] != stmt {
+		stmt.Close()
+	}
+}
+
+// dbClose closes the cached prepared statements, then the database.
+func dbClose(db *sql.DB) error {
+	dbStatements.Lock()
+	for _, stmt := range dbStatements.stmts[db] {
+		stmt.Close()
+	}
+	delete(dbStatements.stmts, db)
+	dbStatements.Unlock()
+
+	return db.Close()
+}
+
 func dbBegin(db *sql.DB) (*sql.Tx, error) {
 	for i := 0; i < 1000; i++ {
 		tx, err := db.Begin()
@@ -335,9 +472,46 @@ func txCommit(tx *sql.Tx) error {
 	return fmt.Errorf("DB is locked")
 }
 
+// dbTransaction runs f within a transaction which is committed if f
+// succeeds and rolled back otherwise. As sqlite may report the database as
+// busy at any statement, not only when beginning or committing, the whole
+// transaction is retried in that case, so f must not have side effects
+// outside of the database.
+func dbTransaction(db *sql.DB, f func(tx *sql.Tx) error) error {
+	for i := 0; i < 1000; i++ {
+		tx, err := dbBegin(db)
+		if err != nil {
+			return err
+		}
+
+		err = f(tx)
+		if err != nil {
+			tx.Rollback()
+			if !isDbLockedError(err) {
+				return err
+			}
+
+			time.Sleep(30 * time.Millisecond)
+			continue
+		}
+
+		return txCommit(tx)
+	}
+
+	logger.Debugf("DbTransaction: DB still locked")
+	logger.Debugf(logger.GetStack())
+	return fmt.Errorf("DB is locked")
+}
+
 func dbQueryRowScan(db *sql.DB, q string, args []interface{}, outargs []interface{}) error {
+	stmt, err := dbPrepare(db, q)
+	if err != nil {
+		return err
+	}
+	defer dbStatementRelease(db, q, stmt)
+
 	for i := 0; i < 1000; i++ {
-		err := db.QueryRow(q, args...).Scan(outargs...)
+		err := stmt.QueryRow(args...).Scan(outargs...)
 		if err == nil {
 			return nil
 		}
@@ -374,7 +548,13 @@ func dbQuery(db *sql.DB, q string, args ...interface{}) (*sql.Rows, error) {
 }
 
 func doDbQueryScan(db *sql.DB, q string, args []interface{}, outargs []interface{}) ([][]interface{}, error) {
-	rows, err := db.Query(q, args...)
+	stmt, err := dbPrepare(db, q)
+	if err != nil {
+		return [][]interface{}{}, err
+	}
+	defer dbStatementRelease(db, q, stmt)
+
+	rows, err := stmt.Query(args...)
 	if err != nil {
 		return [][]interface{}{}, err
 	}
@@ -454,8 +634,14 @@ func dbQueryScan(db *sql.DB, q string, inargs []interface{}, outfmt []interface{
 }
 
 func dbExec(db *sql.DB, q string, args ...interface{}) (sql.Result, error) {
+	stmt, err := dbPrepare(db, q)
+	if err != nil {
+		return nil, err
+	}
+	defer dbStatementRelease(db, q, stmt)
+
 	for i := 0; i < 1000; i++ {
-		result, err := db.Exec(q, args...)
+		result, err := stmt.Exec(args...)
 		if err == nil {
 			return result, nil
 		}
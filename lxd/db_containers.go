@@ -120,11 +120,6 @@ func dbContainerCreate(db *sql.DB, args containerArgs) (int, error) {
 		return 0, DbErrAlreadyDefined
 	}
 
-	tx, err := dbBegin(db)
-	if err != nil {
-		return 0, err
-	}
-
 	ephemInt := 0
 	if args.Ephemeral == true {
 		ephemInt = 1
@@ -138,42 +133,39 @@ func dbContainerCreate(db *sql.DB, args containerArgs) (int, error) {
 	args.CreationDate = time.Now().UTC()
 	args.LastUsedDate = time.Unix(0, 0).UTC()
 
-	str := fmt.Sprintf("INSERT INTO containers (name, architecture, type, ephemeral, creation_date, last_use_date, stateful) VALUES (?, ?, ?, ?, ?, ?, ?)")
-	stmt, err := tx.Prepare(str)
-	if err != nil {
-		tx.Rollback()
-		return 0, err
-	}
-	defer stmt.Close()
-	result, err := stmt.Exec(args.Name, args.Architecture, args.Ctype, ephemInt, args.CreationDate.Unix(), args.LastUsedDate.Unix(), statefulInt)
-	if err != nil {
-		tx.Rollback()
-		return 0, err
-	}
+	err = dbTransaction(db, func(tx *sql.Tx) error {
+		str := fmt.Sprintf("INSERT INTO containers (name, description, architecture, type, ephemeral, creation_date, last_use_date, stateful) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+		stmt, err := tx.Prepare(str)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		result, err := stmt.Exec(args.Name, args.Description, args.Architecture, args.Ctype, ephemInt, args.CreationDate.Unix(), args.LastUsedDate.Unix(), statefulInt)
+		if err != nil {
+			return err
+		}
 
-	id64, err := result.LastInsertId()
-	if err != nil {
-		tx.Rollback()
-		return 0, fmt.Errorf("Error inserting %s into database", args.Name)
-	}
-	// TODO: is this really int64? we should fix it everywhere if so
-	id = int(id64)
-	if err := dbContainerConfigInsert(tx, id, args.Config); err != nil {
-		tx.Rollback()
-		return 0, err
-	}
+		id64, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("Error inserting %s into database", args.Name)
+		}
+		// TODO: is this really int64? we should fix it everywhere if so
+		id = int(id64)
+		if err := dbContainerConfigInsert(tx, id, args.Config); err != nil {
+			return err
+		}
 
-	if err := dbContainerProfilesInsert(tx, id, args.Profiles); err != nil {
-		tx.Rollback()
-		return 0, err
-	}
+		if err := dbContainerProfilesInsert(tx, id, args.Profiles); err != nil {
+			return err
+		}
 
-	if err := dbDevicesAdd(tx, "container", int64(id), args.Devices); err != nil {
-		tx.Rollback()
+		return dbDevicesAdd(tx, "container", int64(id), args.Devices)
+	})
+	if err != nil {
 		return 0, err
 	}
 
-	return id, txCommit(tx)
+	return id, nil
 }
 
 func dbContainerConfigClear(tx *sql.Tx, id int) error {
@@ -39,8 +39,10 @@ func dbImagesGet(db *sql.DB, public bool) ([]string, error) {
 	return results, nil
 }
 
-func dbImagesGetExpired(db *sql.DB, expiry int64) ([]string, error) {
-	q := `SELECT fingerprint FROM images WHERE cached=1 AND creation_date<=strftime('%s', date('now', '-` + fmt.Sprintf("%d", expiry) + ` day'))`
+// dbImagesGetCached returns the fingerprints of the images downloaded to
+// create containers.
+func dbImagesGetCached(db *sql.DB) ([]string, error) {
+	q := "SELECT fingerprint FROM images WHERE cached=1"
 
 	var fp string
 	inargs := []interface{}{}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// dbNetworkForwards returns the listen addresses of the network's forwards.
+func dbNetworkForwards(db *sql.DB, networkID int64) ([]string, error) {
+	q := "SELECT listen_address FROM networks_forwards WHERE network_id=? ORDER BY listen_address"
+	inargs := []interface{}{networkID}
+	var address string
+	outfmt := []interface{}{address}
+	result, err := dbQueryScan(db, q, inargs, outfmt)
+	if err != nil {
+		return []string{}, err
+	}
+
+	response := []string{}
+	for _, r := range result {
+		response = append(response, r[0].(string))
+	}
+
+	return response, nil
+}
+
+func dbNetworkForwardGet(db *sql.DB, networkID int64, listenAddress string) (int64, *api.NetworkForward, error) {
+	description := sql.NullString{}
+	id := int64(-1)
+
+	q := "SELECT id, description FROM networks_forwards WHERE network_id=? AND listen_address=?"
+	arg1 := []interface{}{networkID, listenAddress}
+	arg2 := []interface{}{&id, &description}
+	err := dbQueryRowScan(db, q, arg1, arg2)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	config, err := dbNetworkForwardConfigGet(db, id)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	ports, err := dbNetworkForwardPortsGet(db, id)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	forward := api.NetworkForward{
+		ListenAddress: listenAddress,
+	}
+	forward.Description = description.String
+	forward.Config = config
+	forward.Ports = ports
+
+	return id, &forward, nil
+}
+
+func dbNetworkForwardConfigGet(db *sql.DB, id int64) (map[string]string, error) {
+	var key, value string
+	query := "SELECT key, value FROM networks_forwards_config WHERE network_forward_id=?"
+	inargs := []interface{}{id}
+	outfmt := []interface{}{key, value}
+	results, err := dbQueryScan(db, query, inargs, outfmt)
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]string{}
+	for _, r := range results {
+		config[r[0].(string)] = r[1].(string)
+	}
+
+	return config, nil
+}
+
+func dbNetworkForwardPortsGet(db *sql.DB, id int64) ([]api.NetworkForwardPort, error) {
+	rows, err := dbQuery(db, `
+		SELECT protocol, listen_port, target_port, target_address, description
+		FROM networks_forwards_ports
+		WHERE network_forward_id=?
+		ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ports := []api.NetworkForwardPort{}
+	for rows.Next() {
+		port := api.NetworkForwardPort{}
+		description := sql.NullString{}
+
+		err := rows.Scan(&port.Protocol, &port.ListenPort, &port.TargetPort, &port.TargetAddress, &description)
+		if err != nil {
+			return nil, err
+		}
+
+		port.Description = description.String
+		ports = append(ports, port)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return ports, nil
+}
+
+func dbNetworkForwardCreate(db *sql.DB, networkID int64, listenAddress string, forward api.NetworkForwardPut) (int64, error) {
+	tx, err := dbBegin(db)
+	if err != nil {
+		return -1, err
+	}
+
+	result, err := tx.Exec("INSERT INTO networks_forwards (network_id, listen_address, description) VALUES (?, ?, ?)", networkID, listenAddress, forward.Description)
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	err = dbNetworkForwardFill(tx, id, forward)
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	err = txCommit(tx)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+func dbNetworkForwardUpdate(db *sql.DB, id int64, forward api.NetworkForwardPut) error {
+	tx, err := dbBegin(db)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE networks_forwards SET description=? WHERE id=?", forward.Description, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM networks_forwards_config WHERE network_forward_id=?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM networks_forwards_ports WHERE network_forward_id=?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = dbNetworkForwardFill(tx, id, forward)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return txCommit(tx)
+}
+
+// dbNetworkForwardFill stores the configuration and ports of a forward.
+func dbNetworkForwardFill(tx *sql.Tx, id int64, forward api.NetworkForwardPut) error {
+	stmt, err := tx.Prepare("INSERT INTO networks_forwards_config (network_forward_id, key, value) VALUES(?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for k, v := range forward.Config {
+		if v == "" {
+			continue
+		}
+
+		_, err = stmt.Exec(id, k, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	portStmt, err := tx.Prepare(`
+		INSERT INTO networks_forwards_ports
+			(network_forward_id, protocol, listen_port, target_port, target_address, description)
+		VALUES(?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer portStmt.Close()
+
+	for _, port := range forward.Ports {
+		_, err = portStmt.Exec(id, port.Protocol, port.ListenPort, port.TargetPort, port.TargetAddress, port.Description)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dbNetworkForwardDelete(db *sql.DB, id int64) error {
+	_, err := dbExec(db, "DELETE FROM networks_forwards WHERE id=?", id)
+	return err
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func dbNetworkZones(db *sql.DB) ([]string, error) {
+	q := "SELECT name FROM networks_zones ORDER BY name"
+	inargs := []interface{}{}
+	var name string
+	outfmt := []interface{}{name}
+	result, err := dbQueryScan(db, q, inargs, outfmt)
+	if err != nil {
+		return []string{}, err
+	}
+
+	response := []string{}
+	for _, r := range result {
+		response = append(response, r[0].(string))
+	}
+
+	return response, nil
+}
+
+func dbNetworkZoneGet(db *sql.DB, name string) (int64, *api.NetworkZone, error) {
+	description := sql.NullString{}
+	id := int64(-1)
+
+	q := "SELECT id, description FROM networks_zones WHERE name=?"
+	arg1 := []interface{}{name}
+	arg2 := []interface{}{&id, &description}
+	err := dbQueryRowScan(db, q, arg1, arg2)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	config, err := dbNetworkZoneConfigGet(db, id)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	zone := api.NetworkZone{
+		Name: name,
+	}
+	zone.Description = description.String
+	zone.Config = config
+
+	return id, &zone, nil
+}
+
+func dbNetworkZoneConfigGet(db *sql.DB, id int64) (map[string]string, error) {
+	var key, value string
+	query := "SELECT key, value FROM networks_zones_config WHERE network_zone_id=?"
+	inargs := []interface{}{id}
+	outfmt := []interface{}{key, value}
+	results, err := dbQueryScan(db, query, inargs, outfmt)
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]string{}
+	for _, r := range results {
+		config[r[0].(string)] = r[1].(string)
+	}
+
+	return config, nil
+}
+
+func dbNetworkZoneCreate(db *sql.DB, name, description string, config map[string]string) (int64, error) {
+	tx, err := dbBegin(db)
+	if err != nil {
+		return -1, err
+	}
+
+	result, err := tx.Exec("INSERT INTO networks_zones (name, description) VALUES (?, ?)", name, description)
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	err = dbNetworkZoneConfigAdd(tx, id, config)
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	err = txCommit(tx)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+func dbNetworkZoneUpdate(db *sql.DB, id int64, description string, config map[string]string) error {
+	tx, err := dbBegin(db)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE networks_zones SET description=? WHERE id=?", description, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM networks_zones_config WHERE network_zone_id=?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = dbNetworkZoneConfigAdd(tx, id, config)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return txCommit(tx)
+}
+
+func dbNetworkZoneConfigAdd(tx *sql.Tx, id int64, config map[string]string) error {
+	stmt, err := tx.Prepare("INSERT INTO networks_zones_config (network_zone_id, key, value) VALUES(?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for k, v := range config {
+		if v == "" {
+			continue
+		}
+
+		_, err = stmt.Exec(id, k, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dbNetworkZoneDelete(db *sql.DB, id int64) error {
+	_, err := dbExec(db, "DELETE FROM networks_zones WHERE id=?", id)
+	return err
+}
@@ -97,6 +97,8 @@ func dbProfileCreate(db *sql.DB, profile string, description string, config map[
 		return -1, err
 	}
 
+	profileCacheFlush()
+
 	return id, nil
 }
 
@@ -172,6 +174,8 @@ func dbProfileDelete(db *sql.DB, name string) error {
 		return err
 	}
 
+	profileCacheFlush()
+
 	return nil
 }
 
@@ -188,8 +192,13 @@ func dbProfileUpdate(db *sql.DB, name string, newName string) error {
 	}
 
 	err = txCommit(tx)
+	if err != nil {
+		return err
+	}
 
-	return err
+	profileCacheFlush()
+
+	return nil
 }
 
 func dbProfileDescriptionUpdate(tx *sql.Tx, id int64, description string) error {
@@ -3,6 +3,10 @@ package main
 import (
 	"database/sql"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -511,3 +515,138 @@ func (s *dbTestSuite) Test_dbDevices_containers() {
 			fmt.Sprintf("Mismatching value for key %s: %s != %s", key, subresult[key], value))
 	}
 }
+
+func (s *dbTestSuite) Test_dbTransaction_commits() {
+	err := dbTransaction(s.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO profiles (name) VALUES ('committed')")
+		return err
+	})
+	s.Nil(err)
+
+	_, _, err = dbProfileGet(s.db, "committed")
+	s.Nil(err)
+}
+
+func (s *dbTestSuite) Test_dbTransaction_rolls_back_on_error() {
+	err := dbTransaction(s.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO profiles (name) VALUES ('rolledback')")
+		s.Nil(err)
+
+		return fmt.Errorf("failure")
+	})
+	s.Equal("failure", err.Error())
+
+	_, _, err = dbProfileGet(s.db, "rolledback")
+	s.Equal(sql.ErrNoRows, err)
+}
+
+func (s *dbTestSuite) Test_dbPrepare_caches_statements() {
+	q := "SELECT name FROM profiles WHERE name=?"
+
+	stmt1, err := dbPrepare(s.db, q)
+	s.Nil(err)
+
+	stmt2, err := dbPrepare(s.db, q)
+	s.Nil(err)
+	s.True(stmt1 == stmt2)
+}
+
+// benchmarkDb opens an on-disk database, which unlike an in-memory one is
+// shared by all the connections of the pool.
+func benchmarkDb(b *testing.B) (*sql.DB, func()) {
+	if logger.Log == nil {
+		logger.Log, _ = logging.GetLogger("", "", false, false, nil)
+	}
+
+	dir, err := ioutil.TempDir("", "lxd-db-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	d := &Daemon{MockMode: true}
+	err = initializeDbObject(d, filepath.Join(dir, "lxd.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		b.Fatal(err)
+	}
+
+	return d.db, func() {
+		dbClose(d.db)
+		os.RemoveAll(dir)
+	}
+}
+
+func benchmarkContainerArgs(name string) containerArgs {
+	return containerArgs{
+		Name:         name,
+		Architecture: 1,
+		Ctype:        cTypeRegular,
+		Config:       map[string]string{"user.foo": "bar"},
+		Devices:      types.Devices{"eth0": types.Device{"type": "nic", "nictype": "bridged", "parent": "lxdbr0"}},
+		Profiles:     []string{"default"},
+	}
+}
+
+// BenchmarkDbContainerCreateParallel creates containers from concurrent
+// goroutines, as happens when many containers are launched at once.
+func BenchmarkDbContainerCreateParallel(b *testing.B) {
+	db, cleanup := benchmarkDb(b)
+	defer cleanup()
+
+	var count int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			name := fmt.Sprintf("c%d", atomic.AddInt64(&count, 1))
+			_, err := dbContainerCreate(db, benchmarkContainerArgs(name))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkDbContainerGetWhileWriting reads containers while another
+// goroutine keeps creating new ones.
+func BenchmarkDbContainerGetWhileWriting(b *testing.B) {
+	db, cleanup := benchmarkDb(b)
+	defer cleanup()
+
+	for i := 0; i < 100; i++ {
+		_, err := dbContainerCreate(db, benchmarkContainerArgs(fmt.Sprintf("c%d", i)))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	stop := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			dbContainerCreate(db, benchmarkContainerArgs(fmt.Sprintf("w%d", i)))
+		}
+	}()
+
+	var count int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			name := fmt.Sprintf("c%d", atomic.AddInt64(&count, 1)%100)
+			_, err := dbContainerGet(db, name)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.StopTimer()
+
+	close(stop)
+	<-done
+}
@@ -66,6 +66,10 @@ var dbUpdates = []dbUpdate{
 	{version: 34, run: dbUpdateFromV33},
 	{version: 35, run: dbUpdateFromV34},
 	{version: 36, run: dbUpdateFromV35},
+	{version: 37, run: dbUpdateFromV36},
+	{version: 38, run: dbUpdateFromV37},
+	{version: 39, run: dbUpdateFromV38},
+	{version: 40, run: dbUpdateFromV39},
 }
 
 type dbUpdate struct {
@@ -109,7 +113,14 @@ func dbUpdatesApplyAll(db *sql.DB, doBackup bool, postApply func(int) error) err
 
 		if doBackup && !backup {
 			logger.Infof("Updating the LXD database schema. Backup made as \"lxd.db.bak\"")
-			err := shared.FileCopy(shared.VarPath("lxd.db"), shared.VarPath("lxd.db.bak"))
+
+			// Flush the write-ahead log so the copy is complete
+			_, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE);")
+			if err != nil {
+				return err
+			}
+
+			err = shared.FileCopy(shared.VarPath("lxd.db"), shared.VarPath("lxd.db.bak"))
 			if err != nil {
 				return err
 			}
@@ -121,6 +132,8 @@ func dbUpdatesApplyAll(db *sql.DB, doBackup bool, postApply func(int) error) err
 		if err != nil {
 			return err
 		}
+
+		profileCacheFlush()
 		if postApply != nil {
 			err = postApply(update.version)
 			if err != nil {
@@ -135,6 +148,95 @@ func dbUpdatesApplyAll(db *sql.DB, doBackup bool, postApply func(int) error) err
 }
 
 // Schema updates begin here
+func dbUpdateFromV39(currentVersion int, version int, db *sql.DB) error {
+	stmt := `
+CREATE TABLE IF NOT EXISTS networks_zones (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    UNIQUE (name)
+);
+CREATE TABLE IF NOT EXISTS networks_zones_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_zone_id INTEGER NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    value TEXT,
+    UNIQUE (network_zone_id, key),
+    FOREIGN KEY (network_zone_id) REFERENCES networks_zones (id) ON DELETE CASCADE
+);`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func dbUpdateFromV38(currentVersion int, version int, db *sql.DB) error {
+	stmt := `
+CREATE TABLE IF NOT EXISTS networks_forwards (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_id INTEGER NOT NULL,
+    listen_address VARCHAR(255) NOT NULL,
+    description TEXT,
+    UNIQUE (network_id, listen_address),
+    FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS networks_forwards_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_forward_id INTEGER NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    value TEXT,
+    UNIQUE (network_forward_id, key),
+    FOREIGN KEY (network_forward_id) REFERENCES networks_forwards (id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS networks_forwards_ports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_forward_id INTEGER NOT NULL,
+    protocol VARCHAR(255) NOT NULL,
+    listen_port VARCHAR(255) NOT NULL,
+    target_port VARCHAR(255) NOT NULL DEFAULT "",
+    target_address VARCHAR(255) NOT NULL,
+    description TEXT,
+    FOREIGN KEY (network_forward_id) REFERENCES networks_forwards (id) ON DELETE CASCADE
+);`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func dbUpdateFromV37(currentVersion int, version int, db *sql.DB) error {
+	stmt := `
+CREATE TABLE IF NOT EXISTS projects (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    UNIQUE (name)
+);
+CREATE TABLE IF NOT EXISTS projects_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    project_id INTEGER NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    value TEXT,
+    UNIQUE (project_id, key),
+    FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
+);`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func dbUpdateFromV36(currentVersion int, version int, db *sql.DB) error {
+	stmt := `
+CREATE TABLE IF NOT EXISTS warnings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    type VARCHAR(255) NOT NULL,
+    entity VARCHAR(255) NOT NULL DEFAULT "",
+    message TEXT NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    count INTEGER NOT NULL DEFAULT 1,
+    first_seen_date DATETIME NOT NULL,
+    last_seen_date DATETIME NOT NULL,
+    UNIQUE (type, entity)
+);`
+	_, err := db.Exec(stmt)
+	return err
+}
+
 func dbUpdateFromV35(currentVersion int, version int, db *sql.DB) error {
 	stmts := `
 CREATE TABLE tmp (
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Warning status as stored in the database
+const (
+	warningStatusNew          = 0
+	warningStatusAcknowledged = 1
+)
+
+var warningStatusNames = map[int]string{
+	warningStatusNew:          "new",
+	warningStatusAcknowledged: "acknowledged",
+}
+
+func dbWarningFill(warning *api.Warning, status int) {
+	warning.Status = warningStatusNames[status]
+	if warning.Status == "" {
+		warning.Status = "unknown"
+	}
+}
+
+// dbWarnings returns all the warnings recorded by the daemon.
+func dbWarnings(db *sql.DB) ([]api.Warning, error) {
+	rows, err := dbQuery(db, `
+		SELECT
+			id, type, entity, message, status, count, first_seen_date, last_seen_date
+		FROM warnings
+		ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	warnings := []api.Warning{}
+	for rows.Next() {
+		warning := api.Warning{}
+		status := -1
+
+		err := rows.Scan(&warning.ID, &warning.Type, &warning.Entity, &warning.Message,
+			&status, &warning.Count, &warning.FirstSeenAt, &warning.LastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+
+		dbWarningFill(&warning, status)
+		warnings = append(warnings, warning)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return warnings, nil
+}
+
+// dbWarningGet returns the warning with the given ID.
+func dbWarningGet(db *sql.DB, id int64) (*api.Warning, error) {
+	warning := api.Warning{}
+	status := -1
+
+	q := `
+		SELECT
+			id, type, entity, message, status, count, first_seen_date, last_seen_date
+		FROM warnings
+		WHERE id=?`
+	inargs := []interface{}{id}
+	outfmt := []interface{}{&warning.ID, &warning.Type, &warning.Entity, &warning.Message,
+		&status, &warning.Count, &warning.FirstSeenAt, &warning.LastSeenAt}
+
+	err := dbQueryRowScan(db, q, inargs, outfmt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NoSuchObjectError
+		}
+
+		return nil, err
+	}
+
+	dbWarningFill(&warning, status)
+
+	return &warning, nil
+}
+
+// dbWarningRecord creates a new warning or, if one of the same type already
+// exists for the entity, updates its message, counter and last seen date.
+func dbWarningRecord(db *sql.DB, warningType string, entity string, message string) error {
+	now := time.Now().UTC()
+
+	result, err := dbExec(db, `
+		UPDATE warnings
+		SET message=?, count=count+1, last_seen_date=?
+		WHERE type=? AND entity=?`, message, now, warningType, entity)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if updated > 0 {
+		return nil
+	}
+
+	_, err = dbExec(db, `
+		INSERT INTO warnings (type, entity, message, status, count, first_seen_date, last_seen_date)
+		VALUES (?, ?, ?, ?, 1, ?, ?)`, warningType, entity, message, warningStatusNew, now, now)
+	return err
+}
+
+// dbWarningResolve removes a warning which no longer applies.
+func dbWarningResolve(db *sql.DB, warningType string, entity string) error {
+	_, err := dbExec(db, "DELETE FROM warnings WHERE type=? AND entity=?", warningType, entity)
+	return err
+}
+
+func dbWarningStatusUpdate(db *sql.DB, id int64, status int) error {
+	_, err := dbExec(db, "UPDATE warnings SET status=? WHERE id=?", status, id)
+	return err
+}
+
+func dbWarningDelete(db *sql.DB, id int64) error {
+	_, err := dbExec(db, "DELETE FROM warnings WHERE id=?", id)
+	return err
+}
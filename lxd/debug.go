@@ -1,11 +1,14 @@
 package main
 
 import (
+	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
 	"runtime/pprof"
 	"syscall"
 
+	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -28,3 +31,82 @@ func memProfiler(memProfile string) {
 		doMemDump(memProfile)
 	}
 }
+
+type internalDebugDatabase struct {
+	OpenConnections int   `json:"open_connections" yaml:"open_connections"`
+	Size            int64 `json:"size" yaml:"size"`
+}
+
+type internalDebugMemory struct {
+	Alloc      uint64 `json:"alloc" yaml:"alloc"`
+	Sys        uint64 `json:"sys" yaml:"sys"`
+	NumGC      uint32 `json:"num_gc" yaml:"num_gc"`
+	HeapObject uint64 `json:"heap_objects" yaml:"heap_objects"`
+}
+
+type internalDebugInfo struct {
+	Goroutines   int                   `json:"goroutines" yaml:"goroutines"`
+	Memory       internalDebugMemory   `json:"memory" yaml:"memory"`
+	Operations   map[string]int        `json:"operations" yaml:"operations"`
+	Database     internalDebugDatabase `json:"database" yaml:"database"`
+	StoragePools map[string]string     `json:"storage_pools" yaml:"storage_pools"`
+	Stack        string                `json:"stack" yaml:"stack"`
+}
+
+func internalDebugGet(d *Daemon, r *http.Request) Response {
+	info := internalDebugInfo{
+		Goroutines:   runtime.NumGoroutine(),
+		Operations:   map[string]int{},
+		StoragePools: map[string]string{},
+	}
+
+	// Memory usage
+	memStats := runtime.MemStats{}
+	runtime.ReadMemStats(&memStats)
+	info.Memory.Alloc = memStats.Alloc
+	info.Memory.Sys = memStats.Sys
+	info.Memory.NumGC = memStats.NumGC
+	info.Memory.HeapObject = memStats.HeapObjects
+
+	// Operations by status
+	operationsLock.Lock()
+	for _, op := range operations {
+		info.Operations[op.status.String()]++
+	}
+	operationsLock.Unlock()
+
+	// Database
+	info.Database.OpenConnections = d.db.Stats().OpenConnections
+	fi, err := os.Stat(shared.VarPath("lxd.db"))
+	if err == nil {
+		info.Database.Size = fi.Size()
+	}
+
+	// Storage pools
+	pools, err := dbStoragePools(d.db)
+	if err != nil && err != NoSuchObjectError {
+		return SmartError(err)
+	}
+
+	for _, pool := range pools {
+		s, err := storagePoolInit(d, pool)
+		if err == nil {
+			err = s.StoragePoolCheck()
+		}
+
+		if err != nil {
+			info.StoragePools[pool] = err.Error()
+			continue
+		}
+
+		info.StoragePools[pool] = "ok"
+	}
+
+	// Goroutine dump
+	buf := make([]byte, 1<<20)
+	info.Stack = string(buf[:runtime.Stack(buf, true)])
+
+	return SyncResponse(true, info)
+}
+
+var internalDebugCmd = Command{name: "debug", get: internalDebugGet}
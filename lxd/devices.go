@@ -454,40 +454,44 @@ func deviceNetlinkListener() (chan []string, chan []string, chan usbDevice, erro
 	return chCPU, chNetwork, chUSB, nil
 }
 
-func parseCpuset(cpu string) ([]int, error) {
-	cpus := []int{}
-	chunks := strings.Split(cpu, ",")
-	for _, chunk := range chunks {
-		if strings.Contains(chunk, "-") {
-			// Range
-			fields := strings.SplitN(chunk, "-", 2)
-			if len(fields) != 2 {
-				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
-			}
+// deviceNumaNodesCpus returns the CPUs of the given NUMA nodes which are
+// also part of the provided list of usable CPUs.
+func deviceNumaNodesCpus(nodes string, cpus []int) ([]int, error) {
+	ids, err := shared.ParseCpuset(nodes)
+	if err != nil {
+		return nil, err
+	}
 
-			low, err := strconv.Atoi(fields[0])
-			if err != nil {
-				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
+	nodeCpus := []int{}
+	for _, id := range ids {
+		content, err := ioutil.ReadFile(fmt.Sprintf("/sys/devices/system/node/node%d/cpulist", id))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("NUMA node %d doesn't exist", id)
 			}
 
-			high, err := strconv.Atoi(fields[1])
-			if err != nil {
-				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
-			}
+			return nil, err
+		}
 
-			for i := low; i <= high; i++ {
-				cpus = append(cpus, i)
-			}
-		} else {
-			// Simple entry
-			nr, err := strconv.Atoi(chunk)
-			if err != nil {
-				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
+		value := strings.TrimSpace(string(content))
+		if value == "" {
+			// Memory only node
+			continue
+		}
+
+		ids, err := shared.ParseCpuset(value)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, nr := range ids {
+			if shared.IntInSlice(nr, cpus) && !shared.IntInSlice(nr, nodeCpus) {
+				nodeCpus = append(nodeCpus, nr)
 			}
-			cpus = append(cpus, nr)
 		}
 	}
-	return cpus, nil
+
+	return nodeCpus, nil
 }
 
 func deviceTaskBalance(d *Daemon) {
@@ -517,7 +521,7 @@ func deviceTaskBalance(d *Daemon) {
 	if err != nil && shared.PathExists("/sys/fs/cgroup/cpuset/lxc") {
 		logger.Warn("Error setting lxd's cpuset.cpus", log.Ctx{"err": err})
 	}
-	cpus, err := parseCpuset(effectiveCpus)
+	cpus, err := shared.ParseCpuset(effectiveCpus)
 	if err != nil {
 		logger.Error("Error parsing host's cpu set", log.Ctx{"cpuset": effectiveCpus, "err": err})
 		return
@@ -531,6 +535,7 @@ func deviceTaskBalance(d *Daemon) {
 	}
 	fixedContainers := map[int][]container{}
 	balancedContainers := map[container]int{}
+	balancedNodeCpus := map[container][]int{}
 	for _, name := range containers {
 		c, err := containerLoadByName(d, name)
 		if err != nil {
@@ -547,19 +552,33 @@ func deviceTaskBalance(d *Daemon) {
 			continue
 		}
 
+		// Restrict the container to the CPUs of its NUMA nodes
+		nodeCpus := cpus
+		nodes := conf["limits.cpu.nodes"]
+		if nodes != "" {
+			nodeCpus, err = deviceNumaNodesCpus(nodes, cpus)
+			if err != nil {
+				logger.Error("balance: Unable to get the NUMA node CPUs", log.Ctx{"name": c.Name(), "err": err, "nodes": nodes})
+				continue
+			}
+		}
+
 		count, err := strconv.Atoi(cpulimit)
 		if err == nil {
 			// Load-balance
-			count = min(count, len(cpus))
+			count = min(count, len(nodeCpus))
 			balancedContainers[c] = count
+			if nodes != "" {
+				balancedNodeCpus[c] = nodeCpus
+			}
 		} else {
 			// Pinned
-			containerCpus, err := parseCpuset(cpulimit)
+			containerCpus, err := shared.ParseCpuset(cpulimit)
 			if err != nil {
 				return
 			}
 			for _, nr := range containerCpus {
-				if !shared.IntInSlice(nr, cpus) {
+				if !shared.IntInSlice(nr, nodeCpus) {
 					continue
 				}
 
@@ -612,10 +631,15 @@ func deviceTaskBalance(d *Daemon) {
 
 	for ctn, count := range balancedContainers {
 		sort.Sort(sortedUsage)
+		nodeCpus, restricted := balancedNodeCpus[ctn]
 		for _, cpu := range sortedUsage {
 			if count == 0 {
 				break
 			}
+
+			if restricted && !shared.IntInSlice(cpu.id, nodeCpus) {
+				continue
+			}
 			count -= 1
 
 			id := cpu.strId
@@ -917,6 +941,27 @@ func deviceMountDisk(srcPath string, dstPath string, readonly bool, recursive bo
 	return nil
 }
 
+// deviceParseDiskPriority converts a limits.disk.priority value into a
+// blkio.weight, defaulting to the medium priority of 5.
+func deviceParseDiskPriority(diskPriority string) (string, error) {
+	priorityInt := 5
+	if diskPriority != "" {
+		var err error
+		priorityInt, err = strconv.Atoi(diskPriority)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Minimum valid value is 10
+	priority := priorityInt * 100
+	if priority == 0 {
+		priority = 10
+	}
+
+	return fmt.Sprintf("%d", priority), nil
+}
+
 func deviceParseCPU(cpuAllowance string, cpuPriority string) (string, string, string, error) {
 	var err error
 
@@ -1013,6 +1058,47 @@ func deviceTotalMemory() (int64, error) {
 	return -1, fmt.Errorf("Couldn't find MemTotal")
 }
 
+// deviceParseMemoryLimit converts a limits.memory value, either a size or a
+// percentage of the host's memory, into a number of bytes.
+func deviceParseMemoryLimit(value string) (int64, error) {
+	if !strings.HasSuffix(value, "%") {
+		return shared.ParseByteSizeString(value)
+	}
+
+	percent, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
+	if err != nil {
+		return -1, err
+	}
+
+	memoryTotal, err := deviceTotalMemory()
+	if err != nil {
+		return -1, err
+	}
+
+	return memoryTotal * percent / 100, nil
+}
+
+// deviceMemorySwappiness returns the memory.swappiness value matching the
+// limits.memory.swap and limits.memory.swap.priority keys. An empty string
+// means the host's value should be used.
+func deviceMemorySwappiness(swap string, swapPriority string) (string, error) {
+	if swap != "" && !shared.IsTrue(swap) {
+		return "0", nil
+	}
+
+	if swapPriority == "" {
+		return "", nil
+	}
+
+	priority, err := strconv.Atoi(swapPriority)
+	if err != nil {
+		return "", err
+	}
+
+	// The higher the priority, the less likely the container is to be swapped
+	return fmt.Sprintf("%d", 100-9*priority), nil
+}
+
 func deviceGetParentBlocks(path string) ([]string, error) {
 	var devices []string
 	var device []string
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+// dnsTransferChunk is the number of records sent per zone transfer message
+const dnsTransferChunk = 100
+
+var dnsServers []*dns.Server
+var dnsServersLock sync.Mutex
+
+// dnsServerAddress adds the default DNS port to an address lacking one.
+func dnsServerAddress(address string) string {
+	_, _, err := net.SplitHostPort(address)
+	if err == nil {
+		return address
+	}
+
+	ip := net.ParseIP(address)
+	if ip != nil && ip.To4() == nil {
+		return fmt.Sprintf("[%s]:53", address)
+	}
+
+	return fmt.Sprintf("%s:53", address)
+}
+
+// dnsServerStart (re)starts serving the network zones on the given address,
+// over both UDP and TCP. An empty address only stops the server.
+func dnsServerStart(d *Daemon, address string) error {
+	dnsServersLock.Lock()
+	defer dnsServersLock.Unlock()
+
+	dnsServerStopLocked()
+
+	if address == "" {
+		return nil
+	}
+
+	// The TSIG keys are only read on startup
+	secrets, err := networkZoneSecrets(d)
+	if err != nil {
+		return err
+	}
+
+	address = dnsServerAddress(address)
+
+	udp, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return fmt.Errorf("cannot listen on DNS socket: %v", err)
+	}
+
+	tcp, err := net.Listen("tcp", address)
+	if err != nil {
+		udp.Close()
+		return fmt.Errorf("cannot listen on DNS socket: %v", err)
+	}
+
+	handler := dnsHandler{d: d}
+	servers := []*dns.Server{
+		{PacketConn: udp, Handler: handler, TsigSecret: secrets},
+		{Listener: tcp, Handler: handler, TsigSecret: secrets},
+	}
+
+	// Wait for the servers to be up so that they can be shut down
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		server.NotifyStartedFunc = wg.Done
+
+		go func(server *dns.Server) {
+			err := server.ActivateAndServe()
+			if err != nil {
+				logger.Error("DNS server failed", log.Ctx{"address": address, "err": err})
+			}
+		}(server)
+	}
+	wg.Wait()
+
+	dnsServers = servers
+
+	return nil
+}
+
+func dnsServerStopLocked() {
+	for _, server := range dnsServers {
+		server.Shutdown()
+	}
+
+	dnsServers = nil
+}
+
+// dnsServerStop stops serving the network zones.
+func dnsServerStop() {
+	dnsServersLock.Lock()
+	defer dnsServersLock.Unlock()
+
+	dnsServerStopLocked()
+}
+
+// dnsServerReload restarts the server, if running, so it picks up changes to
+// the peers' TSIG keys.
+func dnsServerReload(d *Daemon) error {
+	address := daemonConfig["core.dns_address"].Get()
+	if address == "" {
+		return nil
+	}
+
+	return dnsServerStart(d, address)
+}
+
+func daemonConfigSetDNSAddress(d *Daemon, key string, value string) (string, error) {
+	err := dnsServerStart(d, value)
+	if err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+type dnsHandler struct {
+	d *Daemon
+}
+
+// zoneFor returns the most specific zone a name belongs to.
+func (h dnsHandler) zoneFor(name string) (*api.NetworkZone, error) {
+	zones, err := dbNetworkZones(h.d.db)
+	if err != nil {
+		return nil, err
+	}
+
+	best := ""
+	for _, zone := range zones {
+		if dns.IsSubDomain(dns.Fqdn(zone), name) && len(zone) > len(best) {
+			best = zone
+		}
+	}
+
+	if best == "" {
+		return nil, nil
+	}
+
+	_, zone, err := dbNetworkZoneGet(h.d.db, best)
+	if err != nil {
+		return nil, err
+	}
+
+	return zone, nil
+}
+
+func (h dnsHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	refuse := func(rcode int) {
+		m.SetRcode(r, rcode)
+		w.WriteMsg(m)
+	}
+
+	if r.Opcode != dns.OpcodeQuery || len(r.Question) != 1 {
+		refuse(dns.RcodeNotImplemented)
+		return
+	}
+
+	question := r.Question[0]
+
+	zone, err := h.zoneFor(question.Name)
+	if err != nil {
+		logger.Error("Failed to look up network zone", log.Ctx{"name": question.Name, "err": err})
+		refuse(dns.RcodeServerFailure)
+		return
+	}
+
+	if zone == nil {
+		refuse(dns.RcodeRefused)
+		return
+	}
+
+	// Only answer the zone's peers, signing the reply if they signed the request
+	var address net.IP
+	var isTCP bool
+	switch addr := w.RemoteAddr().(type) {
+	case *net.UDPAddr:
+		address = addr.IP
+	case *net.TCPAddr:
+		address = addr.IP
+		isTCP = true
+	}
+
+	keyName := ""
+	tsig := r.IsTsig()
+	if tsig != nil && w.TsigStatus() == nil {
+		keyName = tsig.Hdr.Name
+		m.SetTsig(keyName, tsig.Algorithm, 300, time.Now().Unix())
+	}
+
+	if !networkZoneAllowed(zone.Name, zone.Config, address, keyName) {
+		logger.Warn("Refused DNS request", log.Ctx{"zone": zone.Name, "client": address.String(), "key": keyName})
+		refuse(dns.RcodeRefused)
+		return
+	}
+
+	records, err := networkZoneGetRecords(h.d, zone)
+	if err != nil {
+		logger.Error("Failed to get the network zone records", log.Ctx{"zone": zone.Name, "err": err})
+		refuse(dns.RcodeServerFailure)
+		return
+	}
+
+	// Zone transfers, IXFR requests getting the full zone too
+	if question.Qtype == dns.TypeAXFR || question.Qtype == dns.TypeIXFR {
+		if !isTCP || !strings.EqualFold(question.Name, dns.Fqdn(zone.Name)) {
+			refuse(dns.RcodeRefused)
+			return
+		}
+
+		records = append(records, records[0])
+
+		ch := make(chan *dns.Envelope, len(records)/dnsTransferChunk+1)
+		for i := 0; i < len(records); i += dnsTransferChunk {
+			end := i + dnsTransferChunk
+			if end > len(records) {
+				end = len(records)
+			}
+
+			ch <- &dns.Envelope{RR: records[i:end]}
+		}
+		close(ch)
+
+		tr := new(dns.Transfer)
+		err := tr.Out(w, r, ch)
+		if err != nil {
+			logger.Error("Failed to transfer network zone", log.Ctx{"zone": zone.Name, "client": address.String(), "err": err})
+		}
+
+		return
+	}
+
+	m.Authoritative = true
+
+	found := false
+	for _, rr := range records {
+		if !strings.EqualFold(rr.Header().Name, question.Name) {
+			continue
+		}
+
+		found = true
+		if question.Qtype == dns.TypeANY || question.Qtype == rr.Header().Rrtype {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+
+	if !found {
+		m.Rcode = dns.RcodeNameError
+	}
+
+	if len(m.Answer) == 0 {
+		m.Ns = []dns.RR{records[0]}
+	}
+
+	w.WriteMsg(m)
+}
@@ -13,6 +13,7 @@ import (
 	log "gopkg.in/inconshreveable/log15.v2"
 
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -36,16 +37,27 @@ func logContextMap(ctx []interface{}) map[string]string {
 }
 
 func (h eventsHandler) Log(r *log.Record) error {
-	eventSend("logging", shared.Jmap{
-		"message": r.Msg,
-		"level":   r.Lvl.String(),
-		"context": logContextMap(r.Ctx)})
+	eventSend("logging", api.EventLogging{
+		Message: r.Msg,
+		Level:   r.Lvl.String(),
+		Context: logContextMap(r.Ctx)})
 	return nil
 }
 
 var eventsLock sync.Mutex
 var eventListeners map[string]*eventListener = make(map[string]*eventListener)
 
+// Number of past events kept around for replay to reconnecting listeners
+const eventsHistorySize = 1000
+
+var eventsHistory []eventHistoryEntry
+
+type eventHistoryEntry struct {
+	timestamp time.Time
+	eventType string
+	body      []byte
+}
+
 type eventListener struct {
 	connection   *websocket.Conn
 	messageTypes []string
@@ -55,23 +67,44 @@ type eventListener struct {
 }
 
 type eventsServe struct {
-	req *http.Request
+	req   *http.Request
+	since time.Time
 }
 
 func (r *eventsServe) Render(w http.ResponseWriter) error {
-	return eventsSocket(r.req, w)
+	return eventsSocket(r.req, w, r.since)
 }
 
 func (r *eventsServe) String() string {
 	return "event handler"
 }
 
-func eventsSocket(r *http.Request, w http.ResponseWriter) error {
+// eventsParseSince parses the "since" argument of the events API, either an
+// RFC3339 timestamp or a duration relative to the current time.
+func eventsParseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	since, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return since, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid since value: %s", value)
+	}
+
+	return time.Now().Add(-duration), nil
+}
+
+func eventsSocket(r *http.Request, w http.ResponseWriter, since time.Time) error {
 	listener := eventListener{}
 
 	typeStr := r.FormValue("type")
 	if typeStr == "" {
-		typeStr = "logging,operation"
+		typeStr = "lifecycle,logging,operation"
 	}
 
 	c, err := shared.WebsocketUpgrader.Upgrade(w, r, nil)
@@ -84,10 +117,37 @@ func eventsSocket(r *http.Request, w http.ResponseWriter) error {
 	listener.id = uuid.NewRandom().String()
 	listener.messageTypes = strings.Split(typeStr, ",")
 
+	// Hold the message lock until the replay is done so that new events
+	// only get sent after the past ones.
+	listener.msgLock.Lock()
+
 	eventsLock.Lock()
 	eventListeners[listener.id] = &listener
+	history := []eventHistoryEntry{}
+	if !since.IsZero() {
+		for _, entry := range eventsHistory {
+			if entry.timestamp.Before(since) {
+				continue
+			}
+
+			if !shared.StringInSlice(entry.eventType, listener.messageTypes) {
+				continue
+			}
+
+			history = append(history, entry)
+		}
+	}
 	eventsLock.Unlock()
 
+	for _, entry := range history {
+		err = listener.connection.WriteMessage(websocket.TextMessage, entry.body)
+		if err != nil {
+			listener.active <- false
+			break
+		}
+	}
+	listener.msgLock.Unlock()
+
 	logger.Debugf("New events listener: %s", listener.id)
 
 	<-listener.active
@@ -103,16 +163,29 @@ func eventsSocket(r *http.Request, w http.ResponseWriter) error {
 }
 
 func eventsGet(d *Daemon, r *http.Request) Response {
-	return &eventsServe{r}
+	since, err := eventsParseSince(r.FormValue("since"))
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	return &eventsServe{req: r, since: since}
 }
 
 var eventsCmd = Command{name: "events", get: eventsGet}
 
 func eventSend(eventType string, eventMessage interface{}) error {
-	event := shared.Jmap{}
-	event["type"] = eventType
-	event["timestamp"] = time.Now()
-	event["metadata"] = eventMessage
+	timestamp := time.Now()
+
+	metadata, err := json.Marshal(eventMessage)
+	if err != nil {
+		return err
+	}
+
+	event := api.Event{
+		Type:      eventType,
+		Timestamp: timestamp,
+		Metadata:  metadata,
+	}
 
 	body, err := json.Marshal(event)
 	if err != nil {
@@ -120,6 +193,11 @@ func eventSend(eventType string, eventMessage interface{}) error {
 	}
 
 	eventsLock.Lock()
+	eventsHistory = append(eventsHistory, eventHistoryEntry{timestamp: timestamp, eventType: eventType, body: body})
+	if len(eventsHistory) > eventsHistorySize {
+		eventsHistory = eventsHistory[len(eventsHistory)-eventsHistorySize:]
+	}
+
 	listeners := eventListeners
 	for _, listener := range listeners {
 		if !shared.StringInSlice(eventType, listener.messageTypes) {
@@ -142,5 +220,10 @@ func eventSend(eventType string, eventMessage interface{}) error {
 	}
 	eventsLock.Unlock()
 
+	lifecycle, ok := eventMessage.(api.EventLifecycle)
+	if ok {
+		webhooksNotify(lifecycle.Action, body)
+	}
+
 	return nil
 }
@@ -53,6 +53,7 @@ func detectCompression(fname string) ([]string, string, error) {
 	// gz - 2 bytes, 0x1f 0x8b
 	// lzma - 6 bytes, { [0x000, 0xE0], '7', 'z', 'X', 'Z', 0x00 } -
 	// xy - 6 bytes,  header format { 0xFD, '7', 'z', 'X', 'Z', 0x00 }
+	// zstd - 4 bytes, 0x28 0xB5 0x2F 0xFD
 	// tar - 263 bytes, trying to get ustar from 257 - 262
 	header := make([]byte, 263)
 	_, err = f.Read(header)
@@ -71,6 +72,8 @@ func detectCompression(fname string) ([]string, string, error) {
 		return []string{"--lzma", "-xf"}, ".tar.lzma", nil
 	case bytes.Equal(header[0:3], []byte{0x5d, 0x00, 0x00}):
 		return []string{"--lzma", "-xf"}, ".tar.lzma", nil
+	case bytes.Equal(header[0:4], []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return []string{"--use-compress-program=zstd", "-xf"}, ".tar.zst", nil
 	case bytes.Equal(header[257:262], []byte{'u', 's', 't', 'a', 'r'}):
 		return []string{"-xf"}, ".tar", nil
 	case bytes.Equal(header[0:4], []byte{'h', 's', 'q', 's'}):
@@ -81,6 +84,30 @@ func detectCompression(fname string) ([]string, string, error) {
 
 }
 
+// Multi-threaded drop-in replacements for the decompression tools, used by
+// tar when installed.
+var parallelDecompressors = map[string]string{
+	".tar.gz":  "pigz",
+	".tar.bz2": "pbzip2",
+	".tar.xz":  "pixz",
+}
+
+// tarDecompressArgs returns the tar arguments extracting a compressed
+// tarball, going through a parallel decompressor when one is available.
+func tarDecompressArgs(extractArgs []string, extension string) []string {
+	program, ok := parallelDecompressors[extension]
+	if !ok {
+		return extractArgs
+	}
+
+	_, err := exec.LookPath(program)
+	if err != nil {
+		return extractArgs
+	}
+
+	return []string{fmt.Sprintf("--use-compress-program=%s", program), "-xf"}
+}
+
 func unpack(d *Daemon, file string, path string, sType storageType) error {
 	extractArgs, extension, err := detectCompression(file)
 	if err != nil {
@@ -99,7 +126,7 @@ func unpack(d *Daemon, file string, path string, sType storageType) error {
 			args = append(args, "--exclude=rootfs/./dev/*")
 		}
 		args = append(args, "-C", path, "--numeric-owner")
-		args = append(args, extractArgs...)
+		args = append(args, tarDecompressArgs(extractArgs, extension)...)
 		args = append(args, file)
 	} else if strings.HasPrefix(extension, ".squashfs") {
 		command = "unsquashfs"
@@ -151,22 +178,31 @@ func unpack(d *Daemon, file string, path string, sType storageType) error {
 }
 
 func unpackImage(d *Daemon, imagefname string, destpath string, sType storageType) error {
-	err := unpack(d, imagefname, destpath, sType)
-	if err != nil {
-		return err
-	}
-
 	rootfsPath := fmt.Sprintf("%s/rootfs", destpath)
+
+	// Split images get their metadata and rootfs unpacked concurrently
+	chRootfs := make(chan error, 1)
 	if shared.PathExists(imagefname + ".rootfs") {
-		err = os.MkdirAll(rootfsPath, 0755)
+		err := os.MkdirAll(rootfsPath, 0755)
 		if err != nil {
 			return fmt.Errorf("Error creating rootfs directory")
 		}
 
-		err = unpack(d, imagefname+".rootfs", rootfsPath, sType)
-		if err != nil {
-			return err
-		}
+		go func() {
+			chRootfs <- unpack(d, imagefname+".rootfs", rootfsPath, sType)
+		}()
+	} else {
+		chRootfs <- nil
+	}
+
+	err := unpack(d, imagefname, destpath, sType)
+	errRootfs := <-chRootfs
+	if err != nil {
+		return err
+	}
+
+	if errRootfs != nil {
+		return errRootfs
 	}
 
 	if !shared.PathExists(rootfsPath) {
@@ -176,6 +212,13 @@ func unpackImage(d *Daemon, imagefname string, destpath string, sType storageTyp
 	return nil
 }
 
+// Multi-threaded drop-in replacements for the compression tools, used when
+// installed.
+var parallelCompressors = map[string]string{
+	"gzip":  "pigz",
+	"bzip2": "pbzip2",
+}
+
 func compressFile(path string, compress string) (string, error) {
 	reproducible := []string{"gzip"}
 
@@ -184,7 +227,26 @@ func compressFile(path string, compress string) (string, error) {
 		args = append(args, "-n")
 	}
 
-	cmd := exec.Command(compress, args...)
+	// xz and zstd are multi-threaded by themselves
+	if shared.StringInSlice(compress, []string{"xz", "zstd"}) {
+		args = append(args, "-T0")
+	}
+
+	// zstd prints progress to stderr by default
+	if compress == "zstd" {
+		args = append(args, "-q")
+	}
+
+	program := compress
+	parallel, ok := parallelCompressors[compress]
+	if ok {
+		_, err := exec.LookPath(parallel)
+		if err == nil {
+			program = parallel
+		}
+	}
+
+	cmd := exec.Command(program, args...)
 
 	outfile, err := os.Create(path + ".compressed")
 	if err != nil {
@@ -270,7 +332,6 @@ func imgPostContInfo(d *Daemon, r *http.Request, req api.ImagesPost, builddir st
 	}
 	tarfile.Close()
 
-	var compressedPath string
 	var compress string
 
 	if req.CompressionAlgorithm != "" {
@@ -279,52 +340,61 @@ func imgPostContInfo(d *Daemon, r *http.Request, req api.ImagesPost, builddir st
 		compress = daemonConfig["images.compression_algorithm"].Get()
 	}
 
+	err = imageStoreTarball(d, &info, tarfile.Name(), compress)
+	if err != nil {
+		return nil, err
+	}
+
+	info.Architecture, _ = osarch.ArchitectureName(c.Architecture())
+	info.Properties = req.Properties
+
+	// Create the database entry
+	err = dbImageInsert(d.db, info.Fingerprint, info.Filename, info.Size, info.Public, info.AutoUpdate, info.Architecture, info.CreatedAt, info.ExpiresAt, info.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// imageStoreTarball compresses a freshly built image tarball and moves it
+// into the image store, filling in the size and fingerprint of the image.
+func imageStoreTarball(d *Daemon, info *api.Image, path string, compress string) error {
+	var compressedPath string
+	var err error
+
 	if compress != "none" {
-		compressedPath, err = compressFile(tarfile.Name(), compress)
+		compressedPath, err = compressFile(path, compress)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	} else {
-		compressedPath = tarfile.Name()
+		compressedPath = path
 	}
 	defer os.Remove(compressedPath)
 
 	sha256 := sha256.New()
 	tarf, err := os.Open(compressedPath)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	info.Size, err = io.Copy(sha256, tarf)
 	tarf.Close()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	info.Fingerprint = fmt.Sprintf("%x", sha256.Sum(nil))
 
 	_, _, err = dbImageGet(d.db, info.Fingerprint, false, true)
 	if err == nil {
-		return nil, fmt.Errorf("The image already exists: %s", info.Fingerprint)
+		return fmt.Errorf("The image already exists: %s", info.Fingerprint)
 	}
 
 	/* rename the the file to the expected name so our caller can use it */
 	finalName := shared.VarPath("images", info.Fingerprint)
-	err = shared.FileMove(compressedPath, finalName)
-	if err != nil {
-		return nil, err
-	}
-
-	info.Architecture, _ = osarch.ArchitectureName(c.Architecture())
-	info.Properties = req.Properties
-
-	// Create the database entry
-	err = dbImageInsert(d.db, info.Fingerprint, info.Filename, info.Size, info.Public, info.AutoUpdate, info.Architecture, info.CreatedAt, info.ExpiresAt, info.Properties)
-	if err != nil {
-		return nil, err
-	}
-
-	return &info, nil
+	return shared.FileMove(compressedPath, finalName)
 }
 
 func imgPostRemoteInfo(d *Daemon, req api.ImagesPost, op *operation) (*api.Image, error) {
@@ -440,7 +510,6 @@ func getImgPostInfo(d *Daemon, r *http.Request, builddir string, post *os.File)
 
 	public, _ := strconv.Atoi(r.Header.Get("X-LXD-public"))
 	info.Public = public == 1
-	propHeaders := r.Header[http.CanonicalHeaderKey("X-LXD-properties")]
 	ctype, ctypeParams, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	if err != nil {
 		ctype = "application/octet-stream"
@@ -611,13 +680,12 @@ func getImgPostInfo(d *Daemon, r *http.Request, builddir string, post *os.File)
 	info.ExpiresAt = time.Unix(imageMeta.ExpiryDate, 0)
 
 	info.Properties = imageMeta.Properties
-	if len(propHeaders) > 0 {
-		for _, ph := range propHeaders {
-			p, _ := url.ParseQuery(ph)
-			for pkey, pval := range p {
-				info.Properties[pkey] = pval[0]
-			}
-		}
+	if info.Properties == nil {
+		info.Properties = map[string]string{}
+	}
+
+	for pkey, pval := range imageUploadProperties(r) {
+		info.Properties[pkey] = pval
 	}
 
 	// Check if the image already exists
@@ -637,6 +705,20 @@ func getImgPostInfo(d *Daemon, r *http.Request, builddir string, post *os.File)
 	return &info, nil
 }
 
+// imageUploadProperties returns the image properties passed in the
+// X-LXD-properties headers of an image upload.
+func imageUploadProperties(r *http.Request) map[string]string {
+	properties := map[string]string{}
+	for _, ph := range r.Header[http.CanonicalHeaderKey("X-LXD-properties")] {
+		p, _ := url.ParseQuery(ph)
+		for pkey, pval := range p {
+			properties[pkey] = pval[0]
+		}
+	}
+
+	return properties
+}
+
 // imageCreateInPool() creates a new storage volume in a given storage pool for
 // the image. No entry in the images database will be created. This implies that
 // imageCreateinPool() should only be called when an image already exists in the
@@ -708,7 +790,7 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 		imageUpload = true
 	}
 
-	if !imageUpload && !shared.StringInSlice(req.Source.Type, []string{"container", "snapshot", "image", "url"}) {
+	if !imageUpload && !shared.StringInSlice(req.Source.Type, []string{"container", "snapshot", "image", "url", "oci"}) {
 		cleanup(builddir, post)
 		return InternalError(fmt.Errorf("Invalid images JSON"))
 	}
@@ -733,6 +815,17 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 				if err != nil {
 					return err
 				}
+			} else if req.Source.Type == "oci" {
+				/* Processing image conversion from an OCI registry */
+				source, err := ociRegistryReference(req.Source.URL)
+				if err != nil {
+					return err
+				}
+
+				info, err = imgPostOCIInfo(d, req, builddir, source, strings.TrimPrefix(source, "docker://"))
+				if err != nil {
+					return err
+				}
 			} else {
 				/* Processing image creation from container */
 				imagePublishLock.Lock()
@@ -743,6 +836,14 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 				}
 				imagePublishLock.Unlock()
 			}
+		} else if isDockerArchive(post.Name()) {
+			/* Processing image conversion from a "docker save" tarball */
+			req.Public = r.Header.Get("X-LXD-public") == "1"
+			req.Properties = imageUploadProperties(r)
+			info, err = imgPostOCIArchiveInfo(d, req, builddir, post, r.Header.Get("X-LXD-filename"))
+			if err != nil {
+				return err
+			}
 		} else {
 			/* Processing image upload */
 			info, err = getImgPostInfo(d, r, builddir, post)
@@ -856,16 +957,27 @@ func doImagesGet(d *Daemon, recursion bool, public bool) (interface{}, error) {
 		return resultString, nil
 	}
 
-	return resultMap, nil
+	return resultMap[:i], nil
 }
 
 func imagesGet(d *Daemon, r *http.Request) Response {
 	public := !d.isTrustedClient(r)
 
+	params, err := listParamsGet(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	result, err := doImagesGet(d, d.isRecursionRequest(r), public)
 	if err != nil {
 		return SmartError(err)
 	}
+
+	result, err = params.apply(result, "fingerprint")
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	return SyncResponse(true, result)
 }
 
@@ -928,25 +1040,30 @@ func autoUpdateImage(d *Daemon, op *operation, fingerprint string, id int, info
 
 	logger.Debug("Processing image", log.Ctx{"fp": fingerprint, "server": source.Server, "protocol": source.Protocol, "alias": source.Alias})
 
+	hash := fingerprint
+
 	// Set operation metadata to indicate whether a refresh happened
 	setRefreshResult := func(result bool) {
 		if op == nil {
 			return
 		}
 
-		metadata := map[string]interface{}{"refreshed": result}
+		metadata := map[string]interface{}{"refreshed": result, "fingerprint": hash}
 		op.UpdateMetadata(metadata)
 	}
 
 	// Update the image on each pool where it currently exists.
-	hash := fingerprint
+	var downloadErr error
+	downloaded := 0
 	for _, poolName := range poolNames {
 		newInfo, err := d.ImageDownload(op, source.Server, source.Protocol, source.Certificate, "", source.Alias, false, true, poolName, false)
 
 		if err != nil {
 			logger.Error("Failed to update the image", log.Ctx{"err": err, "fp": fingerprint})
+			downloadErr = err
 			continue
 		}
+		downloaded++
 
 		hash = newInfo.Fingerprint
 		if hash == fingerprint {
@@ -982,6 +1099,11 @@ func autoUpdateImage(d *Daemon, op *operation, fingerprint string, id int, info
 		}
 	}
 
+	// Don't report an unreachable source as the image being up to date
+	if downloaded == 0 && downloadErr != nil {
+		return fmt.Errorf("Failed to update the image: %v", downloadErr)
+	}
+
 	// Image didn't change, nothing to do.
 	if hash == fingerprint {
 		setRefreshResult(false)
@@ -1018,14 +1140,19 @@ func autoUpdateImage(d *Daemon, op *operation, fingerprint string, id int, info
 func pruneExpiredImages(d *Daemon) {
 	logger.Infof("Pruning expired images")
 
-	// Get the list of expired images.
-	expiry := daemonConfig["images.remote_cache_expiry"].GetInt64()
-	images, err := dbImagesGetExpired(d.db, expiry)
+	// Get the list of cached images.
+	cached, err := imagesCacheList(d)
 	if err != nil {
-		logger.Error("Unable to retrieve the list of expired images", log.Ctx{"err": err})
+		logger.Error("Unable to retrieve the list of cached images", log.Ctx{"err": err})
 		return
 	}
 
+	// Pick the expired ones and the least recently used ones over the cache size.
+	expiry := daemonConfig["images.remote_cache_expiry"].GetInt64()
+	origins, _ := imagesCacheParseOrigins(daemonConfig["images.remote_cache_expiry_origins"].Get())
+	maxSize, _ := shared.ParseByteSizeString(daemonConfig["images.remote_cache_max_size"].Get())
+	images := imagesCacheEvict(cached, expiry, origins, maxSize, time.Now())
+
 	// Delete them
 	for _, fp := range images {
 		// Get the IDs of all storage pools on which a storage volume
@@ -1608,9 +1735,17 @@ func imageRefresh(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	// Only images which were downloaded from a remote can be refreshed
+	_, _, err = dbImageSourceGet(d.db, imageId)
+	if err == NoSuchObjectError {
+		return BadRequest(fmt.Errorf("Image \"%s\" has no source to refresh from", imageInfo.Fingerprint))
+	} else if err != nil {
+		return SmartError(err)
+	}
+
 	// Begin background operation
 	run := func(op *operation) error {
-		return autoUpdateImage(d, op, fingerprint, imageId, imageInfo)
+		return autoUpdateImage(d, op, imageInfo.Fingerprint, imageId, imageInfo)
 	}
 
 	op, err := operationCreate(operationClassTask, nil, nil, run, nil, nil)
@@ -1619,7 +1754,6 @@ func imageRefresh(d *Daemon, r *http.Request) Response {
 	}
 
 	return OperationResponse(op)
-
 }
 
 var imagesExportCmd = Command{name: "images/{fingerprint}/export", untrustedGet: true, get: imageExport}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/shared"
+)
+
+/* Images downloaded to create containers are kept in a cache. They're
+ * removed once unused for images.remote_cache_expiry days, an expiry which
+ * images.remote_cache_expiry_origins can override for the images of given
+ * servers. When images.remote_cache_max_size is set, the least recently used
+ * images are then removed until the cache fits in it.
+ *
+ * Images a container is being created from are kept until it's created.
+ */
+
+// imageCacheEntry is a cached image as considered for eviction
+type imageCacheEntry struct {
+	fingerprint string
+	origin      string
+	size        int64
+	lastUse     time.Time
+	held        bool
+}
+
+// Images containers are being created from, with the number of creations
+var imagesCacheHeld = map[string]int{}
+var imagesCacheHeldLock sync.Mutex
+
+// imagesCacheHold keeps an image from being evicted while a container gets
+// created from it, until imagesCacheRelease.
+func imagesCacheHold(fingerprint string) {
+	imagesCacheHeldLock.Lock()
+	imagesCacheHeld[fingerprint]++
+	imagesCacheHeldLock.Unlock()
+}
+
+func imagesCacheRelease(fingerprint string) {
+	imagesCacheHeldLock.Lock()
+	imagesCacheHeld[fingerprint]--
+	if imagesCacheHeld[fingerprint] <= 0 {
+		delete(imagesCacheHeld, fingerprint)
+	}
+	imagesCacheHeldLock.Unlock()
+}
+
+type imageCacheByLastUse []imageCacheEntry
+
+func (a imageCacheByLastUse) Len() int           { return len(a) }
+func (a imageCacheByLastUse) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a imageCacheByLastUse) Less(i, j int) bool { return a[i].lastUse.Before(a[j].lastUse) }
+
+// imagesCacheParseOrigins parses a comma separated list of origin=days
+// entries, the origin being a server URL or host name.
+func imagesCacheParseOrigins(value string) (map[string]int64, error) {
+	origins := map[string]int64{}
+	if value == "" {
+		return origins, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		fields := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(fields) != 2 || fields[0] == "" {
+			return nil, fmt.Errorf("Invalid origin expiry, must be origin=days: %s", entry)
+		}
+
+		days, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("Invalid number of days for %s: %s", fields[0], fields[1])
+		}
+
+		origins[strings.TrimRight(fields[0], "/")] = days
+	}
+
+	return origins, nil
+}
+
+func daemonConfigValidateCacheOrigins(d *Daemon, key string, value string) error {
+	_, err := imagesCacheParseOrigins(value)
+	return err
+}
+
+func daemonConfigValidateCacheMaxSize(d *Daemon, key string, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := shared.ParseByteSizeString(value)
+	return err
+}
+
+// imagesCacheOriginExpiry returns the expiry in days applying to an image
+// downloaded from the given server.
+func imagesCacheOriginExpiry(origin string, expiry int64, origins map[string]int64) int64 {
+	origin = strings.TrimRight(origin, "/")
+	days, ok := origins[origin]
+	if ok {
+		return days
+	}
+
+	u, err := url.Parse(origin)
+	if err == nil && u.Host != "" {
+		days, ok = origins[u.Host]
+		if ok {
+			return days
+		}
+
+		host, _, err := net.SplitHostPort(u.Host)
+		if err == nil {
+			days, ok = origins[host]
+			if ok {
+				return days
+			}
+		}
+	}
+
+	return expiry
+}
+
+// imagesCacheEvict returns the fingerprints of the cached images to remove,
+// first the ones unused for longer than their expiry and then the least
+// recently used ones until the rest fits in maxSize (0 for no limit). Held
+// images are kept, still counting towards the size.
+func imagesCacheEvict(images []imageCacheEntry, expiry int64, origins map[string]int64, maxSize int64, now time.Time) []string {
+	evicted := []string{}
+	kept := []imageCacheEntry{}
+
+	for _, image := range images {
+		if image.held {
+			kept = append(kept, image)
+			continue
+		}
+
+		days := imagesCacheOriginExpiry(image.origin, expiry, origins)
+		if !image.lastUse.After(now.Add(-time.Duration(days) * 24 * time.Hour)) {
+			evicted = append(evicted, image.fingerprint)
+			continue
+		}
+
+		kept = append(kept, image)
+	}
+
+	if maxSize <= 0 {
+		return evicted
+	}
+
+	total := int64(0)
+	for _, image := range kept {
+		total += image.size
+	}
+
+	// Least recently used first
+	sort.Stable(imageCacheByLastUse(kept))
+
+	for _, image := range kept {
+		if total <= maxSize {
+			break
+		}
+
+		if image.held {
+			continue
+		}
+
+		evicted = append(evicted, image.fingerprint)
+		total -= image.size
+	}
+
+	return evicted
+}
+
+// imagesCacheList returns the cached images along with their origin, size
+// and last use, the download date standing for it for never used images.
+func imagesCacheList(d *Daemon) ([]imageCacheEntry, error) {
+	fingerprints, err := dbImagesGetCached(d.db)
+	if err != nil {
+		return nil, err
+	}
+
+	held := []string{}
+	imagesCacheHeldLock.Lock()
+	for fp := range imagesCacheHeld {
+		held = append(held, fp)
+	}
+	imagesCacheHeldLock.Unlock()
+
+	images := []imageCacheEntry{}
+	for _, fp := range fingerprints {
+		_, info, err := dbImageGet(d.db, fp, false, true)
+		if err != nil {
+			continue
+		}
+
+		image := imageCacheEntry{
+			fingerprint: fp,
+			size:        info.Size,
+			lastUse:     info.LastUsedAt,
+			held:        shared.StringInSlice(fp, held),
+		}
+
+		if image.lastUse.IsZero() {
+			image.lastUse = info.UploadedAt
+		}
+
+		if info.UpdateSource != nil {
+			image.origin = info.UpdateSource.Server
+		}
+
+		images = append(images, image)
+	}
+
+	return images, nil
+}
+
+// imagesCacheCheckSize asks for a pruning run when a newly cached image may
+// have pushed the cache over its maximum size, once the container using it
+// got created.
+func imagesCacheCheckSize(d *Daemon) {
+	if daemonConfig["images.remote_cache_max_size"].Get() == "" || d.pruneChan == nil {
+		return
+	}
+
+	// Don't wait for a run already in progress, the daily one catches up
+	select {
+	case d.pruneChan <- true:
+	default:
+	}
+}
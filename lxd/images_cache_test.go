@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestImagesCacheParseOrigins(t *testing.T) {
+	origins, err := imagesCacheParseOrigins("images.linuxcontainers.org=2, https://cloud-images.ubuntu.com/releases/=30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int64{
+		"images.linuxcontainers.org":               2,
+		"https://cloud-images.ubuntu.com/releases": 30,
+	}
+
+	if !reflect.DeepEqual(origins, expected) {
+		t.Errorf("Expected %v, got %v", expected, origins)
+	}
+
+	for _, value := range []string{"images.linuxcontainers.org", "=2", "foo=-1", "foo=bar"} {
+		_, err := imagesCacheParseOrigins(value)
+		if err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestImagesCacheOriginExpiry(t *testing.T) {
+	origins := map[string]int64{
+		"images.linuxcontainers.org":               2,
+		"https://cloud-images.ubuntu.com/releases": 30,
+	}
+
+	tests := map[string]int64{
+		"https://images.linuxcontainers.org":             2,
+		"https://images.linuxcontainers.org:8443":        2,
+		"https://cloud-images.ubuntu.com/releases/":      30,
+		"https://cloud-images.ubuntu.com/daily":          10,
+		"https://example.com/images.linuxcontainers.org": 10,
+		"": 10,
+	}
+
+	for origin, expected := range tests {
+		days := imagesCacheOriginExpiry(origin, 10, origins)
+		if days != expected {
+			t.Errorf("Expected %d days for %q, got %d", expected, origin, days)
+		}
+	}
+}
+
+func TestImagesCacheEvict(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+
+	images := []imageCacheEntry{
+		{fingerprint: "old", origin: "https://example.com", size: 10, lastUse: now.Add(-11 * day)},
+		{fingerprint: "daily", origin: "https://images.linuxcontainers.org", size: 10, lastUse: now.Add(-3 * day)},
+		{fingerprint: "lru", origin: "https://example.com", size: 40, lastUse: now.Add(-5 * day)},
+		{fingerprint: "recent", origin: "https://example.com", size: 40, lastUse: now.Add(-1 * day)},
+		{fingerprint: "mru", origin: "https://example.com", size: 40, lastUse: now},
+	}
+
+	origins := map[string]int64{"images.linuxcontainers.org": 2}
+
+	// Expiry only
+	evicted := imagesCacheEvict(images, 10, origins, 0, now)
+	if !reflect.DeepEqual(evicted, []string{"old", "daily"}) {
+		t.Errorf("Unexpected eviction without size limit: %v", evicted)
+	}
+
+	// 120 bytes left after expiry, the least recently used go first
+	evicted = imagesCacheEvict(images, 10, origins, 80, now)
+	if !reflect.DeepEqual(evicted, []string{"old", "daily", "lru"}) {
+		t.Errorf("Unexpected eviction with a size limit: %v", evicted)
+	}
+
+	evicted = imagesCacheEvict(images, 10, origins, 50, now)
+	if !reflect.DeepEqual(evicted, []string{"old", "daily", "lru", "recent"}) {
+		t.Errorf("Unexpected eviction with a small size limit: %v", evicted)
+	}
+
+	evicted = imagesCacheEvict(images, 10, origins, 1000, now)
+	if !reflect.DeepEqual(evicted, []string{"old", "daily"}) {
+		t.Errorf("Unexpected eviction with a large size limit: %v", evicted)
+	}
+
+	// Held images are kept, whatever their age
+	images[0].held = true
+	images[2].held = true
+	evicted = imagesCacheEvict(images, 10, origins, 50, now)
+	if !reflect.DeepEqual(evicted, []string{"daily", "recent", "mru"}) {
+		t.Errorf("Unexpected eviction with held images: %v", evicted)
+	}
+}
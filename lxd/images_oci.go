@@ -0,0 +1,414 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/osarch"
+)
+
+// ociTag is the tag the fetched image is stored under in the temporary OCI
+// layout, before being unpacked.
+const ociTag = "lxd"
+
+// ociImageConfig is the part of an OCI image configuration that gets
+// translated into LXD image metadata.
+type ociImageConfig struct {
+	Architecture string    `json:"architecture"`
+	OS           string    `json:"os"`
+	Created      time.Time `json:"created"`
+	Config       struct {
+		Entrypoint []string          `json:"Entrypoint"`
+		Cmd        []string          `json:"Cmd"`
+		Env        []string          `json:"Env"`
+		WorkingDir string            `json:"WorkingDir"`
+		Labels     map[string]string `json:"Labels"`
+	} `json:"config"`
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// ociRegistryReference validates an image reference given by a client and
+// returns it as a skopeo docker:// source. Other transports would let
+// clients read arbitrary paths on the host, so they aren't accepted.
+func ociRegistryReference(reference string) (string, error) {
+	reference = strings.TrimPrefix(reference, "docker://")
+	reference = strings.TrimLeft(reference, "/")
+
+	if reference == "" {
+		return "", fmt.Errorf("Missing OCI image reference")
+	}
+
+	if strings.Contains(reference, "://") || strings.ContainsAny(reference, " \t\n") {
+		return "", fmt.Errorf("Invalid OCI image reference: %s", reference)
+	}
+
+	return "docker://" + reference, nil
+}
+
+// isDockerArchive returns whether the file is an (uncompressed) tarball as
+// produced by "docker save" rather than a LXD image.
+func isDockerArchive(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			return false
+		}
+
+		switch strings.TrimPrefix(hdr.Name, "./") {
+		case "metadata.yaml":
+			return false
+		case "manifest.json":
+			return true
+		}
+	}
+}
+
+// ociBlobPath returns the path of a blob in an OCI image layout.
+func ociBlobPath(layout string, digest string) (string, error) {
+	fields := strings.SplitN(digest, ":", 2)
+	if len(fields) != 2 || strings.Contains(fields[1], "/") {
+		return "", fmt.Errorf("Invalid OCI digest: %s", digest)
+	}
+
+	return filepath.Join(layout, "blobs", fields[0], fields[1]), nil
+}
+
+func ociReadJSON(path string, v interface{}) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, v)
+}
+
+// ociReadImageConfig finds the image tagged ociTag in an OCI image layout
+// and returns its configuration.
+func ociReadImageConfig(layout string) (*ociImageConfig, error) {
+	index := struct {
+		Manifests []ociDescriptor `json:"manifests"`
+	}{}
+
+	err := ociReadJSON(filepath.Join(layout, "index.json"), &index)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifestDesc *ociDescriptor
+	for i, desc := range index.Manifests {
+		if desc.Annotations["org.opencontainers.image.ref.name"] == ociTag {
+			manifestDesc = &index.Manifests[i]
+			break
+		}
+	}
+
+	if manifestDesc == nil {
+		return nil, fmt.Errorf("Couldn't find the image manifest")
+	}
+
+	manifestPath, err := ociBlobPath(layout, manifestDesc.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := struct {
+		Config ociDescriptor `json:"config"`
+	}{}
+
+	err = ociReadJSON(manifestPath, &manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	configPath, err := ociBlobPath(layout, manifest.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	config := ociImageConfig{}
+	err = ociReadJSON(configPath, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// ociArchitecture translates an OCI (Go) architecture name to LXD's.
+func ociArchitecture(arch string) (string, error) {
+	if arch == "arm" {
+		arch = "armhf"
+	}
+
+	id, err := osarch.ArchitectureId(arch)
+	if err != nil {
+		return "", err
+	}
+
+	return osarch.ArchitectureName(id)
+}
+
+// ociQuoteCommand joins a command the way a POSIX shell would split it back,
+// see ociSplitCommand.
+func ociQuoteCommand(args []string) string {
+	quoted := []string{}
+	for _, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`;&|<>()*?[]#~") {
+			quoted = append(quoted, arg)
+		} else {
+			quoted = append(quoted, fmt.Sprintf("'%s'", strings.Replace(arg, "'", `'\''`, -1)))
+		}
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// ociSplitCommand splits a command quoted as by a POSIX shell (without any
+// expansion) into its arguments.
+func ociSplitCommand(command string) ([]string, error) {
+	args := []string{}
+	arg := []rune{}
+	inArg := false
+	quote := rune(0)
+	escaped := false
+
+	for _, r := range command {
+		switch {
+		case escaped:
+			// Within double quotes, only some characters can be escaped
+			if quote == '"' && !strings.ContainsRune("\"\\$`", r) {
+				arg = append(arg, '\\')
+			}
+
+			arg = append(arg, r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				arg = append(arg, r)
+			}
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				arg = append(arg, r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, string(arg))
+				arg = []rune{}
+				inArg = false
+			}
+		default:
+			arg = append(arg, r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("Unterminated quoting in command: %s", command)
+	}
+
+	if inArg {
+		args = append(args, string(arg))
+	}
+
+	return args, nil
+}
+
+// ociLXCCommand joins a command so that LXC splits it back into the same
+// arguments when used as the container's init command. LXC only strips a
+// pair of quotes around each argument, so arguments with both kinds of
+// quotes can't be passed to it.
+func ociLXCCommand(args []string) (string, error) {
+	quoted := []string{}
+	for _, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n'\"") {
+			quoted = append(quoted, arg)
+		} else if !strings.Contains(arg, "'") {
+			quoted = append(quoted, fmt.Sprintf("'%s'", arg))
+		} else if !strings.Contains(arg, "\"") {
+			quoted = append(quoted, fmt.Sprintf("\"%s\"", arg))
+		} else {
+			return "", fmt.Errorf("Command arguments can't contain both single and double quotes: %s", arg)
+		}
+	}
+
+	return strings.Join(quoted, " "), nil
+}
+
+// ociProperties translates the configuration of an OCI image into the
+// properties of the LXD image built from it. The "oci.*" ones are used when
+// starting containers, see containerLXC.initLXC.
+func ociProperties(config *ociImageConfig, description string) map[string]string {
+	properties := map[string]string{
+		"description": description,
+		"os":          config.OS,
+	}
+
+	for _, key := range []string{"org.opencontainers.image.version", "version"} {
+		if config.Config.Labels[key] != "" {
+			properties["release"] = config.Config.Labels[key]
+			break
+		}
+	}
+
+	// Docker semantics, the command being the arguments of the entrypoint
+	command := append([]string{}, config.Config.Entrypoint...)
+	command = append(command, config.Config.Cmd...)
+	if len(command) > 0 {
+		properties["oci.cmd"] = ociQuoteCommand(command)
+	}
+
+	if len(config.Config.Env) > 0 {
+		properties["oci.env"] = strings.Join(config.Config.Env, "\n")
+	}
+
+	if config.Config.WorkingDir != "" {
+		properties["oci.cwd"] = config.Config.WorkingDir
+	}
+
+	return properties
+}
+
+// imgPostOCIInfo builds a LXD image out of an OCI image, fetching it with
+// skopeo from the given source (e.g. docker://alpine or
+// docker-archive:/path/to/tarball) and squashing its layers with umoci.
+func imgPostOCIInfo(d *Daemon, req api.ImagesPost, builddir string, source string, description string) (*api.Image, error) {
+	for _, tool := range []string{"skopeo", "umoci"} {
+		_, err := exec.LookPath(tool)
+		if err != nil {
+			return nil, fmt.Errorf("Importing OCI images requires %s to be installed", tool)
+		}
+	}
+
+	info := api.Image{}
+	info.Filename = req.Filename
+	info.Public = req.Public
+
+	// Fetch the image into an OCI layout
+	layout := filepath.Join(builddir, "oci")
+	output, err := shared.RunCommand("skopeo", "copy", source, fmt.Sprintf("oci:%s:%s", layout, ociTag))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch the OCI image: %s", strings.TrimSpace(output))
+	}
+
+	config, err := ociReadImageConfig(layout)
+	if err != nil {
+		return nil, err
+	}
+
+	info.Architecture, err = ociArchitecture(config.Architecture)
+	if err != nil {
+		return nil, err
+	}
+
+	info.CreatedAt = config.Created
+	if info.CreatedAt.IsZero() {
+		info.CreatedAt = time.Now().UTC()
+	}
+
+	info.Properties = ociProperties(config, description)
+	for k, v := range req.Properties {
+		info.Properties[k] = v
+	}
+
+	// Squash the layers into a rootfs
+	bundle := filepath.Join(builddir, "bundle")
+	output, err = shared.RunCommand("umoci", "unpack", "--image", fmt.Sprintf("%s:%s", layout, ociTag), bundle)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unpack the OCI image: %s", strings.TrimSpace(output))
+	}
+
+	// The layout isn't needed anymore, free the space early
+	os.RemoveAll(layout)
+
+	metadata := imageMetadata{
+		Architecture: info.Architecture,
+		CreationDate: info.CreatedAt.Unix(),
+		Properties:   info.Properties,
+	}
+
+	content, err := yaml.Marshal(&metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(bundle, "metadata.yaml"), content, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build a unified image tarball
+	tarfile, err := ioutil.TempFile(builddir, "lxd_build_tar_")
+	if err != nil {
+		return nil, err
+	}
+	tarfile.Close()
+	defer os.Remove(tarfile.Name())
+
+	output, err = shared.RunCommand("tar", "-C", bundle, "--numeric-owner", "-cf", tarfile.Name(), "metadata.yaml", "rootfs")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to build the image tarball: %s", strings.TrimSpace(output))
+	}
+	os.RemoveAll(bundle)
+
+	compress := req.CompressionAlgorithm
+	if compress == "" {
+		compress = daemonConfig["images.compression_algorithm"].Get()
+	}
+
+	err = imageStoreTarball(d, &info, tarfile.Name(), compress)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dbImageInsert(d.db, info.Fingerprint, info.Filename, info.Size, info.Public, info.AutoUpdate, info.Architecture, info.CreatedAt, info.ExpiresAt, info.Properties)
+	if err != nil {
+		os.Remove(shared.VarPath("images", info.Fingerprint))
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// imgPostOCIArchiveInfo converts an uploaded "docker save" tarball.
+func imgPostOCIArchiveInfo(d *Daemon, req api.ImagesPost, builddir string, post *os.File, filename string) (*api.Image, error) {
+	description := filename
+	if description == "" {
+		description = "Docker image archive"
+	}
+
+	req.Filename = filename
+	return imgPostOCIInfo(d, req, builddir, fmt.Sprintf("docker-archive:%s", post.Name()), description)
+}
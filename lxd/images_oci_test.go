@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_oci_registry_reference(t *testing.T) {
+	valid := map[string]string{
+		"alpine":                   "docker://alpine",
+		"docker://alpine:3.6":      "docker://alpine:3.6",
+		"docker:///library/ubuntu": "docker://library/ubuntu",
+	}
+
+	for reference, expected := range valid {
+		source, err := ociRegistryReference(reference)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %s", reference, err)
+		}
+
+		if source != expected {
+			t.Fatalf("Expected %q for %q, got %q", expected, reference, source)
+		}
+	}
+
+	for _, reference := range []string{"", "docker://", "oci:///etc", "docker://dir:///etc", "alpine latest"} {
+		_, err := ociRegistryReference(reference)
+		if err == nil {
+			t.Fatalf("Expected an error for %q", reference)
+		}
+	}
+}
+
+func Test_oci_quote_command(t *testing.T) {
+	args := []string{"/bin/sh", "-c", "echo hello", "it's", "", `say "it's"`, "$HOME"}
+	command := ociQuoteCommand(args)
+	expected := `/bin/sh -c 'echo hello' 'it'\''s' '' 'say "it'\''s"' '$HOME'`
+
+	if command != expected {
+		t.Fatalf("Expected %q, got %q", expected, command)
+	}
+
+	split, err := ociSplitCommand(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprintf("%q", split) != fmt.Sprintf("%q", args) {
+		t.Fatalf("Expected %q, got %q", args, split)
+	}
+}
+
+func Test_oci_split_command(t *testing.T) {
+	valid := map[string][]string{
+		`nginx -g 'daemon off;'`:   {"nginx", "-g", "daemon off;"},
+		`echo "it's \"here\"" \$a`: {"echo", `it's "here"`, "$a"},
+		`  a  "" b'c'd  `:          {"a", "", "bcd"},
+		`echo "a\b"`:               {"echo", `a\b`},
+	}
+
+	for command, expected := range valid {
+		args, err := ociSplitCommand(command)
+		if err != nil {
+			t.Errorf("Failed to split %q: %v", command, err)
+			continue
+		}
+
+		if fmt.Sprintf("%q", args) != fmt.Sprintf("%q", expected) {
+			t.Errorf("Expected %q for %q, got %q", expected, command, args)
+		}
+	}
+
+	for _, command := range []string{`echo 'a`, `echo "a`, `echo a\`} {
+		_, err := ociSplitCommand(command)
+		if err == nil {
+			t.Errorf("Expected an error for %q", command)
+		}
+	}
+}
+
+func Test_oci_lxc_command(t *testing.T) {
+	command, err := ociLXCCommand([]string{"/bin/sh", "-c", "echo hello", "it's", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `/bin/sh -c 'echo hello' "it's" ''`
+	if command != expected {
+		t.Fatalf("Expected %q, got %q", expected, command)
+	}
+
+	_, err = ociLXCCommand([]string{"echo", `say "it's"`})
+	if err == nil {
+		t.Fatal("Expected an error for an argument with both kinds of quotes")
+	}
+}
+
+func Test_oci_properties(t *testing.T) {
+	config := ociImageConfig{OS: "linux"}
+	config.Config.Entrypoint = []string{"/entrypoint.sh"}
+	config.Config.Cmd = []string{"nginx", "-g", "daemon off;"}
+	config.Config.Env = []string{"PATH=/usr/bin:/bin", "NGINX_VERSION=1.13"}
+	config.Config.WorkingDir = "/srv"
+	config.Config.Labels = map[string]string{"version": "1.13"}
+
+	properties := ociProperties(&config, "nginx")
+
+	expected := map[string]string{
+		"description": "nginx",
+		"os":          "linux",
+		"release":     "1.13",
+		"oci.cmd":     "/entrypoint.sh nginx -g 'daemon off;'",
+		"oci.env":     "PATH=/usr/bin:/bin\nNGINX_VERSION=1.13",
+		"oci.cwd":     "/srv",
+	}
+
+	if len(properties) != len(expected) {
+		t.Fatalf("Expected %d properties, got %d: %v", len(expected), len(properties), properties)
+	}
+
+	for k, v := range expected {
+		if properties[k] != v {
+			t.Fatalf("Expected %q for %s, got %q", v, k, properties[k])
+		}
+	}
+}
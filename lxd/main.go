@@ -35,6 +35,7 @@ var argTrustPassword = gnuflag.String("trust-password", "", "")
 var argVerbose = gnuflag.Bool("verbose", false, "")
 var argVersion = gnuflag.Bool("version", false, "")
 var argForce = gnuflag.Bool("force", false, "")
+var argDryRun = gnuflag.Bool("dry-run", false, "")
 
 // Global variables
 var debug bool
@@ -79,6 +80,8 @@ func run() error {
 		fmt.Printf("        Wait until LXD is ready to handle requests\n")
 		fmt.Printf("    import <container name> [--force]\n")
 		fmt.Printf("        Import a pre-existing container from storage\n")
+		fmt.Printf("    recover [--dry-run] [--force]\n")
+		fmt.Printf("        Scan the storage pools and import all the containers missing from the database\n")
 
 		fmt.Printf("\n\nCommon options:\n")
 		fmt.Printf("    --debug\n")
@@ -218,6 +221,8 @@ func run() error {
 			return cmdWaitReady()
 		case "import":
 			return cmdImport(os.Args[1:])
+		case "recover":
+			return cmdRecover(os.Args[1:])
 
 		// Internal commands
 		case "forkgetnet":
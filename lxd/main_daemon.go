@@ -51,6 +51,8 @@ func cmdDaemon() error {
 		}()
 	}
 
+	sdNotifyInit()
+
 	d := &Daemon{
 		group:     *argGroup,
 		SetupMode: shared.PathExists(shared.VarPath(".setup_mode"))}
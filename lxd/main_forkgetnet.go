@@ -40,6 +40,16 @@ func cmdForkGetNet() error {
 				continue
 			}
 
+			rxErrors, err := strconv.ParseInt(fields[3], 10, 64)
+			if err != nil {
+				continue
+			}
+
+			rxDrops, err := strconv.ParseInt(fields[4], 10, 64)
+			if err != nil {
+				continue
+			}
+
 			txBytes, err := strconv.ParseInt(fields[9], 10, 64)
 			if err != nil {
 				continue
@@ -50,8 +60,18 @@ func cmdForkGetNet() error {
 				continue
 			}
 
+			txErrors, err := strconv.ParseInt(fields[11], 10, 64)
+			if err != nil {
+				continue
+			}
+
+			txDrops, err := strconv.ParseInt(fields[12], 10, 64)
+			if err != nil {
+				continue
+			}
+
 			intName := strings.TrimSuffix(fields[0], ":")
-			stats[intName] = []int64{rxBytes, rxPackets, txBytes, txPackets}
+			stats[intName] = []int64{rxBytes, rxPackets, txBytes, txPackets, rxErrors, txErrors, rxDrops, txDrops}
 		}
 	}
 
@@ -130,6 +150,10 @@ func cmdForkGetNet() error {
 			network.Counters.PacketsReceived = counters[1]
 			network.Counters.BytesSent = counters[2]
 			network.Counters.PacketsSent = counters[3]
+			network.Counters.ErrorsReceived = counters[4]
+			network.Counters.ErrorsSent = counters[5]
+			network.Counters.PacketsDroppedInbound = counters[6]
+			network.Counters.PacketsDroppedOutbound = counters[7]
 		}
 
 		networks[netIf.Name] = network
@@ -5,6 +5,7 @@ import (
 	"io"
 	"net"
 	"os"
+	"path/filepath"
 	"sync"
 	"syscall"
 
@@ -28,6 +29,11 @@ func cmdNetcat(args []string) error {
 		os.Remove(logPath)
 	}
 
+	// There's no log directory when relaying for an ssh remote
+	if !shared.PathExists(filepath.Dir(logPath)) {
+		logPath = os.DevNull
+	}
+
 	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_SYNC, 0644)
 	if err != nil {
 		return err
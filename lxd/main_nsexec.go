@@ -28,6 +28,7 @@ package main
 #include <linux/sched.h>
 #include <linux/limits.h>
 #include <sys/mman.h>
+#include <sys/sendfile.h>
 #include <sys/stat.h>
 #include <sys/types.h>
 #include <fcntl.h>
@@ -87,10 +88,13 @@ int mkdir_p(const char *dir, mode_t mode)
 	return 0;
 }
 
+// Size of the chunks handed to sendfile() and of the fallback buffer.
+#define COPY_CHUNK_SIZE (1024 * 1024)
+
 int copy(int target, int source, bool append)
 {
 	ssize_t n;
-	char buf[1024];
+	char *buf;
 
 	if (!append && ftruncate(target, 0) < 0) {
 		error("error: truncate");
@@ -102,13 +106,36 @@ int copy(int target, int source, bool append)
 		return -1;
 	}
 
-	while ((n = read(source, buf, 1024)) > 0) {
+	// Let the kernel copy the data directly between the two files. Both
+	// file offsets move along, so if sendfile() isn't supported by the
+	// source (e.g. procfs), the read/write loop picks up where it stopped.
+	while ((n = sendfile(target, source, NULL, COPY_CHUNK_SIZE)) > 0)
+		;
+
+	if (n == 0)
+		return 0;
+
+	if (errno != EINVAL && errno != ENOSYS) {
+		error("error: sendfile");
+		return -1;
+	}
+
+	buf = malloc(COPY_CHUNK_SIZE);
+	if (!buf) {
+		error("error: malloc");
+		return -1;
+	}
+
+	while ((n = read(source, buf, COPY_CHUNK_SIZE)) > 0) {
 		if (write(target, buf, n) != n) {
 			error("error: write");
+			free(buf);
 			return -1;
 		}
 	}
 
+	free(buf);
+
 	if (n < 0) {
 		error("error: read");
 		return -1;
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func cmdRecover(args []string) error {
+	c, err := lxd.ConnectLXDUnix("", nil)
+	if err != nil {
+		return err
+	}
+
+	var resp *api.Response
+	if *argDryRun {
+		resp, _, err = c.RawQuery("GET", "/internal/recover", nil, "")
+	} else {
+		req := map[string]interface{}{
+			"force": *argForce,
+		}
+
+		resp, _, err = c.RawQuery("POST", "/internal/recover", req, "")
+	}
+	if err != nil {
+		return err
+	}
+
+	containers := []internalRecoverContainer{}
+	err = resp.MetadataAsStruct(&containers)
+	if err != nil {
+		return err
+	}
+
+	if len(containers) == 0 {
+		fmt.Printf("No unknown container found\n")
+		return nil
+	}
+
+	failed := false
+	for _, ct := range containers {
+		if ct.Error != "" {
+			fmt.Printf("%s (pool %s): %s: %s\n", ct.Name, ct.Pool, ct.Status, ct.Error)
+		} else {
+			fmt.Printf("%s (pool %s): %s\n", ct.Name, ct.Pool, ct.Status)
+		}
+
+		if ct.Status == "failed" {
+			failed = true
+		}
+	}
+
+	if failed {
+		return fmt.Errorf("Some containers couldn't be recovered")
+	}
+
+	return nil
+}
@@ -20,7 +20,9 @@ import (
 	"github.com/gorilla/websocket"
 	"gopkg.in/lxc/go-lxc.v2"
 
+	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -121,7 +123,7 @@ func (c *migrationFields) sendControl(err error) {
 		message = err.Error()
 	}
 
-	msg := MigrationControl{
+	msg := migration.MigrationControl{
 		Success: proto.Bool(err == nil),
 		Message: proto.String(message),
 	}
@@ -132,10 +134,10 @@ func (c *migrationFields) sendControl(err error) {
 	}
 }
 
-func (c *migrationFields) controlChannel() <-chan MigrationControl {
-	ch := make(chan MigrationControl)
+func (c *migrationFields) controlChannel() <-chan migration.MigrationControl {
+	ch := make(chan migration.MigrationControl)
 	go func() {
-		msg := MigrationControl{}
+		msg := migration.MigrationControl{}
 		err := c.recv(&msg)
 		if err != nil {
 			logger.Debugf("Got error reading migration control socket %s", err)
@@ -152,10 +154,13 @@ type migrationSourceWs struct {
 	migrationFields
 
 	allConnected chan bool
+
+	// rsync tunables requested by the client, if any.
+	rsync *api.MigrationRsyncOptions
 }
 
-func NewMigrationSource(c container, stateful bool, containerOnly bool) (*migrationSourceWs, error) {
-	ret := migrationSourceWs{migrationFields{container: c}, make(chan bool, 1)}
+func NewMigrationSource(c container, stateful bool, containerOnly bool, rsync *api.MigrationRsyncOptions) (*migrationSourceWs, error) {
+	ret := migrationSourceWs{migrationFields{container: c}, make(chan bool, 1), rsync}
 	ret.containerOnly = containerOnly
 
 	var err error
@@ -256,24 +261,24 @@ fi
 	return err
 }
 
-func snapshotToProtobuf(c container) *Snapshot {
-	config := []*Config{}
+func snapshotToProtobuf(c container) *migration.Snapshot {
+	config := []*migration.Config{}
 	for k, v := range c.LocalConfig() {
 		kCopy := string(k)
 		vCopy := string(v)
-		config = append(config, &Config{Key: &kCopy, Value: &vCopy})
+		config = append(config, &migration.Config{Key: &kCopy, Value: &vCopy})
 	}
 
-	devices := []*Device{}
+	devices := []*migration.Device{}
 	for name, d := range c.LocalDevices() {
-		props := []*Config{}
+		props := []*migration.Config{}
 		for k, v := range d {
 			kCopy := string(k)
 			vCopy := string(v)
-			props = append(props, &Config{Key: &kCopy, Value: &vCopy})
+			props = append(props, &migration.Config{Key: &kCopy, Value: &vCopy})
 		}
 
-		devices = append(devices, &Device{Name: &name, Config: props})
+		devices = append(devices, &migration.Device{Name: &name, Config: props})
 	}
 
 	parts := strings.SplitN(c.Name(), shared.SnapshotDelimiter, 2)
@@ -281,7 +286,7 @@ func snapshotToProtobuf(c container) *Snapshot {
 	arch := int32(c.Architecture())
 	stateful := c.IsStateful()
 
-	return &Snapshot{
+	return &migration.Snapshot{
 		Name:         &parts[len(parts)-1],
 		LocalConfig:  config,
 		Profiles:     c.Profiles(),
@@ -295,7 +300,7 @@ func snapshotToProtobuf(c container) *Snapshot {
 func (s *migrationSourceWs) Do(migrateOp *operation) error {
 	<-s.allConnected
 
-	criuType := CRIUType_CRIU_RSYNC.Enum()
+	criuType := migration.CRIUType_CRIU_RSYNC.Enum()
 	if !s.live {
 		criuType = nil
 	}
@@ -310,16 +315,17 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		defer s.container.StorageStop()
 	}
 
-	idmaps := make([]*IDMapType, 0)
+	idmaps := make([]*migration.IDMapType, 0)
 
-	idmapset, err := s.container.IdmapSet()
+	// Send the map the filesystem is actually shifted to
+	idmapset, err := s.container.LastIdmapSet()
 	if err != nil {
 		return err
 	}
 
 	if idmapset != nil {
 		for _, ctnIdmap := range idmapset.Idmap {
-			idmap := IDMapType{
+			idmap := migration.IDMapType{
 				Isuid:    proto.Bool(ctnIdmap.Isuid),
 				Isgid:    proto.Bool(ctnIdmap.Isgid),
 				Hostid:   proto.Int32(int32(ctnIdmap.Hostid)),
@@ -333,7 +339,7 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 
 	driver, fsErr := s.container.Storage().MigrationSource(s.container, s.containerOnly)
 
-	snapshots := []*Snapshot{}
+	snapshots := []*migration.Snapshot{}
 	snapshotNames := []string{}
 	// Only send snapshots when requested.
 	if !s.containerOnly {
@@ -349,7 +355,7 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 	// The protocol says we have to send a header no matter what, so let's
 	// do that, but then immediately send an error.
 	myType := s.container.Storage().MigrationType()
-	header := MigrationHeader{
+	header := migration.MigrationHeader{
 		Fs:            &myType,
 		Criu:          criuType,
 		Idmap:         idmaps,
@@ -357,6 +363,20 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		Snapshots:     snapshots,
 	}
 
+	// Offer to compress the optimized transfer streams.
+	offeredCompression := daemonConfig["migration.compression_algorithm"].Get()
+	if offeredCompression != "none" && migrationCompressionSupported(offeredCompression) {
+		header.Compression = &offeredCompression
+	}
+
+	// Offer the requested rsync features, the sink echoes those it accepts.
+	if s.rsync != nil && (s.rsync.Compression || s.rsync.WholeFile) {
+		header.RsyncFeatures = &migration.RsyncFeatures{
+			Compress:  proto.Bool(s.rsync.Compression),
+			WholeFile: proto.Bool(s.rsync.WholeFile),
+		}
+	}
+
 	err = s.send(&header)
 	if err != nil {
 		s.sendControl(err)
@@ -374,9 +394,16 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		return err
 	}
 
+	// The sink only echoes the offered compression when it supports it.
+	compression := ""
+	if header.GetCompression() == offeredCompression {
+		compression = offeredCompression
+	}
+
 	bwlimit := ""
 	if *header.Fs != myType {
-		myType = MigrationFSType_RSYNC
+		compression = ""
+		myType = migration.MigrationFSType_RSYNC
 		header.Fs = &myType
 
 		driver, _ = rsyncMigrationSource(s.container, s.containerOnly)
@@ -388,6 +415,13 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		}
 	}
 
+	// A limit given with the request takes precedence over the pool's.
+	if s.rsync != nil && s.rsync.Bwlimit != "" {
+		bwlimit = s.rsync.Bwlimit
+	}
+
+	rsyncArgs := rsyncFeatureArgs(header.GetRsyncFeatures())
+
 	// All failure paths need to do a few things to correctly handle errors before returning.
 	// Unfortunately, handling errors is not well-suited to defer as the code depends on the
 	// status of driver and the error value.  The error value is especially tricky due to the
@@ -401,7 +435,7 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		return err
 	}
 
-	err = driver.SendWhileRunning(s.fsConn, migrateOp, bwlimit, s.containerOnly)
+	err = driver.SendWhileRunning(s.fsConn, migrateOp, bwlimit, rsyncArgs, compression, s.containerOnly)
 	if err != nil {
 		return abort(err)
 	}
@@ -411,7 +445,7 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 	if s.live {
 		if header.Criu == nil {
 			return abort(fmt.Errorf("Got no CRIU socket type for live migration"))
-		} else if *header.Criu != CRIUType_CRIU_RSYNC {
+		} else if *header.Criu != migration.CRIUType_CRIU_RSYNC {
 			return abort(fmt.Errorf("Formats other than criu rsync not understood"))
 		}
 
@@ -523,12 +557,12 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		 * p.haul's protocol, it will make sense to do these in parallel.
 		 */
 		ctName, _, _ := containerGetParentAndSnapshotName(s.container.Name())
-		err = RsyncSend(ctName, shared.AddSlash(checkpointDir), s.criuConn, nil, bwlimit)
+		err = RsyncSend(ctName, shared.AddSlash(checkpointDir), s.criuConn, nil, bwlimit, rsyncArgs)
 		if err != nil {
 			return abort(err)
 		}
 
-		err = driver.SendAfterCheckpoint(s.fsConn, bwlimit)
+		err = driver.SendAfterCheckpoint(s.fsConn, bwlimit, rsyncArgs, compression)
 		if err != nil {
 			return abort(err)
 		}
@@ -536,7 +570,7 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 
 	driver.Cleanup()
 
-	msg := MigrationControl{}
+	msg := migration.MigrationControl{}
 	err = s.recv(&msg)
 	if err != nil {
 		s.disconnect()
@@ -753,7 +787,7 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		controller = c.dest.sendControl
 	}
 
-	header := MigrationHeader{}
+	header := migration.MigrationHeader{}
 	if err := receiver(&header); err != nil {
 		controller(err)
 		return err
@@ -764,14 +798,14 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		live = c.dest.live
 	}
 
-	criuType := CRIUType_CRIU_RSYNC.Enum()
+	criuType := migration.CRIUType_CRIU_RSYNC.Enum()
 	if !live {
 		criuType = nil
 	}
 
 	mySink := c.src.container.Storage().MigrationSink
 	myType := c.src.container.Storage().MigrationType()
-	resp := MigrationHeader{
+	resp := migration.MigrationHeader{
 		Fs:   &myType,
 		Criu: criuType,
 	}
@@ -780,10 +814,17 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 	// we have to use rsync.
 	if *header.Fs != *resp.Fs {
 		mySink = rsyncMigrationSink
-		myType = MigrationFSType_RSYNC
+		myType = migration.MigrationFSType_RSYNC
 		resp.Fs = &myType
+	} else if myType != migration.MigrationFSType_RSYNC && migrationCompressionSupported(header.GetCompression()) {
+		// Accept compressing the optimized streams if we can decompress them.
+		resp.Compression = header.Compression
 	}
 
+	// Any rsync we run has to use the same features as the source's.
+	resp.RsyncFeatures = header.RsyncFeatures
+	rsyncArgs := rsyncFeatureArgs(resp.RsyncFeatures)
+
 	err = sender(&resp)
 	if err != nil {
 		controller(err)
@@ -813,7 +854,7 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		 */
 		fsTransfer := make(chan error)
 		go func() {
-			snapshots := []*Snapshot{}
+			snapshots := []*migration.Snapshot{}
 
 			/* Legacy: we only sent the snapshot names, so we just
 			 * copy the container's config over, same as we used to
@@ -836,7 +877,7 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 				fsConn = c.src.fsConn
 			}
 
-			err = mySink(live, c.src.container, snapshots, fsConn, srcIdmap, migrateOp, c.src.containerOnly)
+			err = mySink(live, c.src.container, snapshots, fsConn, srcIdmap, migrateOp, c.src.containerOnly, resp.GetCompression(), rsyncArgs)
 			if err != nil {
 				fsTransfer <- err
 				return
@@ -868,7 +909,7 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 				criuConn = c.src.criuConn
 			}
 
-			err = RsyncRecv(shared.AddSlash(imagesDir), criuConn, nil)
+			err = RsyncRecv(shared.AddSlash(imagesDir), criuConn, nil, rsyncArgs)
 			if err != nil {
 				restore <- err
 				return
@@ -893,7 +934,7 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		restore <- nil
 	}(c)
 
-	var source <-chan MigrationControl
+	var source <-chan migration.MigrationControl
 	if c.push {
 		source = c.dest.controlChannel()
 	} else {
@@ -1,22 +1,23 @@
 // Code generated by protoc-gen-go.
-// source: lxd/migrate.proto
+// source: lxd/migration/migrate.proto
 // DO NOT EDIT!
 
 /*
-Package main is a generated protocol buffer package.
+Package migration is a generated protocol buffer package.
 
 It is generated from these files:
-	lxd/migrate.proto
+	lxd/migration/migrate.proto
 
 It has these top-level messages:
 	IDMapType
 	Config
 	Device
 	Snapshot
+	RsyncFeatures
 	MigrationHeader
 	MigrationControl
 */
-package main
+package migration
 
 import proto "github.com/golang/protobuf/proto"
 import math "math"
@@ -254,12 +255,38 @@ func (m *Snapshot) GetStateful() bool {
 	return false
 }
 
+type RsyncFeatures struct {
+	Compress         *bool  `protobuf:"varint,1,opt,name=compress" json:"compress,omitempty"`
+	WholeFile        *bool  `protobuf:"varint,2,opt,name=wholeFile" json:"wholeFile,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *RsyncFeatures) Reset()         { *m = RsyncFeatures{} }
+func (m *RsyncFeatures) String() string { return proto.CompactTextString(m) }
+func (*RsyncFeatures) ProtoMessage()    {}
+
+func (m *RsyncFeatures) GetCompress() bool {
+	if m != nil && m.Compress != nil {
+		return *m.Compress
+	}
+	return false
+}
+
+func (m *RsyncFeatures) GetWholeFile() bool {
+	if m != nil && m.WholeFile != nil {
+		return *m.WholeFile
+	}
+	return false
+}
+
 type MigrationHeader struct {
 	Fs               *MigrationFSType `protobuf:"varint,1,req,name=fs,enum=main.MigrationFSType" json:"fs,omitempty"`
 	Criu             *CRIUType        `protobuf:"varint,2,opt,name=criu,enum=main.CRIUType" json:"criu,omitempty"`
 	Idmap            []*IDMapType     `protobuf:"bytes,3,rep,name=idmap" json:"idmap,omitempty"`
 	SnapshotNames    []string         `protobuf:"bytes,4,rep,name=snapshotNames" json:"snapshotNames,omitempty"`
 	Snapshots        []*Snapshot      `protobuf:"bytes,5,rep,name=snapshots" json:"snapshots,omitempty"`
+	Compression      *string          `protobuf:"bytes,6,opt,name=compression" json:"compression,omitempty"`
+	RsyncFeatures    *RsyncFeatures   `protobuf:"bytes,7,opt,name=rsyncFeatures" json:"rsyncFeatures,omitempty"`
 	XXX_unrecognized []byte           `json:"-"`
 }
 
@@ -302,6 +329,20 @@ func (m *MigrationHeader) GetSnapshots() []*Snapshot {
 	return nil
 }
 
+func (m *MigrationHeader) GetCompression() string {
+	if m != nil && m.Compression != nil {
+		return *m.Compression
+	}
+	return ""
+}
+
+func (m *MigrationHeader) GetRsyncFeatures() *RsyncFeatures {
+	if m != nil {
+		return m.RsyncFeatures
+	}
+	return nil
+}
+
 type MigrationControl struct {
 	Success *bool `protobuf:"varint,1,req,name=success" json:"success,omitempty"`
 	// optional failure message if sending a failure
@@ -328,6 +369,6 @@ func (m *MigrationControl) GetMessage() string {
 }
 
 func init() {
-	proto.RegisterEnum("main.MigrationFSType", MigrationFSType_name, MigrationFSType_value)
-	proto.RegisterEnum("main.CRIUType", CRIUType_name, CRIUType_value)
+	proto.RegisterEnum("migration.MigrationFSType", MigrationFSType_name, MigrationFSType_value)
+	proto.RegisterEnum("migration.CRIUType", CRIUType_name, CRIUType_value)
 }
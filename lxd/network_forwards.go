@@ -0,0 +1,447 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+/* Network forwards DNAT traffic reaching one of the host's addresses to
+ * addresses on a managed network, either for given ports or, with the
+ * "target_address" key, for everything not matched by a port.
+ *
+ * The rules live in the nat table and their comment is the network's one
+ * followed by "forward <listen address>", so they go away with the rest of
+ * the network's rules when it's stopped.
+ */
+
+var networkForwardConfigKeys = map[string]func(value string) error{
+	"target_address": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		if net.ParseIP(value) == nil {
+			return fmt.Errorf("Invalid IP address: %s", value)
+		}
+
+		return nil
+	},
+}
+
+// networkForwardMaxPorts is how many ports a single multiport match takes, a
+// range counting as two.
+const networkForwardMaxPorts = 15
+
+// networkForwardPorts parses a comma separated list of ports and port
+// ranges ("80,443,8000-8010"), returning how many ports it covers.
+func networkForwardPorts(value string) (int, error) {
+	count := 0
+	for _, entry := range strings.Split(value, ",") {
+		fields := strings.SplitN(entry, "-", 2)
+
+		start, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil || start == 0 {
+			return -1, fmt.Errorf("Invalid port: %s", entry)
+		}
+
+		end := start
+		if len(fields) == 2 {
+			end, err = strconv.ParseUint(fields[1], 10, 16)
+			if err != nil || end < start {
+				return -1, fmt.Errorf("Invalid port range: %s", entry)
+			}
+		}
+
+		count += int(end-start) + 1
+	}
+
+	return count, nil
+}
+
+// networkForwardTargetValid checks that a target address belongs to one of
+// the network's subnets.
+func networkForwardTargetValid(config map[string]string, address net.IP) error {
+	key := "ipv4.address"
+	if address.To4() == nil {
+		key = "ipv6.address"
+	}
+
+	_, subnet, err := net.ParseCIDR(config[key])
+	if err != nil {
+		return fmt.Errorf("The network has no %s subnet to forward to", key)
+	}
+
+	if !subnet.Contains(address) {
+		return fmt.Errorf("Target address %s isn't part of the network's subnet %s", address, subnet)
+	}
+
+	return nil
+}
+
+func networkForwardValidate(config map[string]string, listenAddress string, forward api.NetworkForwardPut) error {
+	listenIP := net.ParseIP(listenAddress)
+	if listenIP == nil {
+		return fmt.Errorf("Invalid listen address: %s", listenAddress)
+	}
+
+	sameFamily := func(address string) error {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return fmt.Errorf("Invalid target address: %s", address)
+		}
+
+		if (ip.To4() == nil) != (listenIP.To4() == nil) {
+			return fmt.Errorf("Target address %s and listen address %s aren't of the same family", address, listenAddress)
+		}
+
+		return networkForwardTargetValid(config, ip)
+	}
+
+	for k, v := range forward.Config {
+		validator, ok := networkForwardConfigKeys[k]
+		if !ok {
+			return fmt.Errorf("Invalid network forward configuration key: %s", k)
+		}
+
+		err := validator(v)
+		if err != nil {
+			return fmt.Errorf("Invalid value for network forward configuration key %s: %v", k, err)
+		}
+	}
+
+	if forward.Config["target_address"] != "" {
+		err := sameFamily(forward.Config["target_address"])
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, port := range forward.Ports {
+		if !shared.StringInSlice(port.Protocol, []string{"tcp", "udp"}) {
+			return fmt.Errorf("Invalid protocol: %s", port.Protocol)
+		}
+
+		_, err := networkForwardPorts(port.ListenPort)
+		if err != nil {
+			return err
+		}
+
+		slots := 0
+		for _, entry := range strings.Split(port.ListenPort, ",") {
+			slots++
+			if strings.Contains(entry, "-") {
+				slots++
+			}
+		}
+
+		if slots > networkForwardMaxPorts {
+			return fmt.Errorf("Too many listen ports: %s (at most %d, a range counting as two)", port.ListenPort, networkForwardMaxPorts)
+		}
+
+		if port.TargetPort != "" {
+			count, err := networkForwardPorts(port.TargetPort)
+			if err != nil {
+				return err
+			}
+
+			if count != 1 {
+				return fmt.Errorf("The target port must be a single port")
+			}
+		}
+
+		err = sameFamily(port.TargetAddress)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func networkForwardComment(netName string, listenAddress string) string {
+	return fmt.Sprintf("%s forward %s", netName, listenAddress)
+}
+
+func networkForwardProtocol(listenAddress string) string {
+	if net.ParseIP(listenAddress).To4() == nil {
+		return "ipv6"
+	}
+
+	return "ipv4"
+}
+
+// forwardSetup (re)creates the firewall rules of a forward.
+func (n *network) forwardSetup(forward *api.NetworkForward) error {
+	protocol := networkForwardProtocol(forward.ListenAddress)
+	comment := networkForwardComment(n.name, forward.ListenAddress)
+
+	err := networkIptablesClear(protocol, comment, "nat")
+	if err != nil {
+		return err
+	}
+
+	// Rules are prepended, so the catch-all goes in first to end up last
+	target := forward.Config["target_address"]
+	if target != "" {
+		for _, chain := range []string{"PREROUTING", "OUTPUT"} {
+			err = networkIptablesPrepend(protocol, comment, "nat", chain, "-d", forward.ListenAddress, "-j", "DNAT", "--to-destination", target)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, port := range forward.Ports {
+		destination := port.TargetAddress
+		if port.TargetPort != "" {
+			destination = net.JoinHostPort(port.TargetAddress, port.TargetPort)
+		} else if protocol == "ipv6" {
+			destination = fmt.Sprintf("[%s]", port.TargetAddress)
+		}
+
+		ports := strings.Replace(port.ListenPort, "-", ":", -1)
+		for _, chain := range []string{"PREROUTING", "OUTPUT"} {
+			err = networkIptablesPrepend(protocol, comment, "nat", chain, "-d", forward.ListenAddress, "-p", port.Protocol, "-m", "multiport", "--dports", ports, "-j", "DNAT", "--to-destination", destination)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// forwardsSetup creates the firewall rules of all the network's forwards.
+func (n *network) forwardsSetup() error {
+	addresses, err := dbNetworkForwards(n.daemon.db, n.id)
+	if err != nil {
+		return err
+	}
+
+	for _, address := range addresses {
+		_, forward, err := dbNetworkForwardGet(n.daemon.db, n.id, address)
+		if err != nil {
+			return err
+		}
+
+		err = n.forwardSetup(forward)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// API endpoints
+func networkForwardsGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	recursion, err := strconv.Atoi(r.FormValue("recursion"))
+	if err != nil {
+		recursion = 0
+	}
+
+	n, err := networkLoadByName(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	addresses, err := dbNetworkForwards(d.db, n.id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	resultString := []string{}
+	resultMap := []api.NetworkForward{}
+	for _, address := range addresses {
+		if recursion == 0 {
+			resultString = append(resultString, fmt.Sprintf("/%s/networks/%s/forwards/%s", version.APIVersion, name, address))
+			continue
+		}
+
+		_, forward, err := dbNetworkForwardGet(d.db, n.id, address)
+		if err != nil {
+			continue
+		}
+
+		resultMap = append(resultMap, *forward)
+	}
+
+	if recursion == 0 {
+		return SyncResponse(true, resultString)
+	}
+
+	return SyncResponse(true, resultMap)
+}
+
+func networkForwardsPost(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	req := api.NetworkForwardsPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	n, err := networkLoadByName(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	err = networkForwardValidate(n.config, req.ListenAddress, req.NetworkForwardPut)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Store the address in its canonical form
+	listenAddress := net.ParseIP(req.ListenAddress).String()
+
+	_, _, err = dbNetworkForwardGet(d.db, n.id, listenAddress)
+	if err == nil {
+		return BadRequest(fmt.Errorf("A forward for %s already exists", listenAddress))
+	}
+
+	id, err := dbNetworkForwardCreate(d.db, n.id, listenAddress, req.NetworkForwardPut)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if n.IsRunning() {
+		_, forward, err := dbNetworkForwardGet(d.db, n.id, listenAddress)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		err = n.forwardSetup(forward)
+		if err != nil {
+			networkIptablesClear(networkForwardProtocol(listenAddress), networkForwardComment(n.name, listenAddress), "nat")
+			dbNetworkForwardDelete(d.db, id)
+			return SmartError(err)
+		}
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/networks/%s/forwards/%s", version.APIVersion, name, listenAddress))
+}
+
+var networkForwardsCmd = Command{name: "networks/{name}/forwards", get: networkForwardsGet, post: networkForwardsPost}
+
+func networkForwardGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	address := mux.Vars(r)["address"]
+
+	n, err := networkLoadByName(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	_, forward, err := dbNetworkForwardGet(d.db, n.id, address)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	etag := []interface{}{forward.ListenAddress, forward.Description, forward.Config, forward.Ports}
+
+	return SyncResponseETag(true, forward, etag)
+}
+
+func networkForwardPut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	address := mux.Vars(r)["address"]
+
+	n, err := networkLoadByName(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	id, forward, err := dbNetworkForwardGet(d.db, n.id, address)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate the ETag
+	etag := []interface{}{forward.ListenAddress, forward.Description, forward.Config, forward.Ports}
+
+	err = etagCheck(r, etag)
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	req := api.NetworkForwardPut{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	err = networkForwardValidate(n.config, forward.ListenAddress, req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = dbNetworkForwardUpdate(d.db, id, req)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if n.IsRunning() {
+		oldForward := forward.NetworkForwardPut
+		forward.NetworkForwardPut = req
+		err = n.forwardSetup(forward)
+		if err != nil {
+			// Put the previous forward back
+			dbNetworkForwardUpdate(d.db, id, oldForward)
+			forward.NetworkForwardPut = oldForward
+			n.forwardSetup(forward)
+			return SmartError(err)
+		}
+	}
+
+	return EmptySyncResponse
+}
+
+func networkForwardDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	address := mux.Vars(r)["address"]
+
+	n, err := networkLoadByName(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	id, forward, err := dbNetworkForwardGet(d.db, n.id, address)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = networkIptablesClear(networkForwardProtocol(forward.ListenAddress), networkForwardComment(n.name, forward.ListenAddress), "nat")
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = dbNetworkForwardDelete(d.db, id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+var networkForwardCmd = Command{name: "networks/{name}/forwards/{address}", get: networkForwardGet, put: networkForwardPut, delete: networkForwardDelete}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestNetworkForwardPorts(t *testing.T) {
+	tests := map[string]int{
+		"80":             1,
+		"80,443":         2,
+		"8000-8010":      11,
+		"22,8000-8001":   3,
+		"22, 8000-8001":  -1,
+		"0":              -1,
+		"65536":          -1,
+		"90-80":          -1,
+		"http":           -1,
+		"80,":            -1,
+		"8000-8010-8020": -1,
+	}
+
+	for value, expected := range tests {
+		count, err := networkForwardPorts(value)
+		if expected == -1 {
+			if err == nil {
+				t.Errorf("Expected %q to be rejected", value)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", value, err)
+			continue
+		}
+
+		if count != expected {
+			t.Errorf("Expected %q to cover %d ports, got %d", value, expected, count)
+		}
+	}
+}
+
+func TestNetworkForwardValidate(t *testing.T) {
+	config := map[string]string{
+		"ipv4.address": "10.0.3.1/24",
+		"ipv6.address": "fd42::1/64",
+	}
+
+	port := func(protocol string, listen string, target string, targetPort string) api.NetworkForwardPut {
+		return api.NetworkForwardPut{
+			Ports: []api.NetworkForwardPort{{
+				Protocol:      protocol,
+				ListenPort:    listen,
+				TargetAddress: target,
+				TargetPort:    targetPort,
+			}},
+		}
+	}
+
+	tests := []struct {
+		listen  string
+		forward api.NetworkForwardPut
+		valid   bool
+	}{
+		{"192.0.2.10", port("tcp", "80,443", "10.0.3.10", ""), true},
+		{"192.0.2.10", port("udp", "2222", "10.0.3.10", "22"), true},
+		{"2001:db8::10", port("tcp", "80", "fd42::10", ""), true},
+		{"192.0.2.10", api.NetworkForwardPut{Config: map[string]string{"target_address": "10.0.3.10"}}, true},
+		{"192.0.2.10", api.NetworkForwardPut{}, true},
+		{"nope", port("tcp", "80", "10.0.3.10", ""), false},
+		{"192.0.2.10", port("sctp", "80", "10.0.3.10", ""), false},
+		{"192.0.2.10", port("tcp", "80", "10.0.4.10", ""), false},
+		{"192.0.2.10", port("tcp", "80", "fd42::10", ""), false},
+		{"192.0.2.10", port("tcp", "80-90", "10.0.3.10", "80-90"), false},
+		{"192.0.2.10", port("tcp", "1,2,3,4,5,6,7,8,9,10,11,12,13,14,15", "10.0.3.10", ""), true},
+		{"192.0.2.10", port("tcp", "1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16", "10.0.3.10", ""), false},
+		{"192.0.2.10", port("tcp", "1-2,3-4,5-6,7-8,9-10,11,12", "10.0.3.10", ""), true},
+		{"192.0.2.10", port("tcp", "1-2,3-4,5-6,7-8,9-10,11-12", "10.0.3.10", ""), false},
+		{"192.0.2.10", api.NetworkForwardPut{Config: map[string]string{"foo": "bar"}}, false},
+		{"192.0.2.10", api.NetworkForwardPut{Config: map[string]string{"target_address": "fd42::10"}}, false},
+	}
+
+	for i, test := range tests {
+		err := networkForwardValidate(config, test.listen, test.forward)
+		if test.valid && err != nil {
+			t.Errorf("Test %d: unexpected error: %v", i, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("Test %d: expected an error", i)
+		}
+	}
+}
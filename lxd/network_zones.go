@@ -0,0 +1,794 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/miekg/dns"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+/* Network zones are DNS zones LXD is authoritative for, served on
+ * core.dns_address. Networks point to them with their dns.zone.forward,
+ * dns.zone.reverse.ipv4 and dns.zone.reverse.ipv6 keys, the zones then
+ * holding the addresses of the containers on those networks, as found in
+ * their NIC configuration and the DHCP leases.
+ *
+ * Only the peers listed in a zone's configuration may query or transfer
+ * (AXFR) it, the intent being for secondary DNS servers to serve it to the
+ * rest of the infrastructure.
+ */
+
+// networkZoneTTL is the TTL of the records of all zones
+const networkZoneTTL = 300
+
+var networkZoneConfigKeys = map[string]func(value string) error{
+	"dns.nameservers": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		for _, entry := range strings.Split(value, ",") {
+			err := networkZoneValidName(strings.TrimSpace(entry))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+
+	"peers.NAME.address": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		if net.ParseIP(value) == nil {
+			return fmt.Errorf("Invalid IP address: %s", value)
+		}
+
+		return nil
+	},
+	"peers.NAME.key": func(value string) error {
+		_, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return fmt.Errorf("The TSIG key must be base64 encoded")
+		}
+
+		return nil
+	},
+}
+
+// networkZoneValidName checks that a zone (or host) name is a valid DNS
+// name, without the trailing dot.
+func networkZoneValidName(name string) error {
+	if name == "" {
+		return fmt.Errorf("No name provided")
+	}
+
+	if len(name) > 253 {
+		return fmt.Errorf("DNS name too long: %s", name)
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("Invalid DNS name: %s", name)
+		}
+
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return fmt.Errorf("Invalid DNS name, only lowercase letters, digits and dashes are allowed: %s", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func networkZoneValidateConfig(config map[string]string) error {
+	for k, v := range config {
+		key := k
+
+		// User keys are free for all
+		if strings.HasPrefix(key, "user.") {
+			continue
+		}
+
+		// Peer keys have the peer name in their name, so extract the real key
+		if strings.HasPrefix(key, "peers.") {
+			fields := strings.Split(key, ".")
+			if len(fields) != 3 || fields[1] == "" {
+				return fmt.Errorf("Invalid network zone configuration key: %s", k)
+			}
+
+			key = fmt.Sprintf("peers.NAME.%s", fields[2])
+		}
+
+		validator, ok := networkZoneConfigKeys[key]
+		if !ok {
+			return fmt.Errorf("Invalid network zone configuration key: %s", k)
+		}
+
+		err := validator(v)
+		if err != nil {
+			return fmt.Errorf("Invalid value for network zone configuration key %s: %v", k, err)
+		}
+	}
+
+	for peer, p := range networkZonePeers(config) {
+		if p.address == nil {
+			return fmt.Errorf("Peer %s has no address", peer)
+		}
+	}
+
+	return nil
+}
+
+// networkZoneValidateNetworkConfig checks the zones a network configuration
+// refers to.
+func networkZoneValidateNetworkConfig(d *Daemon, config map[string]string) error {
+	suffixes := map[string]string{
+		"dns.zone.forward":      "",
+		"dns.zone.reverse.ipv4": "in-addr.arpa.",
+		"dns.zone.reverse.ipv6": "ip6.arpa.",
+	}
+
+	for key, suffix := range suffixes {
+		name := config[key]
+		if name == "" {
+			continue
+		}
+
+		_, _, err := dbNetworkZoneGet(d.db, name)
+		if err != nil {
+			return fmt.Errorf("Network zone %s doesn't exist", name)
+		}
+
+		if suffix != "" && !dns.IsSubDomain(suffix, dns.Fqdn(name)) {
+			return fmt.Errorf("%s must be a zone under %s", key, strings.TrimSuffix(suffix, "."))
+		}
+	}
+
+	return nil
+}
+
+type networkZonePeer struct {
+	address net.IP
+	key     string
+}
+
+func networkZonePeers(config map[string]string) map[string]networkZonePeer {
+	peers := map[string]networkZonePeer{}
+	for k, v := range config {
+		fields := strings.Split(k, ".")
+		if len(fields) != 3 || fields[0] != "peers" {
+			continue
+		}
+
+		peer := peers[fields[1]]
+		switch fields[2] {
+		case "address":
+			peer.address = net.ParseIP(v)
+		case "key":
+			peer.key = v
+		}
+		peers[fields[1]] = peer
+	}
+
+	return peers
+}
+
+// networkZoneKeyName returns the name of the TSIG key a peer signs its
+// requests with.
+func networkZoneKeyName(zoneName string, peer string) string {
+	return dns.Fqdn(fmt.Sprintf("%s_%s", zoneName, peer))
+}
+
+// networkZoneAllowed returns whether a request from the given address,
+// signed with the given verified TSIG key (if any), may access the zone.
+func networkZoneAllowed(zoneName string, config map[string]string, address net.IP, keyName string) bool {
+	for name, peer := range networkZonePeers(config) {
+		if !peer.address.Equal(address) {
+			continue
+		}
+
+		if peer.key == "" || strings.EqualFold(keyName, networkZoneKeyName(zoneName, name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// networkZoneSecrets returns the TSIG keys of all the zone peers.
+func networkZoneSecrets(d *Daemon) (map[string]string, error) {
+	zones, err := dbNetworkZones(d.db)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := map[string]string{}
+	for _, name := range zones {
+		_, zone, err := dbNetworkZoneGet(d.db, name)
+		if err != nil {
+			return nil, err
+		}
+
+		for peer, p := range networkZonePeers(zone.Config) {
+			if p.key != "" {
+				secrets[networkZoneKeyName(name, peer)] = p.key
+			}
+		}
+	}
+
+	return secrets, nil
+}
+
+// networkZoneEntry is an address to publish under a host name
+type networkZoneEntry struct {
+	name    string
+	address net.IP
+}
+
+type networkZoneEntries []networkZoneEntry
+
+func (a networkZoneEntries) Len() int      { return len(a) }
+func (a networkZoneEntries) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a networkZoneEntries) Less(i, j int) bool {
+	if a[i].name != a[j].name {
+		return a[i].name < a[j].name
+	}
+
+	return a[i].address.String() < a[j].address.String()
+}
+
+// networkZoneRecords returns the records of a zone, its SOA (with a zero
+// serial) coming first. Each entry gets an A or AAAA record if its name
+// belongs to the zone and a PTR record if its reverse name does.
+func networkZoneRecords(zoneName string, config map[string]string, entries []networkZoneEntry) []dns.RR {
+	origin := dns.Fqdn(zoneName)
+	header := func(name string, rrtype uint16) dns.RR_Header {
+		return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: networkZoneTTL}
+	}
+
+	nameservers := []string{}
+	if config["dns.nameservers"] != "" {
+		for _, entry := range strings.Split(config["dns.nameservers"], ",") {
+			nameservers = append(nameservers, dns.Fqdn(strings.TrimSpace(entry)))
+		}
+	}
+
+	primary := origin
+	if len(nameservers) > 0 {
+		primary = nameservers[0]
+	}
+
+	records := []dns.RR{&dns.SOA{
+		Hdr:     header(origin, dns.TypeSOA),
+		Ns:      primary,
+		Mbox:    "hostmaster." + origin,
+		Refresh: 120,
+		Retry:   60,
+		Expire:  86400,
+		Minttl:  30,
+	}}
+
+	for _, ns := range nameservers {
+		records = append(records, &dns.NS{Hdr: header(origin, dns.TypeNS), Ns: ns})
+	}
+
+	for _, entry := range entries {
+		name := dns.Fqdn(entry.name)
+		if name != origin && dns.IsSubDomain(origin, name) {
+			if entry.address.To4() != nil {
+				records = append(records, &dns.A{Hdr: header(name, dns.TypeA), A: entry.address})
+			} else {
+				records = append(records, &dns.AAAA{Hdr: header(name, dns.TypeAAAA), AAAA: entry.address})
+			}
+		}
+
+		reverse, err := dns.ReverseAddr(entry.address.String())
+		if err == nil && dns.IsSubDomain(origin, reverse) {
+			records = append(records, &dns.PTR{Hdr: header(reverse, dns.TypePTR), Ptr: name})
+		}
+	}
+
+	return records
+}
+
+type networkZoneSerialState struct {
+	hash   string
+	serial uint32
+}
+
+var networkZoneSerials = map[string]networkZoneSerialState{}
+var networkZoneSerialsLock sync.Mutex
+
+// networkZoneSerial returns the serial of a zone, bumped to the current time
+// (or past the previous serial) whenever its content changes.
+func networkZoneSerial(zoneName string, records []dns.RR, now time.Time) uint32 {
+	content := sha256.New()
+	for _, rr := range records {
+		content.Write([]byte(rr.String() + "\n"))
+	}
+	hash := hex.EncodeToString(content.Sum(nil))
+
+	networkZoneSerialsLock.Lock()
+	defer networkZoneSerialsLock.Unlock()
+
+	state, ok := networkZoneSerials[zoneName]
+	if ok && state.hash == hash {
+		return state.serial
+	}
+
+	serial := uint32(now.Unix())
+	if ok && serial <= state.serial {
+		serial = state.serial + 1
+	}
+
+	networkZoneSerials[zoneName] = networkZoneSerialState{hash: hash, serial: serial}
+
+	return serial
+}
+
+// networkZoneLease is a dnsmasq lease, IPv6 ones having no MAC address
+type networkZoneLease struct {
+	hwaddr   string
+	address  net.IP
+	hostname string
+}
+
+func networkZoneParseLeases(content string) []networkZoneLease {
+	leases := []networkZoneLease{}
+	for _, line := range strings.Split(content, "\n") {
+		// Skips the server DUID line too
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		address := net.ParseIP(fields[2])
+		if address == nil {
+			continue
+		}
+
+		lease := networkZoneLease{address: address}
+		if address.To4() != nil {
+			lease.hwaddr = strings.ToLower(fields[1])
+		}
+
+		if fields[3] != "*" {
+			lease.hostname = fields[3]
+		}
+
+		leases = append(leases, lease)
+	}
+
+	return leases
+}
+
+// networkZoneNICAddresses returns the addresses of a NIC, its static ones
+// taking precedence over the leased ones. IPv6 leases are matched by host
+// name, so only work for the NICs dnsmasq knows the name of.
+func networkZoneNICAddresses(device map[string]string, hostname string, leases []networkZoneLease) []net.IP {
+	addresses := []net.IP{}
+	for _, key := range []string{"ipv4.address", "ipv6.address"} {
+		address := net.ParseIP(device[key])
+		if address != nil {
+			addresses = append(addresses, address)
+		}
+	}
+
+	for _, lease := range leases {
+		if lease.address.To4() != nil {
+			if device["ipv4.address"] != "" || lease.hwaddr != strings.ToLower(device["hwaddr"]) {
+				continue
+			}
+		} else {
+			if device["ipv6.address"] != "" || hostname == "" || lease.hostname != hostname {
+				continue
+			}
+		}
+
+		addresses = append(addresses, lease.address)
+	}
+
+	return addresses
+}
+
+// networkZoneNetworkEntries returns the addresses of the containers on a
+// network, under their name in the given domain. Containers outside the
+// default project go under a subdomain named after their project.
+func networkZoneNetworkEntries(d *Daemon, network string, domain string, containers []string) []networkZoneEntry {
+	content, _ := ioutil.ReadFile(shared.VarPath("networks", network, "dnsmasq.leases"))
+	leases := networkZoneParseLeases(string(content))
+
+	entries := []networkZoneEntry{}
+	for _, cName := range containers {
+		c, err := containerLoadByName(d, cName)
+		if err != nil {
+			continue
+		}
+
+		project, name := projectSplitName(cName)
+		fqdn := fmt.Sprintf("%s.%s", name, domain)
+
+		// Only containers of the default project have their name in dnsmasq
+		hostname := cName
+		if project != projectDefault {
+			fqdn = fmt.Sprintf("%s.%s.%s", name, project, domain)
+			hostname = ""
+		}
+
+		for k, m := range c.ExpandedDevices() {
+			if m["type"] != "nic" || m["nictype"] != "bridged" || m["parent"] != network {
+				continue
+			}
+
+			// The hwaddr from volatile, not generating one for the NICs
+			// which never started
+			if m["hwaddr"] == "" {
+				m["hwaddr"] = c.LocalConfig()[fmt.Sprintf("volatile.%s.hwaddr", k)]
+			}
+
+			for _, address := range networkZoneNICAddresses(m, hostname, leases) {
+				entries = append(entries, networkZoneEntry{name: fqdn, address: address})
+			}
+		}
+	}
+
+	return entries
+}
+
+// Records of the zones, rebuilt after a change to the containers, networks
+// or zones (see networkZoneInvalidate) or to the leases of their networks
+type networkZoneCachedRecords struct {
+	generation uint64
+	networks   []string
+	leases     string
+	records    []dns.RR
+}
+
+var networkZoneCache = map[string]networkZoneCachedRecords{}
+var networkZoneGeneration uint64
+var networkZoneCacheLock sync.Mutex
+
+// networkZoneInvalidate drops the cached zone records.
+func networkZoneInvalidate() {
+	networkZoneCacheLock.Lock()
+	networkZoneGeneration++
+	networkZoneCache = map[string]networkZoneCachedRecords{}
+	networkZoneCacheLock.Unlock()
+}
+
+// networkZoneLeasesState identifies the current content of the dnsmasq
+// leases of some networks.
+func networkZoneLeasesState(networks []string) string {
+	state := ""
+	for _, network := range networks {
+		fi, err := os.Stat(shared.VarPath("networks", network, "dnsmasq.leases"))
+		if err != nil {
+			state += "-;"
+			continue
+		}
+
+		state += fmt.Sprintf("%d:%d;", fi.ModTime().UnixNano(), fi.Size())
+	}
+
+	return state
+}
+
+// networkZoneGetRecords returns the current records of a zone.
+func networkZoneGetRecords(d *Daemon, zone *api.NetworkZone) ([]dns.RR, error) {
+	networkZoneCacheLock.Lock()
+	cached, ok := networkZoneCache[zone.Name]
+	generation := networkZoneGeneration
+	networkZoneCacheLock.Unlock()
+
+	if ok && cached.generation == generation && networkZoneLeasesState(cached.networks) == cached.leases {
+		// The caller may append to them
+		return append([]dns.RR{}, cached.records...), nil
+	}
+
+	networks, err := dbNetworks(d.db)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := dbContainersList(d.db, cTypeRegular)
+	if err != nil {
+		return nil, err
+	}
+
+	// Networks using the zone
+	used := []string{}
+	configs := map[string]map[string]string{}
+	for _, name := range networks {
+		_, network, err := dbNetworkGet(d.db, name)
+		if err != nil {
+			continue
+		}
+
+		config := network.Config
+		if config["dns.zone.forward"] != zone.Name && config["dns.zone.reverse.ipv4"] != zone.Name && config["dns.zone.reverse.ipv6"] != zone.Name {
+			continue
+		}
+
+		used = append(used, name)
+		configs[name] = config
+	}
+
+	// Looked at before reading them, so changes made meanwhile are picked up
+	leases := networkZoneLeasesState(used)
+
+	entries := networkZoneEntries{}
+	for _, name := range used {
+		config := configs[name]
+		forward := config["dns.zone.forward"] == zone.Name
+		reverse4 := config["dns.zone.reverse.ipv4"] == zone.Name
+		reverse6 := config["dns.zone.reverse.ipv6"] == zone.Name
+
+		// PTR records point to the names in the forward zone
+		domain := config["dns.zone.forward"]
+		if domain == "" {
+			domain = config["dns.domain"]
+		}
+
+		if domain == "" {
+			domain = "lxd"
+		}
+
+		for _, entry := range networkZoneNetworkEntries(d, name, domain, containers) {
+			isIPv4 := entry.address.To4() != nil
+			if forward || (reverse4 && isIPv4) || (reverse6 && !isIPv4) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	sort.Sort(entries)
+
+	records := networkZoneRecords(zone.Name, zone.Config, entries)
+	records[0].(*dns.SOA).Serial = networkZoneSerial(zone.Name, records, time.Now())
+
+	networkZoneCacheLock.Lock()
+	if networkZoneGeneration == generation {
+		networkZoneCache[zone.Name] = networkZoneCachedRecords{
+			generation: generation,
+			networks:   used,
+			leases:     leases,
+			records:    records,
+		}
+	}
+	networkZoneCacheLock.Unlock()
+
+	return append([]dns.RR{}, records...), nil
+}
+
+// networkZoneUsedBy returns the networks using a zone.
+func networkZoneUsedBy(d *Daemon, zoneName string) ([]string, error) {
+	networks, err := dbNetworks(d.db)
+	if err != nil {
+		return nil, err
+	}
+
+	usedBy := []string{}
+	for _, name := range networks {
+		_, network, err := dbNetworkGet(d.db, name)
+		if err != nil {
+			continue
+		}
+
+		for _, key := range []string{"dns.zone.forward", "dns.zone.reverse.ipv4", "dns.zone.reverse.ipv6"} {
+			if network.Config[key] == zoneName {
+				usedBy = append(usedBy, fmt.Sprintf("/%s/networks/%s", version.APIVersion, name))
+				break
+			}
+		}
+	}
+
+	return usedBy, nil
+}
+
+func doNetworkZoneGet(d *Daemon, name string) (*api.NetworkZone, error) {
+	_, zone, err := dbNetworkZoneGet(d.db, name)
+	if err != nil {
+		return nil, err
+	}
+
+	zone.UsedBy, err = networkZoneUsedBy(d, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return zone, nil
+}
+
+// API endpoints
+func networkZonesGet(d *Daemon, r *http.Request) Response {
+	recursion, err := strconv.Atoi(r.FormValue("recursion"))
+	if err != nil {
+		recursion = 0
+	}
+
+	zones, err := dbNetworkZones(d.db)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	resultString := []string{}
+	resultMap := []api.NetworkZone{}
+	for _, name := range zones {
+		if recursion == 0 {
+			resultString = append(resultString, fmt.Sprintf("/%s/network-zones/%s", version.APIVersion, name))
+			continue
+		}
+
+		zone, err := doNetworkZoneGet(d, name)
+		if err != nil {
+			continue
+		}
+
+		resultMap = append(resultMap, *zone)
+	}
+
+	if recursion == 0 {
+		return SyncResponse(true, resultString)
+	}
+
+	return SyncResponse(true, resultMap)
+}
+
+func networkZonesPost(d *Daemon, r *http.Request) Response {
+	req := api.NetworkZonesPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = networkZoneValidName(req.Name)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	err = networkZoneValidateConfig(req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	_, _, err = dbNetworkZoneGet(d.db, req.Name)
+	if err == nil {
+		return BadRequest(fmt.Errorf("The network zone already exists"))
+	}
+
+	_, err = dbNetworkZoneCreate(d.db, req.Name, req.Description, req.Config)
+	if err != nil {
+		return SmartError(fmt.Errorf("Error inserting %s into database: %s", req.Name, err))
+	}
+
+	err = dnsServerReload(d)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/network-zones/%s", version.APIVersion, req.Name))
+}
+
+var networkZonesCmd = Command{name: "network-zones", get: networkZonesGet, post: networkZonesPost}
+
+func networkZoneGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	zone, err := doNetworkZoneGet(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	etag := []interface{}{zone.Name, zone.Description, zone.Config}
+
+	return SyncResponseETag(true, zone, etag)
+}
+
+func networkZonePut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	id, zone, err := dbNetworkZoneGet(d.db, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate the ETag
+	etag := []interface{}{zone.Name, zone.Description, zone.Config}
+
+	err = etagCheck(r, etag)
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	req := api.NetworkZonePut{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	err = networkZoneValidateConfig(req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = dbNetworkZoneUpdate(d.db, id, req.Description, req.Config)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	networkZoneInvalidate()
+
+	err = dnsServerReload(d)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+func networkZoneDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	id, _, err := dbNetworkZoneGet(d.db, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	usedBy, err := networkZoneUsedBy(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if len(usedBy) > 0 {
+		return BadRequest(fmt.Errorf("The network zone is currently in use"))
+	}
+
+	err = dbNetworkZoneDelete(d.db, id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	networkZoneSerialsLock.Lock()
+	delete(networkZoneSerials, name)
+	networkZoneSerialsLock.Unlock()
+
+	networkZoneInvalidate()
+
+	err = dnsServerReload(d)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+var networkZoneCmd = Command{name: "network-zones/{name}", get: networkZoneGet, put: networkZonePut, delete: networkZoneDelete}
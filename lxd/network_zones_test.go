@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestNetworkZoneValidName(t *testing.T) {
+	for _, name := range []string{"lxd.example.net", "0.10.in-addr.arpa", "example-1.net"} {
+		err := networkZoneValidName(name)
+		if err != nil {
+			t.Errorf("Expected %q to be valid: %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"", "example.net.", "-example.net", "Example.net", "example..net", "exa_mple.net"} {
+		err := networkZoneValidName(name)
+		if err == nil {
+			t.Errorf("Expected %q to be rejected", name)
+		}
+	}
+}
+
+func TestNetworkZoneNICAddresses(t *testing.T) {
+	leases := networkZoneParseLeases(`1500000000 00:16:3e:aa:bb:cc 10.0.0.5 c1 01:00:16:3e:aa:bb:cc
+1500000000 00:16:3e:dd:ee:ff 10.0.0.6 * *
+duid 00:01:00:01:20:5b:1a:2f:00:16:3e:00:00:01
+1500000000 1036302 fd42::5 c1 00:01:00:01:20:5b:1a:2f:00:16:3e:aa:bb:cc
+`)
+
+	if len(leases) != 3 {
+		t.Fatalf("Expected 3 leases, got %d", len(leases))
+	}
+
+	addresses := networkZoneNICAddresses(map[string]string{"hwaddr": "00:16:3E:AA:BB:CC"}, "c1", leases)
+	expected := []net.IP{net.ParseIP("10.0.0.5"), net.ParseIP("fd42::5")}
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("Expected %v, got %v", expected, addresses)
+	}
+
+	// Static addresses take precedence
+	addresses = networkZoneNICAddresses(map[string]string{"hwaddr": "00:16:3e:aa:bb:cc", "ipv4.address": "10.0.0.50"}, "", leases)
+	expected = []net.IP{net.ParseIP("10.0.0.50")}
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("Expected %v, got %v", expected, addresses)
+	}
+}
+
+func TestNetworkZoneRecords(t *testing.T) {
+	entries := []networkZoneEntry{
+		{name: "c1.lxd.example.net", address: net.ParseIP("10.0.0.5")},
+		{name: "c1.lxd.example.net", address: net.ParseIP("fd42::5")},
+	}
+
+	config := map[string]string{"dns.nameservers": "ns1.example.net, ns2.example.net"}
+
+	records := networkZoneRecords("lxd.example.net", config, entries)
+	if len(records) != 5 {
+		t.Fatalf("Expected 5 records, got %d: %v", len(records), records)
+	}
+
+	soa, ok := records[0].(*dns.SOA)
+	if !ok || soa.Hdr.Name != "lxd.example.net." || soa.Ns != "ns1.example.net." {
+		t.Errorf("Unexpected SOA record: %v", records[0])
+	}
+
+	a, ok := records[3].(*dns.A)
+	if !ok || a.Hdr.Name != "c1.lxd.example.net." || !a.A.Equal(net.ParseIP("10.0.0.5")) {
+		t.Errorf("Unexpected A record: %v", records[3])
+	}
+
+	records = networkZoneRecords("0.10.in-addr.arpa", map[string]string{}, entries)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d: %v", len(records), records)
+	}
+
+	ptr, ok := records[1].(*dns.PTR)
+	if !ok || ptr.Hdr.Name != "5.0.0.10.in-addr.arpa." || ptr.Ptr != "c1.lxd.example.net." {
+		t.Errorf("Unexpected PTR record: %v", records[1])
+	}
+}
+
+func TestNetworkZoneSerial(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	records := networkZoneRecords("serial.example.net", map[string]string{}, nil)
+
+	serial := networkZoneSerial("serial.example.net", records, now)
+	if serial != 1500000000 {
+		t.Errorf("Unexpected initial serial: %d", serial)
+	}
+
+	// Unchanged content keeps its serial
+	serial = networkZoneSerial("serial.example.net", records, now.Add(time.Hour))
+	if serial != 1500000000 {
+		t.Errorf("Serial changed along with the time: %d", serial)
+	}
+
+	// Changes within the same second still bump it
+	records = networkZoneRecords("serial.example.net", map[string]string{"dns.nameservers": "ns1.example.net"}, nil)
+	serial = networkZoneSerial("serial.example.net", records, now)
+	if serial != 1500000001 {
+		t.Errorf("Serial wasn't bumped: %d", serial)
+	}
+}
+
+func TestNetworkZoneAllowed(t *testing.T) {
+	config := map[string]string{
+		"peers.ns1.address": "192.0.2.1",
+		"peers.ns2.address": "2001:db8::2",
+		"peers.ns2.key":     "c2VjcmV0",
+	}
+
+	err := networkZoneValidateConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		address string
+		keyName string
+		allowed bool
+	}{
+		{"192.0.2.1", "", true},
+		{"192.0.2.2", "", false},
+		{"2001:db8::2", "", false},
+		{"2001:db8::2", "lxd.example.net_ns1.", false},
+		{"2001:db8::2", "lxd.example.net_ns2.", true},
+	}
+
+	for _, test := range tests {
+		allowed := networkZoneAllowed("lxd.example.net", config, net.ParseIP(test.address), test.keyName)
+		if allowed != test.allowed {
+			t.Errorf("Expected %v for %s with key %q, got %v", test.allowed, test.address, test.keyName, allowed)
+		}
+	}
+
+	err = networkZoneValidateConfig(map[string]string{"peers.ns1.key": "c2VjcmV0"})
+	if err == nil {
+		t.Errorf("Expected a peer without address to be rejected")
+	}
+}
@@ -95,6 +95,11 @@ func networksPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
+	err = networkZoneValidateNetworkConfig(d, req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	// Set some default values where needed
 	if req.Config["bridge.mode"] == "fan" {
 		if req.Config["fan.underlay_subnet"] == "" {
@@ -144,6 +149,8 @@ func networksPost(d *Daemon, r *http.Request) Response {
 		return InternalError(err)
 	}
 
+	networkZoneInvalidate()
+
 	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/networks/%s", version.APIVersion, req.Name))
 }
 
@@ -239,6 +246,8 @@ func networkDelete(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	networkZoneInvalidate()
+
 	// Cleanup storage
 	if shared.PathExists(shared.VarPath("networks", n.name)) {
 		os.RemoveAll(shared.VarPath("networks", n.name))
@@ -289,6 +298,8 @@ func networkPost(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	networkZoneInvalidate()
+
 	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/networks/%s", version.APIVersion, req.Name))
 }
 
@@ -361,6 +372,11 @@ func doNetworkUpdate(d *Daemon, name string, oldConfig map[string]string, req ap
 		return BadRequest(err)
 	}
 
+	err = networkZoneValidateNetworkConfig(d, req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	// When switching to a fan bridge, auto-detect the underlay
 	if req.Config["bridge.mode"] == "fan" {
 		if req.Config["fan.underlay_subnet"] == "" {
@@ -379,6 +395,8 @@ func doNetworkUpdate(d *Daemon, name string, oldConfig map[string]string, req ap
 		return SmartError(err)
 	}
 
+	networkZoneInvalidate()
+
 	return EmptySyncResponse
 }
 
@@ -1138,6 +1156,12 @@ func (n *network) Start() error {
 		}
 	}
 
+	// Setup the network forwards
+	err = n.forwardsSetup()
+	if err != nil {
+		return err
+	}
+
 	// Kill any existing dnsmasq daemon for this network
 	err = networkKillDnsmasq(n.name, false)
 	if err != nil {
@@ -1193,6 +1217,21 @@ func (n *network) Start() error {
 		if err != nil {
 			return err
 		}
+
+		// Let the host resolve the network's domain through dnsmasq
+		if shared.IsTrue(n.config["dns.host_resolver"]) && n.config["dns.mode"] != "none" {
+			addresses := []string{}
+			for _, arg := range dnsmasqCmd {
+				if strings.HasPrefix(arg, "--listen-address=") {
+					addresses = append(addresses, strings.TrimPrefix(arg, "--listen-address="))
+				}
+			}
+
+			err = networkResolverSetup(n.name, dnsDomain, addresses)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -1335,6 +1374,13 @@ func (n *network) Update(newNetwork api.NetworkPut) error {
 			}
 		}
 
+		if shared.StringInSlice("dns.host_resolver", changedConfig) && !shared.IsTrue(newConfig["dns.host_resolver"]) && n.IsRunning() {
+			err = networkResolverClear(n.name)
+			if err != nil {
+				return err
+			}
+		}
+
 		if shared.StringInSlice("bridge.external_interfaces", changedConfig) && n.IsRunning() {
 			devices := []string{}
 			for _, dev := range strings.Split(newConfig["bridge.external_interfaces"], ",") {
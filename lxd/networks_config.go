@@ -84,10 +84,14 @@ var networkConfigKeys = map[string]func(value string) error{
 	"ipv6.routes":        shared.IsAny,
 	"ipv6.routing":       shared.IsBool,
 
-	"dns.domain": shared.IsAny,
+	"dns.domain":        shared.IsAny,
+	"dns.host_resolver": shared.IsBool,
 	"dns.mode": func(value string) error {
 		return shared.IsOneOf(value, []string{"dynamic", "managed", "none"})
 	},
+	"dns.zone.forward":      networkValidZone,
+	"dns.zone.reverse.ipv4": networkValidZone,
+	"dns.zone.reverse.ipv6": networkValidZone,
 
 	"raw.dnsmasq": shared.IsAny,
 }
@@ -40,6 +40,31 @@ func networkIptablesPrepend(protocol string, netName string, table string, chain
 	return nil
 }
 
+// networkIptablesCommentMatch checks whether a rule listed by "iptables -S"
+// has the given comment, or one made of it followed by more words (as for the
+// rules of the network's forwards). Other names merely starting with the same
+// text don't match.
+func networkIptablesCommentMatch(line string, comment string) bool {
+	fields := strings.SplitN(line, "--comment ", 2)
+	if len(fields) != 2 {
+		return false
+	}
+
+	value := fields[1]
+	if strings.HasPrefix(value, "\"") {
+		end := strings.Index(value[1:], "\"")
+		if end < 0 {
+			return false
+		}
+
+		value = value[1 : end+1]
+	} else {
+		value = strings.SplitN(value, " ", 2)[0]
+	}
+
+	return value == comment || strings.HasPrefix(value, comment+" ")
+}
+
 func networkIptablesClear(protocol string, netName string, table string) error {
 	// Detect kernels that lack IPv6 support
 	if !shared.PathExists("/proc/sys/net/ipv6") && protocol == "ipv6" {
@@ -64,7 +89,7 @@ func networkIptablesClear(protocol string, netName string, table string) error {
 	}
 
 	for _, line := range strings.Split(output, "\n") {
-		if !strings.Contains(line, fmt.Sprintf("generated for LXD network %s", netName)) {
+		if !networkIptablesCommentMatch(line, fmt.Sprintf("generated for LXD network %s", netName)) {
 			continue
 		}
 
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNetworkIptablesCommentMatch(t *testing.T) {
+	rule := func(comment string) string {
+		return `-A PREROUTING -d 192.0.2.10/32 -j DNAT --to-destination 10.0.3.10 -m comment --comment "` + comment + `"`
+	}
+
+	tests := []struct {
+		line    string
+		comment string
+		match   bool
+	}{
+		{rule("generated for LXD network lxdbr0"), "generated for LXD network lxdbr0", true},
+		{rule("generated for LXD network lxdbr0 forward 10.0.0.1"), "generated for LXD network lxdbr0", true},
+		{rule("generated for LXD network lxdbr0 forward 10.0.0.1"), "generated for LXD network lxdbr0 forward 10.0.0.1", true},
+		{rule("generated for LXD network lxdbr0 forward 10.0.0.10"), "generated for LXD network lxdbr0 forward 10.0.0.1", false},
+		{rule("generated for LXD network lxdbr0 forward ::10"), "generated for LXD network lxdbr0 forward ::1", false},
+		{rule("generated for LXD network lxdbr01"), "generated for LXD network lxdbr0", false},
+		{"-A PREROUTING -d 192.0.2.10/32 -j ACCEPT", "generated for LXD network lxdbr0", false},
+	}
+
+	for i, test := range tests {
+		match := networkIptablesCommentMatch(test.line, test.comment)
+		if match != test.match {
+			t.Errorf("Test %d: expected %v for %q, got %v", i, test.match, test.comment, match)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -474,6 +475,14 @@ func networkValidName(value string) error {
 	return nil
 }
 
+func networkValidZone(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	return networkZoneValidName(value)
+}
+
 func networkValidPort(value string) error {
 	if value == "" {
 		return nil
@@ -721,6 +730,55 @@ func networkKillDnsmasq(name string, reload bool) error {
 	return nil
 }
 
+// networkResolverSetup has systemd-resolved on the host send the queries for
+// the network's domain to the given dnsmasq addresses.
+func networkResolverSetup(name string, domain string, addresses []string) error {
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	_, err := exec.LookPath("resolvectl")
+	if err == nil {
+		_, err = shared.RunCommand("resolvectl", append([]string{"dns", name}, addresses...)...)
+		if err != nil {
+			return err
+		}
+
+		_, err = shared.RunCommand("resolvectl", "domain", name, fmt.Sprintf("~%s", domain))
+		return err
+	}
+
+	_, err = exec.LookPath("systemd-resolve")
+	if err != nil {
+		return fmt.Errorf("Registering the network with the host resolver requires systemd-resolved")
+	}
+
+	args := []string{"--interface", name, "--set-domain", fmt.Sprintf("~%s", domain)}
+	for _, address := range addresses {
+		args = append(args, "--set-dns", address)
+	}
+
+	_, err = shared.RunCommand("systemd-resolve", args...)
+	return err
+}
+
+// networkResolverClear drops the network's settings from systemd-resolved.
+func networkResolverClear(name string) error {
+	_, err := exec.LookPath("resolvectl")
+	if err == nil {
+		_, err = shared.RunCommand("resolvectl", "revert", name)
+		return err
+	}
+
+	_, err = exec.LookPath("systemd-resolve")
+	if err == nil {
+		_, err = shared.RunCommand("systemd-resolve", "--interface", name, "--revert")
+		return err
+	}
+
+	return nil
+}
+
 func networkUpdateStatic(d *Daemon, name string) error {
 	// Get all the containers
 	containers, err := dbContainersList(d.db, cTypeRegular)
@@ -767,7 +825,15 @@ func networkUpdateStatic(d *Daemon, name string) error {
 				entries[d["parent"]] = [][]string{}
 			}
 
-			entries[d["parent"]] = append(entries[d["parent"]], []string{d["hwaddr"], cName, d["ipv4.address"], d["ipv6.address"]})
+			// Containers outside the default project only get the name
+			// they request, the project's one not being a valid hostname
+			hostname := cName
+			project, _ := projectSplitName(cName)
+			if project != projectDefault {
+				hostname = ""
+			}
+
+			entries[d["parent"]] = append(entries[d["parent"]], []string{d["hwaddr"], hostname, d["ipv4.address"], d["ipv6.address"]})
 		}
 	}
 
@@ -810,7 +876,7 @@ func networkUpdateStatic(d *Daemon, name string) error {
 					line += fmt.Sprintf(",[%s]", ipv6Address)
 				}
 
-				if config["dns.mode"] == "" || config["dns.mode"] == "managed" {
+				if cName != "" && (config["dns.mode"] == "" || config["dns.mode"] == "managed") {
 					line += fmt.Sprintf(",%s", cName)
 				}
 
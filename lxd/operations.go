@@ -457,6 +457,11 @@ func operationsAPIGet(d *Daemon, r *http.Request) Response {
 
 	recursion := d.isRecursionRequest(r)
 
+	params, err := listParamsGet(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	md = shared.Jmap{}
 
 	operationsLock.Lock()
@@ -487,6 +492,14 @@ func operationsAPIGet(d *Daemon, r *http.Request) Response {
 		md[status] = append(md[status].([]*api.Operation), body)
 	}
 
+	// Each status gets sorted and paginated separately
+	for status, list := range md {
+		md[status], err = params.apply(list, "id")
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
 	return SyncResponse(true, md)
 }
 
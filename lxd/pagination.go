@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listParams holds the sorting and pagination arguments of a collection GET
+type listParams struct {
+	sort    string
+	reverse bool
+	offset  int
+	limit   int
+}
+
+// listParamsGet parses the "sort", "offset" and "limit" arguments of a
+// request, returning nil if none of them was provided.
+func listParamsGet(r *http.Request) (*listParams, error) {
+	values := r.URL.Query()
+	if values.Get("sort") == "" && values.Get("offset") == "" && values.Get("limit") == "" {
+		return nil, nil
+	}
+
+	params := listParams{limit: -1}
+
+	params.sort = values.Get("sort")
+	if strings.HasPrefix(params.sort, "-") {
+		params.sort = strings.TrimPrefix(params.sort, "-")
+		params.reverse = true
+	}
+
+	if values.Get("offset") != "" {
+		offset, err := strconv.Atoi(values.Get("offset"))
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("Invalid offset: %s", values.Get("offset"))
+		}
+
+		params.offset = offset
+	}
+
+	if values.Get("limit") != "" {
+		limit, err := strconv.Atoi(values.Get("limit"))
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("Invalid limit: %s", values.Get("limit"))
+		}
+
+		params.limit = limit
+	}
+
+	return &params, nil
+}
+
+// apply sorts the list (a slice of URLs or of API structs) and returns the
+// requested page of it. Lists of URLs can only be sorted on the key which
+// identifies the objects (e.g. "name"), which is also the default.
+func (p *listParams) apply(list interface{}, key string) (interface{}, error) {
+	if p == nil {
+		return list, nil
+	}
+
+	sortKey := p.sort
+	if sortKey == "" {
+		sortKey = key
+	}
+
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("Can't paginate a %s", v.Kind())
+	}
+
+	entries := listEntries{reverse: p.reverse}
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		if item.Kind() == reflect.String && sortKey != key {
+			return nil, fmt.Errorf("Sorting on \"%s\" requires recursion", sortKey)
+		}
+
+		value, ok := listSortValue(item, sortKey)
+		if !ok {
+			return nil, fmt.Errorf("Invalid sort key: %s", sortKey)
+		}
+
+		if !listValueSortable(value) {
+			return nil, fmt.Errorf("Can't sort on \"%s\"", sortKey)
+		}
+
+		entries.items = append(entries.items, item)
+		entries.values = append(entries.values, value)
+	}
+
+	sort.Stable(entries)
+
+	start := p.offset
+	if start > len(entries.items) {
+		start = len(entries.items)
+	}
+
+	end := len(entries.items)
+	if p.limit >= 0 && start+p.limit < end {
+		end = start + p.limit
+	}
+
+	result := reflect.MakeSlice(v.Type(), 0, end-start)
+	for _, item := range entries.items[start:end] {
+		result = reflect.Append(result, item)
+	}
+
+	return result.Interface(), nil
+}
+
+// listSortValue returns the value to sort the item on, being the item itself
+// for URLs and the field with the matching JSON name for structs.
+func listSortValue(item reflect.Value, key string) (reflect.Value, bool) {
+	for item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface {
+		if item.IsNil() {
+			return item, false
+		}
+
+		item = item.Elem()
+	}
+
+	if item.Kind() == reflect.String {
+		return item, true
+	}
+
+	if item.Kind() != reflect.Struct {
+		return item, false
+	}
+
+	for i := 0; i < item.NumField(); i++ {
+		field := item.Type().Field(i)
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.Anonymous && name == "" {
+			value, ok := listSortValue(item.Field(i), key)
+			if ok {
+				return value, true
+			}
+
+			continue
+		}
+
+		if name == key {
+			return item.Field(i), true
+		}
+	}
+
+	return item, false
+}
+
+type listEntries struct {
+	items   []reflect.Value
+	values  []reflect.Value
+	reverse bool
+}
+
+func (l listEntries) Len() int {
+	return len(l.items)
+}
+
+func (l listEntries) Swap(i, j int) {
+	l.items[i], l.items[j] = l.items[j], l.items[i]
+	l.values[i], l.values[j] = l.values[j], l.values[i]
+}
+
+func (l listEntries) Less(i, j int) bool {
+	if l.reverse {
+		return listValueLess(l.values[j], l.values[i])
+	}
+
+	return listValueLess(l.values[i], l.values[j])
+}
+
+func listValueSortable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+
+	return v.Type() == reflect.TypeOf(time.Time{})
+}
+
+func listValueLess(a reflect.Value, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	}
+
+	return a.Interface().(time.Time).Before(b.Interface().(time.Time))
+}
@@ -82,6 +82,9 @@ func patchesApplyAll(d *Daemon) error {
 		if err != nil {
 			return err
 		}
+
+		// Patches may rewrite profiles behind the cache's back
+		profileCacheFlush()
 	}
 
 	return nil
@@ -26,24 +26,32 @@ func profilesGet(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	projects, err := dbProjects(d.db)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	project := projectProfiles(d, projectParam(r))
 	recursion := d.isRecursionRequest(r)
 
-	resultString := make([]string, len(results))
-	resultMap := make([]*api.Profile, len(results))
-	i := 0
+	resultString := []string{}
+	resultMap := []*api.Profile{}
 	for _, name := range results {
+		if !projectOwns(projects, project, name) {
+			continue
+		}
+
 		if !recursion {
-			url := fmt.Sprintf("/%s/profiles/%s", version.APIVersion, name)
-			resultString[i] = url
+			url := fmt.Sprintf("/%s/profiles/%s", version.APIVersion, projectStrip(project, name))
+			resultString = append(resultString, url)
 		} else {
-			profile, err := doProfileGet(d, name)
+			profile, err := doProfileGet(d, project, name)
 			if err != nil {
 				logger.Error("Failed to get profile", log.Ctx{"profile": name})
 				continue
 			}
-			resultMap[i] = profile
+			resultMap = append(resultMap, profile)
 		}
-		i++
 	}
 
 	if !recursion {
@@ -64,7 +72,7 @@ func profilesPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("No name provided"))
 	}
 
-	_, profile, _ := dbProfileGet(d.db, req.Name)
+	_, profile, _ := dbProfileGet(d.db, projectPrefix(projectProfiles(d, projectParam(r)), req.Name))
 	if profile != nil {
 		return BadRequest(fmt.Errorf("The profile already exists"))
 	}
@@ -73,11 +81,16 @@ func profilesPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("Profile names may not contain slashes"))
 	}
 
+	err := projectCheckNameConflict(d, projectProfiles(d, projectParam(r)), req.Name)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	if shared.StringInSlice(req.Name, []string{".", ".."}) {
 		return BadRequest(fmt.Errorf("Invalid profile name '%s'", req.Name))
 	}
 
-	err := containerValidConfig(d, req.Config, true, false)
+	err = containerValidConfig(d, req.Config, true, false)
 	if err != nil {
 		return BadRequest(err)
 	}
@@ -88,7 +101,8 @@ func profilesPost(d *Daemon, r *http.Request) Response {
 	}
 
 	// Update DB entry
-	_, err = dbProfileCreate(d.db, req.Name, req.Description, req.Config, req.Devices)
+	name := projectPrefix(projectProfiles(d, projectParam(r)), req.Name)
+	_, err = dbProfileCreate(d.db, name, req.Description, req.Config, req.Devices)
 	if err != nil {
 		return SmartError(
 			fmt.Errorf("Error inserting %s into database: %s", req.Name, err))
@@ -102,7 +116,9 @@ var profilesCmd = Command{
 	get:  profilesGet,
 	post: profilesPost}
 
-func doProfileGet(d *Daemon, name string) (*api.Profile, error) {
+// doProfileGet renders the profile with the given internal name, as seen
+// from the project owning it.
+func doProfileGet(d *Daemon, project string, name string) (*api.Profile, error) {
 	_, profile, err := dbProfileGet(d.db, name)
 	if err != nil {
 		return nil, err
@@ -115,8 +131,15 @@ func doProfileGet(d *Daemon, name string) (*api.Profile, error) {
 
 	usedBy := []string{}
 	for _, ct := range cts {
-		usedBy = append(usedBy, fmt.Sprintf("/%s/containers/%s", version.APIVersion, ct))
+		ctProject, ctName := projectSplitName(ct)
+		url := fmt.Sprintf("/%s/containers/%s", version.APIVersion, ctName)
+		if ctProject != projectDefault {
+			url += fmt.Sprintf("?project=%s", ctProject)
+		}
+
+		usedBy = append(usedBy, url)
 	}
+	profile.Name = projectStrip(project, profile.Name)
 	profile.UsedBy = usedBy
 
 	return profile, nil
@@ -125,7 +148,7 @@ func doProfileGet(d *Daemon, name string) (*api.Profile, error) {
 func profileGet(d *Daemon, r *http.Request) Response {
 	name := mux.Vars(r)["name"]
 
-	resp, err := doProfileGet(d, name)
+	resp, err := doProfileGet(d, projectProfiles(d, projectParam(r)), name)
 	if err != nil {
 		return SmartError(err)
 	}
@@ -257,8 +280,11 @@ func profilePost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("No name provided"))
 	}
 
+	// Renames stay within the profile's project
+	newName := projectPrefix(projectProfiles(d, projectParam(r)), req.Name)
+
 	// Check that the name isn't already in use
-	id, _, _ := dbProfileGet(d.db, req.Name)
+	id, _, _ := dbProfileGet(d.db, newName)
 	if id > 0 {
 		return Conflict
 	}
@@ -267,11 +293,16 @@ func profilePost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("Profile names may not contain slashes"))
 	}
 
+	err := projectCheckNameConflict(d, projectProfiles(d, projectParam(r)), req.Name)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	if shared.StringInSlice(req.Name, []string{".", ".."}) {
 		return BadRequest(fmt.Errorf("Invalid profile name '%s'", req.Name))
 	}
 
-	err := dbProfileUpdate(d.db, name, req.Name)
+	err = dbProfileUpdate(d.db, name, newName)
 	if err != nil {
 		return SmartError(err)
 	}
@@ -283,7 +314,7 @@ func profilePost(d *Daemon, r *http.Request) Response {
 func profileDelete(d *Daemon, r *http.Request) Response {
 	name := mux.Vars(r)["name"]
 
-	_, err := doProfileGet(d, name)
+	_, _, err := dbProfileGet(d.db, name)
 	if err != nil {
 		return SmartError(err)
 	}
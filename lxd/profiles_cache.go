@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/lxc/lxd/lxd/types"
+)
+
+// profileCache holds the config and devices of the profiles as used to
+// expand the configuration of every container being loaded, which would
+// otherwise cost two queries per profile of each container. The container's
+// own config is always read from the database, so only profile changes
+// need to flush the cache.
+var profileCache = struct {
+	sync.Mutex
+
+	// generation is bumped on every flush so that a profile read before
+	// a flush doesn't get stored after it.
+	generation int64
+	entries    map[profileCacheKey]*profileCacheEntry
+}{entries: map[profileCacheKey]*profileCacheEntry{}}
+
+type profileCacheKey struct {
+	db   *sql.DB
+	name string
+}
+
+type profileCacheEntry struct {
+	config  map[string]string
+	devices types.Devices
+}
+
+// profileCacheGet returns the config and devices of a profile. The returned
+// config is shared with the cache and must not be modified, the devices are
+// a copy.
+func profileCacheGet(db *sql.DB, name string) (map[string]string, types.Devices, error) {
+	key := profileCacheKey{db: db, name: name}
+
+	profileCache.Lock()
+	entry, ok := profileCache.entries[key]
+	generation := profileCache.generation
+	profileCache.Unlock()
+
+	if !ok {
+		config, err := dbProfileConfig(db, name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		devices, err := dbDevices(db, name, true)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		entry = &profileCacheEntry{config: config, devices: devices}
+
+		profileCache.Lock()
+		if profileCache.generation == generation {
+			profileCache.entries[key] = entry
+		}
+		profileCache.Unlock()
+	}
+
+	devices := types.Devices{}
+	for name, device := range entry.devices {
+		devices[name] = types.Device{}
+		for k, v := range device {
+			devices[name][k] = v
+		}
+	}
+
+	return entry.config, devices, nil
+}
+
+// profileCacheFlush drops all the cached profiles. It must be called once
+// any change to a profile has been committed.
+func profileCacheFlush() {
+	profileCache.Lock()
+	profileCache.generation++
+	profileCache.entries = map[profileCacheKey]*profileCacheEntry{}
+	profileCache.Unlock()
+}
@@ -109,6 +109,8 @@ func doProfileUpdate(d *Daemon, name string, id int64, profile *api.Profile, req
 		return SmartError(err)
 	}
 
+	profileCacheFlush()
+
 	// Update all the containers using the profile. Must be done after txCommit due to DB lock.
 	failures := map[string]error{}
 	for _, c := range containers {
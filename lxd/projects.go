@@ -0,0 +1,772 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// projectDefault is the implicit project holding everything created without
+// a project, it can be neither modified nor deleted.
+const projectDefault = "default"
+
+var projectConfigKeys = map[string]func(value string) error{
+	"features.profiles": shared.IsBool,
+	"limits.containers": shared.IsUint32,
+	"limits.cpu":        shared.IsUint32,
+	"limits.disk":       projectValidSize,
+	"limits.memory":     projectValidSize,
+}
+
+// projectResourceLimits are the project limits applying to the total of the
+// resources allocated to its containers.
+var projectResourceLimits = []string{"limits.cpu", "limits.disk", "limits.memory"}
+
+func projectValidSize(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := shared.ParseByteSizeString(value)
+	return err
+}
+
+/*
+ * Containers and profiles of a project are stored as "<project>_<name>".
+ * As "_" isn't allowed in container or project names, the default project
+ * simply keeps the plain names. Its profiles may only use "_" when the part
+ * before it isn't the name of a project.
+ */
+func projectPrefix(project string, name string) string {
+	if project == projectDefault {
+		return name
+	}
+
+	return fmt.Sprintf("%s_%s", project, name)
+}
+
+func projectStrip(project string, name string) string {
+	if project == projectDefault {
+		return name
+	}
+
+	return strings.TrimPrefix(name, project+"_")
+}
+
+func projectSplitName(name string) (string, string) {
+	fields := strings.SplitN(name, "_", 2)
+	if len(fields) != 2 {
+		return projectDefault, name
+	}
+
+	return fields[0], fields[1]
+}
+
+// projectOwns returns whether the internal name belongs to the project,
+// projects being the list of all the non-default projects.
+func projectOwns(projects []string, project string, name string) bool {
+	if project != projectDefault {
+		return strings.HasPrefix(name, project+"_")
+	}
+
+	for _, entry := range projects {
+		if strings.HasPrefix(name, entry+"_") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// projectCheckNameConflict returns an error if a name of the default project
+// would be taken for one in another project, as "<project>_<name>".
+func projectCheckNameConflict(d *Daemon, project string, name string) error {
+	if project != projectDefault || !strings.Contains(name, "_") {
+		return nil
+	}
+
+	projects, err := dbProjects(d.db)
+	if err != nil {
+		return err
+	}
+
+	prefix, _ := projectSplitName(name)
+	if prefix != projectDefault && shared.StringInSlice(prefix, projects) {
+		return fmt.Errorf("The name '%s' conflicts with project '%s'", name, prefix)
+	}
+
+	return nil
+}
+
+func projectParam(r *http.Request) string {
+	project := r.URL.Query().Get("project")
+	if project == "" {
+		return projectDefault
+	}
+
+	return project
+}
+
+func projectHasProfiles(project *api.Project) bool {
+	value, ok := project.Config["features.profiles"]
+	return !ok || shared.IsTrue(value)
+}
+
+// projectProfiles returns the project whose profiles are used by the
+// containers of the given project.
+func projectProfiles(d *Daemon, project string) string {
+	if project == projectDefault {
+		return project
+	}
+
+	_, p, err := dbProjectGet(d.db, project)
+	if err != nil || !projectHasProfiles(p) {
+		return projectDefault
+	}
+
+	return project
+}
+
+func projectStripContainer(d *Daemon, project string, c *api.Container) {
+	if project == projectDefault {
+		return
+	}
+
+	profileProject := projectProfiles(d, project)
+
+	c.Name = projectStrip(project, c.Name)
+	for i, profile := range c.Profiles {
+		c.Profiles[i] = projectStrip(profileProject, profile)
+	}
+}
+
+// projectRewriteRequest maps the container and profile names found in the
+// URL of a request made against a project to their internal names.
+func projectRewriteRequest(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	if project == projectDefault {
+		return nil
+	}
+
+	_, p, err := dbProjectGet(d.db, project)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	prefixes := []string{fmt.Sprintf("/%s/containers/", version.APIVersion)}
+	if projectHasProfiles(p) {
+		prefixes = append(prefixes, fmt.Sprintf("/%s/profiles/", version.APIVersion))
+	}
+
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(r.URL.Path, prefix) || r.URL.Path == prefix {
+			continue
+		}
+
+		r.URL.Path = prefix + projectPrefix(project, strings.TrimPrefix(r.URL.Path, prefix))
+		r.URL.RawPath = ""
+		break
+	}
+
+	return nil
+}
+
+// projectUsage returns the internal names of the containers and profiles of
+// a project, snapshots excluded.
+func projectUsage(d *Daemon, project string) ([]string, []string, error) {
+	projects, err := dbProjects(d.db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	containers := []string{}
+	names, err := dbContainersList(d.db, cTypeRegular)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, name := range names {
+		if projectOwns(projects, project, name) {
+			containers = append(containers, name)
+		}
+	}
+
+	profiles := []string{}
+	names, err = dbProfiles(d.db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, name := range names {
+		if projectOwns(projects, project, name) {
+			profiles = append(profiles, name)
+		}
+	}
+
+	return containers, profiles, nil
+}
+
+// projectIsEmpty returns whether a project holds nothing but its own default profile
+func projectIsEmpty(d *Daemon, project string) (bool, error) {
+	containers, profiles, err := projectUsage(d, project)
+	if err != nil {
+		return false, err
+	}
+
+	if len(containers) > 0 {
+		return false, nil
+	}
+
+	for _, profile := range profiles {
+		if profile != projectPrefix(project, "default") {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// projectCheckContainerLimit returns an error if the project can't hold another container
+func projectCheckContainerLimit(d *Daemon, project string) error {
+	_, p, err := dbProjectGet(d.db, project)
+	if err != nil {
+		return err
+	}
+
+	value := p.Config["limits.containers"]
+	if value == "" {
+		return nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+
+	containers, _, err := projectUsage(d, project)
+	if err != nil {
+		return err
+	}
+
+	if len(containers) >= limit {
+		return fmt.Errorf("The project already has the maximum number of containers (%d)", limit)
+	}
+
+	return nil
+}
+
+// projectContainerAllocation returns the resources allocated to a container
+// for each of projectResourceLimits, -1 meaning unlimited.
+func projectContainerAllocation(config map[string]string, devices types.Devices) (map[string]int64, error) {
+	cpus, memory, err := containerLimitsUsage(config)
+	if err != nil {
+		return nil, err
+	}
+
+	disk := int64(-1)
+	_, rootDisk, _ := containerGetRootDiskDevice(devices)
+	if rootDisk["size"] != "" {
+		disk, err = shared.ParseByteSizeString(rootDisk["size"])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return map[string]int64{"limits.cpu": cpus, "limits.disk": disk, "limits.memory": memory}, nil
+}
+
+// projectCheckAllocation checks the resources allocated to the containers of
+// a project against the given limits. When name is set, allocation replaces
+// the current one of that container.
+func projectCheckAllocation(d *Daemon, project string, config map[string]string, name string, allocation map[string]int64) error {
+	limits := map[string]int64{}
+	for _, key := range projectResourceLimits {
+		if config[key] == "" {
+			continue
+		}
+
+		var limit int64
+		var err error
+		if key == "limits.cpu" {
+			limit, err = strconv.ParseInt(config[key], 10, 64)
+		} else {
+			limit, err = shared.ParseByteSizeString(config[key])
+		}
+		if err != nil {
+			return err
+		}
+
+		limits[key] = limit
+	}
+
+	if len(limits) == 0 {
+		return nil
+	}
+
+	containers, _, err := projectUsage(d, project)
+	if err != nil {
+		return err
+	}
+
+	allocations := map[string]map[string]int64{}
+	for _, ctName := range containers {
+		if ctName == name {
+			continue
+		}
+
+		c, err := containerLoadByName(d, ctName)
+		if err != nil {
+			return err
+		}
+
+		allocations[ctName], err = projectContainerAllocation(c.ExpandedConfig(), c.ExpandedDevices())
+		if err != nil {
+			return err
+		}
+	}
+
+	if name != "" {
+		allocations[name] = allocation
+	}
+
+	for key, limit := range limits {
+		total := int64(0)
+		for ctName, ctAllocation := range allocations {
+			// Without a value, the container could use all of the project's share
+			if ctAllocation[key] < 0 {
+				if key == "limits.disk" {
+					return fmt.Errorf("Container '%s' must have a root disk size in project '%s'", projectStrip(project, ctName), project)
+				}
+
+				return fmt.Errorf("Container '%s' must have %s set in project '%s'", projectStrip(project, ctName), key, project)
+			}
+
+			total += ctAllocation[key]
+		}
+
+		if total > limit {
+			used := fmt.Sprintf("%d", total)
+			available := fmt.Sprintf("%d", limit)
+			if key != "limits.cpu" {
+				used = shared.GetByteSizeString(total, 2)
+				available = shared.GetByteSizeString(limit, 2)
+			}
+
+			return fmt.Errorf("Project '%s' would exceed its %s (%s allocated out of %s)", project, key, used, available)
+		}
+	}
+
+	return nil
+}
+
+// projectValidLimits checks a container's resources against the limits of its project
+func projectValidLimits(d *Daemon, name string, config map[string]string, devices types.Devices) error {
+	project, _ := projectSplitName(name)
+	if project == projectDefault {
+		return nil
+	}
+
+	_, p, err := dbProjectGet(d.db, project)
+	if err != nil {
+		return err
+	}
+
+	allocation, err := projectContainerAllocation(config, devices)
+	if err != nil {
+		return err
+	}
+
+	return projectCheckAllocation(d, project, p.Config, name, allocation)
+}
+
+func projectValidName(name string) error {
+	if name == "" {
+		return fmt.Errorf("No name provided")
+	}
+
+	if strings.ContainsAny(name, "_/ ") || strings.Contains(name, shared.SnapshotDelimiter) {
+		return fmt.Errorf("Project names may not contain underscores, slashes, spaces or '%s'", shared.SnapshotDelimiter)
+	}
+
+	if shared.StringInSlice(name, []string{".", "..", projectDefault}) {
+		return fmt.Errorf("Invalid project name '%s'", name)
+	}
+
+	return nil
+}
+
+func projectValidateConfig(config map[string]string) error {
+	for k, v := range config {
+		// User keys are free for all
+		if strings.HasPrefix(k, "user.") {
+			continue
+		}
+
+		validator, ok := projectConfigKeys[k]
+		if !ok {
+			return fmt.Errorf("Invalid project configuration key: %s", k)
+		}
+
+		err := validator(v)
+		if err != nil {
+			return fmt.Errorf("Invalid value for '%s': %s", k, err)
+		}
+	}
+
+	return nil
+}
+
+// projectCreateDefaultProfile gives a project its own default profile,
+// starting from the one of the default project.
+func projectCreateDefaultProfile(d *Daemon, project string) error {
+	_, profile, err := dbProfileGet(d.db, "default")
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("Default LXD profile for project %s", project)
+	_, err = dbProfileCreate(d.db, projectPrefix(project, "default"), description, profile.Config, profile.Devices)
+	return err
+}
+
+func doProjectGet(d *Daemon, name string) (*api.Project, error) {
+	project := &api.Project{Name: projectDefault}
+	project.Config = map[string]string{}
+	if name != projectDefault {
+		var err error
+		_, project, err = dbProjectGet(d.db, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	containers, profiles, err := projectUsage(d, name)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := ""
+	if name != projectDefault {
+		suffix = fmt.Sprintf("?project=%s", name)
+	}
+
+	profileProject := projectDefault
+	if projectHasProfiles(project) {
+		profileProject = name
+	}
+
+	usedBy := []string{}
+	for _, ct := range containers {
+		usedBy = append(usedBy, fmt.Sprintf("/%s/containers/%s%s", version.APIVersion, projectStrip(name, ct), suffix))
+	}
+
+	if profileProject == name {
+		for _, profile := range profiles {
+			usedBy = append(usedBy, fmt.Sprintf("/%s/profiles/%s%s", version.APIVersion, projectStrip(name, profile), suffix))
+		}
+	}
+
+	project.UsedBy = usedBy
+
+	return project, nil
+}
+
+func projectsGet(d *Daemon, r *http.Request) Response {
+	names, err := dbProjects(d.db)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	names = append([]string{projectDefault}, names...)
+
+	recursion := d.isRecursionRequest(r)
+
+	resultString := []string{}
+	resultMap := []*api.Project{}
+	for _, name := range names {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/projects/%s", version.APIVersion, name))
+		} else {
+			project, err := doProjectGet(d, name)
+			if err != nil {
+				return SmartError(err)
+			}
+
+			resultMap = append(resultMap, project)
+		}
+	}
+
+	if !recursion {
+		return SyncResponse(true, resultString)
+	}
+
+	return SyncResponse(true, resultMap)
+}
+
+func projectsPost(d *Daemon, r *http.Request) Response {
+	req := api.ProjectsPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Sanity checks
+	err = projectValidName(req.Name)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	_, _, err = dbProjectGet(d.db, req.Name)
+	if err == nil {
+		return BadRequest(fmt.Errorf("The project already exists"))
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	err = projectValidateConfig(req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Existing objects whose name starts with the project prefix would
+	// suddenly become part of the new project.
+	containers, profiles, err := projectUsage(d, projectDefault)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, name := range append(containers, profiles...) {
+		if strings.HasPrefix(name, req.Name+"_") {
+			return BadRequest(fmt.Errorf("The name of '%s' conflicts with the project", name))
+		}
+	}
+
+	// Create the database entry
+	_, err = dbProjectCreate(d.db, req.Name, req.Description, req.Config)
+	if err != nil {
+		return SmartError(fmt.Errorf("Error inserting %s into database: %s", req.Name, err))
+	}
+
+	if projectHasProfiles(&api.Project{ProjectPut: req.ProjectPut}) {
+		err = projectCreateDefaultProfile(d, req.Name)
+		if err != nil {
+			dbProjectDelete(d.db, req.Name)
+			return SmartError(err)
+		}
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/projects/%s", version.APIVersion, req.Name))
+}
+
+var projectsCmd = Command{name: "projects", get: projectsGet, post: projectsPost}
+
+func projectGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	project, err := doProjectGet(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	etag := []interface{}{project.Config, project.Description}
+	return SyncResponseETag(true, project, etag)
+}
+
+func projectPut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	if name == projectDefault {
+		return BadRequest(fmt.Errorf("The default project can't be modified"))
+	}
+
+	_, project, err := dbProjectGet(d.db, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate the ETag
+	etag := []interface{}{project.Config, project.Description}
+	err = etagCheck(r, etag)
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	req := api.ProjectPut{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	return doProjectUpdate(d, name, project, req)
+}
+
+func projectPatch(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	if name == projectDefault {
+		return BadRequest(fmt.Errorf("The default project can't be modified"))
+	}
+
+	_, project, err := dbProjectGet(d.db, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate the ETag
+	etag := []interface{}{project.Config, project.Description}
+	err = etagCheck(r, etag)
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	reqRaw := shared.Jmap{}
+	err = json.NewDecoder(bytes.NewReader(body)).Decode(&reqRaw)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	req := api.ProjectPut{}
+	err = json.NewDecoder(bytes.NewReader(body)).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Get Description
+	_, err = reqRaw.GetString("description")
+	if err != nil {
+		req.Description = project.Description
+	}
+
+	// Get Config
+	if req.Config == nil {
+		req.Config = project.Config
+	} else {
+		for k, v := range project.Config {
+			_, ok := req.Config[k]
+			if !ok {
+				req.Config[k] = v
+			}
+		}
+	}
+
+	return doProjectUpdate(d, name, project, req)
+}
+
+func doProjectUpdate(d *Daemon, name string, project *api.Project, req api.ProjectPut) Response {
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	err := projectValidateConfig(req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	hadProfiles := projectHasProfiles(project)
+	hasProfiles := projectHasProfiles(&api.Project{ProjectPut: req})
+	if req.Config["limits.containers"] != "" {
+		limit, err := strconv.Atoi(req.Config["limits.containers"])
+		if err != nil {
+			return BadRequest(err)
+		}
+
+		containers, _, err := projectUsage(d, name)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		if len(containers) > limit {
+			return BadRequest(fmt.Errorf("The project already has %d containers", len(containers)))
+		}
+	}
+
+	err = projectCheckAllocation(d, name, req.Config, "", nil)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if hadProfiles != hasProfiles {
+		empty, err := projectIsEmpty(d, name)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		if !empty {
+			return BadRequest(fmt.Errorf("Features can only be changed on empty projects"))
+		}
+	}
+
+	if !reflect.DeepEqual(project.Config, req.Config) || project.Description != req.Description {
+		err = dbProjectUpdate(d.db, name, req.Description, req.Config)
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	if !hadProfiles && hasProfiles {
+		err = projectCreateDefaultProfile(d, name)
+		if err != nil {
+			return SmartError(err)
+		}
+	} else if hadProfiles && !hasProfiles {
+		err = dbProfileDelete(d.db, projectPrefix(name, "default"))
+		if err != nil && err != sql.ErrNoRows {
+			return SmartError(err)
+		}
+	}
+
+	return EmptySyncResponse
+}
+
+func projectDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	if name == projectDefault {
+		return BadRequest(fmt.Errorf("The default project can't be deleted"))
+	}
+
+	_, _, err := dbProjectGet(d.db, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	empty, err := projectIsEmpty(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !empty {
+		return BadRequest(fmt.Errorf("Only empty projects can be removed"))
+	}
+
+	err = dbProfileDelete(d.db, projectPrefix(name, "default"))
+	if err != nil && err != sql.ErrNoRows {
+		return SmartError(err)
+	}
+
+	err = dbProjectDelete(d.db, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+var projectCmd = Command{name: "projects/{name}", get: projectGet, put: projectPut, patch: projectPatch, delete: projectDelete}
@@ -207,6 +207,32 @@ func FileResponse(r *http.Request, files []fileResponseEntry, headers map[string
 	return &fileResponse{r, files, headers, removeAfterServe}
 }
 
+// Stream response, for content which doesn't come from a local file
+type streamResponse struct {
+	reader   io.ReadCloser
+	filename string
+	size     int64
+}
+
+func (r *streamResponse) Render(w http.ResponseWriter) error {
+	defer r.reader.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", r.size))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline;filename=%s", r.filename))
+
+	_, err := io.Copy(w, r.reader)
+	return err
+}
+
+func (r *streamResponse) String() string {
+	return r.filename
+}
+
+func StreamResponse(reader io.ReadCloser, filename string, size int64) Response {
+	return &streamResponse{reader, filename, size}
+}
+
 // Operation response
 type operationResponse struct {
 	op *operation
@@ -273,7 +299,7 @@ func (r *errorResponse) Render(w http.ResponseWriter) error {
 		output = io.MultiWriter(buf, captured)
 	}
 
-	err := json.NewEncoder(output).Encode(shared.Jmap{"type": api.ErrorResponse, "error": r.msg, "error_code": r.code})
+	err := json.NewEncoder(output).Encode(shared.Jmap{"type": api.ErrorResponse, "error": r.msg, "error_code": r.code, "error_name": api.ErrorNameFromCode(r.code)})
 
 	if err != nil {
 		return err
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Past this many tracked URLs, the tracking starts over
+const conditionalMaxEntries = 1024
+
+type conditionalEntry struct {
+	hash     string
+	modified time.Time
+}
+
+// Content hash and modification time of the last response sent for each
+// GET URL, used to answer conditional requests.
+var conditionalEntries = map[string]conditionalEntry{}
+var conditionalLock sync.Mutex
+
+// conditionalModified returns when the response to the URL last changed,
+// considering it changed now if its content hash differs from the last one.
+func conditionalModified(uri string, hash string) time.Time {
+	conditionalLock.Lock()
+	defer conditionalLock.Unlock()
+
+	entry, ok := conditionalEntries[uri]
+	if ok && entry.hash == hash {
+		return entry.modified
+	}
+
+	// HTTP dates have a one second resolution, make sure that two changes
+	// within the same second still get different modification times.
+	modified := time.Now().UTC().Truncate(time.Second)
+	if ok && !modified.After(entry.modified) {
+		modified = entry.modified.Add(time.Second)
+	}
+
+	if len(conditionalEntries) >= conditionalMaxEntries {
+		conditionalEntries = map[string]conditionalEntry{}
+	}
+
+	conditionalEntries[uri] = conditionalEntry{hash: hash, modified: modified}
+
+	return modified
+}
+
+// etagMatch returns whether an If-None-Match header matches the ETag.
+func etagMatch(header string, etag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		value = strings.TrimPrefix(value, "W/")
+		value = strings.Trim(value, "\"")
+
+		if value == "*" || value == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// conditionalResponse adds the Last-Modified header (as well as an ETag when
+// the handler didn't set one) to a successful GET response and replaces it
+// by a 304 if the client already has its current content.
+func conditionalResponse(r *http.Request, resp Response) Response {
+	syncResp, ok := resp.(*syncResponse)
+	if !ok || !syncResp.success || syncResp.location != "" {
+		return resp
+	}
+
+	hash, err := etagHash(syncResp.metadata)
+	if err != nil {
+		return resp
+	}
+
+	modified := conditionalModified(r.URL.RequestURI(), hash)
+
+	headers := map[string]string{"Last-Modified": modified.Format(http.TimeFormat)}
+
+	// Existing ETags only cover the writable fields, as used by If-Match,
+	// so If-None-Match is only honored against content ETags.
+	notModified := false
+	if syncResp.etag == nil {
+		headers["ETag"] = hash
+
+		match := r.Header.Get("If-None-Match")
+		if match != "" {
+			notModified = etagMatch(match, hash)
+		}
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err == nil && r.Header.Get("If-None-Match") == "" {
+		notModified = !modified.After(since)
+	}
+
+	if notModified {
+		return &notModifiedResponse{headers: headers}
+	}
+
+	if syncResp.headers == nil {
+		syncResp.headers = map[string]string{}
+	}
+
+	for key, value := range headers {
+		syncResp.headers[key] = value
+	}
+
+	return syncResp
+}
+
+type notModifiedResponse struct {
+	headers map[string]string
+}
+
+func (r *notModifiedResponse) Render(w http.ResponseWriter) error {
+	for key, value := range r.headers {
+		w.Header().Set(key, value)
+	}
+
+	// No body is sent along with a 304
+	w.Header().Del("Content-Type")
+	w.WriteHeader(http.StatusNotModified)
+
+	return nil
+}
+
+func (r *notModifiedResponse) String() string {
+	return "not modified"
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/pborman/uuid"
 
+	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
 )
@@ -45,7 +46,23 @@ func rsyncLocalCopy(source string, dest string, bwlimit string) (string, error)
 		dest)
 }
 
-func rsyncSendSetup(name string, path string, bwlimit string) (*exec.Cmd, net.Conn, io.ReadCloser, error) {
+// rsyncFeatureArgs returns the rsync arguments matching the features
+// negotiated in a migration header. Both ends of a transfer must use the
+// same ones.
+func rsyncFeatureArgs(features *migration.RsyncFeatures) []string {
+	args := []string{}
+	if features.GetCompress() {
+		args = append(args, "--compress")
+	}
+
+	if features.GetWholeFile() {
+		args = append(args, "--whole-file")
+	}
+
+	return args
+}
+
+func rsyncSendSetup(name string, path string, bwlimit string, rsyncArgs []string) (*exec.Cmd, net.Conn, io.ReadCloser, error) {
 	/*
 	 * The way rsync works, it invokes a subprocess that does the actual
 	 * talking (given to it by a -E argument). Since there isn't an easy
@@ -87,12 +104,15 @@ func rsyncSendSetup(name string, path string, bwlimit string) (*exec.Cmd, net.Co
 		bwlimit = "0"
 	}
 
-	cmd := exec.Command("rsync",
+	args := []string{
 		"-arvP",
 		"--devices",
 		"--numeric-ids",
 		"--partial",
 		"--sparse",
+	}
+	args = append(args, rsyncArgs...)
+	args = append(args,
 		path,
 		"localhost:/tmp/foo",
 		"-e",
@@ -100,6 +120,8 @@ func rsyncSendSetup(name string, path string, bwlimit string) (*exec.Cmd, net.Co
 		"--bwlimit",
 		bwlimit)
 
+	cmd := exec.Command("rsync", args...)
+
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		return nil, nil, nil, err
@@ -122,8 +144,8 @@ func rsyncSendSetup(name string, path string, bwlimit string) (*exec.Cmd, net.Co
 
 // RsyncSend sets up the sending half of an rsync, to recursively send the
 // directory pointed to by path over the websocket.
-func RsyncSend(name string, path string, conn *websocket.Conn, readWrapper func(io.ReadCloser) io.ReadCloser, bwlimit string) error {
-	cmd, dataSocket, stderr, err := rsyncSendSetup(name, path, bwlimit)
+func RsyncSend(name string, path string, conn *websocket.Conn, readWrapper func(io.ReadCloser) io.ReadCloser, bwlimit string, rsyncArgs []string) error {
+	cmd, dataSocket, stderr, err := rsyncSendSetup(name, path, bwlimit, rsyncArgs)
 	if err != nil {
 		return err
 	}
@@ -159,17 +181,20 @@ func RsyncSend(name string, path string, conn *websocket.Conn, readWrapper func(
 
 // RsyncRecv sets up the receiving half of the websocket to rsync (the other
 // half set up by RsyncSend), putting the contents in the directory specified
-// by path.
-func RsyncRecv(path string, conn *websocket.Conn, writeWrapper func(io.WriteCloser) io.WriteCloser) error {
-	cmd := exec.Command("rsync",
+// by path. rsyncArgs must match the ones given to the sending half.
+func RsyncRecv(path string, conn *websocket.Conn, writeWrapper func(io.WriteCloser) io.WriteCloser, rsyncArgs []string) error {
+	args := []string{
 		"--server",
 		"-vlogDtpre.iLsfx",
 		"--numeric-ids",
 		"--devices",
 		"--partial",
 		"--sparse",
-		".",
-		path)
+	}
+	args = append(args, rsyncArgs...)
+	args = append(args, ".", path)
+
+	cmd := exec.Command("rsync", args...)
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/schema"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// Objects whose writable fields are published as JSON schemas
+var schemaObjects = map[string]interface{}{
+	"certificate":    api.CertificatePut{},
+	"container":      api.ContainerPut{},
+	"image":          api.ImagePut{},
+	"network":        api.NetworkPut{},
+	"profile":        api.ProfilePut{},
+	"project":        api.ProjectPut{},
+	"server":         api.ServerPut{},
+	"storage-pool":   api.StoragePoolPut{},
+	"storage-volume": api.StorageVolumePut{},
+}
+
+func schemasGet(d *Daemon, r *http.Request) Response {
+	names := []string{}
+	for name := range schemaObjects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if d.isRecursionRequest(r) {
+		schemas := map[string]schema.Schema{}
+		for _, name := range names {
+			schemas[name] = schema.Generate(schemaObjects[name])
+		}
+
+		return SyncResponse(true, schemas)
+	}
+
+	urls := []string{}
+	for _, name := range names {
+		urls = append(urls, fmt.Sprintf("/%s/schemas/%s", version.APIVersion, name))
+	}
+
+	return SyncResponse(true, urls)
+}
+
+var schemasCmd = Command{name: "schemas", get: schemasGet}
+
+func schemaGet(d *Daemon, r *http.Request) Response {
+	obj, ok := schemaObjects[mux.Vars(r)["name"]]
+	if !ok {
+		return NotFound
+	}
+
+	return SyncResponse(true, schema.Generate(obj))
+}
+
+var schemaCmd = Command{name: "schemas/{name}", get: schemaGet}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"syscall"
+
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// shiftfsCanUse returns whether container root filesystems can be mounted
+// through shiftfs instead of being shifted on disk
+func shiftfsCanUse() bool {
+	// The mark has to be placed from the initial user namespace
+	if runningInUserns {
+		return false
+	}
+
+	// Older LXC doesn't run the pre-mount hook in the container's mount namespace
+	if !lxc.VersionAtLeast(2, 1, 0) {
+		return false
+	}
+
+	content, err := ioutil.ReadFile("/proc/filesystems")
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[len(fields)-1] == "shiftfs" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shiftfsUnmark removes the shiftfs mark the pre-start hook placed on a
+// container's rootfs, if it's still there after the container failed to start
+func shiftfsUnmark(rootfs string) error {
+	fs, err := filesystemDetect(rootfs)
+	if err != nil || fs != "shiftfs" {
+		return err
+	}
+
+	return syscall.Unmount(rootfs, syscall.MNT_DETACH)
+}
+
+// containerDiskIdmap returns the map the container's root filesystem should
+// be shifted to on disk, nil when it's left untouched
+func containerDiskIdmap(c container) (*shared.IdmapSet, error) {
+	if shiftfsAvailable {
+		return nil, nil
+	}
+
+	return c.IdmapSet()
+}
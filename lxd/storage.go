@@ -12,6 +12,7 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/ioprogress"
@@ -81,6 +82,8 @@ const (
 	filesystemSuperMagicXfs   = 0x58465342
 	filesystemSuperMagicNfs   = 0x6969
 	filesystemSuperMagicZfs   = 0x2fc12fc1
+
+	filesystemSuperMagicShiftfs = 0x6a656a62
 )
 
 // filesystemDetect returns the filesystem on which the passed-in path sits.
@@ -105,6 +108,8 @@ func filesystemDetect(path string) (string, error) {
 		return "xfs", nil
 	case filesystemSuperMagicNfs:
 		return "nfs", nil
+	case filesystemSuperMagicShiftfs:
+		return "shiftfs", nil
 	default:
 		logger.Debugf("Unknown backing filesystem type: 0x%x", fs.Type)
 		return string(fs.Type), nil
@@ -207,6 +212,7 @@ type storage interface {
 
 	ContainerSnapshotCreate(snapshotContainer container, sourceContainer container) error
 	ContainerSnapshotDelete(snapshotContainer container) error
+	ContainerSnapshotGetUsage(snapshotContainer container) (int64, error)
 	ContainerSnapshotRename(snapshotContainer container, newName string) error
 	ContainerSnapshotStart(container container) (bool, error)
 	ContainerSnapshotStop(container container) (bool, error)
@@ -214,6 +220,16 @@ type storage interface {
 	// For use in migrating snapshots.
 	ContainerSnapshotCreateEmpty(snapshotContainer container) error
 
+	// Functions dealing with optimized container backups.
+	// ContainerBackupDump stores the backend's native send streams for
+	// the snapshots (oldest first) and the container in the target
+	// directory as snapshots/<name>.bin and container.bin.
+	ContainerBackupDump(container container, snapshots []container, target string) error
+	// ContainerBackupLoad restores the streams written by
+	// ContainerBackupDump into a freshly created container and empty
+	// snapshots.
+	ContainerBackupLoad(container container, snapshots []container, source string) error
+
 	// Functions dealing with image storage volumes.
 	ImageCreate(fingerprint string) error
 	ImageDelete(fingerprint string) error
@@ -221,7 +237,7 @@ type storage interface {
 	ImageUmount(fingerprint string) (bool, error)
 
 	// Functions dealing with migration.
-	MigrationType() MigrationFSType
+	MigrationType() migration.MigrationFSType
 	// Does this storage backend preserve inodes when it is moved across LXD
 	// hosts?
 	PreservesInodes() bool
@@ -244,7 +260,28 @@ type storage interface {
 	// already present on the target instance as an exercise for the
 	// enterprising developer.
 	MigrationSource(container container, containerOnly bool) (MigrationStorageSourceDriver, error)
-	MigrationSink(live bool, container container, objects []*Snapshot, conn *websocket.Conn, srcIdmap *shared.IdmapSet, op *operation, containerOnly bool) error
+	MigrationSink(live bool, container container, objects []*migration.Snapshot, conn *websocket.Conn, srcIdmap *shared.IdmapSet, op *operation, containerOnly bool, compression string, rsyncArgs []string) error
+}
+
+// storageDriversProbe returns the storage drivers whose tools are available,
+// along with their version.
+func storageDriversProbe() map[string]string {
+	drivers := map[string]string{}
+	for _, driver := range supportedStoragePoolDrivers {
+		sCore, err := storageCoreInit(driver)
+		if err != nil {
+			// The directory driver doesn't need any tool
+			if driver == "dir" {
+				drivers[driver] = ""
+			}
+
+			continue
+		}
+
+		drivers[driver] = sCore.GetStorageTypeVersion()
+	}
+
+	return drivers
 }
 
 func storageCoreInit(driver string) (storage, error) {
@@ -572,7 +609,7 @@ func deleteSnapshotMountpoint(snapshotMountpoint string, snapshotsSymlinkTarget
 // ShiftIfNecessary sets the volatile.last_state.idmap key to the idmap last
 // used by the container.
 func ShiftIfNecessary(container container, srcIdmap *shared.IdmapSet) error {
-	dstIdmap, err := container.IdmapSet()
+	dstIdmap, err := containerDiskIdmap(container)
 	if err != nil {
 		return err
 	}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
@@ -1092,6 +1093,12 @@ func (s *storageBtrfs) ContainerGetUsage(container container) (int64, error) {
 	return s.btrfsPoolVolumeQGroupUsage(container.Path())
 }
 
+// ContainerSnapshotGetUsage returns the space exclusively used by the
+// snapshot subvolume.
+func (s *storageBtrfs) ContainerSnapshotGetUsage(snapshotContainer container) (int64, error) {
+	return s.btrfsPoolVolumeQGroupUsage(getSnapshotMountPoint(s.pool.Name, snapshotContainer.Name()))
+}
+
 func (s *storageBtrfs) ContainerSnapshotCreate(snapshotContainer container, sourceContainer container) error {
 	logger.Debugf("Creating BTRFS storage volume for snapshot \"%s\" on storage pool \"%s\".", s.volume.Name, s.pool.Name)
 
@@ -1285,6 +1292,115 @@ func (s *storageBtrfs) ContainerSnapshotCreateEmpty(snapshotContainer container)
 	return nil
 }
 
+func (s *storageBtrfs) ContainerBackupDump(container container, snapshots []container, target string) error {
+	if runningInUserns {
+		return fmt.Errorf("BTRFS send isn't available when running in a user namespace")
+	}
+
+	err := os.MkdirAll(filepath.Join(target, "snapshots"), 0700)
+	if err != nil {
+		return err
+	}
+
+	// Send the snapshots, each one relative to the previous one
+	prev := ""
+	for _, snap := range snapshots {
+		_, snapOnlyName, _ := containerGetParentAndSnapshotName(snap.Name())
+		snapMntPoint := getSnapshotMountPoint(s.pool.Name, snap.Name())
+
+		args := []string{"send", snapMntPoint}
+		if prev != "" {
+			args = append(args, "-p", prev)
+		}
+
+		err := storageSendToFile(filepath.Join(target, "snapshots", fmt.Sprintf("%s.bin", snapOnlyName)), "btrfs", args...)
+		if err != nil {
+			return err
+		}
+
+		prev = snapMntPoint
+	}
+
+	// Send the current state of the container through a read-only snapshot
+	containersPath := getContainerMountPoint(s.pool.Name, "")
+	tmpContainerMntPoint, err := ioutil.TempDir(containersPath, container.Name())
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpContainerMntPoint)
+
+	err = os.Chmod(tmpContainerMntPoint, 0700)
+	if err != nil {
+		return err
+	}
+
+	backupSendSnapshot := fmt.Sprintf("%s/.backup-send", tmpContainerMntPoint)
+	containerMntPoint := getContainerMountPoint(s.pool.Name, container.Name())
+	err = s.btrfsPoolVolumesSnapshot(containerMntPoint, backupSendSnapshot, true)
+	if err != nil {
+		return err
+	}
+	defer btrfsSubVolumesDelete(backupSendSnapshot)
+
+	args := []string{"send", backupSendSnapshot}
+	if prev != "" {
+		args = append(args, "-p", prev)
+	}
+
+	return storageSendToFile(filepath.Join(target, "container.bin"), "btrfs", args...)
+}
+
+func (s *storageBtrfs) ContainerBackupLoad(container container, snapshots []container, source string) error {
+	if runningInUserns {
+		return fmt.Errorf("BTRFS receive isn't available when running in a user namespace")
+	}
+
+	// Receive a stream next to its target and move it into place
+	btrfsRecv := func(stream string, receivedName string, tmpParent string, targetPath string, readonly bool) error {
+		tmpPath, err := ioutil.TempDir(tmpParent, container.Name())
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpPath)
+
+		err = os.Chmod(tmpPath, 0700)
+		if err != nil {
+			return err
+		}
+
+		err = storageReceiveFromFile(stream, "btrfs", "receive", "-e", tmpPath)
+		if err != nil {
+			return err
+		}
+
+		receivedSnapshot := fmt.Sprintf("%s/%s", tmpPath, receivedName)
+		defer btrfsSubVolumesDelete(receivedSnapshot)
+
+		// Remove the pre-created subvolume
+		err = btrfsSubVolumesDelete(targetPath)
+		if err != nil {
+			return err
+		}
+
+		return s.btrfsPoolVolumesSnapshot(receivedSnapshot, targetPath, readonly)
+	}
+
+	for _, snap := range snapshots {
+		_, snapOnlyName, _ := containerGetParentAndSnapshotName(snap.Name())
+		snapshotMntPoint := getSnapshotMountPoint(s.pool.Name, snap.Name())
+
+		err := btrfsRecv(filepath.Join(source, "snapshots", fmt.Sprintf("%s.bin", snapOnlyName)), snapOnlyName,
+			getSnapshotMountPoint(s.pool.Name, container.Name()), snapshotMntPoint, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	containerMntPoint := getContainerMountPoint(s.pool.Name, container.Name())
+	return btrfsRecv(filepath.Join(source, "container.bin"), ".backup-send",
+		getContainerMountPoint(s.pool.Name, ""), containerMntPoint, false)
+}
+
 func (s *storageBtrfs) ImageCreate(fingerprint string) error {
 	logger.Debugf("Creating BTRFS storage volume for image \"%s\" on storage pool \"%s\".", fingerprint, s.pool.Name)
 
@@ -1724,7 +1840,7 @@ func (s *btrfsMigrationSourceDriver) Snapshots() []container {
 	return s.snapshots
 }
 
-func (s *btrfsMigrationSourceDriver) send(conn *websocket.Conn, btrfsPath string, btrfsParent string, readWrapper func(io.ReadCloser) io.ReadCloser) error {
+func (s *btrfsMigrationSourceDriver) send(conn *websocket.Conn, btrfsPath string, btrfsParent string, compression string, readWrapper func(io.ReadCloser) io.ReadCloser) error {
 	args := []string{"send", btrfsPath}
 	if btrfsParent != "" {
 		args = append(args, "-p", btrfsParent)
@@ -1752,8 +1868,25 @@ func (s *btrfsMigrationSourceDriver) send(conn *websocket.Conn, btrfsPath string
 		return err
 	}
 
+	var compressor *exec.Cmd
+	if compression != "" {
+		readPipe, compressor, err = migrationCompressReader(readPipe, compression)
+		if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+
 	<-shared.WebsocketSendStream(conn, readPipe, 4*1024*1024)
 
+	if compressor != nil {
+		err = compressor.Wait()
+		if err != nil {
+			logger.Errorf("Problem compressing btrfs send stream: %s.", err)
+		}
+	}
+
 	output, err := ioutil.ReadAll(stderr)
 	if err != nil {
 		logger.Errorf("Problem reading btrfs send stderr: %s.", err)
@@ -1767,7 +1900,7 @@ func (s *btrfsMigrationSourceDriver) send(conn *websocket.Conn, btrfsPath string
 	return err
 }
 
-func (s *btrfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn, op *operation, bwlimit string, containerOnly bool) error {
+func (s *btrfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn, op *operation, bwlimit string, rsyncArgs []string, compression string, containerOnly bool) error {
 	_, containerPool := s.container.Storage().GetContainerPoolInfo()
 	containerName := s.container.Name()
 	containersPath := getContainerMountPoint(containerPool, "")
@@ -1800,7 +1933,7 @@ func (s *btrfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn, op *
 		defer btrfsSubVolumesDelete(migrationSendSnapshot)
 
 		wrapper := StorageProgressReader(op, "fs_progress", containerName)
-		return s.send(conn, migrationSendSnapshot, "", wrapper)
+		return s.send(conn, migrationSendSnapshot, "", compression, wrapper)
 	}
 
 	if !containerOnly {
@@ -1812,7 +1945,7 @@ func (s *btrfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn, op *
 
 			snapMntPoint := getSnapshotMountPoint(containerPool, snap.Name())
 			wrapper := StorageProgressReader(op, "fs_progress", snap.Name())
-			if err := s.send(conn, snapMntPoint, prev, wrapper); err != nil {
+			if err := s.send(conn, snapMntPoint, prev, compression, wrapper); err != nil {
 				return err
 			}
 		}
@@ -1843,10 +1976,10 @@ func (s *btrfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn, op *
 	}
 
 	wrapper := StorageProgressReader(op, "fs_progress", containerName)
-	return s.send(conn, migrationSendSnapshot, btrfsParent, wrapper)
+	return s.send(conn, migrationSendSnapshot, btrfsParent, compression, wrapper)
 }
 
-func (s *btrfsMigrationSourceDriver) SendAfterCheckpoint(conn *websocket.Conn, bwlimit string) error {
+func (s *btrfsMigrationSourceDriver) SendAfterCheckpoint(conn *websocket.Conn, bwlimit string, rsyncArgs []string, compression string) error {
 	tmpPath := containerPath(fmt.Sprintf("%s/.migration-send", s.container.Name()), true)
 	err := os.MkdirAll(tmpPath, 0700)
 	if err != nil {
@@ -1859,7 +1992,7 @@ func (s *btrfsMigrationSourceDriver) SendAfterCheckpoint(conn *websocket.Conn, b
 		return err
 	}
 
-	return s.send(conn, s.stoppedSnapName, s.runningSnapName, nil)
+	return s.send(conn, s.stoppedSnapName, s.runningSnapName, compression, nil)
 }
 
 func (s *btrfsMigrationSourceDriver) Cleanup() {
@@ -1872,12 +2005,12 @@ func (s *btrfsMigrationSourceDriver) Cleanup() {
 	}
 }
 
-func (s *storageBtrfs) MigrationType() MigrationFSType {
+func (s *storageBtrfs) MigrationType() migration.MigrationFSType {
 	if runningInUserns {
-		return MigrationFSType_RSYNC
+		return migration.MigrationFSType_RSYNC
 	}
 
-	return MigrationFSType_BTRFS
+	return migration.MigrationFSType_BTRFS
 }
 
 func (s *storageBtrfs) PreservesInodes() bool {
@@ -1923,9 +2056,9 @@ func (s *storageBtrfs) MigrationSource(c container, containerOnly bool) (Migrati
 	return driver, nil
 }
 
-func (s *storageBtrfs) MigrationSink(live bool, container container, snapshots []*Snapshot, conn *websocket.Conn, srcIdmap *shared.IdmapSet, op *operation, containerOnly bool) error {
+func (s *storageBtrfs) MigrationSink(live bool, container container, snapshots []*migration.Snapshot, conn *websocket.Conn, srcIdmap *shared.IdmapSet, op *operation, containerOnly bool, compression string, rsyncArgs []string) error {
 	if runningInUserns {
-		return rsyncMigrationSink(live, container, snapshots, conn, srcIdmap, op, containerOnly)
+		return rsyncMigrationSink(live, container, snapshots, conn, srcIdmap, op, containerOnly, compression, rsyncArgs)
 	}
 
 	btrfsRecv := func(snapName string, btrfsPath string, targetPath string, isSnapshot bool, writeWrapper func(io.WriteCloser) io.WriteCloser) error {
@@ -1959,8 +2092,26 @@ func (s *storageBtrfs) MigrationSink(live bool, container container, snapshots [
 			writePipe = writeWrapper(stdin)
 		}
 
+		var decompressor io.WriteCloser
+		if compression != "" {
+			decompressor, err = migrationDecompressWriter(writePipe, compression)
+			if err != nil {
+				cmd.Process.Kill()
+				cmd.Wait()
+				return err
+			}
+			writePipe = decompressor
+		}
+
 		<-shared.WebsocketRecvStream(writePipe, conn)
 
+		if decompressor != nil {
+			err = decompressor.Close()
+			if err != nil {
+				logger.Errorf("Problem decompressing btrfs receive stream: %s.", err)
+			}
+		}
+
 		output, err := ioutil.ReadAll(stderr)
 		if err != nil {
 			logger.Debugf("Problem reading btrfs receive stderr %s.", err)
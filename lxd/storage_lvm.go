@@ -8,6 +8,7 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
@@ -1337,6 +1338,16 @@ func (s *storageLvm) ContainerGetUsage(container container) (int64, error) {
 	return -1, fmt.Errorf("the LVM container backend doesn't support quotas")
 }
 
+// ContainerSnapshotGetUsage returns the space used by the snapshot LV, based
+// on its allocated data percentage.
+func (s *storageLvm) ContainerSnapshotGetUsage(snapshotContainer container) (int64, error) {
+	snapshotLvmName := containerNameToLVName(snapshotContainer.Name())
+	poolName := s.getOnDiskPoolName()
+	snapshotLvmPath := getLvmDevPath(poolName, storagePoolVolumeAPIEndpointContainers, snapshotLvmName)
+
+	return lvmGetLVUsage(snapshotLvmPath)
+}
+
 func (s *storageLvm) ContainerSnapshotCreate(snapshotContainer container, sourceContainer container) error {
 	logger.Debugf("Creating LVM storage volume for snapshot \"%s\" on storage pool \"%s\".", s.volume.Name, s.pool.Name)
 
@@ -1484,6 +1495,14 @@ func (s *storageLvm) ContainerSnapshotCreateEmpty(snapshotContainer container) e
 	return nil
 }
 
+func (s *storageLvm) ContainerBackupDump(container container, snapshots []container, target string) error {
+	return fmt.Errorf("the LVM container backend doesn't support optimized backups")
+}
+
+func (s *storageLvm) ContainerBackupLoad(container container, snapshots []container, source string) error {
+	return fmt.Errorf("the LVM container backend doesn't support optimized backups")
+}
+
 func (s *storageLvm) ImageCreate(fingerprint string) error {
 	logger.Debugf("Creating LVM storage volume for image \"%s\" on storage pool \"%s\".", fingerprint, s.pool.Name)
 
@@ -1638,8 +1657,8 @@ func (s *storageLvm) ImageUmount(fingerprint string) (bool, error) {
 	return true, nil
 }
 
-func (s *storageLvm) MigrationType() MigrationFSType {
-	return MigrationFSType_RSYNC
+func (s *storageLvm) MigrationType() migration.MigrationFSType {
+	return migration.MigrationFSType_RSYNC
 }
 
 func (s *storageLvm) PreservesInodes() bool {
@@ -1650,6 +1669,6 @@ func (s *storageLvm) MigrationSource(container container, containerOnly bool) (M
 	return rsyncMigrationSource(container, containerOnly)
 }
 
-func (s *storageLvm) MigrationSink(live bool, container container, snapshots []*Snapshot, conn *websocket.Conn, srcIdmap *shared.IdmapSet, op *operation, containerOnly bool) error {
-	return rsyncMigrationSink(live, container, snapshots, conn, srcIdmap, op, containerOnly)
+func (s *storageLvm) MigrationSink(live bool, container container, snapshots []*migration.Snapshot, conn *websocket.Conn, srcIdmap *shared.IdmapSet, op *operation, containerOnly bool, compression string, rsyncArgs []string) error {
+	return rsyncMigrationSink(live, container, snapshots, conn, srcIdmap, op, containerOnly, compression, rsyncArgs)
 }
@@ -569,6 +569,30 @@ func storageLVExists(lvName string) (bool, error) {
 	return true, nil
 }
 
+func lvmGetLVUsage(lvPath string) (int64, error) {
+	msg, err := shared.TryRunCommand("lvs", "--noheadings", "-o", "size,data_percent", "--nosuffix", "--units", "b", lvPath)
+	if err != nil {
+		return -1, fmt.Errorf("failed to retrieve usage of logical volume: %s: %s", string(msg), err)
+	}
+
+	fields := strings.Fields(msg)
+	if len(fields) != 2 {
+		return -1, fmt.Errorf("no usage information for logical volume \"%s\"", lvPath)
+	}
+
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return -1, err
+	}
+
+	percent, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return -1, err
+	}
+
+	return int64(float64(size) * percent / 100), nil
+}
+
 func lvmGetLVSize(lvPath string) (string, error) {
 	msg, err := shared.TryRunCommand("lvs", "--noheadings", "-o", "size", "--nosuffix", "--units", "b", lvPath)
 	if err != nil {
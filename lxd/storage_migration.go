@@ -2,9 +2,12 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os/exec"
 
 	"github.com/gorilla/websocket"
 
+	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/shared"
 )
@@ -18,14 +21,14 @@ type MigrationStorageSourceDriver interface {
 	/* send any bits of the container/snapshots that are possible while the
 	 * container is still running.
 	 */
-	SendWhileRunning(conn *websocket.Conn, op *operation, bwlimit string, containerOnly bool) error
+	SendWhileRunning(conn *websocket.Conn, op *operation, bwlimit string, rsyncArgs []string, compression string, containerOnly bool) error
 
 	/* send the final bits (e.g. a final delta snapshot for zfs, btrfs, or
 	 * do a final rsync) of the fs after the container has been
 	 * checkpointed. This will only be called when a container is actually
 	 * being live migrated.
 	 */
-	SendAfterCheckpoint(conn *websocket.Conn, bwlimit string) error
+	SendAfterCheckpoint(conn *websocket.Conn, bwlimit string, rsyncArgs []string, compression string) error
 
 	/* Called after either success or failure of a migration, can be used
 	 * to clean up any temporary snapshots, etc.
@@ -33,6 +36,75 @@ type MigrationStorageSourceDriver interface {
 	Cleanup()
 }
 
+// Compression algorithms which may be negotiated for the optimized (zfs and
+// btrfs) migration streams. rsync being a two way protocol, its transfers
+// can only use rsync's own compression (see rsyncFeatureArgs).
+var migrationCompressionAlgorithms = []string{"bzip2", "gzip", "lzma", "xz", "zstd"}
+
+// migrationCompressionSupported returns whether streams compressed with the
+// given algorithm can be produced and consumed by this host.
+func migrationCompressionSupported(compression string) bool {
+	if !shared.StringInSlice(compression, migrationCompressionAlgorithms) {
+		return false
+	}
+
+	_, err := exec.LookPath(compression)
+	return err == nil
+}
+
+func migrationCompressionArgs(compression string, decompress bool) []string {
+	args := []string{"-c"}
+	if decompress {
+		args = append(args, "-d")
+	} else if shared.StringInSlice(compression, []string{"xz", "zstd"}) {
+		args = append(args, "-T0")
+	}
+
+	if compression == "zstd" {
+		args = append(args, "-q")
+	}
+
+	return args
+}
+
+// migrationCompressReader starts compressing the stream read from r, the
+// command must be waited for once its output has been fully read.
+func migrationCompressReader(r io.Reader, compression string) (io.ReadCloser, *exec.Cmd, error) {
+	cmd := exec.Command(compression, migrationCompressionArgs(compression, false)...)
+	cmd.Stdin = r
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stdout, cmd, nil
+}
+
+// migrationDecompressWriter returns a writer decompressing what is written
+// to it into w. It must be closed once the whole stream has been written.
+func migrationDecompressWriter(w io.Writer, compression string) (io.WriteCloser, error) {
+	cmd := exec.Command(compression, migrationCompressionArgs(compression, true)...)
+	cmd.Stdout = w
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cmdWriter{cmd: cmd, stdin: stdin}, nil
+}
+
 type rsyncStorageSourceDriver struct {
 	container container
 	snapshots []container
@@ -42,7 +114,7 @@ func (s rsyncStorageSourceDriver) Snapshots() []container {
 	return s.snapshots
 }
 
-func (s rsyncStorageSourceDriver) SendWhileRunning(conn *websocket.Conn, op *operation, bwlimit string, containerOnly bool) error {
+func (s rsyncStorageSourceDriver) SendWhileRunning(conn *websocket.Conn, op *operation, bwlimit string, rsyncArgs []string, compression string, containerOnly bool) error {
 	ctName, _, _ := containerGetParentAndSnapshotName(s.container.Name())
 
 	if !containerOnly {
@@ -57,7 +129,7 @@ func (s rsyncStorageSourceDriver) SendWhileRunning(conn *websocket.Conn, op *ope
 
 			path := send.Path()
 			wrapper := StorageProgressReader(op, "fs_progress", send.Name())
-			err = RsyncSend(ctName, shared.AddSlash(path), conn, wrapper, bwlimit)
+			err = RsyncSend(ctName, shared.AddSlash(path), conn, wrapper, bwlimit, rsyncArgs)
 			if err != nil {
 				return err
 			}
@@ -65,13 +137,13 @@ func (s rsyncStorageSourceDriver) SendWhileRunning(conn *websocket.Conn, op *ope
 	}
 
 	wrapper := StorageProgressReader(op, "fs_progress", s.container.Name())
-	return RsyncSend(ctName, shared.AddSlash(s.container.Path()), conn, wrapper, bwlimit)
+	return RsyncSend(ctName, shared.AddSlash(s.container.Path()), conn, wrapper, bwlimit, rsyncArgs)
 }
 
-func (s rsyncStorageSourceDriver) SendAfterCheckpoint(conn *websocket.Conn, bwlimit string) error {
+func (s rsyncStorageSourceDriver) SendAfterCheckpoint(conn *websocket.Conn, bwlimit string, rsyncArgs []string, compression string) error {
 	ctName, _, _ := containerGetParentAndSnapshotName(s.container.Name())
 	// resync anything that changed between our first send and the checkpoint
-	return RsyncSend(ctName, shared.AddSlash(s.container.Path()), conn, nil, bwlimit)
+	return RsyncSend(ctName, shared.AddSlash(s.container.Path()), conn, nil, bwlimit, rsyncArgs)
 }
 
 func (s rsyncStorageSourceDriver) Cleanup() {
@@ -91,7 +163,7 @@ func rsyncMigrationSource(c container, containerOnly bool) (MigrationStorageSour
 	return rsyncStorageSourceDriver{c, snapshots}, nil
 }
 
-func snapshotProtobufToContainerArgs(containerName string, snap *Snapshot) containerArgs {
+func snapshotProtobufToContainerArgs(containerName string, snap *migration.Snapshot) containerArgs {
 	config := map[string]string{}
 
 	for _, ent := range snap.LocalConfig {
@@ -121,7 +193,7 @@ func snapshotProtobufToContainerArgs(containerName string, snap *Snapshot) conta
 	}
 }
 
-func rsyncMigrationSink(live bool, container container, snapshots []*Snapshot, conn *websocket.Conn, srcIdmap *shared.IdmapSet, op *operation, containerOnly bool) error {
+func rsyncMigrationSink(live bool, container container, snapshots []*migration.Snapshot, conn *websocket.Conn, srcIdmap *shared.IdmapSet, op *operation, containerOnly bool, compression string, rsyncArgs []string) error {
 	ourStart, err := container.StorageStart()
 	if err != nil {
 		return err
@@ -168,7 +240,7 @@ func rsyncMigrationSink(live bool, container container, snapshots []*Snapshot, c
 				}
 
 				wrapper := StorageProgressWriter(op, "fs_progress", s.Name())
-				if err := RsyncRecv(shared.AddSlash(s.Path()), conn, wrapper); err != nil {
+				if err := RsyncRecv(shared.AddSlash(s.Path()), conn, wrapper, rsyncArgs); err != nil {
 					return err
 				}
 
@@ -180,7 +252,7 @@ func rsyncMigrationSink(live bool, container container, snapshots []*Snapshot, c
 		}
 
 		wrapper := StorageProgressWriter(op, "fs_progress", container.Name())
-		err = RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper)
+		err = RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper, rsyncArgs)
 		if err != nil {
 			return err
 		}
@@ -202,7 +274,7 @@ func rsyncMigrationSink(live bool, container container, snapshots []*Snapshot, c
 				}
 
 				wrapper := StorageProgressWriter(op, "fs_progress", snap.GetName())
-				err := RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper)
+				err := RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper, rsyncArgs)
 				if err != nil {
 					return err
 				}
@@ -220,7 +292,7 @@ func rsyncMigrationSink(live bool, container container, snapshots []*Snapshot, c
 		}
 
 		wrapper := StorageProgressWriter(op, "fs_progress", container.Name())
-		err = RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper)
+		err = RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper, rsyncArgs)
 		if err != nil {
 			return err
 		}
@@ -229,7 +301,7 @@ func rsyncMigrationSink(live bool, container container, snapshots []*Snapshot, c
 	if live {
 		/* now receive the final sync */
 		wrapper := StorageProgressWriter(op, "fs_progress", container.Name())
-		err := RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper)
+		err := RsyncRecv(shared.AddSlash(container.Path()), conn, wrapper, rsyncArgs)
 		if err != nil {
 			return err
 		}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
@@ -164,6 +165,12 @@ func (s *storageMock) ContainerSnapshotCreate(
 
 	return nil
 }
+func (s *storageMock) ContainerSnapshotGetUsage(
+	snapshotContainer container) (int64, error) {
+
+	return 0, nil
+}
+
 func (s *storageMock) ContainerSnapshotDelete(
 	snapshotContainer container) error {
 
@@ -188,6 +195,14 @@ func (s *storageMock) ContainerSnapshotCreateEmpty(snapshotContainer container)
 	return nil
 }
 
+func (s *storageMock) ContainerBackupDump(container container, snapshots []container, target string) error {
+	return nil
+}
+
+func (s *storageMock) ContainerBackupLoad(container container, snapshots []container, source string) error {
+	return nil
+}
+
 func (s *storageMock) ImageCreate(fingerprint string) error {
 	return nil
 }
@@ -204,8 +219,8 @@ func (s *storageMock) ImageUmount(fingerprint string) (bool, error) {
 	return true, nil
 }
 
-func (s *storageMock) MigrationType() MigrationFSType {
-	return MigrationFSType_RSYNC
+func (s *storageMock) MigrationType() migration.MigrationFSType {
+	return migration.MigrationFSType_RSYNC
 }
 
 func (s *storageMock) PreservesInodes() bool {
@@ -215,6 +230,6 @@ func (s *storageMock) PreservesInodes() bool {
 func (s *storageMock) MigrationSource(container container, containerOnly bool) (MigrationStorageSourceDriver, error) {
 	return nil, fmt.Errorf("not implemented")
 }
-func (s *storageMock) MigrationSink(live bool, container container, snapshots []*Snapshot, conn *websocket.Conn, srcIdmap *shared.IdmapSet, op *operation, containerOnly bool) error {
+func (s *storageMock) MigrationSink(live bool, container container, snapshots []*migration.Snapshot, conn *websocket.Conn, srcIdmap *shared.IdmapSet, op *operation, containerOnly bool, compression string, rsyncArgs []string) error {
 	return nil
 }
@@ -48,10 +48,13 @@ func (s *storageShared) shiftRootfs(c container) error {
 		return fmt.Errorf("IdmapSet of container '%s' is nil", c.Name())
 	}
 
-	err = idmapset.ShiftRootfs(rpath)
-	if err != nil {
-		logger.Debugf("Shift of rootfs %s failed: %s", rpath, err)
-		return err
+	// Shiftfs takes care of the mapping at mount time
+	if !shiftfsAvailable {
+		err = idmapset.ShiftRootfs(rpath)
+		if err != nil {
+			logger.Debugf("Shift of rootfs %s failed: %s", rpath, err)
+			return err
+		}
 	}
 
 	/* Set an acl so the container root can descend the container dir */
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bytes"
 	"database/sql"
+	"fmt"
 	"os"
+	"os/exec"
 	"strings"
 	"syscall"
 	"time"
@@ -179,3 +182,47 @@ func lxdUsesPool(db *sql.DB, onDiskPoolName string, driver string, onDiskPropert
 
 	return false, "", nil
 }
+
+// storageSendToFile runs a storage send command (e.g. "zfs send") and stores
+// the resulting stream in the given file.
+func storageSendToFile(path string, name string, args ...string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stderr := bytes.Buffer{}
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = f
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("Failed to run: %s %s: %s", name, strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// storageReceiveFromFile feeds a stream stored in the given file to a storage
+// receive command (e.g. "zfs receive").
+func storageReceiveFromFile(path string, name string, args ...string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stderr := bytes.Buffer{}
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = f
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("Failed to run: %s %s: %s", name, strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
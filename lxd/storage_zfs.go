@@ -14,6 +14,7 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
@@ -1296,6 +1297,20 @@ func (s *storageZfs) ContainerGetUsage(container container) (int64, error) {
 	return valueInt, nil
 }
 
+// ContainerSnapshotGetUsage returns the space which would be freed by
+// deleting the ZFS snapshot.
+func (s *storageZfs) ContainerSnapshotGetUsage(snapshotContainer container) (int64, error) {
+	sourceName, snapOnlyName, _ := containerGetParentAndSnapshotName(snapshotContainer.Name())
+	snapshotDataset := fmt.Sprintf("containers/%s@snapshot-%s", sourceName, snapOnlyName)
+
+	value, err := s.zfsFilesystemEntityPropertyGet(snapshotDataset, "used", true)
+	if err != nil {
+		return -1, err
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
 func (s *storageZfs) ContainerSnapshotCreate(snapshotContainer container, sourceContainer container) error {
 	snapshotContainerName := snapshotContainer.Name()
 	logger.Debugf("Creating ZFS storage volume for snapshot \"%s\" on storage pool \"%s\".", snapshotContainerName, s.pool.Name)
@@ -1519,6 +1534,115 @@ func (s *storageZfs) ContainerSnapshotCreateEmpty(snapshotContainer container) e
 	return nil
 }
 
+func (s *storageZfs) ContainerBackupDump(container container, snapshots []container, target string) error {
+	poolName := s.getOnDiskPoolName()
+	fs := fmt.Sprintf("containers/%s", container.Name())
+
+	err := os.MkdirAll(filepath.Join(target, "snapshots"), 0700)
+	if err != nil {
+		return err
+	}
+
+	// Send the snapshots, each one relative to the previous one
+	prev := ""
+	for _, snap := range snapshots {
+		_, snapOnlyName, _ := containerGetParentAndSnapshotName(snap.Name())
+		dataset := fmt.Sprintf("%s/%s@snapshot-%s", poolName, fs, snapOnlyName)
+
+		args := []string{"send", dataset}
+		if prev != "" {
+			args = append(args, "-i", prev)
+		}
+
+		err := storageSendToFile(filepath.Join(target, "snapshots", fmt.Sprintf("%s.bin", snapOnlyName)), "zfs", args...)
+		if err != nil {
+			return err
+		}
+
+		prev = dataset
+	}
+
+	// Send the current state of the container through a temporary snapshot
+	backupSnapName := fmt.Sprintf("backup-send-%s", uuid.NewRandom().String())
+	err = s.zfsPoolVolumeSnapshotCreate(fs, backupSnapName)
+	if err != nil {
+		return err
+	}
+	defer s.zfsPoolVolumeSnapshotDestroy(fs, backupSnapName)
+
+	args := []string{"send", fmt.Sprintf("%s/%s@%s", poolName, fs, backupSnapName)}
+	if prev != "" {
+		args = append(args, "-i", prev)
+	}
+
+	return storageSendToFile(filepath.Join(target, "container.bin"), "zfs", args...)
+}
+
+func (s *storageZfs) ContainerBackupLoad(container container, snapshots []container, source string) error {
+	poolName := s.getOnDiskPoolName()
+	zfsName := fmt.Sprintf("containers/%s", container.Name())
+
+	// zfs receive needs the target filesystem unmounted
+	containerMntPoint := getContainerMountPoint(s.pool.Name, container.Name())
+	err := s.zfsPoolVolumeUmount(zfsName, containerMntPoint)
+	if err != nil {
+		return err
+	}
+
+	if len(snapshots) > 0 {
+		snapshotMntPointSymlinkTarget := shared.VarPath("storage-pools", s.pool.Name, "snapshots", container.Name())
+		snapshotMntPointSymlink := shared.VarPath("snapshots", container.Name())
+		if !shared.PathExists(snapshotMntPointSymlink) {
+			err := os.Symlink(snapshotMntPointSymlinkTarget, snapshotMntPointSymlink)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, snap := range snapshots {
+		_, snapOnlyName, _ := containerGetParentAndSnapshotName(snap.Name())
+		dataset := fmt.Sprintf("%s/%s@snapshot-%s", poolName, zfsName, snapOnlyName)
+
+		err := storageReceiveFromFile(filepath.Join(source, "snapshots", fmt.Sprintf("%s.bin", snapOnlyName)), "zfs", "receive", "-F", "-u", dataset)
+		if err != nil {
+			return err
+		}
+
+		snapshotMntPoint := getSnapshotMountPoint(s.pool.Name, snap.Name())
+		if !shared.PathExists(snapshotMntPoint) {
+			err := os.MkdirAll(snapshotMntPoint, 0700)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = storageReceiveFromFile(filepath.Join(source, "container.bin"), "zfs", "receive", "-F", "-u", fmt.Sprintf("%s/%s", poolName, zfsName))
+	if err != nil {
+		return err
+	}
+
+	// Remove the temporary snapshot the container was sent through
+	zfsSnapshots, err := s.zfsPoolListSnapshots(zfsName)
+	if err != nil {
+		return err
+	}
+
+	for _, snap := range zfsSnapshots {
+		if strings.HasPrefix(snap, "snapshot-") {
+			continue
+		}
+
+		err := s.zfsPoolVolumeSnapshotDestroy(zfsName, snap)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.zfsPoolVolumeMount(zfsName)
+}
+
 // - create temporary directory ${LXD_DIR}/images/lxd_images_
 // - create new zfs volume images/<fingerprint>
 // - mount the zfs volume on ${LXD_DIR}/images/lxd_images_
@@ -2481,7 +2605,7 @@ func (s *zfsMigrationSourceDriver) Snapshots() []container {
 	return s.snapshots
 }
 
-func (s *zfsMigrationSourceDriver) send(conn *websocket.Conn, zfsName string, zfsParent string, readWrapper func(io.ReadCloser) io.ReadCloser) error {
+func (s *zfsMigrationSourceDriver) send(conn *websocket.Conn, zfsName string, zfsParent string, compression string, readWrapper func(io.ReadCloser) io.ReadCloser) error {
 	sourceParentName, _, _ := containerGetParentAndSnapshotName(s.container.Name())
 	poolName := s.zfs.getOnDiskPoolName()
 	args := []string{"send", fmt.Sprintf("%s/containers/%s@%s", poolName, sourceParentName, zfsName)}
@@ -2510,8 +2634,25 @@ func (s *zfsMigrationSourceDriver) send(conn *websocket.Conn, zfsName string, zf
 		return err
 	}
 
+	var compressor *exec.Cmd
+	if compression != "" {
+		readPipe, compressor, err = migrationCompressReader(readPipe, compression)
+		if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+
 	<-shared.WebsocketSendStream(conn, readPipe, 4*1024*1024)
 
+	if compressor != nil {
+		err = compressor.Wait()
+		if err != nil {
+			logger.Errorf("Problem compressing zfs send stream: %s.", err)
+		}
+	}
+
 	output, err := ioutil.ReadAll(stderr)
 	if err != nil {
 		logger.Errorf("Problem reading zfs send stderr: %s.", err)
@@ -2525,12 +2666,12 @@ func (s *zfsMigrationSourceDriver) send(conn *websocket.Conn, zfsName string, zf
 	return err
 }
 
-func (s *zfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn, op *operation, bwlimit string, containerOnly bool) error {
+func (s *zfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn, op *operation, bwlimit string, rsyncArgs []string, compression string, containerOnly bool) error {
 	if s.container.IsSnapshot() {
 		_, snapOnlyName, _ := containerGetParentAndSnapshotName(s.container.Name())
 		snapshotName := fmt.Sprintf("snapshot-%s", snapOnlyName)
 		wrapper := StorageProgressReader(op, "fs_progress", s.container.Name())
-		return s.send(conn, snapshotName, "", wrapper)
+		return s.send(conn, snapshotName, "", compression, wrapper)
 	}
 
 	lastSnap := ""
@@ -2544,7 +2685,7 @@ func (s *zfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn, op *op
 			lastSnap = snap
 
 			wrapper := StorageProgressReader(op, "fs_progress", snap)
-			if err := s.send(conn, snap, prev, wrapper); err != nil {
+			if err := s.send(conn, snap, prev, compression, wrapper); err != nil {
 				return err
 			}
 		}
@@ -2556,20 +2697,20 @@ func (s *zfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn, op *op
 	}
 
 	wrapper := StorageProgressReader(op, "fs_progress", s.container.Name())
-	if err := s.send(conn, s.runningSnapName, lastSnap, wrapper); err != nil {
+	if err := s.send(conn, s.runningSnapName, lastSnap, compression, wrapper); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (s *zfsMigrationSourceDriver) SendAfterCheckpoint(conn *websocket.Conn, bwlimit string) error {
+func (s *zfsMigrationSourceDriver) SendAfterCheckpoint(conn *websocket.Conn, bwlimit string, rsyncArgs []string, compression string) error {
 	s.stoppedSnapName = fmt.Sprintf("migration-send-%s", uuid.NewRandom().String())
 	if err := s.zfs.zfsPoolVolumeSnapshotCreate(fmt.Sprintf("containers/%s", s.container.Name()), s.stoppedSnapName); err != nil {
 		return err
 	}
 
-	if err := s.send(conn, s.stoppedSnapName, s.runningSnapName, nil); err != nil {
+	if err := s.send(conn, s.stoppedSnapName, s.runningSnapName, compression, nil); err != nil {
 		return err
 	}
 
@@ -2586,8 +2727,8 @@ func (s *zfsMigrationSourceDriver) Cleanup() {
 	}
 }
 
-func (s *storageZfs) MigrationType() MigrationFSType {
-	return MigrationFSType_ZFS
+func (s *storageZfs) MigrationType() migration.MigrationFSType {
+	return migration.MigrationFSType_ZFS
 }
 
 func (s *storageZfs) PreservesInodes() bool {
@@ -2645,7 +2786,7 @@ func (s *storageZfs) MigrationSource(ct container, containerOnly bool) (Migratio
 	return &driver, nil
 }
 
-func (s *storageZfs) MigrationSink(live bool, container container, snapshots []*Snapshot, conn *websocket.Conn, srcIdmap *shared.IdmapSet, op *operation, containerOnly bool) error {
+func (s *storageZfs) MigrationSink(live bool, container container, snapshots []*migration.Snapshot, conn *websocket.Conn, srcIdmap *shared.IdmapSet, op *operation, containerOnly bool, compression string, rsyncArgs []string) error {
 	poolName := s.getOnDiskPoolName()
 	zfsRecv := func(zfsName string, writeWrapper func(io.WriteCloser) io.WriteCloser) error {
 		zfsFsName := fmt.Sprintf("%s/%s", poolName, zfsName)
@@ -2671,8 +2812,26 @@ func (s *storageZfs) MigrationSink(live bool, container container, snapshots []*
 			writePipe = writeWrapper(stdin)
 		}
 
+		var decompressor io.WriteCloser
+		if compression != "" {
+			decompressor, err = migrationDecompressWriter(writePipe, compression)
+			if err != nil {
+				cmd.Process.Kill()
+				cmd.Wait()
+				return err
+			}
+			writePipe = decompressor
+		}
+
 		<-shared.WebsocketRecvStream(writePipe, conn)
 
+		if decompressor != nil {
+			err = decompressor.Close()
+			if err != nil {
+				logger.Errorf("problem decompressing zfs recv stream: %s.", err)
+			}
+		}
+
 		output, err := ioutil.ReadAll(stderr)
 		if err != nil {
 			logger.Debugf("problem reading zfs recv stderr %s.", err)
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// systemd notification socket and watchdog interval, captured at startup so
+// that they don't leak into the environment of child processes.
+var sdNotifySocket string
+var sdWatchdogUsec int64
+
+func sdNotifyInit() {
+	defer func() {
+		os.Unsetenv("NOTIFY_SOCKET")
+		os.Unsetenv("WATCHDOG_PID")
+		os.Unsetenv("WATCHDOG_USEC")
+	}()
+
+	sdNotifySocket = os.Getenv("NOTIFY_SOCKET")
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return
+	}
+
+	// The watchdog may be meant for another process
+	pid := os.Getenv("WATCHDOG_PID")
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return
+	}
+
+	sdWatchdogUsec = usec
+}
+
+// sdNotify sends a state update (e.g. "READY=1") to systemd. It's a no-op
+// when the daemon isn't running as a Type=notify systemd service.
+func sdNotify(state string) error {
+	if sdNotifySocket == "" {
+		return nil
+	}
+
+	// Abstract namespace sockets
+	name := sdNotifySocket
+	if name[0] == '@' {
+		name = "\x00" + name[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdWatchdogInterval returns how often systemd expects to be pinged, or zero
+// if the watchdog isn't enabled.
+func sdWatchdogInterval() time.Duration {
+	if sdNotifySocket == "" || sdWatchdogUsec <= 0 {
+		return 0
+	}
+
+	// Ping twice as often as required to avoid any race
+	return time.Duration(sdWatchdogUsec) * time.Microsecond / 2
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"github.com/gorilla/mux"
+	log "gopkg.in/inconshreveable/log15.v2"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// Percentage of used space above which a storage pool is reported
+const warningPoolUsageThreshold = 90
+
+// warningSet records the warning if the condition is true and resolves any
+// previously recorded warning of that type otherwise.
+func warningSet(d *Daemon, condition bool, warningType string, entity string, message string) {
+	var err error
+	if condition {
+		err = dbWarningRecord(d.db, warningType, entity, message)
+	} else {
+		err = dbWarningResolve(d.db, warningType, entity)
+	}
+
+	if err != nil {
+		logger.Error("Failed to update warning", log.Ctx{"type": warningType, "entity": entity, "err": err})
+	}
+}
+
+// warningsCheck looks for non-fatal problems with the host setup.
+func warningsCheck(d *Daemon) {
+	_, err := exec.LookPath("criu")
+	warningSet(d, err != nil, "missing_criu", "",
+		"CRIU isn't installed, stateful snapshots and live migration won't be available")
+
+	warningSet(d, d.IdmapSet == nil, "unprivileged_unavailable", "",
+		"No usable uid/gid map could be found, only privileged containers will be able to run")
+
+	warningSet(d, !cgMemoryController, "missing_cgroup", "memory",
+		"Couldn't find the CGroup memory controller, memory limits will be ignored")
+	warningSet(d, !cgCpuController, "missing_cgroup", "cpu",
+		"Couldn't find the CGroup CPU controller, CPU time limits will be ignored")
+	warningSet(d, !cgBlkioController, "missing_cgroup", "blkio",
+		"Couldn't find the CGroup blkio controller, I/O limits will be ignored")
+	warningSet(d, !cgPidsController, "missing_cgroup", "pids",
+		"Couldn't find the CGroup pids controller, process limits will be ignored")
+
+	pools, err := dbStoragePools(d.db)
+	if err != nil && err != NoSuchObjectError {
+		logger.Error("Failed to list storage pools for warnings", log.Ctx{"err": err})
+		return
+	}
+
+	for _, pool := range pools {
+		fs := syscall.Statfs_t{}
+		err := syscall.Statfs(getStoragePoolMountPoint(pool), &fs)
+		if err != nil || fs.Blocks == 0 {
+			continue
+		}
+
+		used := 100 - (fs.Bavail * 100 / fs.Blocks)
+		warningSet(d, used >= warningPoolUsageThreshold, "pool_nearly_full", pool,
+			fmt.Sprintf("Storage pool is %d%% full", used))
+	}
+}
+
+func warningsGet(d *Daemon, r *http.Request) Response {
+	warnings, err := dbWarnings(d.db)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if d.isRecursionRequest(r) {
+		return SyncResponse(true, warnings)
+	}
+
+	urls := []string{}
+	for _, warning := range warnings {
+		urls = append(urls, fmt.Sprintf("/%s/warnings/%d", version.APIVersion, warning.ID))
+	}
+
+	return SyncResponse(true, urls)
+}
+
+var warningsCmd = Command{name: "warnings", get: warningsGet}
+
+func warningGetFromRequest(d *Daemon, r *http.Request) (*api.Warning, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, NoSuchObjectError
+	}
+
+	return dbWarningGet(d.db, id)
+}
+
+func warningGet(d *Daemon, r *http.Request) Response {
+	warning, err := warningGetFromRequest(d, r)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponseETag(true, warning, warning.Writable())
+}
+
+func warningPut(d *Daemon, r *http.Request) Response {
+	warning, err := warningGetFromRequest(d, r)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate the ETag
+	err = etagCheck(r, warning.Writable())
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	req := api.WarningPut{}
+	if err := shared.ReadToJSON(r.Body, &req); err != nil {
+		return BadRequest(err)
+	}
+
+	status := -1
+	for id, name := range warningStatusNames {
+		if name == req.Status {
+			status = id
+		}
+	}
+
+	if status < 0 {
+		return BadRequest(fmt.Errorf("Invalid warning status: %s", req.Status))
+	}
+
+	err = dbWarningStatusUpdate(d.db, warning.ID, status)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+func warningDelete(d *Daemon, r *http.Request) Response {
+	warning, err := warningGetFromRequest(d, r)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = dbWarningDelete(d.db, warning.ID)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+var warningCmd = Command{name: "warnings/{id}", get: warningGet, put: warningPut, delete: warningDelete}
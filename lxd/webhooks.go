@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "gopkg.in/inconshreveable/log15.v2"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// webhookActions are the lifecycle events which can be selected with
+// webhooks.events.
+var webhookActions = []string{
+	"container-created",
+	"container-deleted",
+	"container-frozen",
+	"container-healthy",
+	"container-oom",
+	"container-restarted",
+	"container-started",
+	"container-stopped",
+	"container-thawed",
+	"container-unhealthy",
+}
+
+// Number of delivery attempts of a notification, and delay before the first
+// retry (doubled after each attempt).
+const webhookAttempts = 3
+const webhookRetryDelay = 5 * time.Second
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+func daemonConfigValidateWebhookURLs(d *Daemon, key string, value string) error {
+	for _, entry := range webhookSplit(value) {
+		u, err := url.Parse(entry)
+		if err != nil {
+			return fmt.Errorf("Invalid webhook URL %s: %s", entry, err)
+		}
+
+		if !shared.StringInSlice(u.Scheme, []string{"http", "https"}) || u.Host == "" {
+			return fmt.Errorf("Invalid webhook URL %s: only http and https URLs are supported", entry)
+		}
+	}
+
+	return nil
+}
+
+func daemonConfigValidateWebhookEvents(d *Daemon, key string, value string) error {
+	for _, entry := range webhookSplit(value) {
+		if !shared.StringInSlice(entry, webhookActions) {
+			return fmt.Errorf("Invalid webhook event: %s", entry)
+		}
+	}
+
+	return nil
+}
+
+func webhookSplit(value string) []string {
+	entries := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// webhookSignature returns the value of the X-LXD-Signature header, a HMAC
+// of the body using the shared secret.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return fmt.Sprintf("sha256=%s", hex.EncodeToString(mac.Sum(nil)))
+}
+
+// webhooksNotify posts a lifecycle event to the configured webhooks, in the
+// background.
+func webhooksNotify(action string, body []byte) {
+	// The configuration isn't loaded in some tests
+	if daemonConfig == nil {
+		return
+	}
+
+	urls := webhookSplit(daemonConfig["webhooks.urls"].Get())
+	if len(urls) == 0 {
+		return
+	}
+
+	events := webhookSplit(daemonConfig["webhooks.events"].Get())
+	if len(events) > 0 && !shared.StringInSlice(action, events) {
+		return
+	}
+
+	secret := daemonConfig["webhooks.secret"].Get()
+	for _, target := range urls {
+		go webhookDeliver(target, action, secret, body)
+	}
+}
+
+func webhookDeliver(target string, action string, secret string, body []byte) {
+	delay := webhookRetryDelay
+
+	for attempt := 1; ; attempt++ {
+		err := webhookPost(target, action, secret, body)
+		if err == nil {
+			return
+		}
+
+		if attempt == webhookAttempts {
+			logger.Warn("Failed to deliver webhook notification", log.Ctx{"url": target, "action": action, "err": err})
+			return
+		}
+
+		logger.Debug("Retrying webhook notification", log.Ctx{"url": target, "action": action, "err": err})
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func webhookPost(target string, action string, secret string, body []byte) error {
+	req, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent)
+	req.Header.Set("X-LXD-Event", action)
+	if secret != "" {
+		req.Header.Set("X-LXD-Signature", webhookSignature(secret, body))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Unexpected response: %s", resp.Status)
+	}
+
+	return nil
+}
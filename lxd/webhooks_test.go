@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	signature := webhookSignature("secret", []byte(`{"type":"lifecycle"}`))
+	expected := "sha256=21a980d81724fd8eabdb08df28d5bfaa0d4aa7f5959de055e9103ae7b1506101"
+
+	if signature != expected {
+		t.Fatalf("Expected %s, got %s", expected, signature)
+	}
+}
+
+func TestWebhookValidation(t *testing.T) {
+	valid := []string{"", "http://example.com/hook", "https://example.com/a, http://10.0.0.1:8080/b"}
+	for _, value := range valid {
+		err := daemonConfigValidateWebhookURLs(nil, "webhooks.urls", value)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %s", value, err)
+		}
+	}
+
+	invalid := []string{"example.com", "ftp://example.com/hook", "http://"}
+	for _, value := range invalid {
+		err := daemonConfigValidateWebhookURLs(nil, "webhooks.urls", value)
+		if err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+
+	err := daemonConfigValidateWebhookEvents(nil, "webhooks.events", "container-started,container-stopped")
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	err = daemonConfigValidateWebhookEvents(nil, "webhooks.events", "container-exploded")
+	if err == nil {
+		t.Errorf("Expected an error for an unknown event")
+	}
+}
+
+func TestWebhookPost(t *testing.T) {
+	body := []byte(`{"type":"lifecycle"}`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := ioutil.ReadAll(r.Body)
+		if string(content) != string(body) {
+			t.Errorf("Unexpected body: %s", content)
+		}
+
+		if r.Header.Get("X-LXD-Event") != "container-stopped" {
+			t.Errorf("Unexpected event header: %s", r.Header.Get("X-LXD-Event"))
+		}
+
+		if r.Header.Get("X-LXD-Signature") != webhookSignature("secret", body) {
+			t.Errorf("Unexpected signature: %s", r.Header.Get("X-LXD-Signature"))
+		}
+
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	err := webhookPost(server.URL+"/hook", "container-stopped", "secret", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = webhookPost(server.URL+"/broken", "container-stopped", "secret", body)
+	if err == nil {
+		t.Fatal("Expected an error for a 500 response")
+	}
+}
@@ -1,5 +1,9 @@
 package api
 
+import (
+	"time"
+)
+
 // CertificatesPost represents the fields of a new LXD certificate
 type CertificatesPost struct {
 	CertificatePut `yaml:",inline"`
@@ -28,3 +32,14 @@ type Certificate struct {
 func (cert *Certificate) Writable() CertificatePut {
 	return cert.CertificatePut
 }
+
+// CertificateToken represents a one-time token allowing a client to add its
+// certificate to the trust store
+//
+// API extension: certificate_token
+type CertificateToken struct {
+	Addresses   []string  `json:"addresses" yaml:"addresses"`
+	Fingerprint string    `json:"fingerprint" yaml:"fingerprint"`
+	Secret      string    `json:"secret" yaml:"secret"`
+	ExpiresAt   time.Time `json:"expires_at" yaml:"expires_at"`
+}
@@ -25,6 +25,24 @@ type ContainerPost struct {
 
 	// API extension: container_only_migration
 	ContainerOnly bool `json:"container_only" yaml:"container_only"`
+
+	// API extension: migration_rsync_options
+	Rsync *MigrationRsyncOptions `json:"rsync,omitempty" yaml:"rsync,omitempty"`
+}
+
+// MigrationRsyncOptions represents the tunables of the rsync transfers of a
+// migration
+//
+// API extension: migration_rsync_options
+type MigrationRsyncOptions struct {
+	// Have rsync compress the data it sends
+	Compression bool `json:"compression" yaml:"compression"`
+
+	// Send whole files instead of computing deltas
+	WholeFile bool `json:"whole_file" yaml:"whole_file"`
+
+	// Bandwidth limit (rsync's --bwlimit), overrides the pool's rsync.bwlimit
+	Bwlimit string `json:"bwlimit" yaml:"bwlimit"`
 }
 
 // ContainerPut represents the modifiable fields of a LXD container
@@ -105,3 +123,11 @@ type ContainerSource struct {
 	// API extension: container_only_migration
 	ContainerOnly bool `json:"container_only,omitempty" yaml:"container_only,omitempty"`
 }
+
+// ContainerResetIdentityPost represents the fields required to reset the
+// identity of a container
+//
+// API extension: container_reset_identity
+type ContainerResetIdentityPost struct {
+	MachineID bool `json:"machine_id" yaml:"machine_id"`
+}
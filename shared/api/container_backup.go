@@ -0,0 +1,30 @@
+package api
+
+import (
+	"time"
+)
+
+// ContainerBackupsPost represents the fields available for a new LXD container backup
+//
+// API extension: container_backup_schedule
+type ContainerBackupsPost struct {
+	Name             string `json:"name" yaml:"name"`
+	OptimizedStorage bool   `json:"optimized_storage" yaml:"optimized_storage"`
+}
+
+// ContainerBackup represents a stored LXD container backup
+//
+// API extension: container_backup_schedule
+type ContainerBackup struct {
+	Name         string    `json:"name" yaml:"name"`
+	CreationDate time.Time `json:"created_at" yaml:"created_at"`
+	Size         int64     `json:"size" yaml:"size"`
+}
+
+// ContainerExportPost represents the fields to export a LXD container as a
+// disk image
+//
+// API extension: container_export_disk
+type ContainerExportPost struct {
+	Format string `json:"format" yaml:"format"`
+}
@@ -0,0 +1,11 @@
+package api
+
+// ContainerFull is a combination of Container, ContainerState and ContainerSnapshot
+//
+// API extension: container_full
+type ContainerFull struct {
+	Container `yaml:",inline"`
+
+	State     *ContainerState     `json:"state" yaml:"state"`
+	Snapshots []ContainerSnapshot `json:"snapshots" yaml:"snapshots"`
+}
@@ -8,12 +8,18 @@ import (
 type ContainerSnapshotsPost struct {
 	Name     string `json:"name" yaml:"name"`
 	Stateful bool   `json:"stateful" yaml:"stateful"`
+
+	// API extension: container_snapshot_description
+	Description string `json:"description" yaml:"description"`
 }
 
 // ContainerSnapshotPost represents the fields required to rename/move a LXD container snapshot
 type ContainerSnapshotPost struct {
 	Name      string `json:"name" yaml:"name"`
 	Migration bool   `json:"migration" yaml:"migration"`
+
+	// API extension: migration_rsync_options
+	Rsync *MigrationRsyncOptions `json:"rsync,omitempty" yaml:"rsync,omitempty"`
 }
 
 // ContainerSnapshot represents a LXD conainer snapshot
@@ -29,4 +35,10 @@ type ContainerSnapshot struct {
 	Name            string                       `json:"name" yaml:"name"`
 	Profiles        []string                     `json:"profiles" yaml:"profiles"`
 	Stateful        bool                         `json:"stateful" yaml:"stateful"`
+
+	// API extension: container_snapshot_description
+	Description string `json:"description" yaml:"description"`
+
+	// API extension: snapshot_size
+	Size int64 `json:"size" yaml:"size"`
 }
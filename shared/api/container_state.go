@@ -1,5 +1,9 @@
 package api
 
+import (
+	"time"
+)
+
 // ContainerStatePut represents the modifiable fields of a LXD container's state
 type ContainerStatePut struct {
 	Action   string `json:"action" yaml:"action"`
@@ -8,6 +12,14 @@ type ContainerStatePut struct {
 	Stateful bool   `json:"stateful" yaml:"stateful"`
 }
 
+// ContainersStatePut represents a state change applied to several containers
+//
+// API extension: container_bulk_state
+type ContainersStatePut struct {
+	Containers []string          `json:"containers" yaml:"containers"`
+	State      ContainerStatePut `json:"state" yaml:"state"`
+}
+
 // ContainerState represents a LXD container's state
 type ContainerState struct {
 	Status     string                           `json:"status" yaml:"status"`
@@ -20,6 +32,12 @@ type ContainerState struct {
 
 	// API extension: container_cpu_time
 	CPU ContainerStateCPU `json:"cpu" yaml:"cpu"`
+
+	// API extension: container_cloud_init
+	CloudInit string `json:"cloud_init" yaml:"cloud_init"`
+
+	// API extension: container_health_probes
+	Health string `json:"health" yaml:"health"`
 }
 
 // ContainerStateDisk represents the disk information section of a LXD container's state
@@ -32,6 +50,9 @@ type ContainerStateDisk struct {
 // API extension: container_cpu_time
 type ContainerStateCPU struct {
 	Usage int64 `json:"usage" yaml:"usage"`
+
+	// API extension: container_cpu_percentage
+	UsagePercent float64 `json:"usage_percent" yaml:"usage_percent"`
 }
 
 // ContainerStateMemory represents the memory information section of a LXD container's state
@@ -40,6 +61,9 @@ type ContainerStateMemory struct {
 	UsagePeak     int64 `json:"usage_peak" yaml:"usage_peak"`
 	SwapUsage     int64 `json:"swap_usage" yaml:"swap_usage"`
 	SwapUsagePeak int64 `json:"swap_usage_peak" yaml:"swap_usage_peak"`
+
+	// API extension: container_oom_policy
+	OOMKills int64 `json:"oom_kills" yaml:"oom_kills"`
 }
 
 // ContainerStateNetwork represents the network information section of a LXD container's state
@@ -67,4 +91,20 @@ type ContainerStateNetworkCounters struct {
 	BytesSent       int64 `json:"bytes_sent" yaml:"bytes_sent"`
 	PacketsReceived int64 `json:"packets_received" yaml:"packets_received"`
 	PacketsSent     int64 `json:"packets_sent" yaml:"packets_sent"`
+
+	// API extension: container_state_counters
+	ErrorsReceived         int64 `json:"errors_received" yaml:"errors_received"`
+	ErrorsSent             int64 `json:"errors_sent" yaml:"errors_sent"`
+	PacketsDroppedInbound  int64 `json:"packets_dropped_inbound" yaml:"packets_dropped_inbound"`
+	PacketsDroppedOutbound int64 `json:"packets_dropped_outbound" yaml:"packets_dropped_outbound"`
+}
+
+// ContainerUsageSample represents a point in time sample of a LXD container's resource usage
+//
+// API extension: container_usage_history
+type ContainerUsageSample struct {
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+	CPU       int64     `json:"cpu" yaml:"cpu"`
+	Memory    int64     `json:"memory" yaml:"memory"`
+	Disk      int64     `json:"disk" yaml:"disk"`
 }
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event represents an event entry (over websocket)
+type Event struct {
+	Type      string          `json:"type" yaml:"type"`
+	Timestamp time.Time       `json:"timestamp" yaml:"timestamp"`
+	Metadata  json.RawMessage `json:"metadata" yaml:"metadata"`
+}
+
+// ToOperation decodes the metadata of an "operation" event
+func (e *Event) ToOperation() (*Operation, error) {
+	op := Operation{}
+	err := json.Unmarshal(e.Metadata, &op)
+	if err != nil {
+		return nil, err
+	}
+
+	return &op, nil
+}
+
+// ToLogging decodes the metadata of a "logging" event
+func (e *Event) ToLogging() (*EventLogging, error) {
+	logging := EventLogging{}
+	err := json.Unmarshal(e.Metadata, &logging)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logging, nil
+}
+
+// ToLifecycle decodes the metadata of a "lifecycle" event
+func (e *Event) ToLifecycle() (*EventLifecycle, error) {
+	lifecycle := EventLifecycle{}
+	err := json.Unmarshal(e.Metadata, &lifecycle)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lifecycle, nil
+}
+
+// EventLogging represents a logging type event entry (admin only)
+type EventLogging struct {
+	Message string            `json:"message" yaml:"message"`
+	Level   string            `json:"level" yaml:"level"`
+	Context map[string]string `json:"context" yaml:"context"`
+}
+
+// EventLifecycle represents a lifecycle type event entry
+type EventLifecycle struct {
+	Action  string `json:"action" yaml:"action"`
+	Source  string `json:"source" yaml:"source"`
+	Project string `json:"project" yaml:"project"`
+}
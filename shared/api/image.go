@@ -25,7 +25,7 @@ type ImagesPostSource struct {
 	Mode string `json:"mode" yaml:"mode"`
 	Type string `json:"type" yaml:"type"`
 
-	// For protocol "direct"
+	// For protocol "direct" and type "oci" (API extension: image_oci_import)
 	URL string `json:"url" yaml:"url"`
 
 	// For type "container"
@@ -0,0 +1,45 @@
+package api
+
+// NetworkForwardsPost represents the fields of a new LXD network forward
+//
+// API extension: network_forwards
+type NetworkForwardsPost struct {
+	NetworkForwardPut `yaml:",inline"`
+
+	ListenAddress string `json:"listen_address" yaml:"listen_address"`
+}
+
+// NetworkForwardPut represents the modifiable fields of a LXD network forward
+//
+// API extension: network_forwards
+type NetworkForwardPut struct {
+	Config      map[string]string    `json:"config" yaml:"config"`
+	Description string               `json:"description" yaml:"description"`
+	Ports       []NetworkForwardPort `json:"ports" yaml:"ports"`
+}
+
+// NetworkForwardPort represents a port (or set of ports) forwarded to a
+// target address
+//
+// API extension: network_forwards
+type NetworkForwardPort struct {
+	Description   string `json:"description" yaml:"description"`
+	Protocol      string `json:"protocol" yaml:"protocol"`
+	ListenPort    string `json:"listen_port" yaml:"listen_port"`
+	TargetPort    string `json:"target_port" yaml:"target_port"`
+	TargetAddress string `json:"target_address" yaml:"target_address"`
+}
+
+// NetworkForward represents a LXD network forward
+//
+// API extension: network_forwards
+type NetworkForward struct {
+	NetworkForwardPut `yaml:",inline"`
+
+	ListenAddress string `json:"listen_address" yaml:"listen_address"`
+}
+
+// Writable converts a full NetworkForward struct into a NetworkForwardPut struct (filters read-only fields)
+func (forward *NetworkForward) Writable() NetworkForwardPut {
+	return forward.NetworkForwardPut
+}
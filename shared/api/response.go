@@ -26,10 +26,23 @@ type Response struct {
 	Code  int    `json:"error_code" yaml:"error_code"`
 	Error string `json:"error" yaml:"error"`
 
+	// API extension: error_names
+	ErrorName ErrorName `json:"error_name" yaml:"error_name"`
+
 	// Valid for Sync and Error responses
 	Metadata json.RawMessage `json:"metadata" yaml:"metadata"`
 }
 
+// AsError returns the error carried by an error Response
+func (r *Response) AsError() *ResponseError {
+	name := r.ErrorName
+	if name == "" {
+		name = ErrorNameFromCode(r.Code)
+	}
+
+	return &ResponseError{Code: r.Code, Name: name, Message: r.Error}
+}
+
 // MetadataAsMap parses the Response metadata into a map
 func (r *Response) MetadataAsMap() (map[string]interface{}, error) {
 	ret := map[string]interface{}{}
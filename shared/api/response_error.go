@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+)
+
+// ErrorName represents a machine-readable LXD error name
+//
+// API extension: error_names
+type ErrorName string
+
+// LXD error names
+const (
+	ErrorNameInvalidRequest     ErrorName = "invalid_request"
+	ErrorNameForbidden          ErrorName = "forbidden"
+	ErrorNameNotFound           ErrorName = "not_found"
+	ErrorNameAlreadyExists      ErrorName = "already_exists"
+	ErrorNamePreconditionFailed ErrorName = "precondition_failed"
+	ErrorNameInternal           ErrorName = "internal"
+	ErrorNameNotImplemented     ErrorName = "not_implemented"
+)
+
+// ErrorNameFromCode returns the error name matching an error code
+func ErrorNameFromCode(code int) ErrorName {
+	name, ok := map[int]ErrorName{
+		http.StatusBadRequest:          ErrorNameInvalidRequest,
+		http.StatusForbidden:           ErrorNameForbidden,
+		http.StatusNotFound:            ErrorNameNotFound,
+		http.StatusConflict:            ErrorNameAlreadyExists,
+		http.StatusPreconditionFailed:  ErrorNamePreconditionFailed,
+		http.StatusInternalServerError: ErrorNameInternal,
+		http.StatusNotImplemented:      ErrorNameNotImplemented,
+	}[code]
+
+	if !ok {
+		return ErrorNameInternal
+	}
+
+	return name
+}
+
+// ResponseError represents an error returned by LXD
+//
+// API extension: error_names
+type ResponseError struct {
+	Code    int
+	Name    ErrorName
+	Message string
+}
+
+// Error returns the message sent by LXD
+func (e *ResponseError) Error() string {
+	return e.Message
+}
+
+// IsErrorName returns whether err is a LXD error of the given name
+func IsErrorName(err error, name ErrorName) bool {
+	respErr, ok := err.(*ResponseError)
+	if !ok {
+		return false
+	}
+
+	return respErr.Name == name
+}
@@ -16,6 +16,10 @@ type ServerEnvironment struct {
 	ServerVersion          string   `json:"server_version" yaml:"server_version"`
 	Storage                string   `json:"storage" yaml:"storage"`
 	StorageVersion         string   `json:"storage_version" yaml:"storage_version"`
+
+	// API extension: server_environment_features
+	KernelFeatures   map[string]string `json:"kernel_features" yaml:"kernel_features"`
+	StorageAvailable []string          `json:"storage_available" yaml:"storage_available"`
 }
 
 // ServerPut represents the modifiable fields of a LXD server configuration
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"time"
+)
+
+// WarningPut represents the modifiable fields of a LXD warning
+//
+// API extension: warnings
+type WarningPut struct {
+	Status string `json:"status" yaml:"status"`
+}
+
+// Warning represents a problem detected by the LXD daemon
+//
+// API extension: warnings
+type Warning struct {
+	WarningPut `yaml:",inline"`
+
+	ID          int64     `json:"id" yaml:"id"`
+	Type        string    `json:"type" yaml:"type"`
+	Entity      string    `json:"entity" yaml:"entity"`
+	Message     string    `json:"message" yaml:"message"`
+	Count       int       `json:"count" yaml:"count"`
+	FirstSeenAt time.Time `json:"first_seen_at" yaml:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" yaml:"last_seen_at"`
+}
+
+// Writable converts a full Warning struct into a WarningPut struct (filters read-only fields)
+func (warning *Warning) Writable() WarningPut {
+	return warning.WarningPut
+}
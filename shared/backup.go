@@ -0,0 +1,204 @@
+package shared
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+
+	"gopkg.in/yaml.v2"
+)
+
+// BackupIndexPath is the first member of a container backup tarball
+const BackupIndexPath = "backup/index.yaml"
+
+// BackupManifestPath is the last member of a container backup tarball
+const BackupManifestPath = "backup/manifest.yaml"
+
+// BackupManifest lists the SHA-256 checksum of every regular file stored in
+// a container backup tarball, keyed by member name.
+type BackupManifest struct {
+	Files map[string]string `yaml:"files"`
+}
+
+// zstdMagic starts every zstd compressed stream
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// zstdReader streams the output of an external zstd decompressor.
+type zstdReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	done   bool
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	n, err := r.stdout.Read(p)
+	if err == io.EOF && !r.done {
+		r.done = true
+
+		waitErr := r.cmd.Wait()
+		if waitErr != nil {
+			return n, fmt.Errorf("zstd decompression failed: %s", waitErr)
+		}
+	}
+
+	return n, err
+}
+
+func (r *zstdReader) Close() error {
+	if r.done {
+		return nil
+	}
+
+	// Stop the decompressor when the stream wasn't read to the end
+	r.done = true
+	r.cmd.Process.Kill()
+	r.cmd.Wait()
+
+	return nil
+}
+
+// NewBackupDecompressReader returns a reader decompressing a container backup
+// tarball, detecting whether it's gzip or zstd compressed. zstd streams are
+// handled by the zstd tool which must then be installed.
+func NewBackupDecompressReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	header, _ := br.Peek(len(zstdMagic))
+	if !bytes.Equal(header, zstdMagic) {
+		return gzip.NewReader(br)
+	}
+
+	cmd := exec.Command("zstd", "-d", "-c", "-q")
+	cmd.Stdin = br
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to run zstd: %s", err)
+	}
+
+	return &zstdReader{cmd: cmd, stdout: stdout}, nil
+}
+
+// VerifyBackupTarball reads a whole compressed container backup tarball,
+// checking it against its manifest. It returns the name of the backed up
+// container and the number of files verified. Tarballs predating manifests
+// are only accepted when requireManifest is false.
+func VerifyBackupTarball(r io.Reader, requireManifest bool) (string, int, error) {
+	gr, err := NewBackupDecompressReader(r)
+	if err != nil {
+		return "", 0, fmt.Errorf("Invalid backup tarball: %s", err)
+	}
+	defer gr.Close()
+
+	name := ""
+	checksums := map[string]string{}
+	var manifest *BackupManifest
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", 0, fmt.Errorf("Invalid backup tarball: %s", err)
+		}
+
+		if manifest != nil {
+			return "", 0, fmt.Errorf("Invalid backup tarball: unexpected %s after the manifest", hdr.Name)
+		}
+
+		if name == "" && hdr.Name != BackupIndexPath {
+			return "", 0, fmt.Errorf("Invalid backup tarball: missing %s", BackupIndexPath)
+		}
+
+		switch hdr.Name {
+		case BackupIndexPath:
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return "", 0, fmt.Errorf("Invalid backup tarball: %s", err)
+			}
+
+			index := struct {
+				Container struct {
+					Name string `yaml:"name"`
+				} `yaml:"container"`
+			}{}
+
+			err = yaml.Unmarshal(data, &index)
+			if err != nil || index.Container.Name == "" {
+				return "", 0, fmt.Errorf("Invalid backup tarball: bad %s", BackupIndexPath)
+			}
+
+			name = index.Container.Name
+			sum := sha256.Sum256(data)
+			checksums[hdr.Name] = hex.EncodeToString(sum[:])
+		case BackupManifestPath:
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return "", 0, fmt.Errorf("Invalid backup tarball: %s", err)
+			}
+
+			manifest = &BackupManifest{}
+			err = yaml.Unmarshal(data, manifest)
+			if err != nil {
+				return "", 0, fmt.Errorf("Invalid backup tarball: bad %s", BackupManifestPath)
+			}
+		default:
+			if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+				continue
+			}
+
+			h := sha256.New()
+			_, err = io.Copy(h, tr)
+			if err != nil {
+				return "", 0, fmt.Errorf("Invalid backup tarball: %s", err)
+			}
+
+			checksums[hdr.Name] = hex.EncodeToString(h.Sum(nil))
+		}
+	}
+
+	if name == "" {
+		return "", 0, fmt.Errorf("Invalid backup tarball: missing %s", BackupIndexPath)
+	}
+
+	if manifest == nil {
+		if requireManifest {
+			return "", 0, fmt.Errorf("The backup doesn't contain a manifest")
+		}
+
+		return name, 0, nil
+	}
+
+	for file, sum := range manifest.Files {
+		actual, ok := checksums[file]
+		if !ok {
+			return "", 0, fmt.Errorf("Backup is missing %s", file)
+		}
+
+		if actual != sum {
+			return "", 0, fmt.Errorf("Checksum mismatch for %s", file)
+		}
+	}
+
+	for file := range checksums {
+		_, ok := manifest.Files[file]
+		if !ok {
+			return "", 0, fmt.Errorf("Backup contains %s which isn't in the manifest", file)
+		}
+	}
+
+	return name, len(checksums), nil
+}
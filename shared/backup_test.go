@@ -0,0 +1,108 @@
+package shared
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func makeBackupTarball(t *testing.T, files map[string]string, manifest *BackupManifest) []byte {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+
+	add := func(name string, content string) {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = tw.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	add(BackupIndexPath, files[BackupIndexPath])
+	for name, content := range files {
+		if name != BackupIndexPath {
+			add(name, content)
+		}
+	}
+
+	if manifest != nil {
+		data := "files:\n"
+		for name, sum := range manifest.Files {
+			data += "  " + name + ": " + sum + "\n"
+		}
+
+		add(BackupManifestPath, data)
+	}
+
+	tw.Close()
+	gw.Close()
+
+	return buf.Bytes()
+}
+
+func backupChecksums(files map[string]string) *BackupManifest {
+	manifest := &BackupManifest{Files: map[string]string{}}
+	for name, content := range files {
+		sum := sha256.Sum256([]byte(content))
+		manifest.Files[name] = hex.EncodeToString(sum[:])
+	}
+
+	return manifest
+}
+
+func TestVerifyBackupTarball(t *testing.T) {
+	files := map[string]string{
+		BackupIndexPath:                        "container:\n  name: c1\n",
+		"backup/container/rootfs/etc/hostname": "c1\n",
+	}
+
+	name, count, err := VerifyBackupTarball(bytes.NewReader(makeBackupTarball(t, files, backupChecksums(files))), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "c1" || count != 2 {
+		t.Errorf("Unexpected result: %s, %d", name, count)
+	}
+}
+
+func TestVerifyBackupTarballMismatch(t *testing.T) {
+	files := map[string]string{
+		BackupIndexPath:                        "container:\n  name: c1\n",
+		"backup/container/rootfs/etc/hostname": "c1\n",
+	}
+
+	manifest := backupChecksums(files)
+	files["backup/container/rootfs/etc/hostname"] = "c2\n"
+
+	_, _, err := VerifyBackupTarball(bytes.NewReader(makeBackupTarball(t, files, manifest)), true)
+	if err == nil {
+		t.Error("Tampered backup was verified")
+	}
+}
+
+func TestVerifyBackupTarballNoManifest(t *testing.T) {
+	files := map[string]string{
+		BackupIndexPath: "container:\n  name: c1\n",
+	}
+
+	tarball := makeBackupTarball(t, files, nil)
+
+	_, _, err := VerifyBackupTarball(bytes.NewReader(tarball), true)
+	if err == nil {
+		t.Error("Backup without a manifest was verified")
+	}
+
+	_, _, err = VerifyBackupTarball(bytes.NewReader(tarball), false)
+	if err != nil {
+		t.Error(err)
+	}
+}
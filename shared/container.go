@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type ContainerAction string
@@ -87,16 +88,242 @@ func IsAny(value string) error {
 	return nil
 }
 
+// BackupScheduleInterval parses a backups.schedule value into the time
+// between two automatic backups, a zero duration disables them.
+func BackupScheduleInterval(value string) (time.Duration, error) {
+	switch value {
+	case "":
+		return 0, nil
+	case "hourly":
+		return time.Hour, nil
+	case "daily":
+		return 24 * time.Hour, nil
+	case "weekly":
+		return 7 * 24 * time.Hour, nil
+	}
+
+	hours, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || hours < 0 {
+		return 0, fmt.Errorf("Invalid backup schedule: %s", value)
+	}
+
+	return time.Duration(hours) * time.Hour, nil
+}
+
+// FreezeWindow is a recurring window during which a container is kept
+// frozen, Start and End being minutes since midnight and Days the week
+// days it starts on (all of them when empty).
+type FreezeWindow struct {
+	Days  []time.Weekday
+	Start int
+	End   int
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func parseWeekday(value string) (time.Weekday, error) {
+	for i, name := range weekdayNames {
+		if strings.ToLower(value) == name {
+			return time.Weekday(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("Invalid day of the week: %s", value)
+}
+
+func parseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid time of day: %s", value)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// FreezeScheduleParse parses a freeze.schedule value, a comma separated list
+// of "[<day>[-<day>] ]HH:MM-HH:MM" windows (e.g. "mon-fri 09:00-17:00").
+func FreezeScheduleParse(value string) ([]FreezeWindow, error) {
+	windows := []FreezeWindow{}
+	if value == "" {
+		return windows, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("Invalid freeze window: %s", entry)
+		}
+
+		window := FreezeWindow{}
+		if len(fields) == 2 {
+			days := strings.SplitN(fields[0], "-", 2)
+
+			first, err := parseWeekday(days[0])
+			if err != nil {
+				return nil, err
+			}
+
+			last := first
+			if len(days) == 2 {
+				last, err = parseWeekday(days[1])
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			// Ranges may wrap around the end of the week (e.g. "fri-mon")
+			for day := first; ; day = (day + 1) % 7 {
+				window.Days = append(window.Days, day)
+				if day == last {
+					break
+				}
+			}
+		}
+
+		times := strings.SplitN(fields[len(fields)-1], "-", 2)
+		if len(times) != 2 {
+			return nil, fmt.Errorf("Invalid freeze window: %s", entry)
+		}
+
+		var err error
+		window.Start, err = parseTimeOfDay(times[0])
+		if err != nil {
+			return nil, err
+		}
+
+		window.End, err = parseTimeOfDay(times[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if window.Start == window.End {
+			return nil, fmt.Errorf("Empty freeze window: %s", entry)
+		}
+
+		windows = append(windows, window)
+	}
+
+	return windows, nil
+}
+
+func (w FreezeWindow) startsOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Active returns whether the given time falls within the window, windows
+// ending before they start running past midnight into the next day.
+func (w FreezeWindow) Active(t time.Time) bool {
+	minutes := t.Hour()*60 + t.Minute()
+
+	if w.Start < w.End {
+		return w.startsOn(t.Weekday()) && minutes >= w.Start && minutes < w.End
+	}
+
+	if minutes >= w.Start {
+		return w.startsOn(t.Weekday())
+	}
+
+	return minutes < w.End && w.startsOn((t.Weekday()+6)%7)
+}
+
 // KnownContainerConfigKeys maps all fully defined, well-known config keys
 // to an appropriate checker function, which validates whether or not a
 // given value is syntactically legal.
 var KnownContainerConfigKeys = map[string]func(value string) error{
+	"backups.optimized_storage": IsBool,
+	"backups.retention":         IsUint32,
+	"backups.schedule": func(value string) error {
+		_, err := BackupScheduleInterval(value)
+		return err
+	},
+
+	"boot.autorestart": func(value string) error {
+		return IsOneOf(value, []string{"always", "on-failure"})
+	},
+	"boot.autorestart.delay":     IsUint32,
+	"boot.autorestart.max_delay": IsUint32,
+	"boot.autorestart.retries":   IsUint32,
 	"boot.autostart":             IsBool,
 	"boot.autostart.delay":       IsInt64,
 	"boot.autostart.priority":    IsInt64,
-	"boot.host_shutdown_timeout": IsInt64,
+	"boot.depends_on": func(value string) error {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" && !ValidHostname(name) {
+				return fmt.Errorf("Invalid container name: %s", name)
+			}
+		}
+
+		return nil
+	},
+	"boot.host_shutdown_stateful": IsBool,
+	"boot.host_shutdown_timeout":  IsInt64,
+
+	"cloud-init.seed": IsBool,
+
+	"export.kernel_cmdline": IsAny,
+
+	"health.interval": IsUint32,
+	"health.probe": func(value string) error {
+		return IsOneOf(value, []string{"exec", "http", "tcp"})
+	},
+	"health.probe.command": IsAny,
+	"health.probe.path":    IsAny,
+	"health.probe.port": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return fmt.Errorf("Invalid port: %s", value)
+		}
+
+		return nil
+	},
+	"health.retries": IsUint32,
+	"health.timeout": IsUint32,
+
+	"freeze.schedule": func(value string) error {
+		_, err := FreezeScheduleParse(value)
+		return err
+	},
+
+	"console.log": IsBool,
+	"console.log_size": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := ParseByteSizeString(value)
+		return err
+	},
+
+	"limits.cpu": func(value string) error {
+		if value == "" {
+			return nil
+		}
 
-	"limits.cpu": IsAny,
+		// Number of CPUs to load-balance over
+		_, err := strconv.ParseUint(value, 10, 32)
+		if err == nil {
+			return nil
+		}
+
+		// Explicit list of CPUs to pin to
+		_, err = ParseCpuset(value)
+		return err
+	},
 	"limits.cpu.allowance": func(value string) error {
 		if value == "" {
 			return nil
@@ -130,21 +357,50 @@ var KnownContainerConfigKeys = map[string]func(value string) error{
 
 		return nil
 	},
+	"limits.cpu.nodes": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := ParseCpuset(value)
+		return err
+	},
 	"limits.cpu.priority": IsPriority,
 
 	"limits.disk.priority": IsPriority,
 
+	"limits.hugepages.1GB": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := ParseByteSizeString(value)
+		return err
+	},
+	"limits.hugepages.2MB": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := ParseByteSizeString(value)
+		return err
+	},
+
 	"limits.memory": func(value string) error {
 		if value == "" {
 			return nil
 		}
 
 		if strings.HasSuffix(value, "%") {
-			_, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
+			percent, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
 			if err != nil {
 				return err
 			}
 
+			if percent <= 0 || percent > 100 {
+				return fmt.Errorf("Invalid memory percentage: %s", value)
+			}
+
 			return nil
 		}
 
@@ -163,10 +419,26 @@ var KnownContainerConfigKeys = map[string]func(value string) error{
 
 	"limits.network.priority": IsPriority,
 
-	"limits.processes": IsInt64,
+	"limits.processes": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		valueInt, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || valueInt < 1 {
+			return fmt.Errorf("Invalid process limit: %s", value)
+		}
+
+		return nil
+	},
 
 	"linux.kernel_modules": IsAny,
 
+	"oom.policy": func(value string) error {
+		return IsOneOf(value, []string{"freeze", "restart"})
+	},
+	"oom.threshold": IsUint32,
+
 	"security.nesting":    IsBool,
 	"security.privileged": IsBool,
 
@@ -178,6 +450,22 @@ var KnownContainerConfigKeys = map[string]func(value string) error{
 	"security.syscalls.blacklist":         IsAny,
 	"security.syscalls.whitelist":         IsAny,
 
+	"snapshots.pattern": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		if strings.Contains(value, "/") {
+			return fmt.Errorf("'/' not allowed in snapshot name pattern")
+		}
+
+		if strings.Count(value, "%d") > 1 {
+			return fmt.Errorf("Snapshot name pattern can only contain one %%d")
+		}
+
+		return nil
+	},
+
 	// Caller is responsible for full validation of any raw.* value
 	"raw.apparmor": IsAny,
 	"raw.lxc":      IsAny,
@@ -191,6 +479,39 @@ var KnownContainerConfigKeys = map[string]func(value string) error{
 	"volatile.idmap.next":       IsAny,
 	"volatile.idmap.base":       IsAny,
 	"volatile.apply_quota":      IsAny,
+	"volatile.restart_required": IsAny,
+	"volatile.freeze.scheduled": IsAny,
+}
+
+// KernelLimits lists the resources which can be set through the
+// limits.kernel.* keys, named like in prlimit(1).
+var KernelLimits = []string{"as", "core", "cpu", "data", "fsize", "locks", "memlock", "msgqueue", "nice", "nofile", "nproc", "rss", "rtprio", "rttime", "sigpending", "stack"}
+
+// IsKernelLimit validates a resource limit, either a single value used for
+// both the soft and hard limits or "soft:hard", each being a number or
+// "unlimited".
+func IsKernelLimit(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	fields := strings.Split(value, ":")
+	if len(fields) > 2 {
+		return fmt.Errorf("Invalid resource limit: %s", value)
+	}
+
+	for _, field := range fields {
+		if field == "unlimited" {
+			continue
+		}
+
+		_, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid resource limit: %s", value)
+		}
+	}
+
+	return nil
 }
 
 // ConfigKeyChecker returns a function that will check whether or not
@@ -218,7 +539,16 @@ func ConfigKeyChecker(key string) (func(value string) error, error) {
 		}
 	}
 
+	if strings.HasPrefix(key, "limits.kernel.") && StringInSlice(strings.TrimPrefix(key, "limits.kernel."), KernelLimits) {
+		return IsKernelLimit, nil
+	}
+
 	if strings.HasPrefix(key, "environment.") {
+		name := strings.TrimPrefix(key, "environment.")
+		if name == "" || strings.Contains(name, "=") {
+			return nil, fmt.Errorf("Bad environment variable name: %s", name)
+		}
+
 		return IsAny, nil
 	}
 
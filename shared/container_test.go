@@ -0,0 +1,60 @@
+package shared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFreezeScheduleParse(t *testing.T) {
+	valid := []string{"", "09:00-17:00", "mon-fri 09:00-17:00", "fri-mon 22:00-06:00,sun 00:00-23:59"}
+	for _, value := range valid {
+		_, err := FreezeScheduleParse(value)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %s", value, err)
+		}
+	}
+
+	invalid := []string{"09:00", "09:00-09:00", "9am-5pm", "someday 09:00-17:00", "mon fri 09:00-17:00", "mon-fri"}
+	for _, value := range invalid {
+		_, err := FreezeScheduleParse(value)
+		if err == nil {
+			t.Errorf("Parsed invalid schedule %q", value)
+		}
+	}
+}
+
+func TestFreezeWindowActive(t *testing.T) {
+	windows, err := FreezeScheduleParse("mon-fri 09:00-17:00,sat 22:00-02:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2017-06-05 is a Monday
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2017, 6, day, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		window int
+		time   time.Time
+		active bool
+	}{
+		{0, at(5, 9, 0), true},
+		{0, at(5, 16, 59), true},
+		{0, at(5, 17, 0), false},
+		{0, at(5, 8, 59), false},
+		{0, at(10, 12, 0), false},
+		{1, at(10, 23, 0), true},
+		{1, at(11, 1, 59), true},
+		{1, at(11, 2, 0), false},
+		{1, at(10, 1, 0), false},
+		{1, at(11, 23, 0), false},
+	}
+
+	for _, test := range tests {
+		active := windows[test.window].Active(test.time)
+		if active != test.active {
+			t.Errorf("Window %d at %s: expected active=%t, got %t", test.window, test.time, test.active, active)
+		}
+	}
+}
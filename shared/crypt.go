@@ -0,0 +1,179 @@
+package shared
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+/* Encrypted streams start with EncryptedStreamMagic followed by a random
+ * scrypt salt, then a sequence of AES-256-GCM sealed chunks, each prefixed
+ * by its 32bit big endian length. The nonce of each chunk is its index, with
+ * the last byte set on the final chunk so truncated streams are detected.
+ */
+
+// EncryptedStreamMagic is the header of streams produced by NewEncryptWriter
+const EncryptedStreamMagic = "LXDCRYPT1\n"
+
+const encryptSaltSize = 32
+const encryptChunkSize = 64 * 1024
+
+func encryptAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func encryptNonce(size int, index uint64, final bool) []byte {
+	nonce := make([]byte, size)
+	binary.BigEndian.PutUint64(nonce[size-9:size-1], index)
+	if final {
+		nonce[size-1] = 1
+	}
+
+	return nonce
+}
+
+// IsEncryptedStream returns whether the given header belongs to an encrypted stream
+func IsEncryptedStream(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(EncryptedStreamMagic))
+}
+
+type encryptWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	index uint64
+}
+
+// NewEncryptWriter returns a writer encrypting everything written to it with
+// the given passphrase. Close must be called to write the final chunk.
+func NewEncryptWriter(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	salt := make([]byte, encryptSaltSize)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := encryptAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(append([]byte(EncryptedStreamMagic), salt...))
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{w: w, aead: aead}, nil
+}
+
+func (e *encryptWriter) seal(data []byte, final bool) error {
+	sealed := e.aead.Seal(nil, encryptNonce(e.aead.NonceSize(), e.index, final), data, nil)
+	e.index++
+
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(sealed)))
+
+	_, err := e.w.Write(append(header, sealed...))
+	return err
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	e.buf = append(e.buf, p...)
+
+	// Always keep the trailing data around, it may be the final chunk
+	for len(e.buf) > encryptChunkSize {
+		err := e.seal(e.buf[:encryptChunkSize], false)
+		if err != nil {
+			return 0, err
+		}
+
+		e.buf = e.buf[encryptChunkSize:]
+	}
+
+	return len(p), nil
+}
+
+func (e *encryptWriter) Close() error {
+	return e.seal(e.buf, true)
+}
+
+type decryptReader struct {
+	r     io.Reader
+	aead  cipher.AEAD
+	buf   []byte
+	index uint64
+	done  bool
+}
+
+// NewDecryptReader returns a reader decrypting a stream produced by NewEncryptWriter.
+func NewDecryptReader(r io.Reader, passphrase string) (io.Reader, error) {
+	header := make([]byte, len(EncryptedStreamMagic)+encryptSaltSize)
+	_, err := io.ReadFull(r, header)
+	if err != nil || !IsEncryptedStream(header) {
+		return nil, fmt.Errorf("Not an encrypted stream")
+	}
+
+	aead, err := encryptAEAD(passphrase, header[len(EncryptedStreamMagic):])
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{r: r, aead: aead}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+
+		header := make([]byte, 4)
+		_, err := io.ReadFull(d.r, header)
+		if err != nil {
+			return 0, fmt.Errorf("Truncated encrypted stream")
+		}
+
+		size := binary.BigEndian.Uint32(header)
+		if size > encryptChunkSize+uint32(d.aead.Overhead()) {
+			return 0, fmt.Errorf("Invalid encrypted stream")
+		}
+
+		sealed := make([]byte, size)
+		_, err = io.ReadFull(d.r, sealed)
+		if err != nil {
+			return 0, fmt.Errorf("Truncated encrypted stream")
+		}
+
+		d.buf, err = d.aead.Open(nil, encryptNonce(d.aead.NonceSize(), d.index, false), sealed, nil)
+		if err != nil {
+			d.buf, err = d.aead.Open(nil, encryptNonce(d.aead.NonceSize(), d.index, true), sealed, nil)
+			if err != nil {
+				return 0, fmt.Errorf("Failed to decrypt the stream (wrong passphrase?)")
+			}
+
+			d.done = true
+		}
+		d.index++
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+
+	return n, nil
+}
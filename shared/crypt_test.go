@@ -0,0 +1,89 @@
+package shared
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"testing"
+)
+
+func encryptBuffer(t *testing.T, data []byte, passphrase string) []byte {
+	buf := &bytes.Buffer{}
+	w, err := NewEncryptWriter(buf, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 10, encryptChunkSize, 3*encryptChunkSize + 7} {
+		data := make([]byte, size)
+		rand.Read(data)
+
+		encrypted := encryptBuffer(t, data, "secret")
+		if !IsEncryptedStream(encrypted) {
+			t.Errorf("Missing header for %d bytes", size)
+			continue
+		}
+
+		r, err := NewDecryptReader(bytes.NewReader(encrypted), "secret")
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		decrypted, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if !bytes.Equal(data, decrypted) {
+			t.Errorf("Round trip of %d bytes doesn't match", size)
+		}
+	}
+}
+
+func TestDecryptWrongPassphrase(t *testing.T) {
+	encrypted := encryptBuffer(t, []byte("hello world\n"), "secret")
+
+	r, err := NewDecryptReader(bytes.NewReader(encrypted), "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ioutil.ReadAll(r)
+	if err == nil {
+		t.Error("Decryption with a wrong passphrase succeeded")
+	}
+}
+
+func TestDecryptTruncated(t *testing.T) {
+	data := make([]byte, 2*encryptChunkSize+1)
+	encrypted := encryptBuffer(t, data, "secret")
+
+	// Drop the final chunk
+	encrypted = encrypted[:len(encrypted)-(1+4+16)]
+
+	r, err := NewDecryptReader(bytes.NewReader(encrypted), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ioutil.ReadAll(r)
+	if err == nil {
+		t.Error("Decryption of a truncated stream succeeded")
+	}
+}
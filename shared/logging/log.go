@@ -2,8 +2,11 @@ package logging
 
 import (
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
 
 	log "gopkg.in/inconshreveable/log15.v2"
 	"gopkg.in/inconshreveable/log15.v2/term"
@@ -15,8 +18,73 @@ import (
 func GetLogger(syslog string, logfile string, verbose bool, debug bool, customHandler log.Handler) (logger.Logger, error) {
 	Log := log.New()
 
+	handler, _, err := getHandler(syslog, logfile, verbose, debug, "", nil, customHandler)
+	if err != nil {
+		return nil, err
+	}
+
+	Log.SetHandler(handler)
+
+	return Log, nil
+}
+
+// Reconfigure replaces the handlers of a logger returned by GetLogger.
+//
+// A non-empty level ("debug", "info", "warn", "error" or "crit") overrides
+// the verbosity selected by the verbose and debug flags. Each target
+// additionally ships the log messages to the local syslog ("syslog") or to a
+// remote syslog endpoint ("udp://HOST:PORT", "tcp://HOST:PORT" or
+// "tls://HOST:PORT").
+func Reconfigure(l logger.Logger, syslog string, logfile string, verbose bool, debug bool, level string, targets []string, customHandler log.Handler) error {
+	log15logger, ok := l.(log.Logger)
+	if !ok {
+		return fmt.Errorf("Logger doesn't support reconfiguration")
+	}
+
+	handler, closers, err := getHandler(syslog, logfile, verbose, debug, level, targets, customHandler)
+	if err != nil {
+		for _, closer := range closers {
+			closer.Close()
+		}
+
+		return err
+	}
+
+	log15logger.SetHandler(handler)
+
+	// Close the connections of the replaced handlers
+	targetClosersLock.Lock()
+	for _, closer := range targetClosers {
+		closer.Close()
+	}
+	targetClosers = closers
+	targetClosersLock.Unlock()
+
+	return nil
+}
+
+// Log target handlers in use which hold a connection
+var targetClosers []io.Closer
+var targetClosersLock sync.Mutex
+
+func getHandler(syslog string, logfile string, verbose bool, debug bool, level string, targets []string, customHandler log.Handler) (log.Handler, []io.Closer, error) {
 	var handlers []log.Handler
 	var syshandler log.Handler
+	var closers []io.Closer
+
+	// Level override
+	var lvl log.Lvl
+	if level != "" {
+		var err error
+		lvl, err = log.LvlFromString(level)
+		if err != nil {
+			return nil, closers, fmt.Errorf("Invalid log level: %s", level)
+		}
+
+		// The level applies to all the handlers
+		debug = lvl == log.LvlDebug
+		verbose = lvl >= log.LvlInfo
+	}
 
 	// Format handler
 	format := LogfmtFormat()
@@ -33,7 +101,7 @@ func GetLogger(syslog string, logfile string, verbose bool, debug bool, customHa
 	// FileHandler
 	if logfile != "" {
 		if !pathExists(filepath.Dir(logfile)) {
-			return nil, fmt.Errorf("Log file path doesn't exist: %s", filepath.Dir(logfile))
+			return nil, closers, fmt.Errorf("Log file path doesn't exist: %s", filepath.Dir(logfile))
 		}
 
 		if !debug {
@@ -72,13 +140,66 @@ func GetLogger(syslog string, logfile string, verbose bool, debug bool, customHa
 		)
 	}
 
+	// TargetHandler
+	for _, target := range targets {
+		targethandler, err := getTargetHandler(target, LogfmtFormat())
+		if err != nil {
+			return nil, closers, err
+		}
+
+		closer, ok := targethandler.(io.Closer)
+		if ok {
+			closers = append(closers, closer)
+		}
+
+		if !debug {
+			targethandler = log.LvlFilterHandler(log.LvlInfo, targethandler)
+		}
+
+		handlers = append(handlers, targethandler)
+	}
+
+	// Restrict everything but the custom handler to the requested level
+	if level != "" && lvl < log.LvlInfo {
+		handler := log.LvlFilterHandler(lvl, log.MultiHandler(handlers...))
+		handlers = []log.Handler{handler}
+	}
+
 	if customHandler != nil {
 		handlers = append(handlers, customHandler)
 	}
 
-	Log.SetHandler(log.MultiHandler(handlers...))
+	return log.MultiHandler(handlers...), closers, nil
+}
+
+// ValidateTarget checks that a log target is either "syslog" or a
+// "udp://HOST:PORT", "tcp://HOST:PORT" or "tls://HOST:PORT" endpoint.
+func ValidateTarget(target string) error {
+	if target == "" || target == "syslog" {
+		return nil
+	}
+
+	_, _, err := parseTarget(target)
+	return err
+}
 
-	return Log, nil
+func parseTarget(target string) (string, string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", "", err
+	}
+
+	if (u.Scheme != "udp" && u.Scheme != "tcp" && u.Scheme != "tls") || u.Host == "" {
+		return "", "", fmt.Errorf("Invalid log target: %s", target)
+	}
+
+	return u.Scheme, u.Host, nil
+}
+
+// TargetWriter sends messages to a log target, see NewTargetWriter.
+type TargetWriter interface {
+	Info(msg string) error
+	Close() error
 }
 
 // AddContext will return a copy of the logger with extra context added
@@ -3,6 +3,8 @@
 package logging
 
 import (
+	"log/syslog"
+
 	log "gopkg.in/inconshreveable/log15.v2"
 )
 
@@ -22,3 +24,89 @@ func getSystemHandler(syslog string, debug bool, format log.Format) log.Handler
 
 	return nil
 }
+
+// getTargetHandler on Linux ships messages to the local or a remote syslog,
+// from its own goroutine so that logging never waits on the target.
+func getTargetHandler(target string, format log.Format) (log.Handler, error) {
+	if target == "syslog" {
+		w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "lxd")
+		if err != nil {
+			return nil, err
+		}
+
+		return &targetQueueHandler{queue: newTargetQueue(syslogSend(w), w.Close), format: format}, nil
+	}
+
+	network, address, err := parseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if network == "tls" {
+		handler, err := syslogTLSHandler(address, "lxd", format)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler, nil
+	}
+
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, "lxd")
+	if err != nil {
+		return nil, err
+	}
+
+	return &targetQueueHandler{queue: newTargetQueue(syslogSend(w), w.Close), format: format}, nil
+}
+
+// syslogSend returns a function writing messages to w with their severity.
+func syslogSend(w *syslog.Writer) func(severity int, msg string) error {
+	return func(severity int, msg string) error {
+		switch severity {
+		case 2:
+			return w.Crit(msg)
+		case 3:
+			return w.Err(msg)
+		case 4:
+			return w.Warning(msg)
+		case 6:
+			return w.Info(msg)
+		}
+
+		return w.Debug(msg)
+	}
+}
+
+// NewTargetWriter returns a writer sending messages with the given tag to
+// the local or a remote syslog, from its own goroutine.
+func NewTargetWriter(target string, tag string) (TargetWriter, error) {
+	if target == "syslog" {
+		w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+		if err != nil {
+			return nil, err
+		}
+
+		return newTargetQueue(syslogSend(w), w.Close), nil
+	}
+
+	network, address, err := parseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if network == "tls" {
+		w, err := newSyslogTLSWriter(address, tag)
+		if err != nil {
+			return nil, err
+		}
+
+		return newTargetQueue(w.write, w.Close), nil
+	}
+
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTargetQueue(syslogSend(w), w.Close), nil
+}
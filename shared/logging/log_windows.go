@@ -10,3 +10,22 @@ import (
 func getSystemHandler(syslog string, debug bool, format log.Format) log.Handler {
 	return nil
 }
+
+// getTargetHandler on Windows only supports remote endpoints.
+func getTargetHandler(target string, format log.Format) (log.Handler, error) {
+	network, address, err := parseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if network == "tls" {
+		handler, err := syslogTLSHandler(address, "lxd", format)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler, nil
+	}
+
+	return log.NetHandler(network, address, format)
+}
@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+// Syslog facility used for the messages (daemon)
+const syslogFacility = 3
+
+// syslogTLSWriteTimeout bounds how long sending a message may take before
+// the connection is given up on.
+const syslogTLSWriteTimeout = 5 * time.Second
+
+// syslogTLSWriter sends RFC 5424 messages to a syslog server over TLS, using
+// the octet counting framing of RFC 5425. It reconnects as needed.
+type syslogTLSWriter struct {
+	address  string
+	tag      string
+	hostname string
+
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+func newSyslogTLSWriter(address string, tag string) (*syslogTLSWriter, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+
+	w := &syslogTLSWriter{address: address, tag: tag, hostname: hostname}
+
+	// Report unreachable servers right away
+	err = w.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *syslogTLSWriter) connect() error {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", w.address, &tls.Config{})
+	if err != nil {
+		return err
+	}
+
+	w.conn = conn
+	return nil
+}
+
+func (w *syslogTLSWriter) write(severity int, msg string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	frame := fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+		syslogFacility*8+severity, time.Now().Format(time.RFC3339), w.hostname, w.tag, os.Getpid(), strings.TrimRight(msg, "\n"))
+
+	// Retry once on a new connection if the server went away
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			err = w.connect()
+			if err != nil {
+				continue
+			}
+		}
+
+		w.conn.SetWriteDeadline(time.Now().Add(syslogTLSWriteTimeout))
+		_, err = fmt.Fprintf(w.conn, "%d %s", len(frame), frame)
+		if err == nil {
+			return nil
+		}
+
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	return err
+}
+
+// Close closes the connection to the server.
+func (w *syslogTLSWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+func syslogSeverity(lvl log.Lvl) int {
+	switch lvl {
+	case log.LvlCrit:
+		return 2
+	case log.LvlError:
+		return 3
+	case log.LvlWarn:
+		return 4
+	case log.LvlInfo:
+		return 6
+	}
+
+	return 7
+}
+
+// syslogTLSHandler returns a handler sending the records to a syslog server
+// over TLS, from its own goroutine.
+func syslogTLSHandler(address string, tag string, format log.Format) (*targetQueueHandler, error) {
+	w, err := newSyslogTLSWriter(address, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &targetQueueHandler{queue: newTargetQueue(w.write, w.Close), format: format}, nil
+}
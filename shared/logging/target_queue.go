@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+
+	log "gopkg.in/inconshreveable/log15.v2"
+)
+
+// targetQueueSize is how many messages may wait to be sent to a log target,
+// later ones being dropped until it catches up.
+const targetQueueSize = 1024
+
+type targetMessage struct {
+	severity int
+	msg      string
+}
+
+// targetQueue sends messages to a log target from its own goroutine, so that
+// logging never waits on the network.
+type targetQueue struct {
+	messages  chan targetMessage
+	done      chan struct{}
+	closeOnce sync.Once
+
+	send  func(severity int, msg string) error
+	close func() error
+}
+
+func newTargetQueue(send func(severity int, msg string) error, close func() error) *targetQueue {
+	q := &targetQueue{
+		messages: make(chan targetMessage, targetQueueSize),
+		done:     make(chan struct{}),
+		send:     send,
+		close:    close,
+	}
+
+	go q.run()
+
+	return q
+}
+
+func (q *targetQueue) run() {
+	for {
+		select {
+		case m := <-q.messages:
+			q.send(m.severity, m.msg)
+		case <-q.done:
+			q.close()
+			return
+		}
+	}
+}
+
+func (q *targetQueue) write(severity int, msg string) error {
+	select {
+	case q.messages <- targetMessage{severity: severity, msg: msg}:
+		return nil
+	default:
+		return fmt.Errorf("Too many messages waiting for the log target")
+	}
+}
+
+// Info queues a message with the informational severity.
+func (q *targetQueue) Info(msg string) error {
+	return q.write(6, msg)
+}
+
+// Close drops the waiting messages and closes the target.
+func (q *targetQueue) Close() error {
+	q.closeOnce.Do(func() {
+		close(q.done)
+	})
+
+	return nil
+}
+
+// targetQueueHandler is a log handler going through a targetQueue.
+type targetQueueHandler struct {
+	queue  *targetQueue
+	format log.Format
+}
+
+func (h *targetQueueHandler) Log(r *log.Record) error {
+	return h.queue.write(syslogSeverity(r.Lvl), string(h.format.Format(r)))
+}
+
+func (h *targetQueueHandler) Close() error {
+	return h.queue.Close()
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"testing"
+)
+
+func TestTargetQueue(t *testing.T) {
+	started := make(chan string, targetQueueSize+1)
+	release := make(chan bool)
+	closed := make(chan bool, 1)
+
+	// Sending blocks until released, as would a stuck connection
+	send := func(severity int, msg string) error {
+		started <- msg
+		<-release
+		return nil
+	}
+
+	q := newTargetQueue(send, func() error {
+		closed <- true
+		return nil
+	})
+
+	err := q.Info("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if <-started != "first" {
+		t.Fatal("Expected the first message to be sent")
+	}
+
+	for i := 0; i < targetQueueSize; i++ {
+		err := q.Info("queued")
+		if err != nil {
+			t.Fatalf("Unexpected error for message %d: %v", i, err)
+		}
+	}
+
+	// The queue is full, writes fail instead of blocking
+	err = q.Info("dropped")
+	if err == nil {
+		t.Fatal("Expected an error with a full queue")
+	}
+
+	q.Close()
+	q.Close()
+	close(release)
+
+	<-closed
+}
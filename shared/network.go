@@ -17,23 +17,31 @@ import (
 )
 
 func RFC3493Dialer(network, address string) (net.Conn, error) {
-	host, port, err := net.SplitHostPort(address)
-	if err != nil {
-		return nil, err
-	}
+	return RFC3493DialerTimeout(10*time.Second)(network, address)
+}
 
-	addrs, err := net.LookupHost(host)
-	if err != nil {
-		return nil, err
-	}
-	for _, a := range addrs {
-		c, err := net.DialTimeout(network, net.JoinHostPort(a, port), 10*time.Second)
+// RFC3493DialerTimeout returns a dialer like RFC3493Dialer, giving up on
+// each of the host's addresses after the given timeout.
+func RFC3493DialerTimeout(timeout time.Duration) func(network, address string) (net.Conn, error) {
+	return func(network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
 		if err != nil {
-			continue
+			return nil, err
 		}
-		return c, err
+
+		addrs, err := net.LookupHost(host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			c, err := net.DialTimeout(network, net.JoinHostPort(a, port), timeout)
+			if err != nil {
+				continue
+			}
+			return c, err
+		}
+		return nil, fmt.Errorf("Unable to connect to: " + address)
 	}
-	return nil, fmt.Errorf("Unable to connect to: " + address)
 }
 
 func initTLSConfig() *tls.Config {
@@ -46,6 +54,11 @@ func initTLSConfig() *tls.Config {
 			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
 			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA},
 		PreferServerCipherSuites: true,
+
+		// Resume TLS sessions on the following connections to the same
+		// server (e.g. the websockets of an operation), skipping the
+		// expensive part of the handshake.
+		ClientSessionCache: tls.NewLRUClientSessionCache(0),
 	}
 }
 
@@ -0,0 +1,270 @@
+// Package schema generates JSON schemas for the LXD API structures and
+// validates loosely typed data (as decoded from YAML or JSON) against them.
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Schema is a JSON schema document
+type Schema map[string]interface{}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Generate returns the JSON schema for the type of v, based on its json tags
+func Generate(v interface{}) Schema {
+	s := generate(reflect.TypeOf(v))
+	s["$schema"] = "http://json-schema.org/draft-04/schema#"
+
+	return s
+}
+
+func generate(t reflect.Type) Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := Schema{}
+		structProperties(t, properties)
+		return Schema{"type": "object", "properties": properties, "additionalProperties": false}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": generate(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": generate(t.Elem())}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	}
+
+	// interface{} and anything else can't be constrained
+	return Schema{}
+}
+
+func structProperties(t reflect.Type, properties Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				structProperties(ft, properties)
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = generate(field.Type)
+	}
+}
+
+// Allow returns a copy of an object schema which also accepts the given
+// fields, whatever their content
+func (s Schema) Allow(fields ...string) Schema {
+	if s == nil {
+		return nil
+	}
+
+	properties := Schema{}
+	for key, value := range asSchema(s["properties"]) {
+		properties[key] = value
+	}
+
+	for _, field := range fields {
+		_, ok := properties[field]
+		if !ok {
+			properties[field] = Schema{}
+		}
+	}
+
+	out := Schema{}
+	for key, value := range s {
+		out[key] = value
+	}
+	out["properties"] = properties
+
+	return out
+}
+
+// ValidateYAML checks that the YAML document matches the schema
+func ValidateYAML(s Schema, content []byte) error {
+	var data interface{}
+	err := yaml.Unmarshal(content, &data)
+	if err != nil {
+		return err
+	}
+
+	return Validate(s, normalize(data))
+}
+
+// normalize converts the maps produced by the YAML decoder into JSON style maps
+func normalize(data interface{}) interface{} {
+	switch v := data.(type) {
+	case map[interface{}]interface{}:
+		out := map[string]interface{}{}
+		for key, value := range v {
+			out[fmt.Sprintf("%v", key)] = normalize(value)
+		}
+		return out
+	case []interface{}:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+	}
+
+	return data
+}
+
+// Validate checks that the decoded data matches the schema
+func Validate(s Schema, data interface{}) error {
+	return validate(s, data, "")
+}
+
+func validate(s map[string]interface{}, data interface{}, path string) error {
+	// Missing values are left to their default
+	if data == nil {
+		return nil
+	}
+
+	where := path
+	if where == "" {
+		where = "top level"
+	}
+
+	kind, _ := s["type"].(string)
+	switch kind {
+	case "object":
+		obj, ok := data.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Expected an object at %s", where)
+		}
+
+		properties := asSchema(s["properties"])
+		extra := s["additionalProperties"]
+
+		keys := []string{}
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			sub := asSchema(properties[key])
+			if sub == nil {
+				if allowed, ok := extra.(bool); ok && !allowed {
+					return fmt.Errorf("Unknown field \"%s\" at %s", key, where)
+				}
+
+				sub = asSchema(extra)
+			}
+
+			if sub == nil {
+				continue
+			}
+
+			err := validate(sub, obj[key], joinPath(path, key))
+			if err != nil {
+				return err
+			}
+		}
+	case "array":
+		list, ok := data.([]interface{})
+		if !ok {
+			return fmt.Errorf("Expected a list at %s", where)
+		}
+
+		items := asSchema(s["items"])
+		if items == nil {
+			return nil
+		}
+
+		for i, entry := range list {
+			err := validate(items, entry, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return err
+			}
+		}
+	case "string":
+		// YAML turns unquoted numbers and booleans into their own types,
+		// LXD accepts those anywhere a string is expected.
+		switch data.(type) {
+		case map[string]interface{}, []interface{}:
+			return fmt.Errorf("Expected a string at %s", where)
+		}
+	case "boolean":
+		if _, ok := data.(bool); !ok {
+			return fmt.Errorf("Expected a boolean at %s", where)
+		}
+	case "integer":
+		switch v := data.(type) {
+		case int, int64, uint64:
+		case float64:
+			if v != float64(int64(v)) {
+				return fmt.Errorf("Expected an integer at %s", where)
+			}
+		default:
+			return fmt.Errorf("Expected an integer at %s", where)
+		}
+	case "number":
+		switch data.(type) {
+		case int, int64, uint64, float64:
+		default:
+			return fmt.Errorf("Expected a number at %s", where)
+		}
+	}
+
+	return nil
+}
+
+// asSchema accepts both generated schemas and ones decoded from JSON
+func asSchema(v interface{}) map[string]interface{} {
+	switch s := v.(type) {
+	case Schema:
+		return s
+	case map[string]interface{}:
+		return s
+	}
+
+	return nil
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
@@ -0,0 +1,98 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestGenerateContainerPut(t *testing.T) {
+	s := Generate(api.ContainerPut{})
+
+	if s["type"] != "object" {
+		t.Fatalf("Expected an object, got %v", s["type"])
+	}
+
+	properties := s["properties"].(Schema)
+	for _, key := range []string{"architecture", "config", "devices", "ephemeral", "profiles", "description"} {
+		if _, ok := properties[key]; !ok {
+			t.Errorf("Missing property %q", key)
+		}
+	}
+
+	devices := properties["devices"].(Schema)["additionalProperties"].(Schema)
+	if devices["type"] != "object" {
+		t.Errorf("Expected devices entries to be objects, got %v", devices["type"])
+	}
+}
+
+func TestValidateYAML(t *testing.T) {
+	s := Generate(api.ContainerPut{})
+
+	valid := []string{
+		"config:\n  limits.cpu: 2\n  boot.autostart: true\nephemeral: false\n",
+		"devices:\n  root:\n    path: /\n    type: disk\nprofiles:\n- default\n",
+		"description:\n",
+	}
+
+	for _, content := range valid {
+		err := ValidateYAML(s, []byte(content))
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", content, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"confg:\n  limits.cpu: 2\n":       "Unknown field \"confg\" at top level",
+		"ephemeral: maybe\n":              "Expected a boolean at ephemeral",
+		"profiles: default\n":             "Expected a list at profiles",
+		"devices:\n  root: disk\n":        "Expected an object at devices.root",
+		"config:\n  foo:\n    bar: baz\n": "Expected a string at config.foo",
+	}
+
+	for content, expected := range invalid {
+		err := ValidateYAML(s, []byte(content))
+		if err == nil || err.Error() != expected {
+			t.Errorf("Expected %q for %q, got: %v", expected, content, err)
+		}
+	}
+}
+
+func TestValidateDecodedSchema(t *testing.T) {
+	// Schemas fetched from the server come back as plain maps
+	data, err := json.Marshal(Generate(api.ProfilePut{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Schema{}
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ValidateYAML(s, []byte("devices:\n  eth0:\n    - nictype\n"))
+	if err == nil || err.Error() != "Expected an object at devices.eth0" {
+		t.Errorf("Unexpected result: %v", err)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	s := Generate(api.ProfilePut{})
+
+	content := []byte("name: default\nused_by:\n- /1.0/containers/foo\nconfig: {}\n")
+	err := ValidateYAML(s, content)
+	if err == nil {
+		t.Fatal("Expected read-only fields to be rejected")
+	}
+
+	err = ValidateYAML(s.Allow("name", "used_by"), content)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if Schema(nil).Allow("name") != nil {
+		t.Error("Expected a nil schema to stay nil")
+	}
+}
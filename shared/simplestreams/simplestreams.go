@@ -300,14 +300,52 @@ type SimpleStreams struct {
 	cachedManifest map[string]*SimpleStreamsManifest
 	cachedImages   []api.Image
 	cachedAliases  map[string]*api.ImageAliasesEntry
+
+	cachePath  string
+	cacheTTL   time.Duration
+	cacheForce bool
+	cacheUsed  time.Time
 }
 
-func (s *SimpleStreams) parseIndex() (*SimpleStreamsIndex, error) {
-	if s.cachedIndex != nil {
-		return s.cachedIndex, nil
+// SetCache keeps a copy of the index and manifests in the given directory.
+// Copies younger than ttl are used without contacting the server, older
+// ones only when the server can't be reached.
+func (s *SimpleStreams) SetCache(path string, ttl time.Duration) {
+	s.cachePath = path
+	s.cacheTTL = ttl
+}
+
+// CachedSince returns the date of the oldest stale copy used because the
+// server couldn't be reached, the zero time if none was.
+func (s *SimpleStreams) CachedSince() time.Time {
+	return s.cacheUsed
+}
+
+// Refresh drops all cached data, fetching it again from the server
+func (s *SimpleStreams) Refresh() error {
+	s.cachedIndex = nil
+	s.cachedManifest = map[string]*SimpleStreamsManifest{}
+	s.cachedImages = nil
+	s.cachedAliases = nil
+	s.cacheUsed = time.Time{}
+
+	s.cacheForce = true
+	defer func() { s.cacheForce = false }()
+
+	_, _, err := s.getImages()
+	if err != nil {
+		return err
+	}
+
+	if !s.cacheUsed.IsZero() {
+		return fmt.Errorf("Unable to reach %s", s.url)
 	}
 
-	url := fmt.Sprintf("%s/streams/v1/index.json", s.url)
+	return nil
+}
+
+func (s *SimpleStreams) download(path string) ([]byte, error) {
+	url := fmt.Sprintf("%s/%s", s.url, path)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -327,7 +365,58 @@ func (s *SimpleStreams) parseIndex() (*SimpleStreamsIndex, error) {
 		return nil, fmt.Errorf("Unable to fetch %s: %s", url, r.Status)
 	}
 
-	body, err := ioutil.ReadAll(r.Body)
+	return ioutil.ReadAll(r.Body)
+}
+
+// fetch retrieves a file of the stream, going through the cache if any
+func (s *SimpleStreams) fetch(path string) ([]byte, error) {
+	if s.cachePath == "" {
+		return s.download(path)
+	}
+
+	cacheFile := filepath.Join(s.cachePath, strings.Replace(path, "/", "_", -1))
+	fi, err := os.Stat(cacheFile)
+	if err == nil && !s.cacheForce && time.Since(fi.ModTime()) < s.cacheTTL {
+		body, err := ioutil.ReadFile(cacheFile)
+		if err == nil {
+			return body, nil
+		}
+	}
+
+	body, err := s.download(path)
+	if err != nil {
+		// Fallback to a stale copy
+		if fi == nil {
+			return nil, err
+		}
+
+		cached, cacheErr := ioutil.ReadFile(cacheFile)
+		if cacheErr != nil {
+			return nil, err
+		}
+
+		if s.cacheUsed.IsZero() || fi.ModTime().Before(s.cacheUsed) {
+			s.cacheUsed = fi.ModTime()
+		}
+
+		return cached, nil
+	}
+
+	// Failing to update the cache isn't fatal
+	err = os.MkdirAll(s.cachePath, 0700)
+	if err == nil {
+		ioutil.WriteFile(cacheFile, body, 0600)
+	}
+
+	return body, nil
+}
+
+func (s *SimpleStreams) parseIndex() (*SimpleStreamsIndex, error) {
+	if s.cachedIndex != nil {
+		return s.cachedIndex, nil
+	}
+
+	body, err := s.fetch("streams/v1/index.json")
 	if err != nil {
 		return nil, err
 	}
@@ -349,27 +438,7 @@ func (s *SimpleStreams) parseManifest(path string) (*SimpleStreamsManifest, erro
 		return s.cachedManifest[path], nil
 	}
 
-	url := fmt.Sprintf("%s/%s", s.url, path)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	if s.useragent != "" {
-		req.Header.Set("User-Agent", s.useragent)
-	}
-
-	r, err := s.http.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer r.Body.Close()
-
-	if r.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Unable to fetch %s: %s", url, r.Status)
-	}
-
-	body, err := ioutil.ReadAll(r.Body)
+	body, err := s.fetch(path)
 	if err != nil {
 		return nil, err
 	}
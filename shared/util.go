@@ -19,6 +19,7 @@ import (
 	"reflect"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -261,6 +262,18 @@ func WriteAllBuf(w io.Writer, buf *bytes.Buffer) error {
 	}
 }
 
+// fileCopyBufferSize is the chunk size used by CopyFileData, large enough to
+// keep the number of syscalls low when transferring multi-GB files.
+const fileCopyBufferSize = 1024 * 1024
+
+// CopyFileData copies from src to dst like io.Copy, but going through a
+// larger buffer as is better suited to file contents.
+func CopyFileData(dst io.Writer, src io.Reader) (int64, error) {
+	// io.CopyBuffer ignores the buffer when dst implements io.ReaderFrom or
+	// src io.WriterTo (as *os.File does), so hide those.
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, make([]byte, fileCopyBufferSize))
+}
+
 // FileMove tries to move a file by using os.Rename,
 // if that fails it tries to copy the file and remove the source.
 func FileMove(oldPath string, newPath string) error {
@@ -305,7 +318,7 @@ func FileCopy(source string, dest string) error {
 	}
 	defer d.Close()
 
-	_, err = io.Copy(d, s)
+	_, err = CopyFileData(d, s)
 	if err != nil {
 		return err
 	}
@@ -432,6 +445,46 @@ func Int64InSlice(key int64, list []int64) bool {
 	return false
 }
 
+// cpusetMaxID is the highest CPU id the kernel supports (NR_CPUS - 1)
+const cpusetMaxID = 8191
+
+// ParseCpuset parses a list of CPU or NUMA node ids in the kernel's cpuset
+// format, e.g. "0-3,8", into a sorted list without duplicates.
+func ParseCpuset(value string) ([]int, error) {
+	ids := []int{}
+	seen := map[int]bool{}
+
+	for _, chunk := range strings.Split(value, ",") {
+		fields := strings.SplitN(chunk, "-", 2)
+		low, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil || low < 0 {
+			return nil, fmt.Errorf("Invalid cpuset value: %s", value)
+		}
+
+		high := low
+		if len(fields) == 2 {
+			high, err = strconv.Atoi(strings.TrimSpace(fields[1]))
+			if err != nil || high < low {
+				return nil, fmt.Errorf("Invalid cpuset value: %s", value)
+			}
+		}
+
+		if high > cpusetMaxID {
+			return nil, fmt.Errorf("Invalid cpuset value: %s (ids go up to %d)", value, cpusetMaxID)
+		}
+
+		for i := low; i <= high; i++ {
+			if !seen[i] {
+				seen[i] = true
+				ids = append(ids, i)
+			}
+		}
+	}
+
+	sort.Ints(ids)
+	return ids, nil
+}
+
 func IsTrue(value string) bool {
 	if StringInSlice(strings.ToLower(value), []string{"true", "1", "yes", "on"}) {
 		return true
@@ -95,3 +95,32 @@ func TestReaderToChannel(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCpuset(t *testing.T) {
+	valid := map[string]string{
+		"0":        "[0]",
+		"0-3,8":    "[0 1 2 3 8]",
+		"8,0-1,1":  "[0 1 8]",
+		"2-2":      "[2]",
+		"1, 3 - 4": "[1 3 4]",
+	}
+
+	for value, expected := range valid {
+		ids, err := ParseCpuset(value)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %s", value, err)
+			continue
+		}
+
+		if fmt.Sprintf("%v", ids) != expected {
+			t.Errorf("Parsed %q as %v, expected %s", value, ids, expected)
+		}
+	}
+
+	for _, value := range []string{"", "a", "3-1", "-1", "1-", "0,,1", "8192", "0-2147483647"} {
+		_, err := ParseCpuset(value)
+		if err == nil {
+			t.Errorf("Invalid cpuset %q was accepted", value)
+		}
+	}
+}
@@ -22,6 +22,8 @@ var argImage = gnuflag.String("image", "ubuntu:", "Image to use for the test")
 var argPrivileged = gnuflag.Bool("privileged", false, "Use privileged containers")
 var argFreeze = gnuflag.Bool("freeze", false, "Freeze the container right after start")
 
+var latencies = newLatencyReport()
+
 func main() {
 	err := run(os.Args)
 	if err != nil {
@@ -213,26 +215,23 @@ func spawnContainers(c lxd.ContainerServer, count int, image string, privileged
 		}
 		req.Config = config
 
-		op, err := c.CreateContainer(req)
-		if err != nil {
-			logf(fmt.Sprintf("Failed to spawn container '%s': %s", name, err))
-			return
-		}
+		err := latencies.timed("create", func() error {
+			op, err := c.CreateContainer(req)
+			if err != nil {
+				return err
+			}
 
-		err = op.Wait()
+			return op.Wait()
+		})
 		if err != nil {
 			logf(fmt.Sprintf("Failed to spawn container '%s': %s", name, err))
 			return
 		}
 
 		// Start
-		op, err = c.UpdateContainerState(name, api.ContainerStatePut{Action: "start", Timeout: -1}, "")
-		if err != nil {
-			logf(fmt.Sprintf("Failed to spawn container '%s': %s", name, err))
-			return
-		}
-
-		err = op.Wait()
+		err = latencies.timed("start", func() error {
+			return updateContainerState(c, name, api.ContainerStatePut{Action: "start", Timeout: -1})
+		})
 		if err != nil {
 			logf(fmt.Sprintf("Failed to spawn container '%s': %s", name, err))
 			return
@@ -240,13 +239,9 @@ func spawnContainers(c lxd.ContainerServer, count int, image string, privileged
 
 		// Freeze
 		if *argFreeze {
-			op, err := c.UpdateContainerState(name, api.ContainerStatePut{Action: "freeze", Timeout: -1}, "")
-			if err != nil {
-				logf(fmt.Sprintf("Failed to spawn container '%s': %s", name, err))
-				return
-			}
-
-			err = op.Wait()
+			err = latencies.timed("freeze", func() error {
+				return updateContainerState(c, name, api.ContainerStatePut{Action: "freeze", Timeout: -1})
+			})
 			if err != nil {
 				logf(fmt.Sprintf("Failed to spawn container '%s': %s", name, err))
 				return
@@ -284,10 +279,20 @@ func spawnContainers(c lxd.ContainerServer, count int, image string, privileged
 	wgBatch.Wait()
 
 	logf("Test completed in %.3fs", time.Since(timeStart).Seconds())
+	latencies.print()
 
 	return nil
 }
 
+func updateContainerState(c lxd.ContainerServer, name string, state api.ContainerStatePut) error {
+	op, err := c.UpdateContainerState(name, state, "")
+	if err != nil {
+		return err
+	}
+
+	return op.Wait()
+}
+
 func deleteContainers(c lxd.ContainerServer) error {
 	batch := *argParallel
 	if batch < 1 {
@@ -330,13 +335,9 @@ func deleteContainers(c lxd.ContainerServer) error {
 
 		// Stop
 		if ct.IsActive() {
-			op, err := c.UpdateContainerState(ct.Name, api.ContainerStatePut{Action: "stop", Timeout: -1, Force: true}, "")
-			if err != nil {
-				logf(fmt.Sprintf("Failed to delete container '%s': %s", ct.Name, err))
-				return
-			}
-
-			err = op.Wait()
+			err := latencies.timed("stop", func() error {
+				return updateContainerState(c, ct.Name, api.ContainerStatePut{Action: "stop", Timeout: -1, Force: true})
+			})
 			if err != nil {
 				logf(fmt.Sprintf("Failed to delete container '%s': %s", ct.Name, err))
 				return
@@ -344,13 +345,14 @@ func deleteContainers(c lxd.ContainerServer) error {
 		}
 
 		// Delete
-		op, err := c.DeleteContainer(ct.Name)
-		if err != nil {
-			logf("Failed to delete container: %s", ct.Name)
-			return
-		}
+		err := latencies.timed("delete", func() error {
+			op, err := c.DeleteContainer(ct.Name)
+			if err != nil {
+				return err
+			}
 
-		err = op.Wait()
+			return op.Wait()
+		})
 		if err != nil {
 			logf("Failed to delete container: %s", ct.Name)
 			return
@@ -385,6 +387,7 @@ func deleteContainers(c lxd.ContainerServer) error {
 	wgBatch.Wait()
 
 	logf("Cleanup completed")
+	latencies.print()
 
 	return nil
 }
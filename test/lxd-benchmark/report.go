@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyReport collects how long each kind of action took so that their
+// distribution can be printed once the test is over.
+type latencyReport struct {
+	lock    sync.Mutex
+	actions []string
+	samples map[string][]time.Duration
+}
+
+func newLatencyReport() *latencyReport {
+	return &latencyReport{samples: map[string][]time.Duration{}}
+}
+
+// record adds a sample for the given action, in the order actions are first seen.
+func (r *latencyReport) record(action string, duration time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, ok := r.samples[action]
+	if !ok {
+		r.actions = append(r.actions, action)
+	}
+
+	r.samples[action] = append(r.samples[action], duration)
+}
+
+// timed runs f and records its duration for the action if it succeeded.
+func (r *latencyReport) timed(action string, f func() error) error {
+	start := time.Now()
+	err := f()
+	if err != nil {
+		return err
+	}
+
+	r.record(action, time.Since(start))
+	return nil
+}
+
+// percentile returns the nearest-rank percentile of sorted samples.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+func (r *latencyReport) print() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.actions) == 0 {
+		return
+	}
+
+	fmt.Printf("\n")
+	fmt.Printf("Latencies:\n")
+	fmt.Printf("  %-10s %6s %10s %10s %10s %10s %10s\n", "action", "count", "min", "p50", "p90", "p99", "max")
+	for _, action := range r.actions {
+		samples := r.samples[action]
+		sorted := make([]time.Duration, len(samples))
+		copy(sorted, samples)
+		sort.Sort(durationSlice(sorted))
+
+		fmt.Printf("  %-10s %6d %10s %10s %10s %10s %10s\n", action, len(sorted),
+			formatLatency(sorted[0]),
+			formatLatency(percentile(sorted, 50)),
+			formatLatency(percentile(sorted, 90)),
+			formatLatency(percentile(sorted, 99)),
+			formatLatency(sorted[len(sorted)-1]))
+	}
+}
+
+func formatLatency(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+type durationSlice []time.Duration
+
+func (s durationSlice) Len() int           { return len(s) }
+func (s durationSlice) Less(i, j int) bool { return s[i] < s[j] }
+func (s durationSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
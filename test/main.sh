@@ -383,6 +383,8 @@ kill_lxd() {
     check_empty_table "${daemon_dir}/lxd.db" "profiles_config"
     check_empty_table "${daemon_dir}/lxd.db" "profiles_devices"
     check_empty_table "${daemon_dir}/lxd.db" "profiles_devices_config"
+    check_empty_table "${daemon_dir}/lxd.db" "projects"
+    check_empty_table "${daemon_dir}/lxd.db" "projects_config"
     check_empty_table "${daemon_dir}/lxd.db" "storage_pools"
     check_empty_table "${daemon_dir}/lxd.db" "storage_pools_config"
     check_empty_table "${daemon_dir}/lxd.db" "storage_volumes"
@@ -609,10 +611,17 @@ run_test test_snap_restore "snapshot restores"
 run_test test_config_profiles "profiles and configuration"
 run_test test_config_edit "container configuration edit"
 run_test test_config_edit_container_snapshot_pool_config "container and snapshot volume configuration edit"
+run_test test_config_cloud_init "cloud-init seed and status"
+run_test test_config_reset_identity "container identity reset"
 run_test test_server_config "server configuration"
+run_test test_warnings "server warnings"
+run_test test_projects "projects"
+run_test test_apply "environment files"
+run_test test_projects_limits "project limits"
 run_test test_filemanip "file manipulations"
 run_test test_network "network management"
 run_test test_idmap "id mapping"
+run_test test_shiftfs "shiftfs"
 run_test test_template "file templating"
 run_test test_pki "PKI mode"
 run_test test_devlxd "/dev/lxd"
@@ -627,5 +636,7 @@ run_test test_init_interactive "lxd init interactive"
 run_test test_init_preseed "lxd init preseed"
 run_test test_storage_profiles "storage profiles"
 run_test test_container_import "container import"
+run_test test_container_export "container export"
+run_test test_container_backups "container stored backups"
 
 TEST_RESULT=success
@@ -0,0 +1,59 @@
+test_apply() {
+  ensure_import_testimage
+
+  env_file="${TEST_DIR}/apply-test.yaml"
+  cat > "${env_file}" << EOT
+profiles:
+  apply-limits:
+    config:
+      limits.memory: 256MB
+containers:
+  apply-db:
+    image: testimage
+    profiles: [default, apply-limits]
+    state: stopped
+  apply-web:
+    image: testimage
+    config:
+      user.role: web
+    state: stopped
+    depends: [apply-db]
+EOT
+
+  # Missing objects are created and tagged with the environment
+  lxc apply "${env_file}"
+  lxc profile get apply-limits limits.memory | grep -q "^256MB$"
+  lxc config get apply-db user.environment | grep -q "^apply-test$"
+  lxc config get apply-web user.role | grep -q "^web$"
+  lxc apply "${env_file}" | grep -q "is up to date"
+
+  # Changed objects are updated
+  sed -i "s/user.role: web/user.role: frontend/" "${env_file}"
+  lxc apply "${env_file}" | grep -q "Updating container apply-web"
+  lxc config get apply-web user.role | grep -q "^frontend$"
+
+  # Removed objects are only deleted with --prune
+  sed -i "/apply-web:/,\$d" "${env_file}"
+  lxc apply "${env_file}" 2>&1 | grep -q "use --prune"
+  lxc info apply-web
+  lxc apply "${env_file}" --prune
+  ! lxc info apply-web
+
+  # Dependency loops and objects of other environments are refused
+  cat > "${TEST_DIR}/apply-loop.yaml" << EOT
+containers:
+  c1:
+    image: testimage
+    depends: [c2]
+  c2:
+    image: testimage
+    depends: [c1]
+EOT
+  ! lxc apply "${TEST_DIR}/apply-loop.yaml"
+  cp "${env_file}" "${TEST_DIR}/apply-other.yaml"
+  ! lxc apply "${TEST_DIR}/apply-other.yaml"
+
+  lxc delete apply-db
+  lxc profile delete apply-limits
+  rm -f "${env_file}" "${TEST_DIR}/apply-loop.yaml" "${TEST_DIR}/apply-other.yaml"
+}
@@ -98,7 +98,185 @@ test_container_import() {
       lxc info ctImport | grep snap0
       lxc delete --force ctImport
     fi
+
+    # Recover all the containers whose database entries were lost
+    lxc init testimage ctImport
+    lxc init testimage ctImport2
+    lxc start ctImport
+    lxc stop ctImport --force
+    lxc start ctImport2
+    lxc stop ctImport2 --force
+    shutdown_lxd "${LXD_IMPORT_DIR}"
+    sqlite3 "${LXD_DIR}/lxd.db" "PRAGMA foreign_keys=ON; DELETE FROM containers WHERE name IN ('ctImport', 'ctImport2')"
+    sqlite3 "${LXD_DIR}/lxd.db" "PRAGMA foreign_keys=ON; DELETE FROM storage_volumes WHERE name IN ('ctImport', 'ctImport2')"
+    respawn_lxd "${LXD_IMPORT_DIR}"
+    ! lxc info ctImport
+    lxd recover --dry-run | grep "ctImport .*recoverable"
+    lxd recover --dry-run | grep "ctImport2 .*recoverable"
+    ! lxc info ctImport
+    lxd recover
+    lxc info ctImport
+    lxc info ctImport2
+    lxd recover | grep "No unknown container found"
+    lxc delete --force ctImport ctImport2
   )
   # shellcheck disable=SC2031
   kill_lxd "${LXD_IMPORT_DIR}"
 }
+
+test_container_export() {
+  ensure_import_testimage
+
+  lxc init testimage ctExport
+  lxc snapshot ctExport
+  lxc config set ctExport user.foo bar
+
+  # Export and restore under a new name
+  lxc export ctExport "${LXD_DIR}/ctExport.tar.gz"
+  tar -tzf "${LXD_DIR}/ctExport.tar.gz" | grep -q "^backup/index.yaml"
+  tar -tzf "${LXD_DIR}/ctExport.tar.gz" | grep -q "^backup/snapshots/snap0/rootfs"
+  tar -tzf "${LXD_DIR}/ctExport.tar.gz" | tail -n1 | grep -q "^backup/manifest.yaml"
+
+  # Backups can be verified without being restored
+  lxc import "${LXD_DIR}/ctExport.tar.gz" --verify-only | grep -q "Backup of ctExport verified"
+  head -c 4096 "${LXD_DIR}/ctExport.tar.gz" > "${LXD_DIR}/ctTruncated.tar.gz"
+  ! lxc import "${LXD_DIR}/ctTruncated.tar.gz" --verify-only
+  rm -f "${LXD_DIR}/ctTruncated.tar.gz"
+
+  lxc import "${LXD_DIR}/ctExport.tar.gz" --name ctImported
+  lxc info ctImported | grep snap0
+  [ "$(lxc config get ctImported user.foo)" = "bar" ]
+  lxc start ctImported
+  lxc delete --force ctImported
+
+  # Backups can be streamed through pipes
+  lxc export ctExport - | tar -tz | grep -q "^backup/index.yaml"
+  lxc export ctExport - | lxc import - --name ctPiped
+  lxc info ctPiped | grep snap0
+  lxc delete ctPiped
+
+  # Renamed imports don't share volatile keys with the original
+  lxc config set ctExport volatile.eth0.hwaddr 00:16:3e:00:00:01
+  lxc export ctExport "${LXD_DIR}/ctExport.tar.gz"
+  lxc import "${LXD_DIR}/ctExport.tar.gz" --name ctRenamed --storage "$(lxc profile device get default root pool)"
+  [ -z "$(lxc config get ctRenamed volatile.eth0.hwaddr)" ]
+  lxc delete ctRenamed
+  ! lxc import "${LXD_DIR}/ctExport.tar.gz" --name "invalid/name"
+  ! lxc import "${LXD_DIR}/ctExport.tar.gz" --name ctBadPool --storage nonexistent
+  lxc config unset ctExport volatile.eth0.hwaddr
+  lxc export ctExport "${LXD_DIR}/ctExport.tar.gz"
+
+  # The original name is kept by default and must be free
+  ! lxc import "${LXD_DIR}/ctExport.tar.gz"
+  lxc delete ctExport
+  lxc import "${LXD_DIR}/ctExport.tar.gz"
+  lxc info ctExport | grep snap0
+
+  # Optimized backups are only supported by zfs and btrfs
+  lxd_backend=$(storage_backend "$LXD_DIR")
+  if [ "${lxd_backend}" = "zfs" ] || [ "${lxd_backend}" = "btrfs" ]; then
+    lxc export ctExport "${LXD_DIR}/ctExport.tar.gz" --optimized-storage
+    tar -tzf "${LXD_DIR}/ctExport.tar.gz" | grep -q "^backup/container.bin"
+    tar -tzf "${LXD_DIR}/ctExport.tar.gz" | grep -q "^backup/snapshots/snap0.bin"
+    lxc import "${LXD_DIR}/ctExport.tar.gz" --name ctOptimized
+    lxc info ctOptimized | grep snap0
+    lxc start ctOptimized
+    lxc delete --force ctOptimized
+  else
+    ! lxc export ctExport "${LXD_DIR}/ctExport.tar.gz" --optimized-storage
+  fi
+
+  # Backups can be zstd compressed
+  if which zstd >/dev/null 2>&1; then
+    lxc config set backups.compression_algorithm zstd
+    lxc export ctExport "${LXD_DIR}/ctExport.tar.zst"
+    zstd -d -c -q "${LXD_DIR}/ctExport.tar.zst" | tar -t | grep -q "^backup/index.yaml"
+    lxc import "${LXD_DIR}/ctExport.tar.zst" --verify-only | grep -q "Backup of ctExport verified"
+    lxc import "${LXD_DIR}/ctExport.tar.zst" --name ctZstd
+    lxc info ctZstd | grep snap0
+    lxc delete ctZstd
+    lxc config unset backups.compression_algorithm
+    rm -f "${LXD_DIR}/ctExport.tar.zst"
+  fi
+  ! lxc config set backups.compression_algorithm bzip2
+
+  # Encrypted backups need the passphrase to be imported
+  LXD_BACKUP_PASSPHRASE=secret lxc export ctExport "${LXD_DIR}/ctExport.tar.gz" --encrypt
+  ! tar -tzf "${LXD_DIR}/ctExport.tar.gz"
+  ! LXD_BACKUP_PASSPHRASE=wrong lxc import "${LXD_DIR}/ctExport.tar.gz" --name ctEncrypted
+  echo secret > "${LXD_DIR}/passphrase"
+  lxc import "${LXD_DIR}/ctExport.tar.gz" --name ctEncrypted --passphrase-file "${LXD_DIR}/passphrase"
+  lxc info ctEncrypted | grep snap0
+  lxc delete ctEncrypted
+  rm -f "${LXD_DIR}/passphrase"
+
+  # Disk images need a kernel in the container, which the test image lacks
+  ! lxc export ctExport "${LXD_DIR}/ctExport.img" --format raw
+  ! lxc export ctExport "${LXD_DIR}/ctExport.img" --format vmdk
+  [ ! -e "${LXD_DIR}/ctExport.img" ]
+
+  lxc delete ctExport
+  rm -f "${LXD_DIR}/ctExport.tar.gz"
+  ! lxc import "${LXD_DIR}/ctExport.tar.gz"
+}
+
+test_container_backups() {
+  ensure_import_testimage
+
+  lxc init testimage ctBackup
+  lxc config set ctBackup backups.retention 2
+
+  # Invalid schedules are rejected
+  ! lxc config set ctBackup backups.schedule fortnightly
+  lxc config set ctBackup backups.schedule daily
+
+  # Manual backups, only the most recent ones are kept
+  lxc backup create ctBackup first
+  sleep 1
+  lxc backup create ctBackup second
+  sleep 1
+  lxc backup create ctBackup third
+  ! lxc backup create ctBackup third
+  lxc backup list ctBackup | grep -q third
+  ! lxc backup list ctBackup | grep -q first
+  [ "$(find "${LXD_DIR}/backups/containers/ctBackup" -name "*.tar.gz" | wc -l)" = "2" ]
+
+  # Stored backups can be downloaded and restored
+  lxc backup export ctBackup third "${LXD_DIR}/ctBackup.tar.gz"
+  tar -tzf "${LXD_DIR}/ctBackup.tar.gz" | grep -q "^backup/index.yaml"
+  lxc import "${LXD_DIR}/ctBackup.tar.gz" --name ctBackupRestored
+  lxc delete ctBackupRestored
+  rm -f "${LXD_DIR}/ctBackup.tar.gz"
+
+  # Stored backups are encrypted when a server passphrase is set
+  lxc config set backups.encryption_passphrase secret
+  lxc backup create ctBackup encrypted
+  lxc backup export ctBackup encrypted "${LXD_DIR}/ctBackup.tar.gz"
+  ! tar -tzf "${LXD_DIR}/ctBackup.tar.gz"
+  LXD_BACKUP_PASSPHRASE=secret lxc import "${LXD_DIR}/ctBackup.tar.gz" --name ctBackupRestored
+  lxc delete ctBackupRestored
+  rm -f "${LXD_DIR}/ctBackup.tar.gz"
+  lxc config unset backups.encryption_passphrase
+  lxc backup delete ctBackup encrypted
+
+  lxc backup delete ctBackup third
+  ! lxc backup delete ctBackup third
+  ! lxc backup list ctBackup | grep -q third
+
+  # Backups can be stored elsewhere
+  mkdir -p "${LXD_DIR}/backup-target"
+  ! lxc config set backups.target "${LXD_DIR}/missing"
+  lxc config set backups.target "${LXD_DIR}/backup-target"
+  lxc backup create ctBackup
+  [ "$(find "${LXD_DIR}/backup-target/ctBackup" -name "backup-*.tar.gz" | wc -l)" = "1" ]
+  lxc config unset backups.target
+
+  # The s3 target needs an endpoint and a bucket
+  ! lxc config set backups.s3.endpoint "ftp://example.com"
+  lxc config set backups.target s3
+  ! lxc backup create ctBackup
+  lxc config unset backups.target
+
+  lxc delete ctBackup
+  rm -rf "${LXD_DIR}/backup-target"
+}
@@ -6,6 +6,12 @@ test_basic_usage() {
   ensure_import_testimage
   ensure_has_localhost_remote "${LXD_ADDR}"
 
+  # Test server information
+  lxc info | grep -q "certificate_fingerprint:"
+  lxc info | grep -q "server_environment_features"
+  lxc info | grep -A10 "kernel_features:" | grep -q "userns:"
+  lxc info | grep -A5 "storage_available:" | grep -q "dir"
+
   # Test image export
   sum=$(lxc image info testimage | grep ^Fingerprint | cut -d' ' -f2)
   lxc image export testimage "${LXD_DIR}/"
@@ -16,6 +22,9 @@ test_basic_usage() {
   lxc image alias create a/b/ "${sum}"
   lxc image alias delete a/b/
 
+  # Locally imported images have no source to refresh from
+  ! lxc image refresh testimage
+
   # Test alias list filtering
   lxc image alias create foo "${sum}"
   lxc image alias create bar "${sum}"
@@ -33,12 +42,34 @@ test_basic_usage() {
     | jq '.[]|select(.alias[0].name="testimage")' \
     | grep -q '"name": "testimage"'
 
+  # Test image list columns and filtering
+  lxc image list -c l,property.os --format csv | grep -q "testimage,Busybox"
+  lxc image list -c P | grep -q "os=Busybox"
+  ! lxc image list -c l,property.
+  [ "$(lxc image list os=Busybox --format json | jq length)" = "1" ]
+  [ "$(lxc image list os=nothing --format json | jq length)" = "0" ]
+
   # Test image delete
   lxc image delete testimage
 
   # test GET /1.0, since the client always puts to /1.0/
   my_curl -f -X GET "https://${LXD_ADDR}/1.0"
   my_curl -f -X GET "https://${LXD_ADDR}/1.0/containers"
+  my_curl -X GET "https://${LXD_ADDR}/1.0/containers/nonexistent" | jq -e ".error_name == \"not_found\""
+
+  # Test conditional requests
+  etag=$(my_curl -D - -o /dev/null "https://${LXD_ADDR}/1.0/containers" | grep -i '^etag:' | cut -d' ' -f2 | tr -d '\r')
+  modified=$(my_curl -D - -o /dev/null "https://${LXD_ADDR}/1.0/containers" | grep -i '^last-modified:' | cut -d' ' -f2- | tr -d '\r')
+  [ "$(my_curl -o /dev/null -w '%{http_code}' -H "If-None-Match: ${etag}" "https://${LXD_ADDR}/1.0/containers")" = "304" ]
+  [ "$(my_curl -o /dev/null -w '%{http_code}' -H "If-Modified-Since: ${modified}" "https://${LXD_ADDR}/1.0/containers")" = "304" ]
+  [ "$(my_curl -o /dev/null -w '%{http_code}' -H "If-None-Match: nomatch" "https://${LXD_ADDR}/1.0/containers")" = "200" ]
+
+  # Test sorting and pagination
+  my_curl "https://${LXD_ADDR}/1.0/images?limit=0" | jq -e '.metadata | length == 0'
+  my_curl "https://${LXD_ADDR}/1.0/images?recursion=1&sort=-size&limit=1" | jq -e '.metadata | length == 1'
+  my_curl -f "https://${LXD_ADDR}/1.0/operations?sort=id&offset=5"
+  ! my_curl -f "https://${LXD_ADDR}/1.0/containers?sort=created_at"
+  ! my_curl -f "https://${LXD_ADDR}/1.0/containers?limit=-1"
 
   # Re-import the image
   mv "${LXD_DIR}/${sum}.tar.xz" "${LXD_DIR}/testimage.tar.xz"
@@ -91,6 +122,22 @@ test_basic_usage() {
   # Test list json format
   lxc list --format json | jq '.[]|select(.name="foo")' | grep '"name": "foo"'
 
+  # Test filtering on user keys
+  lxc config set foo user.owner alice
+  lxc list user.owner=alice | grep -q foo
+  ! lxc list user.owner=bob | grep -q foo
+
+  # Test the Ansible inventory format
+  lxc list --format ansible-inventory | jq -r '.user_owner_alice.hosts[]' | grep -q "^foo$"
+  lxc list --format ansible-inventory | jq -r '._meta.hostvars.foo.ansible_connection' | grep -q "^lxd$"
+  ! lxc list bar user.owner=alice | grep -q foo
+  my_curl "https://${LXD_ADDR}/1.0/containers?filter=user.owner=alice" | jq -e '.metadata | length == 1'
+  my_curl "https://${LXD_ADDR}/1.0/containers?filter=user.owner=bob" | jq -e '.metadata | length == 0'
+  lxc config unset foo user.owner
+
+  # Test listing containers along with their state and snapshots
+  my_curl "https://${LXD_ADDR}/1.0/containers?recursion=2" | jq -e '.metadata[] | select(.name == "foo") | .state.status == "Stopped" and (.snapshots | type == "array")'
+
   # Test container rename
   lxc move foo bar
   lxc list | grep -v foo
@@ -118,6 +165,12 @@ test_basic_usage() {
   curl -k -s --cert "${LXD_CONF}/client3.crt" --key "${LXD_CONF}/client3.key" -X GET "https://${LXD_ADDR}/1.0/images" | grep "/1.0/images/" && false
   lxc image delete foo-image-compressed
 
+  if which zstd >/dev/null 2>&1; then
+    lxc publish bar --alias=foo-image-zstd --compression=zstd
+    lxc init foo-image-zstd zstd-container
+    lxc delete zstd-container
+    lxc image delete foo-image-zstd
+  fi
 
   # Test privileged container publish
   lxc profile create priv
@@ -239,6 +292,16 @@ test_basic_usage() {
 
     lxc start autostart --force-local
     PID=$(lxc info autostart --force-local | grep ^Pid | awk '{print $2}')
+
+    # Containers of the same priority get started concurrently
+    lxc config set core.autostart_concurrency 2 --force-local
+    lxc init testimage autostart2 --force-local
+    lxc config set autostart2 boot.autostart true --force-local
+
+    # Dependent containers come up after their dependencies
+    ! lxc config set autostart2 boot.depends_on "auto_start" --force-local || false
+    lxc config set autostart2 boot.depends_on autostart --force-local
+
     shutdown_lxd "${LXD_DIR}"
     [ -d "/proc/${PID}" ] && false
 
@@ -248,8 +311,9 @@ test_basic_usage() {
     respawn_lxd "${LXD_DIR}"
 
     lxc list --force-local autostart | grep -q RUNNING
+    lxc list --force-local autostart2 | grep -q RUNNING
 
-    lxc delete autostart --force --force-local
+    lxc delete autostart autostart2 --force --force-local
   )
   # shellcheck disable=SC2031
   LXD_DIR=${LXD_DIR}
@@ -258,8 +322,18 @@ test_basic_usage() {
   # Create and start a container
   lxc launch testimage foo
   lxc list | grep foo | grep RUNNING
+  # The CPU utilization is sampled once asked for
+  my_curl -f -X GET "https://${LXD_ADDR}/1.0/containers/foo/state" | jq -e ".metadata.cpu.usage_percent >= -1"
+  sleep 5
+  lxc list -c nu foo | grep foo | grep -q "%"
+  my_curl -f -X GET "https://${LXD_ADDR}/1.0/containers/foo/state" | jq -e ".metadata.cpu.usage_percent >= 0"
+  my_curl -f -X GET "https://${LXD_ADDR}/1.0/containers/foo/state" | jq -e ".metadata.network.lo.counters.errors_received >= 0"
   lxc stop foo --force  # stop is hanging
 
+  # stop with a timeout, falling back to a kill
+  lxc start foo
+  lxc stop foo --timeout=5 --force
+
   # cycle it a few times
   lxc start foo
   mac1=$(lxc exec foo cat /sys/class/net/eth0/address)
@@ -272,6 +346,28 @@ test_basic_usage() {
     false
   fi
 
+  # Stop and start all containers at once
+  lxc stop --all --force --parallel=2 | grep foo | grep -q OK
+  lxc list | grep foo | grep STOPPED
+  lxc start --all --all-timeout=60 | grep foo | grep -q OK
+  lxc list | grep foo | grep RUNNING
+
+  # Change the state of several containers in a single request
+  op=$(my_curl -X PUT "https://${LXD_ADDR}/1.0/containers" -d '{"containers": ["foo"], "state": {"action": "freeze"}}' | jq -r '.metadata.foo.id')
+  my_curl -f "https://${LXD_ADDR}/1.0/operations/${op}/wait" | jq -e '.metadata.status == "Success"'
+  lxc list | grep foo | grep FROZEN
+  ! my_curl -f -X PUT "https://${LXD_ADDR}/1.0/containers" -d '{"containers": ["foo", "nonexistent"], "state": {"action": "unfreeze"}}'
+  lxc list | grep foo | grep FROZEN
+  lxc start foo
+
+  # Test the console log
+  lxc console foo --show-log
+  lxc console foo --show-log --clear
+  ! lxc console foo
+  lxc init testimage console-log-test
+  [ -z "$(lxc console console-log-test --show-log)" ]
+  lxc delete console-log-test
+
   # Test last_used_at field is working properly
   lxc init testimage last-used-at-test
   lxc list last-used-at-test  --format json | jq -r '.[].last_used_at' | grep '1970-01-01T00:00:00Z'
@@ -279,10 +375,27 @@ test_basic_usage() {
   lxc list last-used-at-test  --format json | jq -r '.[].last_used_at' | grep -v '1970-01-01T00:00:00Z'
   lxc delete last-used-at-test --force
 
+  # check the container description
+  lxc config set foo description "Test container"
+  [ "$(lxc config get foo description)" = "Test container" ]
+  lxc info foo | grep -q "Description: Test container"
+  lxc list foo -c nd | grep -q "Test container"
+  lxc config unset foo description
+  [ -z "$(lxc config get foo description)" ]
+
   # check that we can set the environment
   lxc exec foo pwd | grep /root
   lxc exec --env BEST_BAND=meshuggah foo env | grep meshuggah
+  lxc config set foo environment.BEST_BAND meshuggah
+  lxc config set foo environment.HOME /srv
+  lxc exec foo env | grep BEST_BAND=meshuggah
+  lxc exec foo env | grep HOME=/srv
+  lxc exec --env BEST_BAND=gojira foo env | grep BEST_BAND=gojira
+  lxc config unset foo environment.BEST_BAND
+  lxc config unset foo environment.HOME
+  ! lxc config set foo environment.A=B value
   lxc exec foo ip link show | grep eth0
+  echo "echo \$0" | lxc shell foo | grep -q sh
 
   # check that we can get the return code for a non- wait-for-websocket exec
   op=$(my_curl -X POST "https://${LXD_ADDR}/1.0/containers/foo/exec" -d '{"command": ["sleep", "1"], "environment": {}, "wait-for-websocket": false, "interactive": false}' | jq -r .operation)
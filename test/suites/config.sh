@@ -180,6 +180,33 @@ test_config_profiles() {
   lxc exec foo -- ls /mnt2/hosts
   lxc config device remove foo mnt2
   ! lxc exec foo -- ls /mnt2/hosts
+
+  # test live-updating the process limit
+  lxc config set foo limits.processes 100
+  lxc exec foo -- true
+  lxc config unset foo limits.processes
+  ! lxc config set foo limits.processes 0
+  ! lxc config set foo limits.processes -1
+
+  # test live-updating the priorities
+  lxc config set foo limits.cpu.priority 5
+  lxc config set foo limits.disk.priority 0
+  lxc exec foo -- true
+  lxc config unset foo limits.cpu.priority
+  lxc config unset foo limits.disk.priority
+  ! lxc config set foo limits.cpu.priority 11
+  ! lxc config set foo limits.disk.priority -1
+
+  # test reporting changes which need a restart
+  lxc config set foo limits.memory 512MB
+  ! lxc config get foo volatile.restart_required | grep limits
+  lxc config set foo console.log_size 2MB
+  lxc config get foo volatile.restart_required | grep console.log_size
+  lxc info foo | grep "Pending restart: console.log_size"
+  lxc restart foo --force
+  [ -z "$(lxc config get foo volatile.restart_required)" ]
+  lxc config unset foo console.log_size
+  lxc config unset foo limits.memory
   lxc stop foo --force
   lxc start foo
   ! lxc exec foo -- ls /mnt2/hosts
@@ -193,6 +220,63 @@ test_config_profiles() {
   ! lxc config set foo raw.lxc a
   ! lxc profile set default raw.lxc a
 
+  # Test for nesting incompatible settings
+  lxc config set foo security.nesting true
+  ! lxc config set foo raw.lxc "lxc.mount.auto = proc:rw"
+  lxc config set foo security.idmap.isolated true
+  ! lxc config set foo security.idmap.size 65536
+  lxc config unset foo security.idmap.isolated
+  lxc config unset foo security.nesting
+
+  # Test CPU limits validation
+  lxc config set foo limits.cpu 2
+  lxc config set foo limits.cpu 0-1,3
+  ! lxc config set foo limits.cpu 3-1
+  ! lxc config set foo limits.cpu all
+  lxc config unset foo limits.cpu
+  lxc config set foo limits.cpu.nodes 0
+  ! lxc config set foo limits.cpu.nodes a-b
+  lxc config unset foo limits.cpu.nodes
+
+  # Test memory limits validation
+  lxc config set foo limits.memory 50%
+  ! lxc config set foo limits.memory 150%
+  ! lxc config set foo limits.memory 0%
+  lxc config unset foo limits.memory
+  ! lxc config set foo limits.memory.enforce medium
+  ! lxc config set foo limits.memory.swap.priority 11
+
+  # Test hugepages limits validation
+  lxc config set foo limits.hugepages.2MB 64MB
+  lxc config set foo limits.hugepages.1GB 2GB
+  ! lxc config set foo limits.hugepages.2MB lots
+  lxc config unset foo limits.hugepages.2MB
+  lxc config unset foo limits.hugepages.1GB
+
+  # Test kernel resource limits validation
+  lxc config set foo limits.kernel.nofile 1024:4096
+  lxc config set foo limits.kernel.memlock unlimited
+  ! lxc config set foo limits.kernel.nofile 1:2:3
+  ! lxc config set foo limits.kernel.nofile lots
+  ! lxc config set foo limits.kernel.foo 10
+  lxc config unset foo limits.kernel.nofile
+  lxc config unset foo limits.kernel.memlock
+
+  # Test boot ordering validation
+  lxc config set foo boot.autostart.priority 10
+  lxc config set foo boot.autostart.delay 5
+  ! lxc config set foo boot.autostart.priority high
+  ! lxc config set foo boot.host_shutdown_timeout soon
+  lxc config unset foo boot.autostart.priority
+  lxc config unset foo boot.autostart.delay
+
+  # Test freeze schedule validation
+  lxc config set foo freeze.schedule "mon-fri 09:00-17:00,22:00-02:00"
+  ! lxc config set foo freeze.schedule "09:00-09:00"
+  ! lxc config set foo freeze.schedule "someday 09:00-17:00"
+  ! lxc config set foo freeze.schedule "9am-5pm"
+  lxc config unset foo freeze.schedule
+
   bad=0
   lxc list user.prop=value | grep foo && bad=1
   if [ "${bad}" -eq 1 ]; then
@@ -233,6 +317,37 @@ test_config_edit() {
     lxc init testimage foo -s "lxdtest-$(basename "${LXD_DIR}")"
     lxc config show foo | sed 's/^description:.*/description: bar/' | lxc config edit foo
     lxc config show foo | grep -q 'description: bar'
+
+    # Documents not matching the published schema are rejected
+    my_curl -f "https://${LXD_ADDR}/1.0/schemas/container" | jq -e ".metadata.properties.ephemeral.type == \"boolean\""
+    ! lxc config show foo | sed 's/^ephemeral:.*/ephemeral: maybe/' | lxc config edit foo
+    ! lxc config show foo | sed 's/^description:/descripton:/' | lxc config edit foo
+    lxc profile show default | lxc profile edit default
+
+    # Devices described in YAML
+    cat > "${LXD_DIR}/devices.yaml" << EOF
+mnt1:
+  type: disk
+  source: /tmp
+  path: /mnt1
+  readonly: true
+mnt2:
+  type: disk
+  source: /tmp
+  path: /mnt2
+EOF
+    lxc config device add foo - < "${LXD_DIR}/devices.yaml"
+    lxc config device show foo | grep -q "path: /mnt1"
+    lxc config device show foo | grep -q "path: /mnt2"
+    ! lxc config device add foo - < "${LXD_DIR}/devices.yaml"
+    printf "mnt3:\n  source: /tmp\n" | lxc config device add foo - 2>&1 | grep -q 'Missing field "type" at mnt3'
+    printf "mnt3:\n  type: disk\n  path: [a]\n" | lxc config device add foo - 2>&1 | grep -q "Expected a string at mnt3.path"
+    printf "type: disk\nsource: /tmp\npath: /mnt3\n" | lxc config device edit foo mnt1
+    lxc config device get foo mnt1 path | grep -q "/mnt3"
+    [ -z "$(lxc config device get foo mnt1 readonly)" ]
+    ! printf "source: /tmp\n" | lxc config device edit foo mnt1
+    rm "${LXD_DIR}/devices.yaml"
+
     lxc delete foo
 }
 
@@ -256,3 +371,56 @@ test_config_edit_container_snapshot_pool_config() {
     lxc storage volume show "$storage_pool" container/c1/s1 | grep -q 'description: baz'
     lxc delete c1
 }
+
+test_config_cloud_init() {
+    ensure_import_testimage
+
+    lxc init testimage c1
+    lxc config set c1 cloud-init.seed true
+    lxc config set c1 user.user-data "#cloud-config
+packages: [hello]"
+    ! lxc config set c1 cloud-init.seed maybe
+    lxc start c1
+
+    # The seed is mounted read-only
+    lxc exec c1 -- cat /var/lib/cloud/seed/nocloud-net/user-data | grep -q "packages: \[hello\]"
+    lxc exec c1 -- cat /var/lib/cloud/seed/nocloud-net/meta-data | grep -q "local-hostname: c1"
+    ! lxc exec c1 -- test -e /var/lib/cloud/seed/nocloud-net/network-config
+    ! lxc exec c1 -- touch /var/lib/cloud/seed/nocloud-net/foo
+
+    # The status comes from the files cloud-init writes
+    ! lxc info c1 | grep -q "Cloud-init:"
+    lxc exec c1 -- mkdir -p /run/cloud-init
+    echo '{"v1": {"stage": "modules-final"}}' | lxc file push - c1/run/cloud-init/status.json
+    lxc info c1 | grep -q "Cloud-init: running"
+    echo '{"v1": {"datasource": "DataSourceNoCloud", "errors": []}}' | lxc file push - c1/run/cloud-init/result.json
+    lxc info c1 | grep -q "Cloud-init: done"
+    echo '{"v1": {"datasource": "DataSourceNoCloud", "errors": ["failed"]}}' | lxc file push - c1/run/cloud-init/result.json
+    lxc info c1 | grep -q "Cloud-init: error"
+
+    lxc delete c1 --force
+}
+
+test_config_reset_identity() {
+    ensure_import_testimage
+
+    lxc init testimage c1
+    lxc config device add c1 eth0 nic nictype=p2p name=eth0
+    lxc config set c1 volatile.eth0.hwaddr 00:16:3e:00:00:01
+    echo "0123456789abcdef0123456789abcdef" | lxc file push - c1/etc/machine-id
+
+    # The MAC address is regenerated, the machine-id only on request
+    lxc config reset-identity c1
+    [ -n "$(lxc config get c1 volatile.eth0.hwaddr)" ]
+    [ "$(lxc config get c1 volatile.eth0.hwaddr)" != "00:16:3e:00:00:01" ]
+    lxc file pull c1/etc/machine-id - | grep -q 0123456789abcdef
+
+    lxc config reset-identity c1 --machine-id
+    [ -z "$(lxc file pull c1/etc/machine-id -)" ]
+
+    # Only stopped containers can be reset
+    lxc start c1
+    ! lxc config reset-identity c1
+
+    lxc delete c1 --force
+}
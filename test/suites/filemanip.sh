@@ -80,6 +80,24 @@ test_filemanip() {
   lxc file push -p "${TEST_DIR}"/source/foo filemanip/A/B/C/D/
   [ "$(lxc exec filemanip cat /A/B/C/D/foo)" = "foo" ]
 
+  # Files larger than a transfer chunk go through unchanged
+  dd if=/dev/urandom of="${TEST_DIR}"/large bs=1M count=3 2>/dev/null
+  lxc file push "${TEST_DIR}"/large filemanip/tmp/large
+  lxc file pull filemanip/tmp/large "${TEST_DIR}"/large.pulled
+  cmp "${TEST_DIR}"/large "${TEST_DIR}"/large.pulled
+  rm -f "${TEST_DIR}"/large "${TEST_DIR}"/large.pulled
+
+  # Files can be read out of snapshots
+  lxc snapshot filemanip snap0
+  lxc exec filemanip -- rm /foo
+  lxc file pull filemanip/snap0/foo "${TEST_DIR}"/snapfoo
+  [ "$(cat "${TEST_DIR}"/snapfoo)" = "foo" ]
+  ! lxc file pull filemanip/foo "${TEST_DIR}"/snapfoo
+  lxc file pull -r filemanip/snap0/A "${TEST_DIR}"/snapdest
+  [ "$(cat "${TEST_DIR}"/snapdest/A/B/C/D/foo)" = "foo" ]
+  err=$(my_curl -o /dev/null -w "%{http_code}" -X GET "https://${LXD_ADDR}/1.0/containers/filemanip/snapshots/snap0/files?path=/tmp/foo")
+  [ "${err}" -eq "404" ]
+
   lxc delete filemanip -f
 
   if [ "$(storage_backend "$LXD_DIR")" != "lvm" ]; then
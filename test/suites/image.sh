@@ -35,6 +35,32 @@ test_image_expiry() {
   lxc_remote config set images.remote_cache_expiry 10
   lxc_remote remote set-default local
 
+  # per-origin expiry
+  lxc_remote init l1:testimage l2:c1
+  lxc_remote image list l2: | grep -q "${fpbrief}"
+  lxc_remote remote set-default l2
+  ! lxc_remote config set images.remote_cache_expiry_origins "${LXD_ADDR}"
+  lxc_remote config set images.remote_cache_expiry_origins "${LXD_ADDR}=0"
+  lxc_remote remote set-default local
+  ! lxc_remote image list l2: | grep -q "${fpbrief}"
+  lxc_remote delete l2:c1
+  lxc_remote remote set-default l2
+  lxc_remote config unset images.remote_cache_expiry_origins
+  lxc_remote remote set-default local
+
+  # cache size limit
+  lxc_remote init l1:testimage l2:c1
+  lxc_remote image list l2: | grep -q "${fpbrief}"
+  lxc_remote remote set-default l2
+  ! lxc_remote config set images.remote_cache_max_size lots
+  lxc_remote config set images.remote_cache_max_size 1KB
+  lxc_remote remote set-default local
+  ! lxc_remote image list l2: | grep -q "${fpbrief}"
+  lxc_remote delete l2:c1
+  lxc_remote remote set-default l2
+  lxc_remote config unset images.remote_cache_max_size
+  lxc_remote remote set-default local
+
   lxc_remote remote remove l2
   kill_lxd "$LXD2_DIR"
 }
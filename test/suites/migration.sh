@@ -118,6 +118,10 @@ migration() {
   lxc_remote copy l2:nonlive l1:nobase
   lxc_remote delete l1:nobase
 
+  # rsync bandwidth limit given on the command line
+  lxc_remote copy l2:nonlive l1:limited --bwlimit 100m
+  lxc_remote delete l1:limited
+
   lxc_remote start l1:nonlive2
   lxc_remote list l1: | grep RUNNING | grep nonlive2
   lxc_remote delete l1:nonlive2 l2:nonlive2 --force
@@ -8,6 +8,7 @@ test_network() {
   lxc network create lxdt$$
   lxc network set lxdt$$ dns.mode dynamic
   lxc network set lxdt$$ dns.domain blah
+  ! lxc network set lxdt$$ dns.host_resolver maybe
   lxc network set lxdt$$ ipv4.routing false
   lxc network set lxdt$$ ipv6.routing false
   lxc network set lxdt$$ ipv6.dhcp.stateful true
@@ -19,6 +20,52 @@ test_network() {
   lxc network show lxdt$$ | grep -q 'description: foo'
   lxc network delete lxdt$$
 
+  # Network forwards
+  lxc network create lxdt$$ ipv4.address=10.253.42.1/24 ipv6.address=none
+  lxc network forward create lxdt$$ 192.0.2.10
+  lxc network forward port add lxdt$$ 192.0.2.10 tcp 80,443 10.253.42.10
+  lxc network forward port add lxdt$$ 192.0.2.10 udp 5000-5010 10.253.42.11
+  ! lxc network forward port add lxdt$$ 192.0.2.10 sctp 80 10.253.42.10
+  ! lxc network forward port add lxdt$$ 192.0.2.10 tcp 80 10.0.0.10
+  ! lxc network forward port add lxdt$$ 192.0.2.10 tcp 8000-8010 10.253.42.10 80-90
+  lxc network forward set lxdt$$ 192.0.2.10 target_address 10.253.42.12
+  lxc network forward show lxdt$$ 192.0.2.10 | grep -q "target_address: 10.253.42.12"
+  iptables -t nat -S PREROUTING | grep "generated for LXD network lxdt$$ forward 192.0.2.10" | grep -q "dports 80,443"
+  iptables -t nat -S PREROUTING | grep "generated for LXD network lxdt$$ forward 192.0.2.10" | grep -q "dports 5000:5010"
+  lxc network forward port remove lxdt$$ 192.0.2.10 tcp 80,443
+  ! iptables -t nat -S PREROUTING | grep "generated for LXD network lxdt$$ forward 192.0.2.10" | grep -q "dports 80,443"
+  lxc network forward list lxdt$$ | grep -q 192.0.2.10
+  lxc network forward delete lxdt$$ 192.0.2.10
+  ! iptables -t nat -S PREROUTING | grep -q "generated for LXD network lxdt$$ forward"
+  lxc network delete lxdt$$
+
+  # Network zones
+  lxc network zone create lxdt$$.example.net peers.local.address=127.0.0.1
+  lxc network zone create 42.253.10.in-addr.arpa peers.local.address=127.0.0.1
+  ! lxc network zone create lxdt$$.example.net
+  ! lxc network zone create Invalid_Zone
+  ! lxc network zone set lxdt$$.example.net peers.local.address not-an-address
+  ! lxc network zone set lxdt$$.example.net peers.other.key c2VjcmV0
+  lxc network create lxdt$$ ipv4.address=10.253.42.1/24 ipv6.address=none dns.zone.forward=lxdt$$.example.net
+  ! lxc network set lxdt$$ dns.zone.forward missing.example.net
+  ! lxc network set lxdt$$ dns.zone.reverse.ipv4 lxdt$$.example.net
+  lxc network set lxdt$$ dns.zone.reverse.ipv4 42.253.10.in-addr.arpa
+  lxc network zone show lxdt$$.example.net | grep -q "/1.0/networks/lxdt$$"
+  ! lxc network zone delete lxdt$$.example.net
+  lxc network attach lxdt$$ nettest eth0
+  lxc config device set nettest eth0 ipv4.address 10.253.42.10
+  if which dig >/dev/null 2>&1; then
+    lxc config set core.dns_address 127.0.0.1:8853
+    dig @127.0.0.1 -p 8853 +short nettest.lxdt$$.example.net | grep -qx 10.253.42.10
+    dig @127.0.0.1 -p 8853 +short -x 10.253.42.10 | grep -qx "nettest.lxdt$$.example.net."
+    dig @127.0.0.1 -p 8853 AXFR lxdt$$.example.net | grep -q "^nettest.lxdt$$.example.net."
+    lxc config unset core.dns_address
+  fi
+  lxc config device remove nettest eth0
+  lxc network delete lxdt$$
+  lxc network zone delete lxdt$$.example.net
+  lxc network zone delete 42.253.10.in-addr.arpa
+
   # Unconfigured bridge
   lxc network create lxdt$$ ipv4.address=none ipv6.address=none
   lxc network delete lxdt$$
@@ -0,0 +1,73 @@
+test_projects() {
+  ensure_import_testimage
+
+  lxc project create foo limits.containers=1
+  lxc project list | grep -q foo
+  lxc project get foo limits.containers | grep -q "^1$"
+
+  # Invalid names and keys are refused
+  ! lxc project create foo_bar
+  ! lxc project create bar bad.key=1
+  ! lxc project delete default
+
+  # Containers with the same name can exist in both projects
+  lxc init testimage c1
+  lxc init testimage c1 --project foo
+  lxc list --project foo | grep -q c1
+  lxc info c1 --project foo | grep -q "Name: c1"
+  [ -d "${LXD_DIR}/containers/foo_c1" ]
+
+  # The project has its own default profile
+  lxc profile list --project foo | grep -q default
+  lxc profile show default --project foo | grep -q "/1.0/containers/c1?project=foo"
+
+  # Default project profiles may only use a project prefix they don't clash with
+  lxc profile create web_base
+  ! lxc profile create foo_base
+  lxc profile delete web_base
+
+  # The container limit applies
+  ! lxc init testimage c2 --project foo
+
+  # Switching project affects all further commands
+  lxc project switch foo
+  lxc project list | grep -q "foo (current)"
+  lxc list | grep -q c1
+  lxc move c1 c3
+  lxc list | grep -q c3
+  lxc project switch default
+  lxc list | grep -q c1
+
+  # Only empty projects can be deleted
+  ! lxc project delete foo
+  lxc delete c3 --project foo
+  lxc project delete foo
+  ! lxc project list | grep -q foo
+
+  lxc delete c1
+}
+
+test_projects_limits() {
+  ensure_import_testimage
+
+  lxc project create limited limits.memory=1GB limits.cpu=2
+  lxc project switch limited
+
+  # Containers must have their resources set once the project is limited
+  ! lxc init testimage c1
+  lxc profile set default limits.memory 512MB
+  lxc profile set default limits.cpu 1
+  lxc init testimage c1
+  lxc init testimage c2
+
+  # Neither a container nor the project can go above the limits
+  ! lxc init testimage c3
+  ! lxc config set c1 limits.memory 768MB
+  ! lxc project set limited limits.cpu 1
+  lxc delete c2
+  lxc init testimage c3
+
+  lxc delete c1 c3
+  lxc project switch default
+  lxc project delete limited
+}
@@ -9,6 +9,20 @@ test_remote_url() {
     lxc_remote remote remove test
   done
 
+  # Protocol detection and validation
+  lxc_remote remote add test "https://${LXD_ADDR}" --accept-certificate --password foo
+  lxc_remote remote list | grep test | grep -q lxd
+  lxc_remote remote remove test
+  ! lxc_remote remote add test "${LXD_ADDR}" --accept-certificate --password foo --protocol=foo
+  lxc_remote remote add test "${LXD_ADDR}" --accept-certificate --password foo --protocol=LXD
+  lxc_remote remote remove test
+
+  if [ -z "${LXD_OFFLINE:-}" ]; then
+    lxc_remote remote add test https://cloud-images.ubuntu.com/releases --public
+    lxc_remote remote list | grep test | grep -q simplestreams
+    lxc_remote remote remove test
+  fi
+
   # shellcheck disable=2153
   urls="${LXD_DIR}/unix.socket unix:${LXD_DIR}/unix.socket unix://${LXD_DIR}/unix.socket"
   if [ -z "${LXD_OFFLINE:-}" ]; then
@@ -28,6 +42,17 @@ test_remote_admin() {
 
   lxc_remote remote add localhost "${LXD_ADDR}" --accept-certificate --password foo
   lxc_remote remote list | grep 'localhost'
+  lxc_remote remote list --format json | jq -e '.[] | select(.name == "localhost") | .protocol == "lxd"'
+  lxc_remote remote list --format csv | grep -q "^localhost,"
+  ! lxc_remote remote list --format foo
+
+  # Global timeout
+  lxc_remote list localhost: --connect-timeout=5
+  ! lxc_remote list localhost: --connect-timeout=-1
+
+  # Health check
+  lxc_remote remote check localhost | grep -q "Authentication: trusted"
+  ! lxc_remote remote check nonexistent
 
   lxc_remote remote set-default localhost
   [ "$(lxc_remote remote get-default)" = "localhost" ]
@@ -37,6 +62,13 @@ test_remote_admin() {
   lxc_remote remote list | grep -v 'localhost'
   [ "$(lxc_remote remote get-default)" = "foo" ]
 
+  # The server certificate follows the remote
+  [ -f "${LXD_CONF}/servercerts/foo.crt" ]
+  [ ! -e "${LXD_CONF}/servercerts/localhost.crt" ]
+  lxc_remote remote set-url foo "${LXD_ADDR}"
+  lxc_remote remote list | grep foo | grep -q "https://${LXD_ADDR}"
+  lxc_remote list foo:
+
   ! lxc_remote remote remove foo
   lxc_remote remote set-default local
   lxc_remote remote remove foo
@@ -59,6 +91,26 @@ test_remote_admin() {
     false
   fi
 
+  # Inspect and rename a trusted cert
+  fp=$(lxc_remote config trust list | awk '/^\| [0-9a-f]/ {print $2; exit}')
+  lxc_remote config trust show "${fp}" | grep -q "^Fingerprint: ${fp}"
+  lxc_remote config trust show "${fp}" | grep -q "^Key: "
+  printf "name: renamed\ntype: client\n" | lxc_remote config trust edit "${fp}"
+  lxc_remote config trust show "${fp}" | grep -q "^Name: renamed"
+  ! printf "name: renamed\ntype: server\n" | lxc_remote config trust edit "${fp}"
+
+  # Join tokens are single use
+  token=$(lxc config trust token)
+  LXC_TOKEN_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  (
+    set -e
+    export LXD_CONF=${LXC_TOKEN_DIR}
+    lxc_remote remote add token-test "${token}"
+    lxc_remote info token-test: | grep -q "auth: trusted"
+    ! lxc_remote remote add token-test2 "${token}"
+  )
+  rm -rf "${LXC_TOKEN_DIR}"
+
   # Check that we can add domains with valid certs without confirmation:
 
   # avoid default high port behind some proxies:
@@ -12,6 +12,55 @@ test_server_config() {
   lxc config unset core.trust_password
   lxc config show | grep -q -v "trust_password"
 
+  # test runtime logging configuration
+  lxc config set core.log_level debug
+  lxc config get core.log_level | grep -q debug
+  ! lxc config set core.log_level foo
+  lxc config set core.log_target udp://127.0.0.1:514
+  ! lxc config set core.log_target http://127.0.0.1
+  lxc config unset core.log_target
+  lxc config set core.syslog_address udp://127.0.0.1:514
+  ! lxc config set core.syslog_address syslog
+  ! lxc config set core.syslog_address http://127.0.0.1
+  lxc config set core.log_console true
+  lxc config unset core.log_console
+  lxc config unset core.syslog_address
+  lxc config unset core.log_level
+
+  # test usage history sampling configuration
+  lxc config set core.usage_history_interval 5
+  lxc config get core.usage_history_interval | grep -q 5
+  ! lxc config set core.usage_history_interval foo
+  lxc config unset core.usage_history_interval
+
+  # test webhooks configuration
+  lxc config set webhooks.urls "http://127.0.0.1:8080/hook,https://example.com/lxd"
+  lxc config set webhooks.events container-stopped,container-oom
+  lxc config set webhooks.secret foo
+  ! lxc config show | grep -q "secret: foo"
+  ! lxc config set webhooks.urls ftp://127.0.0.1/hook
+  ! lxc config set webhooks.events container-exploded
+  lxc config unset webhooks.urls
+  lxc config unset webhooks.events
+  lxc config unset webhooks.secret
+
+  # test limits overcommit validation
+  ensure_import_testimage
+  ! lxc config set limits.memory_overcommit 0.5
+  ! lxc config set limits.overcommit_action foo
+  lxc config set limits.memory_overcommit 1
+  lxc config set limits.cpu_overcommit 1
+  ! lxc init testimage overcommit -c limits.memory=1000TB
+  ! lxc init testimage overcommit -c limits.cpu=100000
+  lxc init testimage overcommit -c limits.memory=1MB
+  ! lxc config set overcommit limits.memory 1000TB
+  lxc config set limits.overcommit_action warn
+  ! lxc config set overcommit limits.memory 1000TB
+  lxc delete overcommit
+  lxc config unset limits.overcommit_action
+  lxc config unset limits.cpu_overcommit
+  lxc config unset limits.memory_overcommit
+
   # test untrusted server GET
   my_curl -X GET "https://$(cat "${LXD_SERVERCONFIG_DIR}/lxd.addr")/1.0" | grep -v -q environment
 }
@@ -0,0 +1,24 @@
+test_shiftfs() {
+  if ! lxc info | grep -A10 "kernel_features:" | grep -q 'shiftfs: "true"'; then
+    echo "==> SKIP: shiftfs isn't available"
+    return
+  fi
+
+  ensure_import_testimage
+
+  # The rootfs isn't shifted on disk but the container still owns its files
+  lxc launch testimage shiftfs
+  [ "$(stat -c %u "${LXD_DIR}/containers/shiftfs/rootfs/bin")" = "0" ]
+  [ "$(lxc exec shiftfs -- stat -c %u /bin)" = "0" ]
+  lxc stop shiftfs --force
+  ! grep -q " - shiftfs " /proc/self/mountinfo
+
+  # A start failing after the mark got placed doesn't leave it behind
+  lxc config set shiftfs raw.lxc "lxc.hook.pre-mount = /bin/false"
+  ! lxc start shiftfs
+  ! grep -q " - shiftfs " /proc/self/mountinfo
+  lxc config unset shiftfs raw.lxc
+  lxc start shiftfs
+
+  lxc delete shiftfs --force
+}
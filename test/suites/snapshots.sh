@@ -68,6 +68,47 @@ snapshots() {
     [ ! -d "${LXD_DIR}/snapshots/foo/tester-two" ]
   fi
 
+  # generated names and descriptions
+  lxc snapshot foo --description "Before upgrade"
+  lxc info foo | grep -q "Before upgrade"
+
+  # snapshot size, only unknown on the dir backend
+  snap=$(my_curl "https://${LXD_ADDR}/1.0/containers/foo/snapshots?recursion=1" | jq -r '.metadata[0].name' | cut -d/ -f2)
+  if [ "$lxd_backend" = "dir" ]; then
+    my_curl "https://${LXD_ADDR}/1.0/containers/foo/snapshots/${snap}" | jq -e '.metadata.size == -1'
+  else
+    my_curl "https://${LXD_ADDR}/1.0/containers/foo/snapshots/${snap}" | jq -e '.metadata.size >= 0'
+    my_curl "https://${LXD_ADDR}/1.0/containers/foo/snapshots?recursion=1" | jq -e '.metadata[0].size >= 0'
+  fi
+
+  # the size lookup can be skipped
+  my_curl "https://${LXD_ADDR}/1.0/containers/foo/snapshots?recursion=1&size=false" | jq -e '.metadata[0].size == -1'
+  lxc config set foo snapshots.pattern "backup-%d"
+  lxc snapshot foo
+  lxc snapshot foo
+  lxc info foo | grep -q "backup-0"
+  lxc info foo | grep -q "backup-1"
+  lxc config set foo snapshots.pattern "fixed"
+  lxc snapshot foo
+  lxc snapshot foo
+  lxc info foo | grep -q "fixed-0"
+  ! lxc config set foo snapshots.pattern "a/b"
+  lxc config unset foo snapshots.pattern
+  lxc delete "foo/backup-*" "foo/fixed*"
+
+  # wildcard and multiple deletions
+  lxc snapshot foo glob0
+  lxc snapshot foo glob1
+  lxc snapshot foo keep
+  lxc delete "foo/glob*"
+  ! lxc info foo | grep -q glob
+  lxc info foo | grep -q keep
+  ! lxc delete "foo/nomatch*"
+  echo no | lxc delete -i foo/keep
+  lxc info foo | grep -q keep
+  echo yes | lxc delete -i foo/keep
+  ! lxc info foo | grep -q keep
+
   lxc snapshot foo namechange
   # FIXME: make this backend agnostic
   if [ "$lxd_backend" = "dir" ]; then
@@ -0,0 +1,18 @@
+test_warnings() {
+  lxc warning list
+
+  # Record a fake warning straight in the database
+  sqlite3 "${LXD_DIR}/lxd.db" "INSERT INTO warnings (type, entity, message, first_seen_date, last_seen_date) VALUES ('test_warning', 'foo', 'Test warning', datetime('now'), datetime('now'));"
+  id=$(sqlite3 "${LXD_DIR}/lxd.db" "SELECT id FROM warnings WHERE type='test_warning';")
+  lxc warning list | grep -q "Test warning"
+
+  # Acknowledged warnings are hidden by default
+  lxc warning ack "${id}"
+  ! lxc warning list | grep -q "Test warning"
+  lxc warning list --all | grep -q "Test warning"
+
+  lxc warning delete "${id}"
+  ! lxc warning list --all | grep -q "Test warning"
+  ! lxc warning delete "${id}"
+  ! lxc warning ack foo
+}